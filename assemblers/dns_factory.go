@@ -12,16 +12,36 @@ import (
 	"github.com/kubeshark/worker/misc/wcap"
 	"github.com/kubeshark/worker/pkg/api"
 	"github.com/kubeshark/worker/pkg/extensions"
+	dnsExt "github.com/kubeshark/worker/pkg/extensions/dns"
 	"github.com/rs/zerolog/log"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
+// dnsFlow identifies a DNS transaction by the client/server 5-tuple of its
+// UDP datagrams and the transaction ID.
+type dnsFlow struct {
+	clientIP   string
+	clientPort string
+	serverIP   string
+	serverPort string
+	id         uint16
+}
+
+// dnsQuery is a transaction waiting for its response.
+type dnsQuery struct {
+	streamId       int64
+	request        *api.GenericMessage
+	connectionInfo *api.ConnectionInfo
+	createdAt      time.Time
+}
+
 type dnsFactory struct {
 	pcapId        string
 	assembler     *TcpAssembler
 	outputChannel chan *api.OutputChannelItem
 	streamsMap    api.TcpStreamMap
-	idMap         map[uint16]int64
+	queries       map[dnsFlow]*dnsQuery
+	queryTimeout  time.Duration
 	opts          *misc.Opts
 }
 
@@ -37,7 +57,8 @@ func NewDnsFactory(
 		assembler:     assembler,
 		outputChannel: outputChannel,
 		streamsMap:    streamsMap,
-		idMap:         make(map[uint16]int64),
+		queries:       make(map[dnsFlow]*dnsQuery),
+		queryTimeout:  GetDnsQueryTimeout(),
 		opts:          opts,
 	}
 }
@@ -56,20 +77,102 @@ func (factory *dnsFactory) writePacket(ci gopacket.CaptureInfo, data []byte, pca
 	})
 }
 
-func (factory *dnsFactory) handlePacket(packet gopacket.Packet, dnsID uint16) {
+func (factory *dnsFactory) handlePacket(packet gopacket.Packet, dns *layers.DNS) {
+	connectionInfo := factory.connectionInfo(packet, dns)
+	if connectionInfo == nil {
+		return
+	}
+
+	flow := dnsFlow{
+		clientIP:   connectionInfo.ClientIP,
+		clientPort: connectionInfo.ClientPort,
+		serverIP:   connectionInfo.ServerIP,
+		serverPort: connectionInfo.ServerPort,
+		id:         dns.ID,
+	}
+
+	// Retransmissions of a query and its response share the PCAP of the
+	// first packet of the transaction.
+	query, ok := factory.queries[flow]
+	if !ok {
+		query = &dnsQuery{
+			connectionInfo: connectionInfo,
+			createdAt:      time.Now(),
+		}
+		if factory.assembler.captureMode != ItemCapture {
+			query.streamId = factory.streamsMap.NextId()
+		}
+		factory.queries[flow] = query
+	}
+
 	var pcapName string
 	if factory.assembler.captureMode != ItemCapture {
-		var id int64
-		var ok bool
-		id, ok = factory.idMap[dnsID]
-		if !ok {
-			id = factory.streamsMap.NextId()
-			factory.idMap[dnsID] = id
-		}
-		pcapName = misc.BuildUdpPcapFilename(id)
+		pcapName = misc.BuildUdpPcapFilename(query.streamId)
 	}
 
 	factory.writeWithEthernetLayer(packet, pcapName)
+
+	if !dns.QR {
+		if query.request == nil {
+			query.request = &api.GenericMessage{
+				IsRequest:   true,
+				CaptureTime: packet.Metadata().Timestamp,
+				CaptureSize: packet.Metadata().CaptureLength,
+				Payload:     dnsExt.MapDNSLayerToRequest(dns),
+			}
+		}
+		return
+	}
+
+	delete(factory.queries, flow)
+
+	res := &api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: packet.Metadata().Timestamp,
+		CaptureSize: packet.Metadata().CaptureLength,
+		Payload:     dnsExt.MapDNSLayerToResponse(dns),
+	}
+
+	// The query wasn't captured, so the questions echoed in the response
+	// stand in for it.
+	req := query.request
+	if req == nil {
+		req = &api.GenericMessage{
+			IsRequest:   true,
+			CaptureTime: res.CaptureTime,
+			CaptureSize: res.CaptureSize,
+			Payload:     dnsExt.MapDNSLayerToRequest(dns),
+		}
+	}
+
+	if factory.emitItem(query, req, res) && len(dns.Answers) > 0 && len(dns.Questions) > 0 {
+		resolver.K8sResolver.SaveResolution(dns.Answers[0].IP.String(), &api.Resolution{
+			Name: string(dns.Questions[0].Name),
+		}, watch.Added)
+	}
+}
+
+// expire emits the queries that are pending since before `olderThan` as
+// timed out entries.
+func (factory *dnsFactory) expire(olderThan time.Time) {
+	for flow, query := range factory.queries {
+		if query.createdAt.After(olderThan) {
+			continue
+		}
+
+		delete(factory.queries, flow)
+
+		if query.request == nil {
+			continue
+		}
+
+		factory.emitItem(query, query.request, &api.GenericMessage{
+			IsRequest:   false,
+			CaptureTime: query.request.CaptureTime,
+			CaptureSize: 0,
+			Payload:     dnsExt.TimeoutResponse(),
+		})
+	}
 }
 
 func (factory *dnsFactory) writeWithEthernetLayer(packet gopacket.Packet, pcapName string) {
@@ -143,65 +246,71 @@ func (factory *dnsFactory) writeWithEthernetLayer(packet gopacket.Packet, pcapNa
 	factory.writePacket(info, outgoingPacket, pcapName)
 }
 
-func (factory *dnsFactory) emitItem(packet gopacket.Packet, dns *layers.DNS) {
-	connetionInfo := &api.ConnectionInfo{}
+// connectionInfo returns the client and server endpoints of a DNS message,
+// the client being the sender of queries.
+func (factory *dnsFactory) connectionInfo(packet gopacket.Packet, dns *layers.DNS) *api.ConnectionInfo {
+	var srcIP, dstIP string
 
 	// IPv4 layer
 	ipv4Layer := packet.Layer(layers.LayerTypeIPv4)
 	if ipv4Layer != nil {
 		_ipv4Layer := ipv4Layer.(*layers.IPv4)
-		connetionInfo.ClientIP = _ipv4Layer.DstIP.String()
-		connetionInfo.ServerIP = _ipv4Layer.SrcIP.String()
+		srcIP = _ipv4Layer.SrcIP.String()
+		dstIP = _ipv4Layer.DstIP.String()
 	}
 
 	// IPv6 layer
 	ipv6Layer := packet.Layer(layers.LayerTypeIPv6)
 	if ipv4Layer == nil && ipv6Layer != nil {
 		_ipv6Layer := ipv6Layer.(*layers.IPv6)
-		connetionInfo.ClientIP = _ipv6Layer.DstIP.String()
-		connetionInfo.ServerIP = _ipv6Layer.SrcIP.String()
+		srcIP = _ipv6Layer.SrcIP.String()
+		dstIP = _ipv6Layer.DstIP.String()
 	}
 
 	if ipv4Layer == nil && ipv6Layer == nil {
-		return
+		return nil
 	}
 
 	// UDP layer
 	udpLayer := packet.Layer(layers.LayerTypeUDP)
 	if udpLayer == nil {
-		return
+		return nil
 	}
 
 	_udpLayer := udpLayer.(*layers.UDP)
-	if _udpLayer.SrcPort != 53 {
-		return
+	srcPort := fmt.Sprintf("%d", _udpLayer.SrcPort)
+	dstPort := fmt.Sprintf("%d", _udpLayer.DstPort)
+
+	if dns.QR {
+		return &api.ConnectionInfo{
+			ClientIP:   dstIP,
+			ClientPort: dstPort,
+			ServerIP:   srcIP,
+			ServerPort: srcPort,
+		}
 	}
 
-	connetionInfo.ClientPort = fmt.Sprintf("%d", _udpLayer.DstPort)
-	connetionInfo.ServerPort = fmt.Sprintf("%d", _udpLayer.SrcPort)
-
-	dnsExtension := extensions.ExtensionsMap["dns"]
-
-	req := api.GenericMessage{
-		IsRequest:   true,
-		CaptureTime: packet.Metadata().Timestamp,
-		CaptureSize: packet.Metadata().CaptureLength,
-		Payload:     mapDNSLayerToRequest(dns),
+	return &api.ConnectionInfo{
+		ClientIP:   srcIP,
+		ClientPort: srcPort,
+		ServerIP:   dstIP,
+		ServerPort: dstPort,
 	}
+}
 
-	res := api.GenericMessage{
-		IsRequest:   false,
-		CaptureTime: packet.Metadata().Timestamp,
-		CaptureSize: packet.Metadata().CaptureLength,
-		Payload:     mapDNSLayerToResponse(dns),
+// emitItem sends the pair of a transaction to the output channel and reports
+// whether it was sent.
+func (factory *dnsFactory) emitItem(query *dnsQuery, req *api.GenericMessage, res *api.GenericMessage) bool {
+	connetionInfo := query.connectionInfo
+	if connetionInfo.ServerPort != "53" {
+		return false
 	}
 
+	dnsExtension := extensions.ExtensionsMap["dns"]
+
 	pcapId := factory.pcapId
 	if pcapId == "" {
-		id, ok := factory.idMap[dns.ID]
-		if ok {
-			pcapId = misc.BuildUdpPcapFilename(id)
-		}
+		pcapId = misc.BuildUdpPcapFilename(query.streamId)
 	}
 
 	item := api.OutputChannelItem{
@@ -211,8 +320,8 @@ func (factory *dnsFactory) emitItem(packet gopacket.Packet, dns *layers.DNS) {
 		Timestamp:      req.CaptureTime.UnixNano() / int64(time.Millisecond),
 		ConnectionInfo: connetionInfo,
 		Pair: &api.RequestResponsePair{
-			Request:  req,
-			Response: res,
+			Request:  *req,
+			Response: *res,
 		},
 	}
 
@@ -230,16 +339,10 @@ func (factory *dnsFactory) emitItem(packet gopacket.Packet, dns *layers.DNS) {
 	}
 
 	if !isTargeted {
-		return
+		return false
 	}
 
 	factory.outputChannel <- &item
 
-	if len(dns.Answers) > 0 && len(dns.Questions) > 0 {
-		resolver.K8sResolver.SaveResolution(dns.Answers[0].IP.String(), &api.Resolution{
-			Name: string(dns.Questions[0].Name),
-		}, watch.Added)
-	}
-
-	delete(factory.idMap, dns.ID)
+	return true
 }
@@ -22,6 +22,8 @@ const (
 	CloseTimedoutTcpChannelsIntervalMsDefaultValue = 1000
 	CloseTimedoutTcpChannelsIntervalMsMinValue     = 10
 	CloseTimedoutTcpChannelsIntervalMsMaxValue     = 10000
+	DnsQueryTimeoutMsEnvVarName                    = "DNS_QUERY_TIMEOUT_MS"
+	DnsQueryTimeoutMsDefaultValue                  = 10000
 )
 
 func GetMaxBufferedPagesTotal() int {
@@ -52,6 +54,14 @@ func GetTcpChannelTimeoutMs() time.Duration {
 	return time.Duration(valueFromEnv) * time.Millisecond
 }
 
+func GetDnsQueryTimeout() time.Duration {
+	valueFromEnv, err := strconv.Atoi(os.Getenv(DnsQueryTimeoutMsEnvVarName))
+	if err != nil {
+		return DnsQueryTimeoutMsDefaultValue * time.Millisecond
+	}
+	return time.Duration(valueFromEnv) * time.Millisecond
+}
+
 func GetCloseTimedoutTcpChannelsInterval() time.Duration {
 	defaultDuration := CloseTimedoutTcpChannelsIntervalMsDefaultValue * time.Millisecond
 	rangeMin := CloseTimedoutTcpChannelsIntervalMsMinValue
@@ -245,8 +245,9 @@ func (a *TcpAssembler) ProcessPacket(packetInfo source.TcpPacketInfo, dumpPacket
 		a.processTCPPacket(packet, tcp.(*layers.TCP))
 	}
 
+	// DNS over TCP is dissected once its stream is reassembled
 	dns := packet.Layer(layers.LayerTypeDNS)
-	if dns != nil {
+	if dns != nil && tcp == nil {
 		a.processDNSPacket(packet, dns.(*layers.DNS))
 	}
 }
@@ -263,8 +264,13 @@ func (a *TcpAssembler) processTCPPacket(packet gopacket.Packet, tcp *layers.TCP)
 func (a *TcpAssembler) processDNSPacket(packet gopacket.Packet, dns *layers.DNS) {
 	diagnose.AppStats.IncDnsPacketsCount()
 
-	a.dnsFactory.handlePacket(packet, dns.ID)
-	a.dnsFactory.emitItem(packet, dns)
+	a.dnsFactory.handlePacket(packet, dns)
+}
+
+// FlushDnsQueries emits every DNS query that is still waiting for its
+// response as a timed out entry.
+func (a *TcpAssembler) FlushDnsQueries() {
+	a.dnsFactory.expire(time.Now())
 }
 
 func (a *TcpAssembler) DumpStreamPool() {
@@ -282,6 +288,8 @@ func (a *TcpAssembler) PeriodicClean() {
 	stats.ClosedConnections += closed
 	stats.FlushedConnections += flushed
 	a.Unlock()
+
+	a.dnsFactory.expire(time.Now().Add(-a.dnsFactory.queryTimeout))
 }
 
 func (a *TcpAssembler) DumpStats() AssemblerStats {
@@ -1,8 +1,6 @@
 package assemblers
 
 import (
-	"encoding/binary"
-
 	"github.com/dreadl0ck/tlsx"
 	"github.com/kubeshark/gopacket"
 	"github.com/kubeshark/gopacket/layers" // pulls in all layers decoders
@@ -17,7 +15,6 @@ type tcpReassemblyStream struct {
 	tcpState        *reassembly.TCPSimpleFSM
 	fsmerr          bool
 	optchecker      reassembly.TCPOptionCheck
-	isDNS           bool
 	tcpStream       *tcpStream
 	notignorefsmerr bool
 	optcheck        bool
@@ -29,7 +26,6 @@ func NewTcpReassemblyStream(ident string, tcp *layers.TCP, fsmOptions reassembly
 		ident:      ident,
 		tcpState:   reassembly.NewTCPSimpleFSM(fsmOptions),
 		optchecker: reassembly.NewTCPOptionCheck(),
-		isDNS:      tcp.SrcPort == 53 || tcp.DstPort == 53,
 		tcpStream:  stream,
 	}
 }
@@ -86,34 +82,7 @@ func (t *tcpReassemblyStream) ReassembledSG(sg reassembly.ScatterGather, ac reas
 		return
 	}
 	data := sg.Fetch(length)
-	if t.isDNS {
-		dns := &layers.DNS{}
-		var decoded []gopacket.LayerType
-		if len(data) < 2 {
-			if len(data) > 0 {
-				sg.KeepFrom(0)
-			}
-			return
-		}
-		dnsSize := binary.BigEndian.Uint16(data[:2])
-		missing := int(dnsSize) - len(data[2:])
-		diagnose.ErrorsMap.Debug("dnsSize: %d, missing: %d", dnsSize, missing)
-		if missing > 0 {
-			diagnose.ErrorsMap.Debug("Missing some bytes: %d", missing)
-			sg.KeepFrom(0)
-			return
-		}
-		p := gopacket.NewDecodingLayerParser(layers.LayerTypeDNS, dns)
-		err := p.DecodeLayers(data[2:], &decoded)
-		if err != nil {
-			diagnose.ErrorsMap.SilentError("DNS-parser", "Failed to decode DNS: %v", err)
-		} else {
-			diagnose.ErrorsMap.Debug("DNS: %s", gopacket.LayerDump(dns))
-		}
-		if len(data) > 2+int(dnsSize) {
-			sg.KeepFrom(2 + int(dnsSize))
-		}
-	} else if t.tcpStream.GetIsTargeted() {
+	if t.tcpStream.GetIsTargeted() {
 		if length > 0 {
 			// This is where we pass the reassembled information onwards
 			// This channel is read by an tcpReader object
@@ -12,6 +12,8 @@ import (
 	"github.com/kubeshark/worker/misc"
 	"github.com/kubeshark/worker/pkg/api"
 	"github.com/kubeshark/worker/pkg/extensions"
+	httpExt "github.com/kubeshark/worker/pkg/extensions/http"
+	kafkaExt "github.com/kubeshark/worker/pkg/extensions/kafka"
 	"github.com/kubeshark/worker/queue"
 	"github.com/kubeshark/worker/server"
 	"github.com/kubeshark/worker/utils"
@@ -30,7 +32,11 @@ var staleTimeoutSeconds = flag.Int("staletimeout", 30, "Max time in seconds to k
 var servicemesh = flag.Bool("servicemesh", false, "Record decrypted traffic if the cluster is configured with a service mesh and with mtls")
 var tls = flag.Bool("tls", false, "Enable TLS tracing")
 var packetCapture = flag.String("packet-capture", "libpcap", "Packet capture backend. Possible values: libpcap, af_packet")
+var bpfExcludedPorts = flag.String("bpf-excluded-ports", "443", "Comma separated list of the ports that are left out of the capture. Set it to empty to dissect the TLS handshakes on 443")
 var procfs = flag.String("procfs", "/proc", "The procfs directory, used when mapping host volumes into a container")
+var grpcReflection = flag.Bool("grpc-reflection", true, "Learn the gRPC descriptors from the captured responses of the server reflection service")
+var httpMaxDecodedBodySize = flag.Int("http-max-decoded-body-size", 16*1024*1024, "Max size in bytes of the HTTP bodies that are decoded from their Content-Encoding")
+var kafkaMaxRecordsSize = flag.Int("kafka-max-records-size", 4*1024*1024, "Max size in bytes of the decompressed Kafka records that are decoded for an entry")
 
 // development
 var debug = flag.Bool("debug", false, "Enable debug mode")
@@ -59,6 +65,10 @@ func main() {
 	misc.InitDataDir()
 	vm.Init()
 
+	httpExt.SetGrpcReflectionLearning(*grpcReflection)
+	httpExt.SetMaxDecodedBodySize(*httpMaxDecodedBodySize)
+	kafkaExt.SetMaxRecordsSize(*kafkaMaxRecordsSize)
+
 	run()
 }
 
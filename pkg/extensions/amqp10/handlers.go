@@ -0,0 +1,301 @@
+package amqp10
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	directionClient = "client"
+	directionServer = "server"
+)
+
+// The deliveries that a single disposition settles are capped
+const maxDispositionRange = 1024
+
+type linkKey struct {
+	channel uint16
+	handle  uint64
+}
+
+type link struct {
+	name    string
+	address string
+}
+
+// delivery is a transfer whose message is carried by several frames.
+type delivery struct {
+	frame   *AMQP10Frame
+	key     string
+	payload []byte
+}
+
+// message is a frame that's shown, with the keys of its pairs. A
+// disposition can settle several deliveries at once, and a transfer that's
+// already settled isn't answered.
+type message struct {
+	frame     *AMQP10Frame
+	keys      []string
+	isRequest bool
+	settled   bool
+}
+
+// connectionState keeps track of the sessions and the links of one side of
+// a connection. A session is known by the side that began it and the
+// channel it used, since each side picks the channels of its own.
+type connectionState struct {
+	direction  string
+	sessions   map[uint16]string
+	links      map[linkKey]link
+	deliveries map[linkKey]*delivery
+}
+
+func newConnectionState(isClient bool) *connectionState {
+	direction := directionServer
+	if isClient {
+		direction = directionClient
+	}
+	return &connectionState{
+		direction:  direction,
+		sessions:   make(map[uint16]string),
+		links:      make(map[linkKey]link),
+		deliveries: make(map[linkKey]*delivery),
+	}
+}
+
+func (s *connectionState) peer() string {
+	if s.direction == directionClient {
+		return directionServer
+	}
+	return directionClient
+}
+
+// readMessage reads the protocol headers and the frames up to the next one
+// that's shown. It tells whether a header or a frame was read, even when it
+// returns an error.
+func (s *connectionState) readMessage(b *bufio.Reader) (msg *message, identified bool, err error) {
+	for {
+		var isHeader bool
+		if isHeader, err = isProtocolHeader(b); err != nil {
+			return
+		}
+		if isHeader {
+			if _, err = readProtocolHeader(b); err != nil {
+				return
+			}
+			identified = true
+			continue
+		}
+
+		var f *frame
+		if f, err = readFrame(b); err != nil {
+			return
+		}
+		identified = true
+		if f == nil {
+			continue
+		}
+		if msg = s.handleFrame(f); msg != nil {
+			return
+		}
+	}
+}
+
+func (s *connectionState) handleFrame(f *frame) *message {
+	details := &AMQP10Frame{
+		Performative: describedTypes[f.code].name,
+		Direction:    s.direction,
+		Channel:      f.channel,
+		Fields:       f.fields,
+		Condition:    errorCondition(f.fields),
+	}
+	isClient := s.direction == directionClient
+
+	switch f.code {
+	case descriptorSaslInit, descriptorSaslOutcome:
+		return &message{frame: details, keys: []string{"sasl"}, isRequest: f.code == descriptorSaslInit}
+	case descriptorOpen:
+		return &message{frame: details, keys: []string{"open"}, isRequest: isClient}
+	case descriptorClose:
+		return &message{frame: details, keys: []string{"close"}, isRequest: isClient}
+	case descriptorBegin:
+		// The begin that answers another one refers to the channel of the
+		// side that sent it.
+		if remoteChannel, ok := fieldUint(f.fields, "remoteChannel"); ok {
+			session := fmt.Sprintf("%s%d", s.peer(), remoteChannel)
+			s.sessions[f.channel] = session
+			return &message{frame: details, keys: []string{"begin_" + session}, isRequest: false}
+		}
+		session := fmt.Sprintf("%s%d", s.direction, f.channel)
+		s.sessions[f.channel] = session
+		return &message{frame: details, keys: []string{"begin_" + session}, isRequest: true}
+	case descriptorEnd:
+		return &message{frame: details, keys: []string{"end_" + s.session(f.channel)}, isRequest: isClient}
+	case descriptorAttach:
+		handle, _ := fieldUint(f.fields, "handle")
+		l := link{name: fieldString(f.fields, "name")}
+		// The address is the one of the node of the server, which is the
+		// target of the links that send to it and the source of the others.
+		terminus := "source"
+		if fieldBool(f.fields, "role") != isClient {
+			terminus = "target"
+		}
+		if t, ok := f.fields[terminus].(map[string]interface{}); ok {
+			l.address = fieldString(t, "address")
+		}
+		s.links[linkKey{f.channel, handle}] = l
+		details.Link, details.Address = l.name, l.address
+		return &message{frame: details, keys: []string{"attach_" + l.name}, isRequest: isClient}
+	case descriptorDetach:
+		handle, _ := fieldUint(f.fields, "handle")
+		key := linkKey{f.channel, handle}
+		l := s.links[key]
+		delete(s.links, key)
+		delete(s.deliveries, key)
+		details.Link, details.Address = l.name, l.address
+		return &message{frame: details, keys: []string{"detach_" + l.name}, isRequest: isClient}
+	case descriptorTransfer:
+		return s.handleTransfer(f, details)
+	case descriptorDisposition:
+		return s.handleDisposition(f, details)
+	}
+	return nil
+}
+
+// session returns the key of the session of a channel, which is the channel
+// itself if the session wasn't seen beginning.
+func (s *connectionState) session(channel uint16) string {
+	if session, ok := s.sessions[channel]; ok {
+		return session
+	}
+	return fmt.Sprintf("%s%d", s.direction, channel)
+}
+
+// deliveryKey is the key of a delivery, whose id is given by the side that
+// sent it within a session.
+func (s *connectionState) deliveryKey(channel uint16, sender string, id uint64) string {
+	return fmt.Sprintf("delivery_%s_%s_%d", s.session(channel), sender, id)
+}
+
+// handleTransfer joins the frames of a transfer, and returns it once its
+// message is complete.
+func (s *connectionState) handleTransfer(f *frame, details *AMQP10Frame) *message {
+	handle, _ := fieldUint(f.fields, "handle")
+	key := linkKey{f.channel, handle}
+
+	d, ok := s.deliveries[key]
+	if !ok {
+		l := s.links[key]
+		details.Link, details.Address = l.name, l.address
+		id, _ := fieldUint(f.fields, "deliveryId")
+		d = &delivery{frame: details, key: s.deliveryKey(f.channel, s.direction, id)}
+	} else if fieldBool(f.fields, "settled") {
+		// The frames that follow can settle the delivery
+		d.frame.Fields["settled"] = true
+	}
+
+	d.frame.MessageSize += len(f.payload)
+	if len(d.payload)+len(f.payload) > maxMessageSize {
+		d.frame.Truncated = true
+	} else {
+		d.payload = append(d.payload, f.payload...)
+	}
+
+	if fieldBool(f.fields, "more") {
+		s.deliveries[key] = d
+		return nil
+	}
+	delete(s.deliveries, key)
+
+	aborted := fieldBool(f.fields, "aborted")
+	if aborted {
+		d.frame.Fields["aborted"] = true
+	} else if !d.frame.Truncated {
+		// The sections that can't be decoded are left out
+		d.frame.Message, _ = readMessage(d.payload)
+	}
+	return &message{
+		frame:     d.frame,
+		keys:      []string{d.key},
+		isRequest: true,
+		settled:   aborted || fieldBool(d.frame.Fields, "settled"),
+	}
+}
+
+// handleDisposition returns a disposition with the keys of the deliveries it
+// settles. The role of a receiver refers to the deliveries that the peer
+// sent.
+func (s *connectionState) handleDisposition(f *frame, details *AMQP10Frame) *message {
+	details.Outcome = outcome(f.fields)
+
+	sender := s.direction
+	if fieldBool(f.fields, "role") {
+		sender = s.peer()
+	}
+	first, _ := fieldUint(f.fields, "first")
+	last, ok := fieldUint(f.fields, "last")
+	if !ok || last < first {
+		last = first
+	}
+	if last-first >= maxDispositionRange {
+		last = first + maxDispositionRange - 1
+	}
+
+	var keys []string
+	for id := first; id <= last; id++ {
+		keys = append(keys, s.deliveryKey(f.channel, sender, id))
+	}
+	return &message{frame: details, keys: keys, isRequest: false}
+}
+
+func identity(tcpID *api.TcpID, isClient bool, key string) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, key)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, key)
+}
+
+// handleMessage pairs a message. Either side can send a request, like a
+// transfer, so the pair is outgoing when the client sent the request.
+func handleMessage(progress *api.ReadProgress, tcpID *api.TcpID, isClient bool, captureTime time.Time, emitter api.Emitter, msg *message, reqResMatcher *requestResponseMatcher) {
+	var items []*api.OutputChannelItem
+	if msg.settled {
+		items = append(items, reqResMatcher.prepareSettled(msg.frame, captureTime, progress.Current()))
+	} else {
+		for _, key := range msg.keys {
+			var item *api.OutputChannelItem
+			if msg.isRequest {
+				item = reqResMatcher.registerRequest(identity(tcpID, isClient, key), msg.frame, captureTime, progress.Current())
+			} else {
+				item = reqResMatcher.registerResponse(identity(tcpID, isClient, key), msg.frame, captureTime, progress.Current())
+			}
+			if item != nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	for _, item := range items {
+		request := item.Pair.Request.Payload.(AMQP10Payload).Data.(*AMQP10Wrapper).Details.(*AMQP10Frame)
+		if isClient {
+			item.ConnectionInfo = &api.ConnectionInfo{
+				ClientIP:   tcpID.SrcIP,
+				ClientPort: tcpID.SrcPort,
+				ServerIP:   tcpID.DstIP,
+				ServerPort: tcpID.DstPort,
+			}
+		} else {
+			item.ConnectionInfo = &api.ConnectionInfo{
+				ClientIP:   tcpID.DstIP,
+				ClientPort: tcpID.DstPort,
+				ServerIP:   tcpID.SrcIP,
+				ServerPort: tcpID.SrcPort,
+			}
+		}
+		item.ConnectionInfo.IsOutgoing = request.Direction == directionClient
+		emitter.Emit(item)
+	}
+}
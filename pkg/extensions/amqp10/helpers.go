@@ -0,0 +1,167 @@
+package amqp10
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// representFrame represents a request or a response, which are both frames.
+// The response of a transfer that's settled when it's sent is empty.
+func representFrame(frame map[string]interface{}, selectorPrefix string) (rep []interface{}) {
+	rep = make([]interface{}, 0)
+
+	performative, _ := frame["performative"].(string)
+	if performative == "" {
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Performative",
+			Value:    performative,
+			Selector: selectorPrefix + `.performative`,
+		},
+		{
+			Name:     "Direction",
+			Value:    frame["direction"],
+			Selector: selectorPrefix + `.direction`,
+		},
+		{
+			Name:     "Channel",
+			Value:    frame["channel"],
+			Selector: selectorPrefix + `.channel`,
+		},
+		{
+			Name:     "Link",
+			Value:    frame["link"],
+			Selector: selectorPrefix + `.link`,
+		},
+		{
+			Name:     "Address",
+			Value:    frame["address"],
+			Selector: selectorPrefix + `.address`,
+		},
+		{
+			Name:     "Outcome",
+			Value:    frame["outcome"],
+			Selector: selectorPrefix + `.outcome`,
+		},
+		{
+			Name:     "Condition",
+			Value:    frame["condition"],
+			Selector: selectorPrefix + `.condition`,
+		},
+		{
+			Name:     "Message Size",
+			Value:    frame["messageSize"],
+			Selector: selectorPrefix + `.messageSize`,
+		},
+		{
+			Name:     "Truncated",
+			Value:    frame["truncated"],
+			Selector: selectorPrefix + `.truncated`,
+		},
+	})
+	rep = append(rep, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if fields, ok := frame["fields"].(map[string]interface{}); ok && len(fields) > 0 {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Fields",
+			Data:  representMapAsTable(fields, selectorPrefix+`.fields`),
+		})
+	}
+
+	message, ok := frame["message"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	selectorPrefix += `.message`
+
+	sections := []struct {
+		title string
+		key   string
+	}{
+		{"Header", "header"},
+		{"Delivery Annotations", "deliveryAnnotations"},
+		{"Message Annotations", "messageAnnotations"},
+		{"Properties", "properties"},
+		{"Application Properties", "applicationProperties"},
+	}
+	for _, section := range sections {
+		if m, ok := message[section.key].(map[string]interface{}); ok && len(m) > 0 {
+			rep = append(rep, api.SectionData{
+				Type:  api.TABLE,
+				Title: section.title,
+				Data:  representMapAsTable(m, fmt.Sprintf("%s.%s", selectorPrefix, section.key)),
+			})
+		}
+	}
+
+	switch message["bodyType"] {
+	case sectionNames[descriptorData]:
+		// An empty body is omitted from the JSON
+		body, _ := message["body"].(string)
+		rep = append(rep, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Body",
+			Encoding: "base64",
+			Data:     body,
+			Selector: selectorPrefix + `.body`,
+		})
+	case sectionNames[descriptorAmqpValue], sectionNames[descriptorAmqpSequence]:
+		body, _ := json.MarshalIndent(message["body"], "", "  ")
+		rep = append(rep, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Body",
+			MimeType: "application/json",
+			Data:     string(body),
+			Selector: selectorPrefix + `.body`,
+		})
+	}
+
+	if footer, ok := message["footer"].(map[string]interface{}); ok && len(footer) > 0 {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Footer",
+			Data:  representMapAsTable(footer, selectorPrefix+`.footer`),
+		})
+	}
+
+	return
+}
+
+// representMapAsTable represents a map, giving the values that are lists or
+// maps themselves as JSON.
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		value := mapToTable[key]
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			data, _ := json.Marshal(value)
+			value = string(data)
+		}
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    value,
+			Selector: fmt.Sprintf(`%s["%s"]`, selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
@@ -0,0 +1,148 @@
+package amqp10
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "amqp10",
+	Version:         "1.0",
+	Abbreviation:    "AMQP",
+	LongName:        "Advanced Message Queuing Protocol 1.0",
+	Macro:           "amqp10",
+	BackgroundColor: "#e65100",
+	ForegroundColor: "#ffffff",
+	FontSize:        12,
+	ReferenceLink:   "https://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-overview-v1.0-os.html",
+	Ports:           []string{"5671", "5672"},
+	Layer4:          "tcp",
+	Priority:        14,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	state := newConnectionState(reader.GetIsClient())
+
+	for {
+		msg, identified, err := state.readMessage(b)
+		if identified {
+			reader.GetParent().SetProtocol(&protocol)
+		}
+		if err != nil {
+			return err
+		}
+		handleMessage(reader.GetReadProgress(), reader.GetTcpID(), reader.GetIsClient(), reader.GetCaptureTime(), reader.GetEmitter(), msg, reqResMatcher)
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	method := entry.Request["performative"].(string)
+	methodQuery := fmt.Sprintf(`request.performative == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	fields, _ := entry.Request["fields"].(map[string]interface{})
+	if address, ok := entry.Request["address"].(string); ok {
+		summary = address
+		summaryQuery = fmt.Sprintf(`request.address == "%s"`, address)
+	} else if link, ok := entry.Request["link"].(string); ok {
+		summary = link
+		summaryQuery = fmt.Sprintf(`request.link == "%s"`, link)
+	} else if containerID, ok := fields["containerId"].(string); ok {
+		summary = containerID
+		summaryQuery = fmt.Sprintf(`request.fields.containerId == "%s"`, containerID)
+	} else if mechanism, ok := fields["mechanism"].(string); ok {
+		summary = mechanism
+		summaryQuery = fmt.Sprintf(`request.fields.mechanism == "%s"`, mechanism)
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       0,
+		StatusQuery:  "",
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representFrame(request, `request`)
+	repResponse := representFrame(response, `response`)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`amqp10`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
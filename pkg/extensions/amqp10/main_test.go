@@ -0,0 +1,335 @@
+package amqp10
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "amqp10", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"amqp10": `protocol.name == "amqp10"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+// The types that are encoded differently than their Go counterparts
+type (
+	symbol    string
+	list      []interface{}
+	keyValues []interface{}
+	desc      struct {
+		code  uint64
+		value interface{}
+	}
+)
+
+// encode encodes a value of the AMQP type system.
+func encode(v interface{}) []byte {
+	var b bytes.Buffer
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(0x40)
+	case bool:
+		if v {
+			b.WriteByte(0x41)
+		} else {
+			b.WriteByte(0x42)
+		}
+	case uint16:
+		b.WriteByte(0x60)
+		_ = binary.Write(&b, binary.BigEndian, v)
+	case uint32:
+		b.WriteByte(0x70)
+		_ = binary.Write(&b, binary.BigEndian, v)
+	case uint64:
+		b.WriteByte(0x80)
+		_ = binary.Write(&b, binary.BigEndian, v)
+	case string:
+		b.WriteByte(0xa1)
+		b.WriteByte(byte(len(v)))
+		b.WriteString(v)
+	case symbol:
+		b.WriteByte(0xa3)
+		b.WriteByte(byte(len(v)))
+		b.WriteString(string(v))
+	case []byte:
+		b.WriteByte(0xb0)
+		_ = binary.Write(&b, binary.BigEndian, uint32(len(v)))
+		b.Write(v)
+	case list:
+		b.WriteByte(0xc0)
+		b.Write(compound(v))
+	case keyValues:
+		b.WriteByte(0xc1)
+		b.Write(compound(v))
+	case desc:
+		b.Write([]byte{0x00, 0x53, byte(v.code)})
+		b.Write(encode(v.value))
+	}
+	return b.Bytes()
+}
+
+func compound(elements []interface{}) []byte {
+	var data []byte
+	for _, element := range elements {
+		data = append(data, encode(element)...)
+	}
+	return append([]byte{byte(len(data) + 1), byte(len(elements))}, data...)
+}
+
+func performative(code uint64, fields ...interface{}) desc {
+	return desc{code, list(fields)}
+}
+
+func encodeFrame(frameType byte, channel uint16, body ...desc) []byte {
+	var data []byte
+	for _, d := range body {
+		data = append(data, encode(d)...)
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(8+len(data)))
+	b[4] = 2
+	b[5] = frameType
+	binary.BigEndian.PutUint16(b[6:], channel)
+	return append(b, data...)
+}
+
+// withPayload appends the payload of a transfer to its frame.
+func withPayload(frame []byte, payload []byte) []byte {
+	frame = append(frame, payload...)
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)))
+	return frame
+}
+
+var (
+	saslHeader = []byte("AMQP\x03\x01\x00\x00")
+	amqpHeader = []byte("AMQP\x00\x01\x00\x00")
+)
+
+func dissect(t *testing.T, client []byte, server []byte) map[string]*api.Entry {
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, client, server) {
+		key := entry.Request["performative"].(string)
+		if link, ok := entry.Request["link"].(string); ok {
+			key += "_" + link
+		}
+		if id, ok := entry.Request["fields"].(map[string]interface{})["deliveryId"]; ok {
+			key += fmt.Sprintf("_%v", id)
+		}
+		entries[key] = entry
+	}
+	return entries
+}
+
+func TestDissect(t *testing.T) {
+	accepted := desc{0x24, list{}}
+	rejected := desc{0x25, list{desc{0x1d, list{symbol("amqp:not-allowed"), "no"}}}}
+	properties := performative(descriptorProperties, "order-1", nil, nil, "created")
+	applicationProperties := desc{descriptorApplicationProperties, keyValues{"region", "eu", "priority", uint32(3)}}
+
+	// The first message is split in two transfers in the middle of its data
+	// section.
+	firstMessage := append(encode(properties), encode(applicationProperties)...)
+	firstMessage = append(firstMessage, encode(desc{descriptorData, []byte(`{"id":1}`)})...)
+	split := len(firstMessage) - 4
+
+	client := bytes.Join([][]byte{
+		saslHeader,
+		encodeFrame(frameTypeSASL, 0, performative(descriptorSaslInit, symbol("PLAIN"), []byte("\x00guest\x00guest"))),
+		amqpHeader,
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorOpen, "client", "broker.local")),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorBegin, nil, uint32(0), uint32(100), uint32(100))),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorAttach, "sender-link", uint32(0), false, nil, nil,
+			desc{0x28, list{}}, desc{0x29, list{"orders"}})),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), uint32(0), []byte{0}, uint32(0), false, true)), firstMessage[:split]),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), nil, nil, nil, nil, false)), firstMessage[split:]),
+		encodeFrame(frameTypeAMQP, 0),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), uint32(1), []byte{1}, uint32(0), true)),
+			encode(desc{descriptorAmqpValue, keyValues{symbol("event"), "created"}})),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), uint32(2), []byte{2}, uint32(0), false)),
+			encode(desc{descriptorAmqpSequence, list{"a", "b"}})),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), uint32(3), []byte{3}, uint32(0), false)),
+			encode(desc{descriptorData, []byte{0xff}})),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorAttach, "receiver-link", uint32(1), true, nil, nil,
+			desc{0x28, list{"events"}}, desc{0x29, list{}})),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorFlow, uint32(0), uint32(100), uint32(4), uint32(100), uint32(1), uint32(0), uint32(10))),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorDisposition, true, uint32(0), nil, true, accepted)),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorDetach, uint32(0), true)),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorEnd)),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorClose)),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		saslHeader,
+		encodeFrame(frameTypeSASL, 0, performative(descriptorSaslMechanisms, symbol("PLAIN"))),
+		encodeFrame(frameTypeSASL, 0, performative(descriptorSaslOutcome, uint16(0))),
+		amqpHeader,
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorOpen, "broker")),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorBegin, uint16(0), uint32(0), uint32(100), uint32(100))),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorAttach, "sender-link", uint32(7), true, nil, nil,
+			desc{0x28, list{}}, desc{0x29, list{"orders"}})),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorAttach, "receiver-link", uint32(8), false, nil, nil,
+			desc{0x28, list{"events"}}, desc{0x29, list{}})),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorDisposition, true, uint32(0), nil, true, accepted)),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorDisposition, true, uint32(2), uint32(3), true, rejected)),
+		withPayload(encodeFrame(frameTypeAMQP, 1, performative(descriptorTransfer, uint32(8), uint32(0), []byte{0}, uint32(0), false)),
+			encode(desc{descriptorData, []byte("hello")})),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorDetach, uint32(7), true)),
+		encodeFrame(frameTypeAMQP, 1, performative(descriptorEnd)),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorClose, desc{0x1d, list{symbol("amqp:connection:forced")}})),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 13)
+	dissector := NewDissector()
+
+	sasl := entries["sasl-init"]
+	assert.Equal(t, "PLAIN", sasl.Request["fields"].(map[string]interface{})["mechanism"])
+	assert.Equal(t, "sasl-outcome", sasl.Response["performative"])
+	assert.Equal(t, float64(0), sasl.Response["fields"].(map[string]interface{})["code"])
+
+	open := entries["open"]
+	assert.Equal(t, "broker.local", open.Request["fields"].(map[string]interface{})["hostname"])
+	assert.Equal(t, "broker", open.Response["fields"].(map[string]interface{})["containerId"])
+	summary := dissector.Summarize(open)
+	assert.Equal(t, "client", summary.Summary)
+	assert.Equal(t, `request.fields.containerId == "client"`, summary.SummaryQuery)
+
+	begin := entries["begin"]
+	assert.Equal(t, float64(1), begin.Response["channel"])
+	assert.Equal(t, float64(0), begin.Response["fields"].(map[string]interface{})["remoteChannel"])
+
+	attach := entries["attach_sender-link"]
+	assert.Equal(t, "orders", attach.Request["address"])
+	assert.Equal(t, "orders", attach.Response["address"])
+	assert.Equal(t, "target", attach.Request["fields"].(map[string]interface{})["target"].(map[string]interface{})["$type"])
+	assert.Equal(t, "events", entries["attach_receiver-link"].Request["address"])
+
+	transfer := entries["transfer_sender-link_0"]
+	assert.True(t, transfer.Outgoing)
+	assert.Equal(t, "orders", transfer.Request["address"])
+	assert.Equal(t, float64(len(firstMessage)), transfer.Request["messageSize"])
+	message := transfer.Request["message"].(map[string]interface{})
+	assert.Equal(t, "order-1", message["properties"].(map[string]interface{})["messageId"])
+	assert.Equal(t, "created", message["properties"].(map[string]interface{})["subject"])
+	assert.Equal(t, "eu", message["applicationProperties"].(map[string]interface{})["region"])
+	assert.Equal(t, "data", message["bodyType"])
+	assert.Equal(t, `{"id":1}`, message["bodyText"])
+	assert.Equal(t, "disposition", transfer.Response["performative"])
+	assert.Equal(t, "accepted", transfer.Response["outcome"])
+	summary = dissector.Summarize(transfer)
+	assert.Equal(t, "transfer", summary.Method)
+	assert.Equal(t, "orders", summary.Summary)
+	assert.Equal(t, `request.address == "orders"`, summary.SummaryQuery)
+
+	settled := entries["transfer_sender-link_1"]
+	assert.Equal(t, "amqp-value", settled.Request["message"].(map[string]interface{})["bodyType"])
+	assert.Equal(t, "created", settled.Request["message"].(map[string]interface{})["body"].(map[string]interface{})["event"])
+	assert.Equal(t, "", settled.Response["performative"])
+
+	for _, key := range []string{"transfer_sender-link_2", "transfer_sender-link_3"} {
+		assert.Equal(t, "rejected", entries[key].Response["outcome"])
+		assert.Equal(t, "amqp:not-allowed", entries[key].Response["condition"])
+	}
+	sequence := entries["transfer_sender-link_2"].Request["message"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"a", "b"}, sequence["body"])
+	data := entries["transfer_sender-link_3"].Request["message"].(map[string]interface{})
+	assert.Equal(t, "/w==", data["body"])
+	assert.Nil(t, data["bodyText"])
+
+	incoming := entries["transfer_receiver-link_0"]
+	assert.False(t, incoming.Outgoing)
+	assert.Equal(t, "server", incoming.Request["direction"])
+	assert.Equal(t, "events", incoming.Request["address"])
+	assert.Equal(t, "hello", incoming.Request["message"].(map[string]interface{})["bodyText"])
+	assert.Equal(t, "client", incoming.Response["direction"])
+	assert.Equal(t, "accepted", incoming.Response["outcome"])
+
+	detach := entries["detach_sender-link"]
+	assert.Equal(t, true, detach.Response["fields"].(map[string]interface{})["closed"])
+
+	assert.Contains(t, entries, "end")
+	assert.Equal(t, "amqp:connection:forced", entries["close"].Response["condition"])
+	assert.NotContains(t, entries, "flow")
+}
+
+func TestDissectAborted(t *testing.T) {
+	client := bytes.Join([][]byte{
+		amqpHeader,
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorBegin, nil, uint32(0), uint32(100), uint32(100))),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), uint32(0), []byte{0}, uint32(0), false, true)), []byte{0x00, 0x53}),
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), nil, nil, nil, nil, false, nil, nil, nil, true)),
+	}, nil)
+	server := bytes.Join([][]byte{
+		amqpHeader,
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorBegin, uint16(0), uint32(0), uint32(100), uint32(100))),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 2)
+	transfer := entries["transfer_0"]
+	assert.Equal(t, true, transfer.Request["fields"].(map[string]interface{})["aborted"])
+	assert.Nil(t, transfer.Request["message"])
+	assert.Equal(t, "", transfer.Response["performative"])
+}
+
+func TestDissectEmptyData(t *testing.T) {
+	client := bytes.Join([][]byte{
+		amqpHeader,
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorBegin, nil, uint32(0), uint32(100), uint32(100))),
+		withPayload(encodeFrame(frameTypeAMQP, 0, performative(descriptorTransfer, uint32(0), uint32(0), []byte{0}, uint32(0), true, false)),
+			encode(desc{descriptorData, []byte{}})),
+	}, nil)
+	server := bytes.Join([][]byte{
+		amqpHeader,
+		encodeFrame(frameTypeAMQP, 0, performative(descriptorBegin, uint16(0), uint32(0), uint32(100), uint32(100))),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	transfer := entries["transfer_0"]
+	if !assert.NotNil(t, transfer) {
+		return
+	}
+	message := transfer.Request["message"].(map[string]interface{})
+	assert.Equal(t, sectionNames[descriptorData], message["bodyType"])
+	assert.Equal(t, "", message["body"])
+
+	// A body that's missing altogether is represented as empty
+	delete(message, "body")
+	_, err := NewDissector().Represent(transfer.Request, transfer.Response)
+	assert.Nil(t, err)
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, input := range [][]byte{
+		[]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		[]byte("AMQP\x00\x00\x09\x01"),
+	} {
+		for _, isClient := range []bool{true, false} {
+			reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+			err := dissector.Dissect(bufio.NewReader(bytes.NewReader(input)), reader)
+			assert.NotNil(t, err)
+		}
+	}
+}
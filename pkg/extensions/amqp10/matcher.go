@@ -0,0 +1,122 @@
+package amqp10
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{key}` where the key is
+// `open`, `close` or `sasl` for a connection, `begin_{session}` or `end_{session}` for a session,
+// `attach_{link}` or `detach_{link}` for a link and `delivery_{session}_{sender}_{delivery_id}`
+// for a transfer and its disposition.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *AMQP10Frame, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestAMQP10Message := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: AMQP10Payload{
+			Data: &AMQP10Wrapper{
+				Method:  request.Performative,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseAMQP10Message := response.(*api.GenericMessage)
+		if responseAMQP10Message.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestAMQP10Message, responseAMQP10Message)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestAMQP10Message)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *AMQP10Frame, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseAMQP10Message := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: AMQP10Payload{
+			Data: &AMQP10Wrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestAMQP10Message := request.(*api.GenericMessage)
+		if !requestAMQP10Message.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestAMQP10Message, &responseAMQP10Message)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseAMQP10Message)
+	return nil
+}
+
+// prepareSettled pairs a transfer that's settled when it's sent, which gets
+// no disposition, with an empty response.
+func (matcher *requestResponseMatcher) prepareSettled(request *AMQP10Frame, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestAMQP10Message := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: AMQP10Payload{
+			Data: &AMQP10Wrapper{
+				Method:  request.Performative,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseAMQP10Message := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: AMQP10Payload{
+			Data: &AMQP10Wrapper{
+				Method:  "",
+				Url:     "",
+				Details: &AMQP10Frame{Fields: map[string]interface{}{}},
+			},
+		},
+	}
+	return matcher.preparePair(&requestAMQP10Message, &responseAMQP10Message)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestAMQP10Message *api.GenericMessage, responseAMQP10Message *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestAMQP10Message.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestAMQP10Message,
+			Response: *responseAMQP10Message,
+		},
+	}
+}
@@ -0,0 +1,233 @@
+package amqp10
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	maxFrameSize   = 16 * 1024 * 1024
+	maxMessageSize = 1024 * 1024
+)
+
+const (
+	frameTypeAMQP = 0
+	frameTypeSASL = 1
+)
+
+// The protocol ids of the header that starts a connection, or its layer of
+// SASL. The one of TLS is followed by a handshake that can't be read.
+const (
+	protocolIDAMQP = 0
+	protocolIDTLS  = 2
+	protocolIDSASL = 3
+)
+
+var protocolHeader = []byte("AMQP")
+
+// frame is a frame with a performative, the payload is the part of a
+// message carried by a transfer.
+type frame struct {
+	frameType byte
+	channel   uint16
+	code      uint64
+	fields    map[string]interface{}
+	payload   []byte
+}
+
+// isProtocolHeader tells whether the next bytes are a protocol header, which
+// the client sends first and the server sends back.
+func isProtocolHeader(b *bufio.Reader) (bool, error) {
+	peeked, err := b.Peek(len(protocolHeader))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(peeked, protocolHeader), nil
+}
+
+// readProtocolHeader reads `AMQP` followed by the protocol id and the
+// version 1.0.0, and returns the protocol id.
+func readProtocolHeader(b *bufio.Reader) (byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(b, header); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(header[:4], protocolHeader) || header[5] != 1 || header[6] != 0 || header[7] != 0 {
+		return 0, fmt.Errorf("unsupported protocol header: %q", header)
+	}
+	switch header[4] {
+	case protocolIDAMQP, protocolIDSASL:
+		return header[4], nil
+	case protocolIDTLS:
+		return 0, errors.New("TLS is negotiated within the connection")
+	}
+	return 0, fmt.Errorf("unknown protocol id: %d", header[4])
+}
+
+// readFrame reads a frame and decodes its performative. The frame is nil
+// for an empty frame, which is a heartbeat.
+func readFrame(b *bufio.Reader) (*frame, error) {
+	var size uint32
+	if err := binary.Read(b, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 8 || size > maxFrameSize {
+		return nil, fmt.Errorf("invalid frame size: %d", size)
+	}
+
+	data := make([]byte, size-4)
+	if _, err := io.ReadFull(b, data); err != nil {
+		return nil, err
+	}
+	doff := int(data[0]) * 4
+	if doff < 8 || doff > int(size) {
+		return nil, fmt.Errorf("invalid data offset: %d", data[0])
+	}
+
+	f := &frame{
+		frameType: data[1],
+		channel:   binary.BigEndian.Uint16(data[2:4]),
+	}
+	// The extended header is skipped
+	body := data[doff-4:]
+	if len(body) == 0 {
+		if f.frameType != frameTypeAMQP {
+			return nil, errors.New("empty SASL frame")
+		}
+		return nil, nil
+	}
+
+	d := &decoder{data: body}
+	code, value, err := d.readDescribed()
+	if err != nil {
+		return nil, err
+	}
+	switch f.frameType {
+	case frameTypeAMQP:
+		if code < descriptorOpen || code > descriptorClose {
+			return nil, fmt.Errorf("unknown performative: %#x", code)
+		}
+	case frameTypeSASL:
+		if code < descriptorSaslMechanisms || code > descriptorSaslOutcome {
+			return nil, fmt.Errorf("unknown SASL frame: %#x", code)
+		}
+	default:
+		return nil, fmt.Errorf("unknown frame type: %d", f.frameType)
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("performative isn't a list: %#x", code)
+	}
+
+	f.code = code
+	f.fields = fields
+	f.payload = body[d.pos:]
+	if len(f.payload) > 0 && code != descriptorTransfer {
+		return nil, fmt.Errorf("unexpected payload of %s", describedTypes[code].name)
+	}
+	return f, nil
+}
+
+// readMessage decodes the sections of a message.
+func readMessage(payload []byte) (*AMQP10Message, error) {
+	message := &AMQP10Message{}
+	var data []byte
+	var sequence []interface{}
+
+	d := &decoder{data: payload}
+	for d.remaining() > 0 {
+		code, value, err := d.readDescribed()
+		if err != nil {
+			return message, err
+		}
+
+		switch code {
+		case descriptorHeader:
+			message.Header, _ = value.(map[string]interface{})
+		case descriptorDeliveryAnnotations:
+			message.DeliveryAnnotations, _ = value.(map[string]interface{})
+		case descriptorMessageAnnotations:
+			message.MessageAnnotations, _ = value.(map[string]interface{})
+		case descriptorProperties:
+			message.Properties, _ = value.(map[string]interface{})
+		case descriptorApplicationProperties:
+			message.ApplicationProperties, _ = value.(map[string]interface{})
+		case descriptorData:
+			encoded, ok := value.(string)
+			if !ok {
+				return message, errors.New("data section isn't a binary")
+			}
+			// Binaries are decoded to base64, the data sections are joined together
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			message.BodyType = sectionNames[code]
+			data = append(data, decoded...)
+		case descriptorAmqpSequence:
+			list, ok := value.([]interface{})
+			if !ok {
+				return message, errors.New("amqp-sequence section isn't a list")
+			}
+			message.BodyType = sectionNames[code]
+			sequence = append(sequence, list...)
+		case descriptorAmqpValue:
+			message.BodyType = sectionNames[code]
+			message.Body = value
+		case descriptorFooter:
+			message.Footer, _ = value.(map[string]interface{})
+		default:
+			return message, fmt.Errorf("unknown section: %#x", code)
+		}
+	}
+
+	switch message.BodyType {
+	case sectionNames[descriptorData]:
+		message.Body = base64.StdEncoding.EncodeToString(data)
+		if utf8.Valid(data) {
+			message.BodyText = string(data)
+		}
+		message.BodySize = len(data)
+	case sectionNames[descriptorAmqpSequence]:
+		message.Body = sequence
+	}
+	return message, nil
+}
+
+func fieldUint(fields map[string]interface{}, name string) (uint64, bool) {
+	v, ok := fields[name].(uint64)
+	return v, ok
+}
+
+func fieldString(fields map[string]interface{}, name string) string {
+	v, _ := fields[name].(string)
+	return v
+}
+
+func fieldBool(fields map[string]interface{}, name string) bool {
+	v, _ := fields[name].(bool)
+	return v
+}
+
+// errorCondition returns the condition of the error of a performative, or
+// of the rejected state of a disposition.
+func errorCondition(fields map[string]interface{}) string {
+	if e, ok := fields["error"].(map[string]interface{}); ok {
+		return fieldString(e, "condition")
+	}
+	if state, ok := fields["state"].(map[string]interface{}); ok {
+		return errorCondition(state)
+	}
+	return ""
+}
+
+// outcome returns the name of the delivery state of a disposition.
+func outcome(fields map[string]interface{}) string {
+	if state, ok := fields["state"].(map[string]interface{}); ok {
+		return fieldString(state, "$type")
+	}
+	return ""
+}
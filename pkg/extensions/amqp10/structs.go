@@ -0,0 +1,57 @@
+package amqp10
+
+import (
+	"encoding/json"
+)
+
+type AMQP10Payload struct {
+	Data interface{}
+}
+
+type AMQP10Payloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h AMQP10Payload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type AMQP10Wrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+// AMQP10Frame is a performative along with the message of a transfer. The
+// link and the address are the ones of the attach that the frame refers to,
+// the outcome is the delivery state of a disposition and the condition is
+// the one of an error, if any.
+type AMQP10Frame struct {
+	Performative string                 `json:"performative"`
+	Direction    string                 `json:"direction"`
+	Channel      uint16                 `json:"channel"`
+	Fields       map[string]interface{} `json:"fields"`
+	Link         string                 `json:"link,omitempty"`
+	Address      string                 `json:"address,omitempty"`
+	Outcome      string                 `json:"outcome,omitempty"`
+	Condition    string                 `json:"condition,omitempty"`
+	Message      *AMQP10Message         `json:"message,omitempty"`
+	MessageSize  int                    `json:"messageSize,omitempty"`
+	Truncated    bool                   `json:"truncated,omitempty"`
+}
+
+// AMQP10Message holds the sections of a message. A body of data sections is
+// base64 encoded and also given as text when it's valid UTF-8, the body of
+// an amqp-value or amqp-sequence section is decoded.
+type AMQP10Message struct {
+	Header                map[string]interface{} `json:"header,omitempty"`
+	DeliveryAnnotations   map[string]interface{} `json:"deliveryAnnotations,omitempty"`
+	MessageAnnotations    map[string]interface{} `json:"messageAnnotations,omitempty"`
+	Properties            map[string]interface{} `json:"properties,omitempty"`
+	ApplicationProperties map[string]interface{} `json:"applicationProperties,omitempty"`
+	BodyType              string                 `json:"bodyType,omitempty"`
+	Body                  interface{}            `json:"body,omitempty"`
+	BodyText              string                 `json:"bodyText,omitempty"`
+	BodySize              int                    `json:"bodySize,omitempty"`
+	Footer                map[string]interface{} `json:"footer,omitempty"`
+}
@@ -0,0 +1,513 @@
+package amqp10
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+const maxDepth = 32
+
+// describedType is a composite type whose list of fields is named by the
+// spec, like a performative or a section of a message.
+type describedType struct {
+	name   string
+	fields []string
+}
+
+const (
+	descriptorOpen                  = 0x10
+	descriptorBegin                 = 0x11
+	descriptorAttach                = 0x12
+	descriptorFlow                  = 0x13
+	descriptorTransfer              = 0x14
+	descriptorDisposition           = 0x15
+	descriptorDetach                = 0x16
+	descriptorEnd                   = 0x17
+	descriptorClose                 = 0x18
+	descriptorSaslMechanisms        = 0x40
+	descriptorSaslInit              = 0x41
+	descriptorSaslChallenge         = 0x42
+	descriptorSaslResponse          = 0x43
+	descriptorSaslOutcome           = 0x44
+	descriptorHeader                = 0x70
+	descriptorDeliveryAnnotations   = 0x71
+	descriptorMessageAnnotations    = 0x72
+	descriptorProperties            = 0x73
+	descriptorApplicationProperties = 0x74
+	descriptorData                  = 0x75
+	descriptorAmqpSequence          = 0x76
+	descriptorAmqpValue             = 0x77
+	descriptorFooter                = 0x78
+)
+
+var describedTypes = map[uint64]describedType{
+	descriptorOpen: {"open", []string{
+		"containerId", "hostname", "maxFrameSize", "channelMax", "idleTimeOut", "outgoingLocales",
+		"incomingLocales", "offeredCapabilities", "desiredCapabilities", "properties",
+	}},
+	descriptorBegin: {"begin", []string{
+		"remoteChannel", "nextOutgoingId", "incomingWindow", "outgoingWindow", "handleMax",
+		"offeredCapabilities", "desiredCapabilities", "properties",
+	}},
+	descriptorAttach: {"attach", []string{
+		"name", "handle", "role", "sndSettleMode", "rcvSettleMode", "source", "target", "unsettled",
+		"incompleteUnsettled", "initialDeliveryCount", "maxMessageSize", "offeredCapabilities",
+		"desiredCapabilities", "properties",
+	}},
+	descriptorFlow: {"flow", []string{
+		"nextIncomingId", "incomingWindow", "nextOutgoingId", "outgoingWindow", "handle",
+		"deliveryCount", "linkCredit", "available", "drain", "echo", "properties",
+	}},
+	descriptorTransfer: {"transfer", []string{
+		"handle", "deliveryId", "deliveryTag", "messageFormat", "settled", "more", "rcvSettleMode",
+		"state", "resume", "aborted", "batchable",
+	}},
+	descriptorDisposition: {"disposition", []string{"role", "first", "last", "settled", "state", "batchable"}},
+	descriptorDetach:      {"detach", []string{"handle", "closed", "error"}},
+	descriptorEnd:         {"end", []string{"error"}},
+	descriptorClose:       {"close", []string{"error"}},
+	0x1d:                  {"error", []string{"condition", "description", "info"}},
+	0x23:                  {"received", []string{"sectionNumber", "sectionOffset"}},
+	0x24:                  {"accepted", []string{}},
+	0x25:                  {"rejected", []string{"error"}},
+	0x26:                  {"released", []string{}},
+	0x27:                  {"modified", []string{"deliveryFailed", "undeliverableHere", "messageAnnotations"}},
+	0x28: {"source", []string{
+		"address", "durable", "expiryPolicy", "timeout", "dynamic", "dynamicNodeProperties",
+		"distributionMode", "filter", "defaultOutcome", "outcomes", "capabilities",
+	}},
+	0x29:                     {"target", []string{"address", "durable", "expiryPolicy", "timeout", "dynamic", "dynamicNodeProperties", "capabilities"}},
+	0x30:                     {"delete-on-close", []string{}},
+	0x31:                     {"delete-on-no-links", []string{}},
+	0x32:                     {"delete-on-no-messages", []string{}},
+	0x33:                     {"delete-on-no-links-or-messages", []string{}},
+	descriptorSaslMechanisms: {"sasl-mechanisms", []string{"saslServerMechanisms"}},
+	descriptorSaslInit:       {"sasl-init", []string{"mechanism", "initialResponse", "hostname"}},
+	descriptorSaslChallenge:  {"sasl-challenge", []string{"challenge"}},
+	descriptorSaslResponse:   {"sasl-response", []string{"response"}},
+	descriptorSaslOutcome:    {"sasl-outcome", []string{"code", "additionalData"}},
+	descriptorHeader:         {"header", []string{"durable", "priority", "ttl", "firstAcquirer", "deliveryCount"}},
+	descriptorProperties: {"properties", []string{
+		"messageId", "userId", "to", "subject", "replyTo", "correlationId", "contentType",
+		"contentEncoding", "absoluteExpiryTime", "creationTime", "groupId", "groupSequence", "replyToGroupId",
+	}},
+}
+
+// The sections of a message that aren't lists
+var sectionNames = map[uint64]string{
+	descriptorDeliveryAnnotations:   "delivery-annotations",
+	descriptorMessageAnnotations:    "message-annotations",
+	descriptorApplicationProperties: "application-properties",
+	descriptorData:                  "data",
+	descriptorAmqpSequence:          "amqp-sequence",
+	descriptorAmqpValue:             "amqp-value",
+	descriptorFooter:                "footer",
+}
+
+// descriptorSymbols maps the symbolic descriptors to their numeric codes.
+var descriptorSymbols = map[string]uint64{
+	"amqp:delivery-annotations:map":   descriptorDeliveryAnnotations,
+	"amqp:message-annotations:map":    descriptorMessageAnnotations,
+	"amqp:application-properties:map": descriptorApplicationProperties,
+	"amqp:data:binary":                descriptorData,
+	"amqp:amqp-sequence:list":         descriptorAmqpSequence,
+	"amqp:amqp-value:*":               descriptorAmqpValue,
+	"amqp:footer:map":                 descriptorFooter,
+}
+
+func init() {
+	for code, t := range describedTypes {
+		descriptorSymbols[fmt.Sprintf("amqp:%s:list", t.name)] = code
+	}
+}
+
+// described is a value of a described type that isn't known, or not a list.
+type described struct {
+	descriptor interface{}
+	value      interface{}
+}
+
+// decoder decodes the values of the AMQP type system, turning them into
+// values that can be marshalled to JSON. Binaries are given in base64, the
+// keys of maps as strings and the known described lists as maps of their
+// fields.
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+var errShortValue = errors.New("value is too short")
+
+func (d *decoder) remaining() int {
+	return len(d.data) - d.pos
+}
+
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || d.remaining() < n {
+		return nil, errShortValue
+	}
+	v := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	v, err := d.take(1)
+	if err != nil {
+		return 0, err
+	}
+	return v[0], nil
+}
+
+// readDescribed reads a described value and returns its numeric descriptor
+// along with the value, which is a map of fields for a known list.
+func (d *decoder) readDescribed() (uint64, interface{}, error) {
+	constructor, err := d.readByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	if constructor != 0x00 {
+		return 0, nil, fmt.Errorf("expected a described type instead of %#x", constructor)
+	}
+	return d.readDescribedValue()
+}
+
+func (d *decoder) readDescribedValue() (uint64, interface{}, error) {
+	descriptor, err := d.readValue()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var code uint64
+	var known bool
+	switch v := descriptor.(type) {
+	case uint64:
+		code, known = v, true
+	case string:
+		code, known = descriptorSymbols[v]
+	}
+
+	if known {
+		if t, ok := describedTypes[code]; ok {
+			list, err := d.readValue()
+			if err != nil {
+				return 0, nil, err
+			}
+			return code, namedFields(t, list), nil
+		}
+	}
+	value, err := d.readValue()
+	if err != nil {
+		return 0, nil, err
+	}
+	if !known {
+		return 0, described{descriptor: descriptor, value: value}, nil
+	}
+	return code, value, nil
+}
+
+// namedFields names the fields of a list, leaving out the ones that are
+// null. A value that isn't a list is kept as it is.
+func namedFields(t describedType, value interface{}) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	fields := make(map[string]interface{})
+	for i, v := range list {
+		if v == nil {
+			continue
+		}
+		if i < len(t.fields) {
+			fields[t.fields[i]] = v
+		} else {
+			fields[fmt.Sprint(i)] = v
+		}
+	}
+	return fields
+}
+
+func (d *decoder) readValue() (interface{}, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, errors.New("value is nested too deep")
+	}
+
+	constructor, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if constructor == 0x00 {
+		code, value, err := d.readDescribedValue()
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := value.(described); ok {
+			return map[string]interface{}{"descriptor": fmt.Sprint(v.descriptor), "value": v.value}, nil
+		}
+		if t, ok := describedTypes[code]; ok {
+			if fields, ok := value.(map[string]interface{}); ok {
+				fields["$type"] = t.name
+			}
+		}
+		return value, nil
+	}
+	return d.readPrimitive(constructor)
+}
+
+func (d *decoder) readPrimitive(constructor byte) (interface{}, error) {
+	switch constructor {
+	case 0x40:
+		return nil, nil
+	case 0x41:
+		return true, nil
+	case 0x42:
+		return false, nil
+	case 0x43, 0x44:
+		return uint64(0), nil
+	case 0x45:
+		return []interface{}{}, nil
+	case 0x50, 0x52, 0x53:
+		v, err := d.readByte()
+		return uint64(v), err
+	case 0x51, 0x54, 0x55:
+		v, err := d.readByte()
+		return int64(int8(v)), err
+	case 0x56:
+		v, err := d.readByte()
+		return v != 0, err
+	case 0x60:
+		v, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		return uint64(binary.BigEndian.Uint16(v)), nil
+	case 0x61:
+		v, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int16(binary.BigEndian.Uint16(v))), nil
+	case 0x70:
+		v, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return uint64(binary.BigEndian.Uint32(v)), nil
+	case 0x71:
+		v, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(v))), nil
+	case 0x72:
+		v, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(v))), nil
+	case 0x73:
+		v, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return string(rune(binary.BigEndian.Uint32(v))), nil
+	case 0x74:
+		v, err := d.take(4)
+		return fmt.Sprintf("%x", v), err
+	case 0x80:
+		v, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint64(v), nil
+	case 0x81:
+		v, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(v)), nil
+	case 0x82:
+		v, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(v)), nil
+	case 0x83:
+		v, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(int64(binary.BigEndian.Uint64(v))).UTC(), nil
+	case 0x84:
+		v, err := d.take(8)
+		return fmt.Sprintf("%x", v), err
+	case 0x94:
+		v, err := d.take(16)
+		return fmt.Sprintf("%x", v), err
+	case 0x98:
+		v, err := d.take(16)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:]), nil
+	case 0xa0, 0xb0:
+		v, err := d.readVariable(constructor == 0xb0)
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(v), nil
+	case 0xa1, 0xb1, 0xa3, 0xb3:
+		v, err := d.readVariable(constructor&0xf0 == 0xb0)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(v) {
+			return nil, errors.New("invalid string")
+		}
+		return string(v), nil
+	case 0xc0, 0xd0:
+		return d.readList(constructor == 0xd0)
+	case 0xc1, 0xd1:
+		return d.readMap(constructor == 0xd1)
+	case 0xe0, 0xf0:
+		return d.readArray(constructor == 0xf0)
+	}
+	return nil, fmt.Errorf("unknown constructor: %#x", constructor)
+}
+
+// readSize reads the size, or the count, of a variable or compound value,
+// which is a byte or a uint depending on the constructor.
+func (d *decoder) readSize(wide bool) (int, error) {
+	if !wide {
+		v, err := d.readByte()
+		return int(v), err
+	}
+	v, err := d.take(4)
+	if err != nil {
+		return 0, err
+	}
+	size := binary.BigEndian.Uint32(v)
+	if size > uint32(len(d.data)) {
+		return 0, fmt.Errorf("invalid size: %d", size)
+	}
+	return int(size), nil
+}
+
+func (d *decoder) readVariable(wide bool) ([]byte, error) {
+	size, err := d.readSize(wide)
+	if err != nil {
+		return nil, err
+	}
+	return d.take(size)
+}
+
+// readCompound reads the size and the count of a list or a map, and checks
+// that the elements end where the size says.
+func (d *decoder) readCompound(wide bool) (count int, end int, err error) {
+	size, err := d.readSize(wide)
+	if err != nil {
+		return
+	}
+	end = d.pos + size
+	if end > len(d.data) {
+		err = errShortValue
+		return
+	}
+	if count, err = d.readSize(wide); err != nil {
+		return
+	}
+	if count > end-d.pos {
+		err = fmt.Errorf("invalid count: %d", count)
+	}
+	return
+}
+
+func (d *decoder) readList(wide bool) ([]interface{}, error) {
+	count, end, err := d.readCompound(wide)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	if d.pos != end {
+		return nil, errors.New("list doesn't end where its size says")
+	}
+	return list, nil
+}
+
+func (d *decoder) readMap(wide bool) (map[string]interface{}, error) {
+	count, end, err := d.readCompound(wide)
+	if err != nil {
+		return nil, err
+	}
+	if count%2 != 0 {
+		return nil, fmt.Errorf("map has an odd count: %d", count)
+	}
+	m := make(map[string]interface{}, count/2)
+	for i := 0; i < count; i += 2 {
+		key, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	if d.pos != end {
+		return nil, errors.New("map doesn't end where its size says")
+	}
+	return m, nil
+}
+
+// readArray reads an array, whose elements share a single constructor.
+func (d *decoder) readArray(wide bool) ([]interface{}, error) {
+	count, end, err := d.readCompound(wide)
+	if err != nil {
+		return nil, err
+	}
+	constructor, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	var descriptor interface{}
+	if constructor == 0x00 {
+		if descriptor, err = d.readValue(); err != nil {
+			return nil, err
+		}
+		if constructor, err = d.readByte(); err != nil {
+			return nil, err
+		}
+	}
+
+	array := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		v, err := d.readPrimitive(constructor)
+		if err != nil {
+			return nil, err
+		}
+		if descriptor != nil {
+			v = map[string]interface{}{"descriptor": fmt.Sprint(descriptor), "value": v}
+		}
+		array = append(array, v)
+	}
+	if d.pos != end {
+		return nil, errors.New("array doesn't end where its size says")
+	}
+	return array, nil
+}
@@ -0,0 +1,190 @@
+package cql
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+)
+
+var errShortFrame = errors.New("frame body is shorter than its declared fields")
+
+// buffer is a cursor over a frame body. The notations in the comments are
+// the ones of the native protocol specification.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+func (b *buffer) next(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || len(b.data) < n {
+		b.err = errShortFrame
+		return nil
+	}
+	v := b.data[:n]
+	b.data = b.data[n:]
+	return v
+}
+
+// checkCount fails the buffer if count items of at least size bytes each
+// can't fit in what's left of it. The counts are read from the frame, so
+// they're checked before anything is allocated for them.
+func (b *buffer) checkCount(count int64, size int64) bool {
+	if b.err != nil {
+		return false
+	}
+	if count < 0 || count*size > int64(len(b.data)) {
+		b.err = errShortFrame
+		return false
+	}
+	return true
+}
+
+func (b *buffer) readByte() byte {
+	v := b.next(1)
+	if v == nil {
+		return 0
+	}
+	return v[0]
+}
+
+// [short]
+func (b *buffer) readShort() uint16 {
+	v := b.next(2)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(v)
+}
+
+// [int]
+func (b *buffer) readInt() int32 {
+	v := b.next(4)
+	if v == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(v))
+}
+
+// [long]
+func (b *buffer) readLong() int64 {
+	v := b.next(8)
+	if v == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+// [string]
+func (b *buffer) readString() string {
+	return string(b.next(int(b.readShort())))
+}
+
+// [long string]
+func (b *buffer) readLongString() string {
+	return string(b.next(int(b.readInt())))
+}
+
+// [string list]
+func (b *buffer) readStringList() []string {
+	n := int(b.readShort())
+	list := make([]string, 0)
+	for i := 0; i < n && b.err == nil; i++ {
+		list = append(list, b.readString())
+	}
+	return list
+}
+
+// [string map]
+func (b *buffer) readStringMap() map[string]string {
+	n := int(b.readShort())
+	m := make(map[string]string)
+	for i := 0; i < n && b.err == nil; i++ {
+		key := b.readString()
+		m[key] = b.readString()
+	}
+	return m
+}
+
+// [string multimap]
+func (b *buffer) readStringMultimap() map[string][]string {
+	n := int(b.readShort())
+	m := make(map[string][]string)
+	for i := 0; i < n && b.err == nil; i++ {
+		key := b.readString()
+		m[key] = b.readStringList()
+	}
+	return m
+}
+
+// [bytes], nil when the length is negative
+func (b *buffer) readBytes() []byte {
+	n := b.readInt()
+	if n < 0 {
+		return nil
+	}
+	return b.next(int(n))
+}
+
+// [short bytes]
+func (b *buffer) readShortBytes() []byte {
+	return b.next(int(b.readShort()))
+}
+
+// [bytes map]
+func (b *buffer) readBytesMap() map[string][]byte {
+	n := int(b.readShort())
+	m := make(map[string][]byte)
+	for i := 0; i < n && b.err == nil; i++ {
+		key := b.readString()
+		m[key] = b.readBytes()
+	}
+	return m
+}
+
+// [uuid]
+func (b *buffer) readUUID() []byte {
+	return b.next(16)
+}
+
+// [inetaddr]
+func (b *buffer) readInetAddr() string {
+	n := int(b.readByte())
+	if n != net.IPv4len && n != net.IPv6len {
+		if b.err == nil {
+			b.err = errors.New("invalid inet address length")
+		}
+		return ""
+	}
+	return net.IP(b.next(n)).String()
+}
+
+// [inet]
+func (b *buffer) readInet() string {
+	addr := b.readInetAddr()
+	port := b.readInt()
+	return net.JoinHostPort(addr, strconv.Itoa(int(port)))
+}
+
+// [consistency]
+func (b *buffer) readConsistency() string {
+	return consistencyName(b.readShort())
+}
+
+// [value] is like [bytes] but a length of -2 means that the value is not
+// set. Both unset and null values are returned as nil.
+func (b *buffer) readValue() []byte {
+	return b.readBytes()
+}
+
+// readQueryFlags reads the flags of the query parameters, which became an
+// [int] in v5.
+func (b *buffer) readQueryFlags(version byte) uint32 {
+	if version >= 5 {
+		return uint32(b.readInt())
+	}
+	return uint32(b.readByte())
+}
@@ -0,0 +1,638 @@
+package cql
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	queryFlagValues            = 0x0001
+	queryFlagSkipMetadata      = 0x0002
+	queryFlagPageSize          = 0x0004
+	queryFlagPagingState       = 0x0008
+	queryFlagSerialConsistency = 0x0010
+	queryFlagTimestamp         = 0x0020
+	queryFlagNamesForValues    = 0x0040
+	queryFlagKeyspace          = 0x0080
+	queryFlagNowInSeconds      = 0x0100
+
+	prepareFlagKeyspace = 0x01
+)
+
+// The least sizes of a column spec, a [string] name and an [option] type,
+// and of a cell, whose [bytes] length is given even if it's null
+const (
+	minColumnSpecSize = 4
+	minCellSize       = 4
+)
+
+const (
+	resultVoid         = 0x0001
+	resultRows         = 0x0002
+	resultSetKeyspace  = 0x0003
+	resultPrepared     = 0x0004
+	resultSchemaChange = 0x0005
+
+	metadataFlagGlobalTablesSpec = 0x0001
+	metadataFlagHasMorePages     = 0x0002
+	metadataFlagNoMetadata       = 0x0004
+	metadataFlagMetadataChanged  = 0x0008
+)
+
+var resultKinds = map[int32]string{
+	resultVoid:         "Void",
+	resultRows:         "Rows",
+	resultSetKeyspace:  "Set_keyspace",
+	resultPrepared:     "Prepared",
+	resultSchemaChange: "Schema_change",
+}
+
+var batchTypes = map[byte]string{
+	0: "LOGGED",
+	1: "UNLOGGED",
+	2: "COUNTER",
+}
+
+const (
+	errServer          = 0x0000
+	errProtocol        = 0x000a
+	errAuthentication  = 0x0100
+	errUnavailable     = 0x1000
+	errOverloaded      = 0x1001
+	errIsBootstrapping = 0x1002
+	errTruncate        = 0x1003
+	errWriteTimeout    = 0x1100
+	errReadTimeout     = 0x1200
+	errReadFailure     = 0x1300
+	errFunctionFailure = 0x1400
+	errWriteFailure    = 0x1500
+	errCDCWriteFailure = 0x1600
+	errCASWriteUnknown = 0x1700
+	errSyntax          = 0x2000
+	errUnauthorized    = 0x2100
+	errInvalid         = 0x2200
+	errConfig          = 0x2300
+	errAlreadyExists   = 0x2400
+	errUnprepared      = 0x2500
+)
+
+var errorNames = map[int32]string{
+	errServer:          "Server error",
+	errProtocol:        "Protocol error",
+	errAuthentication:  "Authentication error",
+	errUnavailable:     "Unavailable exception",
+	errOverloaded:      "Overloaded",
+	errIsBootstrapping: "Is_bootstrapping",
+	errTruncate:        "Truncate_error",
+	errWriteTimeout:    "Write_timeout",
+	errReadTimeout:     "Read_timeout",
+	errReadFailure:     "Read_failure",
+	errFunctionFailure: "Function_failure",
+	errWriteFailure:    "Write_failure",
+	errCDCWriteFailure: "CDC_write_failure",
+	errCASWriteUnknown: "CAS_write_unknown",
+	errSyntax:          "Syntax_error",
+	errUnauthorized:    "Unauthorized",
+	errInvalid:         "Invalid",
+	errConfig:          "Config_error",
+	errAlreadyExists:   "Already_exists",
+	errUnprepared:      "Unprepared",
+}
+
+func parseRequest(f *frame) (*CqlRequest, error) {
+	request := &CqlRequest{
+		Version:     f.Header.Version,
+		Flags:       f.Header.Flags,
+		Stream:      f.Header.Stream,
+		Opcode:      requestOpcodes[f.Header.Opcode],
+		Compression: f.Compression,
+	}
+
+	b := &buffer{data: f.Body}
+	if f.Header.Flags&flagCustomPayload != 0 {
+		request.CustomPayload = hexMap(b.readBytesMap())
+	}
+
+	switch f.Header.Opcode {
+	case opStartup:
+		request.Options = b.readStringMap()
+	case opQuery:
+		request.Statement = b.readLongString()
+		readQueryParameters(b, request)
+	case opPrepare:
+		request.Statement = b.readLongString()
+		if request.Version >= 5 && b.readInt()&prepareFlagKeyspace != 0 {
+			request.Keyspace = b.readString()
+		}
+	case opExecute:
+		request.PreparedID = hex.EncodeToString(b.readShortBytes())
+		if request.Version >= 5 {
+			b.readShortBytes() // result metadata id
+		}
+		readQueryParameters(b, request)
+	case opBatch:
+		readBatch(b, request)
+	case opRegister:
+		request.Events = b.readStringList()
+	}
+	// The token of AUTH_RESPONSE is left out since it holds the credentials
+
+	if b.err != nil {
+		return nil, b.err
+	}
+	if !utf8.ValidString(request.Statement) {
+		return nil, errors.New("statement is not valid UTF-8")
+	}
+
+	request.Command = queryCommand(request.Statement)
+	if request.Opcode == "BATCH" || request.Command == "" {
+		request.Command = request.Opcode
+	}
+
+	return request, nil
+}
+
+func readQueryParameters(b *buffer, request *CqlRequest) {
+	request.Consistency = b.readConsistency()
+	flags := b.readQueryFlags(request.Version)
+	if flags&queryFlagValues != 0 {
+		n := int(b.readShort())
+		for i := 0; i < n && b.err == nil; i++ {
+			if flags&queryFlagNamesForValues != 0 {
+				request.Names = append(request.Names, b.readString())
+			}
+			request.rawValues = append(request.rawValues, b.readValue())
+		}
+		request.Values = hexValues(request.rawValues)
+	}
+	if flags&queryFlagPageSize != 0 {
+		request.PageSize = b.readInt()
+	}
+	if flags&queryFlagPagingState != 0 {
+		request.PagingState = hex.EncodeToString(b.readBytes())
+	}
+	if flags&queryFlagSerialConsistency != 0 {
+		request.SerialConsistency = b.readConsistency()
+	}
+	if flags&queryFlagTimestamp != 0 {
+		request.Timestamp = b.readLong()
+	}
+	if request.Version >= 5 && flags&queryFlagKeyspace != 0 {
+		request.Keyspace = b.readString()
+	}
+	if request.Version >= 5 && flags&queryFlagNowInSeconds != 0 {
+		b.readInt()
+	}
+}
+
+func readBatch(b *buffer, request *CqlRequest) {
+	batchType := b.readByte()
+	request.BatchType = batchTypes[batchType]
+	if b.err == nil && request.BatchType == "" {
+		b.err = fmt.Errorf("unknown batch type: %d", batchType)
+		return
+	}
+
+	n := int(b.readShort())
+	for i := 0; i < n && b.err == nil; i++ {
+		query := CqlBatchQuery{}
+		switch kind := b.readByte(); kind {
+		case 0:
+			query.Statement = b.readLongString()
+		case 1:
+			query.PreparedID = hex.EncodeToString(b.readShortBytes())
+		default:
+			if b.err == nil {
+				b.err = fmt.Errorf("unknown batch query kind: %d", kind)
+			}
+		}
+		values := int(b.readShort())
+		for j := 0; j < values && b.err == nil; j++ {
+			query.rawValues = append(query.rawValues, b.readValue())
+		}
+		query.Values = hexValues(query.rawValues)
+		request.Queries = append(request.Queries, query)
+	}
+
+	request.Consistency = b.readConsistency()
+	flags := b.readQueryFlags(request.Version)
+	if flags&queryFlagSerialConsistency != 0 {
+		request.SerialConsistency = b.readConsistency()
+	}
+	if flags&queryFlagTimestamp != 0 {
+		request.Timestamp = b.readLong()
+	}
+	if request.Version >= 5 && flags&queryFlagKeyspace != 0 {
+		request.Keyspace = b.readString()
+	}
+	if request.Version >= 5 && flags&queryFlagNowInSeconds != 0 {
+		b.readInt()
+	}
+
+	request.Statement = batchStatement(request.Queries)
+}
+
+// batchStatement joins the statements of a batch that are known.
+func batchStatement(queries []CqlBatchQuery) string {
+	var statements []string
+	for _, query := range queries {
+		if query.Statement != "" {
+			statements = append(statements, query.Statement)
+		}
+	}
+	return strings.Join(statements, "; ")
+}
+
+func parseResponse(f *frame) (*CqlResponse, error) {
+	response := &CqlResponse{
+		Version:     f.Header.Version,
+		Flags:       f.Header.Flags,
+		Stream:      f.Header.Stream,
+		Opcode:      responseOpcodes[f.Header.Opcode],
+		Compression: f.Compression,
+	}
+
+	b := &buffer{data: f.Body}
+	if f.Header.Flags&flagTracing != 0 {
+		if id, err := uuid.FromBytes(b.readUUID()); err == nil {
+			response.TracingID = id.String()
+		}
+	}
+	if f.Header.Flags&flagWarning != 0 {
+		response.Warnings = b.readStringList()
+	}
+	if f.Header.Flags&flagCustomPayload != 0 {
+		response.CustomPayload = hexMap(b.readBytesMap())
+	}
+
+	switch f.Header.Opcode {
+	case opError:
+		response.Error = readError(b, response.Version)
+	case opAuthenticate:
+		response.Authenticator = b.readString()
+	case opSupported:
+		response.Supported = b.readStringMultimap()
+	case opResult:
+		readResult(b, response)
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return response, nil
+}
+
+func readResult(b *buffer, response *CqlResponse) {
+	kind := b.readInt()
+	response.Kind = resultKinds[kind]
+	if b.err == nil && response.Kind == "" {
+		b.err = fmt.Errorf("unknown result kind: %d", kind)
+		return
+	}
+
+	switch kind {
+	case resultRows:
+		metadata := readRowsMetadata(b, response.Version)
+		response.Columns = metadata.columns
+		response.PagingState = hex.EncodeToString(metadata.pagingState)
+		response.noMetadata = metadata.noMetadata
+		readRows(b, response, metadata.count)
+	case resultSetKeyspace:
+		response.Keyspace = b.readString()
+	case resultPrepared:
+		response.PreparedID = hex.EncodeToString(b.readShortBytes())
+		if response.Version >= 5 {
+			b.readShortBytes() // result metadata id
+		}
+		response.Variables = readPreparedMetadata(b, response.Version)
+		response.Columns = readRowsMetadata(b, response.Version).columns
+	case resultSchemaChange:
+		response.SchemaChange = readSchemaChange(b)
+	}
+}
+
+type rowsMetadata struct {
+	count       int
+	columns     []CqlColumn
+	pagingState []byte
+	noMetadata  bool
+}
+
+// readRowsMetadata reads the <metadata> of a Rows result. The column specs
+// are omitted when the client already knows them from the prepared
+// statement.
+func readRowsMetadata(b *buffer, version byte) *rowsMetadata {
+	metadata := &rowsMetadata{}
+	flags := b.readInt()
+	metadata.count = int(b.readInt())
+	if flags&metadataFlagHasMorePages != 0 {
+		metadata.pagingState = b.readBytes()
+	}
+	if version >= 5 && flags&metadataFlagMetadataChanged != 0 {
+		b.readShortBytes() // new metadata id
+	}
+	if flags&metadataFlagNoMetadata != 0 {
+		metadata.noMetadata = true
+		return metadata
+	}
+
+	metadata.columns = readColumnSpecs(b, metadata.count, flags&metadataFlagGlobalTablesSpec != 0)
+	return metadata
+}
+
+// readPreparedMetadata reads the bind markers of a prepared statement.
+func readPreparedMetadata(b *buffer, version byte) []CqlColumn {
+	flags := b.readInt()
+	count := int(b.readInt())
+	if version >= 4 {
+		pkCount := int(b.readInt())
+		if !b.checkCount(int64(pkCount), 2) {
+			return nil
+		}
+		for i := 0; i < pkCount && b.err == nil; i++ {
+			b.readShort() // pk index
+		}
+	}
+	return readColumnSpecs(b, count, flags&metadataFlagGlobalTablesSpec != 0)
+}
+
+func readColumnSpecs(b *buffer, count int, globalTablesSpec bool) []CqlColumn {
+	var keyspace, table string
+	if globalTablesSpec {
+		keyspace = b.readString()
+		table = b.readString()
+	}
+
+	columns := make([]CqlColumn, 0)
+	if !b.checkCount(int64(count), minColumnSpecSize) {
+		return columns
+	}
+	for i := 0; i < count && b.err == nil; i++ {
+		column := CqlColumn{Keyspace: keyspace, Table: table}
+		if !globalTablesSpec {
+			column.Keyspace = b.readString()
+			column.Table = b.readString()
+		}
+		column.Name = b.readString()
+		column.dataType = b.readType()
+		if b.err == nil {
+			column.Type = column.dataType.String()
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func readRows(b *buffer, response *CqlResponse, columnCount int) {
+	response.RowCount = b.readInt()
+	if columnCount <= 0 {
+		return
+	}
+	if !b.checkCount(int64(response.RowCount)*int64(columnCount), minCellSize) {
+		return
+	}
+
+	for i := int32(0); i < response.RowCount && b.err == nil; i++ {
+		row := make([][]byte, 0, columnCount)
+		for j := 0; j < columnCount && b.err == nil; j++ {
+			row = append(row, b.readBytes())
+		}
+		response.rawRows = append(response.rawRows, row)
+	}
+	if b.err != nil {
+		return
+	}
+
+	// The types of the columns of a prepared statement may be known only
+	// once the request is matched
+	response.Rows = decodeRows(response.Columns, response.rawRows)
+}
+
+func decodeRows(columns []CqlColumn, rawRows [][][]byte) [][]interface{} {
+	rows := make([][]interface{}, 0, len(rawRows))
+	for _, rawRow := range rawRows {
+		row := make([]interface{}, len(rawRow))
+		for i, cell := range rawRow {
+			var t *dataType
+			if i < len(columns) {
+				t = columns[i].dataType
+			}
+			row[i] = decodeValue(t, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func readSchemaChange(b *buffer) *CqlSchemaChange {
+	schemaChange := &CqlSchemaChange{
+		Change: b.readString(),
+		Target: b.readString(),
+	}
+	schemaChange.Keyspace = b.readString()
+	switch schemaChange.Target {
+	case "TABLE", "TYPE":
+		schemaChange.Name = b.readString()
+	case "FUNCTION", "AGGREGATE":
+		schemaChange.Name = b.readString()
+		schemaChange.Arguments = b.readStringList()
+	}
+	return schemaChange
+}
+
+func readError(b *buffer, version byte) *CqlError {
+	cqlError := &CqlError{
+		Code:    b.readInt(),
+		Message: b.readString(),
+	}
+	cqlError.Name = errorNames[cqlError.Code]
+
+	switch cqlError.Code {
+	case errUnavailable:
+		cqlError.Consistency = b.readConsistency()
+		cqlError.Required = b.readInt()
+		cqlError.Alive = b.readInt()
+	case errWriteTimeout:
+		cqlError.Consistency = b.readConsistency()
+		cqlError.Received = b.readInt()
+		cqlError.BlockFor = b.readInt()
+		cqlError.WriteType = b.readString()
+	case errReadTimeout:
+		cqlError.Consistency = b.readConsistency()
+		cqlError.Received = b.readInt()
+		cqlError.BlockFor = b.readInt()
+		cqlError.DataPresent = b.readByte() != 0
+	case errReadFailure:
+		cqlError.Consistency = b.readConsistency()
+		cqlError.Received = b.readInt()
+		cqlError.BlockFor = b.readInt()
+		cqlError.Failures = readFailures(b, version)
+		cqlError.DataPresent = b.readByte() != 0
+	case errWriteFailure:
+		cqlError.Consistency = b.readConsistency()
+		cqlError.Received = b.readInt()
+		cqlError.BlockFor = b.readInt()
+		cqlError.Failures = readFailures(b, version)
+		cqlError.WriteType = b.readString()
+	case errCASWriteUnknown:
+		cqlError.Consistency = b.readConsistency()
+		cqlError.Received = b.readInt()
+		cqlError.BlockFor = b.readInt()
+	case errFunctionFailure:
+		cqlError.Keyspace = b.readString()
+		cqlError.Function = b.readString()
+		b.readStringList() // argument types
+	case errAlreadyExists:
+		cqlError.Keyspace = b.readString()
+		cqlError.Table = b.readString()
+	case errUnprepared:
+		cqlError.PreparedID = hex.EncodeToString(b.readShortBytes())
+	}
+
+	return cqlError
+}
+
+// readFailures reads the number of failed replicas, which is followed by
+// the reason of each of them starting with v5.
+func readFailures(b *buffer, version byte) int32 {
+	n := b.readInt()
+	if version >= 5 {
+		for i := int32(0); i < n && b.err == nil; i++ {
+			b.readInetAddr()
+			b.readShort() // failure code
+		}
+	}
+	return n
+}
+
+func hexValues(rawValues [][]byte) []interface{} {
+	values := make([]interface{}, len(rawValues))
+	for i, value := range rawValues {
+		values[i] = hexValue(value)
+	}
+	return values
+}
+
+func hexMap(m map[string][]byte) map[string]string {
+	hexes := make(map[string]string)
+	for k, v := range m {
+		hexes[k] = hex.EncodeToString(v)
+	}
+	return hexes
+}
+
+// resolvePrepared completes the requests that execute a prepared statement
+// with what was learned when the statement was prepared.
+func resolvePrepared(request *CqlRequest, response *CqlResponse) {
+	switch request.Opcode {
+	case "PREPARE":
+		if response.Kind == resultKinds[resultPrepared] {
+			registerStatement(response.PreparedID, &preparedStatement{
+				Query:     request.Statement,
+				Variables: response.Variables,
+				Columns:   response.Columns,
+			})
+		}
+	case "EXECUTE":
+		statement := lookupStatement(request.PreparedID)
+		if statement == nil {
+			return
+		}
+		request.Statement = statement.Query
+		request.Command = queryCommand(statement.Query)
+		request.Values = decodeValues(statement.Variables, request.Names, request.rawValues)
+		if response.noMetadata {
+			response.Columns = statement.Columns
+			response.Rows = decodeRows(statement.Columns, response.rawRows)
+		}
+	case "BATCH":
+		for i := range request.Queries {
+			query := &request.Queries[i]
+			if query.PreparedID == "" {
+				continue
+			}
+			if statement := lookupStatement(query.PreparedID); statement != nil {
+				query.Statement = statement.Query
+				query.Values = decodeValues(statement.Variables, nil, query.rawValues)
+			}
+		}
+		request.Statement = batchStatement(request.Queries)
+	}
+}
+
+// decodeValues decodes the values bound to the markers of a statement,
+// which are matched by name if the values are named.
+func decodeValues(variables []CqlColumn, names []string, rawValues [][]byte) []interface{} {
+	values := make([]interface{}, len(rawValues))
+	for i, value := range rawValues {
+		var t *dataType
+		if len(names) == len(rawValues) {
+			for _, variable := range variables {
+				if variable.Name == names[i] {
+					t = variable.dataType
+					break
+				}
+			}
+		} else if i < len(variables) {
+			t = variables[i].dataType
+		}
+		values[i] = decodeValue(t, value)
+	}
+	return values
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, request *CqlRequest, occurrence int, reqResMatcher *requestResponseMatcher) {
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d_%d",
+		tcpID.SrcIP,
+		tcpID.DstIP,
+		tcpID.SrcPort,
+		tcpID.DstPort,
+		request.Stream,
+		occurrence,
+	)
+
+	item := reqResMatcher.registerRequest(ident, request, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, response *CqlResponse, occurrence int, reqResMatcher *requestResponseMatcher) {
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d_%d",
+		tcpID.DstIP,
+		tcpID.SrcIP,
+		tcpID.DstPort,
+		tcpID.SrcPort,
+		response.Stream,
+		occurrence,
+	)
+
+	item := reqResMatcher.registerResponse(ident, response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
@@ -0,0 +1,304 @@
+package cql
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Opcode",
+			Value:    request["opcode"].(string),
+			Selector: `request.opcode`,
+		},
+		{
+			Name:     "Command",
+			Value:    request["command"].(string),
+			Selector: `request.command`,
+		},
+		{
+			Name:     "Version",
+			Value:    request["version"].(float64),
+			Selector: `request.version`,
+		},
+		{
+			Name:     "Stream",
+			Value:    request["stream"].(float64),
+			Selector: `request.stream`,
+		},
+		{
+			Name:     "Keyspace",
+			Value:    request["keyspace"].(string),
+			Selector: `request.keyspace`,
+		},
+		{
+			Name:     "Consistency",
+			Value:    request["consistency"],
+			Selector: `request.consistency`,
+		},
+		{
+			Name:     "Serial Consistency",
+			Value:    request["serialConsistency"],
+			Selector: `request.serialConsistency`,
+		},
+		{
+			Name:     "Page Size",
+			Value:    request["pageSize"],
+			Selector: `request.pageSize`,
+		},
+		{
+			Name:     "Prepared ID",
+			Value:    request["preparedId"],
+			Selector: `request.preparedId`,
+		},
+		{
+			Name:     "Batch Type",
+			Value:    request["batchType"],
+			Selector: `request.batchType`,
+		},
+		{
+			Name:     "Compression",
+			Value:    request["compression"],
+			Selector: `request.compression`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if statement, ok := request["statement"].(string); ok && statement != "" {
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Statement",
+			MimeType: "application/sql",
+			Data:     statement,
+			Selector: `request.statement`,
+		})
+	}
+
+	if values, ok := request["values"].([]interface{}); ok && len(values) > 0 {
+		names, _ := request["names"].([]interface{})
+		var table []api.TableData
+		for i, value := range values {
+			name := fmt.Sprintf("?%d", i+1)
+			if i < len(names) {
+				name = fmt.Sprintf(":%s", names[i])
+			}
+			table = append(table, api.TableData{
+				Name:     name,
+				Value:    value,
+				Selector: fmt.Sprintf("request.values[%d]", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Values",
+			Data:  string(obj),
+		})
+	}
+
+	if queries, ok := request["queries"].([]interface{}); ok && len(queries) > 0 {
+		obj, _ := json.Marshal(queries)
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Batch",
+			MimeType: "application/json",
+			Data:     string(obj),
+			Selector: `request.queries`,
+		})
+	}
+
+	if options, ok := request["options"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Options",
+			Data:  representMapAsTable(options, `request.options`),
+		})
+	}
+
+	if events, ok := request["events"].([]interface{}); ok && len(events) > 0 {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Events",
+			Data:  representSliceAsTable(events, `request.events`),
+		})
+	}
+
+	if customPayload, ok := request["customPayload"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Custom Payload",
+			Data:  representMapAsTable(customPayload, `request.customPayload`),
+		})
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Opcode",
+			Value:    response["opcode"].(string),
+			Selector: `response.opcode`,
+		},
+		{
+			Name:     "Kind",
+			Value:    response["kind"],
+			Selector: `response.kind`,
+		},
+		{
+			Name:     "Row Count",
+			Value:    response["rowCount"].(float64),
+			Selector: `response.rowCount`,
+		},
+		{
+			Name:     "Keyspace",
+			Value:    response["keyspace"],
+			Selector: `response.keyspace`,
+		},
+		{
+			Name:     "Prepared ID",
+			Value:    response["preparedId"],
+			Selector: `response.preparedId`,
+		},
+		{
+			Name:     "Tracing ID",
+			Value:    response["tracingId"],
+			Selector: `response.tracingId`,
+		},
+		{
+			Name:     "Authenticator",
+			Value:    response["authenticator"],
+			Selector: `response.authenticator`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if cqlError, ok := response["error"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Error",
+			Data:  representMapAsTable(cqlError, `response.error`),
+		})
+	}
+
+	if warnings, ok := response["warnings"].([]interface{}); ok && len(warnings) > 0 {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Warnings",
+			Data:  representSliceAsTable(warnings, `response.warnings`),
+		})
+	}
+
+	if schemaChange, ok := response["schemaChange"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Schema Change",
+			Data:  representMapAsTable(schemaChange, `response.schemaChange`),
+		})
+	}
+
+	repResponse = append(repResponse, representColumns("Variables", response["variables"], `response.variables`)...)
+	repResponse = append(repResponse, representColumns("Columns", response["columns"], `response.columns`)...)
+
+	if rows, ok := response["rows"].([]interface{}); ok && len(rows) > 0 {
+		obj, _ := json.Marshal(rows)
+		repResponse = append(repResponse, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Rows",
+			MimeType: "application/json",
+			Data:     string(obj),
+			Selector: `response.rows`,
+		})
+	}
+
+	if supported, ok := response["supported"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Supported",
+			Data:  representMapAsTable(supported, `response.supported`),
+		})
+	}
+
+	if customPayload, ok := response["customPayload"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Custom Payload",
+			Data:  representMapAsTable(customPayload, `response.customPayload`),
+		})
+	}
+
+	return
+}
+
+func representColumns(title string, columns interface{}, selectorPrefix string) (sections []interface{}) {
+	list, ok := columns.([]interface{})
+	if !ok || len(list) == 0 {
+		return
+	}
+
+	var table []api.TableData
+	for i, column := range list {
+		c := column.(map[string]interface{})
+		table = append(table, api.TableData{
+			Name:     c["name"].(string),
+			Value:    c["type"].(string),
+			Selector: fmt.Sprintf("%s[%d].name", selectorPrefix, i),
+		})
+	}
+	obj, _ := json.Marshal(table)
+	sections = append(sections, api.SectionData{
+		Type:  api.TABLE,
+		Title: title,
+		Data:  string(obj),
+	})
+	return
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
+
+func representSliceAsTable(slice []interface{}, selectorPrefix string) string {
+	var table []api.TableData
+	for i, item := range slice {
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("%d", i),
+			Value:    item,
+			Selector: fmt.Sprintf("%s[%d]", selectorPrefix, i),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
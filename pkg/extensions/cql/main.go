@@ -0,0 +1,171 @@
+package cql
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "cql",
+	Version:         "4",
+	Abbreviation:    "CQL",
+	LongName:        "Cassandra Query Language Native Protocol",
+	Macro:           "cql",
+	BackgroundColor: "#1287b1",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://github.com/apache/cassandra/blob/trunk/doc/native_protocol_v5.spec",
+	Ports:           []string{"9042", "9142"},
+	Layer4:          "tcp",
+	Priority:        8,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	r := newFrameReader(b, reader.GetIsClient())
+
+	// How many frames of each stream ID were read so far
+	occurrences := make(map[int16]int)
+	for {
+		f, err := r.readFrame()
+		if err != nil {
+			return err
+		}
+
+		occurrence := occurrences[f.Header.Stream]
+		if reader.GetIsClient() {
+			request, err := parseRequest(f)
+			if err != nil {
+				return err
+			}
+			reader.GetParent().SetProtocol(&protocol)
+			occurrences[f.Header.Stream]++
+			handleClientStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), request, occurrence, reqResMatcher)
+		} else {
+			if f.Header.Opcode == opEvent {
+				// Events are pushed by the server on negative stream IDs
+				// without being requested
+				reader.GetParent().SetProtocol(&protocol)
+				continue
+			}
+			response, err := parseResponse(f)
+			if err != nil {
+				return err
+			}
+			reader.GetParent().SetProtocol(&protocol)
+			occurrences[f.Header.Stream]++
+			handleServerStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), response, occurrence, reqResMatcher)
+		}
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	status := 0
+	statusQuery := ""
+	if cqlError, ok := entry.Response["error"].(map[string]interface{}); ok {
+		status = int(cqlError["code"].(float64))
+		statusQuery = fmt.Sprintf(`response.error.code == %d`, status)
+	}
+
+	method := entry.Request["command"].(string)
+	methodQuery := fmt.Sprintf(`request.command == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	if statement, ok := entry.Request["statement"].(string); ok && statement != "" {
+		summary = statement
+		summaryQuery = fmt.Sprintf(`request.statement == %q`, summary)
+	} else if keyspace, ok := entry.Request["keyspace"].(string); ok && keyspace != "" {
+		summary = keyspace
+		summaryQuery = fmt.Sprintf(`request.keyspace == "%s"`, summary)
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       status,
+		StatusQuery:  statusQuery,
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representRequest(request)
+	repResponse := representResponse(response)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`cql`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
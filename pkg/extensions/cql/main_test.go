@@ -0,0 +1,243 @@
+package cql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/pierrec/lz4/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "cql", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"cql": `protocol.name == "cql"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func shortBytes(v uint16) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, v)
+	return buf
+}
+
+func intBytes(v int32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(v))
+	return buf
+}
+
+func str(s string) []byte {
+	return append(shortBytes(uint16(len(s))), s...)
+}
+
+func longStr(s string) []byte {
+	return append(intBytes(int32(len(s))), s...)
+}
+
+func value(v []byte) []byte {
+	return append(intBytes(int32(len(v))), v...)
+}
+
+func cqlFrame(version byte, flags byte, stream int16, opcode byte, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	header := []byte{version, flags, byte(uint16(stream) >> 8), byte(stream), opcode}
+	return append(append(header, intBytes(int32(len(body)))...), body...)
+}
+
+func segment(payload []byte) []byte {
+	header := uint32(len(payload)) | 1<<17
+	crc := crc24(uint64(header), 3)
+	buf := []byte{byte(header), byte(header >> 8), byte(header >> 16), byte(crc), byte(crc >> 8), byte(crc >> 16)}
+	buf = append(buf, payload...)
+	return append(buf, 0, 0, 0, 0)
+}
+
+func dissect(t *testing.T, client []byte, server []byte) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, client, server)
+}
+
+func TestDissect(t *testing.T) {
+	preparedID := []byte{0xca, 0xfe, 0xba, 0xbe}
+
+	client := bytes.Join([][]byte{
+		cqlFrame(0x04, 0, 0, opStartup, shortBytes(1), str("CQL_VERSION"), str("3.0.0")),
+		cqlFrame(0x04, 0, 1, opQuery, longStr("SELECT id, name FROM shop.products"), shortBytes(0x0001), []byte{queryFlagPageSize}, intBytes(5000)),
+		cqlFrame(0x04, 0, 1, opPrepare, longStr("SELECT name FROM shop.products WHERE id = ?")),
+		cqlFrame(0x04, 0, 1, opExecute, str(string(preparedID)), shortBytes(0x0006), []byte{queryFlagValues | queryFlagSkipMetadata}, shortBytes(1), value(intBytes(7))),
+		cqlFrame(0x04, 0, 2, opBatch, []byte{1}, shortBytes(2),
+			[]byte{0}, longStr("UPDATE shop.products SET name = 'pear' WHERE id = 2"), shortBytes(0),
+			[]byte{1}, str(string(preparedID)), shortBytes(1), value(intBytes(3)),
+			shortBytes(0x0004), []byte{0}),
+		cqlFrame(0x04, 0, 1, opQuery, longStr("SELEC oops"), shortBytes(0x0001), []byte{0}),
+	}, nil)
+
+	compressedRows := make([]byte, lz4.CompressBlockBound(64))
+	uncompressedRows := bytes.Join([][]byte{
+		intBytes(resultRows), intBytes(metadataFlagNoMetadata), intBytes(1),
+		intBytes(1), value([]byte("apple")),
+	}, nil)
+	n, err := lz4.CompressBlock(uncompressedRows, compressedRows, nil)
+	assert.Nil(t, err)
+
+	server := bytes.Join([][]byte{
+		cqlFrame(0x84, 0, 0, opReady),
+		cqlFrame(0x84, 0, 1, opResult, intBytes(resultRows),
+			intBytes(metadataFlagGlobalTablesSpec), intBytes(2), str("shop"), str("products"),
+			str("id"), shortBytes(typeInt), str("name"), shortBytes(typeVarchar),
+			intBytes(2), value(intBytes(1)), value([]byte("apple")), value(intBytes(2)), intBytes(-1)),
+		cqlFrame(0x84, 0, -1, opEvent, str("STATUS_CHANGE"), str("UP"), []byte{4, 10, 0, 0, 1}, intBytes(9042)),
+		cqlFrame(0x84, 0, 1, opResult, intBytes(resultPrepared), str(string(preparedID)),
+			intBytes(metadataFlagGlobalTablesSpec), intBytes(1), intBytes(1), shortBytes(0), str("shop"), str("products"),
+			str("id"), shortBytes(typeInt),
+			intBytes(metadataFlagGlobalTablesSpec), intBytes(1), str("shop"), str("products"),
+			str("name"), shortBytes(typeVarchar)),
+		cqlFrame(0x84, flagCompression, 1, opResult, intBytes(int32(len(uncompressedRows))), compressedRows[:n]),
+		cqlFrame(0x84, flagWarning, 2, opResult, shortBytes(1), str("Batch is unlogged"), intBytes(resultVoid)),
+		cqlFrame(0x84, 0, 1, opError, intBytes(errSyntax), str("line 1:0 no viable alternative at input 'SELEC'")),
+	}, nil)
+
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissect(t, client, server) {
+		entries[entry.Request["opcode"].(string)+"/"+entry.Request["command"].(string)] = entry
+	}
+	assert.Len(t, entries, 6)
+
+	startup := entries["STARTUP/STARTUP"]
+	assert.Equal(t, "3.0.0", startup.Request["options"].(map[string]interface{})["CQL_VERSION"])
+	assert.Equal(t, "READY", startup.Response["opcode"])
+
+	query := entries["QUERY/SELECT"]
+	assert.Equal(t, "ONE", query.Request["consistency"])
+	assert.Equal(t, float64(5000), query.Request["pageSize"])
+	assert.Equal(t, "Rows", query.Response["kind"])
+	assert.Equal(t, float64(2), query.Response["rowCount"])
+	assert.Equal(t, []interface{}{[]interface{}{float64(1), "apple"}, []interface{}{float64(2), nil}}, query.Response["rows"])
+	dissector := NewDissector()
+	summary := dissector.Summarize(query)
+	assert.Equal(t, "SELECT", summary.Method)
+	assert.Equal(t, "SELECT id, name FROM shop.products", summary.Summary)
+	assert.Equal(t, `request.statement == "SELECT id, name FROM shop.products"`, summary.SummaryQuery)
+
+	prepare := entries["PREPARE/SELECT"]
+	assert.Equal(t, "cafebabe", prepare.Response["preparedId"])
+	assert.Equal(t, "int", prepare.Response["variables"].([]interface{})[0].(map[string]interface{})["type"])
+
+	execute := entries["EXECUTE/SELECT"]
+	assert.Equal(t, "SELECT name FROM shop.products WHERE id = ?", execute.Request["statement"])
+	assert.Equal(t, "LOCAL_QUORUM", execute.Request["consistency"])
+	assert.Equal(t, []interface{}{float64(7)}, execute.Request["values"])
+	assert.Equal(t, "lz4", execute.Response["compression"])
+	assert.Equal(t, []interface{}{[]interface{}{"apple"}}, execute.Response["rows"])
+
+	batch := entries["BATCH/BATCH"]
+	assert.Equal(t, "UNLOGGED", batch.Request["batchType"])
+	queries := batch.Request["queries"].([]interface{})
+	assert.Equal(t, "SELECT name FROM shop.products WHERE id = ?", queries[1].(map[string]interface{})["statement"])
+	assert.Equal(t, []interface{}{float64(3)}, queries[1].(map[string]interface{})["values"])
+	assert.Equal(t, "Void", batch.Response["kind"])
+	assert.Equal(t, []interface{}{"Batch is unlogged"}, batch.Response["warnings"])
+
+	failed := entries["QUERY/SELEC"]
+	assert.Equal(t, "Syntax_error", failed.Response["error"].(map[string]interface{})["name"])
+	summary = dissector.Summarize(failed)
+	assert.Equal(t, errSyntax, summary.Status)
+	assert.Equal(t, `response.error.code == 8192`, summary.StatusQuery)
+}
+
+func TestDissectSegments(t *testing.T) {
+	client := bytes.Join([][]byte{
+		cqlFrame(0x05, 0, 0, opStartup, shortBytes(1), str("CQL_VERSION"), str("3.0.0")),
+		segment(bytes.Join([][]byte{
+			cqlFrame(0x05, 0, 3, opQuery, longStr("USE shop"), shortBytes(0x0001), intBytes(0)),
+			cqlFrame(0x05, 0, 4, opQuery, longStr("SELECT now() FROM system.local"), shortBytes(0x0001), intBytes(0)),
+		}, nil)),
+	}, nil)
+
+	rows := cqlFrame(0x85, 0, 4, opResult, intBytes(resultRows),
+		intBytes(metadataFlagGlobalTablesSpec), intBytes(1), str("system"), str("local"),
+		str("now()"), shortBytes(typeTimeUUID),
+		intBytes(1), value([]byte{0x8e, 0x1f, 0x7a, 0x10, 0x6a, 0x1b, 0x11, 0xee, 0x9d, 0x4a, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}))
+	server := bytes.Join([][]byte{
+		cqlFrame(0x85, 0, 0, opReady),
+		segment(cqlFrame(0x85, 0, 3, opResult, intBytes(resultSetKeyspace), str("shop"))),
+		// A frame may be split across segments
+		segment(rows[:20]),
+		segment(rows[20:]),
+	}, nil)
+
+	entries := make(map[float64]*api.Entry)
+	for _, entry := range dissect(t, client, server) {
+		entries[entry.Request["stream"].(float64)] = entry
+	}
+	assert.Len(t, entries, 3)
+
+	assert.Equal(t, float64(5), entries[0].Request["version"])
+	assert.Equal(t, "USE", entries[3].Request["command"])
+	assert.Equal(t, "shop", entries[3].Response["keyspace"])
+	assert.Equal(t, []interface{}{[]interface{}{"8e1f7a10-6a1b-11ee-9d4a-010203040506"}}, entries[4].Response["rows"])
+}
+
+func TestDecodeValue(t *testing.T) {
+	assert.Equal(t, "2023-10-01T00:00:00Z", decodeValue(&dataType{ID: typeTimestamp}, []byte{0, 0, 0x01, 0x8a, 0xe8, 0x88, 0xe4, 0x00}))
+	assert.Equal(t, "1970-01-02", decodeValue(&dataType{ID: typeDate}, []byte{0x80, 0, 0, 1}))
+	assert.Equal(t, "-12.345", decodeValue(&dataType{ID: typeDecimal}, []byte{0, 0, 0, 3, 0xcf, 0xc7}))
+	assert.Equal(t, int64(-1), decodeValue(&dataType{ID: typeVarint}, []byte{0xff}))
+	assert.Equal(t, "NaN", decodeValue(&dataType{ID: typeDouble}, []byte{0x7f, 0xf8, 0, 0, 0, 0, 0, 1}))
+	assert.Equal(t, "1mo-1d1000ns", decodeValue(&dataType{ID: typeDuration}, []byte{2, 1, 0x87, 0xd0}))
+	assert.Equal(t, "0x0102", decodeValue(&dataType{ID: typeInt}, []byte{1, 2}))
+
+	list := &dataType{ID: typeList, Elements: []*dataType{{ID: typeVarchar}}}
+	assert.Equal(t, []interface{}{"a", nil}, decodeValue(list, bytes.Join([][]byte{intBytes(2), value([]byte("a")), intBytes(-1)}, nil)))
+	m := &dataType{ID: typeMap, Elements: []*dataType{{ID: typeVarchar}, {ID: typeBoolean}}}
+	assert.Equal(t, map[string]interface{}{"on": true}, decodeValue(m, bytes.Join([][]byte{intBytes(1), value([]byte("on")), value([]byte{1})}, nil)))
+	udt := &dataType{ID: typeUDT, Fields: []string{"street", "zip"}, Elements: []*dataType{{ID: typeVarchar}, {ID: typeInt}}}
+	assert.Equal(t, map[string]interface{}{"street": "Main"}, decodeValue(udt, value([]byte("Main"))))
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestParseResponseHugeCounts(t *testing.T) {
+	bodies := map[string][]byte{
+		"columns": bytes.Join([][]byte{intBytes(resultRows), intBytes(metadataFlagNoMetadata), intBytes(0x7fffffff), intBytes(1), value([]byte("a"))}, nil),
+		"rows":    bytes.Join([][]byte{intBytes(resultRows), intBytes(metadataFlagNoMetadata), intBytes(2), intBytes(0x7fffffff), value([]byte("a"))}, nil),
+		"specs":   bytes.Join([][]byte{intBytes(resultRows), intBytes(metadataFlagGlobalTablesSpec), intBytes(0x7fffffff), str("shop"), str("products")}, nil),
+		"pks":     bytes.Join([][]byte{intBytes(resultPrepared), str("id"), intBytes(0), intBytes(1), intBytes(0x7fffffff)}, nil),
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseResponse(&frame{Header: frameHeader{Version: 4, IsResponse: true, Opcode: opResult}, Body: body})
+			assert.Equal(t, errShortFrame, err)
+		})
+	}
+
+	// No rows need no cells, whatever the column count
+	body := bytes.Join([][]byte{intBytes(resultRows), intBytes(metadataFlagNoMetadata), intBytes(0x7fffffff), intBytes(0)}, nil)
+	response, err := parseResponse(&frame{Header: frameHeader{Version: 4, IsResponse: true, Opcode: opResult}, Body: body})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(0), response.RowCount)
+}
@@ -0,0 +1,124 @@
+package cql
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const maxPreparedStatements = 10000
+
+type preparedStatement struct {
+	Query     string
+	Variables []CqlColumn
+	Columns   []CqlColumn
+}
+
+// preparedStatements is shared by all of the connections since the drivers
+// prepare a statement on one connection and execute it on any other. The
+// statement IDs are hashes of the queries, so they are the same on every
+// node.
+var preparedStatements, _ = lru.New(maxPreparedStatements)
+
+func registerStatement(id string, statement *preparedStatement) {
+	preparedStatements.Add(id, statement)
+}
+
+func lookupStatement(id string) *preparedStatement {
+	if statement, found := preparedStatements.Get(id); found {
+		return statement.(*preparedStatement)
+	}
+	return nil
+}
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{stream_id}_{occurrence}`
+// A stream ID is reused once the response of its request is received, so
+// the requests and the responses of a stream ID are matched in order.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *CqlRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestCqlMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: CqlPayload{
+			Data: &CqlWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseCqlMessage := response.(*api.GenericMessage)
+		if responseCqlMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestCqlMessage, responseCqlMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestCqlMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *CqlResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseCqlMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: CqlPayload{
+			Data: &CqlWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestCqlMessage := request.(*api.GenericMessage)
+		if !requestCqlMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestCqlMessage, &responseCqlMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseCqlMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestCqlMessage *api.GenericMessage, responseCqlMessage *api.GenericMessage) *api.OutputChannelItem {
+	requestWrapper := requestCqlMessage.Payload.(CqlPayload).Data.(*CqlWrapper)
+	request := requestWrapper.Details.(*CqlRequest)
+	response := responseCqlMessage.Payload.(CqlPayload).Data.(*CqlWrapper).Details.(*CqlResponse)
+	resolvePrepared(request, response)
+	requestWrapper.Method = request.Command
+
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestCqlMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestCqlMessage,
+			Response: *responseCqlMessage,
+		},
+	}
+}
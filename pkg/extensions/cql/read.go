@@ -0,0 +1,317 @@
+package cql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	frameHeaderLength = 9
+	maxFrameLength    = 256 * 1024 * 1024
+
+	minVersion = 3
+	maxVersion = 5
+
+	responseBit = 0x80
+)
+
+const (
+	flagCompression   = 0x01
+	flagTracing       = 0x02
+	flagCustomPayload = 0x04
+	flagWarning       = 0x08
+	flagUseBeta       = 0x10
+)
+
+const (
+	opError         = 0x00
+	opStartup       = 0x01
+	opReady         = 0x02
+	opAuthenticate  = 0x03
+	opOptions       = 0x05
+	opSupported     = 0x06
+	opQuery         = 0x07
+	opResult        = 0x08
+	opPrepare       = 0x09
+	opExecute       = 0x0a
+	opRegister      = 0x0b
+	opEvent         = 0x0c
+	opBatch         = 0x0d
+	opAuthChallenge = 0x0e
+	opAuthResponse  = 0x0f
+	opAuthSuccess   = 0x10
+)
+
+var requestOpcodes = map[byte]string{
+	opStartup:      "STARTUP",
+	opOptions:      "OPTIONS",
+	opQuery:        "QUERY",
+	opPrepare:      "PREPARE",
+	opExecute:      "EXECUTE",
+	opRegister:     "REGISTER",
+	opBatch:        "BATCH",
+	opAuthResponse: "AUTH_RESPONSE",
+}
+
+var responseOpcodes = map[byte]string{
+	opError:         "ERROR",
+	opReady:         "READY",
+	opAuthenticate:  "AUTHENTICATE",
+	opSupported:     "SUPPORTED",
+	opResult:        "RESULT",
+	opEvent:         "EVENT",
+	opAuthChallenge: "AUTH_CHALLENGE",
+	opAuthSuccess:   "AUTH_SUCCESS",
+}
+
+type frameHeader struct {
+	Version    byte
+	IsResponse bool
+	Flags      byte
+	Stream     int16
+	Opcode     byte
+	Length     int32
+}
+
+type frame struct {
+	Header      frameHeader
+	Compression string
+	Body        []byte
+}
+
+// frameReader reads the frames of one direction of a connection. Up to v4
+// the frames are written to the connection as they are. Starting with v5,
+// once the connection is established, the frames are wrapped in segments
+// that are protected by checksums and may be compressed as a whole.
+type frameReader struct {
+	r        *bufio.Reader
+	isClient bool
+
+	// frames, or parts of a frame, of the segments read so far
+	pending []byte
+}
+
+func newFrameReader(r *bufio.Reader, isClient bool) *frameReader {
+	return &frameReader{r: r, isClient: isClient}
+}
+
+func (fr *frameReader) readFrame() (*frame, error) {
+	for {
+		if len(fr.pending) >= frameHeaderLength {
+			header, err := parseFrameHeader(fr.pending, fr.isClient)
+			if err != nil {
+				return nil, err
+			}
+			end := frameHeaderLength + int(header.Length)
+			if len(fr.pending) >= end {
+				f := &frame{
+					Header: header,
+					Body:   fr.pending[frameHeaderLength:end],
+				}
+				fr.pending = fr.pending[end:]
+				return f, nil
+			}
+		}
+
+		segmented, err := fr.peekSegment()
+		if err != nil {
+			return nil, err
+		}
+		if segmented == nil {
+			if len(fr.pending) > 0 {
+				return nil, errors.New("frame is truncated by the end of its segments")
+			}
+			return fr.readLegacyFrame()
+		}
+
+		payload, err := fr.readSegment(segmented)
+		if err != nil {
+			return nil, err
+		}
+		fr.pending = append(fr.pending, payload...)
+	}
+}
+
+func (fr *frameReader) readLegacyFrame() (*frame, error) {
+	buf := make([]byte, frameHeaderLength)
+	if _, err := io.ReadFull(fr.r, buf); err != nil {
+		return nil, err
+	}
+
+	header, err := parseFrameHeader(buf, fr.isClient)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &frame{
+		Header: header,
+		Body:   make([]byte, header.Length),
+	}
+	if _, err := io.ReadFull(fr.r, f.Body); err != nil {
+		return nil, err
+	}
+
+	if header.Flags&flagCompression != 0 {
+		if err := f.decompress(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func parseFrameHeader(buf []byte, isClient bool) (frameHeader, error) {
+	header := frameHeader{
+		Version:    buf[0] &^ responseBit,
+		IsResponse: buf[0]&responseBit != 0,
+		Flags:      buf[1],
+		Stream:     int16(binary.BigEndian.Uint16(buf[2:4])),
+		Opcode:     buf[4],
+		Length:     int32(binary.BigEndian.Uint32(buf[5:9])),
+	}
+
+	if header.Version < minVersion || header.Version > maxVersion {
+		return header, fmt.Errorf("unsupported protocol version: %d", header.Version)
+	}
+	if header.IsResponse == isClient {
+		return header, errors.New("frame is sent in the wrong direction")
+	}
+	if header.Flags&^(flagCompression|flagTracing|flagCustomPayload|flagWarning|flagUseBeta) != 0 {
+		return header, fmt.Errorf("unknown frame flags: %#x", header.Flags)
+	}
+	opcodes := requestOpcodes
+	if header.IsResponse {
+		opcodes = responseOpcodes
+	}
+	if _, ok := opcodes[header.Opcode]; !ok {
+		return header, fmt.Errorf("unknown opcode: %#x", header.Opcode)
+	}
+	if header.Length < 0 || header.Length > maxFrameLength {
+		return header, fmt.Errorf("invalid frame length: %d", header.Length)
+	}
+
+	return header, nil
+}
+
+// decompress undoes the compression of a legacy frame. The algorithm is
+// negotiated in the STARTUP message, so it is recognized by which one
+// succeeds instead: LZ4 bodies are prefixed by their uncompressed length.
+func (f *frame) decompress() error {
+	if len(f.Body) >= 4 {
+		size := binary.BigEndian.Uint32(f.Body)
+		if size <= maxFrameLength {
+			body := make([]byte, size)
+			if n, err := lz4.UncompressBlock(f.Body[4:], body); err == nil && n == int(size) {
+				f.Body = body
+				f.Compression = "lz4"
+				return nil
+			}
+		}
+	}
+
+	if size, err := snappy.DecodedLen(f.Body); err == nil && size <= maxFrameLength {
+		if body, err := snappy.Decode(nil, f.Body); err == nil {
+			f.Body = body
+			f.Compression = "snappy"
+			return nil
+		}
+	}
+
+	return errors.New("unknown frame compression")
+}
+
+const (
+	uncompressedSegmentHeaderLength = 6
+	compressedSegmentHeaderLength   = 8
+	segmentTrailerLength            = 4
+	maxSegmentPayloadLength         = 128*1024 - 1
+)
+
+type segmentHeader struct {
+	headerLength       int
+	payloadLength      int
+	uncompressedLength int
+}
+
+// peekSegment recognizes a v5 segment by the CRC24 of its header. The
+// header is 6 bytes long without compression and 8 bytes with it.
+func (fr *frameReader) peekSegment() (*segmentHeader, error) {
+	buf, err := fr.r.Peek(compressedSegmentHeaderLength)
+	if len(buf) < uncompressedSegmentHeaderLength {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	if header := readUint24(buf); crc24(uint64(header), 3) == readUint24(buf[3:]) {
+		return &segmentHeader{
+			headerLength:  uncompressedSegmentHeaderLength,
+			payloadLength: int(header & maxSegmentPayloadLength),
+		}, nil
+	}
+
+	if len(buf) == compressedSegmentHeaderLength {
+		header := uint64(readUint24(buf)) | uint64(buf[3])<<24 | uint64(buf[4])<<32
+		if crc24(header, 5) == readUint24(buf[5:]) {
+			return &segmentHeader{
+				headerLength:       compressedSegmentHeaderLength,
+				payloadLength:      int(header & maxSegmentPayloadLength),
+				uncompressedLength: int((header >> 17) & maxSegmentPayloadLength),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (fr *frameReader) readSegment(header *segmentHeader) ([]byte, error) {
+	buf := make([]byte, header.headerLength+header.payloadLength+segmentTrailerLength)
+	if _, err := io.ReadFull(fr.r, buf); err != nil {
+		return nil, err
+	}
+	payload := buf[header.headerLength : header.headerLength+header.payloadLength]
+
+	// An uncompressed length of zero means that compressing the payload
+	// wasn't worth it.
+	if header.uncompressedLength == 0 {
+		return payload, nil
+	}
+
+	uncompressed := make([]byte, header.uncompressedLength)
+	n, err := lz4.UncompressBlock(payload, uncompressed)
+	if err != nil {
+		return nil, err
+	}
+	if n != header.uncompressedLength {
+		return nil, errors.New("uncompressed segment length mismatch")
+	}
+	return uncompressed, nil
+}
+
+func readUint24(buf []byte) uint32 {
+	return uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16
+}
+
+// crc24 computes the checksum of a segment header, whose bytes are given
+// in little-endian order.
+func crc24(bytes uint64, length int) uint32 {
+	crc := uint32(0x875060)
+	for ; length > 0; length-- {
+		crc ^= uint32(bytes&0xff) << 16
+		bytes >>= 8
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1974f0b
+			}
+		}
+	}
+	return crc & 0xffffff
+}
@@ -0,0 +1,128 @@
+package cql
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type CqlPayload struct {
+	Data interface{}
+}
+
+type CqlPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h CqlPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type CqlWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+type CqlColumn struct {
+	Keyspace string `json:"keyspace"`
+	Table    string `json:"table"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+
+	dataType *dataType
+}
+
+type CqlBatchQuery struct {
+	Statement  string        `json:"statement"`
+	PreparedID string        `json:"preparedId,omitempty"`
+	Values     []interface{} `json:"values,omitempty"`
+
+	rawValues [][]byte
+}
+
+type CqlRequest struct {
+	Version           byte              `json:"version"`
+	Flags             byte              `json:"flags"`
+	Stream            int16             `json:"stream"`
+	Opcode            string            `json:"opcode"`
+	Command           string            `json:"command"`
+	Statement         string            `json:"statement"`
+	Keyspace          string            `json:"keyspace"`
+	PreparedID        string            `json:"preparedId,omitempty"`
+	Consistency       string            `json:"consistency,omitempty"`
+	SerialConsistency string            `json:"serialConsistency,omitempty"`
+	PageSize          int32             `json:"pageSize,omitempty"`
+	PagingState       string            `json:"pagingState,omitempty"`
+	Timestamp         int64             `json:"timestamp,omitempty"`
+	Names             []string          `json:"names,omitempty"`
+	Values            []interface{}     `json:"values,omitempty"`
+	BatchType         string            `json:"batchType,omitempty"`
+	Queries           []CqlBatchQuery   `json:"queries,omitempty"`
+	Options           map[string]string `json:"options,omitempty"`
+	Events            []string          `json:"events,omitempty"`
+	CustomPayload     map[string]string `json:"customPayload,omitempty"`
+	Compression       string            `json:"compression,omitempty"`
+
+	rawValues [][]byte
+}
+
+type CqlSchemaChange struct {
+	Change    string   `json:"change"`
+	Target    string   `json:"target"`
+	Keyspace  string   `json:"keyspace"`
+	Name      string   `json:"name,omitempty"`
+	Arguments []string `json:"arguments,omitempty"`
+}
+
+type CqlError struct {
+	Code        int32  `json:"code"`
+	Name        string `json:"name"`
+	Message     string `json:"message"`
+	Consistency string `json:"consistency,omitempty"`
+	Required    int32  `json:"required,omitempty"`
+	Alive       int32  `json:"alive,omitempty"`
+	Received    int32  `json:"received,omitempty"`
+	BlockFor    int32  `json:"blockFor,omitempty"`
+	Failures    int32  `json:"failures,omitempty"`
+	DataPresent bool   `json:"dataPresent,omitempty"`
+	WriteType   string `json:"writeType,omitempty"`
+	Keyspace    string `json:"keyspace,omitempty"`
+	Table       string `json:"table,omitempty"`
+	Function    string `json:"function,omitempty"`
+	PreparedID  string `json:"preparedId,omitempty"`
+}
+
+type CqlResponse struct {
+	Version       byte                `json:"version"`
+	Flags         byte                `json:"flags"`
+	Stream        int16               `json:"stream"`
+	Opcode        string              `json:"opcode"`
+	Kind          string              `json:"kind,omitempty"`
+	Keyspace      string              `json:"keyspace,omitempty"`
+	Columns       []CqlColumn         `json:"columns,omitempty"`
+	Rows          [][]interface{}     `json:"rows,omitempty"`
+	RowCount      int32               `json:"rowCount"`
+	PagingState   string              `json:"pagingState,omitempty"`
+	PreparedID    string              `json:"preparedId,omitempty"`
+	Variables     []CqlColumn         `json:"variables,omitempty"`
+	SchemaChange  *CqlSchemaChange    `json:"schemaChange,omitempty"`
+	Error         *CqlError           `json:"error,omitempty"`
+	TracingID     string              `json:"tracingId,omitempty"`
+	Warnings      []string            `json:"warnings,omitempty"`
+	Authenticator string              `json:"authenticator,omitempty"`
+	Supported     map[string][]string `json:"supported,omitempty"`
+	CustomPayload map[string]string   `json:"customPayload,omitempty"`
+	Compression   string              `json:"compression,omitempty"`
+
+	rawRows    [][][]byte
+	noMetadata bool
+}
+
+// queryCommand returns the upper-cased leading keyword of a statement.
+func queryCommand(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimRight(fields[0], ";("))
+}
@@ -0,0 +1,432 @@
+package cql
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	typeCustom    = 0x0000
+	typeASCII     = 0x0001
+	typeBigint    = 0x0002
+	typeBlob      = 0x0003
+	typeBoolean   = 0x0004
+	typeCounter   = 0x0005
+	typeDecimal   = 0x0006
+	typeDouble    = 0x0007
+	typeFloat     = 0x0008
+	typeInt       = 0x0009
+	typeTimestamp = 0x000b
+	typeUUID      = 0x000c
+	typeVarchar   = 0x000d
+	typeVarint    = 0x000e
+	typeTimeUUID  = 0x000f
+	typeInet      = 0x0010
+	typeDate      = 0x0011
+	typeTime      = 0x0012
+	typeSmallint  = 0x0013
+	typeTinyint   = 0x0014
+	typeDuration  = 0x0015
+	typeList      = 0x0020
+	typeMap       = 0x0021
+	typeSet       = 0x0022
+	typeUDT       = 0x0030
+	typeTuple     = 0x0031
+)
+
+var typeNames = map[uint16]string{
+	typeASCII:     "ascii",
+	typeBigint:    "bigint",
+	typeBlob:      "blob",
+	typeBoolean:   "boolean",
+	typeCounter:   "counter",
+	typeDecimal:   "decimal",
+	typeDouble:    "double",
+	typeFloat:     "float",
+	typeInt:       "int",
+	typeTimestamp: "timestamp",
+	typeUUID:      "uuid",
+	typeVarchar:   "varchar",
+	typeVarint:    "varint",
+	typeTimeUUID:  "timeuuid",
+	typeInet:      "inet",
+	typeDate:      "date",
+	typeTime:      "time",
+	typeSmallint:  "smallint",
+	typeTinyint:   "tinyint",
+	typeDuration:  "duration",
+	typeList:      "list",
+	typeMap:       "map",
+	typeSet:       "set",
+	typeTuple:     "tuple",
+}
+
+var consistencies = map[uint16]string{
+	0x0000: "ANY",
+	0x0001: "ONE",
+	0x0002: "TWO",
+	0x0003: "THREE",
+	0x0004: "QUORUM",
+	0x0005: "ALL",
+	0x0006: "LOCAL_QUORUM",
+	0x0007: "EACH_QUORUM",
+	0x0008: "SERIAL",
+	0x0009: "LOCAL_SERIAL",
+	0x000a: "LOCAL_ONE",
+}
+
+func consistencyName(consistency uint16) string {
+	if name, ok := consistencies[consistency]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", consistency)
+}
+
+const maxTypeDepth = 32
+
+// dataType is an [option] describing the type of a column or of a bind
+// marker.
+type dataType struct {
+	ID       uint16
+	Custom   string
+	Keyspace string
+	Name     string
+	Fields   []string
+	Elements []*dataType
+}
+
+func (b *buffer) readType() *dataType {
+	return b.readTypeAt(0)
+}
+
+func (b *buffer) readTypeAt(depth int) *dataType {
+	if depth > maxTypeDepth {
+		if b.err == nil {
+			b.err = errors.New("type is nested too deeply")
+		}
+		return nil
+	}
+
+	t := &dataType{ID: b.readShort()}
+	switch t.ID {
+	case typeCustom:
+		t.Custom = b.readString()
+	case typeList, typeSet:
+		t.Elements = []*dataType{b.readTypeAt(depth + 1)}
+	case typeMap:
+		t.Elements = []*dataType{b.readTypeAt(depth + 1), b.readTypeAt(depth + 1)}
+	case typeUDT:
+		t.Keyspace = b.readString()
+		t.Name = b.readString()
+		n := int(b.readShort())
+		for i := 0; i < n && b.err == nil; i++ {
+			t.Fields = append(t.Fields, b.readString())
+			t.Elements = append(t.Elements, b.readTypeAt(depth+1))
+		}
+	case typeTuple:
+		n := int(b.readShort())
+		for i := 0; i < n && b.err == nil; i++ {
+			t.Elements = append(t.Elements, b.readTypeAt(depth+1))
+		}
+	default:
+		if _, ok := typeNames[t.ID]; !ok && b.err == nil {
+			b.err = fmt.Errorf("unknown data type: %#x", t.ID)
+		}
+	}
+	return t
+}
+
+// String returns the CQL name of the type.
+func (t *dataType) String() string {
+	switch t.ID {
+	case typeCustom:
+		return fmt.Sprintf("'%s'", t.Custom)
+	case typeUDT:
+		return fmt.Sprintf("%s.%s", t.Keyspace, t.Name)
+	case typeList, typeSet, typeMap, typeTuple:
+		elements := make([]string, len(t.Elements))
+		for i, element := range t.Elements {
+			elements[i] = element.String()
+		}
+		return fmt.Sprintf("%s<%s>", typeNames[t.ID], strings.Join(elements, ", "))
+	default:
+		return typeNames[t.ID]
+	}
+}
+
+// hexValue is how the values are shown when their type is unknown.
+func hexValue(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return "0x" + hex.EncodeToString(data)
+}
+
+// decodeValue decodes a serialized value into a JSON-compatible value. The
+// value is shown as hex if it doesn't match its type.
+func decodeValue(t *dataType, data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	if t == nil {
+		return hexValue(data)
+	}
+	v, err := decodeTypedValue(t, data)
+	if err != nil {
+		return hexValue(data)
+	}
+	return v
+}
+
+func decodeTypedValue(t *dataType, data []byte) (interface{}, error) {
+	fixed := func(n int) error {
+		if len(data) != n {
+			return fmt.Errorf("%s value must be %d bytes long", t, n)
+		}
+		return nil
+	}
+
+	switch t.ID {
+	case typeASCII, typeVarchar:
+		return string(data), nil
+	case typeBlob, typeCustom:
+		return hexValue(data), nil
+	case typeBoolean:
+		if err := fixed(1); err != nil {
+			return nil, err
+		}
+		return data[0] != 0, nil
+	case typeTinyint:
+		if err := fixed(1); err != nil {
+			return nil, err
+		}
+		return int8(data[0]), nil
+	case typeSmallint:
+		if err := fixed(2); err != nil {
+			return nil, err
+		}
+		return int16(binary.BigEndian.Uint16(data)), nil
+	case typeInt:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		return int32(binary.BigEndian.Uint32(data)), nil
+	case typeBigint, typeCounter:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(data)), nil
+	case typeFloat:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		return floatValue(float64(math.Float32frombits(binary.BigEndian.Uint32(data)))), nil
+	case typeDouble:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		return floatValue(math.Float64frombits(binary.BigEndian.Uint64(data))), nil
+	case typeTimestamp:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(int64(binary.BigEndian.Uint64(data))).UTC().Format(time.RFC3339Nano), nil
+	case typeDate:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		// Days since the epoch, centered at 2^31
+		days := int64(binary.BigEndian.Uint32(data)) - (1 << 31)
+		return time.Unix(days*24*60*60, 0).UTC().Format("2006-01-02"), nil
+	case typeTime:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		// Nanoseconds since midnight
+		return time.Unix(0, int64(binary.BigEndian.Uint64(data))).UTC().Format("15:04:05.999999999"), nil
+	case typeUUID, typeTimeUUID:
+		id, err := uuid.FromBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		return id.String(), nil
+	case typeInet:
+		if len(data) != net.IPv4len && len(data) != net.IPv6len {
+			return nil, errors.New("invalid inet value length")
+		}
+		return net.IP(data).String(), nil
+	case typeVarint:
+		n := varint(data)
+		if n.IsInt64() {
+			return n.Int64(), nil
+		}
+		return n.String(), nil
+	case typeDecimal:
+		if len(data) < 4 {
+			return nil, errors.New("decimal value is too short")
+		}
+		scale := int32(binary.BigEndian.Uint32(data))
+		return decimalString(varint(data[4:]), scale), nil
+	case typeDuration:
+		return durationString(data)
+	case typeList, typeSet:
+		return decodeCollection(t, data)
+	case typeMap:
+		return decodeMap(t, data)
+	case typeTuple:
+		return decodeTuple(t, data)
+	case typeUDT:
+		return decodeUDT(t, data)
+	default:
+		return nil, fmt.Errorf("unknown data type: %#x", t.ID)
+	}
+}
+
+// floatValue keeps the values that JSON can't represent as strings.
+func floatValue(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return f
+	}
+}
+
+// varint decodes a big-endian two's complement integer.
+func varint(data []byte) *big.Int {
+	n := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	return n
+}
+
+func decimalString(unscaled *big.Int, scale int32) string {
+	if scale <= 0 {
+		return new(big.Int).Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil)).String()
+	}
+
+	digits := new(big.Int).Abs(unscaled).String()
+	if len(digits) <= int(scale) {
+		digits = strings.Repeat("0", int(scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(scale)
+	s := digits[:point] + "." + digits[point:]
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// durationString decodes the months, days and nanoseconds of a duration,
+// each encoded as a zigzag variable-length integer.
+func durationString(data []byte) (string, error) {
+	var parts [3]int64
+	for i := range parts {
+		v, n, err := readVInt(data)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = v
+		data = data[n:]
+	}
+	if len(data) != 0 {
+		return "", errors.New("duration value is too long")
+	}
+	return fmt.Sprintf("%dmo%dd%dns", parts[0], parts[1], parts[2]), nil
+}
+
+// readVInt reads a signed variable-length integer whose number of extra
+// bytes is the number of leading one bits of its first byte.
+func readVInt(data []byte) (int64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errShortFrame
+	}
+	first := data[0]
+	extra := 0
+	for mask := byte(0x80); mask != 0 && first&mask != 0; mask >>= 1 {
+		extra++
+	}
+	if len(data) < extra+1 {
+		return 0, 0, errShortFrame
+	}
+	v := uint64(first & (0xff >> uint(extra)))
+	for _, b := range data[1 : extra+1] {
+		v = v<<8 | uint64(b)
+	}
+	return int64(v>>1) ^ -int64(v&1), extra + 1, nil
+}
+
+func decodeCollection(t *dataType, data []byte) (interface{}, error) {
+	b := &buffer{data: data}
+	n := int(b.readInt())
+	values := make([]interface{}, 0)
+	for i := 0; i < n && b.err == nil; i++ {
+		element := b.readBytes()
+		if b.err == nil {
+			values = append(values, decodeValue(t.Elements[0], element))
+		}
+	}
+	return values, b.err
+}
+
+func decodeMap(t *dataType, data []byte) (interface{}, error) {
+	b := &buffer{data: data}
+	n := int(b.readInt())
+	values := make(map[string]interface{})
+	for i := 0; i < n && b.err == nil; i++ {
+		key := b.readBytes()
+		value := b.readBytes()
+		if b.err == nil {
+			values[fmt.Sprintf("%v", decodeValue(t.Elements[0], key))] = decodeValue(t.Elements[1], value)
+		}
+	}
+	return values, b.err
+}
+
+func decodeTuple(t *dataType, data []byte) (interface{}, error) {
+	b := &buffer{data: data}
+	values := make([]interface{}, 0)
+	for _, element := range t.Elements {
+		if len(b.data) == 0 {
+			break
+		}
+		value := b.readBytes()
+		if b.err != nil {
+			break
+		}
+		values = append(values, decodeValue(element, value))
+	}
+	return values, b.err
+}
+
+func decodeUDT(t *dataType, data []byte) (interface{}, error) {
+	b := &buffer{data: data}
+	values := make(map[string]interface{})
+	for i, element := range t.Elements {
+		// The fields that were added to the type after the value was
+		// written are missing
+		if len(b.data) == 0 {
+			break
+		}
+		value := b.readBytes()
+		if b.err != nil {
+			break
+		}
+		values[t.Fields[i]] = decodeValue(element, value)
+	}
+	return values, b.err
+}
@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kubeshark/gopacket"
+	"github.com/kubeshark/gopacket/layers"
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const tcpPort = "53"
+
+// readMessage reads a message that's prefixed by its 2-byte length, as it is
+// over TCP. It returns the message with its size, including the prefix.
+func readMessage(b *bufio.Reader) (*layers.DNS, int, error) {
+	prefix := make([]byte, 2)
+	if _, err := io.ReadFull(b, prefix); err != nil {
+		return nil, 0, err
+	}
+	length := int(binary.BigEndian.Uint16(prefix))
+	if length < 12 {
+		return nil, 0, fmt.Errorf("A DNS message cannot be smaller than its 12 bytes header: %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(b, data); err != nil {
+		return nil, 0, err
+	}
+
+	dns := &layers.DNS{}
+	if err := dns.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		return nil, 0, err
+	}
+	return dns, len(prefix) + length, nil
+}
+
+func identity(tcpID *api.TcpID, isClient bool, id uint16) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_%d", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, id)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%d", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, id)
+}
+
+func handleClientStream(tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, dns *layers.DNS, size int, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerRequest(identity(tcpID, true, dns.ID), MapDNSLayerToRequest(dns), captureTime, size)
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, dns *layers.DNS, size int, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerResponse(identity(tcpID, false, dns.ID), MapDNSLayerToResponse(dns), captureTime, size)
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
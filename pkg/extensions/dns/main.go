@@ -24,14 +24,54 @@ var dnsProtocol = api.Protocol{
 	Priority:        4,
 }
 
+// dnsTcpProtocol is the protocol of the messages of DNS over TCP.
+var dnsTcpProtocol = api.Protocol{
+	Name:            "dns",
+	Version:         "0",
+	Abbreviation:    "DNS",
+	LongName:        "Domain Name System",
+	Macro:           "dns",
+	BackgroundColor: "#606060",
+	ForegroundColor: "#ffffff",
+	FontSize:        12,
+	ReferenceLink:   "https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml",
+	Ports:           []string{},
+	Layer4:          "tcp",
+	Priority:        4,
+}
+
 type dissecting string
 
 func (d dissecting) Register(extension *api.Extension) {
 	extension.Protocol = &dnsProtocol
 }
 
+// Dissect dissects the messages of DNS over TCP, which large responses fall
+// back to. The ones over UDP are handled by the assemblers.
 func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
-	return fmt.Errorf("N/A")
+	tcpID := reader.GetTcpID()
+	if tcpID.SrcPort != tcpPort && tcpID.DstPort != tcpPort {
+		return fmt.Errorf("N/A")
+	}
+
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	for {
+		dns, size, err := readMessage(b)
+		if err != nil {
+			return err
+		}
+		// Queries only come from the client, and responses from the server
+		if dns.QR == reader.GetIsClient() {
+			return fmt.Errorf("A DNS message in the wrong direction")
+		}
+		reader.GetParent().SetProtocol(&dnsTcpProtocol)
+
+		if reader.GetIsClient() {
+			handleClientStream(tcpID, reader.GetCaptureTime(), reader.GetEmitter(), dns, size, reqResMatcher)
+		} else {
+			handleServerStream(tcpID, reader.GetCaptureTime(), reader.GetEmitter(), dns, size, reqResMatcher)
+		}
+	}
 }
 
 func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
@@ -135,9 +175,55 @@ func representRequest(request map[string]interface{}) (repRequest []interface{})
 		})
 	}
 
+	if request["edns"] != nil {
+		repRequest = representEdns(request["edns"].(map[string]interface{}), "request", repRequest)
+	}
+
 	return
 }
 
+func representEdns(edns map[string]interface{}, selectorPrefix string, rep []interface{}) []interface{} {
+	table := []api.TableData{
+		{
+			Name:     "UDP Payload Size",
+			Value:    edns["udpPayloadSize"].(float64),
+			Selector: fmt.Sprintf("%s.edns.udpPayloadSize", selectorPrefix),
+		},
+		{
+			Name:     "Extended RCODE",
+			Value:    edns["extendedRcode"].(float64),
+			Selector: fmt.Sprintf("%s.edns.extendedRcode", selectorPrefix),
+		},
+		{
+			Name:     "Version",
+			Value:    edns["version"].(float64),
+			Selector: fmt.Sprintf("%s.edns.version", selectorPrefix),
+		},
+		{
+			Name:     "DNSSEC OK",
+			Value:    edns["dnssecOk"].(bool),
+			Selector: fmt.Sprintf("%s.edns.dnssecOk", selectorPrefix),
+		},
+	}
+	if edns["options"] != nil {
+		for i, _option := range edns["options"].([]interface{}) {
+			option := _option.(map[string]interface{})
+			table = append(table, api.TableData{
+				Name:     option["code"].(string),
+				Value:    option["data"].(string),
+				Selector: fmt.Sprintf("%s.edns.options[%d].data", selectorPrefix, i),
+			})
+		}
+	}
+
+	details, _ := json.Marshal(table)
+	return append(rep, api.SectionData{
+		Type:  api.TABLE,
+		Title: "EDNS",
+		Data:  string(details),
+	})
+}
+
 func representAnswers(answers []interface{}, field string, title string, repResponse []interface{}) []interface{} {
 	for i, _answer := range answers {
 		answer, _ := json.Marshal([]api.TableData{
@@ -212,6 +298,11 @@ func representAnswers(answers []interface{}, field string, title string, repResp
 				Value:    _answer.(map[string]interface{})["uri"].(string),
 				Selector: fmt.Sprintf("response.%s[%d].uri", field, i),
 			},
+			{
+				Name:     "Data",
+				Value:    _answer.(map[string]interface{})["data"],
+				Selector: fmt.Sprintf("response.%s[%d].data", field, i),
+			},
 		})
 		repResponse = append(repResponse, api.SectionData{
 			Type:  api.TABLE,
@@ -249,6 +340,10 @@ func representResponse(response map[string]interface{}) (repResponse []interface
 		repResponse = representAnswers(response["additionals"].([]interface{}), "additionals", "Additionals", repResponse)
 	}
 
+	if response["edns"] != nil {
+		repResponse = representEdns(response["edns"].(map[string]interface{}), "response", repResponse)
+	}
+
 	return
 }
 
@@ -263,7 +358,7 @@ func (d dissecting) Represent(request map[string]interface{}, response map[strin
 }
 
 func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
-	return nil
+	return createResponseRequestMatcher()
 }
 
 var Dissector dissecting
@@ -0,0 +1,274 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/gopacket/layers"
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func uint16Bytes(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func name(s string) []byte {
+	var b []byte
+	if s != "." {
+		for _, label := range strings.Split(s, ".") {
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0)
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func record(owner string, t layers.DNSType, class uint16, ttl uint32, data []byte) []byte {
+	header := make([]byte, 10)
+	binary.BigEndian.PutUint16(header, uint16(t))
+	binary.BigEndian.PutUint16(header[2:], class)
+	binary.BigEndian.PutUint32(header[4:], ttl)
+	binary.BigEndian.PutUint16(header[8:], uint16(len(data)))
+	return join(name(owner), header, data)
+}
+
+func option(code layers.DNSOptionCode, data []byte) []byte {
+	return join(uint16Bytes(uint16(code)), uint16Bytes(uint16(len(data))), data)
+}
+
+func TestFormatRData(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      layers.DNSType
+		data     []byte
+		expected string
+	}{
+		{
+			name:     "DS",
+			typ:      typeDS,
+			data:     join(uint16Bytes(20326), []byte{8, 2}, []byte{0xe0, 0x6d, 0x44}),
+			expected: "20326 8 2 E06D44",
+		},
+		{
+			name: "RRSIG",
+			typ:  typeRRSIG,
+			data: join(uint16Bytes(uint16(layers.DNSTypeA)), []byte{13, 2}, []byte{0, 0, 0x01, 0x2c},
+				[]byte{0x65, 0x92, 0x00, 0x80}, []byte{0x65, 0x69, 0x22, 0x00}, uint16Bytes(12345), name("example.com"), []byte{1, 2, 3}),
+			expected: "A 13 2 300 20240101000000 20231201000000 12345 example.com AQID",
+		},
+		{
+			name:     "NSEC",
+			typ:      typeNSEC,
+			data:     join(name("b.example.com"), []byte{0, 6, 0x40, 0, 0, 0, 0, 0x03}),
+			expected: "b.example.com A RRSIG NSEC",
+		},
+		{
+			name:     "DNSKEY",
+			typ:      typeDNSKEY,
+			data:     join(uint16Bytes(257), []byte{3, 13}, []byte{1, 2, 3}),
+			expected: "257 3 13 AQID",
+		},
+		{
+			name:     "NSEC3",
+			typ:      typeNSEC3,
+			data:     join([]byte{1, 0}, uint16Bytes(10), []byte{2, 0xaa, 0xbb}, []byte{5, 1, 2, 3, 4, 5}, []byte{0, 1, 0x40}),
+			expected: "1 0 10 AABB 04106105 A",
+		},
+		{
+			name:     "NSEC3PARAM",
+			typ:      typeNSEC3PARAM,
+			data:     join([]byte{1, 0}, uint16Bytes(0), []byte{0}),
+			expected: "1 0 0 -",
+		},
+		{
+			name: "HTTPS",
+			typ:  typeHTTPS,
+			data: join(uint16Bytes(1), name("."),
+				uint16Bytes(1), uint16Bytes(6), []byte{2, 'h', '2', 2, 'h', '3'},
+				uint16Bytes(3), uint16Bytes(2), uint16Bytes(8443),
+				uint16Bytes(4), uint16Bytes(8), []byte{192, 0, 2, 1, 192, 0, 2, 2}),
+			expected: "1 . alpn=h2,h3 port=8443 ipv4hint=192.0.2.1,192.0.2.2",
+		},
+		{
+			name:     "SVCB alias",
+			typ:      typeSVCB,
+			data:     join(uint16Bytes(0), name("svc.example.com")),
+			expected: "0 svc.example.com",
+		},
+		{
+			name:     "truncated",
+			typ:      typeDNSKEY,
+			data:     []byte{1, 1},
+			expected: "0101",
+		},
+		{
+			name:     "decoded by gopacket",
+			typ:      layers.DNSTypeA,
+			data:     []byte{192, 0, 2, 1},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, formatRData(test.typ, test.data))
+		})
+	}
+}
+
+func TestFormatOption(t *testing.T) {
+	tests := []struct {
+		name     string
+		option   layers.DNSOPT
+		code     string
+		expected string
+	}{
+		{
+			name:     "client subnet",
+			option:   layers.DNSOPT{Code: layers.DNSOptionCodeEDNSClientSubnet, Data: []byte{0, 1, 24, 0, 192, 0, 2}},
+			code:     "EDNSClientSubnet",
+			expected: "192.0.2.0/24/0",
+		},
+		{
+			name:     "client subnet v6",
+			option:   layers.DNSOPT{Code: layers.DNSOptionCodeEDNSClientSubnet, Data: []byte{0, 2, 32, 0, 0x20, 0x01, 0x0d, 0xb8}},
+			code:     "EDNSClientSubnet",
+			expected: "2001:db8::/32/0",
+		},
+		{
+			name:     "client cookie",
+			option:   layers.DNSOPT{Code: layers.DNSOptionCodeCookie, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+			code:     "Cookie",
+			expected: "client=0102030405060708",
+		},
+		{
+			name:     "server cookie",
+			option:   layers.DNSOPT{Code: layers.DNSOptionCodeCookie, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}},
+			code:     "Cookie",
+			expected: "client=0102030405060708, server=090a0b0c0d0e0f10",
+		},
+		{
+			name:     "extended error",
+			option:   layers.DNSOPT{Code: optionExtendedDnsError, Data: join(uint16Bytes(18), []byte("Prohibited"))},
+			code:     "ExtendedDNSError",
+			expected: "18: Prohibited",
+		},
+		{
+			name:     "unknown",
+			option:   layers.DNSOPT{Code: 65001, Data: []byte{0xca, 0xfe}},
+			code:     "OPT65001",
+			expected: "cafe",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.code, optionName(test.option.Code))
+			assert.Equal(t, test.expected, formatOption(test.option))
+		})
+	}
+}
+
+func tcpMessage(parts ...[]byte) []byte {
+	message := join(parts...)
+	return join(uint16Bytes(uint16(len(message))), message)
+}
+
+func TestDissectTCP(t *testing.T) {
+	query := tcpMessage(
+		uint16Bytes(0x1234), uint16Bytes(0x0100), uint16Bytes(1), uint16Bytes(0), uint16Bytes(0), uint16Bytes(1),
+		name("example.com"), uint16Bytes(uint16(typeHTTPS)), uint16Bytes(1),
+		record(".", layers.DNSTypeOPT, 1232, ednsDnssecOk, option(layers.DNSOptionCodeEDNSClientSubnet, []byte{0, 1, 24, 0, 192, 0, 2})),
+	)
+	response := tcpMessage(
+		uint16Bytes(0x1234), uint16Bytes(0x8180), uint16Bytes(1), uint16Bytes(1), uint16Bytes(0), uint16Bytes(1),
+		name("example.com"), uint16Bytes(uint16(typeHTTPS)), uint16Bytes(1),
+		record("example.com", typeHTTPS, 1, 300, join(uint16Bytes(1), name("."), uint16Bytes(1), uint16Bytes(3), []byte{2, 'h', '2'})),
+		record(".", layers.DNSTypeOPT, 1232, 0, option(layers.DNSOptionCodeCookie, []byte{1, 2, 3, 4, 5, 6, 7, 8})),
+	)
+
+	dissector := NewDissector()
+	itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+	stream := NewTcpStream()
+	emitter := &api.Emitting{
+		AppStats:      &api.AppStats{},
+		Stream:        stream,
+		OutputChannel: itemChannel,
+	}
+	counterPair := &api.CounterPair{}
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	tcpIDClient := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "40000", DstPort: "53"}
+	reader := NewTcpReader(&api.ReadProgress{}, "", tcpIDClient, time.Time{}, stream, true, false, nil, emitter, counterPair, reqResMatcher)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(query)), reader))
+
+	tcpIDServer := &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "53", DstPort: "40000"}
+	reader = NewTcpReader(&api.ReadProgress{}, "", tcpIDServer, time.Time{}, stream, false, false, nil, emitter, counterPair, reqResMatcher)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(response)), reader))
+
+	close(itemChannel)
+	var entries []*api.Entry
+	for item := range itemChannel {
+		data, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var finalItem *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(data, &finalItem))
+
+		entry := dissector.Analyze(finalItem, &api.Resolution{}, &api.Resolution{})
+		_, err = dissector.Represent(entry.Request, entry.Response)
+		assert.Nil(t, err)
+		entries = append(entries, entry)
+	}
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+
+	entry := entries[0]
+	assert.Equal(t, "tcp", entry.Protocol.Layer4)
+	assert.Equal(t, "example.com", dissector.Summarize(entry).Summary)
+	assert.Equal(t, "HTTPS", entry.Request["questions"].([]interface{})[0].(map[string]interface{})["type"])
+
+	edns := entry.Request["edns"].(map[string]interface{})
+	assert.Equal(t, float64(1232), edns["udpPayloadSize"])
+	assert.Equal(t, true, edns["dnssecOk"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"code": "EDNSClientSubnet", "data": "192.0.2.0/24/0"}}, edns["options"])
+
+	answer := entry.Response["answers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "HTTPS", answer["type"])
+	assert.Equal(t, "1 . alpn=h2", answer["data"])
+	assert.Equal(t, "Cookie", entry.Response["edns"].(map[string]interface{})["options"].([]interface{})[0].(map[string]interface{})["code"])
+}
+
+func TestDissectTCPNotDNS(t *testing.T) {
+	query := tcpMessage(
+		uint16Bytes(0x1234), uint16Bytes(0x0100), uint16Bytes(1), uint16Bytes(0), uint16Bytes(0), uint16Bytes(0),
+		name("example.com"), uint16Bytes(uint16(layers.DNSTypeA)), uint16Bytes(1),
+	)
+
+	dissector := NewDissector()
+	stream := NewTcpStream()
+	emitter := &api.Emitting{AppStats: &api.AppStats{}, Stream: stream, OutputChannel: make(chan *api.OutputChannelItem, 1)}
+
+	// Only TCP/53 is dissected
+	tcpID := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "40000", DstPort: "8080"}
+	reader := NewTcpReader(&api.ReadProgress{}, "", tcpID, time.Time{}, stream, true, false, nil, emitter, &api.CounterPair{}, dissector.NewResponseRequestMatcher())
+	assert.EqualError(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(query)), reader), "N/A")
+
+	// A query from the server isn't DNS
+	tcpID = &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "53", DstPort: "40000"}
+	reader = NewTcpReader(&api.ReadProgress{}, "", tcpID, time.Time{}, stream, false, false, nil, emitter, &api.CounterPair{}, dissector.NewResponseRequestMatcher())
+	assert.EqualError(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(query)), reader), "A DNS message in the wrong direction")
+}
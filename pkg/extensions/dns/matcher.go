@@ -0,0 +1,78 @@
+package dns
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{id}`
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request DnsRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestDnsMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload:     request,
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseDnsMessage := response.(*api.GenericMessage)
+		if responseDnsMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestDnsMessage, responseDnsMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestDnsMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response DnsResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseDnsMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload:     response,
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestDnsMessage := request.(*api.GenericMessage)
+		if !requestDnsMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestDnsMessage, &responseDnsMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseDnsMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestDnsMessage *api.GenericMessage, responseDnsMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       dnsTcpProtocol,
+		Timestamp:      requestDnsMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestDnsMessage,
+			Response: *responseDnsMessage,
+		},
+	}
+}
@@ -0,0 +1,315 @@
+package dns
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/gopacket/layers"
+)
+
+// The types that gopacket doesn't know about
+const (
+	typeDS         layers.DNSType = 43
+	typeRRSIG      layers.DNSType = 46
+	typeNSEC       layers.DNSType = 47
+	typeDNSKEY     layers.DNSType = 48
+	typeNSEC3      layers.DNSType = 50
+	typeNSEC3PARAM layers.DNSType = 51
+	typeSVCB       layers.DNSType = 64
+	typeHTTPS      layers.DNSType = 65
+)
+
+var typeNames = map[layers.DNSType]string{
+	typeDS:         "DS",
+	typeRRSIG:      "RRSIG",
+	typeNSEC:       "NSEC",
+	typeDNSKEY:     "DNSKEY",
+	typeNSEC3:      "NSEC3",
+	typeNSEC3PARAM: "NSEC3PARAM",
+	typeSVCB:       "SVCB",
+	typeHTTPS:      "HTTPS",
+}
+
+const optionExtendedDnsError layers.DNSOptionCode = 15
+
+// The DO bit of the extended flags of the EDNS0 pseudo-record
+const ednsDnssecOk = 0x8000
+
+// The keys of the parameters of SVCB and HTTPS records
+var svcParamKeys = []string{"mandatory", "alpn", "no-default-alpn", "port", "ipv4hint", "ech", "ipv6hint"}
+
+var base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// typeName names a type the way it's presented, which is TYPE followed by its
+// number when it's unknown.
+func typeName(t layers.DNSType) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	if name := t.String(); name != "Unknown" {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// optionName names an EDNS0 option, which is OPT followed by its code when
+// it's unknown.
+func optionName(code layers.DNSOptionCode) string {
+	if code == optionExtendedDnsError {
+		return "ExtendedDNSError"
+	}
+	if name := code.String(); name != "Unknown" {
+		return name
+	}
+	return fmt.Sprintf("OPT%d", code)
+}
+
+// rdata reads the data of a record, whose names are never compressed for the
+// types that it's used for.
+type rdata struct {
+	b   []byte
+	err bool
+}
+
+func (r *rdata) bytes(n int) []byte {
+	if r.err || n > len(r.b) {
+		r.err = true
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *rdata) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *rdata) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *rdata) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *rdata) rest() []byte {
+	return r.bytes(len(r.b))
+}
+
+func (r *rdata) name() string {
+	var labels []string
+	for !r.err {
+		n := int(r.uint8())
+		if n == 0 {
+			break
+		}
+		if n > 63 {
+			r.err = true
+			break
+		}
+		labels = append(labels, string(r.bytes(n)))
+	}
+	if len(labels) == 0 {
+		return "."
+	}
+	return strings.Join(labels, ".")
+}
+
+// typeBitmap reads the windowed bitmap of the types of NSEC and NSEC3 records.
+func (r *rdata) typeBitmap() string {
+	var types []string
+	for len(r.b) > 0 && !r.err {
+		window := int(r.uint8())
+		bitmap := r.bytes(int(r.uint8()))
+		for i, b := range bitmap {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					types = append(types, typeName(layers.DNSType(window*256+i*8+bit)))
+				}
+			}
+		}
+	}
+	return strings.Join(types, " ")
+}
+
+func formatSignatureTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format("20060102150405")
+}
+
+func formatSalt(salt []byte) string {
+	if len(salt) == 0 {
+		return "-"
+	}
+	return strings.ToUpper(hex.EncodeToString(salt))
+}
+
+// formatRData formats the data of the DNSSEC, SVCB and HTTPS records in their
+// presentation format. The data of the other types is left out, since it's
+// already decoded.
+func formatRData(t layers.DNSType, data []byte) string {
+	r := &rdata{b: data}
+	var s string
+	switch t {
+	case typeDS:
+		s = fmt.Sprintf("%d %d %d %s", r.uint16(), r.uint8(), r.uint8(), strings.ToUpper(hex.EncodeToString(r.rest())))
+	case typeRRSIG:
+		typeCovered := typeName(layers.DNSType(r.uint16()))
+		algorithm := r.uint8()
+		labels := r.uint8()
+		originalTTL := r.uint32()
+		expiration := formatSignatureTime(r.uint32())
+		inception := formatSignatureTime(r.uint32())
+		keyTag := r.uint16()
+		signer := r.name()
+		signature := base64.StdEncoding.EncodeToString(r.rest())
+		s = fmt.Sprintf("%s %d %d %d %s %s %d %s %s", typeCovered, algorithm, labels, originalTTL, expiration, inception, keyTag, signer, signature)
+	case typeNSEC:
+		next := r.name()
+		s = strings.TrimSpace(fmt.Sprintf("%s %s", next, r.typeBitmap()))
+	case typeDNSKEY:
+		s = fmt.Sprintf("%d %d %d %s", r.uint16(), r.uint8(), r.uint8(), base64.StdEncoding.EncodeToString(r.rest()))
+	case typeNSEC3:
+		algorithm := r.uint8()
+		flags := r.uint8()
+		iterations := r.uint16()
+		salt := formatSalt(r.bytes(int(r.uint8())))
+		next := base32Hex.EncodeToString(r.bytes(int(r.uint8())))
+		s = strings.TrimSpace(fmt.Sprintf("%d %d %d %s %s %s", algorithm, flags, iterations, salt, next, r.typeBitmap()))
+	case typeNSEC3PARAM:
+		algorithm := r.uint8()
+		flags := r.uint8()
+		iterations := r.uint16()
+		s = fmt.Sprintf("%d %d %d %s", algorithm, flags, iterations, formatSalt(r.bytes(int(r.uint8()))))
+	case typeSVCB, typeHTTPS:
+		s = formatSvcb(r)
+	default:
+		return ""
+	}
+
+	if r.err {
+		return hex.EncodeToString(data)
+	}
+	return s
+}
+
+// formatSvcb formats the priority, the target and the parameters of a SVCB
+// or HTTPS record, like `1 . alpn=h2,h3 ipv4hint=192.0.2.1`.
+func formatSvcb(r *rdata) string {
+	parts := []string{strconv.Itoa(int(r.uint16())), r.name()}
+	for len(r.b) > 0 && !r.err {
+		key := int(r.uint16())
+		value := &rdata{b: r.bytes(int(r.uint16()))}
+
+		name := fmt.Sprintf("key%d", key)
+		if key < len(svcParamKeys) {
+			name = svcParamKeys[key]
+		}
+
+		var values []string
+		switch name {
+		case "mandatory":
+			for len(value.b) > 0 && !value.err {
+				mandatory := int(value.uint16())
+				if mandatory < len(svcParamKeys) {
+					values = append(values, svcParamKeys[mandatory])
+				} else {
+					values = append(values, fmt.Sprintf("key%d", mandatory))
+				}
+			}
+		case "alpn":
+			for len(value.b) > 0 && !value.err {
+				values = append(values, string(value.bytes(int(value.uint8()))))
+			}
+		case "no-default-alpn":
+		case "port":
+			values = append(values, strconv.Itoa(int(value.uint16())))
+		case "ipv4hint":
+			for len(value.b) > 0 && !value.err {
+				values = append(values, net.IP(value.bytes(net.IPv4len)).String())
+			}
+		case "ipv6hint":
+			for len(value.b) > 0 && !value.err {
+				values = append(values, net.IP(value.bytes(net.IPv6len)).String())
+			}
+		case "ech":
+			values = append(values, base64.StdEncoding.EncodeToString(value.rest()))
+		default:
+			values = append(values, hex.EncodeToString(value.rest()))
+		}
+		if value.err {
+			r.err = true
+		}
+
+		if len(values) == 0 {
+			parts = append(parts, name)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, strings.Join(values, ",")))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatOption formats the data of an EDNS0 option, which is hex encoded
+// unless it's an option whose format is known.
+func formatOption(opt layers.DNSOPT) string {
+	r := &rdata{b: opt.Data}
+	var s string
+	switch opt.Code {
+	case layers.DNSOptionCodeEDNSClientSubnet:
+		// The address is cut short to the source prefix length
+		family := r.uint16()
+		sourcePrefix := r.uint8()
+		scopePrefix := r.uint8()
+		address := r.rest()
+		var ip net.IP
+		switch family {
+		case 1:
+			ip = make(net.IP, net.IPv4len)
+		case 2:
+			ip = make(net.IP, net.IPv6len)
+		default:
+			r.err = true
+		}
+		if len(address) > len(ip) {
+			r.err = true
+		}
+		copy(ip, address)
+		s = fmt.Sprintf("%s/%d/%d", ip, sourcePrefix, scopePrefix)
+	case layers.DNSOptionCodeCookie:
+		s = fmt.Sprintf("client=%s", hex.EncodeToString(r.bytes(8)))
+		if server := r.rest(); len(server) > 0 {
+			s = fmt.Sprintf("%s, server=%s", s, hex.EncodeToString(server))
+		}
+	case layers.DNSOptionCodePadding:
+		s = fmt.Sprintf("%d bytes", len(opt.Data))
+	case optionExtendedDnsError:
+		s = strconv.Itoa(int(r.uint16()))
+		if text := r.rest(); len(text) > 0 {
+			s = fmt.Sprintf("%s: %s", s, text)
+		}
+	default:
+		return hex.EncodeToString(opt.Data)
+	}
+
+	if r.err {
+		return hex.EncodeToString(opt.Data)
+	}
+	return s
+}
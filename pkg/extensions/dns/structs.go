@@ -0,0 +1,184 @@
+package dns
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/kubeshark/gopacket/layers"
+)
+
+type DnsQuestion struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+}
+
+type DnsRequest struct {
+	OpCode    string        `json:"opCode"`
+	Questions []DnsQuestion `json:"questions"`
+	Edns      *DnsEdns      `json:"edns,omitempty"`
+}
+
+type DnsAnswer struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	TTL   uint32 `json:"ttl"`
+	IP    string `json:"ip"`
+	NS    string `json:"ns"`
+	CNAME string `json:"cname"`
+	PTR   string `json:"ptr"`
+	TXTs  string `json:"txts"`
+	SOA   string `json:"soa"`
+	SRV   string `json:"srv"`
+	MX    string `json:"mx"`
+	OPT   string `json:"opt"`
+	URI   string `json:"uri"`
+	Data  string `json:"data"`
+}
+
+type DnsResponse struct {
+	Code        string      `json:"code"`
+	Answers     []DnsAnswer `json:"answers"`
+	Authorities []DnsAnswer `json:"authorities"`
+	Additionals []DnsAnswer `json:"additionals"`
+	Edns        *DnsEdns    `json:"edns,omitempty"`
+}
+
+// DnsEdns is the EDNS0 pseudo-record of a message, whose class and TTL are
+// the UDP payload size and the extended flags.
+type DnsEdns struct {
+	UdpPayloadSize uint16      `json:"udpPayloadSize"`
+	ExtendedRcode  uint8       `json:"extendedRcode"`
+	Version        uint8       `json:"version"`
+	DnssecOk       bool        `json:"dnssecOk"`
+	Options        []DnsOption `json:"options"`
+}
+
+type DnsOption struct {
+	Code string `json:"code"`
+	Data string `json:"data"`
+}
+
+func mapResourceRecordToAnswer(r layers.DNSResourceRecord) DnsAnswer {
+	var txts string
+	for _, txt := range r.TXTs {
+		txts = fmt.Sprintf("%s, %s", txts, string(txt))
+	}
+
+	var opts string
+	for _, opt := range r.OPT {
+		opts = fmt.Sprintf("%s, %s", opts, opt.Code.String())
+	}
+
+	name := string(r.Name)
+	b64, err := base64.StdEncoding.DecodeString(string(r.Name))
+	if err == nil {
+		name = string(b64)
+	}
+
+	return DnsAnswer{
+		Name:  name,
+		Type:  typeName(r.Type),
+		Class: r.Class.String(),
+		TTL:   r.TTL,
+		IP:    r.IP.String(),
+		NS:    string(r.NS),
+		CNAME: string(r.CNAME),
+		PTR:   string(r.PTR),
+		TXTs:  txts,
+		SOA:   string(r.SOA.RName),
+		SRV:   string(r.SRV.Name),
+		MX:    string(r.MX.Name),
+		OPT:   opts,
+		URI:   string(r.URI.Target),
+		Data:  formatRData(r.Type, r.Data),
+	}
+}
+
+// MapDNSLayerToRequest maps the questions of a message to the request of an
+// entry.
+func MapDNSLayerToRequest(dns *layers.DNS) DnsRequest {
+	var questions []DnsQuestion
+	for _, q := range dns.Questions {
+		name := string(q.Name)
+		b64, err := base64.StdEncoding.DecodeString(string(q.Name))
+		if err == nil {
+			name = string(b64)
+		}
+
+		questions = append(questions, DnsQuestion{
+			Name:  name,
+			Type:  typeName(q.Type),
+			Class: q.Class.String(),
+		})
+	}
+
+	return DnsRequest{
+		OpCode:    dns.OpCode.String(),
+		Questions: questions,
+		Edns:      mapEdns(dns.Additionals),
+	}
+}
+
+// MapDNSLayerToResponse maps the records of a message to the response of an
+// entry.
+func MapDNSLayerToResponse(dns *layers.DNS) DnsResponse {
+	var answers []DnsAnswer
+	for _, r := range dns.Answers {
+		answers = append(answers, mapResourceRecordToAnswer(r))
+	}
+
+	var authorities []DnsAnswer
+	for _, r := range dns.Authorities {
+		authorities = append(authorities, mapResourceRecordToAnswer(r))
+	}
+
+	var additionals []DnsAnswer
+	for _, r := range dns.Additionals {
+		additionals = append(additionals, mapResourceRecordToAnswer(r))
+	}
+
+	return DnsResponse{
+		Code:        dns.ResponseCode.String(),
+		Answers:     answers,
+		Authorities: authorities,
+		Additionals: additionals,
+		Edns:        mapEdns(dns.Additionals),
+	}
+}
+
+func mapEdns(additionals []layers.DNSResourceRecord) *DnsEdns {
+	for _, r := range additionals {
+		if r.Type != layers.DNSTypeOPT {
+			continue
+		}
+
+		options := make([]DnsOption, 0, len(r.OPT))
+		for _, opt := range r.OPT {
+			options = append(options, DnsOption{
+				Code: optionName(opt.Code),
+				Data: formatOption(opt),
+			})
+		}
+		return &DnsEdns{
+			UdpPayloadSize: uint16(r.Class),
+			ExtendedRcode:  uint8(r.TTL >> 24),
+			Version:        uint8(r.TTL >> 16),
+			DnssecOk:       r.TTL&ednsDnssecOk != 0,
+			Options:        options,
+		}
+	}
+	return nil
+}
+
+// ResponseCodeTimeout is the code of the response of an entry whose query was
+// never answered.
+const ResponseCodeTimeout = "Timeout"
+
+// TimeoutResponse is the response of an entry whose query has timed out.
+func TimeoutResponse() DnsResponse {
+	return DnsResponse{
+		Code: ResponseCodeTimeout,
+	}
+}
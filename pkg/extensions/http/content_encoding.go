@@ -0,0 +1,153 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var maxDecodedBodySize = 16 * 1024 * 1024
+
+// SetMaxDecodedBodySize sets the size of the bodies that are decoded from
+// their Content-Encoding, past which the bodies are cut short.
+func SetMaxDecodedBodySize(size int) {
+	maxDecodedBodySize = size
+}
+
+// ContentEncoding describes a body that is decoded from its Content-Encoding.
+// The body is kept as it was captured if it can't be decoded.
+type ContentEncoding struct {
+	Encoding     string `json:"encoding"`
+	OriginalSize int    `json:"originalSize"`
+	Truncated    bool   `json:"truncated"`
+	Error        string `json:"error,omitempty"`
+}
+
+// decodeContent decodes a body from the encodings of its Content-Encoding
+// header, in the reverse order of their application. The header is removed
+// once the body is handled, so that the HAR conversion doesn't decode it
+// again, and is put back in the HAR headers from the returned encoding.
+func decodeContent(header http.Header, body []byte) ([]byte, *ContentEncoding) {
+	values := header.Values("Content-Encoding")
+	if len(values) == 0 {
+		return body, nil
+	}
+
+	var encodings []string
+	for _, value := range values {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return body, nil
+	}
+
+	header.Del("Content-Encoding")
+	contentEncoding := &ContentEncoding{
+		Encoding:     strings.Join(values, ", "),
+		OriginalSize: len(body),
+	}
+	if len(body) == 0 {
+		return body, contentEncoding
+	}
+
+	decoded := body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var truncated bool
+		var err error
+		decoded, truncated, err = decodeBody(encodings[i], decoded, maxDecodedBodySize)
+		if err != nil {
+			contentEncoding.Error = err.Error()
+			return body, contentEncoding
+		}
+		contentEncoding.Truncated = contentEncoding.Truncated || truncated
+	}
+
+	return decoded, contentEncoding
+}
+
+// decodeBody decodes the data up to the limit. A body that is cut short,
+// like the HTTP/2 bodies past maxHTTP2DataLen, gives what could be decoded.
+func decodeBody(encoding string, data []byte, limit int) ([]byte, bool, error) {
+	reader, err := newContentReader(encoding, data)
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err == io.ErrUnexpectedEOF && len(decoded) > 0 {
+		return decoded, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(decoded) > limit {
+		return decoded[:limit], true, nil
+	}
+	return decoded, false, nil
+}
+
+func newContentReader(encoding string, data []byte) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		// Some servers send a raw DEFLATE stream instead of the zlib format
+		if reader, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			return reader, nil
+		}
+		return flate.NewReader(bytes.NewReader(data)), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(bytes.NewReader(data))), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+func representContentEncoding(contentEncoding map[string]interface{}, selector string) []api.TableData {
+	table := []api.TableData{
+		{
+			Name:     "Content Encoding",
+			Value:    contentEncoding["encoding"],
+			Selector: selector + ".encoding",
+		},
+		{
+			Name:     "Encoded Body Size (bytes)",
+			Value:    int64(contentEncoding["originalSize"].(float64)),
+			Selector: selector + ".originalSize",
+		},
+		{
+			Name:     "Truncated",
+			Value:    contentEncoding["truncated"],
+			Selector: selector + ".truncated",
+		},
+	}
+	if decodingError, ok := contentEncoding["error"]; ok {
+		table = append(table, api.TableData{
+			Name:     "Decoding Error",
+			Value:    decodingError,
+			Selector: selector + ".error",
+		})
+	}
+	return table
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func encodeContent(t *testing.T, encoding string, data []byte) []byte {
+	var encoded bytes.Buffer
+	switch encoding {
+	case "gzip":
+		writer := gzip.NewWriter(&encoded)
+		_, err := writer.Write(data)
+		assert.Nil(t, err)
+		assert.Nil(t, writer.Close())
+	case "deflate":
+		writer := zlib.NewWriter(&encoded)
+		_, err := writer.Write(data)
+		assert.Nil(t, err)
+		assert.Nil(t, writer.Close())
+	case "raw-deflate":
+		writer, err := flate.NewWriter(&encoded, flate.DefaultCompression)
+		assert.Nil(t, err)
+		_, err = writer.Write(data)
+		assert.Nil(t, err)
+		assert.Nil(t, writer.Close())
+	case "br":
+		writer := brotli.NewWriter(&encoded)
+		_, err := writer.Write(data)
+		assert.Nil(t, err)
+		assert.Nil(t, writer.Close())
+	case "zstd":
+		encoder, err := zstd.NewWriter(nil)
+		assert.Nil(t, err)
+		return encoder.EncodeAll(data, nil)
+	}
+	return encoded.Bytes()
+}
+
+// dissectHTTPEntries returns the entries of a connection as the worker would
+// see them, after a round trip through JSON.
+func dissectHTTPEntries(t *testing.T, client []byte, server []byte) (entries []*api.Entry) {
+	for _, item := range dissectConnection(t, client, server) {
+		marshaled, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var unmarshaled *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(marshaled, &unmarshaled))
+
+		entry := NewDissector().Analyze(unmarshaled, &api.Resolution{}, &api.Resolution{})
+		entries = append(entries, entry)
+
+		// The entries are represented as they're read back from the database
+		marshaled, err = json.Marshal(entry)
+		assert.Nil(t, err)
+		var unmarshaledEntry *api.Entry
+		assert.Nil(t, json.Unmarshal(marshaled, &unmarshaledEntry))
+		_, err = NewDissector().Represent(unmarshaledEntry.Request, unmarshaledEntry.Response)
+		assert.Nil(t, err)
+	}
+	return
+}
+
+func responseText(t *testing.T, entry *api.Entry) string {
+	content := entry.Response["content"].(map[string]interface{})
+	text, err := base64.StdEncoding.DecodeString(content["text"].(string))
+	assert.Nil(t, err)
+	return string(text)
+}
+
+func TestContentEncodingHTTP1(t *testing.T) {
+	body := []byte(`{"id":42,"name":"kubeshark"}`)
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			encoded := encodeContent(t, encoding, body)
+			client := fmt.Sprintf("POST /items HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\nContent-Encoding: %s\r\nContent-Length: %d\r\n\r\n%s", encoding, len(encoded), encoded)
+			server := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Encoding: %s\r\nContent-Length: %d\r\n\r\n%s", encoding, len(encoded), encoded)
+
+			entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+			assert.Len(t, entries, 1)
+			entry := entries[0]
+
+			assert.Equal(t, string(body), entry.Request["postData"].(map[string]interface{})["text"])
+			assert.Equal(t, map[string]interface{}{
+				"encoding":     encoding,
+				"originalSize": float64(len(encoded)),
+				"truncated":    false,
+			}, entry.Request["contentEncoding"])
+
+			assert.Equal(t, string(body), responseText(t, entry))
+			assert.Equal(t, float64(len(body)), entry.Response["content"].(map[string]interface{})["size"])
+			assert.Equal(t, float64(len(encoded)), entry.Response["contentEncoding"].(map[string]interface{})["originalSize"])
+			assert.Equal(t, encoding, entry.Response["headers"].(map[string]interface{})["Content-Encoding"])
+		})
+	}
+}
+
+func TestContentEncodingRawDeflate(t *testing.T) {
+	body := []byte("hello deflate")
+	encoded := encodeContent(t, "raw-deflate", body)
+	client := "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	server := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Encoding: deflate\r\nContent-Length: %d\r\n\r\n%s", len(encoded), encoded)
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, string(body), responseText(t, entries[0]))
+}
+
+func TestContentEncodingLimit(t *testing.T) {
+	defer SetMaxDecodedBodySize(maxDecodedBodySize)
+	SetMaxDecodedBodySize(1024)
+
+	encoded := encodeContent(t, "gzip", bytes.Repeat([]byte{'a'}, 1024*1024))
+	client := "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	server := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\nContent-Length: %d\r\n\r\n%s", len(encoded), encoded)
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	assert.Len(t, entries, 1)
+	assert.Len(t, responseText(t, entries[0]), 1024)
+	assert.Equal(t, true, entries[0].Response["contentEncoding"].(map[string]interface{})["truncated"])
+}
+
+func TestContentEncodingInvalid(t *testing.T) {
+	client := "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	server := "HTTP/1.1 200 OK\r\nContent-Encoding: compress\r\nContent-Length: 5\r\n\r\nhello"
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "hello", responseText(t, entries[0]))
+	assert.Equal(t, "unsupported content encoding: compress", entries[0].Response["contentEncoding"].(map[string]interface{})["error"])
+	assert.Equal(t, "compress", entries[0].Response["headers"].(map[string]interface{})["Content-Encoding"])
+}
+
+func TestContentEncodingHTTP2(t *testing.T) {
+	body := []byte(`{"id":42}`)
+	encoded := encodeContent(t, "br", body)
+
+	headerBlock := func(fields ...string) []byte {
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		for i := 0; i < len(fields); i += 2 {
+			assert.Nil(t, encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+		}
+		return block.Bytes()
+	}
+
+	var client bytes.Buffer
+	client.WriteString(http2.ClientPreface)
+	framer := http2.NewFramer(&client, nil)
+	assert.Nil(t, framer.WriteSettings())
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(":method", "GET", ":scheme", "http", ":path", "/items/42", ":authority", "example.com", "accept-encoding", "br"),
+		EndHeaders:    true,
+		EndStream:     true,
+	}))
+
+	var server bytes.Buffer
+	framer = http2.NewFramer(&server, nil)
+	assert.Nil(t, framer.WriteSettings())
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(":status", fmt.Sprint(http.StatusOK), "content-type", "application/json", "content-encoding", "br"),
+		EndHeaders:    true,
+	}))
+	assert.Nil(t, framer.WriteData(1, false, encoded[:len(encoded)/2]))
+	assert.Nil(t, framer.WriteData(1, true, encoded[len(encoded)/2:]))
+
+	entries := dissectHTTPEntries(t, client.Bytes(), server.Bytes())
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+
+	assert.Equal(t, http2Protocol.Abbreviation, entry.Protocol.Abbreviation)
+	// The HTTP/2 bodies are base64 encoded by the assembler
+	text, err := base64.StdEncoding.DecodeString(responseText(t, entry))
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), string(text))
+	assert.Equal(t, map[string]interface{}{
+		"encoding":     "br",
+		"originalSize": float64(len(encoded)),
+		"truncated":    false,
+	}, entry.Response["contentEncoding"])
+}
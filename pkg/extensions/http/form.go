@@ -0,0 +1,204 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/martian/har"
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const maxFormPartPreviewSize = 1024
+
+// FormPart is a part of a multipart/form-data body. The text is a preview
+// of the part, which is left empty if the part is binary.
+type FormPart struct {
+	Name        string `json:"name"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int    `json:"size"`
+	Text        string `json:"text"`
+}
+
+// postData is the HAR post data of a request along with the parts of its
+// multipart/form-data body.
+type postData struct {
+	MimeType string      `json:"mimeType"`
+	Params   []har.Param `json:"params"`
+	Parts    []FormPart  `json:"parts,omitempty"`
+	Text     string      `json:"text"`
+	Encoding string      `json:"encoding,omitempty"`
+}
+
+// newPostData reads the body of a request into its post data. The form
+// bodies are parsed into params, up to the point where they're cut short
+// or malformed, instead of failing the request.
+func newPostData(request *http.Request, harPostData *har.PostData) *postData {
+	data := &postData{
+		MimeType: harPostData.MimeType,
+		Params:   []har.Param{},
+	}
+	if request.Body == nil {
+		return data
+	}
+
+	body, _ := io.ReadAll(request.Body)
+	request.Body = io.NopCloser(bytes.NewBuffer(body)) // rewind
+	text := string(body)
+
+	// The bodies of HTTP/2 are base64 encoded by the assembler
+	if request.ProtoMajor == protoMajorHTTP2 {
+		if decoded, err := base64.StdEncoding.DecodeString(text); err == nil {
+			body = decoded
+		}
+	}
+
+	switch data.MimeType {
+	case "multipart/form-data":
+		_, params, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		data.Params, data.Parts = parseMultipart(body, params["boundary"])
+		if len(data.Parts) == 0 {
+			data.Text = text
+		}
+	case "application/x-www-form-urlencoded":
+		data.Params = parseUrlencoded(body)
+		data.Text = text
+	default:
+		data.Text = text
+	}
+
+	if !utf8.ValidString(data.Text) {
+		data.Text = base64.StdEncoding.EncodeToString([]byte(data.Text))
+		data.Encoding = "base64"
+	}
+
+	return data
+}
+
+func parseMultipart(body []byte, boundary string) (params []har.Param, parts []FormPart) {
+	params = []har.Param{}
+	if boundary == "" {
+		return
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return
+		}
+
+		content, err := io.ReadAll(part)
+		formPart := FormPart{
+			Name:        part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        len(content),
+			Text:        formPartPreview(content),
+		}
+		parts = append(parts, formPart)
+
+		param := har.Param{
+			Name:        formPart.Name,
+			Filename:    formPart.FileName,
+			ContentType: formPart.ContentType,
+		}
+		// The content of the files is only previewed in the parts
+		if formPart.FileName == "" {
+			param.Value = string(content)
+		}
+		params = append(params, param)
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func formPartPreview(content []byte) string {
+	if !utf8.Valid(content) {
+		return ""
+	}
+	if len(content) > maxFormPartPreviewSize {
+		// A rune that's cut in half is left out
+		return strings.ToValidUTF8(string(content[:maxFormPartPreviewSize]), "")
+	}
+	return string(content)
+}
+
+func parseUrlencoded(body []byte) (params []har.Param) {
+	params = []har.Param{}
+	// The values that precede a malformed pair are kept
+	values, _ := url.ParseQuery(string(body))
+	for name, vs := range values {
+		for _, value := range vs {
+			params = append(params, har.Param{
+				Name:  name,
+				Value: value,
+			})
+		}
+	}
+	return
+}
+
+func representFormPart(part map[string]interface{}, selector string) string {
+	table := []api.TableData{
+		{
+			Name:     "Name",
+			Value:    part["name"],
+			Selector: selector + ".name",
+		},
+	}
+	if fileName, ok := part["fileName"]; ok {
+		table = append(table, api.TableData{
+			Name:     "File Name",
+			Value:    fileName,
+			Selector: selector + ".fileName",
+		})
+	}
+	if contentType, ok := part["contentType"]; ok {
+		table = append(table, api.TableData{
+			Name:     "Content Type",
+			Value:    contentType,
+			Selector: selector + ".contentType",
+		})
+	}
+	table = append(table, api.TableData{
+		Name:     "Size (bytes)",
+		Value:    int64(part["size"].(float64)),
+		Selector: selector + ".size",
+	})
+	table = append(table, api.TableData{
+		Name:     "Preview",
+		Value:    part["text"],
+		Selector: selector + ".text",
+	})
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
+
+func representFormParts(parts []interface{}) (sections []interface{}) {
+	for i, part := range parts {
+		part := part.(map[string]interface{})
+		title := fmt.Sprintf("Part [%d]", i)
+		if name, _ := part["name"].(string); name != "" {
+			title = fmt.Sprintf("Part (%s)", name)
+		}
+		sections = append(sections, api.SectionData{
+			Type:  api.TABLE,
+			Title: title,
+			Data:  representFormPart(part, fmt.Sprintf("request.postData.parts[%d]", i)),
+		})
+	}
+	return
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func multipartBody(t *testing.T) (string, []byte) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	assert.Nil(t, writer.WriteField("user", "alice"))
+	assert.Nil(t, writer.WriteField("tag", "a"))
+	assert.Nil(t, writer.WriteField("tag", "b"))
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="avatar"; filename="avatar.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	assert.Nil(t, err)
+	_, err = part.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0xfe})
+	assert.Nil(t, err)
+
+	part, err = writer.CreateFormFile("notes", "notes.txt")
+	assert.Nil(t, err)
+	_, err = part.Write([]byte(strings.Repeat("x", maxFormPartPreviewSize+10)))
+	assert.Nil(t, err)
+
+	assert.Nil(t, writer.Close())
+	return writer.FormDataContentType(), body.Bytes()
+}
+
+func formRequest(contentType string, body []byte) []byte {
+	return []byte(fmt.Sprintf("POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s", contentType, len(body), body))
+}
+
+func representation(t *testing.T, entry *api.Entry) map[string]interface{} {
+	marshaled, err := json.Marshal(entry)
+	assert.Nil(t, err)
+	var unmarshaled *api.Entry
+	assert.Nil(t, json.Unmarshal(marshaled, &unmarshaled))
+
+	object, err := NewDissector().Represent(unmarshaled.Request, unmarshaled.Response)
+	assert.Nil(t, err)
+	var result map[string]interface{}
+	assert.Nil(t, json.Unmarshal(object, &result))
+
+	sections := make(map[string]interface{})
+	for _, section := range result["request"].([]interface{}) {
+		section := section.(map[string]interface{})
+		sections[section["title"].(string)] = section
+	}
+	return sections
+}
+
+func TestFormMultipart(t *testing.T) {
+	contentType, body := multipartBody(t)
+	entries := dissectHTTPEntries(t, formRequest(contentType, body), []byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	assert.Len(t, entries, 1)
+	postData := entries[0].Request["postData"].(map[string]interface{})
+
+	assert.Equal(t, "multipart/form-data", postData["mimeType"])
+	assert.Equal(t, "", postData["text"])
+
+	params := postData["params"].(map[string]interface{})
+	assert.Equal(t, "alice", params["user"])
+	assert.Equal(t, []interface{}{"a", "b"}, params["tag"])
+
+	parts := postData["parts"].([]interface{})
+	assert.Len(t, parts, 5)
+	assert.Equal(t, map[string]interface{}{
+		"name":        "avatar",
+		"fileName":    "avatar.png",
+		"contentType": "image/png",
+		"size":        float64(6),
+		"text":        "",
+	}, parts[3])
+	notes := parts[4].(map[string]interface{})
+	assert.Equal(t, float64(maxFormPartPreviewSize+10), notes["size"])
+	assert.Len(t, notes["text"], maxFormPartPreviewSize)
+
+	sections := representation(t, entries[0])
+	assert.Contains(t, sections, "POST Data (multipart/form-data)")
+	assert.Contains(t, sections, "Part (avatar)")
+	var table []api.TableData
+	assert.Nil(t, json.Unmarshal([]byte(sections["POST Data (multipart/form-data)"].(map[string]interface{})["data"].(string)), &table))
+	assert.Contains(t, table, api.TableData{Name: "user", Value: "alice", Selector: `request.postData.params["user"]`})
+}
+
+func TestFormMultipartTruncated(t *testing.T) {
+	contentType, body := multipartBody(t)
+	// The body is cut short in the middle of the file
+	body = body[:bytes.Index(body, []byte("PNG"))]
+
+	entries := dissectHTTPEntries(t, formRequest(contentType, body), []byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	assert.Len(t, entries, 1)
+	postData := entries[0].Request["postData"].(map[string]interface{})
+
+	assert.Equal(t, "alice", postData["params"].(map[string]interface{})["user"])
+	assert.Len(t, postData["parts"], 4)
+}
+
+func TestFormMultipartMalformed(t *testing.T) {
+	entries := dissectHTTPEntries(t, formRequest("multipart/form-data; boundary=missing", []byte("not a multipart body")), []byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	assert.Len(t, entries, 1)
+	postData := entries[0].Request["postData"].(map[string]interface{})
+
+	assert.Equal(t, "not a multipart body", postData["text"])
+	assert.Nil(t, postData["parts"])
+	assert.Empty(t, postData["params"])
+}
+
+func TestFormUrlencoded(t *testing.T) {
+	body := []byte("user=alice&city=New+York&tag=a&tag=b")
+	entries := dissectHTTPEntries(t, formRequest("application/x-www-form-urlencoded", body), []byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	assert.Len(t, entries, 1)
+	postData := entries[0].Request["postData"].(map[string]interface{})
+
+	assert.Equal(t, string(body), postData["text"])
+	assert.Equal(t, map[string]interface{}{
+		"user": "alice",
+		"city": "New York",
+		"tag":  []interface{}{"a", "b"},
+	}, postData["params"])
+
+	sections := representation(t, entries[0])
+	var table []api.TableData
+	assert.Nil(t, json.Unmarshal([]byte(sections["POST Data (application/x-www-form-urlencoded)"].(map[string]interface{})["data"].(string)), &table))
+	assert.Contains(t, table, api.TableData{Name: "city", Value: "New York", Selector: `request.postData.params["city"]`})
+}
@@ -1,31 +1,223 @@
 package http
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"strings"
 
+	"github.com/kubeshark/worker/pkg/api"
 	"github.com/mertyildiran/gqlparser/v2/ast"
 	"github.com/mertyildiran/gqlparser/v2/parser"
 )
 
-func isGraphQL(request map[string]interface{}) bool {
+// parseJSONText parses a body of the entry, which is base64 encoded once
+// more if it comes from HTTP/2.
+func parseJSONText(text string, v interface{}) error {
+	err := json.Unmarshal([]byte(text), v)
+	if err == nil {
+		return nil
+	}
+	if decoded, decodeErr := base64.StdEncoding.DecodeString(text); decodeErr == nil {
+		return json.Unmarshal(decoded, v)
+	}
+	return err
+}
+
+// parseGraphQLRequest extracts the operation of a GraphQL request, which is
+// either posted in a JSON or an application/graphql body, or given in the
+// query string of a GET request. It returns nil if the request isn't GraphQL.
+func parseGraphQLRequest(request map[string]interface{}) map[string]interface{} {
+	var query, operationName string
+	var variables interface{}
 	if postData, ok := request["postData"].(map[string]interface{}); ok {
-		if postData["mimeType"] == "application/json" {
-			if text, ok := postData["text"].(string); ok {
-				var data map[string]interface{}
-				if err := json.Unmarshal([]byte(text), &data); err != nil {
-					return false
-				}
+		text, _ := postData["text"].(string)
+		switch postData["mimeType"] {
+		case "application/json":
+			var data map[string]interface{}
+			if err := parseJSONText(text, &data); err != nil {
+				return nil
+			}
+			query, _ = data["query"].(string)
+			operationName, _ = data["operationName"].(string)
+			variables = data["variables"]
+		case "application/graphql":
+			query = text
+		}
+	} else if queryString, ok := request["queryString"].(map[string]interface{}); ok {
+		query, _ = queryString["query"].(string)
+		operationName, _ = queryString["operationName"].(string)
+		if text, ok := queryString["variables"].(string); ok {
+			_ = json.Unmarshal([]byte(text), &variables)
+		}
+	}
+	if query == "" {
+		return nil
+	}
+
+	document, err := parser.ParseQuery(&ast.Source{Name: "request", Input: query})
+	if err != nil || len(document.Operations) == 0 {
+		return nil
+	}
 
-				if query, ok := data["query"].(string); ok {
+	operation := document.Operations.ForName(operationName)
+	if operation == nil {
+		operation = document.Operations[0]
+	}
+	operationType := string(operation.Operation)
+	if operationType == "" {
+		operationType = string(ast.Query)
+	}
+
+	if variables == nil {
+		variables = make(map[string]interface{})
+	}
+
+	return map[string]interface{}{
+		"operationType":  operationType,
+		"operationName":  operation.Name,
+		"fields":         graphQLFields(operation.SelectionSet, document.Fragments, make(map[string]bool), make(map[string]bool)),
+		"variables":      variables,
+		"operationCount": len(document.Operations),
+	}
+}
+
+// graphQLFields returns the names of the top-level fields of a selection set,
+// the fields of its fragments included.
+func graphQLFields(selectionSet ast.SelectionSet, fragments ast.FragmentDefinitionList, seen map[string]bool, spread map[string]bool) (fields []interface{}) {
+	fields = make([]interface{}, 0)
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if !seen[selection.Name] {
+				seen[selection.Name] = true
+				fields = append(fields, selection.Name)
+			}
+		case *ast.InlineFragment:
+			fields = append(fields, graphQLFields(selection.SelectionSet, fragments, seen, spread)...)
+		case *ast.FragmentSpread:
+			// A fragment can't spread itself, but the query isn't validated
+			if fragment := fragments.ForName(selection.Name); fragment != nil && !spread[selection.Name] {
+				spread[selection.Name] = true
+				fields = append(fields, graphQLFields(fragment.SelectionSet, fragments, seen, spread)...)
+			}
+		}
+	}
+	return
+}
 
-					_, err := parser.ParseQuery(&ast.Source{Name: "ff", Input: query})
-					if err == nil {
-						return true
-					}
+// parseGraphQLResponse extracts the errors of a GraphQL response.
+func parseGraphQLResponse(response map[string]interface{}) map[string]interface{} {
+	errors := make([]interface{}, 0)
+	if content, ok := response["content"].(map[string]interface{}); ok {
+		if text, ok := content["text"].(string); ok {
+			if content["encoding"] == "base64" {
+				if decoded, err := base64.StdEncoding.DecodeString(text); err == nil {
+					text = string(decoded)
+				}
+			}
+			var data map[string]interface{}
+			if err := parseJSONText(text, &data); err == nil {
+				if list, ok := data["errors"].([]interface{}); ok {
+					errors = list
 				}
 			}
 		}
 	}
 
-	return false
+	return map[string]interface{}{
+		"errors": errors,
+	}
+}
+
+func summarizeGraphQL(graphQL map[string]interface{}, path string) (summary string, summaryQuery string) {
+	operationType := graphQL["operationType"].(string)
+	operationName := graphQL["operationName"].(string)
+	if operationName != "" {
+		summary = fmt.Sprintf("%s %s", operationType, operationName)
+		summaryQuery = fmt.Sprintf(`request.graphql.operationType == "%s" and request.graphql.operationName == "%s"`, operationType, operationName)
+		return
+	}
+
+	// An anonymous operation is told apart by its fields
+	var fields []string
+	for _, field := range graphQL["fields"].([]interface{}) {
+		fields = append(fields, field.(string))
+	}
+	summary = fmt.Sprintf("%s { %s }", operationType, strings.Join(fields, ", "))
+	summaryQuery = fmt.Sprintf(`request.graphql.operationType == "%s" and request.path == "%s"`, operationType, path)
+	return
+}
+
+func representGraphQLRequest(graphQL map[string]interface{}) (sections []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Operation Type",
+			Value:    graphQL["operationType"],
+			Selector: `request.graphql.operationType`,
+		},
+		{
+			Name:     "Operation Name",
+			Value:    graphQL["operationName"],
+			Selector: `request.graphql.operationName`,
+		},
+		{
+			Name:     "Operations in Document",
+			Value:    graphQL["operationCount"],
+			Selector: `request.graphql.operationCount`,
+		},
+	})
+	sections = append(sections, api.SectionData{
+		Type:  api.TABLE,
+		Title: "GraphQL",
+		Data:  string(details),
+	})
+
+	if fields, ok := graphQL["fields"].([]interface{}); ok && len(fields) > 0 {
+		sections = append(sections, api.SectionData{
+			Type:  api.TABLE,
+			Title: "GraphQL Fields",
+			Data:  representSliceAsTable(fields, `request.graphql.fields`),
+		})
+	}
+
+	if variables, ok := graphQL["variables"].(map[string]interface{}); ok && len(variables) > 0 {
+		data, _ := json.MarshalIndent(variables, "", "  ")
+		sections = append(sections, api.SectionData{
+			Type:     api.BODY,
+			Title:    "GraphQL Variables",
+			MimeType: "application/json",
+			Data:     string(data),
+			Selector: `request.graphql.variables`,
+		})
+	}
+
+	return
+}
+
+func representGraphQLResponse(graphQL map[string]interface{}) (sections []interface{}) {
+	errors, _ := graphQL["errors"].([]interface{})
+	if len(errors) == 0 {
+		return
+	}
+
+	var table []api.TableData
+	for i, graphQLError := range errors {
+		message := graphQLError
+		if graphQLError, ok := graphQLError.(map[string]interface{}); ok {
+			message = graphQLError["message"]
+		}
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("[%d]", i),
+			Value:    message,
+			Selector: fmt.Sprintf(`response.graphql.errors[%d].message`, i),
+		})
+	}
+	data, _ := json.Marshal(table)
+	sections = append(sections, api.SectionData{
+		Type:  api.TABLE,
+		Title: "GraphQL Errors",
+		Data:  string(data),
+	})
+	return
 }
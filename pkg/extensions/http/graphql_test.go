@@ -0,0 +1,98 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func graphQLExchange(t *testing.T, request map[string]interface{}, response string) *api.Entry {
+	body, err := json.Marshal(request)
+	assert.Nil(t, err)
+	client := fmt.Sprintf("POST /graphql HTTP/1.1\r\nHost: api.example.com\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+	server := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(response), response)
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	assert.Len(t, entries, 1)
+	return entries[0]
+}
+
+func TestGraphQLNamedOperation(t *testing.T) {
+	entry := graphQLExchange(t, map[string]interface{}{
+		"query": `
+			fragment UserFields on Query { viewer { id } }
+			query ListUsers { users { id } }
+			query GetUser($id: ID!) {
+				user(id: $id) { name }
+				...UserFields
+				... on Query { settings { theme } }
+			}`,
+		"operationName": "GetUser",
+		"variables":     map[string]interface{}{"id": "42"},
+	}, `{"data":{"user":null},"errors":[{"message":"user not found","path":["user"]}]}`)
+
+	assert.Equal(t, graphQL1Protocol.Abbreviation, entry.Protocol.Abbreviation)
+	graphQL := entry.Request["graphql"].(map[string]interface{})
+	assert.Equal(t, "query", graphQL["operationType"])
+	assert.Equal(t, "GetUser", graphQL["operationName"])
+	assert.Equal(t, []interface{}{"user", "viewer", "settings"}, graphQL["fields"])
+	assert.Equal(t, map[string]interface{}{"id": "42"}, graphQL["variables"])
+	assert.Equal(t, 2, graphQL["operationCount"])
+
+	errors := entry.Response["graphql"].(map[string]interface{})["errors"].([]interface{})
+	assert.Len(t, errors, 1)
+	assert.Equal(t, "user not found", errors[0].(map[string]interface{})["message"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, "query GetUser", summary.Summary)
+	assert.Equal(t, `request.graphql.operationType == "query" and request.graphql.operationName == "GetUser"`, summary.SummaryQuery)
+	assert.Equal(t, "POST", summary.Method)
+}
+
+func TestGraphQLAnonymousOperation(t *testing.T) {
+	entry := graphQLExchange(t, map[string]interface{}{
+		"query": `{ viewer { id } repository(name: "worker") { stars } }`,
+	}, `{"data":{"viewer":{"id":"1"}}}`)
+
+	graphQL := entry.Request["graphql"].(map[string]interface{})
+	assert.Equal(t, "", graphQL["operationName"])
+	assert.Equal(t, map[string]interface{}{}, graphQL["variables"])
+	assert.Empty(t, entry.Response["graphql"].(map[string]interface{})["errors"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, "query { viewer, repository }", summary.Summary)
+	assert.Equal(t, `request.graphql.operationType == "query" and request.path == "/graphql"`, summary.SummaryQuery)
+}
+
+func TestGraphQLGet(t *testing.T) {
+	query := url.Values{
+		"query":     []string{"mutation Like($id: ID!) { like(id: $id) { count } }"},
+		"variables": []string{`{"id":"7"}`},
+	}
+	client := fmt.Sprintf("GET /graphql?%s HTTP/1.1\r\nHost: api.example.com\r\n\r\n", query.Encode())
+	server := "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\n{}"
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	assert.Len(t, entries, 1)
+	graphQL := entries[0].Request["graphql"].(map[string]interface{})
+	assert.Equal(t, "mutation", graphQL["operationType"])
+	assert.Equal(t, "Like", graphQL["operationName"])
+	assert.Equal(t, map[string]interface{}{"id": "7"}, graphQL["variables"])
+	assert.Equal(t, "mutation Like", NewDissector().Summarize(entries[0]).Summary)
+}
+
+func TestGraphQLNotGraphQL(t *testing.T) {
+	entry := graphQLExchange(t, map[string]interface{}{"query": "status:open"}, `{}`)
+	assert.Equal(t, http11protocol.Abbreviation, entry.Protocol.Abbreviation)
+	assert.Nil(t, entry.Request["graphql"])
+	assert.Equal(t, "/graphql", NewDissector().Summarize(entry).Summary)
+
+	client := "GET /search?query=hello HTTP/1.1\r\nHost: api.example.com\r\n\r\n"
+	entries := dissectHTTPEntries(t, []byte(client), []byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	assert.Len(t, entries, 1)
+	assert.Nil(t, entries[0].Request["graphql"])
+}
@@ -0,0 +1,613 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// The well-known types that the uploaded descriptors may import
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const grpcMessageHeaderLen = 5
+
+// The nesting limit of the schemaless decoding
+const grpcMaxDepth = 32
+
+var grpcReflectionMethods = map[string]bool{
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      true,
+}
+
+var grpcReflectionLearning = true
+
+// SetGrpcReflectionLearning sets whether the descriptors found in the
+// captured responses of the gRPC server reflection service are learned.
+func SetGrpcReflectionLearning(enabled bool) {
+	grpcReflectionLearning = enabled
+}
+
+// GrpcMessage is one of the length-prefixed messages in the body of a gRPC
+// request or response. Data is the message as JSON if its type is known,
+// otherwise the fields are given by their numbers as decoded from the wire.
+type GrpcMessage struct {
+	Compressed bool        `json:"compressed"`
+	Size       int         `json:"size"`
+	Type       string      `json:"type,omitempty"`
+	Schemaless bool        `json:"schemaless"`
+	Data       interface{} `json:"data"`
+	Error      string      `json:"error,omitempty"`
+
+	raw []byte
+}
+
+// GrpcBody is the gRPC part of a request or a response. The messages of a
+// streaming side are left out since they are emitted on their own.
+type GrpcBody struct {
+	Protocol      string         `json:"protocol,omitempty"`
+	Service       string         `json:"service,omitempty"`
+	Method        string         `json:"method,omitempty"`
+	Codec         string         `json:"codec,omitempty"`
+	Encoding      string         `json:"encoding,omitempty"`
+	Messages      []*GrpcMessage `json:"messages"`
+	Streamed      bool           `json:"streamed"`
+	MessageCount  int            `json:"messageCount"`
+	Status        *int           `json:"status,omitempty"`
+	StatusMessage string         `json:"message,omitempty"`
+}
+
+func (b *GrpcBody) variant() string {
+	if b == nil {
+		return grpcVariantGrpc
+	}
+	return b.Protocol
+}
+
+func decompressGrpcMessage(payload []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		decompressed, err := io.ReadAll(io.LimitReader(reader, int64(maxHTTP2DataLen)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(decompressed) > maxHTTP2DataLen {
+			return nil, errors.New("decompressed message is too large")
+		}
+		return decompressed, nil
+	case "", "identity":
+		return nil, errors.New("compressed message without an encoding")
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// prepareGrpcPair labels a pair of gRPC, gRPC-Web or Connect with its
+// protocol and decodes its messages. It returns false for any other pair.
+func prepareGrpcPair(item *api.OutputChannelItem, streamID uint32, reqResMatcher *requestResponseMatcher) bool {
+	request, ok := item.Pair.Request.Payload.(HTTPPayload)
+	if !ok {
+		return false
+	}
+	response, ok := item.Pair.Response.Payload.(HTTPPayload)
+	if !ok {
+		return false
+	}
+
+	if request.Grpc == nil && response.Grpc == nil {
+		if !prepareConnectUnary(&request, &response) {
+			return false
+		}
+		item.Pair.Request.Payload = request
+		item.Pair.Response.Payload = response
+	}
+
+	// The response of a trailers-only call has no content type
+	variant := response.Grpc.variant()
+	if request.Grpc != nil {
+		variant = request.Grpc.Protocol
+	}
+	version := http11protocol.Version
+	if request.Data.(*http.Request).ProtoMajor == protoMajorHTTP2 {
+		version = http2Protocol.Version
+	}
+	item.Protocol = grpcProtocolOf(variant, version)
+
+	decodeGrpcPair(item)
+	// Both sides of the stream have ended
+	reqResMatcher.deleteGrpcPath(grpcStreamIdent(item.ConnectionInfo, streamID))
+	return true
+}
+
+// decodeGrpcPair decodes the messages of a request and its response with the
+// types of the method that was called. The messages are decoded only once
+// the pair is matched, since the path isn't known to the server side.
+func decodeGrpcPair(item *api.OutputChannelItem) {
+	request, ok := item.Pair.Request.Payload.(HTTPPayload)
+	if !ok {
+		return
+	}
+	response, ok := item.Pair.Response.Payload.(HTTPPayload)
+	if !ok {
+		return
+	}
+
+	path := grpcRequestURL(request.Data.(*http.Request)).Path
+	if grpcReflectionLearning && grpcReflectionMethods[path] && response.Grpc != nil {
+		learnGrpcReflection(response.Grpc.Messages)
+	}
+
+	service, method := splitGrpcPath(path)
+	var input, output protoreflect.MessageDescriptor
+	if descriptor := grpcDescriptors.findMethod(service, method); descriptor != nil {
+		input = descriptor.Input()
+		output = descriptor.Output()
+	}
+
+	if request.Grpc != nil {
+		request.Grpc.Service = service
+		request.Grpc.Method = method
+		decodeGrpcMessages(request.Grpc.Messages, input)
+	}
+	if response.Grpc != nil {
+		response.Grpc.Service = service
+		response.Grpc.Method = method
+		decodeGrpcMessages(response.Grpc.Messages, output)
+	}
+}
+
+func splitGrpcPath(path string) (service string, method string) {
+	path = strings.TrimPrefix(path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return path, ""
+	}
+	return path[:i], path[i+1:]
+}
+
+func decodeGrpcMessages(messages []*GrpcMessage, descriptor protoreflect.MessageDescriptor) {
+	for _, message := range messages {
+		if message.raw == nil {
+			continue
+		}
+
+		if descriptor != nil {
+			data, err := decodeGrpcMessage(message.raw, descriptor)
+			if err == nil {
+				message.Type = string(descriptor.FullName())
+				message.Data = data
+				continue
+			}
+			// The uploaded descriptor may be outdated
+			message.Error = err.Error()
+		}
+
+		data, err := decodeSchemaless(message.raw, 0)
+		if err != nil {
+			message.Error = err.Error()
+			message.Data = base64.StdEncoding.EncodeToString(message.raw)
+			continue
+		}
+		message.Schemaless = true
+		message.Data = data
+	}
+}
+
+func decodeGrpcMessage(raw []byte, descriptor protoreflect.MessageDescriptor) (data interface{}, err error) {
+	message := dynamicpb.NewMessage(descriptor)
+	if err = proto.Unmarshal(raw, message); err != nil {
+		return
+	}
+
+	marshaled, err := protojson.MarshalOptions{Resolver: grpcDescriptors}.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(marshaled, &data)
+	return
+}
+
+// decodeSchemaless decodes the wire format of a message whose type isn't
+// known. The fields are keyed by their numbers and the repeated ones are
+// collected into lists. Since the wire format doesn't tell strings, bytes
+// and nested messages apart, printable bytes are taken as a string, then a
+// nested message is tried, and anything else is given in base64.
+func decodeSchemaless(b []byte, depth int) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var value interface{}
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			value = v
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			value = v
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(b)
+			value = v
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			value = decodeSchemalessBytes(v, depth)
+		case protowire.StartGroupType:
+			var v []byte
+			v, n = protowire.ConsumeGroup(num, b)
+			if n >= 0 {
+				if depth >= grpcMaxDepth {
+					return nil, errors.New("message is nested too deep")
+				}
+				var err error
+				value, err = decodeSchemaless(v, depth+1)
+				if err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unexpected wire type: %d", typ)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		key := strconv.Itoa(int(num))
+		if existing, ok := fields[key]; ok {
+			if list, ok := existing.([]interface{}); ok {
+				fields[key] = append(list, value)
+			} else {
+				fields[key] = []interface{}{existing, value}
+			}
+		} else {
+			fields[key] = value
+		}
+	}
+
+	return fields, nil
+}
+
+func decodeSchemalessBytes(b []byte, depth int) interface{} {
+	if isPrintable(b) {
+		return string(b)
+	}
+	if depth < grpcMaxDepth {
+		if nested, err := decodeSchemaless(b, depth+1); err == nil {
+			return nested
+		}
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// learnGrpcReflection adds the files found in the responses of the server
+// reflection service. A ServerReflectionResponse holds them in the
+// file_descriptor_response field, as serialized FileDescriptorProtos.
+func learnGrpcReflection(messages []*GrpcMessage) {
+	var fds []*descriptorpb.FileDescriptorProto
+	for _, message := range messages {
+		for _, fileDescriptorResponse := range consumeBytesFields(message.raw, 4) {
+			for _, file := range consumeBytesFields(fileDescriptorResponse, 1) {
+				fd := &descriptorpb.FileDescriptorProto{}
+				if err := proto.Unmarshal(file, fd); err != nil {
+					log.Debug().Err(err).Msg("Failed unmarshalling a learned gRPC file descriptor:")
+					continue
+				}
+				fds = append(fds, fd)
+			}
+		}
+	}
+
+	if len(fds) > 0 {
+		grpcDescriptors.add(fds)
+	}
+}
+
+// consumeBytesFields returns the values of a length-delimited field.
+func consumeBytesFields(b []byte, field protowire.Number) (values [][]byte) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return
+		}
+		if num == field && typ == protowire.BytesType {
+			value, _ := protowire.ConsumeBytes(b)
+			values = append(values, value)
+		}
+		b = b[n:]
+	}
+	return
+}
+
+// grpcRegistry holds the file descriptors that the gRPC messages are decoded
+// with. The files may come in any order, so the registry is rebuilt with
+// every addition and a file is left out until all its imports are known.
+type grpcRegistry struct {
+	sync.RWMutex
+	protos map[string]*descriptorpb.FileDescriptorProto
+	files  *protoregistry.Files
+}
+
+var grpcDescriptors = &grpcRegistry{
+	protos: make(map[string]*descriptorpb.FileDescriptorProto),
+	files:  &protoregistry.Files{},
+}
+
+// LoadGrpcDescriptorSet adds the files of a serialized FileDescriptorSet, as
+// produced by protoc with --descriptor_set_out and --include_imports, and
+// returns the gRPC services that are known afterwards.
+func LoadGrpcDescriptorSet(data []byte) ([]string, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, err
+	}
+	if len(set.File) == 0 {
+		return nil, errors.New("descriptor set has no files")
+	}
+
+	grpcDescriptors.add(set.File)
+	return GrpcServices(), nil
+}
+
+// GrpcServices returns the full names of the gRPC services that the
+// messages can be decoded for.
+func GrpcServices() []string {
+	return grpcDescriptors.services()
+}
+
+func (r *grpcRegistry) add(fds []*descriptorpb.FileDescriptorProto) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, fd := range fds {
+		r.protos[fd.GetName()] = fd
+	}
+
+	names := make([]string, 0, len(r.protos))
+	for name := range r.protos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := &protoregistry.Files{}
+	resolver := &grpcFileResolver{files: files}
+	for progress := true; progress; {
+		progress = false
+		pending := names[:0]
+		for _, name := range names {
+			fd := r.protos[name]
+			if !resolver.hasDependencies(fd) {
+				pending = append(pending, name)
+				continue
+			}
+			progress = true
+
+			file, err := protodesc.NewFile(fd, resolver)
+			if err == nil {
+				err = files.RegisterFile(file)
+			}
+			if err != nil {
+				log.Debug().Err(err).Str("file", name).Msg("Failed registering a gRPC file descriptor:")
+			}
+		}
+		names = pending
+	}
+	for _, name := range names {
+		log.Debug().Str("file", name).Msg("Missing the imports of a gRPC file descriptor.")
+	}
+
+	r.files = files
+}
+
+func (r *grpcRegistry) findMethod(service string, method string) protoreflect.MethodDescriptor {
+	r.RLock()
+	files := r.files
+	r.RUnlock()
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return serviceDescriptor.Methods().ByName(protoreflect.Name(method))
+}
+
+func (r *grpcRegistry) services() []string {
+	r.RLock()
+	files := r.files
+	r.RUnlock()
+
+	services := make([]string, 0)
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		for i := 0; i < file.Services().Len(); i++ {
+			services = append(services, string(file.Services().Get(i).FullName()))
+		}
+		return true
+	})
+	sort.Strings(services)
+	return services
+}
+
+// FindMessageByName resolves the types of the google.protobuf.Any fields
+// for protojson.
+func (r *grpcRegistry) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	r.RLock()
+	files := r.files
+	r.RUnlock()
+
+	descriptor, err := files.FindDescriptorByName(name)
+	if err != nil {
+		return protoregistry.GlobalTypes.FindMessageByName(name)
+	}
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, protoregistry.NotFound
+	}
+	return dynamicpb.NewMessageType(messageDescriptor), nil
+}
+
+func (r *grpcRegistry) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		name = url[i+1:]
+	}
+	return r.FindMessageByName(protoreflect.FullName(name))
+}
+
+func (r *grpcRegistry) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (r *grpcRegistry) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// grpcFileResolver resolves the imports of a file from the files registered
+// so far, or from the well-known types that are linked into the worker.
+type grpcFileResolver struct {
+	files *protoregistry.Files
+}
+
+func (r *grpcFileResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *grpcFileResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if descriptor, err := r.files.FindDescriptorByName(name); err == nil {
+		return descriptor, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+func (r *grpcFileResolver) hasDependencies(fd *descriptorpb.FileDescriptorProto) bool {
+	for _, dependency := range fd.GetDependency() {
+		if _, err := r.FindFileByPath(dependency); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func representGrpcBody(grpc map[string]interface{}, selector string) (sections []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Service",
+			Value:    grpc["service"],
+			Selector: fmt.Sprintf("%s.service", selector),
+		},
+		{
+			Name:     "Method",
+			Value:    grpc["method"],
+			Selector: fmt.Sprintf("%s.method", selector),
+		},
+		{
+			Name:     "Protocol",
+			Value:    grpc["protocol"],
+			Selector: fmt.Sprintf("%s.protocol", selector),
+		},
+		{
+			Name:     "Codec",
+			Value:    grpc["codec"],
+			Selector: fmt.Sprintf("%s.codec", selector),
+		},
+		{
+			Name:     "Encoding",
+			Value:    grpc["encoding"],
+			Selector: fmt.Sprintf("%s.encoding", selector),
+		},
+		{
+			Name:     "Status",
+			Value:    grpc["status"],
+			Selector: fmt.Sprintf("%s.status", selector),
+		},
+		{
+			Name:     "Status Message",
+			Value:    grpc["message"],
+			Selector: fmt.Sprintf("%s.message", selector),
+		},
+	})
+	sections = append(sections, api.SectionData{
+		Type:  api.TABLE,
+		Title: "gRPC",
+		Data:  string(details),
+	})
+
+	messages, _ := grpc["messages"].([]interface{})
+	if len(messages) > 0 {
+		data, _ := json.MarshalIndent(messages, "", "  ")
+		sections = append(sections, api.SectionData{
+			Type:     api.BODY,
+			Title:    "gRPC Messages",
+			MimeType: "application/json",
+			Data:     string(data),
+			Selector: fmt.Sprintf("%s.messages", selector),
+		})
+	}
+
+	return
+}
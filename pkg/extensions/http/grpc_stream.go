@@ -0,0 +1,419 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	grpcDirectionClient = "client"
+	grpcDirectionServer = "server"
+)
+
+// GrpcStreamMessage is a message of a streaming RPC, emitted as soon as it's
+// read instead of waiting for the end of the stream.
+type GrpcStreamMessage struct {
+	*GrpcMessage
+	Path      string `json:"path"`
+	Service   string `json:"service"`
+	Method    string `json:"method"`
+	StreamID  uint32 `json:"streamId"`
+	Seq       int    `json:"seq"`
+	Direction string `json:"direction"`
+}
+
+// grpcStream reads the messages of one side of a gRPC call as the DATA
+// frames come. The messages are held until the side turns out to be
+// streaming, either by the method descriptor or by a second message, so a
+// unary call is still emitted as a single pair. Once streaming, each message
+// is given its own entry and the final pair only carries the headers and
+// the trailers.
+//
+// The same goes for gRPC-Web and the streams of Connect, whose messages are
+// followed by a trailer frame or an end-stream message in place of the
+// trailers of HTTP/2. Their bodies are read whole over HTTP/1.
+type grpcStream struct {
+	direction string
+	variant   string
+	codec     string
+	version   string
+	encoding  string
+	path      string
+	streaming bool
+	ended     bool
+
+	buffer  []byte
+	text    []byte
+	invalid bool
+	skip    int
+	pending []*GrpcMessage
+	seq     int
+
+	status        *int
+	statusMessage string
+	trailers      http.Header
+}
+
+// isGrpcHeaders checks for the content type of gRPC and its variants, or a
+// trailers-only response.
+func isGrpcHeaders(header http.Header) bool {
+	if _, _, ok := parseGrpcContentType(header.Get("Content-Type")); ok {
+		return true
+	}
+	return header.Get("Grpc-Status") != ""
+}
+
+func newGrpcStream(direction string, path string, version string, header http.Header) *grpcStream {
+	stream := &grpcStream{
+		direction: direction,
+		variant:   grpcVariantGrpc,
+		codec:     grpcCodecProto,
+		version:   version,
+		encoding:  header.Get("Grpc-Encoding"),
+		trailers:  make(http.Header),
+	}
+	if variant, codec, ok := parseGrpcContentType(header.Get("Content-Type")); ok {
+		stream.variant = variant
+		stream.codec = codec
+	}
+	if stream.variant == grpcVariantConnect {
+		stream.encoding = header.Get("Connect-Content-Encoding")
+	}
+	if path != "" {
+		stream.setPath(path)
+	}
+	return stream
+}
+
+func (s *grpcStream) protocol() api.Protocol {
+	return grpcProtocolOf(s.variant, s.version)
+}
+
+// setPath sets the method that was called, which the server side learns
+// from the client side of the connection.
+func (s *grpcStream) setPath(path string) {
+	s.path = path
+	if descriptor := grpcDescriptors.findMethod(splitGrpcPath(path)); descriptor != nil {
+		if s.direction == grpcDirectionClient {
+			s.streaming = s.streaming || descriptor.IsStreamingClient()
+		} else {
+			s.streaming = s.streaming || descriptor.IsStreamingServer()
+		}
+	}
+}
+
+// feed splits the complete messages out of the data read so far. A message
+// that's larger than maxHTTP2DataLen is skipped and noted with an error.
+func (s *grpcStream) feed(data []byte) {
+	if s.invalid {
+		return
+	}
+	if s.variant == grpcVariantGrpcWebText {
+		decoded, err := s.decodeGrpcWebText(data)
+		if err != nil {
+			// The messages that follow can't be told apart anymore
+			s.pending = append(s.pending, &GrpcMessage{Error: "invalid base64 text: " + err.Error()})
+			s.invalid = true
+			return
+		}
+		data = decoded
+	}
+
+	for len(data) > 0 {
+		if s.skip > 0 {
+			n := s.skip
+			if n > len(data) {
+				n = len(data)
+			}
+			s.skip -= n
+			data = data[n:]
+			continue
+		}
+
+		s.buffer = append(s.buffer, data...)
+		data = nil
+		for len(s.buffer) >= grpcMessageHeaderLen {
+			flags := s.buffer[0]
+			message := &GrpcMessage{
+				Compressed: flags&0x01 != 0,
+				Size:       int(binary.BigEndian.Uint32(s.buffer[1:grpcMessageHeaderLen])),
+			}
+			if message.Size > maxHTTP2DataLen {
+				message.Error = "message is too large"
+				s.pending = append(s.pending, message)
+				// Whatever follows the header in the buffer belongs to the skipped message
+				data = s.buffer[grpcMessageHeaderLen:]
+				s.skip = message.Size
+				s.buffer = nil
+				break
+			}
+			if len(s.buffer) < grpcMessageHeaderLen+message.Size {
+				break
+			}
+
+			payload := s.buffer[grpcMessageHeaderLen : grpcMessageHeaderLen+message.Size]
+			s.buffer = s.buffer[grpcMessageHeaderLen+message.Size:]
+			if message.Compressed {
+				decompressed, err := decompressGrpcMessage(payload, s.encoding)
+				if err != nil {
+					message.Error = err.Error()
+					message.Data = base64.StdEncoding.EncodeToString(payload)
+					s.pending = append(s.pending, message)
+					continue
+				}
+				payload = decompressed
+			} else {
+				payload = append([]byte{}, payload...)
+			}
+
+			switch {
+			case flags&grpcWebTrailerFlag != 0 && s.variant != grpcVariantGrpc && s.variant != grpcVariantConnect:
+				s.parseGrpcWebTrailers(payload)
+				continue
+			case flags&connectEndStreamFlag != 0 && s.variant == grpcVariantConnect:
+				s.parseConnectEndStream(payload)
+				continue
+			}
+			setGrpcPayload(message, payload, s.codec)
+			s.pending = append(s.pending, message)
+		}
+	}
+}
+
+// finish ends the stream with the headers of its side, which include the
+// trailers of a response. The trailers read from the body are added to them.
+func (s *grpcStream) finish(headers http.Header) {
+	s.ended = true
+	for name, values := range s.trailers {
+		for _, value := range values {
+			headers.Add(name, value)
+		}
+	}
+
+	if len(s.buffer) > 0 {
+		message := &GrpcMessage{Error: "truncated message"}
+		if len(s.buffer) >= grpcMessageHeaderLen {
+			message.Compressed = s.buffer[0]&0x01 != 0
+			message.Size = int(binary.BigEndian.Uint32(s.buffer[1:grpcMessageHeaderLen]))
+			message.Data = base64.StdEncoding.EncodeToString(s.buffer[grpcMessageHeaderLen:])
+		}
+		s.pending = append(s.pending, message)
+		s.buffer = nil
+	}
+
+	if value := headers.Get("Grpc-Status"); value != "" {
+		if status, err := strconv.Atoi(value); err == nil {
+			s.status = &status
+		}
+	}
+	// The message is percent-encoded
+	if value := headers.Get("Grpc-Message"); value != "" {
+		s.statusMessage = value
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			s.statusMessage = unescaped
+		}
+	}
+}
+
+// drain returns the messages that are to be emitted on their own.
+func (s *grpcStream) drain(streamID uint32) (messages []*GrpcStreamMessage) {
+	if len(s.pending) > 1 {
+		s.streaming = true
+	}
+	if !s.streaming {
+		return
+	}
+
+	if grpcReflectionLearning && grpcReflectionMethods[s.path] && s.direction == grpcDirectionServer {
+		learnGrpcReflection(s.pending)
+	}
+
+	service, method := splitGrpcPath(s.path)
+	var descriptor protoreflect.MessageDescriptor
+	if methodDescriptor := grpcDescriptors.findMethod(service, method); methodDescriptor != nil {
+		if s.direction == grpcDirectionClient {
+			descriptor = methodDescriptor.Input()
+		} else {
+			descriptor = methodDescriptor.Output()
+		}
+	}
+	decodeGrpcMessages(s.pending, descriptor)
+
+	for _, message := range s.pending {
+		messages = append(messages, &GrpcStreamMessage{
+			GrpcMessage: message,
+			Path:        s.path,
+			Service:     service,
+			Method:      method,
+			StreamID:    streamID,
+			Seq:         s.seq,
+			Direction:   s.direction,
+		})
+		s.seq++
+	}
+	s.pending = nil
+	return
+}
+
+// body returns the gRPC part of the final pair, which holds the messages
+// unless they were already emitted on their own.
+func (s *grpcStream) body() *GrpcBody {
+	body := &GrpcBody{
+		Protocol:      s.variant,
+		Codec:         s.codec,
+		Encoding:      s.encoding,
+		Messages:      s.pending,
+		Streamed:      s.streaming,
+		MessageCount:  s.seq + len(s.pending),
+		Status:        s.status,
+		StatusMessage: s.statusMessage,
+	}
+	if body.Messages == nil {
+		body.Messages = make([]*GrpcMessage, 0)
+	}
+	return body
+}
+
+func handleGrpcStream(stream *grpcStream, streamID uint32, progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) {
+	connectionInfo := grpcConnectionInfo(tcpID, stream.direction == grpcDirectionClient)
+	ident := grpcStreamIdent(connectionInfo, streamID)
+	if stream.direction == grpcDirectionClient {
+		reqResMatcher.registerGrpcPath(ident, stream.path)
+	} else if stream.path == "" {
+		if path := reqResMatcher.getGrpcPath(ident); path != "" {
+			stream.setPath(path)
+		}
+	}
+
+	for _, message := range stream.drain(streamID) {
+		item := reqResMatcher.prepareGrpcStreamMessage(message, stream.protocol(), captureTime, progress.Current())
+		item.ConnectionInfo = connectionInfo
+		emitter.Emit(item)
+	}
+}
+
+// handleGrpcBody reads the messages of an HTTP/1 body, which is read whole,
+// as if its side of a stream ended at once. The requests and the responses
+// are numbered by the counter of the connection in place of a stream ID.
+func handleGrpcBody(direction string, path string, header http.Header, body []byte, counter uint, progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) *GrpcBody {
+	stream := newGrpcStream(direction, path, http11protocol.Version, header)
+	stream.feed(body)
+	stream.finish(header)
+	handleGrpcStream(stream, uint32(counter), progress, tcpID, captureTime, emitter, reqResMatcher)
+	return stream.body()
+}
+
+func grpcConnectionInfo(tcpID *api.TcpID, isClient bool) *api.ConnectionInfo {
+	if isClient {
+		return &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+	}
+	return &api.ConnectionInfo{
+		ClientIP:   tcpID.DstIP,
+		ClientPort: tcpID.DstPort,
+		ServerIP:   tcpID.SrcIP,
+		ServerPort: tcpID.SrcPort,
+		IsOutgoing: false,
+	}
+}
+
+func grpcStreamIdent(connectionInfo *api.ConnectionInfo, streamID uint32) string {
+	return fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		connectionInfo.ClientIP,
+		connectionInfo.ServerIP,
+		connectionInfo.ClientPort,
+		connectionInfo.ServerPort,
+		streamID,
+	)
+}
+
+func isGrpcStreamMessage(request map[string]interface{}) bool {
+	_, ok := request["seq"]
+	return ok
+}
+
+func summarizeGrpcStreamMessage(entry *api.Entry) (summary string, summaryQuery string, method string, methodQuery string) {
+	summary = entry.Request["path"].(string)
+	summaryQuery = fmt.Sprintf(`request.path == "%s"`, summary)
+	method = entry.Request["direction"].(string)
+	methodQuery = fmt.Sprintf(`request.direction == "%s"`, method)
+	return
+}
+
+func representGrpcStreamMessage(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Path",
+			Value:    request["path"].(string),
+			Selector: `request.path`,
+		},
+		{
+			Name:     "Stream ID",
+			Value:    request["streamId"].(float64),
+			Selector: `request.streamId`,
+		},
+		{
+			Name:     "Sequence",
+			Value:    request["seq"].(float64),
+			Selector: `request.seq`,
+		},
+		{
+			Name:     "Direction",
+			Value:    request["direction"].(string),
+			Selector: `request.direction`,
+		},
+		{
+			Name:     "Type",
+			Value:    request["type"],
+			Selector: `request.type`,
+		},
+		{
+			Name:     "Compressed",
+			Value:    request["compressed"],
+			Selector: `request.compressed`,
+		},
+		{
+			Name:     "Size (bytes)",
+			Value:    request["size"],
+			Selector: `request.size`,
+		},
+		{
+			Name:     "Error",
+			Value:    request["error"],
+			Selector: `request.error`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if request["data"] != nil {
+		data, _ := json.MarshalIndent(request["data"], "", "  ")
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Message",
+			MimeType: "application/json",
+			Data:     string(data),
+			Selector: `request.data`,
+		})
+	}
+
+	return
+}
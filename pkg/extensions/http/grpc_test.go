@@ -0,0 +1,416 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// greeterFile describes a service of the given package with a unary method
+// whose request has a name and whose reply has a message and a count.
+func greeterFile(pkg string) *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(pkg + "/greeter.proto"),
+		Package: proto.String(pkg),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name:  proto.String("HelloRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+			},
+			{
+				Name: proto.String("HelloReply"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{
+						Name:       proto.String("SayHello"),
+						InputType:  proto.String("." + pkg + ".HelloRequest"),
+						OutputType: proto.String("." + pkg + ".HelloReply"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func grpcFrame(t *testing.T, message []byte, gzipped bool) []byte {
+	flag := byte(0)
+	if gzipped {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		_, err := writer.Write(message)
+		assert.Nil(t, err)
+		assert.Nil(t, writer.Close())
+		message = compressed.Bytes()
+		flag = 1
+	}
+
+	frame := make([]byte, grpcMessageHeaderLen, grpcMessageHeaderLen+len(message))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// grpcCall returns both sides of an HTTP/2 connection with a single call.
+// Each of the chunks of the response is written in its own DATA frame and
+// the trailers follow them.
+func grpcCall(t *testing.T, path string, request []byte, encoding string, response [][]byte, trailers ...string) (client []byte, server []byte) {
+	headerBlock := func(fields ...string) []byte {
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		for i := 0; i < len(fields); i += 2 {
+			assert.Nil(t, encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+		}
+		return block.Bytes()
+	}
+
+	var clientBuffer bytes.Buffer
+	clientBuffer.WriteString(http2.ClientPreface)
+	framer := http2.NewFramer(&clientBuffer, nil)
+	assert.Nil(t, framer.WriteSettings())
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(":method", "POST", ":scheme", "http", ":path", path, ":authority", "greeter:50051", "content-type", "application/grpc", "grpc-encoding", encoding),
+		EndHeaders:    true,
+	}))
+	assert.Nil(t, framer.WriteData(1, true, request))
+
+	var serverBuffer bytes.Buffer
+	framer = http2.NewFramer(&serverBuffer, nil)
+	assert.Nil(t, framer.WriteSettings())
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(":status", "200", "content-type", "application/grpc", "grpc-encoding", encoding),
+		EndHeaders:    true,
+	}))
+	for _, chunk := range response {
+		assert.Nil(t, framer.WriteData(1, false, chunk))
+	}
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(trailers...),
+		EndHeaders:    true,
+		EndStream:     true,
+	}))
+
+	return clientBuffer.Bytes(), serverBuffer.Bytes()
+}
+
+// dissectGrpcEntries returns the gRPC entries of a connection as the worker
+// would see them, after a round trip through JSON.
+func dissectGrpcEntries(t *testing.T, client []byte, server []byte) (entries []*api.Entry) {
+	for _, item := range dissectConnection(t, client, server) {
+		assert.Equal(t, grpcProtocol.Abbreviation, item.Protocol.Abbreviation)
+
+		marshaled, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var unmarshaled *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(marshaled, &unmarshaled))
+
+		entries = append(entries, NewDissector().Analyze(unmarshaled, &api.Resolution{}, &api.Resolution{}))
+	}
+	return
+}
+
+func dissectGrpc(t *testing.T, client []byte, server []byte) *api.Entry {
+	entries := dissectGrpcEntries(t, client, server)
+	if !assert.Len(t, entries, 1) {
+		t.FailNow()
+	}
+	return entries[0]
+}
+
+func grpcMessages(t *testing.T, details map[string]interface{}) []interface{} {
+	grpc, ok := details["grpc"].(map[string]interface{})
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	return grpc["messages"].([]interface{})
+}
+
+func TestDissectGrpcWithDescriptors(t *testing.T) {
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{greeterFile("uploaded")}})
+	assert.Nil(t, err)
+	services, err := LoadGrpcDescriptorSet(set)
+	assert.Nil(t, err)
+	assert.Contains(t, services, "uploaded.Greeter")
+
+	request := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "Kubeshark")
+	response := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "Hello Kubeshark")
+	response = protowire.AppendVarint(protowire.AppendTag(response, 2, protowire.VarintType), 3)
+
+	client, server := grpcCall(t, "/uploaded.Greeter/SayHello", grpcFrame(t, request, true), "gzip", [][]byte{grpcFrame(t, response, false)}, "grpc-status", "0")
+	entry := dissectGrpc(t, client, server)
+
+	assert.Equal(t, "uploaded.Greeter", entry.Request["grpc"].(map[string]interface{})["service"])
+	assert.Equal(t, "SayHello", entry.Request["grpc"].(map[string]interface{})["method"])
+
+	requestMessages := grpcMessages(t, entry.Request)
+	assert.Len(t, requestMessages, 1)
+	assert.Equal(t, map[string]interface{}{
+		"compressed": true,
+		"size":       float64(len(grpcFrame(t, request, true)) - grpcMessageHeaderLen),
+		"type":       "uploaded.HelloRequest",
+		"schemaless": false,
+		"data":       map[string]interface{}{"name": "Kubeshark"},
+	}, requestMessages[0])
+
+	responseMessages := grpcMessages(t, entry.Response)
+	assert.Len(t, responseMessages, 1)
+	assert.Equal(t, "uploaded.HelloReply", responseMessages[0].(map[string]interface{})["type"])
+	assert.Equal(t, map[string]interface{}{"message": "Hello Kubeshark", "count": float64(3)}, responseMessages[0].(map[string]interface{})["data"])
+
+	// The entries are represented as they're read back from the database
+	marshaled, err := json.Marshal(entry)
+	assert.Nil(t, err)
+	var unmarshaled *api.Entry
+	assert.Nil(t, json.Unmarshal(marshaled, &unmarshaled))
+	_, err = NewDissector().Represent(unmarshaled.Request, unmarshaled.Response)
+	assert.Nil(t, err)
+}
+
+func TestDissectGrpcSchemaless(t *testing.T) {
+	nested := protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 150)
+	request := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "unknown")
+	request = protowire.AppendBytes(protowire.AppendTag(request, 2, protowire.BytesType), nested)
+	request = protowire.AppendBytes(protowire.AppendTag(request, 2, protowire.BytesType), nested)
+	request = protowire.AppendFixed32(protowire.AppendTag(request, 3, protowire.Fixed32Type), 7)
+
+	client, server := grpcCall(t, "/unknown.Service/Call", grpcFrame(t, request, false), "identity", [][]byte{grpcFrame(t, []byte{0xff}, false)}, "grpc-status", "0")
+	entry := dissectGrpc(t, client, server)
+
+	requestMessages := grpcMessages(t, entry.Request)
+	assert.Len(t, requestMessages, 1)
+	message := requestMessages[0].(map[string]interface{})
+	assert.Equal(t, true, message["schemaless"])
+	assert.Equal(t, map[string]interface{}{
+		"1": "unknown",
+		"2": []interface{}{
+			map[string]interface{}{"1": float64(150)},
+			map[string]interface{}{"1": float64(150)},
+		},
+		"3": float64(7),
+	}, message["data"])
+
+	// Not a valid message, so it's kept as is
+	responseMessages := grpcMessages(t, entry.Response)
+	assert.Len(t, responseMessages, 1)
+	assert.Equal(t, "/w==", responseMessages[0].(map[string]interface{})["data"])
+	assert.NotEmpty(t, responseMessages[0].(map[string]interface{})["error"])
+}
+
+func TestDissectGrpcReflection(t *testing.T) {
+	file, err := proto.Marshal(greeterFile("learned"))
+	assert.Nil(t, err)
+
+	// ServerReflectionResponse.file_descriptor_response.file_descriptor_proto
+	fileDescriptorResponse := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), file)
+	reflectionResponse := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "greeter")
+	reflectionResponse = protowire.AppendBytes(protowire.AppendTag(reflectionResponse, 4, protowire.BytesType), fileDescriptorResponse)
+
+	client, server := grpcCall(t, "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", grpcFrame(t, nil, false), "identity", [][]byte{grpcFrame(t, reflectionResponse, false)}, "grpc-status", "0")
+	dissectGrpc(t, client, server)
+	assert.Contains(t, GrpcServices(), "learned.Greeter")
+
+	request := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "Kubeshark")
+	client, server = grpcCall(t, "/learned.Greeter/SayHello", grpcFrame(t, request, false), "identity", [][]byte{grpcFrame(t, nil, false)}, "grpc-status", "0")
+	entry := dissectGrpc(t, client, server)
+
+	requestMessages := grpcMessages(t, entry.Request)
+	assert.Equal(t, "learned.HelloRequest", requestMessages[0].(map[string]interface{})["type"])
+	assert.Equal(t, map[string]interface{}{"name": "Kubeshark"}, requestMessages[0].(map[string]interface{})["data"])
+}
+
+func TestLoadGrpcDescriptorSetOutOfOrder(t *testing.T) {
+	dependent := greeterFile("dependent")
+	dependent.Dependency = []string{"dependency/types.proto"}
+	dependent.MessageType[0].Field[0].Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	dependent.MessageType[0].Field[0].TypeName = proto.String(".dependency.Name")
+
+	dependency := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("dependency/types.proto"),
+		Package:     proto.String("dependency"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Name")}},
+	}
+
+	// The imports of a file are registered before it regardless of the order
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{dependent}})
+	assert.Nil(t, err)
+	services, err := LoadGrpcDescriptorSet(set)
+	assert.Nil(t, err)
+	assert.NotContains(t, services, "dependent.Greeter")
+
+	set, err = proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{dependency}})
+	assert.Nil(t, err)
+	services, err = LoadGrpcDescriptorSet(set)
+	assert.Nil(t, err)
+	assert.Contains(t, services, "dependent.Greeter")
+
+	_, err = LoadGrpcDescriptorSet([]byte{0xff})
+	assert.NotNil(t, err)
+}
+
+func TestDissectGrpcServerStreaming(t *testing.T) {
+	request := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "watch")
+	event := func(revision uint64) []byte {
+		return grpcFrame(t, protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), revision), false)
+	}
+
+	// The second event is split across two DATA frames
+	second := event(2)
+	response := [][]byte{event(1), second[:3], second[3:], event(3)}
+	client, server := grpcCall(t, "/unknown.Watch/Watch", grpcFrame(t, request, false), "identity", response, "grpc-status", "5", "grpc-message", "not%20found")
+	entries := dissectGrpcEntries(t, client, server)
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+
+	// The first event is held until the second one shows that the side is streaming
+	for i, entry := range entries[:3] {
+		assert.Equal(t, "/unknown.Watch/Watch", entry.Request["path"])
+		assert.Equal(t, "server", entry.Request["direction"])
+		assert.Equal(t, float64(i), entry.Request["seq"])
+		assert.Equal(t, float64(1), entry.Request["streamId"])
+		assert.Equal(t, map[string]interface{}{"1": float64(i + 1)}, entry.Request["data"])
+
+		base := NewDissector().Summarize(entry)
+		assert.Equal(t, "/unknown.Watch/Watch", base.Summary)
+		assert.Equal(t, "server", base.Method)
+		assert.Equal(t, `request.direction == "server"`, base.MethodQuery)
+
+		marshaled, err := json.Marshal(entry)
+		assert.Nil(t, err)
+		var unmarshaled *api.Entry
+		assert.Nil(t, json.Unmarshal(marshaled, &unmarshaled))
+		_, err = NewDissector().Represent(unmarshaled.Request, unmarshaled.Response)
+		assert.Nil(t, err)
+	}
+
+	// The final pair carries the trailers and the unary side
+	final := entries[3]
+	assert.Len(t, grpcMessages(t, final.Request), 1)
+	assert.Equal(t, false, final.Request["grpc"].(map[string]interface{})["streamed"])
+	grpc := final.Response["grpc"].(map[string]interface{})
+	assert.Equal(t, true, grpc["streamed"])
+	assert.Equal(t, float64(3), grpc["messageCount"])
+	assert.Equal(t, float64(5), grpc["status"])
+	assert.Equal(t, "not found", grpc["message"])
+	assert.Len(t, grpc["messages"], 0)
+}
+
+func TestDissectGrpcServerStreamingWithDescriptors(t *testing.T) {
+	file := greeterFile("streaming")
+	file.Service[0].Method[0].ServerStreaming = proto.Bool(true)
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	assert.Nil(t, err)
+	_, err = LoadGrpcDescriptorSet(set)
+	assert.Nil(t, err)
+
+	request := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "Kubeshark")
+	response := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "Hello Kubeshark")
+
+	// A single message is known to be streamed by the descriptor
+	client, server := grpcCall(t, "/streaming.Greeter/SayHello", grpcFrame(t, request, false), "identity", [][]byte{grpcFrame(t, response, false)}, "grpc-status", "0")
+	entries := dissectGrpcEntries(t, client, server)
+	if !assert.Len(t, entries, 2) {
+		return
+	}
+
+	assert.Equal(t, float64(0), entries[0].Request["seq"])
+	assert.Equal(t, "streaming.HelloReply", entries[0].Request["type"])
+	assert.Equal(t, map[string]interface{}{"message": "Hello Kubeshark"}, entries[0].Request["data"])
+
+	assert.Equal(t, "streaming.HelloRequest", grpcMessages(t, entries[1].Request)[0].(map[string]interface{})["type"])
+	assert.Equal(t, float64(1), entries[1].Response["grpc"].(map[string]interface{})["messageCount"])
+	assert.Equal(t, float64(0), entries[1].Response["grpc"].(map[string]interface{})["status"])
+}
+
+func TestDissectGrpcReset(t *testing.T) {
+	headerBlock := func(fields ...string) []byte {
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		for i := 0; i < len(fields); i += 2 {
+			assert.Nil(t, encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+		}
+		return block.Bytes()
+	}
+
+	// Either side may cancel the stream, which then never ends
+	for _, clientResets := range []bool{true, false} {
+		var client bytes.Buffer
+		client.WriteString(http2.ClientPreface)
+		framer := http2.NewFramer(&client, nil)
+		assert.Nil(t, framer.WriteSettings())
+		assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: headerBlock(":method", "POST", ":scheme", "http", ":path", "/unknown.Watch/Watch", ":authority", "greeter:50051", "content-type", "application/grpc"),
+			EndHeaders:    true,
+		}))
+		assert.Nil(t, framer.WriteData(1, false, grpcFrame(t, []byte{}, false)))
+
+		var server bytes.Buffer
+		framer = http2.NewFramer(&server, nil)
+		assert.Nil(t, framer.WriteSettings())
+		if clientResets {
+			framer = http2.NewFramer(&client, nil)
+		}
+		assert.Nil(t, framer.WriteRSTStream(1, http2.ErrCodeCancel))
+
+		dissector := NewDissector()
+		itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+		stream := NewTcpStream()
+		emitter := &api.Emitting{AppStats: &api.AppStats{}, Stream: stream, OutputChannel: itemChannel}
+		reqResMatcher := dissector.NewResponseRequestMatcher()
+		sides := []struct {
+			data     []byte
+			tcpID    *api.TcpID
+			isClient bool
+		}{
+			{client.Bytes(), &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}, true},
+			{server.Bytes(), &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "2", DstPort: "1"}, false},
+		}
+		for _, side := range sides {
+			reader := NewTcpReader(&api.ReadProgress{}, "", side.tcpID, time.Time{}, stream, side.isClient, false, nil, emitter, &api.CounterPair{}, reqResMatcher)
+			assert.Nil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(side.data)), reader))
+		}
+
+		paths := 0
+		reqResMatcher.(*requestResponseMatcher).grpcPaths.Range(func(_, _ interface{}) bool {
+			paths++
+			return true
+		})
+		assert.Equal(t, 0, paths)
+	}
+}
@@ -0,0 +1,310 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// The variants of gRPC that share its length-prefixed messages
+const (
+	grpcVariantGrpc        = "grpc"
+	grpcVariantGrpcWeb     = "grpc-web"
+	grpcVariantGrpcWebText = "grpc-web-text"
+	grpcVariantConnect     = "connect"
+)
+
+const (
+	grpcCodecProto = "proto"
+	grpcCodecJSON  = "json"
+)
+
+// The flags of a message header besides the compression
+const (
+	grpcWebTrailerFlag   = 0x80
+	connectEndStreamFlag = 0x02
+)
+
+// The error codes of Connect, which are the names of the gRPC status codes
+var connectCodes = map[string]int{
+	"canceled":            1,
+	"unknown":             2,
+	"invalid_argument":    3,
+	"deadline_exceeded":   4,
+	"not_found":           5,
+	"already_exists":      6,
+	"permission_denied":   7,
+	"resource_exhausted":  8,
+	"failed_precondition": 9,
+	"aborted":             10,
+	"out_of_range":        11,
+	"unimplemented":       12,
+	"internal":            13,
+	"unavailable":         14,
+	"data_loss":           15,
+	"unauthenticated":     16,
+}
+
+// parseGrpcContentType returns the variant and the codec of an enveloped
+// body, e.g. application/grpc-web+json. The codec defaults to protobuf.
+func parseGrpcContentType(contentType string) (variant string, codec string, ok bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return
+	}
+
+	codec = grpcCodecProto
+	if i := strings.IndexByte(mediaType, '+'); i >= 0 {
+		mediaType, codec = mediaType[:i], mediaType[i+1:]
+	}
+
+	switch mediaType {
+	case "application/grpc":
+		variant = grpcVariantGrpc
+	case "application/grpc-web":
+		variant = grpcVariantGrpcWeb
+	case "application/grpc-web-text":
+		variant = grpcVariantGrpcWebText
+	case "application/connect":
+		variant = grpcVariantConnect
+	default:
+		return "", "", false
+	}
+	return variant, codec, true
+}
+
+// grpcProtocolOf returns the protocol that the pairs and the messages of a
+// variant are labeled with.
+func grpcProtocolOf(variant string, version string) api.Protocol {
+	switch variant {
+	case grpcVariantGrpcWeb, grpcVariantGrpcWebText:
+		if version == http2Protocol.Version {
+			return grpcWeb2Protocol
+		}
+		return grpcWeb1Protocol
+	case grpcVariantConnect:
+		if version == http2Protocol.Version {
+			return connect2Protocol
+		}
+		return connect1Protocol
+	default:
+		return grpcProtocol
+	}
+}
+
+// setGrpcPayload sets the payload of a message, which is kept raw for the
+// protobuf decoding that follows the matching of the pair.
+func setGrpcPayload(message *GrpcMessage, payload []byte, codec string) {
+	if codec != grpcCodecJSON {
+		message.raw = payload
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		message.Error = err.Error()
+		message.Data = base64.StdEncoding.EncodeToString(payload)
+		return
+	}
+	message.Data = data
+}
+
+// decodeGrpcWebText decodes the base64 of a grpc-web-text body as it comes.
+// The body may be made of separately padded chunks, so it's decoded one
+// quantum at a time and the incomplete quantum is kept for the next data.
+func (s *grpcStream) decodeGrpcWebText(data []byte) ([]byte, error) {
+	s.text = append(s.text, data...)
+	n := len(s.text) - len(s.text)%4
+
+	decoded := make([]byte, 0, n/4*3)
+	quantum := make([]byte, 3)
+	for i := 0; i < n; i += 4 {
+		m, err := base64.StdEncoding.Decode(quantum, s.text[i:i+4])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, quantum[:m]...)
+	}
+	s.text = s.text[n:]
+	return decoded, nil
+}
+
+// parseGrpcWebTrailers reads a trailer frame of gRPC-Web, which holds the
+// trailers in the HTTP/1 header format.
+func (s *grpcStream) parseGrpcWebTrailers(payload []byte) {
+	for _, line := range strings.Split(string(payload), "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		s.trailers.Add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+}
+
+// parseConnectEndStream reads the end-stream message of a Connect stream,
+// which holds the error of the call, if any, and the trailers.
+func (s *grpcStream) parseConnectEndStream(payload []byte) {
+	var endStream struct {
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Metadata map[string][]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(payload, &endStream); err != nil {
+		s.pending = append(s.pending, &GrpcMessage{
+			Size:  len(payload),
+			Data:  base64.StdEncoding.EncodeToString(payload),
+			Error: "invalid end-stream message: " + err.Error(),
+		})
+		return
+	}
+
+	for name, values := range endStream.Metadata {
+		for _, value := range values {
+			s.trailers.Add(name, value)
+		}
+	}
+
+	status := 0
+	if endStream.Error != nil {
+		status = connectStatus(endStream.Error.Code)
+		s.statusMessage = endStream.Error.Message
+	}
+	s.status = &status
+}
+
+func connectStatus(code string) int {
+	if status, ok := connectCodes[code]; ok {
+		return status
+	}
+	return connectCodes["unknown"]
+}
+
+// grpcRequestURL returns the URL of a request, which is only given by the
+// :path pseudo-header in HTTP/2.
+func grpcRequestURL(request *http.Request) *url.URL {
+	if path := request.Header.Get(":path"); path != "" {
+		if u, err := url.ParseRequestURI(path); err == nil {
+			return u
+		}
+	}
+	return request.URL
+}
+
+// readBody reads a body and rewinds it. The bodies of HTTP/2 are base64
+// encoded by the assembler.
+func readBody(body *io.ReadCloser, protoMajor int) []byte {
+	if *body == nil {
+		return []byte{}
+	}
+
+	data, _ := io.ReadAll(*body)
+	*body = io.NopCloser(bytes.NewBuffer(data)) // rewind
+	if protoMajor == protoMajorHTTP2 {
+		if decoded, err := base64.StdEncoding.DecodeString(string(data)); err == nil {
+			return decoded
+		}
+	}
+	return data
+}
+
+// connectUnaryCodec returns the codec of a unary Connect body, which is
+// given by the media type, e.g. application/proto.
+func connectUnaryCodec(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "application/") {
+		return grpcCodecProto
+	}
+	return strings.TrimPrefix(mediaType, "application/")
+}
+
+// prepareConnectUnary sets the gRPC parts of a unary Connect call, whose
+// bodies aren't enveloped and are compressed by the Content-Encoding that's
+// already decoded. The request is told apart by the protocol version, which
+// a GET request gives in its query string. It returns false if the pair
+// isn't a unary Connect call.
+func prepareConnectUnary(request *HTTPPayload, response *HTTPPayload) bool {
+	req := request.Data.(*http.Request)
+	res := response.Data.(*http.Response)
+
+	query := grpcRequestURL(req).Query()
+	isGet := req.Method == http.MethodGet && query.Get("connect") == "v1"
+	if req.Header.Get("Connect-Protocol-Version") == "" && !isGet {
+		return false
+	}
+
+	message := &GrpcMessage{}
+	var payload []byte
+	var codec string
+	if isGet {
+		// The message is in the query string, unless the method takes nothing
+		codec = query.Get("encoding")
+		payload = []byte(query.Get("message"))
+		if query.Get("base64") == "1" {
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(string(payload), "="))
+			if err != nil {
+				message.Error = err.Error()
+			}
+			payload = decoded
+		}
+		if compression := query.Get("compression"); compression != "" && compression != "identity" && message.Error == "" {
+			message.Compressed = true
+			decompressed, err := decompressGrpcMessage(payload, compression)
+			if err != nil {
+				message.Error = err.Error()
+			}
+			payload = decompressed
+		}
+	} else {
+		codec = connectUnaryCodec(req.Header.Get("Content-Type"))
+		payload = readBody(&req.Body, req.ProtoMajor)
+	}
+	message.Size = len(payload)
+	if message.Error == "" {
+		setGrpcPayload(message, payload, codec)
+	}
+	request.Grpc = &GrpcBody{
+		Protocol:     grpcVariantConnect,
+		Codec:        codec,
+		Messages:     []*GrpcMessage{message},
+		MessageCount: 1,
+	}
+
+	codec = connectUnaryCodec(res.Header.Get("Content-Type"))
+	payload = readBody(&res.Body, res.ProtoMajor)
+	response.Grpc = &GrpcBody{
+		Protocol: grpcVariantConnect,
+		Codec:    codec,
+		Messages: make([]*GrpcMessage, 0),
+	}
+	if res.StatusCode == http.StatusOK {
+		message := &GrpcMessage{Size: len(payload)}
+		setGrpcPayload(message, payload, codec)
+		response.Grpc.Messages = append(response.Grpc.Messages, message)
+		response.Grpc.MessageCount = 1
+		status := 0
+		response.Grpc.Status = &status
+		return true
+	}
+
+	// An error is always given in JSON
+	var connectError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(payload, &connectError); err == nil && connectError.Code != "" {
+		status := connectStatus(connectError.Code)
+		response.Grpc.Status = &status
+		response.Grpc.StatusMessage = connectError.Message
+		response.Grpc.Codec = grpcCodecJSON
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func grpcTrailerFrame(flag byte, payload string) []byte {
+	frame := grpcFrame(nil, []byte(payload), false)
+	frame[0] = flag
+	return frame
+}
+
+func loadGreeter(t *testing.T, pkg string) {
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{greeterFile(pkg)}})
+	assert.Nil(t, err)
+	_, err = LoadGrpcDescriptorSet(set)
+	assert.Nil(t, err)
+}
+
+func helloRequest(name string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), name)
+}
+
+// http2Call returns both sides of an HTTP/2 connection with a single call.
+// The response ends with its last DATA frame unless trailers are given.
+func http2Call(t *testing.T, requestHeaders []string, request []byte, responseHeaders []string, response [][]byte, trailers ...string) (client []byte, server []byte) {
+	headerBlock := func(fields ...string) []byte {
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		for i := 0; i < len(fields); i += 2 {
+			assert.Nil(t, encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+		}
+		return block.Bytes()
+	}
+
+	var clientBuffer bytes.Buffer
+	clientBuffer.WriteString(http2.ClientPreface)
+	framer := http2.NewFramer(&clientBuffer, nil)
+	assert.Nil(t, framer.WriteSettings())
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(requestHeaders...),
+		EndHeaders:    true,
+	}))
+	assert.Nil(t, framer.WriteData(1, true, request))
+
+	var serverBuffer bytes.Buffer
+	framer = http2.NewFramer(&serverBuffer, nil)
+	assert.Nil(t, framer.WriteSettings())
+	assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: headerBlock(responseHeaders...),
+		EndHeaders:    true,
+	}))
+	for i, chunk := range response {
+		assert.Nil(t, framer.WriteData(1, len(trailers) == 0 && i == len(response)-1, chunk))
+	}
+	if len(trailers) > 0 {
+		assert.Nil(t, framer.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: headerBlock(trailers...),
+			EndHeaders:    true,
+			EndStream:     true,
+		}))
+	}
+
+	return clientBuffer.Bytes(), serverBuffer.Bytes()
+}
+
+func TestGrpcWebHTTP1(t *testing.T) {
+	loadGreeter(t, "web")
+
+	request := grpcFrame(t, helloRequest("Kubeshark"), false)
+	response := append(grpcFrame(t, helloRequest("Hello Kubeshark"), false), grpcTrailerFrame(grpcWebTrailerFlag, "grpc-status: 0\r\ngrpc-message: done\r\n")...)
+	client := fmt.Sprintf("POST /web.Greeter/SayHello HTTP/1.1\r\nHost: greeter\r\nContent-Type: application/grpc-web+proto\r\nX-Grpc-Web: 1\r\nContent-Length: %d\r\n\r\n%s", len(request), request)
+	server := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/grpc-web+proto\r\nContent-Length: %d\r\n\r\n%s", len(response), response)
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+	entry := entries[0]
+
+	assert.Equal(t, grpcWeb1Protocol, entry.Protocol)
+	assert.Equal(t, "/web.Greeter/SayHello", entry.Request["path"])
+	requestGrpc := entry.Request["grpc"].(map[string]interface{})
+	assert.Equal(t, "grpc-web", requestGrpc["protocol"])
+	assert.Equal(t, "SayHello", requestGrpc["method"])
+	assert.Equal(t, map[string]interface{}{"name": "Kubeshark"}, grpcMessages(t, entry.Request)[0].(map[string]interface{})["data"])
+
+	// The trailer frame isn't a message, its trailers join the headers
+	responseMessages := grpcMessages(t, entry.Response)
+	assert.Len(t, responseMessages, 1)
+	assert.Equal(t, "web.HelloReply", responseMessages[0].(map[string]interface{})["type"])
+	responseGrpc := entry.Response["grpc"].(map[string]interface{})
+	assert.Equal(t, float64(0), responseGrpc["status"])
+	assert.Equal(t, "done", responseGrpc["message"])
+	assert.Equal(t, "0", entry.Response["headers"].(map[string]interface{})["Grpc-Status"])
+}
+
+func TestGrpcWebTextStreaming(t *testing.T) {
+	event := func(revision uint64) []byte {
+		return grpcFrame(t, protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), revision), false)
+	}
+
+	// Each chunk is padded on its own
+	chunks := []string{
+		base64.StdEncoding.EncodeToString(event(1)),
+		base64.StdEncoding.EncodeToString(event(2)),
+		base64.StdEncoding.EncodeToString(grpcTrailerFrame(grpcWebTrailerFlag, "grpc-status: 5\r\ngrpc-message: not%20found\r\n")),
+	}
+	var server bytes.Buffer
+	server.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/grpc-web-text\r\nTransfer-Encoding: chunked\r\n\r\n")
+	for _, chunk := range chunks {
+		fmt.Fprintf(&server, "%x\r\n%s\r\n", len(chunk), chunk)
+	}
+	server.WriteString("0\r\n\r\n")
+
+	request := base64.StdEncoding.EncodeToString(grpcFrame(t, helloRequest("watch"), false))
+	client := fmt.Sprintf("POST /unknown.Watch/Watch HTTP/1.1\r\nHost: watch\r\nContent-Type: application/grpc-web-text\r\nContent-Length: %d\r\n\r\n%s", len(request), request)
+
+	entries := dissectHTTPEntries(t, []byte(client), server.Bytes())
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	for i, entry := range entries[:2] {
+		assert.Equal(t, grpcWeb1Protocol, entry.Protocol)
+		assert.Equal(t, "/unknown.Watch/Watch", entry.Request["path"])
+		assert.Equal(t, "server", entry.Request["direction"])
+		assert.Equal(t, map[string]interface{}{"1": float64(i + 1)}, entry.Request["data"])
+	}
+
+	final := entries[2]
+	assert.Equal(t, grpcWeb1Protocol, final.Protocol)
+	assert.Equal(t, map[string]interface{}{"1": "watch"}, grpcMessages(t, final.Request)[0].(map[string]interface{})["data"])
+	grpc := final.Response["grpc"].(map[string]interface{})
+	assert.Equal(t, "grpc-web-text", grpc["protocol"])
+	assert.Equal(t, true, grpc["streamed"])
+	assert.Equal(t, float64(2), grpc["messageCount"])
+	assert.Equal(t, float64(5), grpc["status"])
+	assert.Equal(t, "not found", grpc["message"])
+}
+
+func TestConnectUnaryHTTP1(t *testing.T) {
+	client := "POST /connect.Greeter/SayHello HTTP/1.1\r\nHost: greeter\r\nConnect-Protocol-Version: 1\r\nContent-Type: application/json\r\nContent-Length: 20\r\n\r\n{\"name\":\"Kubeshark\"}"
+	server := "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 20\r\n\r\n{\"message\":\"Hello\"}\n"
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+	entry := entries[0]
+
+	assert.Equal(t, connect1Protocol, entry.Protocol)
+	requestGrpc := entry.Request["grpc"].(map[string]interface{})
+	assert.Equal(t, "connect", requestGrpc["protocol"])
+	assert.Equal(t, "json", requestGrpc["codec"])
+	assert.Equal(t, "connect.Greeter", requestGrpc["service"])
+	assert.Equal(t, map[string]interface{}{"name": "Kubeshark"}, grpcMessages(t, entry.Request)[0].(map[string]interface{})["data"])
+	assert.Equal(t, map[string]interface{}{"message": "Hello"}, grpcMessages(t, entry.Response)[0].(map[string]interface{})["data"])
+	assert.Equal(t, float64(0), entry.Response["grpc"].(map[string]interface{})["status"])
+	// Not taken for GraphQL or anything else
+	assert.Nil(t, entry.Request["graphql"])
+}
+
+func TestConnectUnaryError(t *testing.T) {
+	loadGreeter(t, "connect")
+
+	request := helloRequest("nobody")
+	client := fmt.Sprintf("POST /connect.Greeter/SayHello HTTP/1.1\r\nHost: greeter\r\nConnect-Protocol-Version: 1\r\nContent-Type: application/proto\r\nContent-Length: %d\r\n\r\n%s", len(request), request)
+	body := `{"code":"not_found","message":"no such user"}`
+	server := fmt.Sprintf("HTTP/1.1 404 Not Found\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+	entry := entries[0]
+
+	assert.Equal(t, connect1Protocol, entry.Protocol)
+	message := grpcMessages(t, entry.Request)[0].(map[string]interface{})
+	assert.Equal(t, "connect.HelloRequest", message["type"])
+	assert.Equal(t, map[string]interface{}{"name": "nobody"}, message["data"])
+
+	grpc := entry.Response["grpc"].(map[string]interface{})
+	assert.Len(t, grpc["messages"], 0)
+	assert.Equal(t, float64(5), grpc["status"])
+	assert.Equal(t, "no such user", grpc["message"])
+}
+
+func TestConnectUnaryGet(t *testing.T) {
+	message := base64.RawURLEncoding.EncodeToString(helloRequest("Kubeshark"))
+	client := fmt.Sprintf("GET /unknown.Greeter/SayHello?connect=v1&encoding=proto&base64=1&message=%s HTTP/1.1\r\nHost: greeter\r\n\r\n", message)
+	server := "HTTP/1.1 200 OK\r\nContent-Type: application/proto\r\nContent-Length: 0\r\n\r\n"
+
+	entries := dissectHTTPEntries(t, []byte(client), []byte(server))
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+
+	assert.Equal(t, connect1Protocol, entries[0].Protocol)
+	assert.Equal(t, map[string]interface{}{"1": "Kubeshark"}, grpcMessages(t, entries[0].Request)[0].(map[string]interface{})["data"])
+	assert.Len(t, grpcMessages(t, entries[0].Response), 1)
+}
+
+func TestConnectStreamingHTTP2(t *testing.T) {
+	endStream := grpcTrailerFrame(connectEndStreamFlag, `{"error":{"code":"unavailable","message":"draining"},"metadata":{"x-request-id":["42"]}}`)
+	client, server := http2Call(t,
+		[]string{":method", "POST", ":scheme", "http", ":path", "/unknown.Chat/Listen", ":authority", "chat", "content-type", "application/connect+json", "connect-protocol-version", "1"},
+		grpcFrame(t, []byte(`{"room":"1"}`), false),
+		[]string{":status", "200", "content-type", "application/connect+json"},
+		[][]byte{grpcFrame(t, []byte(`{"text":"a"}`), false), grpcFrame(t, []byte(`{"text":"b"}`), false), endStream},
+	)
+
+	entries := dissectHTTPEntries(t, client, server)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	for i, text := range []string{"a", "b"} {
+		assert.Equal(t, connect2Protocol, entries[i].Protocol)
+		assert.Equal(t, map[string]interface{}{"text": text}, entries[i].Request["data"])
+	}
+
+	final := entries[2]
+	assert.Equal(t, connect2Protocol, final.Protocol)
+	assert.Equal(t, map[string]interface{}{"room": "1"}, grpcMessages(t, final.Request)[0].(map[string]interface{})["data"])
+	grpc := final.Response["grpc"].(map[string]interface{})
+	assert.Equal(t, float64(2), grpc["messageCount"])
+	assert.Equal(t, float64(14), grpc["status"])
+	assert.Equal(t, "draining", grpc["message"])
+	assert.Equal(t, "42", final.Response["headers"].(map[string]interface{})["X-Request-Id"])
+}
+
+func TestGrpcWebHTTP2(t *testing.T) {
+	client, server := http2Call(t,
+		[]string{":method", "POST", ":scheme", "http", ":path", "/unknown.Greeter/SayHello", ":authority", "greeter", "content-type", "application/grpc-web"},
+		grpcFrame(t, helloRequest("Kubeshark"), false),
+		[]string{":status", "200", "content-type", "application/grpc-web"},
+		[][]byte{grpcFrame(t, helloRequest("Hello"), false), grpcTrailerFrame(grpcWebTrailerFlag, "grpc-status: 0\r\n")},
+	)
+
+	entries := dissectHTTPEntries(t, client, server)
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+	assert.Equal(t, grpcWeb2Protocol, entries[0].Protocol)
+	assert.Len(t, grpcMessages(t, entries[0].Response), 1)
+	assert.Equal(t, float64(0), entries[0].Response["grpc"].(map[string]interface{})["status"])
+	assert.Equal(t, "greeter", entries[0].Destination.Name)
+}
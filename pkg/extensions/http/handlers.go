@@ -12,12 +12,25 @@ import (
 	"github.com/kubeshark/worker/pkg/api"
 )
 
-func handleHTTP2Stream(http2Assembler *Http2Assembler, progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) error {
-	streamID, messageHTTP1, isGrpc, err := http2Assembler.readMessage()
+func handleHTTP2Stream(http2Assembler *Http2Assembler, isClient bool, progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) error {
+	streamID, messageHTTP1, contentEncoding, grpc, err := http2Assembler.readMessage()
+	if err == errStreamReset {
+		// The path is left by the client side, whichever side cancelled
+		reqResMatcher.deleteGrpcPath(grpcStreamIdent(grpcConnectionInfo(tcpID, isClient), streamID))
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	var grpcBody *GrpcBody
+	if grpc != nil {
+		handleGrpcStream(grpc, streamID, progress, tcpID, captureTime, emitter, reqResMatcher)
+		if grpc.ended {
+			grpcBody = grpc.body()
+		}
+	}
+
 	var item *api.OutputChannelItem
 
 	switch messageHTTP1 := messageHTTP1.(type) {
@@ -31,7 +44,7 @@ func handleHTTP2Stream(http2Assembler *Http2Assembler, progress *api.ReadProgres
 			streamID,
 			"HTTP2",
 		)
-		item = reqResMatcher.registerRequest(ident, &messageHTTP1, captureTime, progress.Current(), messageHTTP1.ProtoMinor)
+		item = reqResMatcher.registerRequest(ident, &messageHTTP1, grpcBody, contentEncoding, captureTime, progress.Current(), messageHTTP1.ProtoMinor)
 		if item != nil {
 			item.ConnectionInfo = &api.ConnectionInfo{
 				ClientIP:   tcpID.SrcIP,
@@ -51,7 +64,7 @@ func handleHTTP2Stream(http2Assembler *Http2Assembler, progress *api.ReadProgres
 			streamID,
 			"HTTP2",
 		)
-		item = reqResMatcher.registerResponse(ident, &messageHTTP1, captureTime, progress.Current(), messageHTTP1.ProtoMinor)
+		item = reqResMatcher.registerResponse(ident, &messageHTTP1, grpcBody, contentEncoding, captureTime, progress.Current(), messageHTTP1.ProtoMinor)
 		if item != nil {
 			item.ConnectionInfo = &api.ConnectionInfo{
 				ClientIP:   tcpID.DstIP,
@@ -64,9 +77,7 @@ func handleHTTP2Stream(http2Assembler *Http2Assembler, progress *api.ReadProgres
 	}
 
 	if item != nil {
-		if isGrpc {
-			item.Protocol = grpcProtocol
-		} else {
+		if !prepareGrpcPair(item, streamID, reqResMatcher) {
 			item.Protocol = http2Protocol
 		}
 		emitter.Emit(item)
@@ -75,7 +86,7 @@ func handleHTTP2Stream(http2Assembler *Http2Assembler, progress *api.ReadProgres
 	return nil
 }
 
-func handleHTTP1ClientStream(b *bufio.Reader, progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) (switchingProtocolsHTTP2 bool, req *http.Request, err error) {
+func handleHTTP1ClientStream(b *bufio.Reader, progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) (switchingProtocolsHTTP2 bool, webSocketUpgrade *WebSocketUpgrade, req *http.Request, contentEncoding *ContentEncoding, err error) {
 	req, err = http.ReadRequest(b)
 	if err != nil {
 		return
@@ -90,8 +101,20 @@ func handleHTTP1ClientStream(b *bufio.Reader, progress *api.ReadProgress, tcpID
 		switchingProtocolsHTTP2 = true
 	}
 
+	// Check WebSocket upgrade, the frames follow the request if the server accepts it
+	if isWebSocketUpgrade(req.Header) {
+		webSocketUpgrade = newWebSocketUpgrade(req)
+	}
+
 	var body []byte
 	body, err = io.ReadAll(req.Body)
+	var grpcBody *GrpcBody
+	if isGrpcHeaders(req.Header) {
+		// The messages are compressed by their own encoding
+		grpcBody = handleGrpcBody(grpcDirectionClient, req.URL.Path, req.Header, body, requestCounter, progress, tcpID, captureTime, emitter, reqResMatcher)
+	} else {
+		body, contentEncoding = decodeContent(req.Header, body)
+	}
 	req.Body = io.NopCloser(bytes.NewBuffer(body)) // rewind
 
 	ident := fmt.Sprintf(
@@ -103,7 +126,7 @@ func handleHTTP1ClientStream(b *bufio.Reader, progress *api.ReadProgress, tcpID
 		requestCounter,
 		"HTTP1",
 	)
-	item := reqResMatcher.registerRequest(ident, req, captureTime, progress.Current(), req.ProtoMinor)
+	item := reqResMatcher.registerRequest(ident, req, grpcBody, contentEncoding, captureTime, progress.Current(), req.ProtoMinor)
 	if item != nil {
 		item.ConnectionInfo = &api.ConnectionInfo{
 			ClientIP:   tcpID.SrcIP,
@@ -112,12 +135,13 @@ func handleHTTP1ClientStream(b *bufio.Reader, progress *api.ReadProgress, tcpID
 			ServerPort: tcpID.DstPort,
 			IsOutgoing: true,
 		}
+		prepareGrpcPair(item, uint32(requestCounter), reqResMatcher)
 		emitter.Emit(item)
 	}
 	return
 }
 
-func handleHTTP1ServerStream(b *bufio.Reader, progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) (switchingProtocolsHTTP2 bool, err error) {
+func handleHTTP1ServerStream(b *bufio.Reader, progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) (switchingProtocolsHTTP2 bool, switchingProtocolsWebSocket bool, eventReader *ServerSentEventReader, err error) {
 	var res *http.Response
 	res, err = http.ReadResponse(b, nil)
 	if err != nil {
@@ -133,9 +157,10 @@ func handleHTTP1ServerStream(b *bufio.Reader, progress *api.ReadProgress, tcpID
 		switchingProtocolsHTTP2 = true
 	}
 
-	var body []byte
-	body, err = io.ReadAll(res.Body)
-	res.Body = io.NopCloser(bytes.NewBuffer(body)) // rewind
+	// Check WebSocket upgrade
+	if res.StatusCode == 101 && isWebSocketUpgrade(res.Header) {
+		switchingProtocolsWebSocket = true
+	}
 
 	ident := fmt.Sprintf(
 		"%s_%s_%s_%s_%d_%s",
@@ -146,7 +171,26 @@ func handleHTTP1ServerStream(b *bufio.Reader, progress *api.ReadProgress, tcpID
 		responseCounter,
 		"HTTP1",
 	)
-	item := reqResMatcher.registerResponse(ident, res, captureTime, progress.Current(), res.ProtoMinor)
+
+	var contentEncoding *ContentEncoding
+	var grpcBody *GrpcBody
+	if res.StatusCode == http.StatusOK && isServerSentEvents(res.Header) {
+		// The response stays open, so it's emitted without the events,
+		// which are read from the body as they come
+		eventReader = createServerSentEventReader(res.Body, ident)
+		res.Body = io.NopCloser(bytes.NewBuffer(nil))
+	} else {
+		var body []byte
+		body, err = io.ReadAll(res.Body)
+		if isGrpcHeaders(res.Header) {
+			grpcBody = handleGrpcBody(grpcDirectionServer, "", res.Header, body, responseCounter, progress, tcpID, captureTime, emitter, reqResMatcher)
+		} else if res.StatusCode != http.StatusPartialContent {
+			// A partial content can't be decoded
+			body, contentEncoding = decodeContent(res.Header, body)
+		}
+		res.Body = io.NopCloser(bytes.NewBuffer(body)) // rewind
+	}
+	item := reqResMatcher.registerResponse(ident, res, grpcBody, contentEncoding, captureTime, progress.Current(), res.ProtoMinor)
 	if item != nil {
 		item.ConnectionInfo = &api.ConnectionInfo{
 			ClientIP:   tcpID.DstIP,
@@ -155,6 +199,7 @@ func handleHTTP1ServerStream(b *bufio.Reader, progress *api.ReadProgress, tcpID
 			ServerPort: tcpID.SrcPort,
 			IsOutgoing: false,
 		}
+		prepareGrpcPair(item, uint32(responseCounter), reqResMatcher)
 		emitter.Emit(item)
 	}
 	return
@@ -155,3 +155,30 @@ func representSliceAsTable(slice []interface{}, selectorPrefix string) (represen
 	representation = string(obj)
 	return
 }
+
+// analyzeUnanswered turns the messages that are paired with an empty
+// response, such as those of a WebSocket or a streaming RPC, into entries.
+func analyzeUnanswered(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     item.Protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  0,
+	}
+}
@@ -78,16 +78,24 @@ func createHTTP2Assembler(b *bufio.Reader) *Http2Assembler {
 	framer.ReadMetaHeaders = hpack.NewDecoder(initialHeaderTableSize, nil)
 	return &Http2Assembler{
 		fragmentsByStream: make(fragmentsByStream),
+		grpcStreams:       make(map[uint32]*grpcStream),
 		framer:            framer,
 	}
 }
 
 type Http2Assembler struct {
 	fragmentsByStream fragmentsByStream
+	grpcStreams       map[uint32]*grpcStream
 	framer            *http2.Framer
 }
 
-func (ga *Http2Assembler) readMessage() (streamID uint32, messageHTTP1 interface{}, isGrpc bool, err error) {
+// readMessage reads a frame and returns the message once its stream ends.
+// The gRPC stream of the frame is returned for every frame, so that the
+// messages of a streaming RPC can be emitted before the end.
+// errStreamReset is returned for a stream that either side cancelled
+var errStreamReset = errors.New("stream reset")
+
+func (ga *Http2Assembler) readMessage() (streamID uint32, messageHTTP1 interface{}, contentEncoding *ContentEncoding, grpc *grpcStream, err error) {
 	// Exactly one Framer is used for each half connection.
 	// (Instead of creating a new Framer for each ReadFrame operation)
 	// This is needed in order to decompress the headers,
@@ -101,10 +109,38 @@ func (ga *Http2Assembler) readMessage() (streamID uint32, messageHTTP1 interface
 
 	ga.fragmentsByStream.appendFrame(streamID, frame)
 
+	grpc = ga.grpcStreams[streamID]
+	switch frame := frame.(type) {
+	case *http2.MetaHeadersFrame:
+		if grpc == nil {
+			header := make(http.Header)
+			for _, field := range frame.Fields {
+				header.Add(field.Name, field.Value)
+			}
+			if isGrpcHeaders(header) {
+				direction := grpcDirectionServer
+				if header.Get(":method") != "" {
+					direction = grpcDirectionClient
+				}
+				grpc = newGrpcStream(direction, header.Get(":path"), http2Protocol.Version, header)
+				ga.grpcStreams[streamID] = grpc
+			}
+		}
+	case *http2.DataFrame:
+		if grpc != nil {
+			grpc.feed(frame.Data())
+		}
+	case *http2.RSTStreamFrame:
+		// A cancelled stream never ends
+		delete(ga.grpcStreams, streamID)
+		err = errStreamReset
+		return
+	}
+
 	if !(ga.isStreamEnd(frame)) {
-		streamID = 0
 		return
 	}
+	delete(ga.grpcStreams, streamID)
 
 	headers, data := ga.fragmentsByStream.pop(streamID)
 
@@ -114,16 +150,19 @@ func (ga *Http2Assembler) readMessage() (streamID uint32, messageHTTP1 interface
 	for _, header := range headers {
 		headersHTTP1.Add(header.Name, header.Value)
 	}
+
+	// The messages of gRPC are compressed by their own grpc-encoding
+	if grpc == nil && headersHTTP1.Get(":status") != strconv.Itoa(http.StatusPartialContent) {
+		data, contentEncoding = decodeContent(headersHTTP1, data)
+	}
 	dataString := base64.StdEncoding.EncodeToString(data)
 
 	// Use http1 types only because they are expected in http_matcher.
 	method := headersHTTP1.Get(":method")
 	status := headersHTTP1.Get(":status")
 
-	// gRPC detection
-	grpcStatus := headersHTTP1.Get("Grpc-Status")
-	if grpcStatus != "" || strings.Contains(headersHTTP1.Get("Content-Type"), "application/grpc") {
-		isGrpc = true
+	if grpc != nil {
+		grpc.finish(headersHTTP1)
 	}
 
 	if method != "" {
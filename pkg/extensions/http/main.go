@@ -73,6 +73,66 @@ var grpcProtocol = api.Protocol{
 	Priority:        0,
 }
 
+var grpcWeb1Protocol = api.Protocol{
+	Name:            "http",
+	Version:         "1.1",
+	Abbreviation:    "gRPC-Web",
+	LongName:        "Hypertext Transfer Protocol -- HTTP/1.1 [ gRPC-Web over HTTP/1.1 ]",
+	Macro:           "grpcweb",
+	BackgroundColor: "#2d7d9a",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md",
+	Ports:           []string{"80", "443", "8080"},
+	Layer4:          "tcp",
+	Priority:        0,
+}
+
+var grpcWeb2Protocol = api.Protocol{
+	Name:            "http",
+	Version:         "2.0",
+	Abbreviation:    "gRPC-Web",
+	LongName:        "Hypertext Transfer Protocol Version 2 (HTTP/2) [ gRPC-Web over HTTP/2 ]",
+	Macro:           "grpcweb",
+	BackgroundColor: "#2d7d9a",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md",
+	Ports:           []string{"80", "443", "8080"},
+	Layer4:          "tcp",
+	Priority:        0,
+}
+
+var connect1Protocol = api.Protocol{
+	Name:            "http",
+	Version:         "1.1",
+	Abbreviation:    "Connect",
+	LongName:        "Hypertext Transfer Protocol -- HTTP/1.1 [ Connect over HTTP/1.1 ]",
+	Macro:           "connect",
+	BackgroundColor: "#4b3f9e",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://connectrpc.com/docs/protocol/",
+	Ports:           []string{"80", "443", "8080"},
+	Layer4:          "tcp",
+	Priority:        0,
+}
+
+var connect2Protocol = api.Protocol{
+	Name:            "http",
+	Version:         "2.0",
+	Abbreviation:    "Connect",
+	LongName:        "Hypertext Transfer Protocol Version 2 (HTTP/2) [ Connect over HTTP/2 ]",
+	Macro:           "connect",
+	BackgroundColor: "#4b3f9e",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://connectrpc.com/docs/protocol/",
+	Ports:           []string{"80", "443", "8080"},
+	Layer4:          "tcp",
+	Priority:        0,
+}
+
 var graphQL1Protocol = api.Protocol{
 	Name:            "http",
 	Version:         "1.1",
@@ -103,9 +163,42 @@ var graphQL2Protocol = api.Protocol{
 	Priority:        0,
 }
 
+var webSocketProtocol = api.Protocol{
+	Name:            "http",
+	Version:         "1.1",
+	Abbreviation:    "WS",
+	LongName:        "Hypertext Transfer Protocol -- HTTP/1.1 [ WebSocket ]",
+	Macro:           "ws",
+	BackgroundColor: "#5a3e8c",
+	ForegroundColor: "#ffffff",
+	FontSize:        12,
+	ReferenceLink:   "https://datatracker.ietf.org/doc/html/rfc6455",
+	Ports:           []string{"80", "443", "8080"},
+	Layer4:          "tcp",
+	Priority:        0,
+}
+
+var serverSentEventsProtocol = api.Protocol{
+	Name:            "http",
+	Version:         "1.1",
+	Abbreviation:    "SSE",
+	LongName:        "Hypertext Transfer Protocol -- HTTP/1.1 [ Server-Sent Events ]",
+	Macro:           "sse",
+	BackgroundColor: "#2f7d6d",
+	ForegroundColor: "#ffffff",
+	FontSize:        12,
+	ReferenceLink:   "https://html.spec.whatwg.org/multipage/server-sent-events.html",
+	Ports:           []string{"80", "443", "8080"},
+	Layer4:          "tcp",
+	Priority:        0,
+}
+
 const (
 	TypeHttpRequest = iota
 	TypeHttpResponse
+	TypeWebSocketMessage
+	TypeGrpcStreamMessage
+	TypeServerSentEvent
 )
 
 type dissecting string
@@ -130,7 +223,33 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 	}
 
 	switchingProtocolsHTTP2 := false
+	switchingProtocolsWebSocket := false
+	var webSocketUpgrade *WebSocketUpgrade
+	var webSocketReader *WebSocketReader
+	var eventReader *ServerSentEventReader
 	for {
+		// The frames of a WebSocket follow its upgrade until the connection is
+		// closed, unless the server refused it and the client goes on with requests
+		if webSocketUpgrade != nil {
+			upgrade := webSocketUpgrade
+			webSocketUpgrade = nil
+			var isWebSocket bool
+			isWebSocket, err = checkIsWebSocketClientStream(b)
+			if err != nil {
+				break
+			}
+			if isWebSocket {
+				reqResMatcher.registerWebSocketUpgrade(webSocketUpgradeIdent(reader.GetTcpID(), true), upgrade)
+				webSocketReader = createWebSocketReader(b, true, upgrade)
+			}
+		}
+
+		// The server switches once it accepts the upgrade
+		if switchingProtocolsWebSocket {
+			switchingProtocolsWebSocket = false
+			webSocketReader = createWebSocketReader(b, false, nil)
+		}
+
 		if switchingProtocolsHTTP2 {
 			switchingProtocolsHTTP2 = false
 			isHTTP2, err = checkIsHTTP2Connection(b, reader.GetIsClient())
@@ -144,8 +263,22 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 			http2Assembler = createHTTP2Assembler(b)
 		}
 
-		if isHTTP2 {
-			err = handleHTTP2Stream(http2Assembler, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
+		if webSocketReader != nil {
+			err = handleWebSocketStream(webSocketReader, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
+			if err != nil {
+				break
+			}
+			reader.GetParent().SetProtocol(&http11protocol)
+		} else if eventReader != nil {
+			// The responses that follow the event stream are read once it ends
+			err = handleServerSentEventStream(eventReader, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
+			if err != nil {
+				eventReader = nil
+				continue
+			}
+			reader.GetParent().SetProtocol(&http11protocol)
+		} else if isHTTP2 {
+			err = handleHTTP2Stream(http2Assembler, reader.GetIsClient(), reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				break
 			} else if err != nil {
@@ -154,7 +287,8 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 			reader.GetParent().SetProtocol(&http11protocol)
 		} else if reader.GetIsClient() {
 			var req *http.Request
-			switchingProtocolsHTTP2, req, err = handleHTTP1ClientStream(b, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
+			var contentEncoding *ContentEncoding
+			switchingProtocolsHTTP2, webSocketUpgrade, req, contentEncoding, err = handleHTTP1ClientStream(b, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				break
 			} else if err != nil {
@@ -172,7 +306,7 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 					reader.GetTcpID().DstPort,
 					"HTTP2",
 				)
-				item := reqResMatcher.registerRequest(ident, req, reader.GetCaptureTime(), reader.GetReadProgress().Current(), req.ProtoMinor)
+				item := reqResMatcher.registerRequest(ident, req, nil, contentEncoding, reader.GetCaptureTime(), reader.GetReadProgress().Current(), req.ProtoMinor)
 				if item != nil {
 					item.ConnectionInfo = &api.ConnectionInfo{
 						ClientIP:   reader.GetTcpID().SrcIP,
@@ -185,7 +319,7 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 				}
 			}
 		} else {
-			switchingProtocolsHTTP2, err = handleHTTP1ServerStream(b, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
+			switchingProtocolsHTTP2, switchingProtocolsWebSocket, eventReader, err = handleHTTP1ServerStream(b, reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), reqResMatcher)
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				break
 			} else if err != nil {
@@ -195,10 +329,25 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 		}
 	}
 
+	// The upgrade is left if the server side never read a message
+	if webSocketReader != nil && !reader.GetIsClient() {
+		reqResMatcher.deleteWebSocketUpgrade(webSocketUpgradeIdent(reader.GetTcpID(), false))
+	}
+
 	return nil
 }
 
 func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	if item.Protocol.Abbreviation == webSocketProtocol.Abbreviation {
+		return analyzeWebSocket(item, resolvedSource, resolvedDestination)
+	}
+	if item.Protocol.Abbreviation == serverSentEventsProtocol.Abbreviation {
+		return analyzeServerSentEvent(item, resolvedSource, resolvedDestination)
+	}
+	if isGrpcStreamMessage(item.Pair.Request.Payload.(map[string]interface{})["details"].(map[string]interface{})) {
+		return analyzeUnanswered(item, resolvedSource, resolvedDestination)
+	}
+
 	var host, authority, path string
 
 	request := item.Pair.Request.Payload.(map[string]interface{})
@@ -227,14 +376,6 @@ func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Res
 		}
 	}
 
-	if isGraphQL(reqDetails) {
-		if item.Protocol.Version == "2.0" {
-			item.Protocol = graphQL2Protocol
-		} else {
-			item.Protocol = graphQL1Protocol
-		}
-	}
-
 	if resDetails["bodySize"].(float64) < 0 {
 		resDetails["bodySize"] = 0
 	}
@@ -269,6 +410,23 @@ func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Res
 
 	reqDetails["queryString"] = mapSliceRebuildAsMap(reqDetails["queryString"].([]interface{}))
 
+	// The JSON messages of Connect aren't taken for GraphQL
+	if graphQL := parseGraphQLRequest(reqDetails); graphQL != nil && reqDetails["grpc"] == nil {
+		if item.Protocol.Version == "2.0" {
+			item.Protocol = graphQL2Protocol
+		} else {
+			item.Protocol = graphQL1Protocol
+		}
+		reqDetails["graphql"] = graphQL
+		resDetails["graphql"] = parseGraphQLResponse(resDetails)
+	}
+
+	if postData, ok := reqDetails["postData"].(map[string]interface{}); ok {
+		if params, ok := postData["params"].([]interface{}); ok {
+			postData["params"] = mapSliceRebuildAsMap(params)
+		}
+	}
+
 	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
 	if elapsedTime < 0 {
 		elapsedTime = 0
@@ -294,12 +452,25 @@ func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Res
 }
 
 func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
-	summary := entry.Request["path"].(string)
-	summaryQuery := fmt.Sprintf(`request.path == "%s"`, summary)
-	method := entry.Request["method"].(string)
-	methodQuery := fmt.Sprintf(`request.method == "%s"`, method)
-	status := int(entry.Response["status"].(float64))
-	statusQuery := fmt.Sprintf(`response.status == %d`, status)
+	var summary, summaryQuery, method, methodQuery, statusQuery string
+	var status int
+	if entry.Protocol.Abbreviation == webSocketProtocol.Abbreviation {
+		summary, summaryQuery, method, methodQuery, status, statusQuery = summarizeWebSocket(entry)
+	} else if isGrpcStreamMessage(entry.Request) {
+		summary, summaryQuery, method, methodQuery = summarizeGrpcStreamMessage(entry)
+	} else if isServerSentEvent(entry.Request) {
+		summary, summaryQuery, method, methodQuery = summarizeServerSentEvent(entry)
+	} else {
+		summary = entry.Request["path"].(string)
+		summaryQuery = fmt.Sprintf(`request.path == "%s"`, summary)
+		method = entry.Request["method"].(string)
+		methodQuery = fmt.Sprintf(`request.method == "%s"`, method)
+		status = int(entry.Response["status"].(float64))
+		statusQuery = fmt.Sprintf(`response.status == %d`, status)
+		if graphQL, ok := entry.Request["graphql"].(map[string]interface{}); ok {
+			summary, summaryQuery = summarizeGraphQL(graphQL, summary)
+		}
+	}
 
 	return &api.BaseEntry{
 		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
@@ -326,7 +497,7 @@ func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
 }
 
 func representRequest(request map[string]interface{}) (repRequest []interface{}) {
-	details, _ := json.Marshal([]api.TableData{
+	detailsTable := []api.TableData{
 		{
 			Name:     "Method",
 			Value:    request["method"].(string),
@@ -347,7 +518,11 @@ func representRequest(request map[string]interface{}) (repRequest []interface{})
 			Value:    int64(request["bodySize"].(float64)),
 			Selector: `request.bodySize`,
 		},
-	})
+	}
+	if contentEncoding, ok := request["contentEncoding"].(map[string]interface{}); ok {
+		detailsTable = append(detailsTable, representContentEncoding(contentEncoding, `request.contentEncoding`)...)
+	}
+	details, _ := json.Marshal(detailsTable)
 	repRequest = append(repRequest, api.SectionData{
 		Type:  api.TABLE,
 		Title: "Details",
@@ -397,29 +572,24 @@ func representRequest(request map[string]interface{}) (repRequest []interface{})
 		})
 	}
 
-	if postData["params"] != nil {
-		params, _ := json.Marshal(postData["params"].([]interface{}))
-		if len(params) > 0 {
-			if mimeType == "multipart/form-data" {
-				multipart, _ := json.Marshal([]map[string]string{
-					{
-						"name":  "Files",
-						"value": string(params),
-					},
-				})
-				repRequest = append(repRequest, api.SectionData{
-					Type:  api.TABLE,
-					Title: "POST Data (multipart/form-data)",
-					Data:  string(multipart),
-				})
-			} else {
-				repRequest = append(repRequest, api.SectionData{
-					Type:  api.TABLE,
-					Title: "POST Data (application/x-www-form-urlencoded)",
-					Data:  representMapSliceAsTable(postData["params"].([]interface{}), `request.postData.params`),
-				})
-			}
-		}
+	if params, ok := postData["params"].(map[string]interface{}); ok && len(params) > 0 {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: fmt.Sprintf("POST Data (%s)", mimeType),
+			Data:  representMapAsTable(params, `request.postData.params`),
+		})
+	}
+
+	if parts, ok := postData["parts"].([]interface{}); ok {
+		repRequest = append(repRequest, representFormParts(parts)...)
+	}
+
+	if grpc, ok := request["grpc"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, representGrpcBody(grpc, `request.grpc`)...)
+	}
+
+	if graphQL, ok := request["graphql"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, representGraphQLRequest(graphQL)...)
 	}
 
 	return
@@ -428,7 +598,7 @@ func representRequest(request map[string]interface{}) (repRequest []interface{})
 func representResponse(response map[string]interface{}) (repResponse []interface{}) {
 	repResponse = make([]interface{}, 0)
 
-	details, _ := json.Marshal([]api.TableData{
+	detailsTable := []api.TableData{
 		{
 			Name:     "Status",
 			Value:    int64(response["status"].(float64)),
@@ -444,7 +614,11 @@ func representResponse(response map[string]interface{}) (repResponse []interface
 			Value:    int64(response["bodySize"].(float64)),
 			Selector: `response.bodySize`,
 		},
-	})
+	}
+	if contentEncoding, ok := response["contentEncoding"].(map[string]interface{}); ok {
+		detailsTable = append(detailsTable, representContentEncoding(contentEncoding, `response.contentEncoding`)...)
+	}
+	details, _ := json.Marshal(detailsTable)
 	repResponse = append(repResponse, api.SectionData{
 		Type:  api.TABLE,
 		Title: "Details",
@@ -481,11 +655,38 @@ func representResponse(response map[string]interface{}) (repResponse []interface
 		})
 	}
 
+	if grpc, ok := response["grpc"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, representGrpcBody(grpc, `response.grpc`)...)
+	}
+
+	if graphQL, ok := response["graphql"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, representGraphQLResponse(graphQL)...)
+	}
+
 	return
 }
 
 func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
 	representation := make(map[string]interface{})
+	if _, ok := request["opcode"]; ok {
+		// The messages of a WebSocket aren't answered
+		representation["request"] = representWebSocketMessage(request)
+		representation["response"] = make([]interface{}, 0)
+		object, err = json.Marshal(representation)
+		return
+	}
+	if isGrpcStreamMessage(request) {
+		representation["request"] = representGrpcStreamMessage(request)
+		representation["response"] = make([]interface{}, 0)
+		object, err = json.Marshal(representation)
+		return
+	}
+	if isServerSentEvent(request) {
+		representation["request"] = representServerSentEvent(request)
+		representation["response"] = make([]interface{}, 0)
+		object, err = json.Marshal(representation)
+		return
+	}
 	repRequest := representRequest(request)
 	repResponse := representResponse(response)
 	representation["request"] = repRequest
@@ -496,10 +697,14 @@ func (d dissecting) Represent(request map[string]interface{}, response map[strin
 
 func (d dissecting) Macros() map[string]string {
 	return map[string]string{
-		`http`:  fmt.Sprintf(`protocol.abbr == "%s"`, http11protocol.Abbreviation),
-		`http2`: fmt.Sprintf(`protocol.abbr == "%s"`, http2Protocol.Abbreviation),
-		`grpc`:  fmt.Sprintf(`protocol.abbr == "%s"`, grpcProtocol.Abbreviation),
-		`gql`:   fmt.Sprintf(`protocol.abbr == "%s"`, graphQL1Protocol.Abbreviation),
+		`http`:    fmt.Sprintf(`protocol.abbr == "%s"`, http11protocol.Abbreviation),
+		`http2`:   fmt.Sprintf(`protocol.abbr == "%s"`, http2Protocol.Abbreviation),
+		`grpc`:    fmt.Sprintf(`protocol.abbr == "%s"`, grpcProtocol.Abbreviation),
+		`grpcweb`: fmt.Sprintf(`protocol.abbr == "%s"`, grpcWeb1Protocol.Abbreviation),
+		`connect`: fmt.Sprintf(`protocol.abbr == "%s"`, connect1Protocol.Abbreviation),
+		`gql`:     fmt.Sprintf(`protocol.abbr == "%s"`, graphQL1Protocol.Abbreviation),
+		`ws`:      fmt.Sprintf(`protocol.abbr == "%s"`, webSocketProtocol.Abbreviation),
+		`sse`:     fmt.Sprintf(`protocol.abbr == "%s"`, serverSentEventsProtocol.Abbreviation),
 	}
 }
 
@@ -44,10 +44,14 @@ func TestRegister(t *testing.T) {
 
 func TestMacros(t *testing.T) {
 	expectedMacros := map[string]string{
-		"http":  `protocol.abbr == "HTTP"`,
-		"http2": `protocol.abbr == "HTTP/2"`,
-		"grpc":  `protocol.abbr == "gRPC"`,
-		"gql":   `protocol.abbr == "GQL"`,
+		"http":    `protocol.abbr == "HTTP"`,
+		"http2":   `protocol.abbr == "HTTP/2"`,
+		"grpc":    `protocol.abbr == "gRPC"`,
+		"grpcweb": `protocol.abbr == "gRPC-Web"`,
+		"connect": `protocol.abbr == "Connect"`,
+		"gql":     `protocol.abbr == "GQL"`,
+		"ws":      `protocol.abbr == "WS"`,
+		"sse":     `protocol.abbr == "SSE"`,
 	}
 	dissector := NewDissector()
 	macros := dissector.Macros()
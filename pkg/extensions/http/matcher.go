@@ -11,10 +11,19 @@ import (
 // Key is {client_addr}_{client_port}_{dest_addr}_{dest_port}_{incremental_counter}_{proto_ident}
 type requestResponseMatcher struct {
 	openMessagesMap *sync.Map
+
+	// Key is {client_addr}_{dest_addr}_{client_port}_{dest_port}
+	webSocketUpgrades *sync.Map
+
+	// Key is {client_addr}_{dest_addr}_{client_port}_{dest_port}_{stream_id}
+	grpcPaths *sync.Map
+
+	// Key is {client_addr}_{dest_addr}_{client_port}_{dest_port}_{incremental_counter}_{proto_ident}
+	serverSentEventOrigins *sync.Map
 }
 
 func createResponseRequestMatcher() api.RequestResponseMatcher {
-	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}, webSocketUpgrades: &sync.Map{}, grpcPaths: &sync.Map{}, serverSentEventOrigins: &sync.Map{}}
 }
 
 func (matcher *requestResponseMatcher) GetMap() *sync.Map {
@@ -24,14 +33,16 @@ func (matcher *requestResponseMatcher) GetMap() *sync.Map {
 func (matcher *requestResponseMatcher) SetMaxTry(value int) {
 }
 
-func (matcher *requestResponseMatcher) registerRequest(ident string, request *http.Request, captureTime time.Time, captureSize int, protoMinor int) *api.OutputChannelItem {
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *http.Request, grpc *GrpcBody, contentEncoding *ContentEncoding, captureTime time.Time, captureSize int, protoMinor int) *api.OutputChannelItem {
 	requestHTTPMessage := api.GenericMessage{
 		IsRequest:   true,
 		CaptureTime: captureTime,
 		CaptureSize: captureSize,
 		Payload: HTTPPayload{
-			Type: TypeHttpRequest,
-			Data: request,
+			Type:            TypeHttpRequest,
+			Data:            request,
+			Grpc:            grpc,
+			ContentEncoding: contentEncoding,
 		},
 	}
 
@@ -41,6 +52,7 @@ func (matcher *requestResponseMatcher) registerRequest(ident string, request *ht
 		if responseHTTPMessage.IsRequest {
 			return nil
 		}
+		matcher.registerServerSentEventOrigin(ident, request, responseHTTPMessage)
 		return matcher.preparePair(&requestHTTPMessage, responseHTTPMessage, protoMinor)
 	}
 
@@ -48,14 +60,16 @@ func (matcher *requestResponseMatcher) registerRequest(ident string, request *ht
 	return nil
 }
 
-func (matcher *requestResponseMatcher) registerResponse(ident string, response *http.Response, captureTime time.Time, captureSize int, protoMinor int) *api.OutputChannelItem {
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *http.Response, grpc *GrpcBody, contentEncoding *ContentEncoding, captureTime time.Time, captureSize int, protoMinor int) *api.OutputChannelItem {
 	responseHTTPMessage := api.GenericMessage{
 		IsRequest:   false,
 		CaptureTime: captureTime,
 		CaptureSize: captureSize,
 		Payload: HTTPPayload{
-			Type: TypeHttpResponse,
-			Data: response,
+			Type:            TypeHttpResponse,
+			Data:            response,
+			Grpc:            grpc,
+			ContentEncoding: contentEncoding,
 		},
 	}
 
@@ -65,6 +79,7 @@ func (matcher *requestResponseMatcher) registerResponse(ident string, response *
 		if !requestHTTPMessage.IsRequest {
 			return nil
 		}
+		matcher.registerServerSentEventOrigin(ident, requestHTTPMessage.Payload.(HTTPPayload).Data.(*http.Request), &responseHTTPMessage)
 		return matcher.preparePair(requestHTTPMessage, &responseHTTPMessage, protoMinor)
 	}
 
@@ -87,3 +102,146 @@ func (matcher *requestResponseMatcher) preparePair(requestHTTPMessage *api.Gener
 		},
 	}
 }
+
+func (matcher *requestResponseMatcher) registerWebSocketUpgrade(ident string, upgrade *WebSocketUpgrade) {
+	matcher.webSocketUpgrades.Store(ident, upgrade)
+}
+
+// takeWebSocketUpgrade returns the upgrade request of a WebSocket for its
+// server side, which is nil if the client side of the connection wasn't read
+// yet. The client side keeps its own.
+func (matcher *requestResponseMatcher) takeWebSocketUpgrade(ident string) *WebSocketUpgrade {
+	if upgrade, found := matcher.webSocketUpgrades.LoadAndDelete(ident); found {
+		return upgrade.(*WebSocketUpgrade)
+	}
+	return nil
+}
+
+func (matcher *requestResponseMatcher) deleteWebSocketUpgrade(ident string) {
+	matcher.webSocketUpgrades.Delete(ident)
+}
+
+// prepareWebSocketMessage pairs a message of a WebSocket with an empty
+// response, since the messages aren't answered.
+func (matcher *requestResponseMatcher) prepareWebSocketMessage(message *WebSocketMessage, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       webSocketProtocol,
+		Timestamp:      captureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request: api.GenericMessage{
+				IsRequest:   true,
+				CaptureTime: captureTime,
+				CaptureSize: captureSize,
+				Payload: HTTPPayload{
+					Type: TypeWebSocketMessage,
+					Data: message,
+				},
+			},
+			Response: api.GenericMessage{
+				IsRequest:   false,
+				CaptureTime: captureTime,
+				Payload: HTTPPayload{
+					Type: TypeWebSocketMessage,
+					Data: &WebSocketMessage{},
+				},
+			},
+		},
+	}
+}
+
+func (matcher *requestResponseMatcher) registerGrpcPath(ident string, path string) {
+	matcher.grpcPaths.Store(ident, path)
+}
+
+// getGrpcPath returns the method that a gRPC stream calls, which is empty if
+// the client side of the connection wasn't read yet.
+func (matcher *requestResponseMatcher) getGrpcPath(ident string) string {
+	if path, found := matcher.grpcPaths.Load(ident); found {
+		return path.(string)
+	}
+	return ""
+}
+
+func (matcher *requestResponseMatcher) deleteGrpcPath(ident string) {
+	matcher.grpcPaths.Delete(ident)
+}
+
+// prepareGrpcStreamMessage pairs a message of a streaming RPC with an empty
+// response, the response comes with the final pair of the stream.
+func (matcher *requestResponseMatcher) prepareGrpcStreamMessage(message *GrpcStreamMessage, protocol api.Protocol, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      captureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request: api.GenericMessage{
+				IsRequest:   true,
+				CaptureTime: captureTime,
+				CaptureSize: captureSize,
+				Payload: HTTPPayload{
+					Type: TypeGrpcStreamMessage,
+					Data: message,
+				},
+			},
+			Response: api.GenericMessage{
+				IsRequest:   false,
+				CaptureTime: captureTime,
+				Payload: HTTPPayload{
+					Type: TypeGrpcStreamMessage,
+					Data: &GrpcStreamMessage{},
+				},
+			},
+		},
+	}
+}
+
+// registerServerSentEventOrigin keeps the request of a pair whose response
+// is an event stream, for the events that follow the response.
+func (matcher *requestResponseMatcher) registerServerSentEventOrigin(ident string, request *http.Request, responseHTTPMessage *api.GenericMessage) {
+	if response, ok := responseHTTPMessage.Payload.(HTTPPayload).Data.(*http.Response); ok && isServerSentEvents(response.Header) {
+		matcher.serverSentEventOrigins.Store(ident, newServerSentEventOrigin(request))
+	}
+}
+
+// getServerSentEventOrigin returns the request of an event stream, which is
+// nil if the client side of the connection wasn't read yet.
+func (matcher *requestResponseMatcher) getServerSentEventOrigin(ident string) *ServerSentEventOrigin {
+	if origin, found := matcher.serverSentEventOrigins.Load(ident); found {
+		return origin.(*ServerSentEventOrigin)
+	}
+	return nil
+}
+
+func (matcher *requestResponseMatcher) deleteServerSentEventOrigin(ident string) {
+	matcher.serverSentEventOrigins.Delete(ident)
+}
+
+// prepareServerSentEvent pairs an event with an empty response, since the
+// events aren't answered.
+func (matcher *requestResponseMatcher) prepareServerSentEvent(event *ServerSentEvent, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       serverSentEventsProtocol,
+		Timestamp:      captureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request: api.GenericMessage{
+				IsRequest:   true,
+				CaptureTime: captureTime,
+				CaptureSize: captureSize,
+				Payload: HTTPPayload{
+					Type: TypeServerSentEvent,
+					Data: event,
+				},
+			},
+			Response: api.GenericMessage{
+				IsRequest:   false,
+				CaptureTime: captureTime,
+				Payload: HTTPPayload{
+					Type: TypeServerSentEvent,
+					Data: &ServerSentEvent{},
+				},
+			},
+		},
+	}
+}
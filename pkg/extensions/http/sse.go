@@ -0,0 +1,269 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	serverSentEventMaxSize      = 1 * 1024 * 1024
+	serverSentEventDefaultEvent = "message"
+)
+
+// ServerSentEventOrigin is the request that an event stream was opened with.
+type ServerSentEventOrigin struct {
+	Method      string `json:"method"`
+	Url         string `json:"url"`
+	Path        string `json:"path"`
+	Host        string `json:"host"`
+	LastEventId string `json:"lastEventId,omitempty"`
+}
+
+// ServerSentEvent is an event of a text/event-stream response, emitted as
+// soon as it's read instead of waiting for the end of the response.
+type ServerSentEvent struct {
+	Id        string                 `json:"id,omitempty"`
+	Event     string                 `json:"event"`
+	Data      string                 `json:"data"`
+	Retry     *int                   `json:"retry,omitempty"`
+	Seq       int                    `json:"eventSeq"`
+	Truncated bool                   `json:"truncated"`
+	Origin    *ServerSentEventOrigin `json:"origin,omitempty"`
+}
+
+func isServerSentEvents(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+func newServerSentEventOrigin(req *http.Request) *ServerSentEventOrigin {
+	return &ServerSentEventOrigin{
+		Method:      req.Method,
+		Url:         req.URL.String(),
+		Path:        req.URL.Path,
+		Host:        req.Host,
+		LastEventId: req.Header.Get("Last-Event-ID"),
+	}
+}
+
+// ServerSentEventReader reads the events of a response body as defined in
+// the event stream interpretation of the HTML Living Standard.
+type ServerSentEventReader struct {
+	b     *bufio.Reader
+	ident string
+
+	// The ID of the last event is kept by the following events
+	lastEventId string
+	seq         int
+}
+
+func createServerSentEventReader(body io.Reader, ident string) *ServerSentEventReader {
+	return &ServerSentEventReader{b: bufio.NewReader(body), ident: ident}
+}
+
+// readLine reads a line that ends with either CRLF, LF or CR, without the
+// line ending. The part of a line past maxSize is discarded.
+func (r *ServerSentEventReader) readLine(maxSize int) (line []byte, truncated bool, err error) {
+	for {
+		c, err := r.b.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		switch c {
+		case '\r':
+			if next, err := r.b.Peek(1); err == nil && next[0] == '\n' {
+				_, _ = r.b.Discard(1)
+			}
+			return line, truncated, nil
+		case '\n':
+			return line, truncated, nil
+		}
+		if len(line) < maxSize {
+			line = append(line, c)
+		} else {
+			truncated = true
+		}
+	}
+}
+
+// readEvent reads the lines of the next event that has data. An event that
+// is cut short by the end of the body is dropped.
+func (r *ServerSentEventReader) readEvent() (*ServerSentEvent, error) {
+	event := &ServerSentEvent{}
+	var data bytes.Buffer
+	hasData := false
+	for {
+		line, truncated, err := r.readLine(serverSentEventMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		event.Truncated = event.Truncated || truncated
+
+		if len(line) == 0 {
+			if !hasData {
+				event = &ServerSentEvent{}
+				continue
+			}
+			break
+		}
+		// A comment, which is used to keep the connection alive
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte{}
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+
+		switch string(field) {
+		case "event":
+			event.Event = string(value)
+		case "data":
+			// The newlines that join the lines count towards the cap too
+			room := serverSentEventMaxSize - data.Len()
+			if hasData {
+				if room == 0 {
+					event.Truncated = true
+					continue
+				}
+				data.WriteByte('\n')
+				room--
+			}
+			hasData = true
+			if len(value) > room {
+				value = value[:room]
+				event.Truncated = true
+			}
+			data.Write(value)
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				r.lastEventId = string(value)
+			}
+		case "retry":
+			if retry, err := strconv.Atoi(string(value)); err == nil && retry >= 0 {
+				event.Retry = &retry
+			}
+		}
+	}
+
+	if event.Event == "" {
+		event.Event = serverSentEventDefaultEvent
+	}
+	event.Id = r.lastEventId
+	event.Data = data.String()
+	event.Seq = r.seq
+	r.seq++
+	return event, nil
+}
+
+func handleServerSentEventStream(eventReader *ServerSentEventReader, progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) error {
+	event, err := eventReader.readEvent()
+	if err != nil {
+		reqResMatcher.deleteServerSentEventOrigin(eventReader.ident)
+		return err
+	}
+
+	event.Origin = reqResMatcher.getServerSentEventOrigin(eventReader.ident)
+	item := reqResMatcher.prepareServerSentEvent(event, captureTime, progress.Current())
+	item.ConnectionInfo = &api.ConnectionInfo{
+		ClientIP:   tcpID.DstIP,
+		ClientPort: tcpID.DstPort,
+		ServerIP:   tcpID.SrcIP,
+		ServerPort: tcpID.SrcPort,
+		IsOutgoing: false,
+	}
+	emitter.Emit(item)
+
+	return nil
+}
+
+func isServerSentEvent(request map[string]interface{}) bool {
+	_, ok := request["eventSeq"]
+	return ok
+}
+
+func analyzeServerSentEvent(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	reqDetails := item.Pair.Request.Payload.(map[string]interface{})["details"].(map[string]interface{})
+	if origin, ok := reqDetails["origin"].(map[string]interface{}); ok && resolvedDestination.Name == "" {
+		resolvedDestination.Name = origin["host"].(string)
+	}
+
+	return analyzeUnanswered(item, resolvedSource, resolvedDestination)
+}
+
+func summarizeServerSentEvent(entry *api.Entry) (summary string, summaryQuery string, method string, methodQuery string) {
+	if origin, ok := entry.Request["origin"].(map[string]interface{}); ok {
+		summary = origin["path"].(string)
+		summaryQuery = fmt.Sprintf(`request.origin.path == "%s"`, summary)
+	}
+	method = entry.Request["event"].(string)
+	methodQuery = fmt.Sprintf(`request.event == "%s"`, method)
+	return
+}
+
+func representServerSentEvent(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Event",
+			Value:    request["event"].(string),
+			Selector: `request.event`,
+		},
+		{
+			Name:     "ID",
+			Value:    request["id"],
+			Selector: `request.id`,
+		},
+		{
+			Name:     "Sequence",
+			Value:    request["eventSeq"].(float64),
+			Selector: `request.eventSeq`,
+		},
+		{
+			Name:     "Retry (ms)",
+			Value:    request["retry"],
+			Selector: `request.retry`,
+		},
+		{
+			Name:     "Truncated",
+			Value:    request["truncated"],
+			Selector: `request.truncated`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if origin, ok := request["origin"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Origin Request",
+			Data:  representMapAsTable(origin, `request.origin`),
+		})
+	}
+
+	mimeType := "text/plain"
+	if json.Valid([]byte(request["data"].(string))) {
+		mimeType = "application/json"
+	}
+	repRequest = append(repRequest, api.SectionData{
+		Type:     api.BODY,
+		Title:    "Data",
+		MimeType: mimeType,
+		Data:     request["data"].(string),
+		Selector: `request.data`,
+	})
+
+	return
+}
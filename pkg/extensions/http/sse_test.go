@@ -0,0 +1,110 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chunk(data string) string {
+	return fmt.Sprintf("%x\r\n%s\r\n", len(data), data)
+}
+
+func TestDissectServerSentEvents(t *testing.T) {
+	client := "GET /notifications HTTP/1.1\r\n" +
+		"Host: notifications.example.com\r\n" +
+		"Accept: text/event-stream\r\n" +
+		"Last-Event-ID: 41\r\n\r\n" +
+		"GET /health HTTP/1.1\r\n" +
+		"Host: notifications.example.com\r\n\r\n"
+
+	var server bytes.Buffer
+	server.WriteString("HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/event-stream; charset=utf-8\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n")
+	server.WriteString(chunk(": keep-alive\n\nretry: 3000\nid: 42\nevent: created\ndata: {\"id\":"))
+	// An event can span chunks
+	server.WriteString(chunk("42}\n\n"))
+	server.WriteString(chunk("data: first line\r\ndata: second line\r\n\r\n"))
+	server.WriteString(chunk("id\n\n"))
+	server.WriteString(chunk("data:no space\n\ndata: cut short"))
+	server.WriteString("0\r\n\r\n")
+	// The connection is reused once the event stream ends
+	server.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+
+	items := dissectConnection(t, []byte(client), server.Bytes())
+
+	var events []*ServerSentEvent
+	var pairs int
+	for _, item := range items {
+		if item.Protocol.Abbreviation != serverSentEventsProtocol.Abbreviation {
+			assert.Equal(t, http11protocol.Abbreviation, item.Protocol.Abbreviation)
+			pairs++
+			continue
+		}
+		assert.Equal(t, false, item.ConnectionInfo.IsOutgoing)
+		events = append(events, item.Pair.Request.Payload.(HTTPPayload).Data.(*ServerSentEvent))
+	}
+	assert.Equal(t, 2, pairs)
+	if !assert.Len(t, events, 3) {
+		return
+	}
+
+	assert.Equal(t, "created", events[0].Event)
+	assert.Equal(t, "42", events[0].Id)
+	assert.Equal(t, `{"id":42}`, events[0].Data)
+	assert.Equal(t, 3000, *events[0].Retry)
+	assert.Equal(t, 0, events[0].Seq)
+	assert.Equal(t, "/notifications", events[0].Origin.Path)
+	assert.Equal(t, "notifications.example.com", events[0].Origin.Host)
+	assert.Equal(t, "41", events[0].Origin.LastEventId)
+
+	assert.Equal(t, "message", events[1].Event)
+	assert.Equal(t, "42", events[1].Id)
+	assert.Equal(t, "first line\nsecond line", events[1].Data)
+	assert.Nil(t, events[1].Retry)
+
+	// The ID is reset by an empty id field
+	assert.Equal(t, "", events[2].Id)
+	assert.Equal(t, "no space", events[2].Data)
+	assert.Equal(t, 2, events[2].Seq)
+
+	entries := dissectHTTPEntries(t, []byte(client), server.Bytes())
+	assert.Len(t, entries, 5)
+	for _, entry := range entries {
+		if entry.Protocol.Abbreviation != serverSentEventsProtocol.Abbreviation {
+			continue
+		}
+		summary := NewDissector().Summarize(entry)
+		assert.Equal(t, "/notifications", summary.Summary)
+		assert.Equal(t, `request.origin.path == "/notifications"`, summary.SummaryQuery)
+		assert.Equal(t, entry.Request["event"], summary.Method)
+		assert.Equal(t, "notifications.example.com", entry.Destination.Name)
+	}
+}
+
+func TestReadServerSentEventPastMaxSize(t *testing.T) {
+	long := strings.Repeat("a", serverSentEventMaxSize+10)
+	half := strings.Repeat("b", serverSentEventMaxSize/2)
+	for _, lines := range [][]string{
+		{long, "bbbbbbbb", ""},
+		{half, half, half, "c"},
+	} {
+		var body strings.Builder
+		for _, line := range lines {
+			body.WriteString("data:" + line + "\n")
+		}
+		body.WriteString("\n")
+
+		event, err := createServerSentEventReader(strings.NewReader(body.String()), "").readEvent()
+		if !assert.Nil(t, err) {
+			continue
+		}
+		assert.Equal(t, serverSentEventMaxSize, len(event.Data))
+		assert.Equal(t, lines[0][:10], event.Data[:10])
+		assert.True(t, event.Truncated)
+	}
+}
@@ -11,8 +11,10 @@ import (
 )
 
 type HTTPPayload struct {
-	Type uint8
-	Data interface{}
+	Type            uint8
+	Data            interface{}
+	Grpc            *GrpcBody
+	ContentEncoding *ContentEncoding
 }
 
 type HTTPPayloader interface {
@@ -25,13 +27,37 @@ type HTTPWrapper struct {
 	Details interface{} `json:"details"`
 }
 
+type httpRequest struct {
+	*har.Request
+	PostData        *postData        `json:"postData,omitempty"`
+	Grpc            *GrpcBody        `json:"grpc,omitempty"`
+	ContentEncoding *ContentEncoding `json:"contentEncoding,omitempty"`
+}
+
+type httpResponse struct {
+	*har.Response
+	Grpc            *GrpcBody        `json:"grpc,omitempty"`
+	ContentEncoding *ContentEncoding `json:"contentEncoding,omitempty"`
+}
+
 func (h HTTPPayload) MarshalJSON() ([]byte, error) {
 	switch h.Type {
 	case TypeHttpRequest:
-		harRequest, err := har.NewRequest(h.Data.(*http.Request), true)
+		request := h.Data.(*http.Request)
+		// The body is read into the post data here rather than by the HAR
+		// conversion, which fails on malformed forms
+		harRequest, err := har.NewRequest(request, false)
 		if err != nil {
 			return nil, errors.New("Failed converting request to HAR")
 		}
+		var requestPostData *postData
+		if harRequest.PostData != nil {
+			requestPostData = newPostData(request, harRequest.PostData)
+			harRequest.PostData = nil
+		}
+		if h.ContentEncoding != nil {
+			harRequest.Headers = append(harRequest.Headers, har.Header{Name: "Content-Encoding", Value: h.ContentEncoding.Encoding})
+		}
 		sort.Slice(harRequest.Headers, func(i, j int) bool {
 			if harRequest.Headers[i].Name < harRequest.Headers[j].Name {
 				return true
@@ -50,27 +76,30 @@ func (h HTTPPayload) MarshalJSON() ([]byte, error) {
 			}
 			return harRequest.QueryString[i].Value < harRequest.QueryString[j].Value
 		})
-		if harRequest.PostData != nil {
-			sort.Slice(harRequest.PostData.Params, func(i, j int) bool {
-				if harRequest.PostData.Params[i].Name < harRequest.PostData.Params[j].Name {
+		if requestPostData != nil {
+			sort.Slice(requestPostData.Params, func(i, j int) bool {
+				if requestPostData.Params[i].Name < requestPostData.Params[j].Name {
 					return true
 				}
-				if harRequest.PostData.Params[i].Name > harRequest.PostData.Params[j].Name {
+				if requestPostData.Params[i].Name > requestPostData.Params[j].Name {
 					return false
 				}
-				return harRequest.PostData.Params[i].Value < harRequest.PostData.Params[j].Value
+				return requestPostData.Params[i].Value < requestPostData.Params[j].Value
 			})
 		}
 		return json.Marshal(&HTTPWrapper{
 			Method:  harRequest.Method,
 			Url:     "",
-			Details: harRequest,
+			Details: &httpRequest{Request: harRequest, PostData: requestPostData, Grpc: h.Grpc, ContentEncoding: h.ContentEncoding},
 		})
 	case TypeHttpResponse:
 		harResponse, err := har.NewResponse(h.Data.(*http.Response), true)
 		if err != nil {
 			return nil, errors.New("Failed converting response to HAR")
 		}
+		if h.ContentEncoding != nil {
+			harResponse.Headers = append(harResponse.Headers, har.Header{Name: "Content-Encoding", Value: h.ContentEncoding.Encoding})
+		}
 		sort.Slice(harResponse.Headers, func(i, j int) bool {
 			if harResponse.Headers[i].Name < harResponse.Headers[j].Name {
 				return true
@@ -92,7 +121,25 @@ func (h HTTPPayload) MarshalJSON() ([]byte, error) {
 		return json.Marshal(&HTTPWrapper{
 			Method:  "",
 			Url:     "",
-			Details: harResponse,
+			Details: &httpResponse{Response: harResponse, Grpc: h.Grpc, ContentEncoding: h.ContentEncoding},
+		})
+	case TypeWebSocketMessage:
+		return json.Marshal(&HTTPWrapper{
+			Method:  h.Data.(*WebSocketMessage).Opcode,
+			Url:     "",
+			Details: h.Data,
+		})
+	case TypeGrpcStreamMessage:
+		return json.Marshal(&HTTPWrapper{
+			Method:  h.Data.(*GrpcStreamMessage).Direction,
+			Url:     "",
+			Details: h.Data,
+		})
+	case TypeServerSentEvent:
+		return json.Marshal(&HTTPWrapper{
+			Method:  h.Data.(*ServerSentEvent).Event,
+			Url:     "",
+			Details: h.Data,
 		})
 	default:
 		msg := "HTTP payload cannot be marshaled."
@@ -0,0 +1,426 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	webSocketOpContinuation = 0x0
+	webSocketOpText         = 0x1
+	webSocketOpBinary       = 0x2
+	webSocketOpClose        = 0x8
+	webSocketOpPing         = 0x9
+	webSocketOpPong         = 0xa
+
+	webSocketMaxMessageSize = 64 * 1024 * 1024
+	webSocketWindowSize     = 32 * 1024
+)
+
+var webSocketOpcodes = map[byte]string{
+	webSocketOpText:   "text",
+	webSocketOpBinary: "binary",
+	webSocketOpClose:  "close",
+}
+
+// Appended to a compressed message to complete the deflate stream, an
+// empty block that was cut off by the sender followed by a final one
+var webSocketDeflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// WebSocketUpgrade is the request that a WebSocket was opened with.
+type WebSocketUpgrade struct {
+	Method     string `json:"method"`
+	Url        string `json:"url"`
+	Path       string `json:"path"`
+	Host       string `json:"host"`
+	Protocols  string `json:"protocols,omitempty"`
+	Extensions string `json:"extensions,omitempty"`
+}
+
+// WebSocketMessage is a message that was either sent in a single frame or
+// reassembled from its fragments. The payload is base64 encoded and also
+// given as text when it's valid UTF-8.
+type WebSocketMessage struct {
+	Opcode         string            `json:"opcode"`
+	Direction      string            `json:"direction"`
+	Fragments      int               `json:"fragments"`
+	Compressed     bool              `json:"compressed"`
+	CompressedSize int               `json:"compressedSize,omitempty"`
+	Payload        string            `json:"payload"`
+	PayloadText    string            `json:"payloadText,omitempty"`
+	PayloadSize    int               `json:"payloadSize"`
+	CloseCode      int               `json:"closeCode,omitempty"`
+	CloseReason    string            `json:"closeReason,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	Upgrade        *WebSocketUpgrade `json:"upgrade,omitempty"`
+}
+
+func isWebSocketUpgrade(header http.Header) bool {
+	return strings.Contains(strings.ToLower(header.Get("Connection")), "upgrade") && strings.ToLower(header.Get("Upgrade")) == "websocket"
+}
+
+func newWebSocketUpgrade(req *http.Request) *WebSocketUpgrade {
+	return &WebSocketUpgrade{
+		Method:     req.Method,
+		Url:        req.URL.String(),
+		Path:       req.URL.Path,
+		Host:       req.Host,
+		Protocols:  req.Header.Get("Sec-WebSocket-Protocol"),
+		Extensions: req.Header.Get("Sec-WebSocket-Extensions"),
+	}
+}
+
+// WebSocketReader reads the frames of one side of a WebSocket as defined
+// in RFC 6455 and inflates the messages compressed by permessage-deflate.
+type WebSocketReader struct {
+	b        *bufio.Reader
+	isClient bool
+	upgrade  *WebSocketUpgrade
+
+	// The last 32KB of the inflated messages, since a message may refer
+	// back to the previous ones unless no_context_takeover was negotiated
+	window []byte
+}
+
+func createWebSocketReader(b *bufio.Reader, isClient bool, upgrade *WebSocketUpgrade) *WebSocketReader {
+	return &WebSocketReader{b: b, isClient: isClient, upgrade: upgrade}
+}
+
+// checkIsWebSocketClientStream tells whether the frames of a WebSocket follow
+// the upgrade request. The frames of a client are always masked, while the
+// request that follows a refused upgrade starts with the ASCII of its method.
+func checkIsWebSocketClientStream(b *bufio.Reader) (bool, error) {
+	buf, err := b.Peek(2)
+	if err != nil {
+		return false, err
+	}
+	return buf[1]&0x80 != 0, nil
+}
+
+// webSocketUpgradeIdent identifies the upgrade of a connection from either
+// of its sides.
+func webSocketUpgradeIdent(tcpID *api.TcpID, isClient bool) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort)
+}
+
+// readMessage reads the frames of the next text, binary or close message.
+// The control frames that may come in between the fragments of a message,
+// ping and pong, are skipped.
+func (r *WebSocketReader) readMessage() (*WebSocketMessage, error) {
+	var message *WebSocketMessage
+	var payload []byte
+	for {
+		fin, rsv1, opcode, data, err := r.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case webSocketOpPing, webSocketOpPong:
+			continue
+		case webSocketOpClose:
+			closeMessage := r.newMessage(opcode)
+			closeMessage.Fragments = 1
+			closeMessage.Payload, closeMessage.PayloadText, closeMessage.PayloadSize = encodeWebSocketPayload(data)
+			if len(data) >= 2 {
+				closeMessage.CloseCode = int(binary.BigEndian.Uint16(data))
+				closeMessage.CloseReason = string(data[2:])
+			}
+			return closeMessage, nil
+		case webSocketOpContinuation:
+			if message == nil {
+				return nil, errors.New("continuation frame without a message")
+			}
+		default:
+			if message != nil {
+				return nil, errors.New("data frame in the middle of a fragmented message")
+			}
+			message = r.newMessage(opcode)
+			message.Compressed = rsv1
+		}
+
+		if len(payload)+len(data) > webSocketMaxMessageSize {
+			return nil, errors.New("message is too large")
+		}
+		payload = append(payload, data...)
+		message.Fragments++
+		if fin {
+			break
+		}
+	}
+
+	if message.Compressed {
+		message.CompressedSize = len(payload)
+		inflated, err := r.inflate(payload)
+		if err != nil {
+			// The capture may have missed the messages that this one refers to
+			message.Error = err.Error()
+		} else {
+			payload = inflated
+		}
+	}
+	message.Payload, message.PayloadText, message.PayloadSize = encodeWebSocketPayload(payload)
+	return message, nil
+}
+
+func (r *WebSocketReader) newMessage(opcode byte) *WebSocketMessage {
+	direction := "server"
+	if r.isClient {
+		direction = "client"
+	}
+	return &WebSocketMessage{Opcode: webSocketOpcodes[opcode], Direction: direction}
+}
+
+// readFrame reads a frame and unmasks its payload. The frames of a client
+// are always masked and those of a server never are.
+func (r *WebSocketReader) readFrame() (fin bool, rsv1 bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(r.b, header); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	rsv1 = header[0]&0x40 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	if header[0]&0x30 != 0 {
+		err = errors.New("reserved bits are set")
+		return
+	}
+	if _, ok := webSocketOpcodes[opcode]; !ok && opcode != webSocketOpContinuation && opcode != webSocketOpPing && opcode != webSocketOpPong {
+		err = fmt.Errorf("unknown opcode: %#x", opcode)
+		return
+	}
+	if masked != r.isClient {
+		err = errors.New("unexpected masking")
+		return
+	}
+	isControl := opcode&0x08 != 0
+	if isControl && (!fin || rsv1 || length > 125) {
+		err = errors.New("malformed control frame")
+		return
+	}
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err = io.ReadFull(r.b, extended); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err = io.ReadFull(r.b, extended); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > webSocketMaxMessageSize {
+		err = errors.New("frame is too large")
+		return
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(r.b, mask); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r.b, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		if masked {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+func (r *WebSocketReader) inflate(payload []byte) ([]byte, error) {
+	compressed := io.MultiReader(bytes.NewReader(payload), bytes.NewReader(webSocketDeflateTail))
+	inflater := flate.NewReaderDict(compressed, r.window)
+	defer inflater.Close()
+
+	inflated, err := io.ReadAll(io.LimitReader(inflater, webSocketMaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(inflated) > webSocketMaxMessageSize {
+		return nil, errors.New("inflated message is too large")
+	}
+
+	r.window = append(r.window, inflated...)
+	if len(r.window) > webSocketWindowSize {
+		r.window = r.window[len(r.window)-webSocketWindowSize:]
+	}
+	return inflated, nil
+}
+
+func encodeWebSocketPayload(payload []byte) (encoded string, text string, size int) {
+	encoded = base64.StdEncoding.EncodeToString(payload)
+	if utf8.Valid(payload) {
+		text = string(payload)
+	}
+	return encoded, text, len(payload)
+}
+
+func handleWebSocketStream(webSocketReader *WebSocketReader, progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, reqResMatcher *requestResponseMatcher) error {
+	message, err := webSocketReader.readMessage()
+	if err != nil {
+		return err
+	}
+
+	if webSocketReader.upgrade == nil {
+		webSocketReader.upgrade = reqResMatcher.takeWebSocketUpgrade(webSocketUpgradeIdent(tcpID, webSocketReader.isClient))
+	}
+	message.Upgrade = webSocketReader.upgrade
+
+	var item *api.OutputChannelItem
+	if webSocketReader.isClient {
+		item = reqResMatcher.prepareWebSocketMessage(message, captureTime, progress.Current())
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+	} else {
+		item = reqResMatcher.prepareWebSocketMessage(message, captureTime, progress.Current())
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+	}
+	emitter.Emit(item)
+
+	return nil
+}
+
+func analyzeWebSocket(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	reqDetails := item.Pair.Request.Payload.(map[string]interface{})["details"].(map[string]interface{})
+	if upgrade, ok := reqDetails["upgrade"].(map[string]interface{}); ok && resolvedDestination.Name == "" {
+		resolvedDestination.Name = upgrade["host"].(string)
+	}
+
+	return analyzeUnanswered(item, resolvedSource, resolvedDestination)
+}
+
+func summarizeWebSocket(entry *api.Entry) (summary string, summaryQuery string, method string, methodQuery string, status int, statusQuery string) {
+	if upgrade, ok := entry.Request["upgrade"].(map[string]interface{}); ok {
+		summary = upgrade["path"].(string)
+		summaryQuery = fmt.Sprintf(`request.upgrade.path == "%s"`, summary)
+	}
+	method = entry.Request["opcode"].(string)
+	methodQuery = fmt.Sprintf(`request.opcode == "%s"`, method)
+	if closeCode, ok := entry.Request["closeCode"].(float64); ok {
+		status = int(closeCode)
+		statusQuery = fmt.Sprintf(`request.closeCode == %d`, status)
+	}
+	return
+}
+
+func representWebSocketMessage(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Opcode",
+			Value:    request["opcode"].(string),
+			Selector: `request.opcode`,
+		},
+		{
+			Name:     "Direction",
+			Value:    request["direction"].(string),
+			Selector: `request.direction`,
+		},
+		{
+			Name:     "Fragments",
+			Value:    request["fragments"].(float64),
+			Selector: `request.fragments`,
+		},
+		{
+			Name:     "Compressed",
+			Value:    request["compressed"].(bool),
+			Selector: `request.compressed`,
+		},
+		{
+			Name:     "Compressed Size (bytes)",
+			Value:    request["compressedSize"],
+			Selector: `request.compressedSize`,
+		},
+		{
+			Name:     "Payload Size (bytes)",
+			Value:    request["payloadSize"].(float64),
+			Selector: `request.payloadSize`,
+		},
+		{
+			Name:     "Close Code",
+			Value:    request["closeCode"],
+			Selector: `request.closeCode`,
+		},
+		{
+			Name:     "Close Reason",
+			Value:    request["closeReason"],
+			Selector: `request.closeReason`,
+		},
+		{
+			Name:     "Error",
+			Value:    request["error"],
+			Selector: `request.error`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if upgrade, ok := request["upgrade"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Upgrade Request",
+			Data:  representMapAsTable(upgrade, `request.upgrade`),
+		})
+	}
+
+	if request["opcode"] != webSocketOpcodes[webSocketOpClose] {
+		mimeType := ""
+		if request["opcode"] == webSocketOpcodes[webSocketOpText] {
+			mimeType = "text/plain"
+		}
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Payload",
+			Encoding: "base64",
+			MimeType: mimeType,
+			Data:     request["payload"].(string),
+			Selector: `request.payload`,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,247 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func webSocketFrame(fin bool, rsv1 bool, opcode byte, payload []byte, mask []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	if rsv1 {
+		first |= 0x40
+	}
+	frame := []byte{first}
+
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	if mask == nil {
+		return append(frame, payload...)
+	}
+	frame = append(frame, mask...)
+	for i, c := range payload {
+		frame = append(frame, c^mask[i%4])
+	}
+	return frame
+}
+
+func webSocketDeflate(t *testing.T, payload []byte) []byte {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	assert.Nil(t, err)
+	_, err = writer.Write(payload)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Flush())
+	// The sync flush marker is left out of the frame
+	return bytes.TrimSuffix(compressed.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+}
+
+func dissectConnection(t *testing.T, client []byte, server []byte) []*api.OutputChannelItem {
+	itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+	stream := NewTcpStream()
+	emitter := &api.Emitting{AppStats: &api.AppStats{}, Stream: stream, OutputChannel: itemChannel}
+	dissector := NewDissector()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+	counterPair := &api.CounterPair{}
+
+	sides := []struct {
+		data     []byte
+		tcpID    *api.TcpID
+		isClient bool
+	}{
+		{client, &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}, true},
+		{server, &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "2", DstPort: "1"}, false},
+	}
+	for _, side := range sides {
+		reader := NewTcpReader(&api.ReadProgress{}, "", side.tcpID, time.Time{}, stream, side.isClient, false, nil, emitter, counterPair, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader(side.data)), reader)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+	}
+
+	var items []*api.OutputChannelItem
+	for len(itemChannel) > 0 {
+		items = append(items, <-itemChannel)
+	}
+	return items
+}
+
+func TestDissectWebSocket(t *testing.T) {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+
+	var client bytes.Buffer
+	client.WriteString("GET /chat?room=1 HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Extensions: permessage-deflate\r\n\r\n")
+	client.Write(webSocketFrame(false, false, webSocketOpText, []byte("Hello, "), mask))
+	client.Write(webSocketFrame(true, false, webSocketOpPing, []byte("ping"), mask))
+	client.Write(webSocketFrame(true, false, webSocketOpContinuation, []byte("World!"), mask))
+	client.Write(webSocketFrame(true, true, webSocketOpText, webSocketDeflate(t, []byte(`{"compressed":"compressed"}`)), mask))
+
+	var server bytes.Buffer
+	server.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n" +
+		"Sec-WebSocket-Extensions: permessage-deflate\r\n\r\n")
+	server.Write(webSocketFrame(true, false, webSocketOpBinary, []byte{0x00, 0xff, 0x10}, nil))
+	server.Write(webSocketFrame(true, false, webSocketOpClose, append([]byte{0x03, 0xe8}, "bye"...), nil))
+
+	items := dissectConnection(t, client.Bytes(), server.Bytes())
+
+	var messages []*WebSocketMessage
+	for _, item := range items {
+		if item.Protocol.Abbreviation != webSocketProtocol.Abbreviation {
+			assert.Equal(t, http11protocol.Abbreviation, item.Protocol.Abbreviation)
+			continue
+		}
+		messages = append(messages, item.Pair.Request.Payload.(HTTPPayload).Data.(*WebSocketMessage))
+	}
+	if !assert.Len(t, messages, 4) {
+		return
+	}
+
+	assert.Equal(t, "text", messages[0].Opcode)
+	assert.Equal(t, "client", messages[0].Direction)
+	assert.Equal(t, 2, messages[0].Fragments)
+	assert.Equal(t, "Hello, World!", messages[0].PayloadText)
+	assert.Equal(t, "/chat", messages[0].Upgrade.Path)
+	assert.Equal(t, "example.com", messages[0].Upgrade.Host)
+	assert.Equal(t, "permessage-deflate", messages[0].Upgrade.Extensions)
+
+	assert.True(t, messages[1].Compressed)
+	assert.Empty(t, messages[1].Error)
+	assert.Equal(t, `{"compressed":"compressed"}`, messages[1].PayloadText)
+
+	assert.Equal(t, "binary", messages[2].Opcode)
+	assert.Equal(t, "server", messages[2].Direction)
+	assert.Equal(t, "AP8Q", messages[2].Payload)
+	assert.Equal(t, "/chat", messages[2].Upgrade.Path)
+
+	assert.Equal(t, "close", messages[3].Opcode)
+	assert.Equal(t, 1000, messages[3].CloseCode)
+	assert.Equal(t, "bye", messages[3].CloseReason)
+}
+
+func TestDissectWebSocketRefused(t *testing.T) {
+	// The server refuses the upgrade and the connection is kept alive
+	client := "GET /chat HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n" +
+		"GET /next HTTP/1.1\r\n" +
+		"Host: example.com\r\n\r\n"
+	server := "HTTP/1.1 400 Bad Request\r\n" +
+		"Content-Length: 0\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\n" +
+		"Content-Length: 2\r\n\r\n" +
+		"ok"
+
+	items := dissectConnection(t, []byte(client), []byte(server))
+	if !assert.Len(t, items, 2) {
+		return
+	}
+	for _, item := range items {
+		assert.Equal(t, http11protocol.Abbreviation, item.Protocol.Abbreviation)
+	}
+	assert.Equal(t, "/next", items[1].Pair.Request.Payload.(HTTPPayload).Data.(*http.Request).URL.Path)
+	assert.Equal(t, 200, items[1].Pair.Response.Payload.(HTTPPayload).Data.(*http.Response).StatusCode)
+}
+
+func TestDissectWebSocketContextTakeover(t *testing.T) {
+	mask := []byte{0xa1, 0xb2, 0xc3, 0xd4}
+	payload := []byte("the same message sent twice")
+
+	// The second message refers back to the first one
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	assert.Nil(t, err)
+	_, _ = writer.Write(payload)
+	assert.Nil(t, writer.Flush())
+	first := bytes.TrimSuffix(compressed.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+	firstLen := compressed.Len()
+	_, _ = writer.Write(payload)
+	assert.Nil(t, writer.Flush())
+	second := bytes.TrimSuffix(compressed.Bytes()[firstLen:], []byte{0x00, 0x00, 0xff, 0xff})
+
+	var client bytes.Buffer
+	client.WriteString("GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	client.Write(webSocketFrame(true, true, webSocketOpText, append([]byte{}, first...), mask))
+	client.Write(webSocketFrame(true, true, webSocketOpText, append([]byte{}, second...), mask))
+
+	items := dissectConnection(t, client.Bytes(), []byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+
+	var texts []string
+	for _, item := range items {
+		if item.Protocol.Abbreviation == webSocketProtocol.Abbreviation {
+			texts = append(texts, item.Pair.Request.Payload.(HTTPPayload).Data.(*WebSocketMessage).PayloadText)
+		}
+	}
+	assert.Equal(t, []string{string(payload), string(payload)}, texts)
+}
+
+func TestWebSocketEntry(t *testing.T) {
+	var client bytes.Buffer
+	client.WriteString("GET /feed HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	client.Write(webSocketFrame(true, false, webSocketOpClose, []byte{0x03, 0xe9}, []byte{1, 2, 3, 4}))
+
+	items := dissectConnection(t, client.Bytes(), nil)
+
+	dissector := NewDissector()
+	for _, item := range items {
+		if item.Protocol.Abbreviation != webSocketProtocol.Abbreviation {
+			continue
+		}
+		marshaled, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var unmarshaled *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(marshaled, &unmarshaled))
+
+		entry := dissector.Analyze(unmarshaled, &api.Resolution{}, &api.Resolution{})
+		assert.Equal(t, "example.com", entry.Destination.Name)
+
+		base := dissector.Summarize(entry)
+		assert.Equal(t, "/feed", base.Summary)
+		assert.Equal(t, "close", base.Method)
+		assert.Equal(t, 1001, base.Status)
+		assert.Equal(t, `request.closeCode == 1001`, base.StatusQuery)
+
+		_, err = dissector.Represent(entry.Request, entry.Response)
+		assert.Nil(t, err)
+		return
+	}
+	t.Fatal("no WebSocket entry")
+}
@@ -0,0 +1,109 @@
+// Package dissecttest dissects the two sides of a connection for the tests
+// of the extensions, and hands the results on the way the worker does.
+package dissecttest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// Order is the order in which the sides of a connection are read.
+type Order int
+
+const (
+	ClientFirst Order = iota
+	ServerFirst
+	// Concurrently is for the dissectors whose server side waits for the
+	// requests before reading the responses.
+	Concurrently
+)
+
+var (
+	tcpIDClient = &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}
+	tcpIDServer = &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "2", DstPort: "1"}
+)
+
+// Items dissects a connection and returns the items after a round trip
+// through JSON, as the worker sends them on. Each item is then checked the
+// way the worker goes on with it: it's analyzed, and its entry is
+// summarized and represented after a round trip of its own.
+func Items(t *testing.T, dissector api.Dissector, order Order, client []byte, server []byte) []*api.OutputChannelItem {
+	itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+	stream := NewTcpStream()
+	emitter := &api.Emitting{
+		AppStats:      &api.AppStats{},
+		Stream:        stream,
+		OutputChannel: itemChannel,
+	}
+	counterPair := &api.CounterPair{}
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	dissectSide := func(isClient bool) {
+		data, tcpID := server, tcpIDServer
+		if isClient {
+			data, tcpID = client, tcpIDClient
+		}
+		reader := NewTcpReader(&api.ReadProgress{}, "", tcpID, time.Time{}, stream, isClient, false, nil, emitter, counterPair, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader(data)), reader)
+		assert.NotNil(t, err)
+	}
+
+	switch order {
+	case ClientFirst:
+		dissectSide(true)
+		dissectSide(false)
+	case ServerFirst:
+		dissectSide(false)
+		dissectSide(true)
+	case Concurrently:
+		var wg sync.WaitGroup
+		wg.Add(2)
+		for _, isClient := range []bool{true, false} {
+			go func(isClient bool) {
+				defer wg.Done()
+				dissectSide(isClient)
+			}(isClient)
+		}
+		wg.Wait()
+	}
+
+	close(itemChannel)
+	var items []*api.OutputChannelItem
+	for item := range itemChannel {
+		var finalItem *api.OutputChannelItem
+		roundTrip(t, item, &finalItem)
+		items = append(items, finalItem)
+
+		entry := dissector.Analyze(finalItem, &api.Resolution{}, &api.Resolution{})
+		var finalEntry *api.Entry
+		roundTrip(t, entry, &finalEntry)
+		dissector.Summarize(finalEntry)
+		_, err := dissector.Represent(finalEntry.Request, finalEntry.Response)
+		assert.Nil(t, err)
+	}
+	return items
+}
+
+// Entries dissects a connection like Items does, and returns the entries
+// that its items are analyzed into.
+func Entries(t *testing.T, dissector api.Dissector, order Order, client []byte, server []byte) []*api.Entry {
+	var entries []*api.Entry
+	for _, item := range Items(t, dissector, order, client, server) {
+		entries = append(entries, dissector.Analyze(item, &api.Resolution{}, &api.Resolution{}))
+	}
+	return entries
+}
+
+func roundTrip(t *testing.T, v interface{}, final interface{}) {
+	data, err := json.Marshal(v)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(data, final))
+}
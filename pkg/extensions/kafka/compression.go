@@ -1,6 +1,10 @@
 package kafka
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+
 	"github.com/segmentio/kafka-go/compress"
 )
 
@@ -8,5 +12,22 @@ type Compression = compress.Compression
 
 type CompressionCodec = compress.Codec
 
-// TODO: this file should probably go away once the internals of the package
-// have moved to use the protocol package.
+// decompress decompresses the records of a batch with its codec. It reads at
+// most limit bytes and reports whether there were more.
+func decompress(compression Compression, data []byte, limit int) ([]byte, bool, error) {
+	codec := compression.Codec()
+	if codec == nil {
+		return nil, false, fmt.Errorf("unknown compression codec: %d", compression)
+	}
+
+	reader := codec.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(decompressed) > limit {
+		return decompressed[:limit], true, nil
+	}
+	return decompressed, false, nil
+}
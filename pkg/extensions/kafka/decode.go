@@ -6,7 +6,6 @@ import (
 	"hash/crc32"
 	"io"
 	"reflect"
-	"strings"
 
 	"github.com/rs/zerolog/log"
 )
@@ -22,6 +21,10 @@ type decoder struct {
 	err    error
 	table  *crc32.Table
 	crc32  uint32
+
+	// The size of the records decoded so far, which is capped by
+	// maxRecordsSize
+	recordsSize int
 }
 
 func (d *decoder) Read(b []byte) (int, error) {
@@ -107,51 +110,29 @@ func (d *decoder) decodeCompactArray(v value, elemType reflect.Type, decodeElem
 	}
 }
 
-func (d *decoder) decodeRecordV0(v value) {
-	x := &RecordV0{}
-	x.Unknown = d.readInt8()
-	x.Attributes = d.readInt8()
-	x.TimestampDelta = d.readInt8()
-	x.OffsetDelta = d.readInt8()
-
-	x.KeyLength = int8(d.readVarInt())
-	key := strings.Builder{}
-	for i := 0; i < int(x.KeyLength); i++ {
-		key.WriteString(fmt.Sprintf("%c", d.readInt8()))
+func (d *decoder) decodeRecords(v value, flexible bool) {
+	var size int
+	if flexible {
+		size = int(d.readUnsignedVarInt()) - 1
+	} else {
+		size = int(d.readInt32())
 	}
-	x.Key = key.String()
-
-	x.ValueLen = int8(d.readVarInt())
-	value := strings.Builder{}
-	for i := 0; i < int(x.ValueLen); i++ {
-		value.WriteString(fmt.Sprintf("%c", d.readInt8()))
+	if size < 0 || size > d.remain {
+		// A null record set, or a broken one
+		return
 	}
-	x.Value = value.String()
-
-	headerLen := d.readInt8() / 2
-	headers := make([]RecordHeader, 0)
-	for i := 0; i < int(headerLen); i++ {
-		header := &RecordHeader{}
-
-		header.HeaderKeyLength = int8(d.readVarInt())
-		headerKey := strings.Builder{}
-		for j := 0; j < int(header.HeaderKeyLength); j++ {
-			headerKey.WriteString(fmt.Sprintf("%c", d.readInt8()))
-		}
-		header.HeaderKey = headerKey.String()
-
-		header.HeaderValueLength = int8(d.readVarInt())
-		headerValue := strings.Builder{}
-		for j := 0; j < int(header.HeaderValueLength); j++ {
-			headerValue.WriteString(fmt.Sprintf("%c", d.readInt8()))
-		}
-		header.Value = headerValue.String()
+	x := readRecordSet(d.read(size), &d.recordsSize)
+	v.val.Set(valueOf(x).val)
+}
 
-		headers = append(headers, *header)
+// discardTaggedFields discards the tagged fields of the header of a flexible
+// message.
+func (d *decoder) discardTaggedFields() {
+	n := int(d.readUnsignedVarInt())
+	for i := 0; i < n && d.err == nil; i++ {
+		d.readUnsignedVarInt()
+		d.discard(int(d.readUnsignedVarInt()))
 	}
-	x.Headers = headers
-
-	v.val.Set(valueOf(x).val)
 }
 
 func (d *decoder) discardAll() {
@@ -331,6 +312,9 @@ func decodeFuncOf(typ reflect.Type, version int16, flexible bool, tag structTag)
 	if reflect.PtrTo(typ).Implements(readerFrom) {
 		return readerDecodeFuncOf(typ)
 	}
+	if typ == reflect.TypeOf(Records{}) {
+		return func(d *decoder, v value) { d.decodeRecords(v, flexible) }
+	}
 	switch typ.Kind() {
 	case reflect.Bool:
 		return (*decoder).decodeBool
@@ -384,10 +368,6 @@ func structDecodeFuncOf(typ reflect.Type, version int16, flexible bool) decodeFu
 	var fields []field
 	taggedFields := map[int]*field{}
 
-	if typ == reflect.TypeOf(RecordV0{}) {
-		return (*decoder).decodeRecordV0
-	}
-
 	forEachStructField(typ, func(typ reflect.Type, index index, tag string) {
 		forEachStructTag(tag, func(tag structTag) bool {
 			if tag.MinVersion <= version && version <= tag.MaxVersion {
@@ -421,15 +401,19 @@ func structDecodeFuncOf(typ reflect.Type, version int16, flexible bool) decodeFu
 			// for details of tag buffers in "flexible" messages.
 			n := int(d.readUnsignedVarInt())
 
-			for i := 0; i < n; i++ {
+			for i := 0; i < n && d.err == nil; i++ {
 				tagID := int(d.readUnsignedVarInt())
 				size := int(d.readUnsignedVarInt())
+				if size < 0 || size > d.remain {
+					d.setError(fmt.Errorf("cannot decode tagged field %d of %d bytes from input stream", tagID, size))
+					break
+				}
 
 				f, ok := taggedFields[tagID]
 				if ok {
 					f.decode(d, v.fieldByIndex(f.index))
 				} else {
-					d.read(size)
+					d.discard(size)
 				}
 			}
 		}
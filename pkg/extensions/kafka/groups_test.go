@@ -0,0 +1,289 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// noTaggedFields is an empty tagged fields section of a flexible message
+var noTaggedFields = []byte{0}
+
+func uvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func compactString(s string) []byte {
+	return append(uvarint(uint64(len(s))+1), s...)
+}
+
+func compactArray(n int) []byte {
+	return uvarint(uint64(n) + 1)
+}
+
+// compactNull is a null compact string, bytes or array
+var compactNull = []byte{0}
+
+func dissectMessages(t *testing.T, client []byte, server []byte) []*api.Entry {
+	dissector := NewDissector()
+	itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+	stream := NewTcpStream()
+	emitter := &api.Emitting{
+		AppStats:      &api.AppStats{},
+		Stream:        stream,
+		OutputChannel: itemChannel,
+	}
+	counterPair := &api.CounterPair{}
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+	reqResMatcher.SetMaxTry(10)
+
+	tcpIDClient := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}
+	reader := NewTcpReader(&api.ReadProgress{}, "", tcpIDClient, time.Time{}, stream, true, false, nil, emitter, counterPair, reqResMatcher)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(client)), reader))
+
+	tcpIDServer := &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "2", DstPort: "1"}
+	reader = NewTcpReader(&api.ReadProgress{}, "", tcpIDServer, time.Time{}, stream, false, false, nil, emitter, counterPair, reqResMatcher)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(server)), reader))
+
+	close(itemChannel)
+	var entries []*api.Entry
+	for item := range itemChannel {
+		data, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var finalItem *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(data, &finalItem))
+
+		entry := dissector.Analyze(finalItem, &api.Resolution{}, &api.Resolution{})
+		_, err = dissector.Represent(entry.Request, entry.Response)
+		assert.Nil(t, err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestDissectConsumerGroup(t *testing.T) {
+	apiVersions := sizedMessage(
+		int16Bytes(int16(ApiVersions)), int16Bytes(3), int32Bytes(1), kafkaString("consumer"), noTaggedFields,
+		compactString("librdkafka"), compactString("2.3.0"), noTaggedFields,
+	)
+	joinGroup := sizedMessage(
+		// A header with a tagged field, that's skipped
+		int16Bytes(int16(JoinGroup)), int16Bytes(9), int32Bytes(2), kafkaString("consumer"), []byte{1, 0, 2, 'a', 'b'},
+		compactString("billing"), int32Bytes(45000), int32Bytes(300000), compactString(""), compactNull, compactString("consumer"),
+		compactArray(1), compactString("range"), compactString("topics"), noTaggedFields,
+		compactNull, noTaggedFields,
+	)
+	syncGroup := sizedMessage(
+		int16Bytes(int16(SyncGroup)), int16Bytes(5), int32Bytes(3), kafkaString("consumer"), noTaggedFields,
+		compactString("billing"), int32Bytes(7), compactString("member-1"), compactNull, compactString("consumer"), compactString("range"),
+		compactArray(0), noTaggedFields,
+	)
+	heartbeat := sizedMessage(
+		int16Bytes(int16(Heartbeat)), int16Bytes(2), int32Bytes(4), kafkaString("consumer"),
+		kafkaString("billing"), int32Bytes(7), kafkaString("member-1"),
+	)
+	offsetCommit := sizedMessage(
+		int16Bytes(int16(OffsetCommit)), int16Bytes(8), int32Bytes(5), kafkaString("consumer"), noTaggedFields,
+		compactString("billing"), int32Bytes(7), compactString("member-1"), compactNull,
+		compactArray(1), compactString("invoices"),
+		compactArray(2),
+		int32Bytes(0), int64Bytes(42), int32Bytes(-1), compactString(""), noTaggedFields,
+		int32Bytes(1), int64Bytes(43), int32Bytes(-1), compactNull, noTaggedFields,
+		noTaggedFields,
+		noTaggedFields,
+	)
+
+	apiVersionsResponse := sizedMessage(
+		// The header of ApiVersions responses has no tagged fields
+		int32Bytes(1),
+		int16Bytes(0),
+		compactArray(2),
+		int16Bytes(int16(JoinGroup)), int16Bytes(0), int16Bytes(9), noTaggedFields,
+		int16Bytes(int16(OffsetCommit)), int16Bytes(0), int16Bytes(8), noTaggedFields,
+		int32Bytes(0), noTaggedFields,
+	)
+	joinGroupResponse := sizedMessage(
+		int32Bytes(2), noTaggedFields,
+		int32Bytes(0), int16Bytes(0), int32Bytes(7), compactString("consumer"), compactString("range"),
+		compactString("member-1"), []byte{0}, compactString("member-1"),
+		compactArray(1), compactString("member-1"), compactNull, compactString("topics"), noTaggedFields,
+		noTaggedFields,
+	)
+	syncGroupResponse := sizedMessage(
+		int32Bytes(3), noTaggedFields,
+		int32Bytes(0), int16Bytes(27), compactNull, compactNull, compactString(""), noTaggedFields,
+	)
+	heartbeatResponse := sizedMessage(
+		int32Bytes(4),
+		int32Bytes(0), int16Bytes(0),
+	)
+	offsetCommitResponse := sizedMessage(
+		int32Bytes(5), noTaggedFields,
+		int32Bytes(0),
+		compactArray(1), compactString("invoices"),
+		compactArray(2),
+		int32Bytes(0), int16Bytes(0), noTaggedFields,
+		int32Bytes(1), int16Bytes(22), noTaggedFields,
+		noTaggedFields,
+		noTaggedFields,
+	)
+
+	entries := dissectMessages(t,
+		bytes.Join([][]byte{apiVersions, joinGroup, syncGroup, heartbeat, offsetCommit}, nil),
+		bytes.Join([][]byte{apiVersionsResponse, joinGroupResponse, syncGroupResponse, heartbeatResponse, offsetCommitResponse}, nil),
+	)
+	if !assert.Len(t, entries, 5) {
+		return
+	}
+
+	dissector := NewDissector()
+	type summary struct {
+		method       string
+		summary      string
+		status       int
+		statusQuery  string
+		summaryQuery string
+	}
+	var summaries []summary
+	for _, entry := range entries {
+		base := dissector.Summarize(entry)
+		summaries = append(summaries, summary{base.Method, base.Summary, base.Status, base.StatusQuery, base.SummaryQuery})
+	}
+	assert.ElementsMatch(t, []summary{
+		{"ApiVersions", "consumer", 0, "", `request.clientID == "consumer"`},
+		{"JoinGroup", "billing", 0, "", `request.payload.groupId == "billing"`},
+		{"SyncGroup", "billing", 27, `response.payload.errorCode == 27`, `request.payload.groupId == "billing"`},
+		{"Heartbeat", "billing", 0, "", `request.payload.groupId == "billing"`},
+		{"OffsetCommit", "billing", 22, `response.payload.topics[0].partitions[1].errorCode == 22`, `request.payload.groupId == "billing"`},
+	}, summaries)
+
+	for _, entry := range entries {
+		request := entry.Request["payload"].(map[string]interface{})
+		response := entry.Response["payload"].(map[string]interface{})
+		switch entry.Request["apiKeyName"] {
+		case "ApiVersions":
+			assert.Equal(t, "librdkafka", request["clientSoftwareName"])
+			assert.Len(t, response["apiKeys"], 2)
+		case "JoinGroup":
+			assert.Equal(t, float64(300000), request["rebalanceTimeoutMs"])
+			assert.Equal(t, "range", request["protocols"].([]interface{})[0].(map[string]interface{})["name"])
+			assert.Equal(t, float64(7), response["generationId"])
+			assert.Equal(t, "member-1", response["leader"])
+			assert.Len(t, response["members"], 1)
+		case "OffsetCommit":
+			partitions := request["topics"].([]interface{})[0].(map[string]interface{})["partitions"].([]interface{})
+			assert.Len(t, partitions, 2)
+			assert.Equal(t, float64(43), partitions[1].(map[string]interface{})["committedOffset"])
+		}
+	}
+}
+
+func TestDissectTransaction(t *testing.T) {
+	initProducerId := sizedMessage(
+		int16Bytes(int16(InitProducerId)), int16Bytes(4), int32Bytes(1), kafkaString("producer"), noTaggedFields,
+		compactString("txn-1"), int32Bytes(60000), int64Bytes(-1), int16Bytes(-1), noTaggedFields,
+	)
+	addPartitionsToTxn := sizedMessage(
+		int16Bytes(int16(AddPartitionsToTxn)), int16Bytes(1), int32Bytes(2), kafkaString("producer"),
+		kafkaString("txn-1"), int64Bytes(1000), int16Bytes(0),
+		int32Bytes(1), kafkaString("orders"), int32Bytes(2), int32Bytes(0), int32Bytes(1),
+	)
+	endTxn := sizedMessage(
+		int16Bytes(int16(EndTxn)), int16Bytes(3), int32Bytes(3), kafkaString("producer"), noTaggedFields,
+		compactString("txn-1"), int64Bytes(1000), int16Bytes(0), []byte{1}, noTaggedFields,
+	)
+
+	initProducerIdResponse := sizedMessage(
+		int32Bytes(1), noTaggedFields,
+		int32Bytes(0), int16Bytes(0), int64Bytes(1000), int16Bytes(0), noTaggedFields,
+	)
+	addPartitionsToTxnResponse := sizedMessage(
+		int32Bytes(2),
+		int32Bytes(0),
+		int32Bytes(1), kafkaString("orders"), int32Bytes(2),
+		int32Bytes(0), int16Bytes(0),
+		int32Bytes(1), int16Bytes(0),
+	)
+	endTxnResponse := sizedMessage(
+		int32Bytes(3), noTaggedFields,
+		int32Bytes(0), int16Bytes(48), noTaggedFields,
+	)
+
+	entries := dissectMessages(t,
+		bytes.Join([][]byte{initProducerId, addPartitionsToTxn, endTxn}, nil),
+		bytes.Join([][]byte{initProducerIdResponse, addPartitionsToTxnResponse, endTxnResponse}, nil),
+	)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	dissector := NewDissector()
+	for _, entry := range entries {
+		base := dissector.Summarize(entry)
+		assert.Equal(t, "txn-1", base.Summary)
+
+		request := entry.Request["payload"].(map[string]interface{})
+		response := entry.Response["payload"].(map[string]interface{})
+		switch base.Method {
+		case "InitProducerId":
+			assert.Equal(t, float64(60000), request["transactionTimeoutMs"])
+			assert.Equal(t, float64(1000), response["producerId"])
+		case "AddPartitionsToTxn":
+			assert.Equal(t, []interface{}{float64(0), float64(1)}, request["topics"].([]interface{})[0].(map[string]interface{})["partitions"])
+			assert.Equal(t, 0, base.Status)
+		case "EndTxn":
+			assert.Equal(t, true, request["committed"])
+			assert.Equal(t, 48, base.Status)
+		}
+	}
+}
+
+func TestReadRequestUnsupportedVersion(t *testing.T) {
+	reqResMatcher := createResponseRequestMatcher().(*requestResponseMatcher)
+	tcpID := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}
+
+	// A version that's unknown isn't decoded as the latest flexible one
+	for _, version := range []int16{-252, 5} {
+		var headerTaggedFields []byte
+		if version > 0 {
+			headerTaggedFields = noTaggedFields
+		}
+		request := sizedMessage(
+			int16Bytes(int16(InitProducerId)), int16Bytes(version), int32Bytes(1), kafkaString("producer"), headerTaggedFields,
+			compactString("txn-1"), int32Bytes(60000), int64Bytes(-1), int16Bytes(-1), noTaggedFields,
+		)
+		_, _, err := ReadRequest(bytes.NewReader(request), tcpID, &api.CounterPair{}, time.Time{}, reqResMatcher)
+		assert.EqualError(t, err, fmt.Sprintf("unsupported version of InitProducerIdRequest: %d", version))
+	}
+}
+
+func TestReadRequestTaggedFieldTooLong(t *testing.T) {
+	reqResMatcher := createResponseRequestMatcher().(*requestResponseMatcher)
+	tcpID := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}
+
+	// An unknown tagged field whose size is beyond the message
+	for _, size := range []uint64{0xffffffffffffffff, 1 << 40, 100} {
+		request := sizedMessage(
+			int16Bytes(int16(Heartbeat)), int16Bytes(4), int32Bytes(1), kafkaString("consumer"), noTaggedFields,
+			compactString("billing"), int32Bytes(3), compactString("member-1"), compactNull,
+			uvarint(1), uvarint(7), uvarint(size),
+		)
+		apiKey, _, err := ReadRequest(bytes.NewReader(request), tcpID, &api.CounterPair{}, time.Time{}, reqResMatcher)
+		assert.Nil(t, err)
+		assert.Equal(t, Heartbeat, apiKey)
+
+		stored, ok := reqResMatcher.openMessagesMap.LoadAndDelete("1_1_2_2_1")
+		if assert.True(t, ok) {
+			assert.Equal(t, "billing", stored.(*Request).Payload.(*HeartbeatRequest).GroupId)
+		}
+	}
+}
@@ -13,8 +13,6 @@ import (
 
 	"github.com/fatih/camelcase"
 	"github.com/kubeshark/worker/pkg/api"
-	"github.com/ohler55/ojg/jp"
-	"github.com/ohler55/ojg/oj"
 )
 
 type KafkaPayload struct {
@@ -332,76 +330,64 @@ func representProduceRequest(data map[string]interface{}) []interface{} {
 	})
 
 	if topicData != nil {
-		for _, _topic := range topicData.([]interface{}) {
+		for i, _topic := range topicData.([]interface{}) {
 			topic := _topic.(map[string]interface{})
 			topicName := topic["topic"].(string)
-			partitions := topic["partitions"].(map[string]interface{})
-			partitionsJson, err := json.Marshal(partitions)
-			if err != nil {
-				return rep
+			if topic["partitions"] == nil {
+				continue
+			}
+			for j, _partition := range topic["partitions"].([]interface{}) {
+				partition := _partition.(map[string]interface{})
+				rep = representRecords(
+					rep,
+					partition["records"].(map[string]interface{}),
+					fmt.Sprintf(`request.payload.topicData[%d].partitions[%d].records`, i, j),
+					fmt.Sprintf("(topic: %s, partition: %d)", topicName, int(partition["index"].(float64))),
+				)
 			}
+		}
+	}
 
-			repPartitions, _ := json.Marshal([]api.TableData{
-				{
-					Name:     "Length",
-					Value:    partitions["length"],
-					Selector: `request.payload.transactionalID`,
-				},
-			})
+	return rep
+}
+
+// representRecords represents the batches of a record set, with a table and
+// a body for the value of each record.
+func representRecords(rep []interface{}, records map[string]interface{}, selectorPrefix string, titleSuffix string) []interface{} {
+	if records["recordBatches"] == nil {
+		return rep
+	}
+	for i, _batch := range records["recordBatches"].([]interface{}) {
+		batch := _batch.(map[string]interface{})
+		batchSelector := fmt.Sprintf(`%s.recordBatches[%d]`, selectorPrefix, i)
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: fmt.Sprintf("Record Batch [%d] %s", i, titleSuffix),
+			Data:  representMapAsTable(batch, batchSelector, []string{"record"}),
+		})
+
+		if batch["record"] == nil {
+			continue
+		}
+		for j, _record := range batch["record"].([]interface{}) {
+			record := _record.(map[string]interface{})
+			recordSelector := fmt.Sprintf(`%s.record[%d]`, batchSelector, j)
+			encoding, _ := record["encoding"].(string)
 			rep = append(rep, api.SectionData{
 				Type:  api.TABLE,
-				Title: fmt.Sprintf("Partitions (topic: %s)", topicName),
-				Data:  string(repPartitions),
+				Title: fmt.Sprintf("Record Batch [%d] Record [%d] %s", i, j, titleSuffix),
+				Data:  representMapAsTable(record, recordSelector, []string{"value"}),
 			})
 
-			obj, err := oj.ParseString(string(partitionsJson))
-			if err != nil {
-				return rep
-			}
-			recordBatchPath, err := jp.ParseString(`partitionData.records.recordBatch`)
-			if err != nil {
-				return rep
-			}
-			recordBatchresults := recordBatchPath.Get(obj)
-			if len(recordBatchresults) > 0 {
-				rep = append(rep, api.SectionData{
-					Type:  api.TABLE,
-					Title: fmt.Sprintf("Record Batch (topic: %s)", topicName),
-					Data:  representMapAsTable(recordBatchresults[0].(map[string]interface{}), `request.payload.topicData.partitions.partitionData.records.recordBatch`, []string{"record"}),
-				})
-			}
-
-			recordsPath, err := jp.ParseString(`partitionData.records.recordBatch.record`)
-			if err != nil {
-				return rep
-			}
-			recordsResults := recordsPath.Get(obj)
-			if len(recordsResults) > 0 {
-				if recordsResults[0] != nil {
-					records := recordsResults[0].([]interface{})
-					for i, _record := range records {
-						record := _record.(map[string]interface{})
-						value := record["value"]
-						delete(record, "value")
-
-						rep = append(rep, api.SectionData{
-							Type:  api.TABLE,
-							Title: fmt.Sprintf("Record [%d] Details (topic: %s)", i, topicName),
-							Data:  representMapAsTable(record, fmt.Sprintf(`request.payload.topicData.partitions.partitionData.records.recordBatch.record[%d]`, i), []string{"value"}),
-						})
-
-						rep = append(rep, api.SectionData{
-							Type:     api.BODY,
-							Title:    fmt.Sprintf("Record [%d] Value", i),
-							Data:     value.(string),
-							Selector: fmt.Sprintf(`request.payload.topicData.partitions.partitionData.records.recordBatch.record[%d].value`, i),
-						})
-					}
-				}
-			}
+			rep = append(rep, api.SectionData{
+				Type:     api.BODY,
+				Title:    fmt.Sprintf("Record Batch [%d] Record [%d] Value %s", i, j, titleSuffix),
+				Encoding: encoding,
+				Data:     record["value"].(string),
+				Selector: fmt.Sprintf(`%s.value`, recordSelector),
+			})
 		}
 	}
-
 	return rep
 }
 
@@ -618,32 +604,12 @@ func representFetchResponse(data map[string]interface{}) []interface{} {
 					Data:  representMapAsTable(partitionResponse, fmt.Sprintf(`response.payload.responses[%d].partitionResponses[%d]`, i, j), []string{"recordSet"}),
 				})
 
-				recordBatch := recordSet["recordBatch"].(map[string]interface{})
-				rep = append(rep, api.SectionData{
-					Type:  api.TABLE,
-					Title: fmt.Sprintf("Response [%d] Partition Response [%d] Record Batch (topic: %s)", i, j, topicName),
-					Data:  representMapAsTable(recordBatch, fmt.Sprintf(`response.payload.responses[%d].partitionResponses[%d].recordSet.recordBatch`, i, j), []string{"record"}),
-				})
-
-				if recordBatch["record"] != nil {
-					for k, _record := range recordBatch["record"].([]interface{}) {
-						record := _record.(map[string]interface{})
-						value := record["value"]
-
-						rep = append(rep, api.SectionData{
-							Type:  api.TABLE,
-							Title: fmt.Sprintf("Response [%d] Partition Response [%d] Record [%d] (topic: %s)", i, j, k, topicName),
-							Data:  representMapAsTable(record, fmt.Sprintf(`response.payload.responses[%d].partitionResponses[%d].recordSet.recordBatch.record[%d]`, i, j, k), []string{"value"}),
-						})
-
-						rep = append(rep, api.SectionData{
-							Type:     api.BODY,
-							Title:    fmt.Sprintf("Response [%d] Partition Response [%d] Record [%d] Value (topic: %s)", i, j, k, topicName),
-							Data:     value.(string),
-							Selector: fmt.Sprintf(`response.payload.responses[%d].partitionResponses[%d].recordSet.recordBatch.record[%d].value`, i, j, k),
-						})
-					}
-				}
+				rep = representRecords(
+					rep,
+					recordSet,
+					fmt.Sprintf(`response.payload.responses[%d].partitionResponses[%d].recordSet`, i, j),
+					fmt.Sprintf("(topic: %s, partition: %d)", topicName, int(partitionResponse["partition"].(float64))),
+				)
 			}
 		}
 	}
@@ -872,6 +838,74 @@ func representDeleteTopicsResponse(data map[string]interface{}) []interface{} {
 	return rep
 }
 
+// representRequest represents a request whose payload has no representation
+// of its own, as a table of its fields.
+func representRequest(data map[string]interface{}) []interface{} {
+	rep := make([]interface{}, 0)
+
+	rep = representRequestHeader(data, rep)
+
+	if payload, ok := data["payload"].(map[string]interface{}); ok {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Payload",
+			Data:  representMapAsTable(payload, `request.payload`, nil),
+		})
+	}
+
+	return rep
+}
+
+// representResponse represents a response whose payload has no representation
+// of its own, as a table of its fields.
+func representResponse(data map[string]interface{}) []interface{} {
+	rep := make([]interface{}, 0)
+
+	rep = representResponseHeader(data, rep)
+
+	if payload, ok := data["payload"].(map[string]interface{}); ok {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Payload",
+			Data:  representMapAsTable(payload, `response.payload`, nil),
+		})
+	}
+
+	return rep
+}
+
+// findErrorCode finds the first error code of a payload that isn't 0, either
+// the one of the whole payload or the one of an element of its arrays, such
+// as a partition whose offset couldn't be committed. It returns the error
+// code and its selector.
+func findErrorCode(data interface{}, selector string) (int, string) {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		for _, key := range []string{"errorCode", "partitionErrorCode"} {
+			if errorCode, ok := data[key].(float64); ok && errorCode != 0 {
+				return int(errorCode), fmt.Sprintf("%s.%s", selector, key)
+			}
+		}
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if errorCode, s := findErrorCode(data[key], fmt.Sprintf("%s.%s", selector, key)); errorCode != 0 {
+				return errorCode, s
+			}
+		}
+	case []interface{}:
+		for i, element := range data {
+			if errorCode, s := findErrorCode(element, fmt.Sprintf("%s[%d]", selector, i)); errorCode != 0 {
+				return errorCode, s
+			}
+		}
+	}
+	return 0, ""
+}
+
 func contains(s []string, str string) bool {
 	for _, v := range s {
 		if v == str {
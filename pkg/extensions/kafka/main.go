@@ -173,6 +173,58 @@ func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
 			summary = summary[:len(summary)-2]
 			summaryQuery = summaryQuery[:len(summaryQuery)-4]
 		}
+	case FindCoordinator:
+		payload := entry.Request["payload"].(map[string]interface{})
+		if key, ok := payload["key"].(string); ok {
+			summary = key
+			summaryQuery = fmt.Sprintf(`request.payload.key == "%s"`, summary)
+			break
+		}
+		_keys := payload["coordinatorKeys"]
+		if _keys == nil {
+			break
+		}
+		keys := _keys.([]interface{})
+		for i, key := range keys {
+			summary += fmt.Sprintf("%s, ", key.(string))
+			summaryQuery += fmt.Sprintf(`request.payload.coordinatorKeys[%d] == "%s" and `, i, key.(string))
+		}
+		if len(summary) > 0 {
+			summary = summary[:len(summary)-2]
+			summaryQuery = summaryQuery[:len(summaryQuery)-5]
+		}
+	case JoinGroup, SyncGroup, Heartbeat, LeaveGroup, OffsetCommit:
+		summary = entry.Request["payload"].(map[string]interface{})["groupId"].(string)
+		summaryQuery = fmt.Sprintf(`request.payload.groupId == "%s"`, summary)
+	case OffsetFetch:
+		payload := entry.Request["payload"].(map[string]interface{})
+		if groupId, ok := payload["groupId"].(string); ok {
+			summary = groupId
+			summaryQuery = fmt.Sprintf(`request.payload.groupId == "%s"`, summary)
+			break
+		}
+		_groups := payload["groups"]
+		if _groups == nil {
+			break
+		}
+		groups := _groups.([]interface{})
+		for i, group := range groups {
+			groupId := group.(map[string]interface{})["groupId"].(string)
+			summary += fmt.Sprintf("%s, ", groupId)
+			summaryQuery += fmt.Sprintf(`request.payload.groups[%d].groupId == "%s" and `, i, groupId)
+		}
+		if len(summary) > 0 {
+			summary = summary[:len(summary)-2]
+			summaryQuery = summaryQuery[:len(summaryQuery)-5]
+		}
+	case InitProducerId, AddPartitionsToTxn, AddOffsetsToTxn, EndTxn, TxnOffsetCommit:
+		summary = entry.Request["payload"].(map[string]interface{})["transactionalId"].(string)
+		summaryQuery = fmt.Sprintf(`request.payload.transactionalId == "%s"`, summary)
+	}
+
+	if errorCode, selector := findErrorCode(entry.Response["payload"], "response.payload"); errorCode != 0 {
+		status = errorCode
+		statusQuery = fmt.Sprintf(`%s == %d`, selector, errorCode)
 	}
 
 	return &api.BaseEntry{
@@ -228,6 +280,9 @@ func (d dissecting) Represent(request map[string]interface{}, response map[strin
 	case DeleteTopics:
 		repRequest = representDeleteTopicsRequest(request)
 		repResponse = representDeleteTopicsResponse(response)
+	default:
+		repRequest = representRequest(request)
+		repResponse = representResponse(response)
 	}
 
 	representation["request"] = repRequest
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 )
@@ -29,7 +30,7 @@ func (k ApiKey) String() string {
 }
 
 const (
-	// v0  = 0
+	v0  = 0
 	v1  = 1
 	v2  = 2
 	v3  = 3
@@ -41,6 +42,7 @@ const (
 	v9  = 9
 	v10 = 10
 	v11 = 11
+	v12 = 12
 )
 
 const (
@@ -151,6 +153,64 @@ var apiNames = [numApis]string{
 	AlterClientQuotas:           "AlterClientQuotas",
 }
 
+// flexibleVersions are the first versions of the APIs that are flexible, whose
+// headers have tagged fields and whose strings and arrays are compact.
+var flexibleVersions = map[ApiKey]int16{
+	Produce:                     v9,
+	Fetch:                       v12,
+	ListOffsets:                 v6,
+	Metadata:                    v9,
+	LeaderAndIsr:                v4,
+	StopReplica:                 v2,
+	UpdateMetadata:              v6,
+	ControlledShutdown:          v3,
+	OffsetCommit:                v8,
+	OffsetFetch:                 v6,
+	FindCoordinator:             v3,
+	JoinGroup:                   v6,
+	Heartbeat:                   v4,
+	LeaveGroup:                  v4,
+	SyncGroup:                   v4,
+	DescribeGroups:              v5,
+	ListGroups:                  v3,
+	ApiVersions:                 v3,
+	CreateTopics:                v5,
+	DeleteTopics:                v4,
+	DeleteRecords:               v2,
+	InitProducerId:              v2,
+	OffsetForLeaderEpoch:        v4,
+	AddPartitionsToTxn:          v3,
+	AddOffsetsToTxn:             v3,
+	EndTxn:                      v3,
+	WriteTxnMarkers:             v1,
+	TxnOffsetCommit:             v3,
+	DescribeAcls:                v2,
+	CreateAcls:                  v2,
+	DeleteAcls:                  v2,
+	DescribeConfigs:             v4,
+	AlterConfigs:                v2,
+	AlterReplicaLogDirs:         v2,
+	DescribeLogDirs:             v2,
+	SaslAuthenticate:            v2,
+	CreatePartitions:            v2,
+	CreateDelegationToken:       v2,
+	RenewDelegationToken:        v2,
+	ExpireDelegationToken:       v2,
+	DescribeDelegationToken:     v2,
+	DeleteGroups:                v2,
+	ElectLeaders:                v2,
+	IncrementalAlterConfigs:     v1,
+	AlterPartitionReassignments: v0,
+	ListPartitionReassignments:  v0,
+	DescribeClientQuotas:        v1,
+	AlterClientQuotas:           v1,
+}
+
+func isFlexible(apiKey ApiKey, apiVersion int16) bool {
+	version, ok := flexibleVersions[apiKey]
+	return ok && apiVersion >= version
+}
+
 type messageType struct {
 	version  int16
 	flexible bool
@@ -158,7 +218,18 @@ type messageType struct {
 	decode   decodeFunc
 }
 
+// The message types are made by reflection once for each Go type
+var messageTypes sync.Map
+
 func makeTypes(t reflect.Type) []messageType {
+	if types, ok := messageTypes.Load(t); ok {
+		return types.([]messageType)
+	}
+	types, _ := messageTypes.LoadOrStore(t, buildTypes(t))
+	return types.([]messageType)
+}
+
+func buildTypes(t reflect.Type) []messageType {
 	minVersion := int16(-1)
 	maxVersion := int16(-1)
 
@@ -197,6 +268,20 @@ func makeTypes(t reflect.Type) []messageType {
 	return types
 }
 
+// decodeMessage decodes a message whose fields are tagged with the versions
+// that they're in. Versions that aren't known are an error, since their
+// fields can't be told.
+func decodeMessage(d *decoder, message interface{}, version int16) (interface{}, error) {
+	t := reflect.TypeOf(message).Elem()
+	for _, mt := range makeTypes(t) {
+		if mt.version == version {
+			mt.decode(d, valueOf(message))
+			return message, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported version of %s: %d", t.Name(), version)
+}
+
 type structTag struct {
 	MinVersion int16
 	MaxVersion int16
@@ -0,0 +1,325 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/segmentio/kafka-go/compress"
+)
+
+var maxRecordsSize = 4 * 1024 * 1024
+
+// SetMaxRecordsSize sets the size of the records that are decoded for an
+// entry, past which the records are cut short.
+func SetMaxRecordsSize(size int) {
+	maxRecordsSize = size
+}
+
+// The offset and the size that precede every batch or legacy message, and
+// the offset of the magic that follows them
+const (
+	logOverhead = 12
+	magicOffset = 16
+)
+
+// The bits of the attributes of a batch. Legacy messages only have the
+// compression codec, and the timestamp type since v1.
+const (
+	compressionCodecMask = 0x07
+	timestampTypeMask    = 0x08
+	transactionalMask    = 0x10
+	controlMask          = 0x20
+)
+
+var errShortRecords = errors.New("records are shorter than their declared size")
+
+// readRecordSet reads the batches of a record set, which can end with a
+// partial batch in a Fetch response. recordsSize is the size of the records
+// decoded so far for the entry.
+func readRecordSet(b []byte, recordsSize *int) *Records {
+	records := &Records{RecordBatches: []RecordBatch{}}
+	for len(b) > 0 {
+		if len(b) <= magicOffset {
+			records.PartialTrailingBatch = true
+			break
+		}
+		length := int32(binary.BigEndian.Uint32(b[8:logOverhead]))
+		if length < 0 || int(length) > len(b)-logOverhead {
+			records.PartialTrailingBatch = true
+			break
+		}
+		data := b[:logOverhead+int(length)]
+		b = b[logOverhead+int(length):]
+
+		var batch RecordBatch
+		switch magic := int8(data[magicOffset]); magic {
+		case 0, 1:
+			batch = readLegacyMessage(data, recordsSize)
+		case 2:
+			batch = readRecordBatch(data, recordsSize)
+		default:
+			batch = RecordBatch{
+				BaseOffset:  int64(binary.BigEndian.Uint64(data)),
+				BatchLength: length,
+				Magic:       magic,
+				Error:       fmt.Sprintf("unknown magic: %d", magic),
+			}
+		}
+		records.RecordBatches = append(records.RecordBatches, batch)
+	}
+	return records
+}
+
+// readRecordBatch reads a batch of records, which follow its header and may
+// be compressed.
+func readRecordBatch(data []byte, recordsSize *int) (batch RecordBatch) {
+	d := &decoder{reader: bytes.NewReader(data), remain: len(data)}
+	batch.BaseOffset = d.readInt64()
+	batch.BatchLength = d.readInt32()
+	batch.PartitionLeaderEpoch = d.readInt32()
+	batch.Magic = d.readInt8()
+	batch.Crc = d.readInt32()
+	batch.Attributes = d.readInt16()
+	batch.LastOffsetDelta = d.readInt32()
+	batch.FirstTimestamp = d.readInt64()
+	batch.MaxTimestamp = d.readInt64()
+	batch.ProducerId = d.readInt64()
+	batch.ProducerEpoch = d.readInt16()
+	batch.BaseSequence = d.readInt32()
+	batch.NumRecords = d.readInt32()
+	batch.setAttributes()
+	batch.Record = []Record{}
+	if d.err != nil {
+		batch.Error = errShortRecords.Error()
+		return
+	}
+
+	records, err := batch.decompress(d.read(d.remain), recordsSize)
+	if err != nil {
+		batch.Error = err.Error()
+		return
+	}
+
+	d = &decoder{reader: bytes.NewReader(records), remain: len(records)}
+	for i := int32(0); i < batch.NumRecords; i++ {
+		length := d.readVarInt()
+		if d.err != nil || length < 0 || length > int64(d.remain) {
+			if !batch.Truncated {
+				batch.Error = errShortRecords.Error()
+			}
+			return
+		}
+		record, err := readRecord(d.read(int(length)))
+		if err != nil {
+			batch.Error = err.Error()
+			return
+		}
+		record.Offset = batch.BaseOffset + record.OffsetDelta
+		if batch.TimestampType == "LogAppendTime" {
+			record.Timestamp = batch.MaxTimestamp
+		} else {
+			record.Timestamp = batch.FirstTimestamp + record.TimestampDelta
+		}
+		batch.Record = append(batch.Record, record)
+	}
+	return
+}
+
+func readRecord(data []byte) (record Record, err error) {
+	d := &decoder{reader: bytes.NewReader(data), remain: len(data)}
+	record.Attributes = d.readInt8()
+	record.TimestampDelta = d.readVarInt()
+	record.OffsetDelta = d.readVarInt()
+	record.setKeyAndValue(d.readVarBytes(), d.readVarBytes())
+
+	n := d.readVarInt()
+	if n < 0 || n > int64(d.remain) {
+		return record, errShortRecords
+	}
+	record.Headers = make([]RecordHeader, 0, n)
+	for i := int64(0); i < n; i++ {
+		record.Headers = append(record.Headers, RecordHeader{
+			Key:   string(d.readVarBytes()),
+			Value: string(d.readVarBytes()),
+		})
+	}
+
+	if d.err != nil {
+		return record, errShortRecords
+	}
+	return record, nil
+}
+
+// readLegacyMessage reads a message of a legacy message set. A compressed
+// message wraps a message set, whose messages become the records of the
+// batch.
+func readLegacyMessage(data []byte, recordsSize *int) (batch RecordBatch) {
+	d := &decoder{reader: bytes.NewReader(data), remain: len(data)}
+	batch.BaseOffset = d.readInt64()
+	batch.BatchLength = d.readInt32()
+	batch.Crc = d.readInt32()
+	batch.Magic = d.readInt8()
+	batch.Attributes = int16(d.readInt8())
+	batch.PartitionLeaderEpoch = -1
+	batch.ProducerId = -1
+	batch.ProducerEpoch = -1
+	batch.BaseSequence = -1
+	batch.FirstTimestamp = -1
+	if batch.Magic == 1 {
+		batch.FirstTimestamp = d.readInt64()
+	}
+	batch.MaxTimestamp = batch.FirstTimestamp
+	batch.setAttributes()
+	batch.Record = []Record{}
+	key := d.readLegacyBytes()
+	value := d.readLegacyBytes()
+	if d.err != nil {
+		batch.Error = errShortRecords.Error()
+		return
+	}
+
+	if Compression(batch.Attributes&compressionCodecMask) == compress.None {
+		if len(key)+len(value) > maxRecordsSize-*recordsSize {
+			batch.Truncated = true
+			return
+		}
+		*recordsSize += len(key) + len(value)
+		record := Record{
+			Attributes: int8(batch.Attributes),
+			Timestamp:  batch.FirstTimestamp,
+			Offset:     batch.BaseOffset,
+			Headers:    []RecordHeader{},
+		}
+		record.setKeyAndValue(key, value)
+		batch.NumRecords = 1
+		batch.Record = append(batch.Record, record)
+		return
+	}
+
+	messages, err := batch.decompress(value, recordsSize)
+	if err != nil {
+		batch.Error = err.Error()
+		return
+	}
+	for len(messages) > magicOffset {
+		length := int32(binary.BigEndian.Uint32(messages[8:logOverhead]))
+		if length < 0 || int(length) > len(messages)-logOverhead {
+			break
+		}
+		// Its size was already capped when it was decompressed
+		var size int
+		message := readLegacyMessage(messages[:logOverhead+int(length)], &size)
+		messages = messages[logOverhead+int(length):]
+		if message.Error != "" {
+			batch.Error = message.Error
+			return
+		}
+		batch.Record = append(batch.Record, message.Record...)
+	}
+	if len(messages) > 0 && !batch.Truncated {
+		batch.Error = errShortRecords.Error()
+	}
+
+	batch.NumRecords = int32(len(batch.Record))
+	if batch.NumRecords == 0 {
+		return
+	}
+	// The offsets of the wrapped messages are relative to the last one since
+	// v1, whose absolute offset is the one of the wrapper
+	last := batch.Record[len(batch.Record)-1].Offset
+	for i := range batch.Record {
+		record := &batch.Record[i]
+		if batch.Magic == 1 && !batch.Truncated {
+			record.Offset += batch.BaseOffset - last
+		}
+		if batch.TimestampType == "LogAppendTime" {
+			record.Timestamp = batch.MaxTimestamp
+		}
+	}
+	batch.BaseOffset = batch.Record[0].Offset
+	batch.FirstTimestamp = batch.Record[0].Timestamp
+	for i := range batch.Record {
+		record := &batch.Record[i]
+		record.OffsetDelta = record.Offset - batch.BaseOffset
+		record.TimestampDelta = record.Timestamp - batch.FirstTimestamp
+	}
+	batch.LastOffsetDelta = int32(batch.Record[len(batch.Record)-1].OffsetDelta)
+	return
+}
+
+func (b *RecordBatch) setAttributes() {
+	b.Compression = Compression(b.Attributes & compressionCodecMask).String()
+	b.TimestampType = "CreateTime"
+	if b.Magic > 0 && b.Attributes&timestampTypeMask != 0 {
+		b.TimestampType = "LogAppendTime"
+	}
+	if b.Magic > 1 {
+		b.Transactional = b.Attributes&transactionalMask != 0
+		b.Control = b.Attributes&controlMask != 0
+	}
+}
+
+// decompress decompresses the records of a batch, if they're compressed, and
+// cuts them short once the records of the entry reach maxRecordsSize.
+func (b *RecordBatch) decompress(records []byte, recordsSize *int) ([]byte, error) {
+	limit := maxRecordsSize - *recordsSize
+	if limit < 0 {
+		limit = 0
+	}
+
+	if compression := Compression(b.Attributes & compressionCodecMask); compression != compress.None {
+		var err error
+		if records, b.Truncated, err = decompress(compression, records, limit); err != nil {
+			return nil, err
+		}
+	} else if len(records) > limit {
+		records, b.Truncated = records[:limit], true
+	}
+	*recordsSize += len(records)
+	return records, nil
+}
+
+// setKeyAndValue sets the key and the value, which are both base64 encoded if
+// either isn't valid UTF-8.
+func (r *Record) setKeyAndValue(key []byte, value []byte) {
+	if utf8.Valid(key) && utf8.Valid(value) {
+		r.Key = string(key)
+		r.Value = string(value)
+		return
+	}
+	r.Key = base64.StdEncoding.EncodeToString(key)
+	r.Value = base64.StdEncoding.EncodeToString(value)
+	r.Encoding = "base64"
+}
+
+// readVarBytes reads bytes that are prefixed by their varint length, which
+// is -1 for null.
+func (d *decoder) readVarBytes() []byte {
+	n := d.readVarInt()
+	if n < 0 {
+		return nil
+	}
+	if n > int64(d.remain) {
+		d.setError(errShortRecords)
+		return nil
+	}
+	return d.read(int(n))
+}
+
+// readLegacyBytes reads bytes that are prefixed by their int32 length, which
+// is -1 for null.
+func (d *decoder) readLegacyBytes() []byte {
+	n := d.readInt32()
+	if n < 0 {
+		return nil
+	}
+	if int(n) > d.remain {
+		d.setError(errShortRecords)
+		return nil
+	}
+	return d.read(int(n))
+}
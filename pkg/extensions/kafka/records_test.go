@@ -0,0 +1,336 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/segmentio/kafka-go/compress"
+	"github.com/stretchr/testify/assert"
+)
+
+func varint(v int64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutVarint(b, v)]
+}
+
+func varBytes(s string) []byte {
+	if s == "" {
+		return varint(-1)
+	}
+	return append(varint(int64(len(s))), s...)
+}
+
+// encodeRecord encodes a record of a v2 batch, with its headers given as
+// pairs of keys and values.
+func encodeRecord(offsetDelta int64, timestampDelta int64, key string, value string, headers ...string) []byte {
+	b := []byte{0}
+	b = append(b, varint(timestampDelta)...)
+	b = append(b, varint(offsetDelta)...)
+	b = append(b, varBytes(key)...)
+	b = append(b, varBytes(value)...)
+	b = append(b, varint(int64(len(headers)/2))...)
+	for _, header := range headers {
+		b = append(b, varBytes(header)...)
+	}
+	return append(varint(int64(len(b))), b...)
+}
+
+func compressed(codec compress.Compression, data []byte) []byte {
+	var buf bytes.Buffer
+	w := codec.Codec().NewWriter(&buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func encodeBatch(baseOffset int64, codec compress.Compression, firstTimestamp int64, records ...[]byte) []byte {
+	var data []byte
+	for _, record := range records {
+		data = append(data, record...)
+	}
+	if codec != compress.None {
+		data = compressed(codec, data)
+	}
+
+	b := make([]byte, 61)
+	binary.BigEndian.PutUint64(b[0:], uint64(baseOffset))
+	binary.BigEndian.PutUint32(b[8:], uint32(49+len(data)))
+	binary.BigEndian.PutUint32(b[12:], 0)
+	b[16] = 2
+	binary.BigEndian.PutUint16(b[21:], uint16(codec))
+	binary.BigEndian.PutUint32(b[23:], uint32(len(records)-1))
+	binary.BigEndian.PutUint64(b[27:], uint64(firstTimestamp))
+	binary.BigEndian.PutUint64(b[35:], uint64(firstTimestamp))
+	binary.BigEndian.PutUint64(b[43:], ^uint64(0))
+	binary.BigEndian.PutUint16(b[51:], 0xffff)
+	binary.BigEndian.PutUint32(b[53:], 0xffffffff)
+	binary.BigEndian.PutUint32(b[57:], uint32(len(records)))
+	return append(b, data...)
+}
+
+func legacyBytes(s string) []byte {
+	b := make([]byte, 4)
+	if s == "" {
+		binary.BigEndian.PutUint32(b, 0xffffffff)
+		return b
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func encodeLegacyMessage(offset int64, magic int8, codec compress.Compression, timestamp int64, key string, value string) []byte {
+	b := []byte{0, 0, 0, 0, byte(magic), byte(codec)}
+	if magic == 1 {
+		b = append(b, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(b[6:], uint64(timestamp))
+	}
+	b = append(b, legacyBytes(key)...)
+	b = append(b, legacyBytes(value)...)
+
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint64(header, uint64(offset))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(b)))
+	return append(header, b...)
+}
+
+func TestReadRecordBatch(t *testing.T) {
+	for _, codec := range []compress.Compression{compress.None, compress.Gzip, compress.Snappy, compress.Lz4, compress.Zstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			data := encodeBatch(100, codec, 1600000000000,
+				encodeRecord(0, 0, "user-1", `{"name":"alice"}`, "trace-id", "abc"),
+				encodeRecord(1, 5, "", "\xff\x00"),
+			)
+
+			var size int
+			records := readRecordSet(data, &size)
+			assert.False(t, records.PartialTrailingBatch)
+			assert.Len(t, records.RecordBatches, 1)
+
+			batch := records.RecordBatches[0]
+			assert.Empty(t, batch.Error)
+			assert.False(t, batch.Truncated)
+			assert.Equal(t, codec.String(), batch.Compression)
+			assert.Equal(t, "CreateTime", batch.TimestampType)
+			assert.Equal(t, int32(2), batch.NumRecords)
+			assert.Equal(t, []Record{
+				{
+					TimestampDelta: 0,
+					Timestamp:      1600000000000,
+					OffsetDelta:    0,
+					Offset:         100,
+					Key:            "user-1",
+					Value:          `{"name":"alice"}`,
+					Headers:        []RecordHeader{{Key: "trace-id", Value: "abc"}},
+				},
+				{
+					TimestampDelta: 5,
+					Timestamp:      1600000000005,
+					OffsetDelta:    1,
+					Offset:         101,
+					Key:            "",
+					Value:          "/wA=",
+					Encoding:       "base64",
+					Headers:        []RecordHeader{},
+				},
+			}, batch.Record)
+		})
+	}
+}
+
+func TestReadLegacyMessageSet(t *testing.T) {
+	var data []byte
+	data = append(data, encodeLegacyMessage(7, 0, compress.None, 0, "k", "v0")...)
+
+	// The offsets of the wrapped messages are relative since v1
+	inner := encodeLegacyMessage(0, 1, compress.None, 1600000000000, "a", "first")
+	inner = append(inner, encodeLegacyMessage(1, 1, compress.None, 1600000000010, "b", "second")...)
+	data = append(data, encodeLegacyMessage(9, 1, compress.Gzip, 1600000000010, "", string(compressed(compress.Gzip, inner)))...)
+
+	var size int
+	records := readRecordSet(data, &size)
+	assert.Len(t, records.RecordBatches, 2)
+
+	batch := records.RecordBatches[0]
+	assert.Empty(t, batch.Error)
+	assert.Equal(t, int8(0), batch.Magic)
+	assert.Equal(t, "uncompressed", batch.Compression)
+	assert.Len(t, batch.Record, 1)
+	assert.Equal(t, int64(7), batch.Record[0].Offset)
+	assert.Equal(t, "v0", batch.Record[0].Value)
+
+	batch = records.RecordBatches[1]
+	assert.Empty(t, batch.Error)
+	assert.Equal(t, int8(1), batch.Magic)
+	assert.Equal(t, "gzip", batch.Compression)
+	assert.Equal(t, int64(8), batch.BaseOffset)
+	assert.Equal(t, int32(2), batch.NumRecords)
+	assert.Equal(t, int32(1), batch.LastOffsetDelta)
+	assert.Equal(t, int64(8), batch.Record[0].Offset)
+	assert.Equal(t, "a", batch.Record[0].Key)
+	assert.Equal(t, "first", batch.Record[0].Value)
+	assert.Equal(t, int64(9), batch.Record[1].Offset)
+	assert.Equal(t, int64(1), batch.Record[1].OffsetDelta)
+	assert.Equal(t, int64(10), batch.Record[1].TimestampDelta)
+	assert.Equal(t, "second", batch.Record[1].Value)
+}
+
+func TestReadRecordSetSizeCap(t *testing.T) {
+	defer SetMaxRecordsSize(maxRecordsSize)
+	SetMaxRecordsSize(40)
+
+	value := string(bytes.Repeat([]byte("x"), 30))
+	data := encodeBatch(0, compress.Zstd, 0,
+		encodeRecord(0, 0, "a", value),
+		encodeRecord(1, 0, "b", value),
+	)
+	data = append(data, encodeBatch(2, compress.None, 0, encodeRecord(0, 0, "c", value))...)
+
+	var size int
+	records := readRecordSet(data, &size)
+	assert.Len(t, records.RecordBatches, 2)
+	assert.Equal(t, 40, size)
+
+	batch := records.RecordBatches[0]
+	assert.True(t, batch.Truncated)
+	assert.Empty(t, batch.Error)
+	assert.Len(t, batch.Record, 1)
+	assert.Equal(t, "a", batch.Record[0].Key)
+
+	batch = records.RecordBatches[1]
+	assert.True(t, batch.Truncated)
+	assert.Len(t, batch.Record, 0)
+}
+
+func TestReadRecordSetPartialTrailingBatch(t *testing.T) {
+	data := encodeBatch(0, compress.None, 0, encodeRecord(0, 0, "a", "b"))
+	partial := encodeBatch(1, compress.None, 0, encodeRecord(0, 0, "c", "d"))
+	data = append(data, partial[:len(partial)-3]...)
+
+	var size int
+	records := readRecordSet(data, &size)
+	assert.True(t, records.PartialTrailingBatch)
+	assert.Len(t, records.RecordBatches, 1)
+	assert.Equal(t, "b", records.RecordBatches[0].Record[0].Value)
+}
+
+func int16Bytes(v int16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(v))
+	return b
+}
+
+func int32Bytes(v int32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func int64Bytes(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+func kafkaString(s string) []byte {
+	return append(int16Bytes(int16(len(s))), s...)
+}
+
+func sizedMessage(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return append(int32Bytes(int32(len(b))), b...)
+}
+
+func TestDissectProduceAndFetchRecords(t *testing.T) {
+	produceRecords := encodeBatch(0, compress.Lz4, 1600000000000, encodeRecord(0, 0, "order-1", "created"))
+	produce := sizedMessage(
+		int16Bytes(int16(Produce)), int16Bytes(3), int32Bytes(1), kafkaString("producer"),
+		int16Bytes(-1), int16Bytes(1), int32Bytes(30000),
+		int32Bytes(1), kafkaString("orders"),
+		int32Bytes(1), int32Bytes(0), int32Bytes(int32(len(produceRecords))), produceRecords,
+	)
+	fetchRecords := encodeBatch(0, compress.Snappy, 1600000000000, encodeRecord(0, 0, "order-1", "created"))
+	fetch := sizedMessage(
+		int16Bytes(int16(Fetch)), int16Bytes(11), int32Bytes(2), kafkaString("consumer"),
+		int32Bytes(-1), int32Bytes(500), int32Bytes(1), int32Bytes(1048576), []byte{0}, int32Bytes(0), int32Bytes(-1),
+		int32Bytes(1), kafkaString("orders"),
+		int32Bytes(1), int32Bytes(0), int32Bytes(0), int64Bytes(0), int64Bytes(0), int32Bytes(1048576),
+		int32Bytes(0), kafkaString(""),
+	)
+	produceResponse := sizedMessage(
+		int32Bytes(1),
+		int32Bytes(1), kafkaString("orders"),
+		int32Bytes(1), int32Bytes(0), int16Bytes(0), int64Bytes(0), int64Bytes(-1),
+		int32Bytes(0),
+	)
+	fetchResponse := sizedMessage(
+		int32Bytes(2),
+		int32Bytes(0), int16Bytes(0), int32Bytes(0),
+		int32Bytes(1), kafkaString("orders"),
+		int32Bytes(1), int32Bytes(0), int16Bytes(0), int64Bytes(1), int64Bytes(1), int64Bytes(0),
+		int32Bytes(-1), int32Bytes(-1), int32Bytes(int32(len(fetchRecords))), fetchRecords,
+	)
+
+	dissector := NewDissector()
+	itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+	stream := NewTcpStream()
+	emitter := &api.Emitting{
+		AppStats:      &api.AppStats{},
+		Stream:        stream,
+		OutputChannel: itemChannel,
+	}
+	counterPair := &api.CounterPair{}
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+	reqResMatcher.SetMaxTry(10)
+
+	tcpIDClient := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}
+	reader := NewTcpReader(&api.ReadProgress{}, "", tcpIDClient, time.Time{}, stream, true, false, nil, emitter, counterPair, reqResMatcher)
+	client := append(produce, fetch...)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(client)), reader))
+
+	tcpIDServer := &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "2", DstPort: "1"}
+	reader = NewTcpReader(&api.ReadProgress{}, "", tcpIDServer, time.Time{}, stream, false, false, nil, emitter, counterPair, reqResMatcher)
+	server := append(produceResponse, fetchResponse...)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader(server)), reader))
+
+	close(itemChannel)
+	var entries []*api.Entry
+	for item := range itemChannel {
+		data, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var finalItem *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(data, &finalItem))
+
+		entry := dissector.Analyze(finalItem, &api.Resolution{}, &api.Resolution{})
+		_, err = dissector.Represent(entry.Request, entry.Response)
+		assert.Nil(t, err)
+		entries = append(entries, entry)
+	}
+	if !assert.Len(t, entries, 2) {
+		return
+	}
+
+	record := func(records interface{}) map[string]interface{} {
+		batch := records.(map[string]interface{})["recordBatches"].([]interface{})[0].(map[string]interface{})
+		return batch["record"].([]interface{})[0].(map[string]interface{})
+	}
+
+	partition := entries[0].Request["payload"].(map[string]interface{})["topicData"].([]interface{})[0].(map[string]interface{})["partitions"].([]interface{})[0]
+	produced := record(partition.(map[string]interface{})["records"])
+	assert.Equal(t, "order-1", produced["key"])
+	assert.Equal(t, "created", produced["value"])
+
+	partitionResponse := entries[1].Response["payload"].(map[string]interface{})["responses"].([]interface{})[0].(map[string]interface{})["partitionResponses"].([]interface{})[0]
+	fetched := record(partitionResponse.(map[string]interface{})["recordSet"])
+	assert.Equal(t, "order-1", fetched["key"])
+	assert.Equal(t, "created", fetched["value"])
+	assert.Equal(t, float64(1600000000000), fetched["timestamp"])
+}
@@ -51,6 +51,10 @@ func ReadRequest(r io.Reader, tcpID *api.TcpID, counterPair *api.CounterPair, ca
 		return apiKey, apiVersion, err
 	}
 
+	if isFlexible(apiKey, apiVersion) {
+		d.discardTaggedFields()
+	}
+
 	if err = d.err; err != nil {
 		err = dontExpectEOF(err)
 		return apiKey, apiVersion, err
@@ -197,9 +201,36 @@ func ReadRequest(r io.Reader, tcpID *api.TcpID, counterPair *api.CounterPair, ca
 		}
 		mt.(messageType).decode(d, valueOf(deleteTopicsRequest))
 		payload = deleteTopicsRequest
+	case FindCoordinator:
+		payload, err = decodeMessage(d, &FindCoordinatorRequest{}, apiVersion)
+	case JoinGroup:
+		payload, err = decodeMessage(d, &JoinGroupRequest{}, apiVersion)
+	case SyncGroup:
+		payload, err = decodeMessage(d, &SyncGroupRequest{}, apiVersion)
+	case Heartbeat:
+		payload, err = decodeMessage(d, &HeartbeatRequest{}, apiVersion)
+	case LeaveGroup:
+		payload, err = decodeMessage(d, &LeaveGroupRequest{}, apiVersion)
+	case OffsetCommit:
+		payload, err = decodeMessage(d, &OffsetCommitRequest{}, apiVersion)
+	case OffsetFetch:
+		payload, err = decodeMessage(d, &OffsetFetchRequest{}, apiVersion)
+	case InitProducerId:
+		payload, err = decodeMessage(d, &InitProducerIdRequest{}, apiVersion)
+	case AddPartitionsToTxn:
+		payload, err = decodeMessage(d, &AddPartitionsToTxnRequest{}, apiVersion)
+	case AddOffsetsToTxn:
+		payload, err = decodeMessage(d, &AddOffsetsToTxnRequest{}, apiVersion)
+	case EndTxn:
+		payload, err = decodeMessage(d, &EndTxnRequest{}, apiVersion)
+	case TxnOffsetCommit:
+		payload, err = decodeMessage(d, &TxnOffsetCommitRequest{}, apiVersion)
 	default:
 		return apiKey, 0, fmt.Errorf("(Request) Not implemented: %s", apiKey)
 	}
+	if err != nil {
+		return apiKey, apiVersion, err
+	}
 
 	request := &Request{
 		Size:          size,
@@ -61,6 +61,12 @@ func ReadResponse(r io.Reader, tcpID *api.TcpID, counterPair *api.CounterPair, c
 	apiKey := reqResPair.Request.ApiKey
 	apiVersion := reqResPair.Request.ApiVersion
 
+	// The header of ApiVersions responses never has tagged fields, so that
+	// clients can read it regardless of the versions that the broker supports
+	if isFlexible(apiKey, apiVersion) && apiKey != ApiVersions {
+		d.discardTaggedFields()
+	}
+
 	switch apiKey {
 	case Metadata:
 		var mt interface{}
@@ -107,7 +113,11 @@ func ReadResponse(r io.Reader, tcpID *api.TcpID, counterPair *api.CounterPair, c
 	case ApiVersions:
 		var mt interface{}
 		var apiVersionsResponse interface{}
-		if apiVersion >= v1 {
+		if apiVersion >= v3 {
+			types := makeTypes(reflect.TypeOf(&ApiVersionsResponseV3{}).Elem())
+			mt = types[0]
+			apiVersionsResponse = &ApiVersionsResponseV3{}
+		} else if apiVersion >= v1 {
 			types := makeTypes(reflect.TypeOf(&ApiVersionsResponseV1{}).Elem())
 			mt = types[0]
 			apiVersionsResponse = &ApiVersionsResponseV1{}
@@ -244,9 +254,36 @@ func ReadResponse(r io.Reader, tcpID *api.TcpID, counterPair *api.CounterPair, c
 		}
 		mt.(messageType).decode(d, valueOf(deleteTopicsResponse))
 		reqResPair.Response.Payload = deleteTopicsResponse
+	case FindCoordinator:
+		reqResPair.Response.Payload, err = decodeMessage(d, &FindCoordinatorResponse{}, apiVersion)
+	case JoinGroup:
+		reqResPair.Response.Payload, err = decodeMessage(d, &JoinGroupResponse{}, apiVersion)
+	case SyncGroup:
+		reqResPair.Response.Payload, err = decodeMessage(d, &SyncGroupResponse{}, apiVersion)
+	case Heartbeat:
+		reqResPair.Response.Payload, err = decodeMessage(d, &HeartbeatResponse{}, apiVersion)
+	case LeaveGroup:
+		reqResPair.Response.Payload, err = decodeMessage(d, &LeaveGroupResponse{}, apiVersion)
+	case OffsetCommit:
+		reqResPair.Response.Payload, err = decodeMessage(d, &OffsetCommitResponse{}, apiVersion)
+	case OffsetFetch:
+		reqResPair.Response.Payload, err = decodeMessage(d, &OffsetFetchResponse{}, apiVersion)
+	case InitProducerId:
+		reqResPair.Response.Payload, err = decodeMessage(d, &InitProducerIdResponse{}, apiVersion)
+	case AddPartitionsToTxn:
+		reqResPair.Response.Payload, err = decodeMessage(d, &AddPartitionsToTxnResponse{}, apiVersion)
+	case AddOffsetsToTxn:
+		reqResPair.Response.Payload, err = decodeMessage(d, &AddOffsetsToTxnResponse{}, apiVersion)
+	case EndTxn:
+		reqResPair.Response.Payload, err = decodeMessage(d, &EndTxnResponse{}, apiVersion)
+	case TxnOffsetCommit:
+		reqResPair.Response.Payload, err = decodeMessage(d, &TxnOffsetCommitResponse{}, apiVersion)
 	default:
 		return fmt.Errorf("(Response) Not implemented: %s", apiKey)
 	}
+	if err != nil {
+		return err
+	}
 
 	connectionInfo := &api.ConnectionInfo{
 		ClientIP:   tcpID.DstIP,
@@ -1,9 +1,5 @@
 package kafka
 
-import (
-	"time"
-)
-
 type RequiredAcks int16
 
 const (
@@ -246,8 +242,9 @@ type ApiVersionsRequestV0 struct{}
 // ApiVersions Request (Version: 3)
 
 type ApiVersionsRequestV3 struct {
-	ClientSoftwareName    string `json:"clientSoftwareName"`
-	ClientSoftwareVersion string `json:"clientSoftwareVersion"`
+	ClientSoftwareName    string   `kafka:"min=v3,max=v3" json:"clientSoftwareName"`
+	ClientSoftwareVersion string   `kafka:"min=v3,max=v3" json:"clientSoftwareVersion"`
+	_                     struct{} `kafka:"min=v3,max=v3,tag"`
 }
 
 // ApiVersions Response (Version: 0)
@@ -271,90 +268,84 @@ type ApiVersionsResponseV1 struct {
 	ThrottleTimeMs int32                       `json:"throttleTimeMs"`
 }
 
-// Produce Request (Version: 0)
+// ApiVersions Response (Version: 3)
 
-// Message is a kafka message type
-type MessageV0 struct {
-	Codec            int8        `json:"codec"`            // codec used to compress the message contents
-	CompressionLevel int         `json:"compressionLevel"` // compression level
-	LogAppendTime    bool        `json:"logAppendTime"`    // the used timestamp is LogAppendTime
-	Key              []byte      `json:"key"`              // the message key, may be nil
-	Value            []byte      `json:"value"`            // the message contents
-	Set              *MessageSet `json:"set"`              // the message set a message might wrap
-	Version          int8        `json:"version"`          // v1 requires Kafka 0.10
-	Timestamp        time.Time   `json:"timestamp"`        // the timestamp of the message (version 1+ only)
+type ApiVersionsResponseApiKeyV3 struct {
+	ApiKey     int16 `kafka:"min=v3,max=v3" json:"apiKey"`
+	MinVersion int16 `kafka:"min=v3,max=v3" json:"minVersion"`
+	MaxVersion int16 `kafka:"min=v3,max=v3" json:"maxVersion"`
 }
 
-// MessageBlock represents a part of request with message
-type MessageBlock struct {
-	Offset int64      `json:"offset"`
-	Msg    *MessageV0 `json:"msg"`
+type ApiVersionsResponseV3 struct {
+	ErrorCode      int16                         `kafka:"min=v3,max=v3" json:"errorCode"`
+	ApiKeys        []ApiVersionsResponseApiKeyV3 `kafka:"min=v3,max=v3" json:"apiKeys"`
+	ThrottleTimeMs int32                         `kafka:"min=v3,max=v3" json:"throttleTimeMs"`
+	_              struct{}                      `kafka:"min=v3,max=v3,tag"`
 }
 
-// MessageSet is a replacement for RecordBatch in older versions
-type MessageSet struct {
-	PartialTrailingMessage bool            `json:"partialTrailingMessage"` // whether the set on the wire contained an incomplete trailing MessageBlock
-	OverflowMessage        bool            `json:"overflowMessage"`        // whether the set on the wire contained an overflow message
-	Messages               []*MessageBlock `json:"messages"`
-}
+// Produce Request (Version: 0)
 
+// RecordHeader is a header of a record
 type RecordHeader struct {
-	HeaderKeyLength   int8   `json:"headerKeyLength"`
-	HeaderKey         string `json:"headerKey"`
-	HeaderValueLength int8   `json:"headerValueLength"`
-	Value             string `json:"value"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
-// Record is kafka record type
-type RecordV0 struct {
-	Unknown        int8           `json:"unknown"`
+// Record is a record of a batch, or a message of a legacy message set. The key
+// and the value are base64 encoded if they aren't valid UTF-8.
+type Record struct {
 	Attributes     int8           `json:"attributes"`
-	TimestampDelta int8           `json:"timestampDelta"`
-	OffsetDelta    int8           `json:"offsetDelta"`
-	KeyLength      int8           `json:"keyLength"`
+	TimestampDelta int64          `json:"timestampDelta"`
+	Timestamp      int64          `json:"timestamp"`
+	OffsetDelta    int64          `json:"offsetDelta"`
+	Offset         int64          `json:"offset"`
 	Key            string         `json:"key"`
-	ValueLen       int8           `json:"valueLen"`
 	Value          string         `json:"value"`
+	Encoding       string         `json:"encoding,omitempty"`
 	Headers        []RecordHeader `json:"headers"`
 }
 
-// RecordBatch are records from one kafka request
+// RecordBatch is a batch of records (magic v2), or a message of a legacy
+// message set (magic v0 and v1) with the messages that it wraps, if it's
+// compressed. The records are cut short once the size cap of the entry is
+// reached.
 type RecordBatch struct {
-	BaseOffset           int64      `json:"baseOffset"`
-	BatchLength          int32      `json:"batchLength"`
-	PartitionLeaderEpoch int32      `json:"partitionLeaderEpoch"`
-	Magic                int8       `json:"magic"`
-	Crc                  int32      `json:"crc"`
-	Attributes           int16      `json:"attributes"`
-	LastOffsetDelta      int32      `json:"lastOffsetDelta"`
-	FirstTimestamp       int64      `json:"firstTimestamp"`
-	MaxTimestamp         int64      `json:"maxTimestamp"`
-	ProducerId           int64      `json:"producerId"`
-	ProducerEpoch        int16      `json:"producerEpoch"`
-	BaseSequence         int32      `json:"baseSequence"`
-	Record               []RecordV0 `json:"record"`
-}
-
+	BaseOffset           int64    `json:"baseOffset"`
+	BatchLength          int32    `json:"batchLength"`
+	PartitionLeaderEpoch int32    `json:"partitionLeaderEpoch"`
+	Magic                int8     `json:"magic"`
+	Crc                  int32    `json:"crc"`
+	Attributes           int16    `json:"attributes"`
+	Compression          string   `json:"compression"`
+	TimestampType        string   `json:"timestampType"`
+	Transactional        bool     `json:"transactional"`
+	Control              bool     `json:"control"`
+	LastOffsetDelta      int32    `json:"lastOffsetDelta"`
+	FirstTimestamp       int64    `json:"firstTimestamp"`
+	MaxTimestamp         int64    `json:"maxTimestamp"`
+	ProducerId           int64    `json:"producerId"`
+	ProducerEpoch        int16    `json:"producerEpoch"`
+	BaseSequence         int32    `json:"baseSequence"`
+	NumRecords           int32    `json:"numRecords"`
+	Record               []Record `json:"record"`
+	Truncated            bool     `json:"truncated,omitempty"`
+	Error                string   `json:"error,omitempty"`
+}
+
+// Records is a record set, which is decoded by decodeRecords
 type Records struct {
-	RecordBatch RecordBatch `json:"recordBatch"`
-	// TODO: Implement `MessageSet`
-	// MessageSet  MessageSet
+	RecordBatches        []RecordBatch `json:"recordBatches"`
+	PartialTrailingBatch bool          `json:"partialTrailingBatch,omitempty"`
 }
 
 type PartitionData struct {
 	Index   int32   `json:"index"`
-	Unknown int32   `json:"unknown"`
 	Records Records `json:"records"`
 }
 
-type Partitions struct {
-	Length        int32         `json:"length"`
-	PartitionData PartitionData `json:"partitionData"`
-}
-
 type TopicData struct {
-	Topic      string     `json:"topic"`
-	Partitions Partitions `json:"partitions"`
+	Topic      string          `json:"topic"`
+	Partitions []PartitionData `json:"partitions"`
 }
 
 type ProduceRequestV0 struct {
@@ -615,17 +606,17 @@ type FetchResponseV1 struct {
 // Fetch Response (Version: 4)
 
 type AbortedTransactionsV4 struct {
-	ProducerId  int32 `json:"producerId"`
-	FirstOffset int32 `json:"firstOffset"`
+	ProducerId  int64 `json:"producerId"`
+	FirstOffset int64 `json:"firstOffset"`
 }
 
 type PartitionResponseFetchV4 struct {
-	Partition           int32                 `json:"partition"`
-	ErrorCode           int16                 `json:"errorCode"`
-	HighWatermark       int64                 `json:"highWatermark"`
-	LastStableOffset    int64                 `json:"lastStableOffset"`
-	AbortedTransactions AbortedTransactionsV4 `json:"abortedTransactions"`
-	RecordSet           Records               `json:"recordSet"`
+	Partition           int32                   `json:"partition"`
+	ErrorCode           int16                   `json:"errorCode"`
+	HighWatermark       int64                   `json:"highWatermark"`
+	LastStableOffset    int64                   `json:"lastStableOffset"`
+	AbortedTransactions []AbortedTransactionsV4 `json:"abortedTransactions"`
+	RecordSet           Records                 `json:"recordSet"`
 }
 
 type ResponseFetchV4 struct {
@@ -641,13 +632,13 @@ type FetchResponseV4 struct {
 // Fetch Response (Version: 5)
 
 type PartitionResponseFetchV5 struct {
-	Partition           int32                 `json:"partition"`
-	ErrorCode           int16                 `json:"errorCode"`
-	HighWatermark       int64                 `json:"highWatermark"`
-	LastStableOffset    int64                 `json:"lastStableOffset"`
-	LogStartOffset      int64                 `json:"logStartOffset"`
-	AbortedTransactions AbortedTransactionsV4 `json:"abortedTransactions"`
-	RecordSet           Records               `json:"recordSet"`
+	Partition           int32                   `json:"partition"`
+	ErrorCode           int16                   `json:"errorCode"`
+	HighWatermark       int64                   `json:"highWatermark"`
+	LastStableOffset    int64                   `json:"lastStableOffset"`
+	LogStartOffset      int64                   `json:"logStartOffset"`
+	AbortedTransactions []AbortedTransactionsV4 `json:"abortedTransactions"`
+	RecordSet           Records                 `json:"recordSet"`
 }
 
 type ResponseFetchV5 struct {
@@ -672,14 +663,14 @@ type FetchResponseV7 struct {
 // Fetch Response (Version: 11)
 
 type PartitionResponseFetchV11 struct {
-	Partition            int32                 `json:"partition"`
-	ErrorCode            int16                 `json:"errorCode"`
-	HighWatermark        int64                 `json:"highWatermark"`
-	LastStableOffset     int64                 `json:"lastStableOffset"`
-	LogStartOffset       int64                 `json:"logStartOffset"`
-	AbortedTransactions  AbortedTransactionsV4 `json:"abortedTransactions"`
-	PreferredReadReplica int32                 `json:"preferredReadReplica"`
-	RecordSet            Records               `json:"recordSet"`
+	Partition            int32                   `json:"partition"`
+	ErrorCode            int16                   `json:"errorCode"`
+	HighWatermark        int64                   `json:"highWatermark"`
+	LastStableOffset     int64                   `json:"lastStableOffset"`
+	LogStartOffset       int64                   `json:"logStartOffset"`
+	AbortedTransactions  []AbortedTransactionsV4 `json:"abortedTransactions"`
+	PreferredReadReplica int32                   `json:"preferredReadReplica"`
+	RecordSet            Records                 `json:"recordSet"`
 }
 
 type ResponseFetchV11 struct {
@@ -688,10 +679,10 @@ type ResponseFetchV11 struct {
 }
 
 type FetchResponseV11 struct {
-	ThrottleTimeMs int32             `json:"throttleTimeMs"`
-	ErrorCode      int16             `json:"errorCode"`
-	SessionId      int32             `json:"sessionId"`
-	Responses      []ResponseFetchV5 `json:"responses"`
+	ThrottleTimeMs int32              `json:"throttleTimeMs"`
+	ErrorCode      int16              `json:"errorCode"`
+	SessionId      int32              `json:"sessionId"`
+	Responses      []ResponseFetchV11 `json:"responses"`
 }
 
 // ListOffsets Request (Version: 0)
@@ -983,3 +974,376 @@ type DeleteTopicsReponseV6 struct {
 	ThrottleTimeMs int32                           `json:"throttleTimeMs"`
 	Responses      []DeleteTopicsReponseResponseV6 `json:"responses"`
 }
+
+// The messages below have a single struct for all of their versions, whose
+// fields are tagged with the versions that they're in. Their flexible
+// versions are tagged with "tag", so that their strings and arrays are
+// decoded as compact and their tagged fields are skipped.
+
+// FindCoordinator Request (Versions: 0-4)
+
+type FindCoordinatorRequest struct {
+	Key             string   `kafka:"min=v0,max=v3" json:"key,omitempty"`
+	KeyType         int8     `kafka:"min=v1,max=v4" json:"keyType"`
+	CoordinatorKeys []string `kafka:"min=v4,max=v4" json:"coordinatorKeys,omitempty"`
+	_               struct{} `kafka:"min=v3,max=v4,tag"`
+}
+
+// FindCoordinator Response (Versions: 0-4)
+
+type FindCoordinatorResponseCoordinator struct {
+	Key          string `kafka:"min=v4,max=v4" json:"key"`
+	NodeId       int32  `kafka:"min=v4,max=v4" json:"nodeId"`
+	Host         string `kafka:"min=v4,max=v4" json:"host"`
+	Port         int32  `kafka:"min=v4,max=v4" json:"port"`
+	ErrorCode    int16  `kafka:"min=v4,max=v4" json:"errorCode"`
+	ErrorMessage string `kafka:"min=v4,max=v4" json:"errorMessage"`
+}
+
+type FindCoordinatorResponse struct {
+	ThrottleTimeMs int32                                `kafka:"min=v1,max=v4" json:"throttleTimeMs"`
+	ErrorCode      int16                                `kafka:"min=v0,max=v3" json:"errorCode"`
+	ErrorMessage   string                               `kafka:"min=v1,max=v3" json:"errorMessage,omitempty"`
+	NodeId         int32                                `kafka:"min=v0,max=v3" json:"nodeId,omitempty"`
+	Host           string                               `kafka:"min=v0,max=v3" json:"host,omitempty"`
+	Port           int32                                `kafka:"min=v0,max=v3" json:"port,omitempty"`
+	Coordinators   []FindCoordinatorResponseCoordinator `kafka:"min=v4,max=v4" json:"coordinators,omitempty"`
+	_              struct{}                             `kafka:"min=v3,max=v4,tag"`
+}
+
+// JoinGroup Request (Versions: 0-9)
+
+type JoinGroupRequestProtocol struct {
+	Name     string `kafka:"min=v0,max=v9" json:"name"`
+	Metadata []byte `kafka:"min=v0,max=v9" json:"metadata"`
+}
+
+type JoinGroupRequest struct {
+	GroupId            string                     `kafka:"min=v0,max=v9" json:"groupId"`
+	SessionTimeoutMs   int32                      `kafka:"min=v0,max=v9" json:"sessionTimeoutMs"`
+	RebalanceTimeoutMs int32                      `kafka:"min=v1,max=v9" json:"rebalanceTimeoutMs"`
+	MemberId           string                     `kafka:"min=v0,max=v9" json:"memberId"`
+	GroupInstanceId    string                     `kafka:"min=v5,max=v9" json:"groupInstanceId"`
+	ProtocolType       string                     `kafka:"min=v0,max=v9" json:"protocolType"`
+	Protocols          []JoinGroupRequestProtocol `kafka:"min=v0,max=v9" json:"protocols"`
+	Reason             string                     `kafka:"min=v8,max=v9" json:"reason"`
+	_                  struct{}                   `kafka:"min=v6,max=v9,tag"`
+}
+
+// JoinGroup Response (Versions: 0-9)
+
+type JoinGroupResponseMember struct {
+	MemberId        string `kafka:"min=v0,max=v9" json:"memberId"`
+	GroupInstanceId string `kafka:"min=v5,max=v9" json:"groupInstanceId"`
+	Metadata        []byte `kafka:"min=v0,max=v9" json:"metadata"`
+}
+
+type JoinGroupResponse struct {
+	ThrottleTimeMs int32                     `kafka:"min=v2,max=v9" json:"throttleTimeMs"`
+	ErrorCode      int16                     `kafka:"min=v0,max=v9" json:"errorCode"`
+	GenerationId   int32                     `kafka:"min=v0,max=v9" json:"generationId"`
+	ProtocolType   string                    `kafka:"min=v7,max=v9" json:"protocolType"`
+	ProtocolName   string                    `kafka:"min=v0,max=v9" json:"protocolName"`
+	Leader         string                    `kafka:"min=v0,max=v9" json:"leader"`
+	SkipAssignment bool                      `kafka:"min=v9,max=v9" json:"skipAssignment"`
+	MemberId       string                    `kafka:"min=v0,max=v9" json:"memberId"`
+	Members        []JoinGroupResponseMember `kafka:"min=v0,max=v9" json:"members"`
+	_              struct{}                  `kafka:"min=v6,max=v9,tag"`
+}
+
+// SyncGroup Request (Versions: 0-5)
+
+type SyncGroupRequestAssignment struct {
+	MemberId   string `kafka:"min=v0,max=v5" json:"memberId"`
+	Assignment []byte `kafka:"min=v0,max=v5" json:"assignment"`
+}
+
+type SyncGroupRequest struct {
+	GroupId         string                       `kafka:"min=v0,max=v5" json:"groupId"`
+	GenerationId    int32                        `kafka:"min=v0,max=v5" json:"generationId"`
+	MemberId        string                       `kafka:"min=v0,max=v5" json:"memberId"`
+	GroupInstanceId string                       `kafka:"min=v3,max=v5" json:"groupInstanceId"`
+	ProtocolType    string                       `kafka:"min=v5,max=v5" json:"protocolType"`
+	ProtocolName    string                       `kafka:"min=v5,max=v5" json:"protocolName"`
+	Assignments     []SyncGroupRequestAssignment `kafka:"min=v0,max=v5" json:"assignments"`
+	_               struct{}                     `kafka:"min=v4,max=v5,tag"`
+}
+
+// SyncGroup Response (Versions: 0-5)
+
+type SyncGroupResponse struct {
+	ThrottleTimeMs int32    `kafka:"min=v1,max=v5" json:"throttleTimeMs"`
+	ErrorCode      int16    `kafka:"min=v0,max=v5" json:"errorCode"`
+	ProtocolType   string   `kafka:"min=v5,max=v5" json:"protocolType"`
+	ProtocolName   string   `kafka:"min=v5,max=v5" json:"protocolName"`
+	Assignment     []byte   `kafka:"min=v0,max=v5" json:"assignment"`
+	_              struct{} `kafka:"min=v4,max=v5,tag"`
+}
+
+// Heartbeat Request (Versions: 0-4)
+
+type HeartbeatRequest struct {
+	GroupId         string   `kafka:"min=v0,max=v4" json:"groupId"`
+	GenerationId    int32    `kafka:"min=v0,max=v4" json:"generationId"`
+	MemberId        string   `kafka:"min=v0,max=v4" json:"memberId"`
+	GroupInstanceId string   `kafka:"min=v3,max=v4" json:"groupInstanceId"`
+	_               struct{} `kafka:"min=v4,max=v4,tag"`
+}
+
+// Heartbeat Response (Versions: 0-4)
+
+type HeartbeatResponse struct {
+	ThrottleTimeMs int32    `kafka:"min=v1,max=v4" json:"throttleTimeMs"`
+	ErrorCode      int16    `kafka:"min=v0,max=v4" json:"errorCode"`
+	_              struct{} `kafka:"min=v4,max=v4,tag"`
+}
+
+// LeaveGroup Request (Versions: 0-5)
+
+type LeaveGroupRequestMember struct {
+	MemberId        string `kafka:"min=v3,max=v5" json:"memberId"`
+	GroupInstanceId string `kafka:"min=v3,max=v5" json:"groupInstanceId"`
+	Reason          string `kafka:"min=v5,max=v5" json:"reason"`
+}
+
+type LeaveGroupRequest struct {
+	GroupId  string                    `kafka:"min=v0,max=v5" json:"groupId"`
+	MemberId string                    `kafka:"min=v0,max=v2" json:"memberId,omitempty"`
+	Members  []LeaveGroupRequestMember `kafka:"min=v3,max=v5" json:"members,omitempty"`
+	_        struct{}                  `kafka:"min=v4,max=v5,tag"`
+}
+
+// LeaveGroup Response (Versions: 0-5)
+
+type LeaveGroupResponseMember struct {
+	MemberId        string `kafka:"min=v3,max=v5" json:"memberId"`
+	GroupInstanceId string `kafka:"min=v3,max=v5" json:"groupInstanceId"`
+	ErrorCode       int16  `kafka:"min=v3,max=v5" json:"errorCode"`
+}
+
+type LeaveGroupResponse struct {
+	ThrottleTimeMs int32                      `kafka:"min=v1,max=v5" json:"throttleTimeMs"`
+	ErrorCode      int16                      `kafka:"min=v0,max=v5" json:"errorCode"`
+	Members        []LeaveGroupResponseMember `kafka:"min=v3,max=v5" json:"members,omitempty"`
+	_              struct{}                   `kafka:"min=v4,max=v5,tag"`
+}
+
+// OffsetCommit Request (Versions: 0-8)
+
+type OffsetCommitRequestPartition struct {
+	PartitionIndex       int32  `kafka:"min=v0,max=v8" json:"partitionIndex"`
+	CommittedOffset      int64  `kafka:"min=v0,max=v8" json:"committedOffset"`
+	CommittedLeaderEpoch int32  `kafka:"min=v6,max=v8" json:"committedLeaderEpoch"`
+	CommitTimestamp      int64  `kafka:"min=v1,max=v1" json:"commitTimestamp,omitempty"`
+	CommittedMetadata    string `kafka:"min=v0,max=v8" json:"committedMetadata"`
+}
+
+type OffsetCommitRequestTopic struct {
+	Name       string                         `kafka:"min=v0,max=v8" json:"name"`
+	Partitions []OffsetCommitRequestPartition `kafka:"min=v0,max=v8" json:"partitions"`
+}
+
+type OffsetCommitRequest struct {
+	GroupId         string                     `kafka:"min=v0,max=v8" json:"groupId"`
+	GenerationId    int32                      `kafka:"min=v1,max=v8" json:"generationId"`
+	MemberId        string                     `kafka:"min=v1,max=v8" json:"memberId"`
+	GroupInstanceId string                     `kafka:"min=v7,max=v8" json:"groupInstanceId"`
+	RetentionTimeMs int64                      `kafka:"min=v2,max=v4" json:"retentionTimeMs,omitempty"`
+	Topics          []OffsetCommitRequestTopic `kafka:"min=v0,max=v8" json:"topics"`
+	_               struct{}                   `kafka:"min=v8,max=v8,tag"`
+}
+
+// OffsetCommit Response (Versions: 0-8)
+
+type OffsetCommitResponsePartition struct {
+	PartitionIndex int32 `kafka:"min=v0,max=v8" json:"partitionIndex"`
+	ErrorCode      int16 `kafka:"min=v0,max=v8" json:"errorCode"`
+}
+
+type OffsetCommitResponseTopic struct {
+	Name       string                          `kafka:"min=v0,max=v8" json:"name"`
+	Partitions []OffsetCommitResponsePartition `kafka:"min=v0,max=v8" json:"partitions"`
+}
+
+type OffsetCommitResponse struct {
+	ThrottleTimeMs int32                       `kafka:"min=v3,max=v8" json:"throttleTimeMs"`
+	Topics         []OffsetCommitResponseTopic `kafka:"min=v0,max=v8" json:"topics"`
+	_              struct{}                    `kafka:"min=v8,max=v8,tag"`
+}
+
+// OffsetFetch Request (Versions: 0-8)
+
+type OffsetFetchRequestTopic struct {
+	Name             string  `kafka:"min=v0,max=v8" json:"name"`
+	PartitionIndexes []int32 `kafka:"min=v0,max=v8" json:"partitionIndexes"`
+}
+
+type OffsetFetchRequestGroup struct {
+	GroupId string                    `kafka:"min=v8,max=v8" json:"groupId"`
+	Topics  []OffsetFetchRequestTopic `kafka:"min=v8,max=v8" json:"topics"`
+}
+
+type OffsetFetchRequest struct {
+	GroupId       string                    `kafka:"min=v0,max=v7" json:"groupId,omitempty"`
+	Topics        []OffsetFetchRequestTopic `kafka:"min=v0,max=v7" json:"topics,omitempty"`
+	Groups        []OffsetFetchRequestGroup `kafka:"min=v8,max=v8" json:"groups,omitempty"`
+	RequireStable bool                      `kafka:"min=v7,max=v8" json:"requireStable"`
+	_             struct{}                  `kafka:"min=v6,max=v8,tag"`
+}
+
+// OffsetFetch Response (Versions: 0-8)
+
+type OffsetFetchResponsePartition struct {
+	PartitionIndex       int32  `kafka:"min=v0,max=v8" json:"partitionIndex"`
+	CommittedOffset      int64  `kafka:"min=v0,max=v8" json:"committedOffset"`
+	CommittedLeaderEpoch int32  `kafka:"min=v5,max=v8" json:"committedLeaderEpoch"`
+	Metadata             string `kafka:"min=v0,max=v8" json:"metadata"`
+	ErrorCode            int16  `kafka:"min=v0,max=v8" json:"errorCode"`
+}
+
+type OffsetFetchResponseTopic struct {
+	Name       string                         `kafka:"min=v0,max=v8" json:"name"`
+	Partitions []OffsetFetchResponsePartition `kafka:"min=v0,max=v8" json:"partitions"`
+}
+
+type OffsetFetchResponseGroup struct {
+	GroupId   string                     `kafka:"min=v8,max=v8" json:"groupId"`
+	Topics    []OffsetFetchResponseTopic `kafka:"min=v8,max=v8" json:"topics"`
+	ErrorCode int16                      `kafka:"min=v8,max=v8" json:"errorCode"`
+}
+
+type OffsetFetchResponse struct {
+	ThrottleTimeMs int32                      `kafka:"min=v3,max=v8" json:"throttleTimeMs"`
+	Topics         []OffsetFetchResponseTopic `kafka:"min=v0,max=v7" json:"topics,omitempty"`
+	ErrorCode      int16                      `kafka:"min=v2,max=v7" json:"errorCode"`
+	Groups         []OffsetFetchResponseGroup `kafka:"min=v8,max=v8" json:"groups,omitempty"`
+	_              struct{}                   `kafka:"min=v6,max=v8,tag"`
+}
+
+// InitProducerId Request (Versions: 0-4)
+
+type InitProducerIdRequest struct {
+	TransactionalId      string   `kafka:"min=v0,max=v4" json:"transactionalId"`
+	TransactionTimeoutMs int32    `kafka:"min=v0,max=v4" json:"transactionTimeoutMs"`
+	ProducerId           int64    `kafka:"min=v3,max=v4" json:"producerId"`
+	ProducerEpoch        int16    `kafka:"min=v3,max=v4" json:"producerEpoch"`
+	_                    struct{} `kafka:"min=v2,max=v4,tag"`
+}
+
+// InitProducerId Response (Versions: 0-4)
+
+type InitProducerIdResponse struct {
+	ThrottleTimeMs int32    `kafka:"min=v0,max=v4" json:"throttleTimeMs"`
+	ErrorCode      int16    `kafka:"min=v0,max=v4" json:"errorCode"`
+	ProducerId     int64    `kafka:"min=v0,max=v4" json:"producerId"`
+	ProducerEpoch  int16    `kafka:"min=v0,max=v4" json:"producerEpoch"`
+	_              struct{} `kafka:"min=v2,max=v4,tag"`
+}
+
+// AddPartitionsToTxn Request (Versions: 0-3)
+
+type AddPartitionsToTxnRequestTopic struct {
+	Name       string  `kafka:"min=v0,max=v3" json:"name"`
+	Partitions []int32 `kafka:"min=v0,max=v3" json:"partitions"`
+}
+
+type AddPartitionsToTxnRequest struct {
+	TransactionalId string                           `kafka:"min=v0,max=v3" json:"transactionalId"`
+	ProducerId      int64                            `kafka:"min=v0,max=v3" json:"producerId"`
+	ProducerEpoch   int16                            `kafka:"min=v0,max=v3" json:"producerEpoch"`
+	Topics          []AddPartitionsToTxnRequestTopic `kafka:"min=v0,max=v3" json:"topics"`
+	_               struct{}                         `kafka:"min=v3,max=v3,tag"`
+}
+
+// AddPartitionsToTxn Response (Versions: 0-3)
+
+type AddPartitionsToTxnResponsePartition struct {
+	PartitionIndex     int32 `kafka:"min=v0,max=v3" json:"partitionIndex"`
+	PartitionErrorCode int16 `kafka:"min=v0,max=v3" json:"partitionErrorCode"`
+}
+
+type AddPartitionsToTxnResponseTopic struct {
+	Name    string                                `kafka:"min=v0,max=v3" json:"name"`
+	Results []AddPartitionsToTxnResponsePartition `kafka:"min=v0,max=v3" json:"results"`
+}
+
+type AddPartitionsToTxnResponse struct {
+	ThrottleTimeMs int32                             `kafka:"min=v0,max=v3" json:"throttleTimeMs"`
+	Results        []AddPartitionsToTxnResponseTopic `kafka:"min=v0,max=v3" json:"results"`
+	_              struct{}                          `kafka:"min=v3,max=v3,tag"`
+}
+
+// AddOffsetsToTxn Request (Versions: 0-3)
+
+type AddOffsetsToTxnRequest struct {
+	TransactionalId string   `kafka:"min=v0,max=v3" json:"transactionalId"`
+	ProducerId      int64    `kafka:"min=v0,max=v3" json:"producerId"`
+	ProducerEpoch   int16    `kafka:"min=v0,max=v3" json:"producerEpoch"`
+	GroupId         string   `kafka:"min=v0,max=v3" json:"groupId"`
+	_               struct{} `kafka:"min=v3,max=v3,tag"`
+}
+
+// AddOffsetsToTxn Response (Versions: 0-3)
+
+type AddOffsetsToTxnResponse struct {
+	ThrottleTimeMs int32    `kafka:"min=v0,max=v3" json:"throttleTimeMs"`
+	ErrorCode      int16    `kafka:"min=v0,max=v3" json:"errorCode"`
+	_              struct{} `kafka:"min=v3,max=v3,tag"`
+}
+
+// EndTxn Request (Versions: 0-3)
+
+type EndTxnRequest struct {
+	TransactionalId string   `kafka:"min=v0,max=v3" json:"transactionalId"`
+	ProducerId      int64    `kafka:"min=v0,max=v3" json:"producerId"`
+	ProducerEpoch   int16    `kafka:"min=v0,max=v3" json:"producerEpoch"`
+	Committed       bool     `kafka:"min=v0,max=v3" json:"committed"`
+	_               struct{} `kafka:"min=v3,max=v3,tag"`
+}
+
+// EndTxn Response (Versions: 0-3)
+
+type EndTxnResponse struct {
+	ThrottleTimeMs int32    `kafka:"min=v0,max=v3" json:"throttleTimeMs"`
+	ErrorCode      int16    `kafka:"min=v0,max=v3" json:"errorCode"`
+	_              struct{} `kafka:"min=v3,max=v3,tag"`
+}
+
+// TxnOffsetCommit Request (Versions: 0-3)
+
+type TxnOffsetCommitRequestPartition struct {
+	PartitionIndex       int32  `kafka:"min=v0,max=v3" json:"partitionIndex"`
+	CommittedOffset      int64  `kafka:"min=v0,max=v3" json:"committedOffset"`
+	CommittedLeaderEpoch int32  `kafka:"min=v2,max=v3" json:"committedLeaderEpoch"`
+	CommittedMetadata    string `kafka:"min=v0,max=v3" json:"committedMetadata"`
+}
+
+type TxnOffsetCommitRequestTopic struct {
+	Name       string                            `kafka:"min=v0,max=v3" json:"name"`
+	Partitions []TxnOffsetCommitRequestPartition `kafka:"min=v0,max=v3" json:"partitions"`
+}
+
+type TxnOffsetCommitRequest struct {
+	TransactionalId string                        `kafka:"min=v0,max=v3" json:"transactionalId"`
+	GroupId         string                        `kafka:"min=v0,max=v3" json:"groupId"`
+	ProducerId      int64                         `kafka:"min=v0,max=v3" json:"producerId"`
+	ProducerEpoch   int16                         `kafka:"min=v0,max=v3" json:"producerEpoch"`
+	GenerationId    int32                         `kafka:"min=v3,max=v3" json:"generationId"`
+	MemberId        string                        `kafka:"min=v3,max=v3" json:"memberId"`
+	GroupInstanceId string                        `kafka:"min=v3,max=v3" json:"groupInstanceId"`
+	Topics          []TxnOffsetCommitRequestTopic `kafka:"min=v0,max=v3" json:"topics"`
+	_               struct{}                      `kafka:"min=v3,max=v3,tag"`
+}
+
+// TxnOffsetCommit Response (Versions: 0-3)
+
+type TxnOffsetCommitResponseTopic struct {
+	Name       string                          `kafka:"min=v0,max=v3" json:"name"`
+	Partitions []OffsetCommitResponsePartition `kafka:"min=v0,max=v3" json:"partitions"`
+}
+
+type TxnOffsetCommitResponse struct {
+	ThrottleTimeMs int32                          `kafka:"min=v0,max=v3" json:"throttleTimeMs"`
+	Topics         []TxnOffsetCommitResponseTopic `kafka:"min=v0,max=v3" json:"topics"`
+	_              struct{}                       `kafka:"min=v3,max=v3,tag"`
+}
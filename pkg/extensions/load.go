@@ -5,10 +5,22 @@ import (
 
 	"github.com/kubeshark/worker/pkg/api"
 	amqpExt "github.com/kubeshark/worker/pkg/extensions/amqp"
+	amqp10Ext "github.com/kubeshark/worker/pkg/extensions/amqp10"
+	cqlExt "github.com/kubeshark/worker/pkg/extensions/cql"
 	dnsExt "github.com/kubeshark/worker/pkg/extensions/dns"
 	httpExt "github.com/kubeshark/worker/pkg/extensions/http"
 	kafkaExt "github.com/kubeshark/worker/pkg/extensions/kafka"
+	memcachedExt "github.com/kubeshark/worker/pkg/extensions/memcached"
+	mongodbExt "github.com/kubeshark/worker/pkg/extensions/mongodb"
+	mqttExt "github.com/kubeshark/worker/pkg/extensions/mqtt"
+	mysqlExt "github.com/kubeshark/worker/pkg/extensions/mysql"
+	natsExt "github.com/kubeshark/worker/pkg/extensions/nats"
+	postgresExt "github.com/kubeshark/worker/pkg/extensions/postgres"
+	pulsarExt "github.com/kubeshark/worker/pkg/extensions/pulsar"
 	redisExt "github.com/kubeshark/worker/pkg/extensions/redis"
+	thriftExt "github.com/kubeshark/worker/pkg/extensions/thrift"
+	tlsExt "github.com/kubeshark/worker/pkg/extensions/tls"
+	zookeeperExt "github.com/kubeshark/worker/pkg/extensions/zookeeper"
 )
 
 var (
@@ -55,6 +67,90 @@ func LoadExtensions() {
 	Extensions = append(Extensions, extensionDns)
 	ExtensionsMap[extensionDns.Protocol.Name] = extensionDns
 
+	extensionPostgres := &api.Extension{}
+	dissectorPostgres := postgresExt.NewDissector()
+	dissectorPostgres.Register(extensionPostgres)
+	extensionPostgres.Dissector = dissectorPostgres
+	Extensions = append(Extensions, extensionPostgres)
+	ExtensionsMap[extensionPostgres.Protocol.Name] = extensionPostgres
+
+	extensionMysql := &api.Extension{}
+	dissectorMysql := mysqlExt.NewDissector()
+	dissectorMysql.Register(extensionMysql)
+	extensionMysql.Dissector = dissectorMysql
+	Extensions = append(Extensions, extensionMysql)
+	ExtensionsMap[extensionMysql.Protocol.Name] = extensionMysql
+
+	extensionMongodb := &api.Extension{}
+	dissectorMongodb := mongodbExt.NewDissector()
+	dissectorMongodb.Register(extensionMongodb)
+	extensionMongodb.Dissector = dissectorMongodb
+	Extensions = append(Extensions, extensionMongodb)
+	ExtensionsMap[extensionMongodb.Protocol.Name] = extensionMongodb
+
+	extensionCql := &api.Extension{}
+	dissectorCql := cqlExt.NewDissector()
+	dissectorCql.Register(extensionCql)
+	extensionCql.Dissector = dissectorCql
+	Extensions = append(Extensions, extensionCql)
+	ExtensionsMap[extensionCql.Protocol.Name] = extensionCql
+
+	extensionMqtt := &api.Extension{}
+	dissectorMqtt := mqttExt.NewDissector()
+	dissectorMqtt.Register(extensionMqtt)
+	extensionMqtt.Dissector = dissectorMqtt
+	Extensions = append(Extensions, extensionMqtt)
+	ExtensionsMap[extensionMqtt.Protocol.Name] = extensionMqtt
+
+	extensionNats := &api.Extension{}
+	dissectorNats := natsExt.NewDissector()
+	dissectorNats.Register(extensionNats)
+	extensionNats.Dissector = dissectorNats
+	Extensions = append(Extensions, extensionNats)
+	ExtensionsMap[extensionNats.Protocol.Name] = extensionNats
+
+	extensionMemcached := &api.Extension{}
+	dissectorMemcached := memcachedExt.NewDissector()
+	dissectorMemcached.Register(extensionMemcached)
+	extensionMemcached.Dissector = dissectorMemcached
+	Extensions = append(Extensions, extensionMemcached)
+	ExtensionsMap[extensionMemcached.Protocol.Name] = extensionMemcached
+
+	extensionThrift := &api.Extension{}
+	dissectorThrift := thriftExt.NewDissector()
+	dissectorThrift.Register(extensionThrift)
+	extensionThrift.Dissector = dissectorThrift
+	Extensions = append(Extensions, extensionThrift)
+	ExtensionsMap[extensionThrift.Protocol.Name] = extensionThrift
+
+	extensionZookeeper := &api.Extension{}
+	dissectorZookeeper := zookeeperExt.NewDissector()
+	dissectorZookeeper.Register(extensionZookeeper)
+	extensionZookeeper.Dissector = dissectorZookeeper
+	Extensions = append(Extensions, extensionZookeeper)
+	ExtensionsMap[extensionZookeeper.Protocol.Name] = extensionZookeeper
+
+	extensionAmqp10 := &api.Extension{}
+	dissectorAmqp10 := amqp10Ext.NewDissector()
+	dissectorAmqp10.Register(extensionAmqp10)
+	extensionAmqp10.Dissector = dissectorAmqp10
+	Extensions = append(Extensions, extensionAmqp10)
+	ExtensionsMap[extensionAmqp10.Protocol.Name] = extensionAmqp10
+
+	extensionPulsar := &api.Extension{}
+	dissectorPulsar := pulsarExt.NewDissector()
+	dissectorPulsar.Register(extensionPulsar)
+	extensionPulsar.Dissector = dissectorPulsar
+	Extensions = append(Extensions, extensionPulsar)
+	ExtensionsMap[extensionPulsar.Protocol.Name] = extensionPulsar
+
+	extensionTls := &api.Extension{}
+	dissectorTls := tlsExt.NewDissector()
+	dissectorTls.Register(extensionTls)
+	extensionTls.Dissector = dissectorTls
+	Extensions = append(Extensions, extensionTls)
+	ExtensionsMap[extensionTls.Protocol.Name] = extensionTls
+
 	sort.Slice(Extensions, func(i, j int) bool {
 		return Extensions[i].Protocol.Priority < Extensions[j].Protocol.Priority
 	})
@@ -0,0 +1,267 @@
+package memcached
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	magicRequest  = 0x80
+	magicResponse = 0x81
+	headerLength  = 24
+)
+
+const (
+	statusNoError          = 0x0000
+	statusKeyNotFound      = 0x0001
+	statusKeyExists        = 0x0002
+	statusValueTooLarge    = 0x0003
+	statusInvalidArguments = 0x0004
+	statusItemNotStored    = 0x0005
+	statusNonNumericValue  = 0x0006
+	statusWrongVbucket     = 0x0007
+	statusAuthError        = 0x0008
+	statusAuthContinue     = 0x0009
+	statusUnknownCommand   = 0x0081
+	statusOutOfMemory      = 0x0082
+	statusNotSupported     = 0x0083
+	statusInternalError    = 0x0084
+	statusBusy             = 0x0085
+	statusTemporaryFailure = 0x0086
+)
+
+var statuses = map[uint16]string{
+	statusNoError:          "No error",
+	statusKeyNotFound:      "Key not found",
+	statusKeyExists:        "Key exists",
+	statusValueTooLarge:    "Value too large",
+	statusInvalidArguments: "Invalid arguments",
+	statusItemNotStored:    "Item not stored",
+	statusNonNumericValue:  "Incr/Decr on non-numeric value",
+	statusWrongVbucket:     "The vbucket belongs to another server",
+	statusAuthError:        "Authentication error",
+	statusAuthContinue:     "Authentication continue",
+	statusUnknownCommand:   "Unknown command",
+	statusOutOfMemory:      "Out of memory",
+	statusNotSupported:     "Not supported",
+	statusInternalError:    "Internal error",
+	statusBusy:             "Busy",
+	statusTemporaryFailure: "Temporary failure",
+}
+
+const (
+	opGet        = 0x00
+	opIncrement  = 0x05
+	opDecrement  = 0x06
+	opQuit       = 0x07
+	opGetQ       = 0x09
+	opNoop       = 0x0a
+	opVersion    = 0x0b
+	opGetK       = 0x0c
+	opGetKQ      = 0x0d
+	opStat       = 0x10
+	opIncrementQ = 0x15
+	opDecrementQ = 0x16
+	opQuitQ      = 0x17
+	opVerbosity  = 0x1b
+	opGat        = 0x1d
+	opGatQ       = 0x1e
+	opSaslAuth   = 0x21
+	opSaslStep   = 0x22
+)
+
+// The opcodes are named after the text commands they stand for
+var opcodes = map[byte]string{
+	0x00: "get",
+	0x01: "set",
+	0x02: "add",
+	0x03: "replace",
+	0x04: "delete",
+	0x05: "incr",
+	0x06: "decr",
+	0x07: "quit",
+	0x08: "flush_all",
+	0x09: "getq",
+	0x0a: "noop",
+	0x0b: "version",
+	0x0c: "getk",
+	0x0d: "getkq",
+	0x0e: "append",
+	0x0f: "prepend",
+	0x10: "stats",
+	0x11: "setq",
+	0x12: "addq",
+	0x13: "replaceq",
+	0x14: "deleteq",
+	0x15: "incrq",
+	0x16: "decrq",
+	0x17: "quitq",
+	0x18: "flush_allq",
+	0x19: "appendq",
+	0x1a: "prependq",
+	0x1b: "verbosity",
+	0x1c: "touch",
+	0x1d: "gat",
+	0x1e: "gatq",
+	0x20: "sasl_list_mechs",
+	0x21: "sasl_auth",
+	0x22: "sasl_step",
+}
+
+// The quiet commands are only answered on a hit, if they are retrievals,
+// or on a failure otherwise
+var quietOpcodes = map[byte]bool{
+	0x09: true,
+	0x0d: true,
+	0x11: true,
+	0x12: true,
+	0x13: true,
+	0x14: true,
+	0x15: true,
+	0x16: true,
+	0x17: true,
+	0x18: true,
+	0x19: true,
+	0x1a: true,
+	0x1e: true,
+}
+
+type binaryPacket struct {
+	Magic    byte
+	Opcode   byte
+	DataType byte
+	Status   uint16
+	Opaque   uint32
+	Cas      uint64
+	Extras   []byte
+	Key      []byte
+	Value    []byte
+}
+
+func readBinaryPacket(r *bufio.Reader, magic byte) (*binaryPacket, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	p := &binaryPacket{
+		Magic:    header[0],
+		Opcode:   header[1],
+		DataType: header[5],
+		Status:   binary.BigEndian.Uint16(header[6:8]),
+		Opaque:   binary.BigEndian.Uint32(header[12:16]),
+		Cas:      binary.BigEndian.Uint64(header[16:24]),
+	}
+	if p.Magic != magic {
+		return nil, fmt.Errorf("unexpected magic: %#x", p.Magic)
+	}
+	if _, ok := opcodes[p.Opcode]; !ok {
+		return nil, fmt.Errorf("unknown opcode: %#x", p.Opcode)
+	}
+
+	keyLength := int(binary.BigEndian.Uint16(header[2:4]))
+	extrasLength := int(header[4])
+	bodyLength := int(binary.BigEndian.Uint32(header[8:12]))
+	if bodyLength > maxValueSize || keyLength+extrasLength > bodyLength {
+		return nil, errors.New("invalid body length")
+	}
+
+	body := make([]byte, bodyLength)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	p.Extras = body[:extrasLength]
+	p.Key = body[extrasLength : extrasLength+keyLength]
+	p.Value = body[extrasLength+keyLength:]
+	return p, nil
+}
+
+func parseBinaryRequest(p *binaryPacket) (*MemcachedRequest, error) {
+	request := &MemcachedRequest{
+		Protocol: protocolBinary,
+		Command:  opcodes[p.Opcode],
+		Cas:      p.Cas,
+		Opaque:   p.Opaque,
+	}
+	if len(p.Key) > 0 {
+		request.Keys = []string{string(p.Key)}
+	}
+
+	switch {
+	case len(p.Extras) == 8 && p.Opcode != opIncrement && p.Opcode != opDecrement:
+		// Flags and expiration of the storage commands
+		request.Flags = binary.BigEndian.Uint32(p.Extras[:4])
+		request.Exptime = int64(binary.BigEndian.Uint32(p.Extras[4:]))
+	case len(p.Extras) == 20 && (p.Opcode == opIncrement || p.Opcode == opDecrement || p.Opcode == opIncrementQ || p.Opcode == opDecrementQ):
+		request.Delta = binary.BigEndian.Uint64(p.Extras[:8])
+		request.Initial = binary.BigEndian.Uint64(p.Extras[8:16])
+		request.Exptime = int64(binary.BigEndian.Uint32(p.Extras[16:]))
+	case len(p.Extras) == 4 && p.Opcode == opVerbosity:
+		request.Arguments = []string{fmt.Sprint(binary.BigEndian.Uint32(p.Extras))}
+	case len(p.Extras) == 4:
+		// Expiration of touch, gat and flush
+		request.Exptime = int64(binary.BigEndian.Uint32(p.Extras))
+	case len(p.Extras) != 0:
+		return nil, fmt.Errorf("unexpected extras length of %s: %d", request.Command, len(p.Extras))
+	}
+
+	switch p.Opcode {
+	case opSaslAuth, opSaslStep:
+		// The value holds the credentials
+		request.ValueSize = len(p.Value)
+	default:
+		request.Value, request.ValueText, request.ValueSize = encodeValue(p.Value)
+	}
+
+	return request, nil
+}
+
+func parseBinaryResponse(p *binaryPacket) (*MemcachedResponse, error) {
+	response := &MemcachedResponse{
+		Protocol:   protocolBinary,
+		Status:     statuses[p.Status],
+		StatusCode: int(p.Status),
+		Opaque:     p.Opaque,
+		Cas:        p.Cas,
+	}
+	if response.Status == "" {
+		response.Status = fmt.Sprintf("Unknown status %#x", p.Status)
+	}
+
+	if p.Status != statusNoError && p.Status != statusAuthContinue {
+		// The value is the error message
+		response.Error = string(p.Value)
+		return response, nil
+	}
+
+	switch p.Opcode {
+	case opGet, opGetQ, opGetK, opGetKQ, opGat, opGatQ:
+		item := MemcachedValue{Key: string(p.Key), Cas: p.Cas}
+		if len(p.Extras) == 4 {
+			item.Flags = binary.BigEndian.Uint32(p.Extras)
+		}
+		item.Value, item.ValueText, item.Size = encodeValue(p.Value)
+		response.Values = []MemcachedValue{item}
+		response.ValueSize = item.Size
+	case opIncrement, opDecrement, opIncrementQ, opDecrementQ:
+		if len(p.Value) != 8 {
+			return nil, fmt.Errorf("unexpected value length of %s: %d", opcodes[p.Opcode], len(p.Value))
+		}
+		number := binary.BigEndian.Uint64(p.Value)
+		response.Number = &number
+	case opVersion:
+		response.Version = string(p.Value)
+	case opStat:
+		if len(p.Key) > 0 {
+			response.Stats = map[string]string{string(p.Key): string(p.Value)}
+		}
+	case opSaslAuth, opSaslStep:
+	default:
+		response.ValueSize = len(p.Value)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,224 @@
+package memcached
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var retrievalCommands = map[string]bool{
+	"get":   true,
+	"gets":  true,
+	"gat":   true,
+	"gats":  true,
+	"getq":  true,
+	"getk":  true,
+	"getkq": true,
+	"gatq":  true,
+}
+
+// connectionState keeps track of one side of a connection. The protocol is
+// told apart by the magic byte that starts every binary packet.
+type connectionState struct {
+	isClient bool
+	protocol string
+
+	// The text protocol answers the requests in order, so they are counted.
+	// The binary protocol echoes the opaque of a request in its response,
+	// and it's counted in case a client reuses it.
+	counter     int
+	occurrences map[uint32]int
+}
+
+func newConnectionState(b *bufio.Reader, isClient bool) (*connectionState, error) {
+	first, err := b.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &connectionState{
+		isClient:    isClient,
+		protocol:    protocolText,
+		occurrences: make(map[uint32]int),
+	}
+	if isClient && first[0] == magicRequest || !isClient && first[0] == magicResponse {
+		s.protocol = protocolBinary
+	}
+	return s, nil
+}
+
+// readRequest reads a request and returns it with the key of its response,
+// which is empty if it isn't answered. The request is nil for the commands
+// that aren't shown, like noop.
+func (s *connectionState) readRequest(b *bufio.Reader) (*MemcachedRequest, string, error) {
+	if s.protocol == protocolText {
+		request, err := readTextRequest(b)
+		if err != nil {
+			return nil, "", err
+		}
+		if request.Noreply || request.Command == "quit" {
+			return request, "", nil
+		}
+		key := fmt.Sprint(s.counter)
+		s.counter++
+		return request, key, nil
+	}
+
+	p, err := readBinaryPacket(b, magicRequest)
+	if err != nil {
+		return nil, "", err
+	}
+	request, err := parseBinaryRequest(p)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case p.Opcode == opNoop:
+		return nil, "", nil
+	case p.Opcode == opQuitQ:
+		return request, "", nil
+	case quietOpcodes[p.Opcode]:
+		return request, s.quietKey(p.Opcode, p.Opaque), nil
+	default:
+		return request, s.opaqueKey(p.Opaque), nil
+	}
+}
+
+// readResponse reads a response and returns it with the key of its
+// request. The response is nil for the commands that aren't shown.
+func (s *connectionState) readResponse(b *bufio.Reader) (*MemcachedResponse, string, error) {
+	if s.protocol == protocolText {
+		response, err := readTextResponse(b)
+		if err != nil {
+			return nil, "", err
+		}
+		key := fmt.Sprint(s.counter)
+		s.counter++
+		return response, key, nil
+	}
+
+	var stats map[string]string
+	for {
+		p, err := readBinaryPacket(b, magicResponse)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := parseBinaryResponse(p)
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch {
+		case p.Opcode == opNoop:
+			return nil, "", nil
+		case p.Opcode == opStat && response.Stats != nil:
+			// Every statistic comes in a packet of its own, until one
+			// without a key
+			if stats == nil {
+				stats = make(map[string]string)
+			}
+			for name, value := range response.Stats {
+				stats[name] = value
+			}
+			continue
+		case p.Opcode == opStat:
+			response.Stats = stats
+		}
+
+		if quietOpcodes[p.Opcode] {
+			return response, s.quietKey(p.Opcode, p.Opaque), nil
+		}
+		return response, s.opaqueKey(p.Opaque), nil
+	}
+}
+
+func (s *connectionState) opaqueKey(opaque uint32) string {
+	occurrence := s.occurrences[opaque]
+	s.occurrences[opaque]++
+	return fmt.Sprintf("%d_%d", opaque, occurrence)
+}
+
+// quietKey isn't counted, since a quiet command may never be answered.
+func (s *connectionState) quietKey(opcode byte, opaque uint32) string {
+	return fmt.Sprintf("%d_%s", opaque, opcodes[opcode])
+}
+
+// resolveHits tells which of the keys of a retrieval were found.
+func resolveHits(request *MemcachedRequest, response *MemcachedResponse) {
+	if !retrievalCommands[request.Command] {
+		return
+	}
+
+	found := make(map[string]bool)
+	for i := range response.Values {
+		// Only getk and getkq return the key in the binary protocol
+		if response.Values[i].Key == "" && len(request.Keys) == 1 {
+			response.Values[i].Key = request.Keys[0]
+		}
+		found[response.Values[i].Key] = true
+	}
+
+	for _, key := range request.Keys {
+		if found[key] {
+			response.Hits++
+		} else {
+			response.Misses++
+			response.MissedKeys = append(response.MissedKeys, key)
+		}
+	}
+	hit := response.Misses == 0
+	response.Hit = &hit
+}
+
+// encodeValue returns the value in base64, and as text if it's valid UTF-8.
+func encodeValue(value []byte) (encoded string, text string, size int) {
+	encoded = base64.StdEncoding.EncodeToString(value)
+	if utf8.Valid(value) {
+		text = string(value)
+	}
+	return encoded, text, len(value)
+}
+
+func identity(tcpID *api.TcpID, isClient bool, key string) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, key)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, key)
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, request *MemcachedRequest, key string, reqResMatcher *requestResponseMatcher) {
+	var item *api.OutputChannelItem
+	if key == "" {
+		item = reqResMatcher.prepareUnacknowledged(request, captureTime, progress.Current())
+	} else {
+		item = reqResMatcher.registerRequest(identity(tcpID, true, key), request, captureTime, progress.Current())
+	}
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, response *MemcachedResponse, key string, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerResponse(identity(tcpID, false, key), response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
@@ -0,0 +1,230 @@
+package memcached
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	var keys []string
+	if list, ok := request["keys"].([]interface{}); ok {
+		for _, key := range list {
+			keys = append(keys, key.(string))
+		}
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Protocol",
+			Value:    request["protocol"].(string),
+			Selector: `request.protocol`,
+		},
+		{
+			Name:     "Command",
+			Value:    request["command"].(string),
+			Selector: `request.command`,
+		},
+		{
+			Name:     "Keys",
+			Value:    strings.Join(keys, " "),
+			Selector: `request.keys`,
+		},
+		{
+			Name:     "Flags",
+			Value:    request["flags"],
+			Selector: `request.flags`,
+		},
+		{
+			Name:     "Expiration",
+			Value:    request["exptime"],
+			Selector: `request.exptime`,
+		},
+		{
+			Name:     "CAS",
+			Value:    request["cas"],
+			Selector: `request.cas`,
+		},
+		{
+			Name:     "Delta",
+			Value:    request["delta"],
+			Selector: `request.delta`,
+		},
+		{
+			Name:     "Initial",
+			Value:    request["initial"],
+			Selector: `request.initial`,
+		},
+		{
+			Name:     "No Reply",
+			Value:    request["noreply"],
+			Selector: `request.noreply`,
+		},
+		{
+			Name:     "Opaque",
+			Value:    request["opaque"],
+			Selector: `request.opaque`,
+		},
+		{
+			Name:     "Arguments",
+			Value:    request["arguments"],
+			Selector: `request.arguments`,
+		},
+		{
+			Name:     "Value Size",
+			Value:    request["valueSize"].(float64),
+			Selector: `request.valueSize`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if value, ok := request["value"].(string); ok && value != "" {
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Value",
+			Encoding: "base64",
+			Data:     value,
+			Selector: `request.value`,
+		})
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	if response["status"].(string) == "" {
+		// The command isn't answered
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Status",
+			Value:    response["status"].(string),
+			Selector: `response.status`,
+		},
+		{
+			Name:     "Status Code",
+			Value:    response["statusCode"].(float64),
+			Selector: `response.statusCode`,
+		},
+		{
+			Name:     "Error",
+			Value:    response["error"],
+			Selector: `response.error`,
+		},
+		{
+			Name:     "Hit",
+			Value:    response["hit"],
+			Selector: `response.hit`,
+		},
+		{
+			Name:     "Hits",
+			Value:    response["hits"].(float64),
+			Selector: `response.hits`,
+		},
+		{
+			Name:     "Misses",
+			Value:    response["misses"].(float64),
+			Selector: `response.misses`,
+		},
+		{
+			Name:     "Missed Keys",
+			Value:    response["missedKeys"],
+			Selector: `response.missedKeys`,
+		},
+		{
+			Name:     "Value Size",
+			Value:    response["valueSize"].(float64),
+			Selector: `response.valueSize`,
+		},
+		{
+			Name:     "Number",
+			Value:    response["number"],
+			Selector: `response.number`,
+		},
+		{
+			Name:     "Version",
+			Value:    response["version"],
+			Selector: `response.version`,
+		},
+		{
+			Name:     "CAS",
+			Value:    response["cas"],
+			Selector: `response.cas`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if values, ok := response["values"].([]interface{}); ok && len(values) > 0 {
+		var table []api.TableData
+		for i, value := range values {
+			v := value.(map[string]interface{})
+			table = append(table, api.TableData{
+				Name:     v["key"].(string),
+				Value:    fmt.Sprintf("%v bytes, flags %v", v["size"], v["flags"]),
+				Selector: fmt.Sprintf("response.values[%d].size", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Values",
+			Data:  string(obj),
+		})
+
+		for i, value := range values {
+			v := value.(map[string]interface{})
+			repResponse = append(repResponse, api.SectionData{
+				Type:     api.BODY,
+				Title:    v["key"].(string),
+				Encoding: "base64",
+				Data:     v["value"].(string),
+				Selector: fmt.Sprintf("response.values[%d].value", i),
+			})
+		}
+	}
+
+	if stats, ok := response["stats"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Stats",
+			Data:  representMapAsTable(stats, `response.stats`),
+		})
+	}
+
+	return
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
@@ -0,0 +1,164 @@
+package memcached
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "memcached",
+	Version:         "1.6",
+	Abbreviation:    "MEMC",
+	LongName:        "Memcached Protocol",
+	Macro:           "memcached",
+	BackgroundColor: "#2b7489",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://github.com/memcached/memcached/blob/master/doc/protocol.txt",
+	Ports:           []string{"11211"},
+	Layer4:          "tcp",
+	Priority:        11,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	state, err := newConnectionState(b, reader.GetIsClient())
+	if err != nil {
+		return err
+	}
+
+	for {
+		if reader.GetIsClient() {
+			request, key, err := state.readRequest(b)
+			if err != nil {
+				return err
+			}
+			reader.GetParent().SetProtocol(&protocol)
+			if request != nil {
+				handleClientStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), request, key, reqResMatcher)
+			}
+		} else {
+			response, key, err := state.readResponse(b)
+			if err != nil {
+				return err
+			}
+			reader.GetParent().SetProtocol(&protocol)
+			if response != nil {
+				handleServerStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCaptureTime(), reader.GetEmitter(), response, key, reqResMatcher)
+			}
+		}
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	status := 0
+	statusQuery := ""
+	if code, ok := entry.Response["statusCode"].(float64); ok && code != 0 {
+		status = int(code)
+		statusQuery = fmt.Sprintf(`response.statusCode == %d`, status)
+	}
+
+	method := entry.Request["command"].(string)
+	methodQuery := fmt.Sprintf(`request.command == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	if keys, ok := entry.Request["keys"].([]interface{}); ok && len(keys) > 0 {
+		var names []string
+		for _, key := range keys {
+			names = append(names, key.(string))
+		}
+		summary = strings.Join(names, " ")
+		summaryQuery = fmt.Sprintf(`request.keys[0] == %q`, names[0])
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       status,
+		StatusQuery:  statusQuery,
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representRequest(request)
+	repResponse := representResponse(response)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`memcached`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
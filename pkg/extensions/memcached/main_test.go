@@ -0,0 +1,200 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "memcached", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"memcached": `protocol.name == "memcached"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func binaryPacketBytes(magic byte, opcode byte, status uint16, opaque uint32, extras []byte, key string, value string) []byte {
+	header := make([]byte, headerLength)
+	header[0] = magic
+	header[1] = opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(key)))
+	header[4] = byte(len(extras))
+	binary.BigEndian.PutUint16(header[6:8], status)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(extras)+len(key)+len(value)))
+	binary.BigEndian.PutUint32(header[12:16], opaque)
+	return append(append(append(header, extras...), key...), value...)
+}
+
+func dissect(t *testing.T, client []byte, server []byte) map[string]*api.Entry {
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, client, server) {
+		key := entry.Request["command"].(string)
+		if keys, ok := entry.Request["keys"].([]interface{}); ok {
+			key += " " + keys[0].(string)
+		}
+		if _, ok := entries[key]; ok {
+			key += " again"
+		}
+		entries[key] = entry
+	}
+	return entries
+}
+
+func TestDissectText(t *testing.T) {
+	client := strings.Join([]string{
+		"set user:1 0 300 5\r\nalice\r\n",
+		"get user:1 user:2\r\n",
+		"gets user:1\r\n",
+		"incr hits 1\r\n",
+		"delete user:9 noreply\r\n",
+		"delete user:2\r\n",
+		"add user:1 0 0 3\r\nbob\r\n",
+		"stats\r\n",
+	}, "")
+
+	server := strings.Join([]string{
+		"STORED\r\n",
+		"VALUE user:1 0 5\r\nalice\r\nEND\r\n",
+		"VALUE user:1 0 5 42\r\nalice\r\nEND\r\n",
+		"7\r\n",
+		"NOT_FOUND\r\n",
+		"NOT_STORED\r\n",
+		"STAT pid 1\r\nSTAT uptime 10\r\nEND\r\n",
+	}, "")
+
+	entries := dissect(t, []byte(client), []byte(server))
+	assert.Len(t, entries, 8)
+	dissector := NewDissector()
+
+	set := entries["set user:1"]
+	assert.Equal(t, "text", set.Request["protocol"])
+	assert.Equal(t, float64(300), set.Request["exptime"])
+	assert.Equal(t, "alice", set.Request["valueText"])
+	assert.Equal(t, float64(5), set.Request["valueSize"])
+	assert.Equal(t, "STORED", set.Response["status"])
+
+	get := entries["get user:1"]
+	assert.Equal(t, false, get.Response["hit"])
+	assert.Equal(t, float64(1), get.Response["hits"])
+	assert.Equal(t, float64(1), get.Response["misses"])
+	assert.Equal(t, []interface{}{"user:2"}, get.Response["missedKeys"])
+	assert.Equal(t, float64(5), get.Response["valueSize"])
+	summary := dissector.Summarize(get)
+	assert.Equal(t, "get", summary.Method)
+	assert.Equal(t, `request.command == "get"`, summary.MethodQuery)
+	assert.Equal(t, "user:1 user:2", summary.Summary)
+	assert.Equal(t, `request.keys[0] == "user:1"`, summary.SummaryQuery)
+
+	gets := entries["gets user:1"]
+	assert.Equal(t, true, gets.Response["hit"])
+	assert.Equal(t, float64(42), gets.Response["values"].([]interface{})[0].(map[string]interface{})["cas"])
+
+	incr := entries["incr hits"]
+	assert.Equal(t, float64(7), incr.Response["number"])
+
+	noreply := entries["delete user:9"]
+	assert.Equal(t, true, noreply.Request["noreply"])
+	assert.Equal(t, "", noreply.Response["status"])
+
+	deleted := entries["delete user:2"]
+	assert.Equal(t, "NOT_FOUND", deleted.Response["status"])
+
+	add := entries["add user:1"]
+	summary = dissector.Summarize(add)
+	assert.Equal(t, statusItemNotStored, summary.Status)
+	assert.Equal(t, `response.statusCode == 5`, summary.StatusQuery)
+
+	stats := entries["stats"]
+	assert.Equal(t, map[string]interface{}{"pid": "1", "uptime": "10"}, stats.Response["stats"])
+}
+
+func TestDissectBinary(t *testing.T) {
+	flags := []byte{0, 0, 0, 2}
+	client := bytes.Join([][]byte{
+		binaryPacketBytes(magicRequest, 0x01, 0, 1, []byte{0, 0, 0, 2, 0, 0, 0, 60}, "user:1", "alice"),
+		binaryPacketBytes(magicRequest, opGet, 0, 2, nil, "user:1", ""),
+		binaryPacketBytes(magicRequest, opGetQ, 0, 3, nil, "user:2", ""),
+		binaryPacketBytes(magicRequest, opGetKQ, 0, 4, nil, "user:3", ""),
+		binaryPacketBytes(magicRequest, opNoop, 0, 5, nil, "", ""),
+		binaryPacketBytes(magicRequest, opGet, 0, 2, nil, "user:4", ""),
+		binaryPacketBytes(magicRequest, opStat, 0, 6, nil, "", ""),
+		binaryPacketBytes(magicRequest, opSaslAuth, 0, 7, nil, "PLAIN", "\x00app\x00hunter2"),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		binaryPacketBytes(magicResponse, 0x01, statusNoError, 1, nil, "", ""),
+		binaryPacketBytes(magicResponse, opGet, statusNoError, 2, flags, "", "alice"),
+		binaryPacketBytes(magicResponse, opGetKQ, statusNoError, 4, flags, "user:3", "carol"),
+		binaryPacketBytes(magicResponse, opNoop, statusNoError, 5, nil, "", ""),
+		binaryPacketBytes(magicResponse, opGet, statusKeyNotFound, 2, nil, "", "Not found"),
+		binaryPacketBytes(magicResponse, opStat, statusNoError, 6, nil, "pid", "1"),
+		binaryPacketBytes(magicResponse, opStat, statusNoError, 6, nil, "uptime", "10"),
+		binaryPacketBytes(magicResponse, opStat, statusNoError, 6, nil, "", ""),
+		binaryPacketBytes(magicResponse, opSaslAuth, statusNoError, 7, nil, "", "Authenticated"),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 6)
+
+	data, err := json.Marshal(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "aHVudGVyMg")
+	assert.NotContains(t, string(data), "hunter2")
+
+	set := entries["set user:1"]
+	assert.Equal(t, "binary", set.Request["protocol"])
+	assert.Equal(t, float64(2), set.Request["flags"])
+	assert.Equal(t, float64(60), set.Request["exptime"])
+	assert.Equal(t, "No error", set.Response["status"])
+
+	hit := entries["get user:1"]
+	assert.Equal(t, true, hit.Response["hit"])
+	value := hit.Response["values"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "user:1", value["key"])
+	assert.Equal(t, "alice", value["valueText"])
+	assert.Equal(t, float64(2), value["flags"])
+
+	quiet := entries["getkq user:3"]
+	assert.Equal(t, true, quiet.Response["hit"])
+
+	miss := entries["get user:4"]
+	assert.Equal(t, false, miss.Response["hit"])
+	assert.Equal(t, "Not found", miss.Response["error"])
+	summary := NewDissector().Summarize(miss)
+	assert.Equal(t, statusKeyNotFound, summary.Status)
+
+	stats := entries["stats"]
+	assert.Equal(t, map[string]interface{}{"pid": "1", "uptime": "10"}, stats.Response["stats"])
+
+	auth := entries["sasl_auth PLAIN"]
+	assert.Equal(t, float64(len("\x00app\x00hunter2")), auth.Request["valueSize"])
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
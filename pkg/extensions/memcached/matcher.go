@@ -0,0 +1,124 @@
+package memcached
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{counter}` for the text protocol
+// and `{client_ip}_{server_ip}_{client_port}_{server_port}_{opaque}_{occurrence}` for the binary protocol.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *MemcachedRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMemcachedMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MemcachedPayload{
+			Data: &MemcachedWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseMemcachedMessage := response.(*api.GenericMessage)
+		if responseMemcachedMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestMemcachedMessage, responseMemcachedMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestMemcachedMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *MemcachedResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseMemcachedMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MemcachedPayload{
+			Data: &MemcachedWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestMemcachedMessage := request.(*api.GenericMessage)
+		if !requestMemcachedMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestMemcachedMessage, &responseMemcachedMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseMemcachedMessage)
+	return nil
+}
+
+// prepareUnacknowledged pairs a command that gets no reply, like one with
+// noreply or a quiet quit, with an empty response.
+func (matcher *requestResponseMatcher) prepareUnacknowledged(request *MemcachedRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMemcachedMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MemcachedPayload{
+			Data: &MemcachedWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseMemcachedMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: MemcachedPayload{
+			Data: &MemcachedWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &MemcachedResponse{Protocol: request.Protocol},
+			},
+		},
+	}
+	return matcher.preparePair(&requestMemcachedMessage, &responseMemcachedMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestMemcachedMessage *api.GenericMessage, responseMemcachedMessage *api.GenericMessage) *api.OutputChannelItem {
+	request := requestMemcachedMessage.Payload.(MemcachedPayload).Data.(*MemcachedWrapper).Details.(*MemcachedRequest)
+	response := responseMemcachedMessage.Payload.(MemcachedPayload).Data.(*MemcachedWrapper).Details.(*MemcachedResponse)
+	resolveHits(request, response)
+
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestMemcachedMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestMemcachedMessage,
+			Response: *responseMemcachedMessage,
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package memcached
+
+import (
+	"encoding/json"
+)
+
+type MemcachedPayload struct {
+	Data interface{}
+}
+
+type MemcachedPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h MemcachedPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type MemcachedWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+type MemcachedRequest struct {
+	Protocol  string   `json:"protocol"`
+	Command   string   `json:"command"`
+	Keys      []string `json:"keys"`
+	Flags     uint32   `json:"flags,omitempty"`
+	Exptime   int64    `json:"exptime,omitempty"`
+	Cas       uint64   `json:"cas,omitempty"`
+	Delta     uint64   `json:"delta,omitempty"`
+	Initial   uint64   `json:"initial,omitempty"`
+	Noreply   bool     `json:"noreply,omitempty"`
+	Opaque    uint32   `json:"opaque,omitempty"`
+	Arguments []string `json:"arguments,omitempty"`
+	Value     string   `json:"value,omitempty"`
+	ValueText string   `json:"valueText,omitempty"`
+	ValueSize int      `json:"valueSize"`
+}
+
+// MemcachedValue is an item returned by a retrieval command. The value is
+// base64 encoded and also given as text when it's valid UTF-8.
+type MemcachedValue struct {
+	Key       string `json:"key"`
+	Flags     uint32 `json:"flags"`
+	Cas       uint64 `json:"cas,omitempty"`
+	Value     string `json:"value"`
+	ValueText string `json:"valueText,omitempty"`
+	Size      int    `json:"size"`
+}
+
+type MemcachedResponse struct {
+	Protocol   string            `json:"protocol"`
+	Status     string            `json:"status"`
+	StatusCode int               `json:"statusCode"`
+	Error      string            `json:"error,omitempty"`
+	Opaque     uint32            `json:"opaque,omitempty"`
+	Cas        uint64            `json:"cas,omitempty"`
+	Values     []MemcachedValue  `json:"values,omitempty"`
+	ValueSize  int               `json:"valueSize"`
+	Number     *uint64           `json:"number,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Stats      map[string]string `json:"stats,omitempty"`
+	Hit        *bool             `json:"hit,omitempty"`
+	Hits       int               `json:"hits"`
+	Misses     int               `json:"misses"`
+	MissedKeys []string          `json:"missedKeys,omitempty"`
+}
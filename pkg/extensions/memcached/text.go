@@ -0,0 +1,282 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	protocolText   = "text"
+	protocolBinary = "binary"
+
+	// A get may ask for many keys on a single line
+	maxLine      = 64 * 1024
+	maxKeyLength = 250
+	maxValueSize = 64 * 1024 * 1024
+	noreply      = "noreply"
+)
+
+// Text responses in terms of the status codes of the binary protocol, so
+// that both can be filtered alike
+var textStatuses = map[string]int{
+	"STORED":       statusNoError,
+	"DELETED":      statusNoError,
+	"TOUCHED":      statusNoError,
+	"OK":           statusNoError,
+	"RESET":        statusNoError,
+	"END":          statusNoError,
+	"NOT_FOUND":    statusKeyNotFound,
+	"EXISTS":       statusKeyExists,
+	"NOT_STORED":   statusItemNotStored,
+	"ERROR":        statusUnknownCommand,
+	"CLIENT_ERROR": statusInvalidArguments,
+	"SERVER_ERROR": statusInternalError,
+}
+
+func readTextRequest(r *bufio.Reader) (*MemcachedRequest, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	request := &MemcachedRequest{Protocol: protocolText, Command: fields[0]}
+	args := fields[1:]
+	malformed := fmt.Errorf("malformed %s command: %q", request.Command, line)
+
+	switch request.Command {
+	case "get", "gets":
+		if len(args) == 0 {
+			return nil, malformed
+		}
+		request.Keys = args
+	case "gat", "gats":
+		if len(args) < 2 {
+			return nil, malformed
+		}
+		if request.Exptime, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+			return nil, malformed
+		}
+		request.Keys = args[1:]
+	case "set", "add", "replace", "append", "prepend", "cas":
+		// <command> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]
+		args, request.Noreply = trimNoreply(args)
+		expected := 4
+		if request.Command == "cas" {
+			expected++
+		}
+		if len(args) != expected {
+			return nil, malformed
+		}
+		request.Keys = args[:1]
+		flags, err1 := strconv.ParseUint(args[1], 10, 32)
+		exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+		size, err3 := strconv.Atoi(args[3])
+		if err1 != nil || err2 != nil || err3 != nil || size < 0 || size > maxValueSize {
+			return nil, malformed
+		}
+		if request.Command == "cas" {
+			if request.Cas, err = strconv.ParseUint(args[4], 10, 64); err != nil {
+				return nil, malformed
+			}
+		}
+		request.Flags = uint32(flags)
+		request.Exptime = exptime
+		value, err := readData(r, size)
+		if err != nil {
+			return nil, err
+		}
+		request.Value, request.ValueText, request.ValueSize = encodeValue(value)
+	case "delete":
+		// delete <key> [<time>] [noreply], where the time must be 0
+		args, request.Noreply = trimNoreply(args)
+		if len(args) != 1 && !(len(args) == 2 && args[1] == "0") {
+			return nil, malformed
+		}
+		request.Keys = args[:1]
+	case "incr", "decr":
+		args, request.Noreply = trimNoreply(args)
+		if len(args) != 2 {
+			return nil, malformed
+		}
+		request.Keys = args[:1]
+		if request.Delta, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			return nil, malformed
+		}
+	case "touch":
+		args, request.Noreply = trimNoreply(args)
+		if len(args) != 2 {
+			return nil, malformed
+		}
+		request.Keys = args[:1]
+		if request.Exptime, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return nil, malformed
+		}
+	case "flush_all", "verbosity":
+		args, request.Noreply = trimNoreply(args)
+		if len(args) > 1 {
+			return nil, malformed
+		}
+		request.Arguments = args
+	case "stats":
+		request.Arguments = args
+	case "version", "quit":
+		if len(args) != 0 {
+			return nil, malformed
+		}
+	default:
+		return nil, fmt.Errorf("unknown command: %q", request.Command)
+	}
+
+	for _, key := range request.Keys {
+		if err := validateKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+// readTextResponse reads a reply line, or the items and statistics that
+// end with END.
+func readTextResponse(r *bufio.Reader) (*MemcachedResponse, error) {
+	response := &MemcachedResponse{Protocol: protocolText}
+	for first := true; ; first = false {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil, errors.New("empty response")
+		}
+
+		switch fields[0] {
+		case "VALUE":
+			// VALUE <key> <flags> <bytes> [<cas unique>]
+			if len(fields) != 4 && len(fields) != 5 {
+				return nil, fmt.Errorf("malformed VALUE: %q", line)
+			}
+			flags, err1 := strconv.ParseUint(fields[2], 10, 32)
+			size, err2 := strconv.Atoi(fields[3])
+			if err1 != nil || err2 != nil || size < 0 || size > maxValueSize || validateKey(fields[1]) != nil {
+				return nil, fmt.Errorf("malformed VALUE: %q", line)
+			}
+			item := MemcachedValue{Key: fields[1], Flags: uint32(flags)}
+			if len(fields) == 5 {
+				if item.Cas, err = strconv.ParseUint(fields[4], 10, 64); err != nil {
+					return nil, fmt.Errorf("malformed VALUE: %q", line)
+				}
+			}
+			value, err := readData(r, size)
+			if err != nil {
+				return nil, err
+			}
+			item.Value, item.ValueText, item.Size = encodeValue(value)
+			response.Values = append(response.Values, item)
+			response.ValueSize += item.Size
+			continue
+		case "STAT":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("malformed STAT: %q", line)
+			}
+			if response.Stats == nil {
+				response.Stats = make(map[string]string)
+			}
+			response.Stats[fields[1]] = strings.Join(fields[2:], " ")
+			continue
+		case "END":
+			response.Status = fields[0]
+			return response, nil
+		}
+
+		if !first {
+			return nil, fmt.Errorf("unexpected response in a list of items: %q", line)
+		}
+
+		if code, ok := textStatuses[fields[0]]; ok {
+			response.Status = fields[0]
+			response.StatusCode = code
+			if code >= statusUnknownCommand || code == statusInvalidArguments {
+				response.Error = strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+			} else if len(fields) != 1 {
+				return nil, fmt.Errorf("malformed %s: %q", fields[0], line)
+			}
+			return response, nil
+		}
+		if fields[0] == "VERSION" && len(fields) >= 2 {
+			response.Status = fields[0]
+			response.Version = strings.Join(fields[1:], " ")
+			return response, nil
+		}
+		// The new value after incr or decr
+		if number, err := strconv.ParseUint(line, 10, 64); err == nil {
+			response.Number = &number
+			return response, nil
+		}
+		return nil, fmt.Errorf("unknown response: %q", line)
+	}
+}
+
+func trimNoreply(args []string) ([]string, bool) {
+	if len(args) > 0 && args[len(args)-1] == noreply {
+		return args[:len(args)-1], true
+	}
+	return args, false
+}
+
+func validateKey(key string) error {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return fmt.Errorf("invalid key length: %d", len(key))
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return fmt.Errorf("invalid key: %q", key)
+		}
+	}
+	return nil
+}
+
+// readData reads a data block and the CRLF that ends it.
+func readData(r *bufio.Reader, size int) ([]byte, error) {
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return nil, errors.New("data block doesn't end with CRLF")
+	}
+	return data[:size], nil
+}
+
+// readLine reads a command or a response line without its CRLF ending.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLine {
+			return "", errors.New("line is too long")
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", errors.New("line doesn't end with CRLF")
+	}
+	return string(line[:len(line)-2]), nil
+}
@@ -0,0 +1,379 @@
+package mongodb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	bsonDouble              = 0x01
+	bsonString              = 0x02
+	bsonDocument            = 0x03
+	bsonArray               = 0x04
+	bsonBinary              = 0x05
+	bsonUndefined           = 0x06
+	bsonObjectID            = 0x07
+	bsonBoolean             = 0x08
+	bsonDateTime            = 0x09
+	bsonNull                = 0x0a
+	bsonRegex               = 0x0b
+	bsonDBPointer           = 0x0c
+	bsonJavaScript          = 0x0d
+	bsonSymbol              = 0x0e
+	bsonJavaScriptWithScope = 0x0f
+	bsonInt32               = 0x10
+	bsonTimestamp           = 0x11
+	bsonInt64               = 0x12
+	bsonDecimal128          = 0x13
+	bsonMinKey              = 0xff
+	bsonMaxKey              = 0x7f
+
+	minDocumentLength = 5
+	maxNestingDepth   = 100
+)
+
+var errInvalidDocument = errors.New("invalid BSON document")
+
+// decodeDocument decodes a BSON document into a JSON-compatible map. The
+// values that have no JSON counterpart are represented in the relaxed
+// MongoDB Extended JSON v2 format, e.g. {"$oid": "..."}. The keys are also
+// returned in their original order since the first key of a command
+// document is the command name.
+func decodeDocument(data []byte) (map[string]interface{}, []string, error) {
+	return decodeDocumentAt(data, 0)
+}
+
+func decodeDocumentAt(data []byte, depth int) (map[string]interface{}, []string, error) {
+	if depth > maxNestingDepth {
+		return nil, nil, errors.New("BSON document is nested too deeply")
+	}
+	if len(data) < minDocumentLength || int(binary.LittleEndian.Uint32(data)) != len(data) || data[len(data)-1] != 0 {
+		return nil, nil, errInvalidDocument
+	}
+
+	document := make(map[string]interface{})
+	keys := make([]string, 0)
+	b := data[4 : len(data)-1]
+	for len(b) > 0 {
+		elementType := b[0]
+		i := bytes.IndexByte(b[1:], 0)
+		if i < 0 {
+			return nil, nil, errInvalidDocument
+		}
+		key := string(b[1 : i+1])
+		b = b[i+2:]
+
+		value, n, err := decodeValue(elementType, b, depth)
+		if err != nil {
+			return nil, nil, err
+		}
+		b = b[n:]
+
+		if _, ok := document[key]; !ok {
+			keys = append(keys, key)
+		}
+		document[key] = value
+	}
+
+	return document, keys, nil
+}
+
+func decodeArray(data []byte, depth int) ([]interface{}, error) {
+	document, keys, err := decodeDocumentAt(data, depth)
+	if err != nil {
+		return nil, err
+	}
+	array := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		array = append(array, document[key])
+	}
+	return array, nil
+}
+
+// embeddedDocument decodes the document under a key of the document at the
+// start of data, keeping the order of its keys.
+func embeddedDocument(data []byte, key string) (map[string]interface{}, []string) {
+	n, err := documentLength(data)
+	if err != nil {
+		return nil, nil
+	}
+	b := data[4 : n-1]
+	for len(b) > 0 {
+		elementType := b[0]
+		i := bytes.IndexByte(b[1:], 0)
+		if i < 0 {
+			return nil, nil
+		}
+		name := string(b[1 : i+1])
+		b = b[i+2:]
+
+		if name == key && elementType == bsonDocument {
+			m, err := documentLength(b)
+			if err != nil {
+				return nil, nil
+			}
+			document, keys, err := decodeDocument(b[:m])
+			if err != nil {
+				return nil, nil
+			}
+			return document, keys
+		}
+
+		_, m, err := decodeValue(elementType, b, 0)
+		if err != nil {
+			return nil, nil
+		}
+		b = b[m:]
+	}
+	return nil, nil
+}
+
+// documentLength returns the declared length of the document at the start
+// of b after checking that it fits.
+func documentLength(b []byte) (int, error) {
+	if len(b) < 4 {
+		return 0, errInvalidDocument
+	}
+	n := int(int32(binary.LittleEndian.Uint32(b)))
+	if n < minDocumentLength || n > len(b) {
+		return 0, errInvalidDocument
+	}
+	return n, nil
+}
+
+func decodeString(b []byte) (string, int, error) {
+	if len(b) < 4 {
+		return "", 0, errInvalidDocument
+	}
+	n := int(int32(binary.LittleEndian.Uint32(b)))
+	if n < 1 || 4+n > len(b) || b[4+n-1] != 0 {
+		return "", 0, errInvalidDocument
+	}
+	return string(b[4 : 4+n-1]), 4 + n, nil
+}
+
+func decodeCString(b []byte) (string, int, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", 0, errInvalidDocument
+	}
+	return string(b[:i]), i + 1, nil
+}
+
+func fixedLength(b []byte, n int) error {
+	if len(b) < n {
+		return errInvalidDocument
+	}
+	return nil
+}
+
+// decodeValue decodes a single element value and returns the number of
+// bytes that it spans.
+func decodeValue(elementType byte, b []byte, depth int) (interface{}, int, error) {
+	switch elementType {
+	case bsonDouble:
+		if err := fixedLength(b, 8); err != nil {
+			return nil, 0, err
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		switch {
+		case math.IsNaN(v):
+			return map[string]interface{}{"$numberDouble": "NaN"}, 8, nil
+		case math.IsInf(v, 1):
+			return map[string]interface{}{"$numberDouble": "Infinity"}, 8, nil
+		case math.IsInf(v, -1):
+			return map[string]interface{}{"$numberDouble": "-Infinity"}, 8, nil
+		}
+		return v, 8, nil
+	case bsonString, bsonSymbol:
+		return decodeString(b)
+	case bsonJavaScript:
+		v, n, err := decodeString(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		return map[string]interface{}{"$code": v}, n, nil
+	case bsonDocument:
+		n, err := documentLength(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := decodeDocumentAt(b[:n], depth+1)
+		return v, n, err
+	case bsonArray:
+		n, err := documentLength(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, err := decodeArray(b[:n], depth+1)
+		return v, n, err
+	case bsonBinary:
+		if err := fixedLength(b, 5); err != nil {
+			return nil, 0, err
+		}
+		n := int(int32(binary.LittleEndian.Uint32(b)))
+		if n < 0 || 5+n > len(b) {
+			return nil, 0, errInvalidDocument
+		}
+		return map[string]interface{}{
+			"$binary": map[string]interface{}{
+				"base64":  base64.StdEncoding.EncodeToString(b[5 : 5+n]),
+				"subType": fmt.Sprintf("%02x", b[4]),
+			},
+		}, 5 + n, nil
+	case bsonUndefined:
+		return map[string]interface{}{"$undefined": true}, 0, nil
+	case bsonObjectID:
+		if err := fixedLength(b, 12); err != nil {
+			return nil, 0, err
+		}
+		return map[string]interface{}{"$oid": hex.EncodeToString(b[:12])}, 12, nil
+	case bsonBoolean:
+		if err := fixedLength(b, 1); err != nil {
+			return nil, 0, err
+		}
+		return b[0] != 0, 1, nil
+	case bsonDateTime:
+		if err := fixedLength(b, 8); err != nil {
+			return nil, 0, err
+		}
+		ms := int64(binary.LittleEndian.Uint64(b))
+		return map[string]interface{}{"$date": time.Unix(0, ms*int64(time.Millisecond)).UTC().Format("2006-01-02T15:04:05.000Z07:00")}, 8, nil
+	case bsonNull:
+		return nil, 0, nil
+	case bsonRegex:
+		pattern, n1, err := decodeCString(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		options, n2, err := decodeCString(b[n1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		return map[string]interface{}{
+			"$regularExpression": map[string]interface{}{
+				"pattern": pattern,
+				"options": options,
+			},
+		}, n1 + n2, nil
+	case bsonDBPointer:
+		ref, n, err := decodeString(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := fixedLength(b[n:], 12); err != nil {
+			return nil, 0, err
+		}
+		return map[string]interface{}{
+			"$dbPointer": map[string]interface{}{
+				"$ref": ref,
+				"$id":  map[string]interface{}{"$oid": hex.EncodeToString(b[n : n+12])},
+			},
+		}, n + 12, nil
+	case bsonJavaScriptWithScope:
+		n, err := documentLength(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		code, m, err := decodeString(b[4:n])
+		if err != nil {
+			return nil, 0, err
+		}
+		scope, _, err := decodeDocumentAt(b[4+m:n], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return map[string]interface{}{"$code": code, "$scope": scope}, n, nil
+	case bsonInt32:
+		if err := fixedLength(b, 4); err != nil {
+			return nil, 0, err
+		}
+		return int32(binary.LittleEndian.Uint32(b)), 4, nil
+	case bsonTimestamp:
+		if err := fixedLength(b, 8); err != nil {
+			return nil, 0, err
+		}
+		return map[string]interface{}{
+			"$timestamp": map[string]interface{}{
+				"t": binary.LittleEndian.Uint32(b[4:8]),
+				"i": binary.LittleEndian.Uint32(b[0:4]),
+			},
+		}, 8, nil
+	case bsonInt64:
+		if err := fixedLength(b, 8); err != nil {
+			return nil, 0, err
+		}
+		return int64(binary.LittleEndian.Uint64(b)), 8, nil
+	case bsonDecimal128:
+		if err := fixedLength(b, 16); err != nil {
+			return nil, 0, err
+		}
+		low := binary.LittleEndian.Uint64(b[0:8])
+		high := binary.LittleEndian.Uint64(b[8:16])
+		return map[string]interface{}{"$numberDecimal": decimal128String(high, low)}, 16, nil
+	case bsonMinKey:
+		return map[string]interface{}{"$minKey": 1}, 0, nil
+	case bsonMaxKey:
+		return map[string]interface{}{"$maxKey": 1}, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown BSON element type: 0x%02x", elementType)
+	}
+}
+
+// decimal128String formats an IEEE 754-2008 128-bit decimal in the binary
+// integer decimal encoding the way the BSON specification describes.
+func decimal128String(high uint64, low uint64) string {
+	sign := ""
+	if high>>63 == 1 {
+		sign = "-"
+	}
+
+	var exponent int
+	significand := new(big.Int)
+	if (high>>61)&3 == 3 {
+		switch (high >> 58) & 0x1f {
+		case 0x1e:
+			return sign + "Infinity"
+		case 0x1f:
+			return "NaN"
+		}
+		// The significand of this form is always larger than the maximum, which means zero
+		exponent = int((high>>47)&0x3fff) - 6176
+	} else {
+		exponent = int((high>>49)&0x3fff) - 6176
+		significand.SetUint64(high & (1<<49 - 1))
+		significand.Lsh(significand, 64)
+		significand.Or(significand, new(big.Int).SetUint64(low))
+		maxSignificand, _ := new(big.Int).SetString(strings.Repeat("9", 34), 10)
+		if significand.Cmp(maxSignificand) > 0 {
+			significand.SetUint64(0)
+		}
+	}
+
+	digits := significand.String()
+	adjustedExponent := exponent + len(digits) - 1
+	if exponent > 0 || adjustedExponent < -6 {
+		s := digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		return fmt.Sprintf("%s%sE%+d", sign, s, adjustedExponent)
+	}
+
+	if exponent == 0 {
+		return sign + digits
+	}
+	point := len(digits) + exponent
+	if point > 0 {
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	return sign + "0." + strings.Repeat("0", -point) + digits
+}
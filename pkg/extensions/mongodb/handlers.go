@@ -0,0 +1,285 @@
+package mongodb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	replyFlagQueryFailure = 1 << 1
+)
+
+func parseRequest(msg *message) (*MongoRequest, error) {
+	request := &MongoRequest{
+		OpCode:          opCodes[msg.Header.OpCode],
+		RequestID:       msg.Header.RequestID,
+		Compressor:      msg.Compressor,
+		expectsResponse: true,
+	}
+
+	b := &buffer{data: msg.Body}
+	switch msg.Header.OpCode {
+	case opMsg:
+		flagBits, s, err := readMsg(msg.Body)
+		if err != nil {
+			return nil, err
+		}
+		request.FlagBits = flagBits
+		request.Body = s.Body
+		request.Sequences = s.Sequences
+		request.expectsResponse = flagBits&flagMoreToCome == 0
+		if len(s.BodyKeys) > 0 {
+			request.Command = s.BodyKeys[0]
+		}
+		request.Database, _ = s.Body["$db"].(string)
+		request.Collection = commandCollection(request.Command, s.Body)
+	case opQuery:
+		request.FlagBits = uint32(b.readInt32())
+		request.FullCollectionName = b.readCString()
+		request.NumberToSkip = b.readInt32()
+		request.NumberToReturn = b.readInt32()
+		raw := b.data
+		query, keys := b.readDocument()
+		if len(b.data) > 0 {
+			request.ReturnFieldsSelector, _ = b.readDocument()
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		request.Body = query
+
+		var collection string
+		request.Database, collection = splitNamespace(request.FullCollectionName)
+		if collection == "$cmd" {
+			// A command, possibly wrapped along with query modifiers
+			if _, ok := query["$query"].(map[string]interface{}); ok {
+				query, keys = embeddedDocument(raw, "$query")
+			}
+			if len(keys) > 0 {
+				request.Command = keys[0]
+			}
+			request.Collection = commandCollection(request.Command, query)
+		} else {
+			request.Command = "find"
+			request.Collection = collection
+		}
+	case opGetMore:
+		b.readInt32() // reserved
+		request.FullCollectionName = b.readCString()
+		request.NumberToReturn = b.readInt32()
+		request.CursorIDs = []int64{b.readInt64()}
+		if b.err != nil {
+			return nil, b.err
+		}
+		request.Command = "getMore"
+		request.Database, request.Collection = splitNamespace(request.FullCollectionName)
+	case opInsert:
+		request.FlagBits = uint32(b.readInt32())
+		request.FullCollectionName = b.readCString()
+		request.Documents = b.readDocuments()
+		if b.err != nil {
+			return nil, b.err
+		}
+		request.Command = "insert"
+		request.Database, request.Collection = splitNamespace(request.FullCollectionName)
+		request.expectsResponse = false
+	case opUpdate, opDelete:
+		b.readInt32() // reserved
+		request.FullCollectionName = b.readCString()
+		request.FlagBits = uint32(b.readInt32())
+		selector, _ := b.readDocument()
+		request.Body = selector
+		if msg.Header.OpCode == opUpdate {
+			update, _ := b.readDocument()
+			request.Documents = []interface{}{update}
+			request.Command = "update"
+		} else {
+			request.Command = "delete"
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		request.Database, request.Collection = splitNamespace(request.FullCollectionName)
+		request.expectsResponse = false
+	case opKillCursors:
+		b.readInt32() // reserved
+		n := b.readInt32()
+		for i := int32(0); i < n && b.err == nil; i++ {
+			request.CursorIDs = append(request.CursorIDs, b.readInt64())
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		request.Command = "killCursors"
+		request.expectsResponse = false
+	default:
+		return nil, fmt.Errorf("%s can't be sent by a client", request.OpCode)
+	}
+
+	if request.Body == nil {
+		request.Body = make(map[string]interface{})
+	}
+
+	return request, nil
+}
+
+func parseResponse(msg *message) (*MongoResponse, error) {
+	response := &MongoResponse{
+		OpCode:     opCodes[msg.Header.OpCode],
+		RequestID:  msg.Header.RequestID,
+		ResponseTo: msg.Header.ResponseTo,
+		Compressor: msg.Compressor,
+	}
+
+	switch msg.Header.OpCode {
+	case opMsg:
+		flagBits, s, err := readMsg(msg.Body)
+		if err != nil {
+			return nil, err
+		}
+		response.FlagBits = flagBits
+		response.Body = s.Body
+		response.Sequences = s.Sequences
+		response.moreToCome = flagBits&flagMoreToCome != 0
+	case opReply:
+		b := &buffer{data: msg.Body}
+		response.FlagBits = uint32(b.readInt32())
+		response.CursorID = b.readInt64()
+		response.StartingFrom = b.readInt32()
+		response.NumberReturned = b.readInt32()
+		response.Documents = b.readDocuments()
+		if b.err != nil {
+			return nil, b.err
+		}
+		if len(response.Documents) > 0 {
+			response.Body = response.Documents[0].(map[string]interface{})
+		}
+		if response.FlagBits&replyFlagQueryFailure != 0 && response.Body != nil {
+			response.Error = &MongoError{
+				Code:    toInt64(response.Body["code"]),
+				Message: fmt.Sprintf("%v", response.Body["$err"]),
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s can't be sent by a server", response.OpCode)
+	}
+
+	if response.Body == nil {
+		response.Body = make(map[string]interface{})
+	}
+	if response.Error == nil {
+		response.Error = commandError(response.Body)
+	}
+
+	return response, nil
+}
+
+// commandCollection returns the collection that a command operates on. Most
+// commands name it as the value of the command itself, except for a few
+// like getMore.
+func commandCollection(command string, body map[string]interface{}) string {
+	if collection, ok := body[command].(string); ok {
+		return collection
+	}
+	if collection, ok := body["collection"].(string); ok {
+		return collection
+	}
+	return ""
+}
+
+// commandError extracts the error of a failed command, or the first write
+// error of a command that has partially failed.
+func commandError(body map[string]interface{}) *MongoError {
+	if ok, found := body["ok"]; found && toInt64(ok) == 0 {
+		mongoError := &MongoError{
+			Code: toInt64(body["code"]),
+		}
+		mongoError.CodeName, _ = body["codeName"].(string)
+		mongoError.Message, _ = body["errmsg"].(string)
+		return mongoError
+	}
+
+	if writeErrors, ok := body["writeErrors"].([]interface{}); ok && len(writeErrors) > 0 {
+		if writeError, ok := writeErrors[0].(map[string]interface{}); ok {
+			mongoError := &MongoError{
+				Code: toInt64(writeError["code"]),
+			}
+			mongoError.CodeName, _ = writeError["codeName"].(string)
+			mongoError.Message, _ = writeError["errmsg"].(string)
+			return mongoError
+		}
+	}
+
+	return nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case bool:
+		if n {
+			return 1
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, request *MongoRequest, reqResMatcher *requestResponseMatcher) {
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		tcpID.SrcIP,
+		tcpID.DstIP,
+		tcpID.SrcPort,
+		tcpID.DstPort,
+		request.RequestID,
+	)
+
+	var item *api.OutputChannelItem
+	if request.expectsResponse {
+		item = reqResMatcher.registerRequest(ident, request, captureTime, progress.Current())
+	} else {
+		item = reqResMatcher.prepareUnacknowledged(request, captureTime, progress.Current())
+	}
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, response *MongoResponse, reqResMatcher *requestResponseMatcher) {
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		tcpID.DstIP,
+		tcpID.SrcIP,
+		tcpID.DstPort,
+		tcpID.SrcPort,
+		response.ResponseTo,
+	)
+
+	item := reqResMatcher.registerResponse(ident, response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
@@ -0,0 +1,179 @@
+package mongodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "OpCode",
+			Value:    request["opCode"].(string),
+			Selector: `request.opCode`,
+		},
+		{
+			Name:     "Request ID",
+			Value:    request["requestId"].(float64),
+			Selector: `request.requestId`,
+		},
+		{
+			Name:     "Command",
+			Value:    request["command"].(string),
+			Selector: `request.command`,
+		},
+		{
+			Name:     "Database",
+			Value:    request["database"].(string),
+			Selector: `request.database`,
+		},
+		{
+			Name:     "Collection",
+			Value:    request["collection"].(string),
+			Selector: `request.collection`,
+		},
+		{
+			Name:     "Flag Bits",
+			Value:    request["flagBits"].(float64),
+			Selector: `request.flagBits`,
+		},
+		{
+			Name:     "Compressor",
+			Value:    request["compressor"],
+			Selector: `request.compressor`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	repRequest = append(repRequest, representDocument("Body", request["body"], `request.body`))
+	repRequest = append(repRequest, representSequences(request["sequences"], `request.sequences`)...)
+	if documents, ok := request["documents"].([]interface{}); ok {
+		repRequest = append(repRequest, representDocument("Documents", documents, `request.documents`))
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	opCode, _ := response["opCode"].(string)
+	if opCode == "" {
+		// No reply is expected for unacknowledged writes
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "OpCode",
+			Value:    opCode,
+			Selector: `response.opCode`,
+		},
+		{
+			Name:     "Request ID",
+			Value:    response["requestId"].(float64),
+			Selector: `response.requestId`,
+		},
+		{
+			Name:     "Response To",
+			Value:    response["responseTo"].(float64),
+			Selector: `response.responseTo`,
+		},
+		{
+			Name:     "Flag Bits",
+			Value:    response["flagBits"].(float64),
+			Selector: `response.flagBits`,
+		},
+		{
+			Name:     "Cursor ID",
+			Value:    response["cursorId"],
+			Selector: `response.cursorId`,
+		},
+		{
+			Name:     "Compressor",
+			Value:    response["compressor"],
+			Selector: `response.compressor`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if mongoError, ok := response["error"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Error",
+			Data:  representMapAsTable(mongoError, `response.error`),
+		})
+	}
+
+	repResponse = append(repResponse, representDocument("Body", response["body"], `response.body`))
+	repResponse = append(repResponse, representSequences(response["sequences"], `response.sequences`)...)
+	if documents, ok := response["documents"].([]interface{}); ok && len(documents) > 1 {
+		repResponse = append(repResponse, representDocument("Documents", documents, `response.documents`))
+	}
+
+	return
+}
+
+func representDocument(title string, document interface{}, selector string) api.SectionData {
+	obj, _ := json.Marshal(document)
+	return api.SectionData{
+		Type:     api.BODY,
+		Title:    title,
+		MimeType: "application/json",
+		Data:     string(obj),
+		Selector: selector,
+	}
+}
+
+func representSequences(sequences interface{}, selectorPrefix string) (sections []interface{}) {
+	m, ok := sequences.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	identifiers := make([]string, 0, len(m))
+	for identifier := range m {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	for _, identifier := range identifiers {
+		sections = append(sections, representDocument(
+			fmt.Sprintf("Sequence: %s", identifier),
+			m[identifier],
+			fmt.Sprintf("%s.%s", selectorPrefix, identifier),
+		))
+	}
+	return
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
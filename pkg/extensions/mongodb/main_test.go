@@ -0,0 +1,306 @@
+package mongodb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "mongodb", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"mongodb": `protocol.name == "mongodb"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+type element struct {
+	key   string
+	value interface{}
+}
+
+type document []element
+
+type array []interface{}
+
+type objectID [12]byte
+
+func int32Bytes(v int32) []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(v))
+	return buf
+}
+
+func int64Bytes(v int64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(v))
+	return buf
+}
+
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func encodeElement(key string, value interface{}) []byte {
+	var kind byte
+	var data []byte
+	switch v := value.(type) {
+	case float64:
+		kind = bsonDouble
+		data = int64Bytes(int64(math.Float64bits(v)))
+	case string:
+		kind = bsonString
+		data = append(int32Bytes(int32(len(v)+1)), cstring(v)...)
+	case document:
+		kind = bsonDocument
+		data = encodeDocument(v)
+	case array:
+		kind = bsonArray
+		var d document
+		for i, item := range v {
+			d = append(d, element{strconv.Itoa(i), item})
+		}
+		data = encodeDocument(d)
+	case objectID:
+		kind = bsonObjectID
+		data = v[:]
+	case bool:
+		kind = bsonBoolean
+		data = []byte{0}
+		if v {
+			data[0] = 1
+		}
+	case int32:
+		kind = bsonInt32
+		data = int32Bytes(v)
+	case int64:
+		kind = bsonInt64
+		data = int64Bytes(v)
+	}
+	return append(append([]byte{kind}, cstring(key)...), data...)
+}
+
+func encodeDocument(d document) []byte {
+	var elements []byte
+	for _, e := range d {
+		elements = append(elements, encodeElement(e.key, e.value)...)
+	}
+	return append(append(int32Bytes(int32(len(elements)+5)), elements...), 0)
+}
+
+func wireMessage(requestID int32, responseTo int32, opCode int32, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	return append(bytes.Join([][]byte{int32Bytes(int32(len(body) + headerLength)), int32Bytes(requestID), int32Bytes(responseTo), int32Bytes(opCode)}, nil), body...)
+}
+
+func opMsgMessage(requestID int32, responseTo int32, flagBits int32, body document) []byte {
+	return wireMessage(requestID, responseTo, opMsg, int32Bytes(flagBits), []byte{0}, encodeDocument(body))
+}
+
+func documentSequence(identifier string, documents ...document) []byte {
+	var data []byte
+	for _, d := range documents {
+		data = append(data, encodeDocument(d)...)
+	}
+	data = append(cstring(identifier), data...)
+	return append(append([]byte{1}, int32Bytes(int32(len(data)+4))...), data...)
+}
+
+func dissect(t *testing.T, client []byte, server []byte) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.ClientFirst, client, server)
+}
+
+func TestDissect(t *testing.T) {
+	id := objectID{0x65, 0x0b, 0x1f, 0x2a, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	client := bytes.Join([][]byte{
+		opMsgMessage(1, 0, 0, document{
+			{"find", "orders"},
+			{"filter", document{{"status", "paid"}}},
+			{"limit", int32(1)},
+			{"$db", "shop"},
+		}),
+		opMsgMessage(2, 0, 0, document{
+			{"aggregate", "orders"},
+			{"pipeline", array{document{{"$match", document{{"total", document{{"$gt", 10.5}}}}}}}},
+			{"cursor", document{}},
+			{"$db", "shop"},
+		}),
+		wireMessage(3, 0, opMsg, int32Bytes(0), []byte{0}, encodeDocument(document{
+			{"insert", "orders"},
+			{"$db", "shop"},
+		}), documentSequence("documents", document{{"_id", id}, {"total", int64(42)}})),
+		wireMessage(4, 0, opQuery, int32Bytes(0), cstring("admin.$cmd"), int32Bytes(0), int32Bytes(-1), encodeDocument(document{
+			{"$query", document{{"isMaster", int32(1)}, {"client", document{{"application", "app"}}}}},
+			{"$readPreference", document{{"mode", "primary"}}},
+		})),
+		opMsgMessage(5, 0, flagMoreToCome, document{
+			{"delete", "orders"},
+			{"$db", "shop"},
+		}),
+		opMsgMessage(6, 0, 0, document{
+			{"drop", "missing"},
+			{"$db", "shop"},
+		}),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		opMsgMessage(101, 1, 0, document{
+			{"cursor", document{
+				{"firstBatch", array{document{{"_id", id}, {"status", "paid"}}}},
+				{"id", int64(0)},
+				{"ns", "shop.orders"},
+			}},
+			{"ok", 1.0},
+		}),
+		opMsgMessage(102, 2, 0, document{
+			{"cursor", document{{"firstBatch", array{}}, {"id", int64(0)}, {"ns", "shop.orders"}}},
+			{"ok", 1.0},
+		}),
+		opMsgMessage(103, 3, 0, document{
+			{"n", int32(1)},
+			{"ok", 1.0},
+		}),
+		wireMessage(104, 4, opReply, int32Bytes(8), int64Bytes(0), int32Bytes(0), int32Bytes(1), encodeDocument(document{
+			{"ismaster", true},
+			{"maxWireVersion", int32(17)},
+			{"ok", 1.0},
+		})),
+		opMsgMessage(106, 6, 0, document{
+			{"ok", 0.0},
+			{"errmsg", "ns not found"},
+			{"code", int32(26)},
+			{"codeName", "NamespaceNotFound"},
+		}),
+	}, nil)
+
+	entries := make(map[float64]*api.Entry)
+	for _, entry := range dissect(t, client, server) {
+		entries[entry.Request["requestId"].(float64)] = entry
+	}
+	assert.Len(t, entries, 6)
+
+	dissector := NewDissector()
+
+	find := entries[1]
+	assert.Equal(t, "OP_MSG", find.Request["opCode"])
+	assert.Equal(t, "find", find.Request["command"])
+	assert.Equal(t, "shop", find.Request["database"])
+	assert.Equal(t, "orders", find.Request["collection"])
+	assert.Equal(t, map[string]interface{}{"status": "paid"}, find.Request["body"].(map[string]interface{})["filter"])
+	assert.Equal(t, float64(1), find.Response["responseTo"])
+	firstBatch := find.Response["body"].(map[string]interface{})["cursor"].(map[string]interface{})["firstBatch"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"$oid": "650b1f2a0102030405060708"}, firstBatch[0].(map[string]interface{})["_id"])
+	summary := dissector.Summarize(find)
+	assert.Equal(t, "find", summary.Method)
+	assert.Equal(t, `request.command == "find"`, summary.MethodQuery)
+	assert.Equal(t, "orders", summary.Summary)
+	assert.Equal(t, `request.collection == "orders"`, summary.SummaryQuery)
+	assert.Equal(t, 0, summary.Status)
+
+	aggregate := entries[2]
+	assert.Equal(t, "aggregate", aggregate.Request["command"])
+	pipeline := aggregate.Request["body"].(map[string]interface{})["pipeline"].([]interface{})
+	assert.Equal(t, 10.5, pipeline[0].(map[string]interface{})["$match"].(map[string]interface{})["total"].(map[string]interface{})["$gt"])
+
+	insert := entries[3]
+	documents := insert.Request["sequences"].(map[string]interface{})["documents"].([]interface{})
+	assert.Equal(t, float64(42), documents[0].(map[string]interface{})["total"])
+	assert.Equal(t, float64(1), insert.Response["body"].(map[string]interface{})["n"])
+
+	isMaster := entries[4]
+	assert.Equal(t, "OP_QUERY", isMaster.Request["opCode"])
+	assert.Equal(t, "isMaster", isMaster.Request["command"])
+	assert.Equal(t, "admin", isMaster.Request["database"])
+	assert.Equal(t, "OP_REPLY", isMaster.Response["opCode"])
+	assert.Equal(t, true, isMaster.Response["body"].(map[string]interface{})["ismaster"])
+	summary = dissector.Summarize(isMaster)
+	assert.Equal(t, "admin", summary.Summary)
+	assert.Equal(t, `request.database == "admin"`, summary.SummaryQuery)
+
+	unacknowledged := entries[5]
+	assert.Equal(t, "delete", unacknowledged.Request["command"])
+	assert.Equal(t, float64(flagMoreToCome), unacknowledged.Request["flagBits"])
+	assert.Equal(t, map[string]interface{}{}, unacknowledged.Response["body"])
+
+	failed := entries[6]
+	assert.Equal(t, "NamespaceNotFound", failed.Response["error"].(map[string]interface{})["codeName"])
+	summary = dissector.Summarize(failed)
+	assert.Equal(t, 26, summary.Status)
+	assert.Equal(t, `response.error.code == 26`, summary.StatusQuery)
+}
+
+func TestDissectExhaust(t *testing.T) {
+	client := opMsgMessage(7, 0, 1<<16, document{
+		{"getMore", int64(99)},
+		{"collection", "events"},
+		{"$db", "shop"},
+	})
+
+	server := bytes.Join([][]byte{
+		opMsgMessage(201, 7, flagMoreToCome, document{
+			{"cursor", document{{"nextBatch", array{document{{"n", int32(1)}}}}, {"id", int64(99)}}},
+			{"ok", 1.0},
+		}),
+		opMsgMessage(202, 201, 0, document{
+			{"cursor", document{{"nextBatch", array{document{{"n", int32(2)}}}}, {"id", int64(0)}}},
+			{"ok", 1.0},
+		}),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 2)
+
+	for _, entry := range entries {
+		assert.Equal(t, "getMore", entry.Request["command"])
+		assert.Equal(t, "events", entry.Request["collection"])
+	}
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestDecompressSnappy(t *testing.T) {
+	body := bytes.Join([][]byte{int32Bytes(0), []byte{0}, encodeDocument(document{{"ping", 1.0}, {"$db", "admin"}})}, nil)
+	compressed := func(uncompressedSize int32, data []byte) *message {
+		msg := &message{Header: header{OpCode: opCompressed}}
+		msg.Body = bytes.Join([][]byte{int32Bytes(opMsg), int32Bytes(uncompressedSize), []byte{1}, data}, nil)
+		return msg
+	}
+
+	msg := compressed(int32(len(body)), snappy.Encode(nil, body))
+	assert.Nil(t, msg.decompress())
+	assert.Equal(t, int32(opMsg), msg.Header.OpCode)
+	assert.Equal(t, "snappy", msg.Compressor)
+	assert.Equal(t, body, msg.Body)
+
+	// The length that the data declares is checked before it's allocated
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0x03}
+	assert.EqualError(t, compressed(int32(len(body)), huge).decompress(), "uncompressed size mismatch")
+}
@@ -0,0 +1,138 @@
+package mongodb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{request_id}`
+// A reply carries the requestID of its request in responseTo.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *MongoRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMongoMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MongoPayload{
+			Data: &MongoWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseMongoMessage := response.(*api.GenericMessage)
+		if responseMongoMessage.IsRequest {
+			return nil
+		}
+		matcher.registerExhaust(ident, responseMongoMessage, &requestMongoMessage)
+		return matcher.preparePair(&requestMongoMessage, responseMongoMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestMongoMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *MongoResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseMongoMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MongoPayload{
+			Data: &MongoWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestMongoMessage := request.(*api.GenericMessage)
+		if !requestMongoMessage.IsRequest {
+			return nil
+		}
+		matcher.registerExhaust(ident, &responseMongoMessage, requestMongoMessage)
+		return matcher.preparePair(requestMongoMessage, &responseMongoMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseMongoMessage)
+	return nil
+}
+
+// registerExhaust makes the request available to the next reply when the
+// server announced that more replies are coming. The next reply points at
+// the requestID of the current one.
+func (matcher *requestResponseMatcher) registerExhaust(ident string, responseMongoMessage *api.GenericMessage, requestMongoMessage *api.GenericMessage) {
+	response := responseMongoMessage.Payload.(MongoPayload).Data.(*MongoWrapper).Details.(*MongoResponse)
+	if !response.moreToCome {
+		return
+	}
+	nextIdent := fmt.Sprintf("%s_%d", ident[:strings.LastIndex(ident, "_")], response.RequestID)
+	next := *requestMongoMessage
+	next.CaptureTime = responseMongoMessage.CaptureTime
+	matcher.openMessagesMap.Store(nextIdent, &next)
+}
+
+// prepareUnacknowledged pairs a request that gets no reply, like a write
+// with the moreToCome flag, with an empty response.
+func (matcher *requestResponseMatcher) prepareUnacknowledged(request *MongoRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMongoMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MongoPayload{
+			Data: &MongoWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseMongoMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: MongoPayload{
+			Data: &MongoWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &MongoResponse{Body: make(map[string]interface{})},
+			},
+		},
+	}
+	return matcher.preparePair(&requestMongoMessage, &responseMongoMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestMongoMessage *api.GenericMessage, responseMongoMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestMongoMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestMongoMessage,
+			Response: *responseMongoMessage,
+		},
+	}
+}
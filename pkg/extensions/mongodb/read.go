@@ -0,0 +1,343 @@
+package mongodb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	headerLength     = 16
+	maxMessageLength = 48 * 1000 * 1000
+)
+
+const (
+	opReply        = 1
+	opUpdate       = 2001
+	opInsert       = 2002
+	opQuery        = 2004
+	opGetMore      = 2005
+	opDelete       = 2006
+	opKillCursors  = 2007
+	opCompressed   = 2012
+	opMsg          = 2013
+	flagChecksum   = 1 << 0
+	flagMoreToCome = 1 << 1
+)
+
+var opCodes = map[int32]string{
+	opReply:       "OP_REPLY",
+	opUpdate:      "OP_UPDATE",
+	opInsert:      "OP_INSERT",
+	opQuery:       "OP_QUERY",
+	opGetMore:     "OP_GET_MORE",
+	opDelete:      "OP_DELETE",
+	opKillCursors: "OP_KILL_CURSORS",
+	opCompressed:  "OP_COMPRESSED",
+	opMsg:         "OP_MSG",
+}
+
+var compressors = map[byte]string{
+	0: "noop",
+	1: "snappy",
+	2: "zlib",
+	3: "zstd",
+}
+
+var (
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+type header struct {
+	MessageLength int32
+	RequestID     int32
+	ResponseTo    int32
+	OpCode        int32
+}
+
+// message is a wire protocol message with its compression, if any,
+// already undone.
+type message struct {
+	Header     header
+	Compressor string
+	Body       []byte
+}
+
+func readMessage(r *bufio.Reader, isClient bool) (*message, error) {
+	buf := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	msg := &message{
+		Header: header{
+			MessageLength: int32(binary.LittleEndian.Uint32(buf[0:4])),
+			RequestID:     int32(binary.LittleEndian.Uint32(buf[4:8])),
+			ResponseTo:    int32(binary.LittleEndian.Uint32(buf[8:12])),
+			OpCode:        int32(binary.LittleEndian.Uint32(buf[12:16])),
+		},
+	}
+
+	if _, ok := opCodes[msg.Header.OpCode]; !ok {
+		return nil, fmt.Errorf("unknown opcode: %d", msg.Header.OpCode)
+	}
+	if isClient && msg.Header.OpCode == opReply {
+		return nil, errors.New("OP_REPLY can't be sent by a client")
+	}
+	if !isClient && msg.Header.OpCode != opReply && msg.Header.OpCode != opMsg && msg.Header.OpCode != opCompressed {
+		return nil, fmt.Errorf("%s can't be sent by a server", opCodes[msg.Header.OpCode])
+	}
+	if msg.Header.MessageLength <= headerLength || msg.Header.MessageLength > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length: %d", msg.Header.MessageLength)
+	}
+
+	msg.Body = make([]byte, msg.Header.MessageLength-headerLength)
+	if _, err := io.ReadFull(r, msg.Body); err != nil {
+		return nil, err
+	}
+
+	if msg.Header.OpCode == opCompressed {
+		if err := msg.decompress(); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+// decompress replaces an OP_COMPRESSED message with the message that it
+// wraps.
+func (msg *message) decompress() error {
+	if len(msg.Body) < 9 {
+		return errShortMessage
+	}
+	originalOpCode := int32(binary.LittleEndian.Uint32(msg.Body[0:4]))
+	uncompressedSize := int32(binary.LittleEndian.Uint32(msg.Body[4:8]))
+	compressorID := msg.Body[8]
+	compressed := msg.Body[9:]
+
+	if originalOpCode == opCompressed {
+		return errors.New("nested OP_COMPRESSED message")
+	}
+	if _, ok := opCodes[originalOpCode]; !ok {
+		return fmt.Errorf("unknown opcode: %d", originalOpCode)
+	}
+	if uncompressedSize < 0 || uncompressedSize > maxMessageLength {
+		return fmt.Errorf("invalid uncompressed size: %d", uncompressedSize)
+	}
+
+	var body []byte
+	var err error
+	switch compressorID {
+	case 0:
+		body = compressed
+	case 1:
+		// The length is declared ahead of the data, and is what Decode allocates
+		var decodedLen int
+		if decodedLen, err = snappy.DecodedLen(compressed); err == nil {
+			if decodedLen != int(uncompressedSize) {
+				return errors.New("uncompressed size mismatch")
+			}
+			body, err = snappy.Decode(nil, compressed)
+		}
+	case 2:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(compressed)); err == nil {
+			body, err = io.ReadAll(io.LimitReader(zr, int64(uncompressedSize)))
+			zr.Close()
+		}
+	case 3:
+		zstdDecoderOnce.Do(func() {
+			zstdDecoder, zstdDecoderErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxMessageLength))
+		})
+		if err = zstdDecoderErr; err == nil {
+			body, err = zstdDecoder.DecodeAll(compressed, nil)
+		}
+	default:
+		return fmt.Errorf("unknown compressor: %d", compressorID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(body) != int(uncompressedSize) {
+		return errors.New("uncompressed size mismatch")
+	}
+
+	msg.Header.OpCode = originalOpCode
+	msg.Compressor = compressors[compressorID]
+	msg.Body = body
+	return nil
+}
+
+// buffer is a cursor over a message body.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+var errShortMessage = errors.New("message is shorter than its declared fields")
+
+func (b *buffer) readInt32() int32 {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 4 {
+		b.err = errShortMessage
+		return 0
+	}
+	v := int32(binary.LittleEndian.Uint32(b.data))
+	b.data = b.data[4:]
+	return v
+}
+
+func (b *buffer) readInt64() int64 {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 8 {
+		b.err = errShortMessage
+		return 0
+	}
+	v := int64(binary.LittleEndian.Uint64(b.data))
+	b.data = b.data[8:]
+	return v
+}
+
+func (b *buffer) readByte() byte {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 1 {
+		b.err = errShortMessage
+		return 0
+	}
+	v := b.data[0]
+	b.data = b.data[1:]
+	return v
+}
+
+func (b *buffer) readCString() string {
+	if b.err != nil {
+		return ""
+	}
+	v, n, err := decodeCString(b.data)
+	if err != nil {
+		b.err = err
+		return ""
+	}
+	b.data = b.data[n:]
+	return v
+}
+
+// readDocument reads a BSON document and returns it with its keys in order.
+func (b *buffer) readDocument() (map[string]interface{}, []string) {
+	if b.err != nil {
+		return nil, nil
+	}
+	n, err := documentLength(b.data)
+	if err != nil {
+		b.err = err
+		return nil, nil
+	}
+	document, keys, err := decodeDocument(b.data[:n])
+	if err != nil {
+		b.err = err
+		return nil, nil
+	}
+	b.data = b.data[n:]
+	return document, keys
+}
+
+// readDocuments reads BSON documents until the buffer is exhausted.
+func (b *buffer) readDocuments() []interface{} {
+	documents := make([]interface{}, 0)
+	for len(b.data) > 0 && b.err == nil {
+		document, _ := b.readDocument()
+		if b.err == nil {
+			documents = append(documents, document)
+		}
+	}
+	return documents
+}
+
+// sections is the content of an OP_MSG: the body and the document
+// sequences keyed by their identifiers.
+type sections struct {
+	Body      map[string]interface{}
+	BodyKeys  []string
+	Sequences map[string][]interface{}
+}
+
+func readMsg(body []byte) (flagBits uint32, s *sections, err error) {
+	b := &buffer{data: body}
+	flagBits = uint32(b.readInt32())
+	if b.err != nil {
+		return 0, nil, b.err
+	}
+	if flagBits&flagChecksum != 0 {
+		if len(b.data) < 4 {
+			return 0, nil, errShortMessage
+		}
+		b.data = b.data[:len(b.data)-4]
+	}
+
+	s = &sections{}
+	for len(b.data) > 0 && b.err == nil {
+		switch kind := b.readByte(); kind {
+		case 0:
+			if s.Body != nil {
+				return 0, nil, errors.New("OP_MSG has more than one body section")
+			}
+			s.Body, s.BodyKeys = b.readDocument()
+		case 1:
+			size := b.readInt32()
+			if b.err != nil {
+				break
+			}
+			if size < 4 || int(size-4) > len(b.data) {
+				return 0, nil, errShortMessage
+			}
+			sequence := &buffer{data: b.data[:size-4]}
+			b.data = b.data[size-4:]
+			identifier := sequence.readCString()
+			documents := sequence.readDocuments()
+			if sequence.err != nil {
+				return 0, nil, sequence.err
+			}
+			if s.Sequences == nil {
+				s.Sequences = make(map[string][]interface{})
+			}
+			s.Sequences[identifier] = documents
+		default:
+			return 0, nil, fmt.Errorf("unknown OP_MSG section kind: %d", kind)
+		}
+	}
+	if b.err != nil {
+		return 0, nil, b.err
+	}
+	if s.Body == nil {
+		return 0, nil, errors.New("OP_MSG has no body section")
+	}
+
+	return flagBits, s, nil
+}
+
+// splitNamespace splits a `db.collection` namespace.
+func splitNamespace(namespace string) (database string, collection string) {
+	i := strings.IndexByte(namespace, '.')
+	if i < 0 {
+		return namespace, ""
+	}
+	return namespace[:i], namespace[i+1:]
+}
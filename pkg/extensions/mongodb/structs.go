@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"encoding/json"
+)
+
+type MongoPayload struct {
+	Data interface{}
+}
+
+type MongoPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h MongoPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type MongoWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+type MongoRequest struct {
+	OpCode               string                   `json:"opCode"`
+	RequestID            int32                    `json:"requestId"`
+	FlagBits             uint32                   `json:"flagBits"`
+	Command              string                   `json:"command"`
+	Database             string                   `json:"database"`
+	Collection           string                   `json:"collection"`
+	Body                 map[string]interface{}   `json:"body"`
+	Sequences            map[string][]interface{} `json:"sequences,omitempty"`
+	Documents            []interface{}            `json:"documents,omitempty"`
+	FullCollectionName   string                   `json:"fullCollectionName,omitempty"`
+	NumberToSkip         int32                    `json:"numberToSkip,omitempty"`
+	NumberToReturn       int32                    `json:"numberToReturn,omitempty"`
+	ReturnFieldsSelector map[string]interface{}   `json:"returnFieldsSelector,omitempty"`
+	CursorIDs            []int64                  `json:"cursorIds,omitempty"`
+	Compressor           string                   `json:"compressor,omitempty"`
+
+	expectsResponse bool
+}
+
+type MongoError struct {
+	Code     int64  `json:"code"`
+	CodeName string `json:"codeName"`
+	Message  string `json:"message"`
+}
+
+type MongoResponse struct {
+	OpCode         string                   `json:"opCode"`
+	RequestID      int32                    `json:"requestId"`
+	ResponseTo     int32                    `json:"responseTo"`
+	FlagBits       uint32                   `json:"flagBits"`
+	Body           map[string]interface{}   `json:"body"`
+	Sequences      map[string][]interface{} `json:"sequences,omitempty"`
+	Documents      []interface{}            `json:"documents,omitempty"`
+	CursorID       int64                    `json:"cursorId,omitempty"`
+	StartingFrom   int32                    `json:"startingFrom,omitempty"`
+	NumberReturned int32                    `json:"numberReturned,omitempty"`
+	Compressor     string                   `json:"compressor,omitempty"`
+	Error          *MongoError              `json:"error,omitempty"`
+
+	moreToCome bool
+}
@@ -0,0 +1,427 @@
+package mqtt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	connectFlagUsername     = 0x80
+	connectFlagPassword     = 0x40
+	connectFlagWillRetain   = 0x20
+	connectFlagWillQoS      = 0x18
+	connectFlagWill         = 0x04
+	connectFlagCleanStart   = 0x02
+	connectFlagReserved     = 0x01
+	connackFlagSessionPrsnt = 0x01
+
+	publishFlagDup    = 0x08
+	publishFlagQoS    = 0x06
+	publishFlagRetain = 0x01
+
+	reasonCodeFailure = 0x80
+)
+
+var connectReturnCodes = map[byte]string{
+	0x00: "Connection Accepted",
+	0x01: "Unacceptable protocol version",
+	0x02: "Identifier rejected",
+	0x03: "Server unavailable",
+	0x04: "Bad user name or password",
+	0x05: "Not authorized",
+}
+
+var reasonCodes = map[byte]string{
+	0x00: "Success",
+	0x01: "Granted QoS 1",
+	0x02: "Granted QoS 2",
+	0x04: "Disconnect with Will Message",
+	0x10: "No matching subscribers",
+	0x11: "No subscription existed",
+	0x18: "Continue authentication",
+	0x19: "Re-authenticate",
+	0x80: "Unspecified error",
+	0x81: "Malformed Packet",
+	0x82: "Protocol Error",
+	0x83: "Implementation specific error",
+	0x84: "Unsupported Protocol Version",
+	0x85: "Client Identifier not valid",
+	0x86: "Bad User Name or Password",
+	0x87: "Not authorized",
+	0x88: "Server unavailable",
+	0x89: "Server busy",
+	0x8a: "Banned",
+	0x8b: "Server shutting down",
+	0x8c: "Bad authentication method",
+	0x8d: "Keep Alive timeout",
+	0x8e: "Session taken over",
+	0x8f: "Topic Filter invalid",
+	0x90: "Topic Name invalid",
+	0x91: "Packet Identifier in use",
+	0x92: "Packet Identifier not found",
+	0x93: "Receive Maximum exceeded",
+	0x94: "Topic Alias invalid",
+	0x95: "Packet too large",
+	0x96: "Message rate too high",
+	0x97: "Quota exceeded",
+	0x98: "Administrative action",
+	0x99: "Payload format invalid",
+	0x9a: "Retain not supported",
+	0x9b: "QoS not supported",
+	0x9c: "Use another server",
+	0x9d: "Server moved",
+	0x9e: "Shared Subscriptions not supported",
+	0x9f: "Connection rate exceeded",
+	0xa0: "Maximum connect time",
+	0xa1: "Subscription Identifiers not supported",
+	0xa2: "Wildcard Subscriptions not supported",
+}
+
+// connectionState keeps track of one side of a connection. The layout of
+// the packets depends on the protocol version, which is learned from the
+// CONNECT or the CONNACK packet that opens the connection.
+type connectionState struct {
+	isClient     bool
+	version      byte
+	topicAliases map[uint16]string
+
+	// How many times each request or response identity was seen so far,
+	// since the packet identifiers are reused once acknowledged
+	occurrences map[string]int
+}
+
+func newConnectionState(isClient bool) *connectionState {
+	return &connectionState{
+		isClient:     isClient,
+		topicAliases: make(map[uint16]string),
+		occurrences:  make(map[string]int),
+	}
+}
+
+// handlePacket parses a packet into either a request or a response. Both
+// are nil for the packets that aren't shown, like the keep alive pings.
+func (s *connectionState) handlePacket(p *packet) (*MqttRequest, *MqttResponse, error) {
+	if s.version == 0 {
+		if s.isClient && p.Type != typeConnect {
+			return nil, nil, errors.New("connection doesn't start with CONNECT")
+		}
+		if !s.isClient && p.Type != typeConnack {
+			return nil, nil, errors.New("connection doesn't start with CONNACK")
+		}
+	}
+
+	b := &buffer{data: p.Body}
+	var request *MqttRequest
+	var response *MqttResponse
+	switch p.Type {
+	case typeConnect:
+		if !s.isClient || s.version != 0 {
+			return nil, nil, errors.New("unexpected CONNECT")
+		}
+		request = s.readConnect(b)
+	case typeConnack:
+		if s.isClient || s.version != 0 {
+			return nil, nil, errors.New("unexpected CONNACK")
+		}
+		// A CONNACK is 2 bytes long unless it has the properties of MQTT 5
+		s.version = version311
+		if len(p.Body) > 2 {
+			s.version = version5
+		}
+		response = s.readConnack(b)
+	case typePublish:
+		request = s.readPublish(b, p.Flags)
+	case typePuback, typePubrec, typePubcomp:
+		response = s.readAck(b, p.Type)
+		if p.Type == typePubrec && response.ReasonCode < reasonCodeFailure {
+			// The exchange of a QoS 2 message goes on until PUBCOMP
+			response = nil
+		}
+	case typeSubscribe:
+		request = s.readSubscribe(b)
+	case typeSuback, typeUnsuback:
+		response = s.readSubscribeAck(b, p.Type)
+	case typeUnsubscribe:
+		request = s.readUnsubscribe(b)
+	case typeDisconnect:
+		request = s.readDisconnect(b)
+	case typePubrel, typePingreq, typePingresp, typeAuth:
+	}
+
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+	if request != nil {
+		request.Version = protocolVersions[s.version]
+	}
+
+	return request, response, nil
+}
+
+func (s *connectionState) readConnect(b *buffer) *MqttRequest {
+	request := &MqttRequest{Type: packetTypes[typeConnect]}
+
+	name := b.readString()
+	level := b.readByte()
+	flags := b.readByte()
+	request.KeepAlive = b.readUint16()
+	if b.err != nil {
+		return nil
+	}
+	if name != "MQTT" && name != "MQIsdp" {
+		b.err = fmt.Errorf("unknown protocol name: %q", name)
+		return nil
+	}
+	if _, ok := protocolVersions[level]; !ok {
+		b.err = fmt.Errorf("unknown protocol level: %d", level)
+		return nil
+	}
+	if flags&connectFlagReserved != 0 {
+		b.err = errors.New("reserved connect flag is set")
+		return nil
+	}
+	s.version = level
+	request.CleanStart = flags&connectFlagCleanStart != 0
+
+	if s.version == version5 {
+		request.Properties = b.readProperties()
+	}
+
+	request.ClientID = b.readString()
+	if flags&connectFlagWill != 0 {
+		will := &MqttMessage{
+			QoS:    (flags & connectFlagWillQoS) >> 3,
+			Retain: flags&connectFlagWillRetain != 0,
+		}
+		if s.version == version5 {
+			will.Properties = b.readProperties()
+		}
+		will.Topic = b.readString()
+		payload := b.readBinary()
+		will.Payload, will.PayloadText, will.PayloadSize = encodePayload(payload)
+		request.Will = will
+	}
+	if flags&connectFlagUsername != 0 {
+		request.Username = b.readString()
+	}
+	// The password is left out since it holds the credentials
+
+	return request
+}
+
+func (s *connectionState) readConnack(b *buffer) *MqttResponse {
+	response := &MqttResponse{Type: packetTypes[typeConnack]}
+	flags := b.readByte()
+	if flags&^connackFlagSessionPrsnt != 0 {
+		b.err = errors.New("reserved connack flags are set")
+		return nil
+	}
+	response.SessionPresent = flags&connackFlagSessionPrsnt != 0
+	response.ReasonCode = b.readByte()
+	if s.version == version5 {
+		response.Reason = reasonCodes[response.ReasonCode]
+		response.Properties = b.readProperties()
+	} else {
+		response.Reason = connectReturnCodes[response.ReasonCode]
+	}
+	return response
+}
+
+func (s *connectionState) readPublish(b *buffer, flags byte) *MqttRequest {
+	request := &MqttRequest{
+		Type:   packetTypes[typePublish],
+		QoS:    (flags & publishFlagQoS) >> 1,
+		Retain: flags&publishFlagRetain != 0,
+		Dup:    flags&publishFlagDup != 0,
+	}
+
+	request.Topic = b.readString()
+	if request.QoS > 0 {
+		request.PacketID = b.readUint16()
+	}
+	if s.version == version5 {
+		request.Properties = b.readProperties()
+		// A topic alias stands for the topic it was last sent with
+		if alias, ok := request.Properties["topicAlias"].(uint16); ok {
+			if request.Topic != "" {
+				s.topicAliases[alias] = request.Topic
+			} else {
+				request.Topic = s.topicAliases[alias]
+			}
+		}
+	}
+	request.Payload, request.PayloadText, request.PayloadSize = encodePayload(b.readRest())
+
+	return request
+}
+
+// readAck reads a PUBACK, PUBREC or PUBCOMP. Starting with MQTT 5 the
+// reason code and the properties are omitted when there's nothing to tell.
+func (s *connectionState) readAck(b *buffer, packetType byte) *MqttResponse {
+	response := &MqttResponse{Type: packetTypes[packetType]}
+	response.PacketID = b.readUint16()
+	if s.version == version5 && len(b.data) > 0 {
+		response.ReasonCode = b.readByte()
+		if len(b.data) > 0 {
+			response.Properties = b.readProperties()
+		}
+	}
+	response.Reason = reasonCodes[response.ReasonCode]
+	return response
+}
+
+func (s *connectionState) readSubscribe(b *buffer) *MqttRequest {
+	request := &MqttRequest{Type: packetTypes[typeSubscribe]}
+	request.PacketID = b.readUint16()
+	if s.version == version5 {
+		request.Properties = b.readProperties()
+	}
+	for len(b.data) > 0 && b.err == nil {
+		subscription := MqttSubscription{TopicFilter: b.readString()}
+		options := b.readByte()
+		subscription.QoS = options & 0x03
+		if s.version == version5 {
+			subscription.NoLocal = options&0x04 != 0
+			subscription.RetainAsPublished = options&0x08 != 0
+			subscription.RetainHandling = (options >> 4) & 0x03
+		}
+		request.Subscriptions = append(request.Subscriptions, subscription)
+	}
+	if b.err == nil && len(request.Subscriptions) == 0 {
+		b.err = errors.New("SUBSCRIBE has no topic filters")
+	}
+	return request
+}
+
+func (s *connectionState) readUnsubscribe(b *buffer) *MqttRequest {
+	request := &MqttRequest{Type: packetTypes[typeUnsubscribe]}
+	request.PacketID = b.readUint16()
+	if s.version == version5 {
+		request.Properties = b.readProperties()
+	}
+	for len(b.data) > 0 && b.err == nil {
+		request.TopicFilters = append(request.TopicFilters, b.readString())
+	}
+	if b.err == nil && len(request.TopicFilters) == 0 {
+		b.err = errors.New("UNSUBSCRIBE has no topic filters")
+	}
+	return request
+}
+
+// readSubscribeAck reads a SUBACK or an UNSUBACK, which has a reason code
+// for each topic filter. The first failure, if any, is the reason code of
+// the whole response.
+func (s *connectionState) readSubscribeAck(b *buffer, packetType byte) *MqttResponse {
+	response := &MqttResponse{Type: packetTypes[packetType]}
+	response.PacketID = b.readUint16()
+	if s.version == version5 {
+		response.Properties = b.readProperties()
+	}
+	for _, code := range b.readRest() {
+		reason := reasonCodes[code]
+		if s.version != version5 && code >= reasonCodeFailure {
+			reason = "Failure"
+		}
+		response.ReasonCodes = append(response.ReasonCodes, MqttReason{Code: code, Reason: reason})
+		if code >= reasonCodeFailure && response.ReasonCode == 0 {
+			response.ReasonCode = code
+		}
+	}
+	response.Reason = reasonCodes[response.ReasonCode]
+	if s.version != version5 && response.ReasonCode >= reasonCodeFailure {
+		response.Reason = "Failure"
+	}
+	return response
+}
+
+func (s *connectionState) readDisconnect(b *buffer) *MqttRequest {
+	request := &MqttRequest{Type: packetTypes[typeDisconnect]}
+	if s.version == version5 && len(b.data) > 0 {
+		request.ReasonCode = b.readByte()
+		if len(b.data) > 0 {
+			request.Properties = b.readProperties()
+		}
+	}
+	if s.version == version5 {
+		request.Reason = reasonCodes[request.ReasonCode]
+	}
+	return request
+}
+
+// encodePayload returns the payload in base64, and as text if it's valid
+// UTF-8.
+func encodePayload(payload []byte) (encoded string, text string, size int) {
+	encoded = base64.StdEncoding.EncodeToString(payload)
+	if utf8.Valid(payload) {
+		text = string(payload)
+	}
+	return encoded, text, len(payload)
+}
+
+// identity returns the key that a request and its response have in
+// common. The requests are keyed by their sender and the responses by
+// their receiver, since PUBLISH is sent both ways.
+func (s *connectionState) identity(tcpID *api.TcpID, isRequest bool, key string) string {
+	var ident string
+	if isRequest {
+		ident = fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, key)
+	} else {
+		ident = fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, key)
+	}
+	occurrence := s.occurrences[ident]
+	s.occurrences[ident]++
+	return fmt.Sprintf("%s_%d", ident, occurrence)
+}
+
+func handleRequest(reader api.TcpReader, state *connectionState, request *MqttRequest, reqResMatcher *requestResponseMatcher) {
+	var item *api.OutputChannelItem
+	switch {
+	case request.Type == packetTypes[typeConnect]:
+		item = reqResMatcher.registerRequest(state.identity(reader.GetTcpID(), true, "connect"), request, reader.GetCaptureTime(), reader.GetReadProgress().Current())
+	case request.PacketID != 0:
+		item = reqResMatcher.registerRequest(state.identity(reader.GetTcpID(), true, fmt.Sprint(request.PacketID)), request, reader.GetCaptureTime(), reader.GetReadProgress().Current())
+	default:
+		// QoS 0 messages and disconnections aren't acknowledged
+		item = reqResMatcher.prepareUnacknowledged(request, reader.GetCaptureTime(), reader.GetReadProgress().Current())
+	}
+	emit(reader, item, true)
+}
+
+func handleResponse(reader api.TcpReader, state *connectionState, response *MqttResponse, reqResMatcher *requestResponseMatcher) {
+	key := fmt.Sprint(response.PacketID)
+	if response.Type == packetTypes[typeConnack] {
+		key = "connect"
+	}
+	item := reqResMatcher.registerResponse(state.identity(reader.GetTcpID(), false, key), response, reader.GetCaptureTime(), reader.GetReadProgress().Current())
+	emit(reader, item, false)
+}
+
+func emit(reader api.TcpReader, item *api.OutputChannelItem, isRequest bool) {
+	if item == nil {
+		return
+	}
+
+	tcpID := reader.GetTcpID()
+	if isRequest {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: reader.GetIsClient(),
+		}
+	} else {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: !reader.GetIsClient(),
+		}
+	}
+	reader.GetEmitter().Emit(item)
+}
@@ -0,0 +1,265 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    request["type"].(string),
+			Selector: `request.type`,
+		},
+		{
+			Name:     "Version",
+			Value:    request["version"].(string),
+			Selector: `request.version`,
+		},
+		{
+			Name:     "Packet ID",
+			Value:    request["packetId"].(float64),
+			Selector: `request.packetId`,
+		},
+		{
+			Name:     "Topic",
+			Value:    request["topic"].(string),
+			Selector: `request.topic`,
+		},
+		{
+			Name:     "QoS",
+			Value:    request["qos"].(float64),
+			Selector: `request.qos`,
+		},
+		{
+			Name:     "Retain",
+			Value:    request["retain"].(bool),
+			Selector: `request.retain`,
+		},
+		{
+			Name:     "Duplicate",
+			Value:    request["dup"].(bool),
+			Selector: `request.dup`,
+		},
+		{
+			Name:     "Client ID",
+			Value:    request["clientId"],
+			Selector: `request.clientId`,
+		},
+		{
+			Name:     "Username",
+			Value:    request["username"],
+			Selector: `request.username`,
+		},
+		{
+			Name:     "Keep Alive",
+			Value:    request["keepAlive"],
+			Selector: `request.keepAlive`,
+		},
+		{
+			Name:     "Clean Start",
+			Value:    request["cleanStart"],
+			Selector: `request.cleanStart`,
+		},
+		{
+			Name:     "Reason Code",
+			Value:    request["reasonCode"],
+			Selector: `request.reasonCode`,
+		},
+		{
+			Name:     "Reason",
+			Value:    request["reason"],
+			Selector: `request.reason`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	properties, _ := request["properties"].(map[string]interface{})
+	if request["type"] == packetTypes[typePublish] {
+		repRequest = append(repRequest, representPayload("Payload", request["payload"].(string), properties, `request.payload`))
+	}
+
+	if properties != nil {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Properties",
+			Data:  representMapAsTable(properties, `request.properties`),
+		})
+	}
+
+	if subscriptions, ok := request["subscriptions"].([]interface{}); ok && len(subscriptions) > 0 {
+		var table []api.TableData
+		for i, subscription := range subscriptions {
+			s := subscription.(map[string]interface{})
+			table = append(table, api.TableData{
+				Name:     s["topicFilter"].(string),
+				Value:    fmt.Sprintf("QoS %v", s["qos"]),
+				Selector: fmt.Sprintf("request.subscriptions[%d].topicFilter", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Subscriptions",
+			Data:  string(obj),
+		})
+	}
+
+	if topicFilters, ok := request["topicFilters"].([]interface{}); ok && len(topicFilters) > 0 {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Topic Filters",
+			Data:  representSliceAsTable(topicFilters, `request.topicFilters`),
+		})
+	}
+
+	if will, ok := request["will"].(map[string]interface{}); ok {
+		willProperties, _ := will["properties"].(map[string]interface{})
+		table := map[string]interface{}{
+			"topic":  will["topic"],
+			"qos":    will["qos"],
+			"retain": will["retain"],
+		}
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Will",
+			Data:  representMapAsTable(table, `request.will`),
+		})
+		repRequest = append(repRequest, representPayload("Will Payload", will["payload"].(string), willProperties, `request.will.payload`))
+		if willProperties != nil {
+			repRequest = append(repRequest, api.SectionData{
+				Type:  api.TABLE,
+				Title: "Will Properties",
+				Data:  representMapAsTable(willProperties, `request.will.properties`),
+			})
+		}
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	if response["type"].(string) == "" {
+		// The request isn't acknowledged
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    response["type"].(string),
+			Selector: `response.type`,
+		},
+		{
+			Name:     "Packet ID",
+			Value:    response["packetId"].(float64),
+			Selector: `response.packetId`,
+		},
+		{
+			Name:     "Reason Code",
+			Value:    response["reasonCode"].(float64),
+			Selector: `response.reasonCode`,
+		},
+		{
+			Name:     "Reason",
+			Value:    response["reason"].(string),
+			Selector: `response.reason`,
+		},
+		{
+			Name:     "Session Present",
+			Value:    response["sessionPresent"],
+			Selector: `response.sessionPresent`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if reasonCodes, ok := response["reasonCodes"].([]interface{}); ok && len(reasonCodes) > 0 {
+		var table []api.TableData
+		for i, reasonCode := range reasonCodes {
+			r := reasonCode.(map[string]interface{})
+			table = append(table, api.TableData{
+				Name:     fmt.Sprintf("%d", i),
+				Value:    fmt.Sprintf("%v (%s)", r["code"], r["reason"]),
+				Selector: fmt.Sprintf("response.reasonCodes[%d].code", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Reason Codes",
+			Data:  string(obj),
+		})
+	}
+
+	if properties, ok := response["properties"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Properties",
+			Data:  representMapAsTable(properties, `response.properties`),
+		})
+	}
+
+	return
+}
+
+// representPayload shows an application message, using its content type
+// when the publisher set one.
+func representPayload(title string, payload string, properties map[string]interface{}, selector string) api.SectionData {
+	mimeType, _ := properties["contentType"].(string)
+	return api.SectionData{
+		Type:     api.BODY,
+		Title:    title,
+		Encoding: "base64",
+		MimeType: mimeType,
+		Data:     payload,
+		Selector: selector,
+	}
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
+
+func representSliceAsTable(slice []interface{}, selectorPrefix string) string {
+	var table []api.TableData
+	for i, item := range slice {
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("%d", i),
+			Value:    item,
+			Selector: fmt.Sprintf("%s[%d]", selectorPrefix, i),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
@@ -0,0 +1,188 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "mqtt",
+	Version:         "5.0",
+	Abbreviation:    "MQTT",
+	LongName:        "Message Queuing Telemetry Transport",
+	Macro:           "mqtt",
+	BackgroundColor: "#660066",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://docs.oasis-open.org/mqtt/mqtt/v5.0/mqtt-v5.0.html",
+	Ports:           []string{"1883", "8883"},
+	Layer4:          "tcp",
+	Priority:        9,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	state := newConnectionState(reader.GetIsClient())
+	for {
+		p, err := readPacket(b)
+		if err != nil {
+			return err
+		}
+
+		request, response, err := state.handlePacket(p)
+		if err != nil {
+			return err
+		}
+		reader.GetParent().SetProtocol(&protocol)
+
+		if request != nil {
+			handleRequest(reader, state, request, reqResMatcher)
+		} else if response != nil {
+			handleResponse(reader, state, response, reqResMatcher)
+		}
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	status := 0
+	statusQuery := ""
+	if code, ok := entry.Response["reasonCode"].(float64); ok {
+		// CONNACK of MQTT 3 has its own return codes, all of them failures but 0
+		if code >= reasonCodeFailure || (entry.Response["type"] == packetTypes[typeConnack] && code != 0) {
+			status = int(code)
+			statusQuery = fmt.Sprintf(`response.reasonCode == %d`, status)
+		}
+	}
+
+	method := entry.Request["type"].(string)
+	methodQuery := fmt.Sprintf(`request.type == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	switch method {
+	case packetTypes[typeConnect]:
+		// The broker assigns an ID to a client that connects without one
+		if clientID, ok := entry.Request["clientId"].(string); ok && clientID != "" {
+			summary = clientID
+			summaryQuery = fmt.Sprintf(`request.clientId == %q`, summary)
+		}
+	case packetTypes[typeSubscribe]:
+		var filters []string
+		subscriptions, _ := entry.Request["subscriptions"].([]interface{})
+		for _, subscription := range subscriptions {
+			filters = append(filters, subscription.(map[string]interface{})["topicFilter"].(string))
+		}
+		if len(filters) > 0 {
+			summary = strings.Join(filters, ", ")
+			summaryQuery = fmt.Sprintf(`request.subscriptions[0].topicFilter == %q`, filters[0])
+		}
+	case packetTypes[typeUnsubscribe]:
+		var filters []string
+		topicFilters, _ := entry.Request["topicFilters"].([]interface{})
+		for _, filter := range topicFilters {
+			filters = append(filters, filter.(string))
+		}
+		if len(filters) > 0 {
+			summary = strings.Join(filters, ", ")
+			summaryQuery = fmt.Sprintf(`request.topicFilters[0] == %q`, filters[0])
+		}
+	case packetTypes[typeDisconnect]:
+		if reason, ok := entry.Request["reason"].(string); ok && reason != "" {
+			summary = reason
+			summaryQuery = fmt.Sprintf(`request.reason == %q`, summary)
+		}
+	default:
+		summary = entry.Request["topic"].(string)
+		summaryQuery = fmt.Sprintf(`request.topic == %q`, summary)
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       status,
+		StatusQuery:  statusQuery,
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representRequest(request)
+	repResponse := representResponse(response)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`mqtt`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
@@ -0,0 +1,260 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "mqtt", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"mqtt": `protocol.name == "mqtt"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func u16(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func str(s string) []byte {
+	return append(u16(uint16(len(s))), s...)
+}
+
+func props(parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	return append([]byte{byte(len(body))}, body...)
+}
+
+func mqttPacket(packetType byte, flags byte, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	header := []byte{packetType<<4 | flags}
+	length := len(body)
+	for {
+		b := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			b |= 0x80
+		}
+		header = append(header, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(header, body...)
+}
+
+func dissect(t *testing.T, client []byte, server []byte) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, client, server)
+}
+
+func byType(list []*api.Entry) map[string]*api.Entry {
+	entries := make(map[string]*api.Entry)
+	for _, entry := range list {
+		key := entry.Request["type"].(string)
+		if topic := entry.Request["topic"].(string); topic != "" {
+			key += "/" + topic
+		}
+		entries[key] = entry
+	}
+	return entries
+}
+
+func TestDissect(t *testing.T) {
+	client := bytes.Join([][]byte{
+		mqttPacket(typeConnect, 0, str("MQTT"), []byte{version311, 0xee}, u16(60),
+			str("sensor-1"), str("status/sensor-1"), str("offline"), str("alice"), str("secret")),
+		mqttPacket(typeSubscribe, 0x02, u16(1), str("sensors/+/temp"), []byte{1}, str("alerts/#"), []byte{0}),
+		mqttPacket(typePublish, 0, str("sensors/1/temp"), []byte("21.5")),
+		mqttPacket(typePublish, 0x02, str("sensors/2/temp"), u16(2), []byte("22.0")),
+		mqttPacket(typePublish, 0x04, str("sensors/1/raw"), u16(3), []byte{0xff, 0x00}),
+		mqttPacket(typePubrel, 0x02, u16(3)),
+		mqttPacket(typePuback, 0, u16(7)),
+		mqttPacket(typePingreq, 0),
+		mqttPacket(typeDisconnect, 0),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		mqttPacket(typeConnack, 0, []byte{0, 0}),
+		mqttPacket(typeSuback, 0, u16(1), []byte{1, 0x80}),
+		mqttPacket(typePuback, 0, u16(2)),
+		mqttPacket(typePubrec, 0, u16(3)),
+		mqttPacket(typePubcomp, 0, u16(3)),
+		mqttPacket(typePublish, 0x03, str("alerts/fire"), u16(7), []byte("!")),
+		mqttPacket(typePingresp, 0),
+	}, nil)
+
+	list := dissect(t, client, server)
+	assert.Len(t, list, 7)
+	entries := byType(list)
+
+	data, err := json.Marshal(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	connect := entries["CONNECT"]
+	assert.Equal(t, "3.1.1", connect.Request["version"])
+	assert.Equal(t, "sensor-1", connect.Request["clientId"])
+	assert.Equal(t, "alice", connect.Request["username"])
+	assert.Equal(t, float64(60), connect.Request["keepAlive"])
+	will := connect.Request["will"].(map[string]interface{})
+	assert.Equal(t, "status/sensor-1", will["topic"])
+	assert.Equal(t, float64(1), will["qos"])
+	assert.Equal(t, true, will["retain"])
+	assert.Equal(t, "offline", will["payloadText"])
+	assert.Equal(t, "CONNACK", connect.Response["type"])
+	assert.Equal(t, "Connection Accepted", connect.Response["reason"])
+	assert.True(t, connect.Outgoing)
+
+	dissector := NewDissector()
+	subscribe := entries["SUBSCRIBE"]
+	assert.Len(t, subscribe.Request["subscriptions"], 2)
+	assert.Equal(t, float64(0x80), subscribe.Response["reasonCode"])
+	assert.Equal(t, "Failure", subscribe.Response["reason"])
+	summary := dissector.Summarize(subscribe)
+	assert.Equal(t, "SUBSCRIBE", summary.Method)
+	assert.Equal(t, "sensors/+/temp, alerts/#", summary.Summary)
+	assert.Equal(t, `request.subscriptions[0].topicFilter == "sensors/+/temp"`, summary.SummaryQuery)
+	assert.Equal(t, 0x80, summary.Status)
+	assert.Equal(t, `response.reasonCode == 128`, summary.StatusQuery)
+
+	qos0 := entries["PUBLISH/sensors/1/temp"]
+	assert.Equal(t, float64(0), qos0.Request["qos"])
+	assert.Equal(t, "21.5", qos0.Request["payloadText"])
+	assert.Equal(t, "", qos0.Response["type"])
+	summary = dissector.Summarize(qos0)
+	assert.Equal(t, "sensors/1/temp", summary.Summary)
+	assert.Equal(t, `request.topic == "sensors/1/temp"`, summary.SummaryQuery)
+	assert.Equal(t, 0, summary.Status)
+
+	qos1 := entries["PUBLISH/sensors/2/temp"]
+	assert.Equal(t, float64(2), qos1.Request["packetId"])
+	assert.Equal(t, "PUBACK", qos1.Response["type"])
+
+	qos2 := entries["PUBLISH/sensors/1/raw"]
+	assert.Equal(t, "/wA=", qos2.Request["payload"])
+	assert.Nil(t, qos2.Request["payloadText"])
+	assert.Equal(t, float64(2), qos2.Request["payloadSize"])
+	assert.Equal(t, "PUBCOMP", qos2.Response["type"])
+
+	fromBroker := entries["PUBLISH/alerts/fire"]
+	assert.Equal(t, true, fromBroker.Request["retain"])
+	assert.Equal(t, "PUBACK", fromBroker.Response["type"])
+	assert.False(t, fromBroker.Outgoing)
+
+	_, ok := entries["DISCONNECT"]
+	assert.True(t, ok)
+}
+
+func TestDissectVersion5(t *testing.T) {
+	client := bytes.Join([][]byte{
+		mqttPacket(typeConnect, 0, str("MQTT"), []byte{version5, 0x02}, u16(30),
+			props([]byte{0x11}, []byte{0, 0, 0x0e, 0x10}, []byte{0x15}, str("SCRAM-SHA-1"), []byte{0x16}, str("token")),
+			str("")),
+		mqttPacket(typeSubscribe, 0x02, u16(1), props(), str("$share/g/jobs"), []byte{0x25}),
+		mqttPacket(typePublish, 0x02, str("orders/new"), u16(2),
+			props([]byte{0x23}, u16(1), []byte{0x03}, str("application/json"), []byte{0x26}, str("tenant"), str("acme")),
+			[]byte(`{"id":1}`)),
+		mqttPacket(typePublish, 0x02, str(""), u16(3), props([]byte{0x23}, u16(1)), []byte(`{"id":2}`)),
+		mqttPacket(typeDisconnect, 0, []byte{0x04}),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		mqttPacket(typeConnack, 0, []byte{0, 0}, props([]byte{0x12}, str("auto-1"), []byte{0x22}, u16(10))),
+		mqttPacket(typeSuback, 0, u16(1), props([]byte{0x1f}, str("denied")), []byte{0x87}),
+		mqttPacket(typePuback, 0, u16(2), []byte{0x10}),
+		mqttPacket(typePuback, 0, u16(3)),
+	}, nil)
+
+	list := dissect(t, client, server)
+	assert.Len(t, list, 5)
+	entries := byType(list)
+
+	data, err := json.Marshal(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "dG9rZW4")
+
+	connect := entries["CONNECT"]
+	assert.Equal(t, "5.0", connect.Request["version"])
+	assert.Equal(t, true, connect.Request["cleanStart"])
+	assert.Equal(t, map[string]interface{}{
+		"sessionExpiryInterval": float64(3600),
+		"authenticationMethod":  "SCRAM-SHA-1",
+	}, connect.Request["properties"])
+	assert.Equal(t, "auto-1", connect.Response["properties"].(map[string]interface{})["assignedClientIdentifier"])
+
+	subscribe := entries["SUBSCRIBE"]
+	subscription := subscribe.Request["subscriptions"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(1), subscription["qos"])
+	assert.Equal(t, true, subscription["noLocal"])
+	assert.Equal(t, float64(2), subscription["retainHandling"])
+	assert.Equal(t, "Not authorized", subscribe.Response["reason"])
+	assert.Equal(t, "denied", subscribe.Response["properties"].(map[string]interface{})["reasonString"])
+
+	// Both messages are on the same topic, the second one by its alias
+	published := make(map[float64]*api.Entry)
+	for _, entry := range list {
+		if entry.Request["type"] == "PUBLISH" {
+			published[entry.Request["packetId"].(float64)] = entry
+		}
+	}
+	assert.Len(t, published, 2)
+
+	properties := published[2].Request["properties"].(map[string]interface{})
+	assert.Equal(t, "application/json", properties["contentType"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "tenant", "value": "acme"}}, properties["userProperties"])
+	assert.Equal(t, "No matching subscribers", published[2].Response["reason"])
+
+	aliased := published[3]
+	assert.Equal(t, "orders/new", aliased.Request["topic"])
+	assert.Equal(t, `{"id":2}`, aliased.Request["payloadText"])
+	assert.Equal(t, "Success", aliased.Response["reason"])
+
+	disconnect := entries["DISCONNECT"]
+	assert.Equal(t, "Disconnect with Will Message", disconnect.Request["reason"])
+	summary := NewDissector().Summarize(disconnect)
+	assert.Equal(t, "Disconnect with Will Message", summary.Summary)
+}
+
+func TestDissectEmptyClientID(t *testing.T) {
+	client := mqttPacket(typeConnect, 0, str("MQTT"), []byte{version311, 0x02}, u16(60), str(""))
+	server := mqttPacket(typeConnack, 0, []byte{0, 0})
+
+	entries := byType(dissect(t, client, server))
+	entry := entries["CONNECT"]
+	if !assert.NotNil(t, entry) {
+		return
+	}
+	assert.Nil(t, entry.Request["clientId"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, "", summary.Summary)
+	assert.Equal(t, "", summary.SummaryQuery)
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
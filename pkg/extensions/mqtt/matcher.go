@@ -0,0 +1,120 @@
+package mqtt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{requester_ip}_{responder_ip}_{requester_port}_{responder_port}_{packet_id}_{occurrence}`
+// Either side may publish, so the requester isn't always the client.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *MqttRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMqttMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MqttPayload{
+			Data: &MqttWrapper{
+				Method:  request.Type,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseMqttMessage := response.(*api.GenericMessage)
+		if responseMqttMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestMqttMessage, responseMqttMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestMqttMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *MqttResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseMqttMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MqttPayload{
+			Data: &MqttWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestMqttMessage := request.(*api.GenericMessage)
+		if !requestMqttMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestMqttMessage, &responseMqttMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseMqttMessage)
+	return nil
+}
+
+// prepareUnacknowledged pairs a packet that gets no reply, like a QoS 0
+// PUBLISH or a DISCONNECT, with an empty response.
+func (matcher *requestResponseMatcher) prepareUnacknowledged(request *MqttRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMqttMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MqttPayload{
+			Data: &MqttWrapper{
+				Method:  request.Type,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseMqttMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: MqttPayload{
+			Data: &MqttWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &MqttResponse{},
+			},
+		},
+	}
+	return matcher.preparePair(&requestMqttMessage, &responseMqttMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestMqttMessage *api.GenericMessage, responseMqttMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestMqttMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestMqttMessage,
+			Response: *responseMqttMessage,
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package mqtt
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+const (
+	propertyByte = iota
+	propertyUint16
+	propertyUint32
+	propertyVarInt
+	propertyString
+	propertyBinary
+	propertyStringPair
+)
+
+const (
+	propertySubscriptionIdentifier = 0x0b
+	propertyAuthenticationData     = 0x16
+	propertyTopicAlias             = 0x23
+	propertyUserProperty           = 0x26
+)
+
+type property struct {
+	Name string
+	Type int
+}
+
+var properties = map[int]property{
+	0x01: {"payloadFormatIndicator", propertyByte},
+	0x02: {"messageExpiryInterval", propertyUint32},
+	0x03: {"contentType", propertyString},
+	0x08: {"responseTopic", propertyString},
+	0x09: {"correlationData", propertyBinary},
+	0x0b: {"subscriptionIdentifier", propertyVarInt},
+	0x11: {"sessionExpiryInterval", propertyUint32},
+	0x12: {"assignedClientIdentifier", propertyString},
+	0x13: {"serverKeepAlive", propertyUint16},
+	0x15: {"authenticationMethod", propertyString},
+	0x16: {"authenticationData", propertyBinary},
+	0x17: {"requestProblemInformation", propertyByte},
+	0x18: {"willDelayInterval", propertyUint32},
+	0x19: {"requestResponseInformation", propertyByte},
+	0x1a: {"responseInformation", propertyString},
+	0x1c: {"serverReference", propertyString},
+	0x1f: {"reasonString", propertyString},
+	0x21: {"receiveMaximum", propertyUint16},
+	0x22: {"topicAliasMaximum", propertyUint16},
+	0x23: {"topicAlias", propertyUint16},
+	0x24: {"maximumQos", propertyByte},
+	0x25: {"retainAvailable", propertyByte},
+	0x26: {"userProperties", propertyStringPair},
+	0x27: {"maximumPacketSize", propertyUint32},
+	0x28: {"wildcardSubscriptionAvailable", propertyByte},
+	0x29: {"subscriptionIdentifierAvailable", propertyByte},
+	0x2a: {"sharedSubscriptionAvailable", propertyByte},
+}
+
+type MqttUserProperty struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// readProperties reads the properties that MQTT 5 adds to most packets.
+// The user properties and the subscription identifiers may be repeated, so
+// they are collected into lists.
+func (b *buffer) readProperties() map[string]interface{} {
+	length := b.readVarInt()
+	data := b.next(length)
+	if b.err != nil {
+		return nil
+	}
+
+	props := make(map[string]interface{})
+	p := &buffer{data: data}
+	for len(p.data) > 0 && p.err == nil {
+		id := p.readVarInt()
+		prop, ok := properties[id]
+		if !ok {
+			if p.err == nil {
+				p.err = fmt.Errorf("unknown property: %#x", id)
+			}
+			break
+		}
+
+		var value interface{}
+		switch prop.Type {
+		case propertyByte:
+			value = p.readByte()
+		case propertyUint16:
+			value = p.readUint16()
+		case propertyUint32:
+			value = p.readUint32()
+		case propertyVarInt:
+			value = p.readVarInt()
+		case propertyString:
+			value = p.readString()
+		case propertyBinary:
+			value = base64.StdEncoding.EncodeToString(p.readBinary())
+		case propertyStringPair:
+			value = MqttUserProperty{Key: p.readString(), Value: p.readString()}
+		}
+		if p.err != nil {
+			break
+		}
+
+		switch id {
+		case propertyAuthenticationData:
+			// Left out like the password since it holds the credentials
+		case propertyUserProperty:
+			userProperties, _ := props[prop.Name].([]MqttUserProperty)
+			props[prop.Name] = append(userProperties, value.(MqttUserProperty))
+		case propertySubscriptionIdentifier:
+			identifiers, _ := props[prop.Name].([]int)
+			props[prop.Name] = append(identifiers, value.(int))
+		default:
+			props[prop.Name] = value
+		}
+	}
+	if p.err != nil {
+		b.err = p.err
+		return nil
+	}
+
+	return props
+}
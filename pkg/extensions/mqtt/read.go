@@ -0,0 +1,200 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	typeConnect     = 1
+	typeConnack     = 2
+	typePublish     = 3
+	typePuback      = 4
+	typePubrec      = 5
+	typePubrel      = 6
+	typePubcomp     = 7
+	typeSubscribe   = 8
+	typeSuback      = 9
+	typeUnsubscribe = 10
+	typeUnsuback    = 11
+	typePingreq     = 12
+	typePingresp    = 13
+	typeDisconnect  = 14
+	typeAuth        = 15
+)
+
+var packetTypes = map[byte]string{
+	typeConnect:     "CONNECT",
+	typeConnack:     "CONNACK",
+	typePublish:     "PUBLISH",
+	typePuback:      "PUBACK",
+	typePubrec:      "PUBREC",
+	typePubrel:      "PUBREL",
+	typePubcomp:     "PUBCOMP",
+	typeSubscribe:   "SUBSCRIBE",
+	typeSuback:      "SUBACK",
+	typeUnsubscribe: "UNSUBSCRIBE",
+	typeUnsuback:    "UNSUBACK",
+	typePingreq:     "PINGREQ",
+	typePingresp:    "PINGRESP",
+	typeDisconnect:  "DISCONNECT",
+	typeAuth:        "AUTH",
+}
+
+const (
+	version31  = 3
+	version311 = 4
+	version5   = 5
+)
+
+var protocolVersions = map[byte]string{
+	version31:  "3.1",
+	version311: "3.1.1",
+	version5:   "5.0",
+}
+
+type packet struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+func readPacket(r *bufio.Reader) (*packet, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &packet{
+		Type:  first >> 4,
+		Flags: first & 0x0f,
+	}
+	if p.Type == 0 {
+		return nil, errors.New("reserved packet type")
+	}
+	switch p.Type {
+	case typePublish:
+		if (p.Flags>>1)&0x03 == 0x03 {
+			return nil, errors.New("invalid QoS: 3")
+		}
+	case typePubrel, typeSubscribe, typeUnsubscribe:
+		if p.Flags != 0x02 {
+			return nil, fmt.Errorf("invalid %s flags: %#x", packetTypes[p.Type], p.Flags)
+		}
+	default:
+		if p.Flags != 0 {
+			return nil, fmt.Errorf("invalid %s flags: %#x", packetTypes[p.Type], p.Flags)
+		}
+	}
+
+	length, err := readRemainingLength(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Body = make([]byte, length)
+	if _, err := io.ReadFull(r, p.Body); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// readRemainingLength reads a variable byte integer of up to four bytes.
+func readRemainingLength(r io.ByteReader) (int, error) {
+	value := 0
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= int(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+var errShortPacket = errors.New("packet is shorter than its declared fields")
+
+// buffer is a cursor over the variable header and the payload of a packet.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+func (b *buffer) next(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || len(b.data) < n {
+		b.err = errShortPacket
+		return nil
+	}
+	v := b.data[:n]
+	b.data = b.data[n:]
+	return v
+}
+
+func (b *buffer) readByte() byte {
+	v := b.next(1)
+	if v == nil {
+		return 0
+	}
+	return v[0]
+}
+
+func (b *buffer) readUint16() uint16 {
+	v := b.next(2)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(v)
+}
+
+func (b *buffer) readUint32() uint32 {
+	v := b.next(4)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(v)
+}
+
+func (b *buffer) readVarInt() int {
+	value := 0
+	for i := 0; i < 4; i++ {
+		c := b.readByte()
+		if b.err != nil {
+			return 0
+		}
+		value |= int(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			return value
+		}
+	}
+	b.err = errors.New("malformed variable byte integer")
+	return 0
+}
+
+func (b *buffer) readBinary() []byte {
+	return b.next(int(b.readUint16()))
+}
+
+func (b *buffer) readString() string {
+	s := b.readBinary()
+	if b.err == nil && !utf8.Valid(s) {
+		b.err = errors.New("string is not valid UTF-8")
+	}
+	return string(s)
+}
+
+func (b *buffer) readRest() []byte {
+	v := b.data
+	b.data = nil
+	return v
+}
@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"encoding/json"
+)
+
+type MqttPayload struct {
+	Data interface{}
+}
+
+type MqttPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h MqttPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type MqttWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+// MqttMessage is an application message, either published or left as the
+// will of a client. The payload is base64 encoded and also given as text
+// when it's valid UTF-8.
+type MqttMessage struct {
+	Topic       string                 `json:"topic"`
+	QoS         byte                   `json:"qos"`
+	Retain      bool                   `json:"retain"`
+	Payload     string                 `json:"payload"`
+	PayloadText string                 `json:"payloadText,omitempty"`
+	PayloadSize int                    `json:"payloadSize"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+type MqttSubscription struct {
+	TopicFilter       string `json:"topicFilter"`
+	QoS               byte   `json:"qos"`
+	NoLocal           bool   `json:"noLocal,omitempty"`
+	RetainAsPublished bool   `json:"retainAsPublished,omitempty"`
+	RetainHandling    byte   `json:"retainHandling,omitempty"`
+}
+
+type MqttRequest struct {
+	Type          string                 `json:"type"`
+	Version       string                 `json:"version"`
+	PacketID      uint16                 `json:"packetId"`
+	Topic         string                 `json:"topic"`
+	QoS           byte                   `json:"qos"`
+	Retain        bool                   `json:"retain"`
+	Dup           bool                   `json:"dup"`
+	Payload       string                 `json:"payload"`
+	PayloadText   string                 `json:"payloadText,omitempty"`
+	PayloadSize   int                    `json:"payloadSize"`
+	ClientID      string                 `json:"clientId,omitempty"`
+	Username      string                 `json:"username,omitempty"`
+	KeepAlive     uint16                 `json:"keepAlive,omitempty"`
+	CleanStart    bool                   `json:"cleanStart,omitempty"`
+	Will          *MqttMessage           `json:"will,omitempty"`
+	Subscriptions []MqttSubscription     `json:"subscriptions,omitempty"`
+	TopicFilters  []string               `json:"topicFilters,omitempty"`
+	ReasonCode    byte                   `json:"reasonCode,omitempty"`
+	Reason        string                 `json:"reason,omitempty"`
+	Properties    map[string]interface{} `json:"properties,omitempty"`
+}
+
+type MqttReason struct {
+	Code   byte   `json:"code"`
+	Reason string `json:"reason"`
+}
+
+type MqttResponse struct {
+	Type           string                 `json:"type"`
+	PacketID       uint16                 `json:"packetId"`
+	ReasonCode     byte                   `json:"reasonCode"`
+	Reason         string                 `json:"reason"`
+	SessionPresent bool                   `json:"sessionPresent,omitempty"`
+	ReasonCodes    []MqttReason           `json:"reasonCodes,omitempty"`
+	Properties     map[string]interface{} `json:"properties,omitempty"`
+}
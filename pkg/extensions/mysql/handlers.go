@@ -0,0 +1,837 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var errCompressed = errors.New("the compressed protocol is not supported")
+
+// clientState keeps track of a client's side of a connection: the
+// negotiated capabilities and the parameter types bound to each prepared
+// statement, which the client sends only on the first execution.
+type clientState struct {
+	capabilities   uint32
+	started        bool
+	compressed     bool
+	parameterTypes map[uint32][]uint16
+}
+
+func newClientState() *clientState {
+	return &clientState{
+		parameterTypes: make(map[uint32][]uint16),
+	}
+}
+
+// handlePacket parses a client packet and returns the request that it
+// starts, if any. Packets that continue an exchange, such as the
+// authentication data or the content of a LOAD DATA LOCAL INFILE, have a
+// non-zero sequence id and are skipped.
+func (s *clientState) handlePacket(p *packet, reqResMatcher *requestResponseMatcher) (*MysqlRequest, error) {
+	if p.Sequence != 0 {
+		if !s.started {
+			s.started = true
+			return s.readHandshakeResponse(p)
+		}
+		return nil, nil
+	}
+
+	if len(p.Payload) == 0 {
+		return nil, errors.New("empty command packet")
+	}
+	name, ok := commands[p.Payload[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown command: 0x%02x", p.Payload[0])
+	}
+	s.started = true
+
+	request := &MysqlRequest{
+		Type:       name,
+		Parameters: make([]interface{}, 0),
+		Sequence:   p.Sequence,
+	}
+	b := &buffer{data: p.Payload[1:]}
+
+	switch p.Payload[0] {
+	case comQuit, comStmtSendLongData:
+		// Neither of these gets a response
+		return nil, nil
+	case comStmtClose:
+		id := b.readUint32()
+		if b.err != nil {
+			return nil, b.err
+		}
+		delete(s.parameterTypes, id)
+		reqResMatcher.deleteStatement(id)
+		return nil, nil
+	case comQuery:
+		// With CLIENT_QUERY_ATTRIBUTES the query is prefixed by its attributes.
+		// If the handshake wasn't captured, the prefix of a query without
+		// attributes gives it away since a query can't start with a zero byte.
+		if s.capabilities&clientQueryAttributes != 0 || (len(b.data) >= 2 && b.data[0] == 0 && b.data[1] == 1) {
+			count, _ := b.readLengthEncodedInteger()
+			b.readLengthEncodedInteger() // parameter set count, always 1
+			if count > 0 {
+				request.Parameters, _ = s.readParameters(b, int(count), nil, true)
+			}
+		}
+		query := b.readRest()
+		if b.err != nil {
+			return nil, b.err
+		}
+		if !utf8.Valid(query) {
+			return nil, errors.New("query is not valid UTF-8")
+		}
+		request.Statement = string(query)
+	case comStmtPrepare:
+		request.Statement = string(b.readRest())
+	case comStmtExecute:
+		request.StatementID = b.readUint32()
+		flags := b.readByte()
+		b.readUint32() // iteration count, always 1
+		if b.err != nil {
+			return nil, b.err
+		}
+
+		numParams := -1
+		if statement := reqResMatcher.waitForStatement(request.StatementID); statement != nil {
+			request.Statement = statement.Query
+			numParams = statement.NumParams
+		}
+		if s.capabilities&clientQueryAttributes != 0 && (numParams > 0 || flags&parameterCountAvailable != 0) {
+			count, _ := b.readLengthEncodedInteger()
+			numParams = int(count)
+		}
+		if numParams > 0 {
+			params, types := s.readParameters(b, numParams, s.parameterTypes[request.StatementID], s.capabilities&clientQueryAttributes != 0)
+			if b.err != nil {
+				return nil, b.err
+			}
+			s.parameterTypes[request.StatementID] = types
+			request.Parameters = params
+		}
+	case comStmtReset, comStmtFetch:
+		request.StatementID = b.readUint32()
+		if b.err != nil {
+			return nil, b.err
+		}
+		if statement, found := reqResMatcher.statements.Load(request.StatementID); found {
+			request.Statement = statement.(*preparedStatement).Query
+		}
+	case comInitDB:
+		request.Database = string(b.readRest())
+	case comChangeUser:
+		request.User = b.readNullTerminatedString()
+		if b.err != nil {
+			return nil, b.err
+		}
+	}
+
+	request.Command = queryCommand(request.Statement)
+	if request.Command == "" {
+		request.Command = request.Type
+	}
+
+	return request, nil
+}
+
+// readParameters reads the values of a COM_STMT_EXECUTE or the attributes
+// of a COM_QUERY. The types are sent only when they are (re)bound,
+// otherwise the previous ones are used.
+func (s *clientState) readParameters(b *buffer, count int, previous []uint16, withNames bool) ([]interface{}, []uint16) {
+	bitmap := b.readBytes((count + 7) / 8)
+	types := previous
+	if b.readByte() == 1 {
+		types = make([]uint16, count)
+		for i := 0; i < count && b.err == nil; i++ {
+			types[i] = b.readUint16()
+			if withNames {
+				b.readLengthEncodedString()
+			}
+		}
+	}
+
+	params := make([]interface{}, 0)
+	if b.err != nil || len(types) < count {
+		return params, types
+	}
+	for i := 0; i < count && b.err == nil; i++ {
+		if isNullInBitmap(bitmap, i, executeParametersNullBitmapOffset) {
+			params = append(params, nil)
+			continue
+		}
+		fieldType := byte(types[i] & 0xff)
+		unsigned := byte(types[i]>>8)&paramUnsignedFlag != 0
+		params = append(params, truncateValue(b.readBinaryValue(fieldType, unsigned)))
+	}
+	return params, types
+}
+
+func (s *clientState) readHandshakeResponse(p *packet) (*MysqlRequest, error) {
+	if p.Sequence != 1 || len(p.Payload) < handshakeResponseSize {
+		return nil, fmt.Errorf("unexpected packet with sequence id %d", p.Sequence)
+	}
+
+	b := &buffer{data: p.Payload}
+	request := &MysqlRequest{
+		Type:       typeHandshake,
+		Parameters: make([]interface{}, 0),
+		Sequence:   p.Sequence,
+	}
+	request.Capabilities = b.readUint32()
+	b.readUint32() // max packet size
+	request.CharacterSet = b.readByte()
+	filler := b.readBytes(23)
+	if request.Capabilities&clientProtocol41 == 0 {
+		return nil, errors.New("only the 4.1 protocol is supported")
+	}
+	for _, c := range filler {
+		if c != 0 {
+			return nil, errors.New("invalid handshake response")
+		}
+	}
+
+	s.capabilities = request.Capabilities
+
+	if len(p.Payload) == handshakeResponseSize && request.Capabilities&clientSSL != 0 {
+		request.Type = typeSSLRequest
+		request.Command = typeSSLRequest
+		return request, nil
+	}
+
+	request.User = b.readNullTerminatedString()
+	if request.Capabilities&clientPluginAuthLenencClientData != 0 {
+		b.readLengthEncodedString()
+	} else if request.Capabilities&clientSecureConnection != 0 {
+		b.readBytes(int(b.readByte()))
+	} else {
+		b.readNullTerminatedString()
+	}
+	if request.Capabilities&clientConnectWithDB != 0 && len(b.data) > 0 {
+		request.Database = b.readNullTerminatedString()
+	}
+	if request.Capabilities&clientPluginAuth != 0 && len(b.data) > 0 {
+		request.AuthPlugin = b.readNullTerminatedString()
+	}
+	if request.Capabilities&clientConnectAttrs != 0 && len(b.data) > 0 {
+		attributes, _ := b.readLengthEncodedString()
+		ab := &buffer{data: attributes}
+		request.Attributes = make(map[string]string)
+		for len(ab.data) > 0 && ab.err == nil {
+			key, _ := ab.readLengthEncodedString()
+			value, _ := ab.readLengthEncodedString()
+			request.Attributes[string(key)] = string(value)
+		}
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	s.compressed = request.Capabilities&clientCompress != 0
+	request.Command = typeHandshake
+
+	return request, nil
+}
+
+// serverState keeps track of a server's side of a connection.
+type serverState struct {
+	capabilities      uint32
+	capabilitiesKnown bool
+	started           bool
+	compressed        bool
+	statementColumns  map[uint32][]MysqlColumn
+}
+
+func newServerState() *serverState {
+	return &serverState{
+		statementColumns: make(map[uint32][]MysqlColumn),
+	}
+}
+
+// handlePacket reads the response that starts with the packet. The request
+// it answers is looked up first since it determines the response layout.
+func (s *serverState) handlePacket(r *MysqlReader, p *packet, tcpID *api.TcpID, counterPair *api.CounterPair, reqResMatcher *requestResponseMatcher) (string, *MysqlResponse, error) {
+	if len(p.Payload) == 0 {
+		return "", nil, errors.New("empty packet")
+	}
+
+	if !s.started && p.Sequence == 0 && p.Payload[0] == handshakeV10 {
+		response, err := s.readGreeting(p)
+		if err != nil {
+			return "", nil, err
+		}
+		s.started = true
+
+		ident := responseIdent(tcpID, counterPair)
+		request := reqResMatcher.waitForRequest(ident)
+		if request == nil {
+			return "", nil, errors.New("couldn't match the handshake to a client")
+		}
+
+		s.capabilities = request.Capabilities
+		s.capabilitiesKnown = true
+		s.compressed = request.Capabilities&response.Capabilities&clientCompress != 0
+		if request.Type == typeSSLRequest {
+			return ident, response, nil
+		}
+
+		next, err := r.readPacket()
+		if err != nil {
+			return "", nil, err
+		}
+		err = s.readAuthentication(r, next, response)
+		return ident, response, err
+	}
+
+	if p.Sequence != 1 {
+		return "", nil, fmt.Errorf("unexpected packet with sequence id %d", p.Sequence)
+	}
+	s.started = true
+
+	ident := responseIdent(tcpID, counterPair)
+	request := reqResMatcher.waitForRequest(ident)
+	if request == nil {
+		return "", nil, fmt.Errorf("couldn't match a MySQL response to a MySQL request in %d milliseconds", reqResMatcher.maxTry)
+	}
+
+	response := &MysqlResponse{
+		Columns: make([]MysqlColumn, 0),
+		Rows:    make([][]interface{}, 0),
+	}
+
+	var err error
+	switch request.Type {
+	case commands[comQuery]:
+		err = s.readQueryResponse(r, p, response, false, 0)
+	case commands[comStmtExecute]:
+		err = s.readQueryResponse(r, p, response, true, request.StatementID)
+	case commands[comStmtPrepare]:
+		err = s.readPrepareResponse(r, p, response)
+		if err == nil && response.Type == typePrepareOK {
+			reqResMatcher.registerStatement(response.StatementID, &preparedStatement{
+				Query:     request.Statement,
+				NumParams: int(response.NumParams),
+			})
+		}
+		reqResMatcher.endPrepare()
+	case commands[comStmtFetch]:
+		err = s.readFetchResponse(r, p, response, request.StatementID)
+	case commands[comFieldList]:
+		err = s.readFieldListResponse(r, p, response)
+	case commands[comStatistics]:
+		response.Type = typeStatistics
+		response.Info = formatValue(p.Payload)
+	case commands[comChangeUser]:
+		err = s.readAuthentication(r, p, response)
+	default:
+		err = s.readGenericResponse(p, response)
+	}
+
+	return ident, response, err
+}
+
+func (s *serverState) readGreeting(p *packet) (*MysqlResponse, error) {
+	b := &buffer{data: p.Payload[1:]}
+	response := &MysqlResponse{
+		Type:    typeHandshake,
+		Columns: make([]MysqlColumn, 0),
+		Rows:    make([][]interface{}, 0),
+	}
+
+	response.ServerVersion = b.readNullTerminatedString()
+	if response.ServerVersion == "" {
+		return nil, errors.New("invalid server version")
+	}
+	for _, c := range response.ServerVersion {
+		if c < 0x20 || c > 0x7e {
+			return nil, errors.New("invalid server version")
+		}
+	}
+	response.ConnectionID = b.readUint32()
+	b.readBytes(8) // first part of the authentication data
+	b.readByte()   // filler
+	response.Capabilities = uint32(b.readUint16())
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if len(b.data) > 0 {
+		b.readByte()   // character set
+		b.readUint16() // status flags
+		response.Capabilities |= uint32(b.readUint16()) << 16
+		authDataLength := int(b.readByte())
+		b.readBytes(10) // reserved
+		if response.Capabilities&clientSecureConnection != 0 {
+			length := authDataLength - 8
+			if length < 13 {
+				length = 13
+			}
+			b.readBytes(length)
+		}
+		if response.Capabilities&clientPluginAuth != 0 && len(b.data) > 0 {
+			plugin := b.readRest()
+			for len(plugin) > 0 && plugin[len(plugin)-1] == 0 {
+				plugin = plugin[:len(plugin)-1]
+			}
+			response.AuthPlugin = string(plugin)
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+	}
+
+	return response, nil
+}
+
+// readAuthentication follows an authentication exchange until the server
+// accepts or rejects the client.
+func (s *serverState) readAuthentication(r *MysqlReader, p *packet, response *MysqlResponse) error {
+	for {
+		if len(p.Payload) == 0 {
+			return errors.New("empty packet")
+		}
+
+		switch p.Payload[0] {
+		case okPacket:
+			if response.Type == "" {
+				response.Type = typeOK
+			}
+			return s.readOK(p, response)
+		case errPacket:
+			response.Type = typeError
+			return s.readError(p, response)
+		case eofPacket:
+			// AuthSwitchRequest
+			b := &buffer{data: p.Payload[1:]}
+			if len(b.data) > 0 {
+				response.AuthPlugin = b.readNullTerminatedString()
+			}
+		case authMoreDataPacket:
+		default:
+			return fmt.Errorf("unexpected authentication packet: 0x%02x", p.Payload[0])
+		}
+
+		var err error
+		p, err = r.readPacket()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *serverState) readGenericResponse(p *packet, response *MysqlResponse) error {
+	switch {
+	case p.Payload[0] == okPacket:
+		response.Type = typeOK
+		return s.readOK(p, response)
+	case p.Payload[0] == errPacket:
+		response.Type = typeError
+		return s.readError(p, response)
+	case isEOFPacket(p):
+		response.Type = typeEOF
+		return s.readEOF(p, response)
+	default:
+		return fmt.Errorf("unexpected response packet: 0x%02x", p.Payload[0])
+	}
+}
+
+// readQueryResponse reads the OK, ERR or result sets that answer a
+// COM_QUERY or COM_STMT_EXECUTE. Multiple results, returned by multi
+// statements or stored procedures, are folded into the same response.
+func (s *serverState) readQueryResponse(r *MysqlReader, p *packet, response *MysqlResponse, binaryRows bool, statementID uint32) error {
+	for {
+		switch p.Payload[0] {
+		case okPacket:
+			if response.Type == "" {
+				response.Type = typeOK
+			}
+			if err := s.readOK(p, response); err != nil {
+				return err
+			}
+		case errPacket:
+			response.Type = typeError
+			return s.readError(p, response)
+		case localInfilePacket:
+			response.Info = string(p.Payload[1:])
+			next, err := r.readPacket()
+			if err != nil {
+				return err
+			}
+			p = next
+			continue
+		default:
+			response.Type = typeResultSet
+			columns, err := s.readResultSet(r, p, response, binaryRows)
+			if err != nil {
+				return err
+			}
+			if binaryRows {
+				s.statementColumns[statementID] = columns
+			}
+		}
+
+		if response.StatusFlags&serverMoreResultsExists == 0 || response.Error != nil {
+			return nil
+		}
+
+		next, err := r.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(next.Payload) == 0 {
+			return errors.New("empty packet")
+		}
+		p = next
+	}
+}
+
+func (s *serverState) readResultSet(r *MysqlReader, p *packet, response *MysqlResponse, binaryRows bool) ([]MysqlColumn, error) {
+	b := &buffer{data: p.Payload}
+	count, _ := b.readLengthEncodedInteger()
+	if b.err != nil {
+		return nil, b.err
+	}
+	if count == 0 || count > 4096 {
+		return nil, fmt.Errorf("invalid column count: %d", count)
+	}
+
+	columns, err := s.readColumnDefinitions(r, int(count))
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Columns) == 0 {
+		response.Columns = columns
+	}
+
+	p, err = r.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if isEOFPacket(p) {
+		if err = s.readEOF(p, response); err != nil {
+			return nil, err
+		}
+		// A cursor was opened, the rows are read by COM_STMT_FETCH
+		if response.StatusFlags&serverStatusCursorExists != 0 {
+			return columns, nil
+		}
+		if p, err = r.readPacket(); err != nil {
+			return nil, err
+		}
+	}
+
+	return columns, s.readRows(r, p, response, columns, binaryRows)
+}
+
+func (s *serverState) readRows(r *MysqlReader, p *packet, response *MysqlResponse, columns []MysqlColumn, binaryRows bool) (err error) {
+	for {
+		switch {
+		case len(p.Payload) == 0:
+			return errors.New("empty packet")
+		case p.Payload[0] == errPacket:
+			response.Type = typeError
+			return s.readError(p, response)
+		case isEOFPacket(p):
+			return s.readEOF(p, response)
+		case isResultSetTerminator(p):
+			return s.readOK(p, response)
+		}
+
+		var row []interface{}
+		if binaryRows {
+			row, err = readBinaryRow(p, columns)
+		} else {
+			row, err = readTextRow(p, columns)
+		}
+		if err != nil {
+			return err
+		}
+		if len(response.Rows) < maxRowsInResponse {
+			response.Rows = append(response.Rows, row)
+		}
+		response.RowCount++
+
+		if p, err = r.readPacket(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *serverState) readPrepareResponse(r *MysqlReader, p *packet, response *MysqlResponse) error {
+	if p.Payload[0] == errPacket {
+		response.Type = typeError
+		return s.readError(p, response)
+	}
+	if p.Payload[0] != okPacket || len(p.Payload) < prepareOKLength {
+		return fmt.Errorf("unexpected response packet: 0x%02x", p.Payload[0])
+	}
+
+	response.Type = typePrepareOK
+	b := &buffer{data: p.Payload[1:]}
+	response.StatementID = b.readUint32()
+	numColumns := b.readUint16()
+	response.NumParams = b.readUint16()
+	b.readByte() // filler
+	response.Warnings = b.readUint16()
+	if b.err != nil {
+		return b.err
+	}
+
+	if response.NumParams > 0 {
+		if _, err := s.readColumnDefinitions(r, int(response.NumParams)); err != nil {
+			return err
+		}
+		if err := s.skipDefinitionsEOF(r); err != nil {
+			return err
+		}
+	}
+	if numColumns > 0 {
+		columns, err := s.readColumnDefinitions(r, int(numColumns))
+		if err != nil {
+			return err
+		}
+		response.Columns = columns
+		if err := s.skipDefinitionsEOF(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skipDefinitionsEOF consumes the EOF packet that follows a block of
+// definitions unless CLIENT_DEPRECATE_EOF is in effect. If the handshake
+// wasn't captured, the next packet tells whether it's there.
+func (s *serverState) skipDefinitionsEOF(r *MysqlReader) error {
+	if s.capabilitiesKnown && s.capabilities&clientDeprecateEOF != 0 {
+		return nil
+	}
+	p, err := r.readPacket()
+	if err != nil {
+		return err
+	}
+	if !isEOFPacket(p) {
+		r.unreadPacket(p)
+	}
+	return nil
+}
+
+func (s *serverState) readFetchResponse(r *MysqlReader, p *packet, response *MysqlResponse, statementID uint32) error {
+	if p.Payload[0] == errPacket {
+		response.Type = typeError
+		return s.readError(p, response)
+	}
+	columns, ok := s.statementColumns[statementID]
+	if !ok {
+		return fmt.Errorf("unknown statement: %d", statementID)
+	}
+	response.Type = typeResultSet
+	response.Columns = columns
+	return s.readRows(r, p, response, columns, true)
+}
+
+func (s *serverState) readFieldListResponse(r *MysqlReader, p *packet, response *MysqlResponse) error {
+	response.Type = typeFieldList
+	for {
+		switch {
+		case p.Payload[0] == errPacket:
+			response.Type = typeError
+			return s.readError(p, response)
+		case isResultSetTerminator(p):
+			return nil
+		}
+
+		column, err := readColumnDefinition(p)
+		if err != nil {
+			return err
+		}
+		response.Columns = append(response.Columns, column)
+
+		if p, err = r.readPacket(); err != nil {
+			return err
+		}
+		if len(p.Payload) == 0 {
+			return errors.New("empty packet")
+		}
+	}
+}
+
+func (s *serverState) readColumnDefinitions(r *MysqlReader, count int) ([]MysqlColumn, error) {
+	columns := make([]MysqlColumn, 0, count)
+	for i := 0; i < count; i++ {
+		p, err := r.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		column, err := readColumnDefinition(p)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func readColumnDefinition(p *packet) (MysqlColumn, error) {
+	b := &buffer{data: p.Payload}
+	column := MysqlColumn{}
+
+	catalog, _ := b.readLengthEncodedString()
+	if b.err == nil && string(catalog) != "def" {
+		return column, errors.New("invalid column definition")
+	}
+	schema, _ := b.readLengthEncodedString()
+	table, _ := b.readLengthEncodedString()
+	b.readLengthEncodedString() // original table
+	name, _ := b.readLengthEncodedString()
+	b.readLengthEncodedString() // original name
+	b.readLengthEncodedInteger()
+	b.readUint16() // character set
+	column.Length = b.readUint32()
+	column.fieldType = b.readByte()
+	column.Flags = b.readUint16()
+	if b.err != nil {
+		return column, b.err
+	}
+
+	column.Schema = string(schema)
+	column.Table = string(table)
+	column.Name = string(name)
+	column.Type = columnTypes[column.fieldType]
+	if column.Type == "" {
+		column.Type = fmt.Sprintf("0x%02x", column.fieldType)
+	}
+	return column, nil
+}
+
+func readTextRow(p *packet, columns []MysqlColumn) ([]interface{}, error) {
+	b := &buffer{data: p.Payload}
+	row := make([]interface{}, 0, len(columns))
+	for range columns {
+		value, isNull := b.readLengthEncodedString()
+		if isNull {
+			row = append(row, nil)
+			continue
+		}
+		row = append(row, truncateValue(formatValue(value)))
+	}
+	return row, b.err
+}
+
+func readBinaryRow(p *packet, columns []MysqlColumn) ([]interface{}, error) {
+	b := &buffer{data: p.Payload}
+	if b.readByte() != okPacket {
+		return nil, errors.New("invalid binary row")
+	}
+	bitmap := b.readBytes((len(columns) + 7 + binaryRowNullBitmapOffset) / 8)
+	row := make([]interface{}, 0, len(columns))
+	for i, column := range columns {
+		if isNullInBitmap(bitmap, i, binaryRowNullBitmapOffset) {
+			row = append(row, nil)
+			continue
+		}
+		row = append(row, truncateValue(b.readBinaryValue(column.fieldType, column.Flags&unsignedFlag != 0)))
+	}
+	return row, b.err
+}
+
+func (s *serverState) readOK(p *packet, response *MysqlResponse) error {
+	b := &buffer{data: p.Payload[1:]}
+	affectedRows, _ := b.readLengthEncodedInteger()
+	response.AffectedRows += affectedRows
+	response.LastInsertID, _ = b.readLengthEncodedInteger()
+	response.StatusFlags = b.readUint16()
+	response.Warnings = b.readUint16()
+	if s.capabilities&clientSessionTrack != 0 {
+		info, _ := b.readLengthEncodedString()
+		response.Info = string(info)
+	} else if len(b.data) > 0 {
+		response.Info = string(b.readRest())
+	}
+	return b.err
+}
+
+func (s *serverState) readEOF(p *packet, response *MysqlResponse) error {
+	b := &buffer{data: p.Payload[1:]}
+	response.Warnings = b.readUint16()
+	response.StatusFlags = b.readUint16()
+	return b.err
+}
+
+func (s *serverState) readError(p *packet, response *MysqlResponse) error {
+	b := &buffer{data: p.Payload[1:]}
+	mysqlError := &MysqlError{}
+	mysqlError.Code = b.readUint16()
+	if len(b.data) > 0 && b.data[0] == '#' {
+		b.readByte()
+		mysqlError.SQLState = string(b.readBytes(5))
+	}
+	mysqlError.Message = string(b.readRest())
+	response.Error = mysqlError
+	return b.err
+}
+
+func responseIdent(tcpID *api.TcpID, counterPair *api.CounterPair) string {
+	counterPair.Lock()
+	counterPair.Response++
+	responseCounter := counterPair.Response
+	counterPair.Unlock()
+
+	return fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		tcpID.DstIP,
+		tcpID.SrcIP,
+		tcpID.DstPort,
+		tcpID.SrcPort,
+		responseCounter,
+	)
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, request *MysqlRequest, reqResMatcher *requestResponseMatcher) {
+	counterPair.Lock()
+	counterPair.Request++
+	requestCounter := counterPair.Request
+	counterPair.Unlock()
+
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		tcpID.SrcIP,
+		tcpID.DstIP,
+		tcpID.SrcPort,
+		tcpID.DstPort,
+		requestCounter,
+	)
+
+	if request.Type == commands[comStmtPrepare] {
+		reqResMatcher.startPrepare()
+	}
+	item := reqResMatcher.registerRequest(ident, request, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, ident string, captureTime time.Time, emitter api.Emitter, response *MysqlResponse, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerResponse(ident, response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
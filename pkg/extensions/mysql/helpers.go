@@ -0,0 +1,197 @@
+package mysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    request["type"].(string),
+			Selector: `request.type`,
+		},
+		{
+			Name:     "Command",
+			Value:    request["command"].(string),
+			Selector: `request.command`,
+		},
+		{
+			Name:     "Statement ID",
+			Value:    request["statementId"].(float64),
+			Selector: `request.statementId`,
+		},
+		{
+			Name:     "User",
+			Value:    request["user"],
+			Selector: `request.user`,
+		},
+		{
+			Name:     "Database",
+			Value:    request["database"],
+			Selector: `request.database`,
+		},
+		{
+			Name:     "Auth Plugin",
+			Value:    request["authPlugin"],
+			Selector: `request.authPlugin`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if statement, ok := request["statement"].(string); ok && statement != "" {
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Statement",
+			MimeType: "application/sql",
+			Data:     statement,
+			Selector: `request.statement`,
+		})
+	}
+
+	if parameters, ok := request["parameters"].([]interface{}); ok && len(parameters) > 0 {
+		var table []api.TableData
+		for i, parameter := range parameters {
+			table = append(table, api.TableData{
+				Name:     fmt.Sprintf("?%d", i+1),
+				Value:    parameter,
+				Selector: fmt.Sprintf("request.parameters[%d]", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Parameters",
+			Data:  string(obj),
+		})
+	}
+
+	if attributes, ok := request["attributes"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Connection Attributes",
+			Data:  representMapAsTable(attributes, `request.attributes`),
+		})
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    response["type"].(string),
+			Selector: `response.type`,
+		},
+		{
+			Name:     "Affected Rows",
+			Value:    response["affectedRows"].(float64),
+			Selector: `response.affectedRows`,
+		},
+		{
+			Name:     "Last Insert ID",
+			Value:    response["lastInsertId"].(float64),
+			Selector: `response.lastInsertId`,
+		},
+		{
+			Name:     "Row Count",
+			Value:    response["rowCount"].(float64),
+			Selector: `response.rowCount`,
+		},
+		{
+			Name:     "Warnings",
+			Value:    response["warnings"].(float64),
+			Selector: `response.warnings`,
+		},
+		{
+			Name:     "Info",
+			Value:    response["info"].(string),
+			Selector: `response.info`,
+		},
+		{
+			Name:     "Server Version",
+			Value:    response["serverVersion"],
+			Selector: `response.serverVersion`,
+		},
+		{
+			Name:     "Connection ID",
+			Value:    response["connectionId"],
+			Selector: `response.connectionId`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if mysqlError, ok := response["error"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Error",
+			Data:  representMapAsTable(mysqlError, `response.error`),
+		})
+	}
+
+	if columns, ok := response["columns"].([]interface{}); ok && len(columns) > 0 {
+		var table []api.TableData
+		for i, column := range columns {
+			c := column.(map[string]interface{})
+			table = append(table, api.TableData{
+				Name:     c["name"].(string),
+				Value:    c["type"].(string),
+				Selector: fmt.Sprintf("response.columns[%d].name", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Columns",
+			Data:  string(obj),
+		})
+	}
+
+	if rows, ok := response["rows"].([]interface{}); ok && len(rows) > 0 {
+		obj, _ := json.Marshal(rows)
+		repResponse = append(repResponse, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Rows",
+			MimeType: "application/json",
+			Data:     string(obj),
+			Selector: `response.rows`,
+		})
+	}
+
+	return
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
@@ -0,0 +1,188 @@
+package mysql
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "mysql",
+	Version:         "10",
+	Abbreviation:    "MYSQL",
+	LongName:        "MySQL Client/Server Protocol",
+	Macro:           "mysql",
+	BackgroundColor: "#00758f",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://dev.mysql.com/doc/dev/mysql-server/latest/PAGE_PROTOCOL.html",
+	Ports:           []string{"3306"},
+	Layer4:          "tcp",
+	Priority:        6,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	r := NewMysqlReader(b)
+
+	if reader.GetIsClient() {
+		defer reqResMatcher.closeClient()
+		client := newClientState()
+		for {
+			p, err := r.readPacket()
+			if err != nil {
+				return err
+			}
+
+			captureTime := reader.GetCaptureTime()
+			request, err := client.handlePacket(p, reqResMatcher)
+			if err != nil {
+				return err
+			}
+			if request != nil {
+				reader.GetParent().SetProtocol(&protocol)
+				handleClientStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), captureTime, reader.GetEmitter(), request, reqResMatcher)
+			}
+			if client.compressed {
+				return errCompressed
+			}
+		}
+	}
+
+	server := newServerState()
+	for {
+		p, err := r.readPacket()
+		if err != nil {
+			return err
+		}
+
+		captureTime := reader.GetCaptureTime()
+		ident, response, err := server.handlePacket(r, p, reader.GetTcpID(), reader.GetCounterPair(), reqResMatcher)
+		if err != nil {
+			return err
+		}
+		reader.GetParent().SetProtocol(&protocol)
+		handleServerStream(reader.GetReadProgress(), reader.GetTcpID(), ident, captureTime, reader.GetEmitter(), response, reqResMatcher)
+		if server.compressed {
+			return errCompressed
+		}
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	status := 0
+	statusQuery := ""
+	if mysqlError, ok := entry.Response["error"].(map[string]interface{}); ok {
+		status = int(mysqlError["code"].(float64))
+		statusQuery = fmt.Sprintf(`response.error.code == %d`, status)
+	}
+
+	method := entry.Request["command"].(string)
+	methodQuery := fmt.Sprintf(`request.command == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	switch entry.Request["type"].(string) {
+	case typeHandshake, typeSSLRequest:
+		if user, ok := entry.Request["user"].(string); ok {
+			summary = user
+			summaryQuery = fmt.Sprintf(`request.user == "%s"`, summary)
+		}
+	default:
+		if statement, ok := entry.Request["statement"].(string); ok && statement != "" {
+			summary = statement
+			summaryQuery = fmt.Sprintf(`request.statement == %q`, summary)
+		} else if database, ok := entry.Request["database"].(string); ok {
+			summary = database
+			summaryQuery = fmt.Sprintf(`request.database == "%s"`, summary)
+		}
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       status,
+		StatusQuery:  statusQuery,
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representRequest(request)
+	repResponse := representResponse(response)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`mysql`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
@@ -0,0 +1,189 @@
+package mysql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "mysql", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"mysql": `protocol.name == "mysql"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func mysqlPacket(sequence byte, parts ...[]byte) []byte {
+	payload := bytes.Join(parts, nil)
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), sequence}
+	return append(header, payload...)
+}
+
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func lenenc(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func uint16Bytes(v uint16) []byte {
+	buf := make([]byte, 2)
+	binary.LittleEndian.PutUint16(buf, v)
+	return buf
+}
+
+func uint32Bytes(v uint32) []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, v)
+	return buf
+}
+
+func uint64Bytes(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return buf
+}
+
+func columnDefinition(sequence byte, table string, name string, fieldType byte) []byte {
+	return mysqlPacket(sequence, lenenc("def"), lenenc("shop"), lenenc(table), lenenc(table), lenenc(name), lenenc(name),
+		[]byte{0x0c}, uint16Bytes(33), uint32Bytes(11), []byte{fieldType}, uint16Bytes(0), []byte{0}, uint16Bytes(0))
+}
+
+func greeting() []byte {
+	capabilities := uint32(clientProtocol41 | clientSecureConnection | clientPluginAuth | clientConnectWithDB | clientDeprecateEOF)
+	return mysqlPacket(0,
+		[]byte{handshakeV10}, cstring("8.0.32"), uint32Bytes(17), []byte("abcdefgh"), []byte{0},
+		uint16Bytes(uint16(capabilities)), []byte{33}, uint16Bytes(2), uint16Bytes(uint16(capabilities>>16)), []byte{21},
+		make([]byte, 10), []byte("ijklmnopqrst\x00"), cstring("caching_sha2_password"))
+}
+
+func handshakeResponse() []byte {
+	capabilities := uint32(clientProtocol41 | clientSecureConnection | clientPluginAuth | clientConnectWithDB | clientDeprecateEOF | clientConnectAttrs)
+	return mysqlPacket(1,
+		uint32Bytes(capabilities), uint32Bytes(16777216), []byte{33}, make([]byte, 23),
+		cstring("app"), []byte{4}, []byte("hash"), cstring("shop"), cstring("caching_sha2_password"),
+		lenenc(string(bytes.Join([][]byte{lenenc("_client_name"), lenenc("libmysql")}, nil))))
+}
+
+func dissect(t *testing.T, client []byte, server []byte) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.Concurrently, client, server)
+}
+
+func TestDissect(t *testing.T) {
+	client := bytes.Join([][]byte{
+		handshakeResponse(),
+		mysqlPacket(0, []byte{comQuery}, []byte("SELECT id, name FROM products")),
+		mysqlPacket(0, []byte{comQuery}, []byte("UPDATE products SET price = 10 WHERE id < 4")),
+		mysqlPacket(0, []byte{comStmtPrepare}, []byte("SELECT id FROM products WHERE name = ? AND price > ?")),
+		mysqlPacket(0, []byte{comStmtExecute}, uint32Bytes(1), []byte{0}, uint32Bytes(1),
+			[]byte{0x00}, []byte{1}, []byte{typeVarString, 0}, []byte{typeLongLong, 0},
+			lenenc("apple"), uint64Bytes(5)),
+		mysqlPacket(0, []byte{comQuery}, []byte("INSERT INTO products VALUES (1)")),
+		mysqlPacket(0, []byte{comStmtClose}, uint32Bytes(1)),
+		mysqlPacket(0, []byte{comQuit}),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		greeting(),
+		mysqlPacket(2, []byte{authMoreDataPacket, 3}),
+		mysqlPacket(3, []byte{okPacket, 0, 0}, uint16Bytes(2), uint16Bytes(0)),
+
+		mysqlPacket(1, []byte{2}),
+		columnDefinition(2, "products", "id", typeLong),
+		columnDefinition(3, "products", "name", typeVarString),
+		mysqlPacket(4, lenenc("1"), lenenc("apple")),
+		mysqlPacket(5, lenenc("2"), []byte{nullColumn}),
+		mysqlPacket(6, []byte{eofPacket, 0, 0}, uint16Bytes(2), uint16Bytes(0)),
+
+		mysqlPacket(1, []byte{okPacket, 3, 0}, uint16Bytes(2), uint16Bytes(0), []byte("Rows matched: 3  Changed: 3  Warnings: 0")),
+
+		mysqlPacket(1, []byte{okPacket}, uint32Bytes(1), uint16Bytes(1), uint16Bytes(2), []byte{0}, uint16Bytes(0)),
+		columnDefinition(2, "", "?", typeVarString),
+		columnDefinition(3, "", "?", typeLongLong),
+		columnDefinition(4, "products", "id", typeLong),
+
+		mysqlPacket(1, []byte{1}),
+		columnDefinition(2, "products", "id", typeLong),
+		mysqlPacket(3, []byte{0x00, 0x00}, uint32Bytes(7)),
+		mysqlPacket(4, []byte{eofPacket, 0, 0}, uint16Bytes(2), uint16Bytes(0)),
+
+		mysqlPacket(1, []byte{errPacket}, uint16Bytes(1062), []byte("#23000"), []byte("Duplicate entry '1' for key 'PRIMARY'")),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	if !assert.Len(t, entries, 6) {
+		return
+	}
+
+	dissector := NewDissector()
+
+	assert.Equal(t, typeHandshake, entries[0].Request["type"])
+	assert.Equal(t, "app", entries[0].Request["user"])
+	assert.Equal(t, "shop", entries[0].Request["database"])
+	assert.Equal(t, "libmysql", entries[0].Request["attributes"].(map[string]interface{})["_client_name"])
+	assert.Equal(t, "8.0.32", entries[0].Response["serverVersion"])
+	assert.Nil(t, entries[0].Response["error"])
+
+	assert.Equal(t, "SELECT id, name FROM products", entries[1].Request["statement"])
+	assert.Equal(t, "SELECT", dissector.Summarize(entries[1]).Method)
+	assert.Equal(t, float64(2), entries[1].Response["rowCount"])
+	assert.Equal(t, "name", entries[1].Response["columns"].([]interface{})[1].(map[string]interface{})["name"])
+	assert.Equal(t, []interface{}{[]interface{}{"1", "apple"}, []interface{}{"2", nil}}, entries[1].Response["rows"])
+
+	assert.Equal(t, float64(3), entries[2].Response["affectedRows"])
+
+	assert.Equal(t, typePrepareOK, entries[3].Response["type"])
+	assert.Equal(t, float64(2), entries[3].Response["numParams"])
+
+	assert.Equal(t, "SELECT id FROM products WHERE name = ? AND price > ?", entries[4].Request["statement"])
+	assert.Equal(t, []interface{}{"apple", float64(5)}, entries[4].Request["parameters"])
+	assert.Equal(t, []interface{}{[]interface{}{float64(7)}}, entries[4].Response["rows"])
+
+	assert.Equal(t, float64(1062), entries[5].Response["error"].(map[string]interface{})["code"])
+	summary := dissector.Summarize(entries[5])
+	assert.Equal(t, 1062, summary.Status)
+	assert.Equal(t, `response.error.code == 1062`, summary.StatusQuery)
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+	reqResMatcher.SetMaxTry(10)
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMatcherWaitsEndEarly(t *testing.T) {
+	reqResMatcher := createResponseRequestMatcher().(*requestResponseMatcher)
+	start := time.Now()
+
+	// No COM_STMT_PREPARE is waiting for its response
+	assert.Nil(t, reqResMatcher.waitForStatement(1))
+
+	// The client side won't read the request anymore
+	reqResMatcher.closeClient()
+	assert.Nil(t, reqResMatcher.waitForRequest("1_2_1_2_1"))
+
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+type preparedStatement struct {
+	Query     string
+	NumParams int
+}
+
+// Key is `{src_ip}_{dst_ip}_{src_port}_{dst_port}_{incremental_counter}`
+// The server replies to the commands in order, so the n-th command of the
+// client is answered by the n-th response of the server. Since the layout
+// of a response depends on its command, the server side waits for the
+// request before reading the response.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+	statements      *sync.Map
+	maxTry          int
+
+	// The waits end early once nothing can come anymore: the client side is
+	// done, or no COM_STMT_PREPARE is waiting for its response
+	clientClosed    int32
+	pendingPrepares int32
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}, statements: &sync.Map{}, maxTry: 3000}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+	matcher.maxTry = value
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *MysqlRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestMysqlMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MysqlPayload{
+			Data: &MysqlWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseMysqlMessage := response.(*api.GenericMessage)
+		if responseMysqlMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestMysqlMessage, responseMysqlMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestMysqlMessage)
+	return nil
+}
+
+// closeClient tells the server side that the client side won't read any
+// more requests, as it reached the end of the connection or isn't MySQL.
+func (matcher *requestResponseMatcher) closeClient() {
+	atomic.StoreInt32(&matcher.clientClosed, 1)
+}
+
+// waitForRequest returns the request that's registered under the ident,
+// without removing it, once the client side has read it.
+func (matcher *requestResponseMatcher) waitForRequest(ident string) *MysqlRequest {
+	try := 0
+	for {
+		try++
+		if try > matcher.maxTry {
+			return nil
+		}
+		// Checked ahead of the lookup, since the requests are registered before
+		closed := atomic.LoadInt32(&matcher.clientClosed) != 0
+		if message, found := matcher.openMessagesMap.Load(ident); found {
+			requestMysqlMessage := message.(*api.GenericMessage)
+			if requestMysqlMessage.IsRequest {
+				return requestMysqlMessage.Payload.(MysqlPayload).Data.(*MysqlWrapper).Details.(*MysqlRequest)
+			}
+		}
+		if closed {
+			return nil
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *MysqlResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseMysqlMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: MysqlPayload{
+			Data: &MysqlWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestMysqlMessage := request.(*api.GenericMessage)
+		if !requestMysqlMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestMysqlMessage, &responseMysqlMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseMysqlMessage)
+	return nil
+}
+
+// startPrepare counts a COM_STMT_PREPARE that the server side is yet to read
+// the response of.
+func (matcher *requestResponseMatcher) startPrepare() {
+	atomic.AddInt32(&matcher.pendingPrepares, 1)
+}
+
+// endPrepare counts the response to a COM_STMT_PREPARE, after its statement
+// is registered if it was prepared.
+func (matcher *requestResponseMatcher) endPrepare() {
+	atomic.AddInt32(&matcher.pendingPrepares, -1)
+}
+
+func (matcher *requestResponseMatcher) registerStatement(id uint32, statement *preparedStatement) {
+	matcher.statements.Store(id, statement)
+}
+
+func (matcher *requestResponseMatcher) deleteStatement(id uint32) {
+	matcher.statements.Delete(id)
+}
+
+// waitForStatement returns the prepared statement with the given id once
+// the server side has read the response to its COM_STMT_PREPARE.
+func (matcher *requestResponseMatcher) waitForStatement(id uint32) *preparedStatement {
+	try := 0
+	for {
+		try++
+		if try > matcher.maxTry {
+			return nil
+		}
+		// A statement that was prepared before the capture never comes
+		pending := atomic.LoadInt32(&matcher.pendingPrepares) != 0
+		if statement, found := matcher.statements.Load(id); found {
+			return statement.(*preparedStatement)
+		}
+		if !pending {
+			return nil
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestMysqlMessage *api.GenericMessage, responseMysqlMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestMysqlMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestMysqlMessage,
+			Response: *responseMysqlMessage,
+		},
+	}
+}
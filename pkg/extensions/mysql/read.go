@@ -0,0 +1,506 @@
+package mysql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+const (
+	packetHeaderLength    = 4
+	maxPacketLength       = 0xffffff
+	maxPayloadLength      = 64 * 1024 * 1024
+	handshakeV10          = 0x0a
+	handshakeResponseSize = 32
+	maxRowsInResponse     = 10
+	maxValueLengthInRows  = 256
+)
+
+// Capability flags, see https://dev.mysql.com/doc/dev/mysql-server/latest/group__group__cs__capabilities__flags.html
+const (
+	clientConnectWithDB               = 0x00000008
+	clientCompress                    = 0x00000020
+	clientProtocol41                  = 0x00000200
+	clientSSL                         = 0x00000800
+	clientSecureConnection            = 0x00008000
+	clientPluginAuth                  = 0x00080000
+	clientConnectAttrs                = 0x00100000
+	clientPluginAuthLenencClientData  = 0x00200000
+	clientSessionTrack                = 0x00800000
+	clientDeprecateEOF                = 0x01000000
+	clientQueryAttributes             = 0x08000000
+	serverMoreResultsExists           = 0x0008
+	serverStatusCursorExists          = 0x0040
+	parameterCountAvailable           = 0x08
+	unsignedFlag                      = 0x0020
+	paramUnsignedFlag                 = 0x80
+	nullColumn                        = 0xfb
+	okPacket                          = 0x00
+	authMoreDataPacket                = 0x01
+	localInfilePacket                 = 0xfb
+	eofPacket                         = 0xfe
+	errPacket                         = 0xff
+	eofPacketLength                   = 5
+	prepareOKLength                   = 12
+	binaryRowNullBitmapOffset         = 2
+	executeParametersNullBitmapOffset = 0
+)
+
+const (
+	comSleep            = 0x00
+	comQuit             = 0x01
+	comInitDB           = 0x02
+	comQuery            = 0x03
+	comFieldList        = 0x04
+	comCreateDB         = 0x05
+	comDropDB           = 0x06
+	comRefresh          = 0x07
+	comShutdown         = 0x08
+	comStatistics       = 0x09
+	comProcessInfo      = 0x0a
+	comConnect          = 0x0b
+	comProcessKill      = 0x0c
+	comDebug            = 0x0d
+	comPing             = 0x0e
+	comTime             = 0x0f
+	comDelayedInsert    = 0x10
+	comChangeUser       = 0x11
+	comBinlogDump       = 0x12
+	comTableDump        = 0x13
+	comConnectOut       = 0x14
+	comRegisterSlave    = 0x15
+	comStmtPrepare      = 0x16
+	comStmtExecute      = 0x17
+	comStmtSendLongData = 0x18
+	comStmtClose        = 0x19
+	comStmtReset        = 0x1a
+	comSetOption        = 0x1b
+	comStmtFetch        = 0x1c
+	comDaemon           = 0x1d
+	comBinlogDumpGtid   = 0x1e
+	comResetConnection  = 0x1f
+)
+
+var commands = map[byte]string{
+	comSleep:            "COM_SLEEP",
+	comQuit:             "COM_QUIT",
+	comInitDB:           "COM_INIT_DB",
+	comQuery:            "COM_QUERY",
+	comFieldList:        "COM_FIELD_LIST",
+	comCreateDB:         "COM_CREATE_DB",
+	comDropDB:           "COM_DROP_DB",
+	comRefresh:          "COM_REFRESH",
+	comShutdown:         "COM_SHUTDOWN",
+	comStatistics:       "COM_STATISTICS",
+	comProcessInfo:      "COM_PROCESS_INFO",
+	comConnect:          "COM_CONNECT",
+	comProcessKill:      "COM_PROCESS_KILL",
+	comDebug:            "COM_DEBUG",
+	comPing:             "COM_PING",
+	comTime:             "COM_TIME",
+	comDelayedInsert:    "COM_DELAYED_INSERT",
+	comChangeUser:       "COM_CHANGE_USER",
+	comBinlogDump:       "COM_BINLOG_DUMP",
+	comTableDump:        "COM_TABLE_DUMP",
+	comConnectOut:       "COM_CONNECT_OUT",
+	comRegisterSlave:    "COM_REGISTER_SLAVE",
+	comStmtPrepare:      "COM_STMT_PREPARE",
+	comStmtExecute:      "COM_STMT_EXECUTE",
+	comStmtSendLongData: "COM_STMT_SEND_LONG_DATA",
+	comStmtClose:        "COM_STMT_CLOSE",
+	comStmtReset:        "COM_STMT_RESET",
+	comSetOption:        "COM_SET_OPTION",
+	comStmtFetch:        "COM_STMT_FETCH",
+	comDaemon:           "COM_DAEMON",
+	comBinlogDumpGtid:   "COM_BINLOG_DUMP_GTID",
+	comResetConnection:  "COM_RESET_CONNECTION",
+}
+
+const (
+	typeDecimal    = 0x00
+	typeTiny       = 0x01
+	typeShort      = 0x02
+	typeLong       = 0x03
+	typeFloat      = 0x04
+	typeDouble     = 0x05
+	typeNull       = 0x06
+	typeTimestamp  = 0x07
+	typeLongLong   = 0x08
+	typeInt24      = 0x09
+	typeDate       = 0x0a
+	typeTime       = 0x0b
+	typeDateTime   = 0x0c
+	typeYear       = 0x0d
+	typeNewDate    = 0x0e
+	typeVarchar    = 0x0f
+	typeBit        = 0x10
+	typeJSON       = 0xf5
+	typeNewDecimal = 0xf6
+	typeEnum       = 0xf7
+	typeSet        = 0xf8
+	typeTinyBlob   = 0xf9
+	typeMediumBlob = 0xfa
+	typeLongBlob   = 0xfb
+	typeBlob       = 0xfc
+	typeVarString  = 0xfd
+	typeString     = 0xfe
+	typeGeometry   = 0xff
+)
+
+var columnTypes = map[byte]string{
+	typeDecimal:    "DECIMAL",
+	typeTiny:       "TINY",
+	typeShort:      "SHORT",
+	typeLong:       "LONG",
+	typeFloat:      "FLOAT",
+	typeDouble:     "DOUBLE",
+	typeNull:       "NULL",
+	typeTimestamp:  "TIMESTAMP",
+	typeLongLong:   "LONGLONG",
+	typeInt24:      "INT24",
+	typeDate:       "DATE",
+	typeTime:       "TIME",
+	typeDateTime:   "DATETIME",
+	typeYear:       "YEAR",
+	typeNewDate:    "NEWDATE",
+	typeVarchar:    "VARCHAR",
+	typeBit:        "BIT",
+	typeJSON:       "JSON",
+	typeNewDecimal: "NEWDECIMAL",
+	typeEnum:       "ENUM",
+	typeSet:        "SET",
+	typeTinyBlob:   "TINY_BLOB",
+	typeMediumBlob: "MEDIUM_BLOB",
+	typeLongBlob:   "LONG_BLOB",
+	typeBlob:       "BLOB",
+	typeVarString:  "VAR_STRING",
+	typeString:     "STRING",
+	typeGeometry:   "GEOMETRY",
+}
+
+// packet is a logical MySQL packet. Payloads that were split into several
+// 16MB wire packets are joined back together.
+type packet struct {
+	Sequence byte
+	Payload  []byte
+}
+
+type MysqlReader struct {
+	*bufio.Reader
+	pending *packet
+}
+
+func NewMysqlReader(r *bufio.Reader) *MysqlReader {
+	return &MysqlReader{Reader: r}
+}
+
+func (r *MysqlReader) readPacket() (*packet, error) {
+	if r.pending != nil {
+		p := r.pending
+		r.pending = nil
+		return p, nil
+	}
+
+	header := make([]byte, packetHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := readUint24(header)
+	p := &packet{Sequence: header[3]}
+	p.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, p.Payload); err != nil {
+		return nil, err
+	}
+
+	for length == maxPacketLength {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		length = readUint24(header)
+		if len(p.Payload)+length > maxPayloadLength {
+			return nil, fmt.Errorf("packet payload exceeds %d bytes", maxPayloadLength)
+		}
+		chunk := make([]byte, length)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		p.Payload = append(p.Payload, chunk...)
+	}
+
+	return p, nil
+}
+
+// unreadPacket returns a packet to the reader so that the next readPacket
+// call yields it again.
+func (r *MysqlReader) unreadPacket(p *packet) {
+	r.pending = p
+}
+
+func readUint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func isEOFPacket(p *packet) bool {
+	return len(p.Payload) == eofPacketLength && p.Payload[0] == eofPacket
+}
+
+// isResultSetTerminator reports whether the packet ends the rows of a result
+// set. Depending on CLIENT_DEPRECATE_EOF it's either an EOF packet or an OK
+// packet with the 0xfe header.
+func isResultSetTerminator(p *packet) bool {
+	return len(p.Payload) > 0 && len(p.Payload) < maxPacketLength && p.Payload[0] == eofPacket
+}
+
+// buffer is a cursor over a packet payload.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+var errShortPacket = errors.New("packet is shorter than its declared fields")
+
+func (b *buffer) readByte() byte {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 1 {
+		b.err = errShortPacket
+		return 0
+	}
+	v := b.data[0]
+	b.data = b.data[1:]
+	return v
+}
+
+func (b *buffer) readUint16() uint16 {
+	v := b.readBytes(2)
+	if v == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(v)
+}
+
+func (b *buffer) readUint32() uint32 {
+	v := b.readBytes(4)
+	if v == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(v)
+}
+
+func (b *buffer) readUint64() uint64 {
+	v := b.readBytes(8)
+	if v == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(v)
+}
+
+// readLengthEncodedInteger reads an integer in the protocol's variable
+// length format. isNull is set when the 0xfb marker is used in its place.
+func (b *buffer) readLengthEncodedInteger() (v uint64, isNull bool) {
+	first := b.readByte()
+	switch first {
+	case nullColumn:
+		return 0, true
+	case 0xfc:
+		return uint64(b.readUint16()), false
+	case 0xfd:
+		v := b.readBytes(3)
+		if v == nil {
+			return 0, false
+		}
+		return uint64(readUint24(v)), false
+	case 0xfe:
+		return b.readUint64(), false
+	case 0xff:
+		if b.err == nil {
+			b.err = errors.New("invalid length-encoded integer")
+		}
+		return 0, false
+	default:
+		return uint64(first), false
+	}
+}
+
+func (b *buffer) readLengthEncodedString() (v []byte, isNull bool) {
+	length, isNull := b.readLengthEncodedInteger()
+	if isNull || b.err != nil {
+		return nil, isNull
+	}
+	if length > uint64(len(b.data)) {
+		b.err = errShortPacket
+		return nil, false
+	}
+	return b.readBytes(int(length)), false
+}
+
+func (b *buffer) readNullTerminatedString() string {
+	if b.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(b.data, 0)
+	if i < 0 {
+		b.err = errors.New("string is not null-terminated")
+		return ""
+	}
+	v := string(b.data[:i])
+	b.data = b.data[i+1:]
+	return v
+}
+
+func (b *buffer) readBytes(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || len(b.data) < n {
+		b.err = errShortPacket
+		return nil
+	}
+	v := b.data[:n]
+	b.data = b.data[n:]
+	return v
+}
+
+func (b *buffer) readRest() []byte {
+	if b.err != nil {
+		return nil
+	}
+	v := b.data
+	b.data = nil
+	return v
+}
+
+// readBinaryValue decodes a value of the binary protocol that is used by
+// prepared statement parameters and rows.
+func (b *buffer) readBinaryValue(fieldType byte, unsigned bool) interface{} {
+	switch fieldType {
+	case typeNull:
+		return nil
+	case typeTiny:
+		v := b.readByte()
+		if unsigned {
+			return uint64(v)
+		}
+		return int64(int8(v))
+	case typeShort, typeYear:
+		v := b.readUint16()
+		if unsigned {
+			return uint64(v)
+		}
+		return int64(int16(v))
+	case typeLong, typeInt24:
+		v := b.readUint32()
+		if unsigned {
+			return uint64(v)
+		}
+		return int64(int32(v))
+	case typeLongLong:
+		v := b.readUint64()
+		if unsigned {
+			return v
+		}
+		return int64(v)
+	case typeFloat:
+		return float64(math.Float32frombits(b.readUint32()))
+	case typeDouble:
+		return math.Float64frombits(b.readUint64())
+	case typeDate, typeDateTime, typeTimestamp, typeNewDate:
+		return b.readBinaryDateTime(fieldType)
+	case typeTime:
+		return b.readBinaryTime()
+	default:
+		v, isNull := b.readLengthEncodedString()
+		if isNull {
+			return nil
+		}
+		return formatValue(v)
+	}
+}
+
+func (b *buffer) readBinaryDateTime(fieldType byte) string {
+	length := b.readByte()
+	var year uint16
+	var month, day, hour, minute, second byte
+	var microsecond uint32
+	if length >= 4 {
+		year = b.readUint16()
+		month = b.readByte()
+		day = b.readByte()
+	}
+	if length >= 7 {
+		hour = b.readByte()
+		minute = b.readByte()
+		second = b.readByte()
+	}
+	if length >= 11 {
+		microsecond = b.readUint32()
+	}
+
+	if fieldType == typeDate || fieldType == typeNewDate {
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	}
+	if microsecond > 0 {
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%06d", year, month, day, hour, minute, second, microsecond)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+}
+
+func (b *buffer) readBinaryTime() string {
+	length := b.readByte()
+	var negative byte
+	var days uint32
+	var hour, minute, second byte
+	var microsecond uint32
+	if length >= 8 {
+		negative = b.readByte()
+		days = b.readUint32()
+		hour = b.readByte()
+		minute = b.readByte()
+		second = b.readByte()
+	}
+	if length >= 12 {
+		microsecond = b.readUint32()
+	}
+
+	sign := ""
+	if negative == 1 {
+		sign = "-"
+	}
+	hours := days*24 + uint32(hour)
+	if microsecond > 0 {
+		return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, hours, minute, second, microsecond)
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, minute, second)
+}
+
+// formatValue renders a column or parameter value. Text values are kept as
+// they are while binary ones are shown in hex.
+func formatValue(value []byte) string {
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	return fmt.Sprintf("0x%s", hex.EncodeToString(value))
+}
+
+func truncateValue(value interface{}) interface{} {
+	if s, ok := value.(string); ok && len(s) > maxValueLengthInRows {
+		return s[:maxValueLengthInRows] + "..."
+	}
+	return value
+}
+
+func isNullInBitmap(bitmap []byte, i int, offset int) bool {
+	pos := i + offset
+	if pos/8 >= len(bitmap) {
+		return false
+	}
+	return bitmap[pos/8]&(1<<(uint(pos)%8)) != 0
+}
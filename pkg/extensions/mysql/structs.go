@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type MysqlPayload struct {
+	Data interface{}
+}
+
+type MysqlPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h MysqlPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type MysqlWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+const (
+	typeHandshake  = "Handshake"
+	typeSSLRequest = "SSLRequest"
+	typeOK         = "OK"
+	typeError      = "ERR"
+	typeEOF        = "EOF"
+	typeResultSet  = "ResultSet"
+	typePrepareOK  = "PrepareOK"
+	typeStatistics = "Statistics"
+	typeFieldList  = "FieldList"
+)
+
+type MysqlRequest struct {
+	Type         string            `json:"type"`
+	Command      string            `json:"command"`
+	Statement    string            `json:"statement"`
+	StatementID  uint32            `json:"statementId"`
+	Parameters   []interface{}     `json:"parameters"`
+	Sequence     byte              `json:"sequence"`
+	User         string            `json:"user,omitempty"`
+	Database     string            `json:"database,omitempty"`
+	AuthPlugin   string            `json:"authPlugin,omitempty"`
+	Capabilities uint32            `json:"capabilities,omitempty"`
+	CharacterSet byte              `json:"characterSet,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+type MysqlColumn struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Length uint32 `json:"length"`
+	Flags  uint16 `json:"flags"`
+
+	fieldType byte
+}
+
+type MysqlError struct {
+	Code     uint16 `json:"code"`
+	SQLState string `json:"sqlState"`
+	Message  string `json:"message"`
+}
+
+type MysqlResponse struct {
+	Type          string          `json:"type"`
+	AffectedRows  uint64          `json:"affectedRows"`
+	LastInsertID  uint64          `json:"lastInsertId"`
+	StatusFlags   uint16          `json:"statusFlags"`
+	Warnings      uint16          `json:"warnings"`
+	Info          string          `json:"info"`
+	Columns       []MysqlColumn   `json:"columns"`
+	Rows          [][]interface{} `json:"rows"`
+	RowCount      int64           `json:"rowCount"`
+	Error         *MysqlError     `json:"error,omitempty"`
+	StatementID   uint32          `json:"statementId,omitempty"`
+	NumParams     uint16          `json:"numParams,omitempty"`
+	ServerVersion string          `json:"serverVersion,omitempty"`
+	ConnectionID  uint32          `json:"connectionId,omitempty"`
+	AuthPlugin    string          `json:"authPlugin,omitempty"`
+	Capabilities  uint32          `json:"capabilities,omitempty"`
+}
+
+// queryCommand returns the upper-cased leading keyword of a statement.
+func queryCommand(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimRight(fields[0], ";("))
+}
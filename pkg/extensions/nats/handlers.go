@@ -0,0 +1,220 @@
+package nats
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	inboxPrefix         = "_INBOX."
+	jetStreamPrefix     = "$JS."
+	jetStreamApiPrefix  = "$JS.API."
+	jetStreamApiSegment = ".API."
+	jetStreamApiType    = "io.nats.jetstream.api."
+	headerVersion       = "NATS/1.0"
+)
+
+// The CONNECT options that hold credentials
+var secretOptions = []string{"pass", "auth_token", "jwt", "sig"}
+
+// connectionState keeps track of one side of a connection, which starts
+// with INFO from the server and CONNECT from the client.
+type connectionState struct {
+	isClient  bool
+	connected bool
+}
+
+// handleOperation turns an operation into the message that is shown. It's
+// nil for the operations that aren't shown, like the keep alive pings.
+func (s *connectionState) handleOperation(op *operation) (*NatsMessage, error) {
+	if !s.connected {
+		if s.isClient && op.Name != opConnect {
+			return nil, errors.New("connection doesn't start with CONNECT")
+		}
+		if !s.isClient && op.Name != opInfo {
+			return nil, errors.New("connection doesn't start with INFO")
+		}
+	}
+
+	msg := &NatsMessage{Op: op.Name}
+	switch op.Name {
+	case opInfo, opConnect:
+		if s.connected {
+			// The server updates the cluster topology with INFO
+			return nil, nil
+		}
+		s.connected = true
+		if err := json.Unmarshal([]byte(op.Arguments), &msg.Options); err != nil {
+			return nil, fmt.Errorf("malformed %s options: %v", op.Name, err)
+		}
+		for _, option := range secretOptions {
+			delete(msg.Options, option)
+		}
+	case opSub, opUnsub:
+		msg.Subject = op.Subject
+		msg.Sid = op.Sid
+		msg.QueueGroup = op.QueueGroup
+		msg.MaxMsgs = op.MaxMsgs
+	case opErr:
+		msg.Error = op.Arguments
+	case opPub, opHpub, opMsg, opHmsg:
+		msg.Subject = op.Subject
+		msg.ReplyTo = op.ReplyTo
+		msg.Sid = op.Sid
+		if op.Name == opHpub || op.Name == opHmsg {
+			if err := msg.readHeaders(op.Headers); err != nil {
+				return nil, err
+			}
+		}
+		msg.Payload = base64.StdEncoding.EncodeToString(op.Payload)
+		if utf8.Valid(op.Payload) {
+			msg.PayloadText = string(op.Payload)
+		}
+		msg.PayloadSize = len(op.Payload)
+		msg.readJetStream(op.Payload)
+	default:
+		return nil, nil
+	}
+
+	return msg, nil
+}
+
+// readHeaders reads the header block of HPUB and HMSG, which starts with a
+// version line that may carry a status like 503 for no responders.
+func (msg *NatsMessage) readHeaders(data []byte) error {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\r\n\r\n"), "\r\n")
+	if !strings.HasPrefix(lines[0], headerVersion) {
+		return fmt.Errorf("unknown header version: %q", lines[0])
+	}
+
+	status := strings.Fields(strings.TrimPrefix(lines[0], headerVersion))
+	if len(status) > 0 {
+		code, err := strconv.Atoi(status[0])
+		if err != nil {
+			return fmt.Errorf("malformed header status: %q", lines[0])
+		}
+		msg.Status = code
+		msg.Description = strings.Join(status[1:], " ")
+	}
+
+	for _, line := range lines[1:] {
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return fmt.Errorf("malformed header: %q", line)
+		}
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]string)
+		}
+		name := line[:i]
+		value := strings.TrimSpace(line[i+1:])
+		if previous, ok := msg.Headers[name]; ok {
+			value = previous + ", " + value
+		}
+		msg.Headers[name] = value
+	}
+	return nil
+}
+
+// readJetStream flags the calls to the JetStream API, with or without a
+// domain, and the errors that the API replies with.
+func (msg *NatsMessage) readJetStream(payload []byte) {
+	if strings.HasPrefix(msg.Subject, jetStreamApiPrefix) {
+		msg.JetStream = true
+		msg.JetStreamApi = strings.TrimPrefix(msg.Subject, jetStreamApiPrefix)
+	} else if strings.HasPrefix(msg.Subject, jetStreamPrefix) {
+		if i := strings.Index(msg.Subject, jetStreamApiSegment); i >= 0 {
+			msg.JetStream = true
+			msg.JetStreamApi = msg.Subject[i+len(jetStreamApiSegment):]
+		}
+	}
+
+	if !bytes.HasPrefix(payload, []byte("{")) {
+		return
+	}
+	var reply struct {
+		Type  string `json:"type"`
+		Error *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil {
+		return
+	}
+	if strings.HasPrefix(reply.Type, jetStreamApiType) && reply.Error != nil {
+		msg.Status = reply.Error.Code
+		msg.Error = reply.Error.Description
+	}
+}
+
+// identity returns the key that a request and its reply have in common,
+// the reply subject. The requests are keyed by their sender and the replies
+// by their receiver, since messages are delivered both ways.
+func identity(tcpID *api.TcpID, isRequest bool, subject string) string {
+	if isRequest {
+		return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, subject)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, subject)
+}
+
+func handleMessage(reader api.TcpReader, msg *NatsMessage, reqResMatcher *requestResponseMatcher) {
+	tcpID := reader.GetTcpID()
+	captureTime := reader.GetCaptureTime()
+	captureSize := reader.GetReadProgress().Current()
+
+	switch msg.Op {
+	case opConnect:
+		emit(reader, reqResMatcher.registerRequest(identity(tcpID, true, opConnect), msg, captureTime, captureSize), true)
+	case opInfo:
+		emit(reader, reqResMatcher.registerResponse(identity(tcpID, false, opConnect), msg, captureTime, captureSize, true), false)
+	case opPub, opHpub, opMsg, opHmsg:
+		if msg.ReplyTo != "" {
+			emit(reader, reqResMatcher.registerRequest(identity(tcpID, true, msg.ReplyTo), msg, captureTime, captureSize), true)
+			return
+		}
+		// Replies to inboxes wait for their request, other messages are
+		// only replies if their request was already seen
+		wait := strings.HasPrefix(msg.Subject, inboxPrefix)
+		if item := reqResMatcher.registerResponse(identity(tcpID, false, msg.Subject), msg, captureTime, captureSize, wait); item != nil || wait {
+			emit(reader, item, false)
+			return
+		}
+		emit(reader, reqResMatcher.prepareUnanswered(msg, captureTime, captureSize), true)
+	default:
+		emit(reader, reqResMatcher.prepareUnanswered(msg, captureTime, captureSize), true)
+	}
+}
+
+func emit(reader api.TcpReader, item *api.OutputChannelItem, isRequest bool) {
+	if item == nil {
+		return
+	}
+
+	tcpID := reader.GetTcpID()
+	if isRequest {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: reader.GetIsClient(),
+		}
+	} else {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: !reader.GetIsClient(),
+		}
+	}
+	reader.GetEmitter().Emit(item)
+}
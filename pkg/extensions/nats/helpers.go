@@ -0,0 +1,174 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Operation",
+			Value:    request["op"].(string),
+			Selector: `request.op`,
+		},
+		{
+			Name:     "Subject",
+			Value:    request["subject"].(string),
+			Selector: `request.subject`,
+		},
+		{
+			Name:     "Reply To",
+			Value:    request["replyTo"],
+			Selector: `request.replyTo`,
+		},
+		{
+			Name:     "Subscription ID",
+			Value:    request["sid"],
+			Selector: `request.sid`,
+		},
+		{
+			Name:     "Queue Group",
+			Value:    request["queueGroup"],
+			Selector: `request.queueGroup`,
+		},
+		{
+			Name:     "Max Messages",
+			Value:    request["maxMsgs"],
+			Selector: `request.maxMsgs`,
+		},
+		{
+			Name:     "JetStream",
+			Value:    request["jetStream"].(bool),
+			Selector: `request.jetStream`,
+		},
+		{
+			Name:     "JetStream API",
+			Value:    request["jetStreamApi"],
+			Selector: `request.jetStreamApi`,
+		},
+		{
+			Name:     "Error",
+			Value:    request["error"],
+			Selector: `request.error`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	repRequest = append(repRequest, representMessage(request, "request")...)
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	if response["op"].(string) == "" {
+		// The message isn't answered
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Operation",
+			Value:    response["op"].(string),
+			Selector: `response.op`,
+		},
+		{
+			Name:     "Subject",
+			Value:    response["subject"].(string),
+			Selector: `response.subject`,
+		},
+		{
+			Name:     "Subscription ID",
+			Value:    response["sid"],
+			Selector: `response.sid`,
+		},
+		{
+			Name:     "Status",
+			Value:    response["status"],
+			Selector: `response.status`,
+		},
+		{
+			Name:     "Description",
+			Value:    response["description"],
+			Selector: `response.description`,
+		},
+		{
+			Name:     "Error",
+			Value:    response["error"],
+			Selector: `response.error`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	repResponse = append(repResponse, representMessage(response, "response")...)
+
+	return
+}
+
+// representMessage shows the headers and the payload of a message, or the
+// options of CONNECT and INFO.
+func representMessage(msg map[string]interface{}, selectorPrefix string) (sections []interface{}) {
+	headers, _ := msg["headers"].(map[string]interface{})
+	if headers != nil {
+		sections = append(sections, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Headers",
+			Data:  representMapAsTable(headers, fmt.Sprintf("%s.headers", selectorPrefix)),
+		})
+	}
+
+	if payload, ok := msg["payload"].(string); ok && payload != "" {
+		mimeType, _ := headers["Content-Type"].(string)
+		sections = append(sections, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Payload",
+			Encoding: "base64",
+			MimeType: mimeType,
+			Data:     payload,
+			Selector: fmt.Sprintf("%s.payload", selectorPrefix),
+		})
+	}
+
+	if options, ok := msg["options"].(map[string]interface{}); ok {
+		sections = append(sections, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Options",
+			Data:  representMapAsTable(options, fmt.Sprintf("%s.options", selectorPrefix)),
+		})
+	}
+
+	return
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf(`%s["%s"]`, selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
@@ -0,0 +1,162 @@
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "nats",
+	Version:         "1",
+	Abbreviation:    "NATS",
+	LongName:        "NATS Client Protocol",
+	Macro:           "nats",
+	BackgroundColor: "#27aae1",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://docs.nats.io/reference/reference-protocols/nats-protocol",
+	Ports:           []string{"4222"},
+	Layer4:          "tcp",
+	Priority:        10,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	state := &connectionState{isClient: reader.GetIsClient()}
+	for {
+		op, err := readOperation(b, reader.GetIsClient())
+		if err != nil {
+			return err
+		}
+
+		msg, err := state.handleOperation(op)
+		if err != nil {
+			return err
+		}
+		reader.GetParent().SetProtocol(&protocol)
+
+		if msg != nil {
+			handleMessage(reader, msg, reqResMatcher)
+		}
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	status := 0
+	statusQuery := ""
+	if code, ok := entry.Response["status"].(float64); ok {
+		status = int(code)
+		statusQuery = fmt.Sprintf(`response.status == %d`, status)
+	}
+
+	method := entry.Request["op"].(string)
+	methodQuery := fmt.Sprintf(`request.op == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	switch method {
+	case opConnect:
+		if name, ok := entry.Request["options"].(map[string]interface{})["name"].(string); ok {
+			summary = name
+			summaryQuery = fmt.Sprintf(`request.options.name == %q`, summary)
+		}
+	case opUnsub:
+		summary = entry.Request["sid"].(string)
+		summaryQuery = fmt.Sprintf(`request.sid == %q`, summary)
+	case opErr:
+		summary = entry.Request["error"].(string)
+		summaryQuery = fmt.Sprintf(`request.error == %q`, summary)
+	default:
+		summary = entry.Request["subject"].(string)
+		summaryQuery = fmt.Sprintf(`request.subject == %q`, summary)
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       status,
+		StatusQuery:  statusQuery,
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representRequest(request)
+	repResponse := representResponse(response)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`nats`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
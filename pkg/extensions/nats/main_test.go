@@ -0,0 +1,140 @@
+package nats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "nats", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"nats": `protocol.name == "nats"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func message(control string, payload string) string {
+	return fmt.Sprintf("%s %d\r\n%s\r\n", control, len(payload), payload)
+}
+
+func headerMessage(control string, headers string, payload string) string {
+	return fmt.Sprintf("%s %d %d\r\n%s%s\r\n", control, len(headers), len(headers)+len(payload), headers, payload)
+}
+
+func dissect(t *testing.T, client string, server string) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, []byte(client), []byte(server))
+}
+
+func TestDissect(t *testing.T) {
+	client := strings.Join([]string{
+		`CONNECT {"verbose":true,"name":"orders-svc","user":"app","pass":"hunter2","headers":true,"protocol":1}` + "\r\n",
+		"SUB orders.* workers 1\r\n",
+		message("PUB orders.new", "hello"),
+		message("pub svc.price _INBOX.abc.1", "foo"),
+		headerMessage("HPUB $JS.API.STREAM.CREATE.ORDERS _INBOX.abc.2", "NATS/1.0\r\nNats-Msg-Id: 42\r\n\r\n", `{"name":"ORDERS"}`),
+		message("PUB svc.none _INBOX.abc.3", ""),
+		message("PUB _INBOX.xyz.9", "ok"),
+		"UNSUB 1 10\r\n",
+		"PING\r\n",
+	}, "")
+
+	server := strings.Join([]string{
+		`INFO {"server_id":"NA1","version":"2.10.4","headers":true,"jetstream":true,"max_payload":1048576}` + "\r\n",
+		"+OK\r\n",
+		message("MSG _INBOX.abc.1 1", "9.99"),
+		message("MSG _INBOX.abc.2 1", `{"type":"io.nats.jetstream.api.v1.stream_create_response","error":{"code":400,"description":"insufficient resources"}}`),
+		headerMessage("HMSG _INBOX.abc.3 1", "NATS/1.0 503\r\n\r\n", ""),
+		message("MSG job.run 1 _INBOX.xyz.9", "work"),
+		"-ERR 'Permissions Violation for Publish to \"forbidden\"'\r\n",
+		"PONG\r\n",
+	}, "")
+
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissect(t, client, server) {
+		entries[entry.Request["op"].(string)+" "+entry.Request["subject"].(string)] = entry
+	}
+	assert.Len(t, entries, 9)
+
+	data, err := json.Marshal(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+
+	dissector := NewDissector()
+	connect := entries["CONNECT "]
+	assert.Equal(t, "app", connect.Request["options"].(map[string]interface{})["user"])
+	assert.Equal(t, "INFO", connect.Response["op"])
+	assert.Equal(t, "2.10.4", connect.Response["options"].(map[string]interface{})["version"])
+	summary := dissector.Summarize(connect)
+	assert.Equal(t, "orders-svc", summary.Summary)
+
+	sub := entries["SUB orders.*"]
+	assert.Equal(t, "workers", sub.Request["queueGroup"])
+	assert.Equal(t, "1", sub.Request["sid"])
+
+	pub := entries["PUB orders.new"]
+	assert.Equal(t, "hello", pub.Request["payloadText"])
+	assert.Equal(t, "", pub.Response["op"])
+	summary = dissector.Summarize(pub)
+	assert.Equal(t, "PUB", summary.Method)
+	assert.Equal(t, `request.op == "PUB"`, summary.MethodQuery)
+	assert.Equal(t, "orders.new", summary.Summary)
+	assert.Equal(t, `request.subject == "orders.new"`, summary.SummaryQuery)
+
+	request := entries["PUB svc.price"]
+	assert.Equal(t, "_INBOX.abc.1", request.Request["replyTo"])
+	assert.Equal(t, "9.99", request.Response["payloadText"])
+	assert.True(t, request.Outgoing)
+
+	jetStream := entries["HPUB $JS.API.STREAM.CREATE.ORDERS"]
+	assert.Equal(t, true, jetStream.Request["jetStream"])
+	assert.Equal(t, "STREAM.CREATE.ORDERS", jetStream.Request["jetStreamApi"])
+	assert.Equal(t, map[string]interface{}{"Nats-Msg-Id": "42"}, jetStream.Request["headers"])
+	assert.Equal(t, "insufficient resources", jetStream.Response["error"])
+	summary = dissector.Summarize(jetStream)
+	assert.Equal(t, 400, summary.Status)
+	assert.Equal(t, `response.status == 400`, summary.StatusQuery)
+
+	noResponders := entries["PUB svc.none"]
+	assert.Equal(t, "HMSG", noResponders.Response["op"])
+	assert.Equal(t, float64(503), noResponders.Response["status"])
+
+	responder := entries["MSG job.run"]
+	assert.Equal(t, "work", responder.Request["payloadText"])
+	assert.Equal(t, "ok", responder.Response["payloadText"])
+	assert.False(t, responder.Outgoing)
+
+	unsub := entries["UNSUB "]
+	assert.Equal(t, float64(10), unsub.Request["maxMsgs"])
+
+	failure := entries["-ERR "]
+	assert.Equal(t, `Permissions Violation for Publish to "forbidden"`, failure.Request["error"])
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,126 @@
+package nats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{requester_ip}_{responder_ip}_{requester_port}_{responder_port}_{reply_subject}`
+// Either side may send a request, since the server delivers the requests of
+// other connections to a responder.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *NatsMessage, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestNatsMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: NatsPayload{
+			Data: &NatsWrapper{
+				Method:  request.Op,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseNatsMessage := response.(*api.GenericMessage)
+		if responseNatsMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestNatsMessage, responseNatsMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestNatsMessage)
+	return nil
+}
+
+// registerResponse pairs a reply with its request. The reply is kept for a
+// request that wasn't read yet only if wait is set, since any message
+// without a reply subject might be a reply.
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *NatsMessage, captureTime time.Time, captureSize int, wait bool) *api.OutputChannelItem {
+	responseNatsMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: NatsPayload{
+			Data: &NatsWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestNatsMessage := request.(*api.GenericMessage)
+		if !requestNatsMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestNatsMessage, &responseNatsMessage)
+	}
+
+	if wait {
+		matcher.openMessagesMap.Store(ident, &responseNatsMessage)
+	}
+	return nil
+}
+
+// prepareUnanswered pairs a message that doesn't take part in a request
+// and reply, like a plain PUB or SUB, with an empty response.
+func (matcher *requestResponseMatcher) prepareUnanswered(request *NatsMessage, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestNatsMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: NatsPayload{
+			Data: &NatsWrapper{
+				Method:  request.Op,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseNatsMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: NatsPayload{
+			Data: &NatsWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &NatsMessage{},
+			},
+		},
+	}
+	return matcher.preparePair(&requestNatsMessage, &responseNatsMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestNatsMessage *api.GenericMessage, responseNatsMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestNatsMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestNatsMessage,
+			Response: *responseNatsMessage,
+		},
+	}
+}
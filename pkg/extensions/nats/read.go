@@ -0,0 +1,187 @@
+package nats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// INFO and CONNECT may be longer than the 4KB control line limit of the
+	// server, since they carry the cluster URLs and the credentials
+	maxControlLine = 64 * 1024
+	maxPayload     = 64 * 1024 * 1024
+)
+
+const (
+	opInfo    = "INFO"
+	opConnect = "CONNECT"
+	opPub     = "PUB"
+	opHpub    = "HPUB"
+	opSub     = "SUB"
+	opUnsub   = "UNSUB"
+	opMsg     = "MSG"
+	opHmsg    = "HMSG"
+	opPing    = "PING"
+	opPong    = "PONG"
+	opOk      = "+OK"
+	opErr     = "-ERR"
+)
+
+// The operations that each side of a connection sends
+var (
+	clientOperations = map[string]bool{opConnect: true, opPub: true, opHpub: true, opSub: true, opUnsub: true, opPing: true, opPong: true}
+	serverOperations = map[string]bool{opInfo: true, opMsg: true, opHmsg: true, opPing: true, opPong: true, opOk: true, opErr: true}
+)
+
+type operation struct {
+	Name       string
+	Arguments  string
+	Subject    string
+	ReplyTo    string
+	Sid        string
+	QueueGroup string
+	MaxMsgs    int
+	Headers    []byte
+	Payload    []byte
+}
+
+func readOperation(r *bufio.Reader, isClient bool) (*operation, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	name := line
+	arguments := ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name = line[:i]
+		arguments = strings.TrimSpace(line[i+1:])
+	}
+	op := &operation{Name: strings.ToUpper(name), Arguments: arguments}
+
+	if isClient && !clientOperations[op.Name] || !isClient && !serverOperations[op.Name] {
+		return nil, fmt.Errorf("unexpected operation: %q", name)
+	}
+
+	args := strings.Fields(arguments)
+	switch op.Name {
+	case opPing, opPong, opOk:
+		if len(args) != 0 {
+			return nil, fmt.Errorf("unexpected arguments of %s", op.Name)
+		}
+	case opInfo, opConnect:
+		if !strings.HasPrefix(arguments, "{") {
+			return nil, fmt.Errorf("%s doesn't have JSON arguments", op.Name)
+		}
+	case opErr:
+		op.Arguments = strings.Trim(arguments, "'")
+	case opSub:
+		// SUB <subject> [queue group] <sid>
+		if len(args) != 2 && len(args) != 3 {
+			return nil, fmt.Errorf("malformed %s arguments: %q", op.Name, arguments)
+		}
+		op.Subject, op.Sid = args[0], args[len(args)-1]
+		if len(args) == 3 {
+			op.QueueGroup = args[1]
+		}
+	case opUnsub:
+		// UNSUB <sid> [max_msgs]
+		if len(args) != 1 && len(args) != 2 {
+			return nil, fmt.Errorf("malformed %s arguments: %q", op.Name, arguments)
+		}
+		op.Sid = args[0]
+		if len(args) == 2 {
+			if op.MaxMsgs, err = strconv.Atoi(args[1]); err != nil {
+				return nil, fmt.Errorf("malformed %s arguments: %q", op.Name, arguments)
+			}
+		}
+	case opPub, opHpub, opMsg, opHmsg:
+		if err := op.readMessage(r, args); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
+
+// readMessage reads the arguments and the payload of a published or a
+// delivered message. Their layout is:
+//
+//	PUB <subject> [reply-to] <#bytes>
+//	HPUB <subject> [reply-to] <#header bytes> <#total bytes>
+//	MSG <subject> <sid> [reply-to] <#bytes>
+//	HMSG <subject> <sid> [reply-to] <#header bytes> <#total bytes>
+func (op *operation) readMessage(r *bufio.Reader, args []string) error {
+	fixed := 2
+	if op.Name == opMsg || op.Name == opHmsg {
+		fixed++
+	}
+	hasHeaders := op.Name == opHpub || op.Name == opHmsg
+	if hasHeaders {
+		fixed++
+	}
+	if len(args) != fixed && len(args) != fixed+1 {
+		return fmt.Errorf("malformed %s arguments: %q", op.Name, op.Arguments)
+	}
+	hasReplyTo := len(args) == fixed+1
+
+	op.Subject = args[0]
+	args = args[1:]
+	if op.Name == opMsg || op.Name == opHmsg {
+		op.Sid = args[0]
+		args = args[1:]
+	}
+	if hasReplyTo {
+		op.ReplyTo = args[0]
+		args = args[1:]
+	}
+
+	headerSize := 0
+	totalSize, err := strconv.Atoi(args[len(args)-1])
+	if err == nil && hasHeaders {
+		headerSize, err = strconv.Atoi(args[0])
+	}
+	if err != nil || totalSize < 0 || totalSize > maxPayload || headerSize < 0 || headerSize > totalSize {
+		return fmt.Errorf("malformed %s arguments: %q", op.Name, op.Arguments)
+	}
+
+	data := make([]byte, totalSize+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return fmt.Errorf("%s payload doesn't end with CRLF", op.Name)
+	}
+	op.Headers = data[:headerSize]
+	op.Payload = data[headerSize:totalSize]
+	return nil
+}
+
+// readLine reads a control line without its CRLF ending.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxControlLine {
+			return "", errors.New("control line is too long")
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", errors.New("control line doesn't end with CRLF")
+	}
+	return string(line[:len(line)-2]), nil
+}
@@ -0,0 +1,45 @@
+package nats
+
+import (
+	"encoding/json"
+)
+
+type NatsPayload struct {
+	Data interface{}
+}
+
+type NatsPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h NatsPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type NatsWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+// NatsMessage is an operation of either side. A message that answers
+// another one through its reply subject is the response of that message,
+// the INFO of the server is the response of CONNECT.
+type NatsMessage struct {
+	Op           string                 `json:"op"`
+	Subject      string                 `json:"subject"`
+	ReplyTo      string                 `json:"replyTo,omitempty"`
+	Sid          string                 `json:"sid,omitempty"`
+	QueueGroup   string                 `json:"queueGroup,omitempty"`
+	MaxMsgs      int                    `json:"maxMsgs,omitempty"`
+	Status       int                    `json:"status,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	Headers      map[string]string      `json:"headers,omitempty"`
+	Payload      string                 `json:"payload"`
+	PayloadText  string                 `json:"payloadText,omitempty"`
+	PayloadSize  int                    `json:"payloadSize"`
+	JetStream    bool                   `json:"jetStream"`
+	JetStreamApi string                 `json:"jetStreamApi,omitempty"`
+	Options      map[string]interface{} `json:"options,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
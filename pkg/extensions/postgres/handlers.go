@@ -0,0 +1,410 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// frontendState accumulates the client messages of one request cycle and
+// remembers the prepared statements and portals of the connection.
+type frontendState struct {
+	statements  map[string]string
+	portals     map[string]string
+	request     *PostgresRequest
+	queries     []string
+	captureTime time.Time
+}
+
+func newFrontendState() *frontendState {
+	return &frontendState{
+		statements: make(map[string]string),
+		portals:    make(map[string]string),
+	}
+}
+
+func (s *frontendState) begin(captureTime time.Time) *PostgresRequest {
+	if s.request == nil {
+		s.request = &PostgresRequest{
+			Messages:   make([]string, 0),
+			Parameters: make([]interface{}, 0),
+		}
+		s.queries = make([]string, 0)
+		s.captureTime = captureTime
+	}
+	return s.request
+}
+
+func (s *frontendState) finish() *PostgresRequest {
+	request := s.request
+	s.request = nil
+	if len(s.queries) > 0 {
+		request.Query = strings.Join(s.queries, "; ")
+	}
+	if request.Command == "" {
+		request.Command = queryCommand(request.Query)
+	}
+	if request.Command == "" {
+		request.Command = request.Type
+	}
+	return request
+}
+
+// handleFrontendMessage folds a client message into the current request
+// cycle. It returns the request once the cycle is complete.
+func (s *frontendState) handleFrontendMessage(msg *message, captureTime time.Time) (*PostgresRequest, error) {
+	if msg.Type == 0 {
+		return s.handleStartupMessage(msg, captureTime)
+	}
+
+	b := &buffer{data: msg.Body}
+
+	switch msg.Type {
+	case 'p', 'd', 'c', 'f', 'H':
+		// Password and COPY sub-protocol messages belong to a cycle that's already reported
+		return nil, nil
+	case 'X':
+		return nil, nil
+	}
+
+	request := s.begin(captureTime)
+	request.Messages = append(request.Messages, frontendMessages[msg.Type])
+
+	switch msg.Type {
+	case 'Q':
+		request.Type = typeSimpleQuery
+		request.Query = b.readString()
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+		return s.finish(), nil
+	case 'P':
+		request.Type = typeExtendedQuery
+		name := b.readString()
+		query := b.readString()
+		n := b.readInt16()
+		types := make([]int32, 0)
+		for i := 0; i < int(n) && b.err == nil; i++ {
+			types = append(types, b.readInt32())
+		}
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+		s.statements[name] = query
+		request.Statement = name
+		request.ParameterTypes = types
+	case 'B':
+		request.Type = typeExtendedQuery
+		portal := b.readString()
+		statement := b.readString()
+		formatCount := b.readInt16()
+		formats := make([]int16, 0)
+		for i := 0; i < int(formatCount) && b.err == nil; i++ {
+			formats = append(formats, b.readInt16())
+		}
+		paramCount := b.readInt16()
+		for i := 0; i < int(paramCount) && b.err == nil; i++ {
+			length := b.readInt32()
+			if length < 0 {
+				request.Parameters = append(request.Parameters, nil)
+				continue
+			}
+			value := b.readBytes(int(length))
+			isBinary := false
+			if len(formats) == 1 {
+				isBinary = formats[0] == 1
+			} else if i < len(formats) {
+				isBinary = formats[i] == 1
+			}
+			request.Parameters = append(request.Parameters, formatValue(value, isBinary))
+		}
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+		s.portals[portal] = statement
+		request.Statement = statement
+		request.Portal = portal
+	case 'E':
+		request.Type = typeExtendedQuery
+		portal := b.readString()
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+		request.Portal = portal
+		if query, ok := s.statements[s.portals[portal]]; ok {
+			s.queries = append(s.queries, query)
+		}
+	case 'D', 'C':
+		kind := b.readByte()
+		name := b.readString()
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+		if kind != 'S' && kind != 'P' {
+			s.request = nil
+			return nil, fmt.Errorf("invalid %s target: %q", frontendMessages[msg.Type], kind)
+		}
+		if request.Type == "" {
+			request.Type = typeExtendedQuery
+		}
+		if msg.Type == 'C' {
+			if kind == 'S' {
+				delete(s.statements, name)
+			} else {
+				delete(s.portals, name)
+			}
+		}
+	case 'F':
+		request.Type = typeFunctionCall
+		request.Command = fmt.Sprintf("FUNCTION %d", b.readInt32())
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+		return s.finish(), nil
+	case 'S':
+		if request.Type == "" {
+			request.Type = typeExtendedQuery
+		}
+		return s.finish(), nil
+	}
+
+	return nil, nil
+}
+
+func (s *frontendState) handleStartupMessage(msg *message, captureTime time.Time) (*PostgresRequest, error) {
+	s.request = nil
+	request := s.begin(captureTime)
+
+	switch msg.Code {
+	case sslRequestCode:
+		request.Type = typeSSLRequest
+	case gssEncRequestCode:
+		request.Type = typeGSSENCRequest
+	case cancelRequestCode:
+		request.Type = typeCancelRequest
+	case protocolVersion3:
+		request.Type = typeStartup
+		request.ProtocolVersion = "3.0"
+		request.StartupParameters = make(map[string]string)
+		b := &buffer{data: msg.Body}
+		for len(b.data) > 1 && b.err == nil {
+			key := b.readString()
+			value := b.readString()
+			// Never expose credentials that some drivers pass as startup options
+			if key == "password" {
+				value = "[REDACTED]"
+			}
+			request.StartupParameters[key] = value
+		}
+		if b.err != nil {
+			s.request = nil
+			return nil, b.err
+		}
+	}
+	request.Messages = append(request.Messages, request.Type)
+
+	return s.finish(), nil
+}
+
+// backendState accumulates the server messages that answer one request cycle.
+type backendState struct {
+	response    *PostgresResponse
+	captureTime time.Time
+}
+
+func (s *backendState) begin(captureTime time.Time) *PostgresResponse {
+	if s.response == nil {
+		s.response = &PostgresResponse{
+			Messages:    make([]string, 0),
+			Columns:     make([]PostgresColumn, 0),
+			Rows:        make([][]interface{}, 0),
+			CommandTags: make([]string, 0),
+		}
+		s.captureTime = captureTime
+	}
+	return s.response
+}
+
+func (s *backendState) finish() *PostgresResponse {
+	response := s.response
+	s.response = nil
+	return response
+}
+
+// handleBackendMessage folds a server message into the current response.
+// It returns the response once ReadyForQuery (or an encryption
+// negotiation reply) completes the cycle.
+func (s *backendState) handleBackendMessage(msg *message, captureTime time.Time) (*PostgresResponse, error) {
+	response := s.begin(captureTime)
+
+	if msg.Type == 0 {
+		response.Messages = append(response.Messages, "EncryptionResponse")
+		response.EncryptionResponse = string(msg.Body)
+		return s.finish(), nil
+	}
+
+	response.Messages = append(response.Messages, backendMessages[msg.Type])
+	b := &buffer{data: msg.Body}
+
+	switch msg.Type {
+	case 'R':
+		method := uint32(b.readInt32())
+		name, ok := authenticationMethods[method]
+		if !ok {
+			name = fmt.Sprintf("%d", method)
+		}
+		response.Authentication = append(response.Authentication, name)
+	case 'S':
+		name := b.readString()
+		value := b.readString()
+		if response.ParameterStatus == nil {
+			response.ParameterStatus = make(map[string]string)
+		}
+		response.ParameterStatus[name] = value
+	case 'K':
+		response.ProcessID = b.readInt32()
+	case 'T':
+		n := b.readInt16()
+		columns := make([]PostgresColumn, 0)
+		for i := 0; i < int(n) && b.err == nil; i++ {
+			column := PostgresColumn{}
+			column.Name = b.readString()
+			column.TableOID = b.readInt32()
+			b.readInt16() // attribute number of the column
+			column.TypeOID = b.readInt32()
+			column.TypeSize = b.readInt16()
+			column.TypeModifier = b.readInt32()
+			column.Format = b.readInt16()
+			columns = append(columns, column)
+		}
+		response.Columns = columns
+	case 'D':
+		n := b.readInt16()
+		row := make([]interface{}, 0)
+		for i := 0; i < int(n) && b.err == nil; i++ {
+			length := b.readInt32()
+			if length < 0 {
+				row = append(row, nil)
+				continue
+			}
+			value := b.readBytes(int(length))
+			isBinary := i < len(response.Columns) && response.Columns[i].Format == 1
+			row = append(row, truncateValue(formatValue(value, isBinary)))
+		}
+		if len(response.Rows) < maxRowsInResponse {
+			response.Rows = append(response.Rows, row)
+		}
+		response.RowCount++
+	case 'C':
+		tag := b.readString()
+		response.CommandTags = append(response.CommandTags, tag)
+		if rows, ok := rowsFromCommandTag(tag); ok && rows > response.RowCount {
+			response.RowCount = rows
+		}
+	case 'E':
+		response.Error = readErrorFields(b)
+	case 'N':
+		response.Notices = append(response.Notices, readErrorFields(b))
+	case 'Z':
+		status := b.readByte()
+		if b.err != nil {
+			s.response = nil
+			return nil, b.err
+		}
+		if name, ok := transactionStatuses[status]; ok {
+			response.TransactionStatus = name
+		} else {
+			s.response = nil
+			return nil, fmt.Errorf("invalid transaction status: %q", status)
+		}
+		return s.finish(), nil
+	}
+
+	if b.err != nil {
+		s.response = nil
+		return nil, b.err
+	}
+
+	return nil, nil
+}
+
+func readErrorFields(b *buffer) PostgresError {
+	fields := make(PostgresError)
+	for b.err == nil {
+		code := b.readByte()
+		if code == 0 {
+			break
+		}
+		value := b.readString()
+		name, ok := errorFields[code]
+		if !ok {
+			name = string(code)
+		}
+		fields[name] = value
+	}
+	return fields
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, request *PostgresRequest, reqResMatcher *requestResponseMatcher) {
+	counterPair.Lock()
+	counterPair.Request++
+	requestCounter := counterPair.Request
+	counterPair.Unlock()
+
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		tcpID.SrcIP,
+		tcpID.DstIP,
+		tcpID.SrcPort,
+		tcpID.DstPort,
+		requestCounter,
+	)
+
+	item := reqResMatcher.registerRequest(ident, request, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, response *PostgresResponse, reqResMatcher *requestResponseMatcher) {
+	counterPair.Lock()
+	counterPair.Response++
+	responseCounter := counterPair.Response
+	counterPair.Unlock()
+
+	ident := fmt.Sprintf(
+		"%s_%s_%s_%s_%d",
+		tcpID.DstIP,
+		tcpID.SrcIP,
+		tcpID.DstPort,
+		tcpID.SrcPort,
+		responseCounter,
+	)
+
+	item := reqResMatcher.registerResponse(ident, response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    request["type"].(string),
+			Selector: `request.type`,
+		},
+		{
+			Name:     "Command",
+			Value:    request["command"].(string),
+			Selector: `request.command`,
+		},
+		{
+			Name:     "Statement",
+			Value:    request["statement"].(string),
+			Selector: `request.statement`,
+		},
+		{
+			Name:     "Portal",
+			Value:    request["portal"].(string),
+			Selector: `request.portal`,
+		},
+		{
+			Name:     "Messages",
+			Value:    joinInterfaces(request["messages"]),
+			Selector: `request.messages`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if parameters, ok := request["startupParameters"].(map[string]interface{}); ok {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Startup Parameters",
+			Data:  representMapAsTable(parameters, `request.startupParameters`),
+		})
+	}
+
+	if query, ok := request["query"].(string); ok && query != "" {
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Query",
+			MimeType: "application/sql",
+			Data:     query,
+			Selector: `request.query`,
+		})
+	}
+
+	if parameters, ok := request["parameters"].([]interface{}); ok && len(parameters) > 0 {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Parameters",
+			Data:  representSliceAsTable(parameters, `request.parameters`),
+		})
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Command Tags",
+			Value:    joinInterfaces(response["commandTags"]),
+			Selector: `response.commandTags`,
+		},
+		{
+			Name:     "Row Count",
+			Value:    int64(response["rowCount"].(float64)),
+			Selector: `response.rowCount`,
+		},
+		{
+			Name:     "Transaction Status",
+			Value:    response["transactionStatus"].(string),
+			Selector: `response.transactionStatus`,
+		},
+		{
+			Name:     "Messages",
+			Value:    joinInterfaces(response["messages"]),
+			Selector: `response.messages`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if pgError, ok := response["error"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Error",
+			Data:  representMapAsTable(pgError, `response.error`),
+		})
+	}
+
+	if columns, ok := response["columns"].([]interface{}); ok && len(columns) > 0 {
+		var table []api.TableData
+		for i, column := range columns {
+			c := column.(map[string]interface{})
+			table = append(table, api.TableData{
+				Name:     c["name"].(string),
+				Value:    fmt.Sprintf("type OID %g", c["typeOid"].(float64)),
+				Selector: fmt.Sprintf("response.columns[%d].name", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Columns",
+			Data:  string(obj),
+		})
+	}
+
+	if rows, ok := response["rows"].([]interface{}); ok && len(rows) > 0 {
+		obj, _ := json.Marshal(rows)
+		repResponse = append(repResponse, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Rows",
+			MimeType: "application/json",
+			Data:     string(obj),
+			Selector: `response.rows`,
+		})
+	}
+
+	if parameters, ok := response["parameterStatus"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Parameter Status",
+			Data:  representMapAsTable(parameters, `response.parameterStatus`),
+		})
+	}
+
+	return
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
+
+func representSliceAsTable(slice []interface{}, selectorPrefix string) string {
+	var table []api.TableData
+	for i, item := range slice {
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("$%d", i+1),
+			Value:    item,
+			Selector: fmt.Sprintf("%s[%d]", selectorPrefix, i),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
+
+func joinInterfaces(value interface{}) string {
+	items, _ := value.([]interface{})
+	joined := ""
+	for i, item := range items {
+		if i > 0 {
+			joined += ", "
+		}
+		joined += fmt.Sprintf("%v", item)
+	}
+	return joined
+}
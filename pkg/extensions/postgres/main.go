@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "postgres",
+	Version:         "3.0",
+	Abbreviation:    "PG",
+	LongName:        "PostgreSQL Frontend/Backend Protocol",
+	Macro:           "postgres",
+	BackgroundColor: "#336791",
+	ForegroundColor: "#ffffff",
+	FontSize:        12,
+	ReferenceLink:   "https://www.postgresql.org/docs/current/protocol.html",
+	Ports:           []string{"5432"},
+	Layer4:          "tcp",
+	Priority:        5,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+	r := NewPostgresReader(b, reader.GetIsClient())
+	frontend := newFrontendState()
+	backend := &backendState{}
+
+	for {
+		msg, err := r.readMessage()
+		if err != nil {
+			// A failed authentication is answered with an ErrorResponse and no ReadyForQuery
+			if !reader.GetIsClient() && backend.response != nil && backend.response.Error != nil {
+				handleServerStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), backend.captureTime, reader.GetEmitter(), backend.finish(), reqResMatcher)
+			}
+			return err
+		}
+
+		if reader.GetIsClient() {
+			request, err := frontend.handleFrontendMessage(msg, reader.GetCaptureTime())
+			if err != nil {
+				return err
+			}
+			if request != nil {
+				reader.GetParent().SetProtocol(&protocol)
+				handleClientStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), frontend.captureTime, reader.GetEmitter(), request, reqResMatcher)
+			}
+		} else {
+			response, err := backend.handleBackendMessage(msg, reader.GetCaptureTime())
+			if err != nil {
+				return err
+			}
+			if response != nil {
+				reader.GetParent().SetProtocol(&protocol)
+				handleServerStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), backend.captureTime, reader.GetEmitter(), response, reqResMatcher)
+			}
+		}
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	status := 0
+	statusQuery := ""
+
+	method := entry.Request["command"].(string)
+	methodQuery := fmt.Sprintf(`request.command == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	switch entry.Request["type"].(string) {
+	case typeStartup:
+		if parameters, ok := entry.Request["startupParameters"].(map[string]interface{}); ok {
+			if database, ok := parameters["database"].(string); ok {
+				summary = database
+				summaryQuery = fmt.Sprintf(`request.startupParameters.database == "%s"`, summary)
+			}
+		}
+	default:
+		if query, ok := entry.Request["query"].(string); ok && query != "" {
+			summary = query
+			summaryQuery = fmt.Sprintf(`request.query == %q`, summary)
+		}
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       status,
+		StatusQuery:  statusQuery,
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representRequest(request)
+	repResponse := representResponse(response)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`postgres`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
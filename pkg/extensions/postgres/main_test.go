@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "postgres", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"postgres": `protocol.name == "postgres"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func int16Bytes(v int16) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, uint16(v))
+	return buf
+}
+
+func int32Bytes(v int32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(v))
+	return buf
+}
+
+func typedMessage(t byte, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	buf := append([]byte{t}, int32Bytes(int32(len(body)+4))...)
+	return append(buf, body...)
+}
+
+func startupMessage(params ...string) []byte {
+	body := int32Bytes(protocolVersion3)
+	for _, param := range params {
+		body = append(body, cstring(param)...)
+	}
+	body = append(body, 0)
+	return append(int32Bytes(int32(len(body)+4)), body...)
+}
+
+func dissect(t *testing.T, client []byte, server []byte) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.ClientFirst, client, server)
+}
+
+func TestDissect(t *testing.T) {
+	client := bytes.Join([][]byte{
+		startupMessage("user", "app", "database", "orders"),
+		typedMessage('Q', cstring("SELECT id FROM orders")),
+		typedMessage('P', cstring("s1"), cstring("UPDATE orders SET state = $1 WHERE id = $2"), int16Bytes(0)),
+		typedMessage('B', cstring(""), cstring("s1"), int16Bytes(0), int16Bytes(2), int32Bytes(4), []byte("paid"), int32Bytes(-1), int16Bytes(0)),
+		typedMessage('E', cstring(""), int32Bytes(0)),
+		typedMessage('S'),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		typedMessage('R', int32Bytes(0)),
+		typedMessage('S', cstring("server_version"), cstring("15.2")),
+		typedMessage('K', int32Bytes(42), int32Bytes(7)),
+		typedMessage('Z', []byte{'I'}),
+		typedMessage('T', int16Bytes(1), cstring("id"), int32Bytes(16384), int16Bytes(1), int32Bytes(23), int16Bytes(4), int32Bytes(-1), int16Bytes(0)),
+		typedMessage('D', int16Bytes(1), int32Bytes(1), []byte("1")),
+		typedMessage('D', int16Bytes(1), int32Bytes(1), []byte("2")),
+		typedMessage('C', cstring("SELECT 2")),
+		typedMessage('Z', []byte{'I'}),
+		typedMessage('1'),
+		typedMessage('2'),
+		typedMessage('E', []byte{'S'}, cstring("ERROR"), []byte{'C'}, cstring("40001"), []byte{'M'}, cstring("could not serialize access"), []byte{0}),
+		typedMessage('Z', []byte{'E'}),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	dissector := NewDissector()
+
+	assert.Equal(t, typeStartup, entries[0].Request["type"])
+	assert.Equal(t, "15.2", entries[0].Response["parameterStatus"].(map[string]interface{})["server_version"])
+	assert.Equal(t, "orders", dissector.Summarize(entries[0]).Summary)
+
+	assert.Equal(t, "SELECT id FROM orders", entries[1].Request["query"])
+	assert.Equal(t, float64(2), entries[1].Response["rowCount"])
+	assert.Equal(t, "SELECT", dissector.Summarize(entries[1]).Method)
+
+	assert.Equal(t, "UPDATE orders SET state = $1 WHERE id = $2", entries[2].Request["query"])
+	assert.Equal(t, []interface{}{"paid", nil}, entries[2].Request["parameters"])
+	assert.Equal(t, "40001", entries[2].Response["error"].(map[string]interface{})["code"])
+	assert.Equal(t, "FailedTransaction", entries[2].Response["transactionStatus"])
+}
+
+func TestDissectEncryptionNegotiation(t *testing.T) {
+	sslRequest := append(int32Bytes(8), int32Bytes(sslRequestCode)...)
+	client := bytes.Join([][]byte{
+		sslRequest,
+		startupMessage("user", "app"),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		{'N'},
+		typedMessage('R', int32Bytes(0)),
+		typedMessage('Z', []byte{'I'}),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 2)
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, true, false, nil, nil, &api.CounterPair{}, dissector.NewResponseRequestMatcher())
+	err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{src_ip}_{dst_ip}_{src_port}_{dst_port}_{incremental_counter}`
+// Each request cycle ends with a ReadyForQuery message from the server, so the
+// n-th cycle of the client is answered by the n-th cycle of the server.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *PostgresRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestPostgresMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: PostgresPayload{
+			Data: &PostgresWrapper{
+				Method:  request.Command,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responsePostgresMessage := response.(*api.GenericMessage)
+		if responsePostgresMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestPostgresMessage, responsePostgresMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestPostgresMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *PostgresResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responsePostgresMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: PostgresPayload{
+			Data: &PostgresWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestPostgresMessage := request.(*api.GenericMessage)
+		if !requestPostgresMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestPostgresMessage, &responsePostgresMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responsePostgresMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestPostgresMessage *api.GenericMessage, responsePostgresMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestPostgresMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestPostgresMessage,
+			Response: *responsePostgresMessage,
+		},
+	}
+}
@@ -0,0 +1,337 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	protocolVersion3     = 196608
+	sslRequestCode       = 80877103
+	gssEncRequestCode    = 80877104
+	cancelRequestCode    = 80877102
+	maxMessageLength     = 16 * 1024 * 1024
+	maxStartupLength     = 10000
+	messageHeaderLength  = 5
+	startupHeaderLength  = 8
+	maxRowsInResponse    = 10
+	maxValueLengthInRows = 256
+)
+
+var frontendMessages = map[byte]string{
+	'B': "Bind",
+	'C': "Close",
+	'c': "CopyDone",
+	'd': "CopyData",
+	'D': "Describe",
+	'E': "Execute",
+	'f': "CopyFail",
+	'F': "FunctionCall",
+	'H': "Flush",
+	'P': "Parse",
+	'p': "PasswordMessage",
+	'Q': "Query",
+	'S': "Sync",
+	'X': "Terminate",
+}
+
+var backendMessages = map[byte]string{
+	'1': "ParseComplete",
+	'2': "BindComplete",
+	'3': "CloseComplete",
+	'A': "NotificationResponse",
+	'c': "CopyDone",
+	'C': "CommandComplete",
+	'd': "CopyData",
+	'D': "DataRow",
+	'E': "ErrorResponse",
+	'G': "CopyInResponse",
+	'H': "CopyOutResponse",
+	'I': "EmptyQueryResponse",
+	'K': "BackendKeyData",
+	'n': "NoData",
+	'N': "NoticeResponse",
+	'R': "Authentication",
+	's': "PortalSuspended",
+	'S': "ParameterStatus",
+	't': "ParameterDescription",
+	'T': "RowDescription",
+	'v': "NegotiateProtocolVersion",
+	'V': "FunctionCallResponse",
+	'W': "CopyBothResponse",
+	'Z': "ReadyForQuery",
+}
+
+var authenticationMethods = map[uint32]string{
+	0:  "OK",
+	2:  "KerberosV5",
+	3:  "CleartextPassword",
+	5:  "MD5Password",
+	7:  "GSS",
+	8:  "GSSContinue",
+	9:  "SSPI",
+	10: "SASL",
+	11: "SASLContinue",
+	12: "SASLFinal",
+}
+
+var errorFields = map[byte]string{
+	'S': "severity",
+	'V': "severityNonLocalized",
+	'C': "code",
+	'M': "message",
+	'D': "detail",
+	'H': "hint",
+	'P': "position",
+	'p': "internalPosition",
+	'q': "internalQuery",
+	'W': "where",
+	's': "schema",
+	't': "table",
+	'c': "column",
+	'd': "dataType",
+	'n': "constraint",
+	'F': "file",
+	'L': "line",
+	'R': "routine",
+}
+
+var transactionStatuses = map[byte]string{
+	'I': "Idle",
+	'T': "InTransaction",
+	'E': "FailedTransaction",
+}
+
+// message is a single frontend or backend message. Untyped startup-phase
+// messages carry a zero Type and their request code in Code.
+type message struct {
+	Type byte
+	Code uint32
+	Body []byte
+}
+
+type PostgresReader struct {
+	*bufio.Reader
+	isClient bool
+	startup  bool
+}
+
+func NewPostgresReader(r *bufio.Reader, isClient bool) *PostgresReader {
+	return &PostgresReader{
+		Reader:   r,
+		isClient: isClient,
+		startup:  true,
+	}
+}
+
+func (r *PostgresReader) readMessage() (msg *message, err error) {
+	if r.startup {
+		if r.isClient {
+			msg, err = r.readStartupMessage()
+		} else {
+			msg, err = r.readEncryptionResponse()
+		}
+		if msg != nil || err != nil {
+			return
+		}
+		r.startup = false
+	}
+
+	return r.readTypedMessage()
+}
+
+// readStartupMessage reads the untyped message that opens a connection.
+// Returns nil without an error if the stream does not start with one,
+// which happens when the capture begins in the middle of a connection.
+func (r *PostgresReader) readStartupMessage() (*message, error) {
+	header, err := r.Peek(startupHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	code := binary.BigEndian.Uint32(header[4:8])
+	switch code {
+	case protocolVersion3, sslRequestCode, gssEncRequestCode, cancelRequestCode:
+	default:
+		return nil, nil
+	}
+
+	if length < startupHeaderLength || length > maxStartupLength {
+		return nil, fmt.Errorf("invalid startup message length: %d", length)
+	}
+
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	// Only an SSL or GSSAPI encryption request is followed by another startup message
+	if code == protocolVersion3 || code == cancelRequestCode {
+		r.startup = false
+	}
+
+	return &message{Code: code, Body: buf[startupHeaderLength:]}, nil
+}
+
+// readEncryptionResponse reads the single byte that the server sends in
+// reply to an SSLRequest or GSSENCRequest. It returns nil without an error
+// if the stream starts with a regular backend message instead.
+func (r *PostgresReader) readEncryptionResponse() (*message, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b[0] {
+	case 'S', 'N', 'G':
+	default:
+		return nil, nil
+	}
+
+	header, err := r.Peek(messageHeaderLength)
+	if err == nil {
+		length := binary.BigEndian.Uint32(header[1:5])
+		if length >= 4 && length <= maxMessageLength {
+			return nil, nil
+		}
+	} else if err != io.EOF {
+		return nil, err
+	}
+
+	if _, err = r.Discard(1); err != nil {
+		return nil, err
+	}
+
+	// 'N' means the client falls back to an unencrypted startup, anything else is encrypted from now on
+	if b[0] == 'N' {
+		r.startup = false
+	}
+
+	return &message{Code: sslRequestCode, Body: []byte{b[0]}}, nil
+}
+
+func (r *PostgresReader) readTypedMessage() (*message, error) {
+	header := make([]byte, messageHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	var known bool
+	if r.isClient {
+		_, known = frontendMessages[header[0]]
+	} else {
+		_, known = backendMessages[header[0]]
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown message type: %q", header[0])
+	}
+
+	length := binary.BigEndian.Uint32(header[1:5])
+	if length < 4 || length > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length: %d", length)
+	}
+
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &message{Type: header[0], Body: body}, nil
+}
+
+// buffer is a cursor over a message body.
+type buffer struct {
+	data []byte
+	err  error
+}
+
+var errShortMessage = errors.New("message is shorter than its declared fields")
+
+func (b *buffer) readByte() byte {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 1 {
+		b.err = errShortMessage
+		return 0
+	}
+	v := b.data[0]
+	b.data = b.data[1:]
+	return v
+}
+
+func (b *buffer) readInt16() int16 {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 2 {
+		b.err = errShortMessage
+		return 0
+	}
+	v := int16(binary.BigEndian.Uint16(b.data))
+	b.data = b.data[2:]
+	return v
+}
+
+func (b *buffer) readInt32() int32 {
+	if b.err != nil {
+		return 0
+	}
+	if len(b.data) < 4 {
+		b.err = errShortMessage
+		return 0
+	}
+	v := int32(binary.BigEndian.Uint32(b.data))
+	b.data = b.data[4:]
+	return v
+}
+
+func (b *buffer) readString() string {
+	if b.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(b.data, 0)
+	if i < 0 {
+		b.err = errors.New("string is not null-terminated")
+		return ""
+	}
+	v := string(b.data[:i])
+	b.data = b.data[i+1:]
+	return v
+}
+
+func (b *buffer) readBytes(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || len(b.data) < n {
+		b.err = errShortMessage
+		return nil
+	}
+	v := b.data[:n]
+	b.data = b.data[n:]
+	return v
+}
+
+// formatValue renders a column or parameter value. Text values are kept as
+// they are while binary ones are shown in the bytea hex format.
+func formatValue(value []byte, binaryFormat bool) string {
+	if !binaryFormat && utf8.Valid(value) {
+		return string(value)
+	}
+	return fmt.Sprintf("\\x%s", hex.EncodeToString(value))
+}
+
+func truncateValue(value string) string {
+	if len(value) > maxValueLengthInRows {
+		return value[:maxValueLengthInRows] + "..."
+	}
+	return value
+}
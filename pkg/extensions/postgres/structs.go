@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type PostgresPayload struct {
+	Data interface{}
+}
+
+type PostgresPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h PostgresPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type PostgresWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+const (
+	typeStartup       = "Startup"
+	typeSSLRequest    = "SSLRequest"
+	typeGSSENCRequest = "GSSENCRequest"
+	typeCancelRequest = "CancelRequest"
+	typeSimpleQuery   = "SimpleQuery"
+	typeExtendedQuery = "ExtendedQuery"
+	typeFunctionCall  = "FunctionCall"
+)
+
+type PostgresRequest struct {
+	Type              string            `json:"type"`
+	Command           string            `json:"command"`
+	Messages          []string          `json:"messages"`
+	Query             string            `json:"query"`
+	Statement         string            `json:"statement"`
+	Portal            string            `json:"portal"`
+	Parameters        []interface{}     `json:"parameters"`
+	ParameterTypes    []int32           `json:"parameterTypes"`
+	ProtocolVersion   string            `json:"protocolVersion,omitempty"`
+	StartupParameters map[string]string `json:"startupParameters,omitempty"`
+}
+
+type PostgresColumn struct {
+	Name         string `json:"name"`
+	TableOID     int32  `json:"tableOid"`
+	TypeOID      int32  `json:"typeOid"`
+	TypeSize     int16  `json:"typeSize"`
+	TypeModifier int32  `json:"typeModifier"`
+	Format       int16  `json:"format"`
+}
+
+type PostgresError map[string]string
+
+type PostgresResponse struct {
+	Messages           []string          `json:"messages"`
+	Columns            []PostgresColumn  `json:"columns"`
+	Rows               [][]interface{}   `json:"rows"`
+	RowCount           int64             `json:"rowCount"`
+	CommandTags        []string          `json:"commandTags"`
+	Error              PostgresError     `json:"error,omitempty"`
+	Notices            []PostgresError   `json:"notices,omitempty"`
+	Authentication     []string          `json:"authentication,omitempty"`
+	ParameterStatus    map[string]string `json:"parameterStatus,omitempty"`
+	ProcessID          int32             `json:"processId,omitempty"`
+	TransactionStatus  string            `json:"transactionStatus"`
+	EncryptionResponse string            `json:"encryptionResponse,omitempty"`
+}
+
+// queryCommand returns the leading keyword of an SQL statement, e.g. SELECT.
+func queryCommand(query string) string {
+	fields := strings.Fields(strings.TrimLeft(query, "( \t\r\n"))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimRight(fields[0], ";"))
+}
+
+// rowsFromCommandTag extracts the affected row count from tags such as
+// "INSERT 0 5", "UPDATE 3" or "SELECT 10".
+func rowsFromCommandTag(tag string) (int64, bool) {
+	fields := strings.Fields(tag)
+	if len(fields) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,146 @@
+package pulsar
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// How a command is paired
+const (
+	roleSkipped = iota
+	roleRequest
+	roleResponse
+	roleUnanswered
+)
+
+// pairing returns the role of a command, with the keys of its pairs. The
+// client's requests are answered by their request_id, SEND by the
+// producer_id and the sequence_id and MESSAGE by the ACK of its message id.
+func (c *PulsarCommand) pairing(isClient bool) (int, []string) {
+	switch c.code {
+	case typePing, typePong, typeFlow, typeAckResponse:
+		return roleSkipped, nil
+	case typeConnect:
+		return roleRequest, []string{"connect"}
+	case typeConnected:
+		return roleResponse, []string{"connect"}
+	case typeAuthChallenge:
+		return roleRequest, []string{"auth"}
+	case typeAuthResponse:
+		return roleResponse, []string{"auth"}
+	case typeSend:
+		return roleRequest, []string{c.sendKey()}
+	case typeSendReceipt, typeSendError:
+		return roleResponse, []string{c.sendKey()}
+	case typeMessage:
+		messageID, _ := c.Command["messageId"].(map[string]interface{})
+		return roleRequest, []string{c.messageKey(messageID)}
+	case typeAck:
+		var keys []string
+		messageIDs, _ := c.Command["messageId"].([]interface{})
+		for _, messageID := range messageIDs {
+			keys = append(keys, c.messageKey(messageID.(map[string]interface{})))
+		}
+		return roleResponse, keys
+	}
+
+	requestID, ok := c.Command["requestId"].(uint64)
+	switch {
+	case !ok:
+		return roleUnanswered, nil
+	case isClient:
+		return roleRequest, []string{fmt.Sprintf("request_%d", requestID)}
+	case c.code == typeCloseProducer || c.code == typeCloseConsumer:
+		// The broker closes them on its own
+		return roleUnanswered, nil
+	case c.code == typeError && requestID == math.MaxUint64:
+		// The error of a CONNECT has no request_id
+		return roleResponse, []string{"connect"}
+	}
+	return roleResponse, []string{fmt.Sprintf("request_%d", requestID)}
+}
+
+func (c *PulsarCommand) sendKey() string {
+	return fmt.Sprintf("send_%v_%v", c.Command["producerId"], c.Command["sequenceId"])
+}
+
+func (c *PulsarCommand) messageKey(messageID map[string]interface{}) string {
+	return fmt.Sprintf("message_%v_%v_%v", c.Command["consumerId"], messageID["ledgerId"], messageID["entryId"])
+}
+
+// topicKey is the key of the topic of the producer or the consumer of a
+// command, if it refers to one.
+func (c *PulsarCommand) topicKey() string {
+	if producerID, ok := c.Command["producerId"]; ok {
+		return fmt.Sprintf("producer_%v", producerID)
+	}
+	if consumerID, ok := c.Command["consumerId"]; ok {
+		return fmt.Sprintf("consumer_%v", consumerID)
+	}
+	return ""
+}
+
+func identity(tcpID *api.TcpID, isClient bool, key string) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, key)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, key)
+}
+
+// handleCommand pairs a command. The broker sends MESSAGE on its own, so the
+// pair is outgoing when the client sent the request.
+func handleCommand(progress *api.ReadProgress, tcpID *api.TcpID, isClient bool, captureTime time.Time, emitter api.Emitter, command *PulsarCommand, reqResMatcher *requestResponseMatcher) {
+	command.fromClient = isClient
+	if command.Topic != "" && (command.code == typeProducer || command.code == typeSubscribe) {
+		reqResMatcher.topics.Store(identity(tcpID, isClient, command.topicKey()), command.Topic)
+	}
+
+	role, keys := command.pairing(isClient)
+	var items []*api.OutputChannelItem
+	switch role {
+	case roleUnanswered:
+		items = append(items, reqResMatcher.prepareUnanswered(command, captureTime, progress.Current()))
+	case roleRequest, roleResponse:
+		for _, key := range keys {
+			var item *api.OutputChannelItem
+			if role == roleRequest {
+				item = reqResMatcher.registerRequest(identity(tcpID, isClient, key), command, captureTime, progress.Current())
+			} else {
+				item = reqResMatcher.registerResponse(identity(tcpID, isClient, key), command, captureTime, progress.Current())
+			}
+			if item != nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	for _, item := range items {
+		request := item.Pair.Request.Payload.(PulsarPayload).Data.(*PulsarWrapper).Details.(*PulsarCommand)
+		if request.Topic == "" && request.topicKey() != "" {
+			if topic, ok := reqResMatcher.topics.Load(identity(tcpID, isClient, request.topicKey())); ok {
+				request.Topic = topic.(string)
+			}
+		}
+
+		if isClient {
+			item.ConnectionInfo = &api.ConnectionInfo{
+				ClientIP:   tcpID.SrcIP,
+				ClientPort: tcpID.SrcPort,
+				ServerIP:   tcpID.DstIP,
+				ServerPort: tcpID.DstPort,
+			}
+		} else {
+			item.ConnectionInfo = &api.ConnectionInfo{
+				ClientIP:   tcpID.DstIP,
+				ClientPort: tcpID.DstPort,
+				ServerIP:   tcpID.SrcIP,
+				ServerPort: tcpID.SrcPort,
+			}
+		}
+		item.ConnectionInfo.IsOutgoing = request.fromClient
+		emitter.Emit(item)
+	}
+}
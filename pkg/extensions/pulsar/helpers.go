@@ -0,0 +1,135 @@
+package pulsar
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// representCommand represents a request or a response, which are both
+// commands. The response of a command that isn't answered is empty.
+func representCommand(command map[string]interface{}, selectorPrefix string) (rep []interface{}) {
+	rep = make([]interface{}, 0)
+
+	commandType, _ := command["type"].(string)
+	if commandType == "" {
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    commandType,
+			Selector: selectorPrefix + `.type`,
+		},
+		{
+			Name:     "Topic",
+			Value:    command["topic"],
+			Selector: selectorPrefix + `.topic`,
+		},
+		{
+			Name:     "Error",
+			Value:    command["error"],
+			Selector: selectorPrefix + `.error`,
+		},
+		{
+			Name:     "Compression",
+			Value:    command["compression"],
+			Selector: selectorPrefix + `.compression`,
+		},
+		{
+			Name:     "Payload Size",
+			Value:    command["payloadSize"],
+			Selector: selectorPrefix + `.payloadSize`,
+		},
+	})
+	rep = append(rep, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if fields, ok := command["command"].(map[string]interface{}); ok && len(fields) > 0 {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Command",
+			Data:  representMapAsTable(fields, selectorPrefix+`.command`),
+		})
+	}
+
+	if metadata, ok := command["brokerEntryMetadata"].(map[string]interface{}); ok {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Broker Entry Metadata",
+			Data:  representMapAsTable(metadata, selectorPrefix+`.brokerEntryMetadata`),
+		})
+	}
+
+	if metadata, ok := command["metadata"].(map[string]interface{}); ok {
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Metadata",
+			Data:  representMapAsTable(metadata, selectorPrefix+`.metadata`),
+		})
+	}
+
+	if payload, ok := command["payload"].(string); ok && payload != "" {
+		rep = append(rep, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Payload",
+			Encoding: "base64",
+			Data:     payload,
+			Selector: selectorPrefix + `.payload`,
+		})
+	}
+
+	messages, _ := command["messages"].([]interface{})
+	for i, message := range messages {
+		m := message.(map[string]interface{})
+		messagePrefix := fmt.Sprintf("%s.messages[%d]", selectorPrefix, i)
+		rep = append(rep, api.SectionData{
+			Type:  api.TABLE,
+			Title: fmt.Sprintf("Message %d Metadata", i),
+			Data:  representMapAsTable(m["metadata"].(map[string]interface{}), messagePrefix+`.metadata`),
+		})
+		rep = append(rep, api.SectionData{
+			Type:     api.BODY,
+			Title:    fmt.Sprintf("Message %d Payload", i),
+			Encoding: "base64",
+			Data:     m["payload"].(string),
+			Selector: messagePrefix + `.payload`,
+		})
+	}
+
+	return
+}
+
+// representMapAsTable represents a map, giving the values that are lists or
+// maps themselves as JSON.
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		value := mapToTable[key]
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			data, _ := json.Marshal(value)
+			value = string(data)
+		}
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    value,
+			Selector: fmt.Sprintf(`%s["%s"]`, selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
@@ -0,0 +1,142 @@
+package pulsar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+var protocol = api.Protocol{
+	Name:            "pulsar",
+	Version:         "2",
+	Abbreviation:    "PULSAR",
+	LongName:        "Apache Pulsar",
+	Macro:           "pulsar",
+	BackgroundColor: "#188fff",
+	ForegroundColor: "#ffffff",
+	FontSize:        11,
+	ReferenceLink:   "https://pulsar.apache.org/docs/next/developing-binary-protocol/",
+	Ports:           []string{"6650", "6651"},
+	Layer4:          "tcp",
+	Priority:        15,
+}
+
+type dissecting string
+
+func (d dissecting) Register(extension *api.Extension) {
+	extension.Protocol = &protocol
+}
+
+func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
+	reqResMatcher := reader.GetReqResMatcher().(*requestResponseMatcher)
+
+	for {
+		command, err := readFrame(b)
+		if err != nil {
+			return err
+		}
+		reader.GetParent().SetProtocol(&protocol)
+		handleCommand(reader.GetReadProgress(), reader.GetTcpID(), reader.GetIsClient(), reader.GetCaptureTime(), reader.GetEmitter(), command, reqResMatcher)
+	}
+}
+
+func (d dissecting) Analyze(item *api.OutputChannelItem, resolvedSource *api.Resolution, resolvedDestination *api.Resolution) *api.Entry {
+	request := item.Pair.Request.Payload.(map[string]interface{})
+	response := item.Pair.Response.Payload.(map[string]interface{})
+	reqDetails := request["details"].(map[string]interface{})
+	resDetails := response["details"].(map[string]interface{})
+
+	elapsedTime := item.Pair.Response.CaptureTime.Sub(item.Pair.Request.CaptureTime).Round(time.Millisecond).Milliseconds()
+	if elapsedTime < 0 {
+		elapsedTime = 0
+	}
+
+	return &api.Entry{
+		Index:        item.Index,
+		Stream:       item.Stream,
+		Node:         &api.Node{},
+		Protocol:     protocol,
+		Tls:          item.Tls,
+		Source:       resolvedSource,
+		Destination:  resolvedDestination,
+		Outgoing:     item.ConnectionInfo.IsOutgoing,
+		Request:      reqDetails,
+		Response:     resDetails,
+		RequestSize:  item.Pair.Request.CaptureSize,
+		ResponseSize: item.Pair.Response.CaptureSize,
+		Timestamp:    item.Timestamp,
+		StartTime:    item.Pair.Request.CaptureTime,
+		ElapsedTime:  elapsedTime,
+	}
+}
+
+func (d dissecting) Summarize(entry *api.Entry) *api.BaseEntry {
+	method := entry.Request["type"].(string)
+	methodQuery := fmt.Sprintf(`request.type == "%s"`, method)
+
+	summary := ""
+	summaryQuery := ""
+	command, _ := entry.Request["command"].(map[string]interface{})
+	if topic, ok := entry.Request["topic"].(string); ok {
+		summary = topic
+		summaryQuery = fmt.Sprintf(`request.topic == "%s"`, topic)
+	} else if namespace, ok := command["namespace"].(string); ok {
+		summary = namespace
+		summaryQuery = fmt.Sprintf(`request.command.namespace == "%s"`, namespace)
+	} else if clientVersion, ok := command["clientVersion"].(string); ok {
+		summary = clientVersion
+		summaryQuery = fmt.Sprintf(`request.command.clientVersion == "%s"`, clientVersion)
+	}
+
+	return &api.BaseEntry{
+		Id:           fmt.Sprintf("%s/%s-%d", entry.Worker, entry.Stream, entry.Index),
+		Stream:       entry.Stream,
+		Worker:       entry.Worker,
+		Protocol:     entry.Protocol,
+		Tls:          entry.Tls,
+		Summary:      summary,
+		SummaryQuery: summaryQuery,
+		Status:       0,
+		StatusQuery:  "",
+		Method:       method,
+		MethodQuery:  methodQuery,
+		Timestamp:    entry.Timestamp,
+		Source:       entry.Source,
+		Destination:  entry.Destination,
+		Outgoing:     entry.Outgoing,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		ElapsedTime:  entry.ElapsedTime,
+		Passed:       entry.Passed,
+		Failed:       entry.Failed,
+	}
+}
+
+func (d dissecting) Represent(request map[string]interface{}, response map[string]interface{}) (object []byte, err error) {
+	representation := make(map[string]interface{})
+	repRequest := representCommand(request, `request`)
+	repResponse := representCommand(response, `response`)
+	representation["request"] = repRequest
+	representation["response"] = repResponse
+	object, err = json.Marshal(representation)
+	return
+}
+
+func (d dissecting) Macros() map[string]string {
+	return map[string]string{
+		`pulsar`: fmt.Sprintf(`protocol.name == "%s"`, protocol.Name),
+	}
+}
+
+func (d dissecting) NewResponseRequestMatcher() api.RequestResponseMatcher {
+	return createResponseRequestMatcher()
+}
+
+var Dissector dissecting
+
+func NewDissector() api.Dissector {
+	return Dissector
+}
@@ -0,0 +1,236 @@
+package pulsar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/pierrec/lz4/v4"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "pulsar", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"pulsar": `protocol.name == "pulsar"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+// pb encodes the fields of a protobuf message, given as pairs of numbers
+// and values, which are int, uint64, bool, string or []byte.
+func pb(fields ...interface{}) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case int:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		case uint64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		case bool:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		}
+	}
+	return b
+}
+
+func sized(data []byte) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// frame encodes a BaseCommand, and the metadata and the payload of a
+// message that follow its checksum.
+func frame(commandType int, command []byte, metadataAndPayload ...[]byte) []byte {
+	data := sized(pb(1, commandType, commandType, command))
+	if len(metadataAndPayload) == 2 {
+		data = append(data, 0x0e, 0x01, 0, 0, 0, 0)
+		data = append(data, sized(metadataAndPayload[0])...)
+		data = append(data, metadataAndPayload[1]...)
+	}
+	return sized(data)
+}
+
+const (
+	ordersTopic = "persistent://public/default/orders"
+	eventsTopic = "persistent://public/default/events"
+)
+
+func dissect(t *testing.T, client []byte, server []byte) map[string]*api.Entry {
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissecttest.Entries(t, NewDissector(), dissecttest.ClientFirst, client, server) {
+		key := entry.Request["type"].(string)
+		if sequenceID, ok := entry.Request["command"].(map[string]interface{})["sequenceId"]; ok {
+			key += fmt.Sprintf("_%v", sequenceID)
+		}
+		entries[key] = entry
+	}
+	return entries
+}
+
+func TestDissect(t *testing.T) {
+	// A batch of two messages that's compressed with LZ4
+	batch := append(sized(pb(1, pb(1, "k", 2, "a"), 3, 5, 8, 1)), []byte("first")...)
+	batch = append(batch, sized(pb(3, 6, 8, 2))...)
+	batch = append(batch, []byte("second")...)
+	compressedBatch := make([]byte, lz4.CompressBlockBound(len(batch)))
+	n, err := lz4.CompressBlock(batch, compressedBatch, nil)
+	assert.Nil(t, err)
+	compressedBatch = compressedBatch[:n]
+
+	// A message that's compressed with ZSTD
+	encoder, err := zstd.NewWriter(nil)
+	assert.Nil(t, err)
+	event := []byte(`{"event":"created","event":"created","event":"created"}`)
+	compressedEvent := encoder.EncodeAll(event, nil)
+
+	client := bytes.Join([][]byte{
+		frame(typeConnect, pb(1, "Pulsar-Go-v0.9.0", 4, 19)),
+		frame(typeLookup, pb(1, ordersTopic, 2, 1)),
+		frame(typeProducer, pb(1, ordersTopic, 2, 0, 3, 2)),
+		frame(typeSend, pb(1, 0, 2, 0, 3, 1),
+			pb(1, "standalone-0-1", 2, 0, 3, 1700000000000, 4, pb(1, "trace", 2, "abc")),
+			[]byte("hello")),
+		frame(typeSend, pb(1, 0, 2, 1, 3, 2, 6, 2),
+			pb(1, "standalone-0-1", 2, 1, 3, 1700000000001, 8, compressionLZ4, 9, len(batch), 11, 2),
+			compressedBatch),
+		frame(typeSend, pb(1, 0, 2, 3, 3, 1), pb(1, "standalone-0-1", 2, 3, 3, 1700000000002), []byte("late")),
+		frame(typeSubscribe, pb(1, eventsTopic, 2, "audit", 3, 1, 4, 5, 5, 3)),
+		frame(typeFlow, pb(1, 5, 2, 1000)),
+		frame(typePing, nil),
+		frame(typeAck, pb(1, 5, 2, 0, 3, pb(1, 10, 2, 2))),
+		frame(typeUnsubscribe, pb(1, 6, 2, 4)),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		frame(typeConnected, pb(1, "Pulsar Server3.1.0", 2, 19, 3, 5242880)),
+		frame(typeLookupResponse, pb(1, "pulsar://broker-0:6650", 3, 1, 4, 1, 5, true)),
+		frame(typeProducerSuccess, pb(1, 2, 2, "standalone-0-1", 3, uint64(math.MaxUint64))),
+		frame(typeSendReceipt, pb(1, 0, 2, 0, 3, pb(1, 10, 2, 0))),
+		frame(typeSendReceipt, pb(1, 0, 2, 1, 3, pb(1, 10, 2, 1), 4, 2)),
+		frame(typeSendError, pb(1, 0, 2, 3, 3, 15, 4, "Topic was already terminated")),
+		frame(typeSuccess, pb(1, 3)),
+		frame(typePong, nil),
+		frame(typeMessage, pb(1, 5, 2, pb(1, 10, 2, 2), 3, 0),
+			pb(1, "other-producer", 2, 7, 3, 1700000000003, 8, compressionZstd, 9, len(event)),
+			compressedEvent),
+		frame(typeReachedEndOfTopic, pb(1, 5)),
+		frame(typeError, pb(1, 4, 2, 12, 3, "Subscription not found")),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 10)
+	dissector := NewDissector()
+
+	connect := entries["CONNECT"]
+	assert.Equal(t, "Pulsar-Go-v0.9.0", connect.Request["command"].(map[string]interface{})["clientVersion"])
+	assert.Equal(t, "CONNECTED", connect.Response["type"])
+	assert.Equal(t, float64(5242880), connect.Response["command"].(map[string]interface{})["maxMessageSize"])
+
+	lookup := entries["LOOKUP"]
+	assert.Equal(t, ordersTopic, lookup.Request["topic"])
+	assert.Equal(t, "Connect", lookup.Response["command"].(map[string]interface{})["response"])
+	summary := dissector.Summarize(lookup)
+	assert.Equal(t, "LOOKUP", summary.Method)
+	assert.Equal(t, ordersTopic, summary.Summary)
+	assert.Equal(t, `request.topic == "`+ordersTopic+`"`, summary.SummaryQuery)
+
+	producer := entries["PRODUCER"]
+	assert.Equal(t, "PRODUCER_SUCCESS", producer.Response["type"])
+	assert.Equal(t, float64(-1), producer.Response["command"].(map[string]interface{})["lastSequenceId"])
+
+	send := entries["SEND_0"]
+	assert.True(t, send.Outgoing)
+	assert.Equal(t, ordersTopic, send.Request["topic"])
+	assert.Equal(t, "abc", send.Request["metadata"].(map[string]interface{})["properties"].(map[string]interface{})["trace"])
+	assert.Equal(t, "hello", send.Request["payloadText"])
+	assert.Equal(t, "SEND_RECEIPT", send.Response["type"])
+	assert.Equal(t, float64(10), send.Response["command"].(map[string]interface{})["messageId"].(map[string]interface{})["ledgerId"])
+
+	batched := entries["SEND_1"]
+	assert.Equal(t, "LZ4", batched.Request["compression"])
+	assert.Equal(t, float64(len(batch)), batched.Request["payloadSize"])
+	messages := batched.Request["messages"].([]interface{})
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "first", messages[0].(map[string]interface{})["payloadText"])
+	assert.Equal(t, "a", messages[0].(map[string]interface{})["metadata"].(map[string]interface{})["properties"].(map[string]interface{})["k"])
+	assert.Equal(t, "second", messages[1].(map[string]interface{})["payloadText"])
+	assert.Equal(t, float64(2), messages[1].(map[string]interface{})["metadata"].(map[string]interface{})["sequenceId"])
+
+	failed := entries["SEND_3"]
+	assert.Equal(t, "SEND_ERROR", failed.Response["type"])
+	assert.Equal(t, "TopicTerminatedError", failed.Response["error"])
+
+	subscribe := entries["SUBSCRIBE"]
+	assert.Equal(t, "Shared", subscribe.Request["command"].(map[string]interface{})["subType"])
+	assert.Equal(t, "SUCCESS", subscribe.Response["type"])
+
+	message := entries["MESSAGE"]
+	assert.False(t, message.Outgoing)
+	assert.Equal(t, eventsTopic, message.Request["topic"])
+	assert.Equal(t, "ZSTD", message.Request["compression"])
+	assert.Equal(t, string(event), message.Request["payloadText"])
+	assert.Equal(t, "ACK", message.Response["type"])
+	assert.Equal(t, "Individual", message.Response["command"].(map[string]interface{})["ackType"])
+
+	end := entries["REACHED_END_OF_TOPIC"]
+	assert.Equal(t, eventsTopic, end.Request["topic"])
+	assert.Equal(t, "", end.Response["type"])
+
+	unsubscribe := entries["UNSUBSCRIBE"]
+	assert.Equal(t, "ERROR", unsubscribe.Response["type"])
+	assert.Equal(t, "SubscriptionNotFound", unsubscribe.Response["error"])
+
+	assert.NotContains(t, entries, "FLOW")
+	assert.NotContains(t, entries, "PING")
+}
+
+func TestDissectConnectError(t *testing.T) {
+	client := frame(typeConnect, pb(1, "Pulsar-Java-v3.1.0", 4, 19, 5, "token", 3, []byte("bad")))
+	server := frame(typeError, pb(1, uint64(math.MaxUint64), 2, 3, 3, "Unable to authenticate"))
+
+	entries := dissect(t, client, server)
+	connect := entries["CONNECT"]
+	assert.Equal(t, "token", connect.Request["command"].(map[string]interface{})["authMethodName"])
+	assert.Equal(t, "ERROR", connect.Response["type"])
+	assert.Equal(t, "AuthenticationError", connect.Response["error"])
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,123 @@
+package pulsar
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{key}` where the key is
+// `connect`, `auth`, `request_{request_id}`, `send_{producer_id}_{sequence_id}` or
+// `message_{consumer_id}_{ledger_id}_{entry_id}`. The topics of the producers and the consumers
+// are kept by the keys `producer_{producer_id}` and `consumer_{consumer_id}`.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+	topics          *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}, topics: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *PulsarCommand, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestPulsarMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: PulsarPayload{
+			Data: &PulsarWrapper{
+				Method:  request.Type,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responsePulsarMessage := response.(*api.GenericMessage)
+		if responsePulsarMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestPulsarMessage, responsePulsarMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestPulsarMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *PulsarCommand, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responsePulsarMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: PulsarPayload{
+			Data: &PulsarWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestPulsarMessage := request.(*api.GenericMessage)
+		if !requestPulsarMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestPulsarMessage, &responsePulsarMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responsePulsarMessage)
+	return nil
+}
+
+// prepareUnanswered pairs a command that isn't answered, like
+// REACHED_END_OF_TOPIC, with an empty response.
+func (matcher *requestResponseMatcher) prepareUnanswered(request *PulsarCommand, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestPulsarMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: PulsarPayload{
+			Data: &PulsarWrapper{
+				Method:  request.Type,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responsePulsarMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: PulsarPayload{
+			Data: &PulsarWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &PulsarCommand{Command: map[string]interface{}{}},
+			},
+		},
+	}
+	return matcher.preparePair(&requestPulsarMessage, &responsePulsarMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestPulsarMessage *api.GenericMessage, responsePulsarMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestPulsarMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestPulsarMessage,
+			Response: *responsePulsarMessage,
+		},
+	}
+}
@@ -0,0 +1,282 @@
+package pulsar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	maxFrameSize   = 16 * 1024 * 1024
+	maxPayloadSize = 16 * 1024 * 1024
+)
+
+// The magic numbers that precede the checksum and the broker entry metadata
+// of a payload
+const (
+	magicCrc32c              = 0x0e01
+	magicBrokerEntryMetadata = 0x0e02
+)
+
+var (
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+var errShortPayload = errors.New("payload is shorter than its declared fields")
+
+// readFrame reads a frame, which is a BaseCommand followed by the metadata
+// and the payload of a message for SEND and MESSAGE.
+func readFrame(b *bufio.Reader) (*PulsarCommand, error) {
+	var totalSize uint32
+	if err := binary.Read(b, binary.BigEndian, &totalSize); err != nil {
+		return nil, err
+	}
+	if totalSize < 4 || totalSize > maxFrameSize {
+		return nil, fmt.Errorf("invalid frame size: %d", totalSize)
+	}
+
+	data := make([]byte, totalSize)
+	if _, err := io.ReadFull(b, data); err != nil {
+		return nil, err
+	}
+	commandSize := binary.BigEndian.Uint32(data[:4])
+	if commandSize == 0 || commandSize > totalSize-4 {
+		return nil, fmt.Errorf("invalid command size: %d", commandSize)
+	}
+
+	command, err := readBaseCommand(data[4 : 4+commandSize])
+	if err != nil {
+		return nil, err
+	}
+	if payload := data[4+commandSize:]; len(payload) > 0 {
+		if command.code != typeSend && command.code != typeMessage {
+			return nil, fmt.Errorf("unexpected payload of %s", command.Type)
+		}
+		if err = command.readPayload(payload); err != nil {
+			return nil, err
+		}
+	}
+	return command, nil
+}
+
+// readBaseCommand decodes a BaseCommand, whose type comes first and is
+// also the number of the field of its command.
+func readBaseCommand(b []byte) (*PulsarCommand, error) {
+	num, typ, n := protowire.ConsumeTag(b)
+	if n < 0 || num != 1 || typ != protowire.VarintType {
+		return nil, errors.New("command doesn't start with its type")
+	}
+	b = b[n:]
+	code, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return nil, protowire.ParseError(n)
+	}
+	b = b[n:]
+	name, ok := commandTypes[code]
+	if !ok {
+		return nil, fmt.Errorf("unknown command type: %d", code)
+	}
+
+	command := &PulsarCommand{
+		Type:    name,
+		Command: make(map[string]interface{}),
+		code:    code,
+	}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if uint64(num) == code && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			fields, err := decodeMessage(v, commandMessages[code], 0)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			command.Command = fields
+			continue
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+
+	command.Topic, _ = command.Command["topic"].(string)
+	command.Error, _ = command.Command["error"].(string)
+	if command.Error == "" {
+		command.Error, _ = command.Command["errorCode"].(string)
+	}
+	return command, nil
+}
+
+// readPayload reads the broker entry metadata, the checksum, the metadata
+// and the payload of a message. A batch is split into its messages.
+func (c *PulsarCommand) readPayload(b []byte) (err error) {
+	if len(b) >= 2 && binary.BigEndian.Uint16(b) == magicBrokerEntryMetadata {
+		var data []byte
+		if data, b, err = readSized(b[2:]); err != nil {
+			return
+		}
+		if c.BrokerEntryMetadata, err = decodeMessage(data, brokerEntryMetadata, 0); err != nil {
+			return
+		}
+	}
+	if len(b) >= 2 && binary.BigEndian.Uint16(b) == magicCrc32c {
+		if len(b) < 6 {
+			return errShortPayload
+		}
+		b = b[6:]
+	}
+
+	var data []byte
+	if data, b, err = readSized(b); err != nil {
+		return
+	}
+	if c.Metadata, err = decodeMessage(data, messageMetadata, 0); err != nil {
+		return
+	}
+
+	payload := b
+	if compression, ok := c.Metadata["compression"].(string); ok && compression != compressionTypes[compressionNone] {
+		c.Compression = compression
+		size, _ := c.Metadata["uncompressedSize"].(uint64)
+		if payload, err = decompress(compression, b, size); err != nil {
+			// A payload that can't be decompressed is kept as it is
+			c.setPayload(b)
+			return nil
+		}
+	}
+
+	if count, ok := c.Metadata["numMessagesInBatch"].(int64); ok {
+		if c.Messages, err = readBatch(payload, count); err == nil {
+			c.PayloadSize = len(payload)
+			return nil
+		}
+	}
+	c.setPayload(payload)
+	return nil
+}
+
+func (c *PulsarCommand) setPayload(payload []byte) {
+	c.Payload, c.PayloadText, c.PayloadSize = encodePayload(payload)
+}
+
+// readBatch splits a batch into its messages, each preceded by its
+// SingleMessageMetadata.
+func readBatch(b []byte, count int64) ([]PulsarSingleMessage, error) {
+	if count < 0 || count > int64(len(b)/4) {
+		return nil, fmt.Errorf("invalid number of messages in batch: %d", count)
+	}
+
+	messages := make([]PulsarSingleMessage, 0, count)
+	for i := int64(0); i < count; i++ {
+		data, rest, err := readSized(b)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := decodeMessage(data, singleMessageMetadata, 0)
+		if err != nil {
+			return nil, err
+		}
+		size, ok := metadata["payloadSize"].(int64)
+		if !ok || size < 0 || size > int64(len(rest)) {
+			return nil, fmt.Errorf("invalid payload size: %d", size)
+		}
+
+		message := PulsarSingleMessage{Metadata: metadata}
+		message.Payload, message.PayloadText, message.PayloadSize = encodePayload(rest[:size])
+		messages = append(messages, message)
+		b = rest[size:]
+	}
+	return messages, nil
+}
+
+// readSized returns the bytes that are prefixed by their size, and the
+// ones that follow.
+func readSized(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errShortPayload
+	}
+	size := binary.BigEndian.Uint32(b)
+	if uint64(size) > uint64(len(b)-4) {
+		return nil, nil, errShortPayload
+	}
+	return b[4 : 4+size], b[4+size:], nil
+}
+
+// decompress decompresses a payload, which is a block for LZ4 and Snappy
+// since the size is given by the metadata.
+func decompress(compression string, b []byte, size uint64) (payload []byte, err error) {
+	if size > maxPayloadSize {
+		return nil, fmt.Errorf("invalid uncompressed size: %d", size)
+	}
+
+	switch compression {
+	case compressionTypes[compressionLZ4]:
+		payload = make([]byte, size)
+		var n int
+		if n, err = lz4.UncompressBlock(b, payload); err == nil {
+			payload = payload[:n]
+		}
+	case compressionTypes[compressionZlib]:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(b)); err == nil {
+			payload, err = io.ReadAll(io.LimitReader(zr, int64(size)))
+			zr.Close()
+		}
+	case compressionTypes[compressionZstd]:
+		zstdDecoderOnce.Do(func() {
+			zstdDecoder, zstdDecoderErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxPayloadSize))
+		})
+		if err = zstdDecoderErr; err == nil {
+			payload, err = zstdDecoder.DecodeAll(b, nil)
+		}
+	case compressionTypes[compressionSnappy]:
+		var n int
+		if n, err = snappy.DecodedLen(b); err == nil && uint64(n) != size {
+			err = errors.New("uncompressed size mismatch")
+		}
+		if err == nil {
+			payload, err = snappy.Decode(nil, b)
+		}
+	default:
+		err = fmt.Errorf("unknown compression: %s", compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(payload)) != size {
+		return nil, errors.New("uncompressed size mismatch")
+	}
+	return payload, nil
+}
+
+// encodePayload returns the payload in base64, and as text if it's valid
+// UTF-8.
+func encodePayload(payload []byte) (encoded string, text string, size int) {
+	encoded = base64.StdEncoding.EncodeToString(payload)
+	if utf8.Valid(payload) {
+		text = string(payload)
+	}
+	return encoded, text, len(payload)
+}
@@ -0,0 +1,754 @@
+package pulsar
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of PulsarApi.proto are decoded by the numbers of their
+// fields, since there's no generated code for them. The fields that aren't
+// listed are skipped.
+
+type kind int
+
+const (
+	kindUint kind = iota
+	kindInt
+	kindBool
+	kindDouble
+	kindString
+	kindBytes
+	kindEnum
+	kindMessage
+	// A repeated KeyValue or KeyLongValue, which is decoded to a map
+	kindKeyValues
+)
+
+type field struct {
+	name     string
+	kind     kind
+	repeated bool
+	enum     map[uint64]string
+	message  messageType
+}
+
+type messageType map[protowire.Number]field
+
+const maxDepth = 16
+
+const (
+	typeConnect                      = 2
+	typeConnected                    = 3
+	typeSubscribe                    = 4
+	typeProducer                     = 5
+	typeSend                         = 6
+	typeSendReceipt                  = 7
+	typeSendError                    = 8
+	typeMessage                      = 9
+	typeAck                          = 10
+	typeFlow                         = 11
+	typeUnsubscribe                  = 12
+	typeSuccess                      = 13
+	typeError                        = 14
+	typeCloseProducer                = 15
+	typeCloseConsumer                = 16
+	typeProducerSuccess              = 17
+	typePing                         = 18
+	typePong                         = 19
+	typeRedeliverUnacknowledged      = 20
+	typePartitionedMetadata          = 21
+	typePartitionedMetadataResponse  = 22
+	typeLookup                       = 23
+	typeLookupResponse               = 24
+	typeConsumerStats                = 25
+	typeConsumerStatsResponse        = 26
+	typeReachedEndOfTopic            = 27
+	typeSeek                         = 28
+	typeGetLastMessageID             = 29
+	typeGetLastMessageIDResponse     = 30
+	typeActiveConsumerChange         = 31
+	typeGetTopicsOfNamespace         = 32
+	typeGetTopicsOfNamespaceResponse = 33
+	typeGetSchema                    = 34
+	typeGetSchemaResponse            = 35
+	typeAuthChallenge                = 36
+	typeAuthResponse                 = 37
+	typeAckResponse                  = 38
+	typeGetOrCreateSchema            = 39
+	typeGetOrCreateSchemaResponse    = 40
+	typeNewTxn                       = 50
+	typeNewTxnResponse               = 51
+	typeAddPartitionToTxn            = 52
+	typeAddPartitionToTxnResponse    = 53
+	typeAddSubscriptionToTxn         = 54
+	typeAddSubscriptionToTxnResponse = 55
+	typeEndTxn                       = 56
+	typeEndTxnResponse               = 57
+	typeEndTxnOnPartition            = 58
+	typeEndTxnOnPartitionResponse    = 59
+	typeEndTxnOnSubscription         = 60
+	typeEndTxnOnSubscriptionResponse = 61
+	typeTcClientConnectRequest       = 62
+	typeTcClientConnectResponse      = 63
+	typeWatchTopicList               = 64
+	typeWatchTopicListSuccess        = 65
+	typeWatchTopicUpdate             = 66
+	typeWatchTopicListClose          = 67
+	typeTopicMigrated                = 68
+)
+
+// The names of BaseCommand.Type, whose values are also the numbers of the
+// fields of the commands within a BaseCommand.
+var commandTypes = map[uint64]string{
+	typeConnect:                      "CONNECT",
+	typeConnected:                    "CONNECTED",
+	typeSubscribe:                    "SUBSCRIBE",
+	typeProducer:                     "PRODUCER",
+	typeSend:                         "SEND",
+	typeSendReceipt:                  "SEND_RECEIPT",
+	typeSendError:                    "SEND_ERROR",
+	typeMessage:                      "MESSAGE",
+	typeAck:                          "ACK",
+	typeFlow:                         "FLOW",
+	typeUnsubscribe:                  "UNSUBSCRIBE",
+	typeSuccess:                      "SUCCESS",
+	typeError:                        "ERROR",
+	typeCloseProducer:                "CLOSE_PRODUCER",
+	typeCloseConsumer:                "CLOSE_CONSUMER",
+	typeProducerSuccess:              "PRODUCER_SUCCESS",
+	typePing:                         "PING",
+	typePong:                         "PONG",
+	typeRedeliverUnacknowledged:      "REDELIVER_UNACKNOWLEDGED_MESSAGES",
+	typePartitionedMetadata:          "PARTITIONED_METADATA",
+	typePartitionedMetadataResponse:  "PARTITIONED_METADATA_RESPONSE",
+	typeLookup:                       "LOOKUP",
+	typeLookupResponse:               "LOOKUP_RESPONSE",
+	typeConsumerStats:                "CONSUMER_STATS",
+	typeConsumerStatsResponse:        "CONSUMER_STATS_RESPONSE",
+	typeReachedEndOfTopic:            "REACHED_END_OF_TOPIC",
+	typeSeek:                         "SEEK",
+	typeGetLastMessageID:             "GET_LAST_MESSAGE_ID",
+	typeGetLastMessageIDResponse:     "GET_LAST_MESSAGE_ID_RESPONSE",
+	typeActiveConsumerChange:         "ACTIVE_CONSUMER_CHANGE",
+	typeGetTopicsOfNamespace:         "GET_TOPICS_OF_NAMESPACE",
+	typeGetTopicsOfNamespaceResponse: "GET_TOPICS_OF_NAMESPACE_RESPONSE",
+	typeGetSchema:                    "GET_SCHEMA",
+	typeGetSchemaResponse:            "GET_SCHEMA_RESPONSE",
+	typeAuthChallenge:                "AUTH_CHALLENGE",
+	typeAuthResponse:                 "AUTH_RESPONSE",
+	typeAckResponse:                  "ACK_RESPONSE",
+	typeGetOrCreateSchema:            "GET_OR_CREATE_SCHEMA",
+	typeGetOrCreateSchemaResponse:    "GET_OR_CREATE_SCHEMA_RESPONSE",
+	typeNewTxn:                       "NEW_TXN",
+	typeNewTxnResponse:               "NEW_TXN_RESPONSE",
+	typeAddPartitionToTxn:            "ADD_PARTITION_TO_TXN",
+	typeAddPartitionToTxnResponse:    "ADD_PARTITION_TO_TXN_RESPONSE",
+	typeAddSubscriptionToTxn:         "ADD_SUBSCRIPTION_TO_TXN",
+	typeAddSubscriptionToTxnResponse: "ADD_SUBSCRIPTION_TO_TXN_RESPONSE",
+	typeEndTxn:                       "END_TXN",
+	typeEndTxnResponse:               "END_TXN_RESPONSE",
+	typeEndTxnOnPartition:            "END_TXN_ON_PARTITION",
+	typeEndTxnOnPartitionResponse:    "END_TXN_ON_PARTITION_RESPONSE",
+	typeEndTxnOnSubscription:         "END_TXN_ON_SUBSCRIPTION",
+	typeEndTxnOnSubscriptionResponse: "END_TXN_ON_SUBSCRIPTION_RESPONSE",
+	typeTcClientConnectRequest:       "TC_CLIENT_CONNECT_REQUEST",
+	typeTcClientConnectResponse:      "TC_CLIENT_CONNECT_RESPONSE",
+	typeWatchTopicList:               "WATCH_TOPIC_LIST",
+	typeWatchTopicListSuccess:        "WATCH_TOPIC_LIST_SUCCESS",
+	typeWatchTopicUpdate:             "WATCH_TOPIC_UPDATE",
+	typeWatchTopicListClose:          "WATCH_TOPIC_LIST_CLOSE",
+	typeTopicMigrated:                "TOPIC_MIGRATED",
+}
+
+var serverErrors = map[uint64]string{
+	0:  "UnknownError",
+	1:  "MetadataError",
+	2:  "PersistenceError",
+	3:  "AuthenticationError",
+	4:  "AuthorizationError",
+	5:  "ConsumerBusy",
+	6:  "ServiceNotReady",
+	7:  "ProducerBlockedQuotaExceededError",
+	8:  "ProducerBlockedQuotaExceededException",
+	9:  "ChecksumError",
+	10: "UnsupportedVersionError",
+	11: "TopicNotFound",
+	12: "SubscriptionNotFound",
+	13: "ConsumerNotFound",
+	14: "TooManyRequests",
+	15: "TopicTerminatedError",
+	16: "ProducerBusy",
+	17: "InvalidTopicName",
+	18: "IncompatibleSchema",
+	19: "ConsumerAssignError",
+	20: "TransactionCoordinatorNotFound",
+	21: "InvalidTxnStatus",
+	22: "NotAllowedError",
+	23: "TransactionConflict",
+	24: "TransactionNotFound",
+	25: "ProducerFenced",
+}
+
+const (
+	compressionNone   = 0
+	compressionLZ4    = 1
+	compressionZlib   = 2
+	compressionZstd   = 3
+	compressionSnappy = 4
+)
+
+var compressionTypes = map[uint64]string{
+	compressionNone:   "NONE",
+	compressionLZ4:    "LZ4",
+	compressionZlib:   "ZLIB",
+	compressionZstd:   "ZSTD",
+	compressionSnappy: "SNAPPY",
+}
+
+var schemaTypes = map[uint64]string{
+	0: "None", 1: "String", 2: "Json", 3: "Protobuf", 4: "Avro", 5: "Bool", 6: "Int8", 7: "Int16",
+	8: "Int32", 9: "Int64", 10: "Float", 11: "Double", 12: "Date", 13: "Time", 14: "Timestamp",
+	15: "KeyValue", 16: "Instant", 17: "LocalDate", 18: "LocalTime", 19: "LocalDateTime",
+	20: "ProtobufNative",
+}
+
+var (
+	messageIDData = messageType{
+		1: {name: "ledgerId", kind: kindUint},
+		2: {name: "entryId", kind: kindUint},
+		3: {name: "partition", kind: kindInt},
+		4: {name: "batchIndex", kind: kindInt},
+		5: {name: "ackSet", kind: kindInt, repeated: true},
+		6: {name: "batchSize", kind: kindInt},
+	}
+
+	schema = messageType{
+		1: {name: "name", kind: kindString},
+		3: {name: "schemaData", kind: kindBytes},
+		4: {name: "type", kind: kindEnum, enum: schemaTypes},
+		5: {name: "properties", kind: kindKeyValues},
+	}
+
+	featureFlags = messageType{
+		1: {name: "supportsAuthRefresh", kind: kindBool},
+		2: {name: "supportsBrokerEntryMetadata", kind: kindBool},
+		3: {name: "supportsPartialProducer", kind: kindBool},
+		4: {name: "supportsTopicWatchers", kind: kindBool},
+	}
+
+	serverError = field{name: "error", kind: kindEnum, enum: serverErrors}
+)
+
+// The commands by their type
+var commandMessages = map[uint64]messageType{
+	typeConnect: {
+		1:  {name: "clientVersion", kind: kindString},
+		2:  {name: "authMethod", kind: kindEnum, enum: map[uint64]string{0: "AuthMethodNone", 1: "AuthMethodYcaV1", 2: "AuthMethodAthens"}},
+		3:  {name: "authData", kind: kindBytes},
+		4:  {name: "protocolVersion", kind: kindInt},
+		5:  {name: "authMethodName", kind: kindString},
+		6:  {name: "proxyToBrokerUrl", kind: kindString},
+		7:  {name: "originalPrincipal", kind: kindString},
+		9:  {name: "originalAuthMethod", kind: kindString},
+		10: {name: "featureFlags", kind: kindMessage, message: featureFlags},
+	},
+	typeConnected: {
+		1: {name: "serverVersion", kind: kindString},
+		2: {name: "protocolVersion", kind: kindInt},
+		3: {name: "maxMessageSize", kind: kindInt},
+		4: {name: "featureFlags", kind: kindMessage, message: featureFlags},
+	},
+	typeAuthChallenge: {
+		1: {name: "serverVersion", kind: kindString},
+		2: {name: "challenge", kind: kindMessage, message: messageType{1: {name: "authMethodName", kind: kindString}, 2: {name: "authData", kind: kindBytes}}},
+		3: {name: "protocolVersion", kind: kindInt},
+	},
+	typeAuthResponse: {
+		1: {name: "clientVersion", kind: kindString},
+		2: {name: "response", kind: kindMessage, message: messageType{1: {name: "authMethodName", kind: kindString}, 2: {name: "authData", kind: kindBytes}}},
+		3: {name: "protocolVersion", kind: kindInt},
+	},
+	typeSubscribe: {
+		1:  {name: "topic", kind: kindString},
+		2:  {name: "subscription", kind: kindString},
+		3:  {name: "subType", kind: kindEnum, enum: map[uint64]string{0: "Exclusive", 1: "Shared", 2: "Failover", 3: "Key_Shared"}},
+		4:  {name: "consumerId", kind: kindUint},
+		5:  {name: "requestId", kind: kindUint},
+		6:  {name: "consumerName", kind: kindString},
+		7:  {name: "priorityLevel", kind: kindInt},
+		8:  {name: "durable", kind: kindBool},
+		9:  {name: "startMessageId", kind: kindMessage, message: messageIDData},
+		10: {name: "metadata", kind: kindKeyValues},
+		11: {name: "readCompacted", kind: kindBool},
+		12: {name: "schema", kind: kindMessage, message: schema},
+		13: {name: "initialPosition", kind: kindEnum, enum: map[uint64]string{0: "Latest", 1: "Earliest"}},
+		14: {name: "replicateSubscriptionState", kind: kindBool},
+		15: {name: "forceTopicCreation", kind: kindBool},
+		16: {name: "startMessageRollbackDurationSec", kind: kindUint},
+		18: {name: "subscriptionProperties", kind: kindKeyValues},
+		19: {name: "consumerEpoch", kind: kindUint},
+	},
+	typeProducer: {
+		1:  {name: "topic", kind: kindString},
+		2:  {name: "producerId", kind: kindUint},
+		3:  {name: "requestId", kind: kindUint},
+		4:  {name: "producerName", kind: kindString},
+		5:  {name: "encrypted", kind: kindBool},
+		6:  {name: "metadata", kind: kindKeyValues},
+		7:  {name: "schema", kind: kindMessage, message: schema},
+		8:  {name: "epoch", kind: kindUint},
+		9:  {name: "userProvidedProducerName", kind: kindBool},
+		10: {name: "producerAccessMode", kind: kindEnum, enum: map[uint64]string{0: "Shared", 1: "Exclusive", 2: "WaitForExclusive", 3: "ExclusiveWithFencing"}},
+		11: {name: "topicEpoch", kind: kindUint},
+		12: {name: "txnEnabled", kind: kindBool},
+		13: {name: "initialSubscriptionName", kind: kindString},
+	},
+	typeProducerSuccess: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "producerName", kind: kindString},
+		3: {name: "lastSequenceId", kind: kindInt},
+		4: {name: "schemaVersion", kind: kindBytes},
+		5: {name: "topicEpoch", kind: kindUint},
+		6: {name: "producerReady", kind: kindBool},
+	},
+	typeSend: {
+		1: {name: "producerId", kind: kindUint},
+		2: {name: "sequenceId", kind: kindUint},
+		3: {name: "numMessages", kind: kindInt},
+		4: {name: "txnidLeastBits", kind: kindUint},
+		5: {name: "txnidMostBits", kind: kindUint},
+		6: {name: "highestSequenceId", kind: kindUint},
+		7: {name: "isChunk", kind: kindBool},
+		8: {name: "marker", kind: kindBool},
+		9: {name: "messageId", kind: kindMessage, message: messageIDData},
+	},
+	typeSendReceipt: {
+		1: {name: "producerId", kind: kindUint},
+		2: {name: "sequenceId", kind: kindUint},
+		3: {name: "messageId", kind: kindMessage, message: messageIDData},
+		4: {name: "highestSequenceId", kind: kindUint},
+	},
+	typeSendError: {
+		1: {name: "producerId", kind: kindUint},
+		2: {name: "sequenceId", kind: kindUint},
+		3: serverError,
+		4: {name: "message", kind: kindString},
+	},
+	typeMessage: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "messageId", kind: kindMessage, message: messageIDData},
+		3: {name: "redeliveryCount", kind: kindUint},
+		4: {name: "ackSet", kind: kindInt, repeated: true},
+		5: {name: "consumerEpoch", kind: kindUint},
+	},
+	typeAck: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "ackType", kind: kindEnum, enum: map[uint64]string{0: "Individual", 1: "Cumulative"}},
+		3: {name: "messageId", kind: kindMessage, message: messageIDData, repeated: true},
+		4: {name: "validationError", kind: kindEnum, enum: map[uint64]string{
+			0: "UncompressedSizeCorruption", 1: "DecompressionError", 2: "ChecksumMismatch",
+			3: "BatchDeSerializeError", 4: "DecryptionError",
+		}},
+		5: {name: "properties", kind: kindKeyValues},
+		6: {name: "txnidLeastBits", kind: kindUint},
+		7: {name: "txnidMostBits", kind: kindUint},
+		8: {name: "requestId", kind: kindUint},
+	},
+	typeAckResponse: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "txnidLeastBits", kind: kindUint},
+		3: {name: "txnidMostBits", kind: kindUint},
+		4: serverError,
+		5: {name: "message", kind: kindString},
+		6: {name: "requestId", kind: kindUint},
+	},
+	typeFlow: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "messagePermits", kind: kindUint},
+	},
+	typeUnsubscribe: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "force", kind: kindBool},
+	},
+	typeSuccess: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "schema", kind: kindMessage, message: schema},
+	},
+	typeError: {
+		1: {name: "requestId", kind: kindUint},
+		2: serverError,
+		3: {name: "message", kind: kindString},
+	},
+	typeCloseProducer: {
+		1: {name: "producerId", kind: kindUint},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "assignedBrokerServiceUrl", kind: kindString},
+	},
+	typeCloseConsumer: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "assignedBrokerServiceUrl", kind: kindString},
+	},
+	typeRedeliverUnacknowledged: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "messageIds", kind: kindMessage, message: messageIDData, repeated: true},
+		3: {name: "consumerEpoch", kind: kindUint},
+	},
+	typePartitionedMetadata: {
+		1: {name: "topic", kind: kindString},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "originalPrincipal", kind: kindString},
+		5: {name: "originalAuthMethod", kind: kindString},
+	},
+	typePartitionedMetadataResponse: {
+		1: {name: "partitions", kind: kindUint},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "response", kind: kindEnum, enum: map[uint64]string{0: "Success", 1: "Failed"}},
+		4: serverError,
+		5: {name: "message", kind: kindString},
+	},
+	typeLookup: {
+		1: {name: "topic", kind: kindString},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "authoritative", kind: kindBool},
+		4: {name: "originalPrincipal", kind: kindString},
+		6: {name: "originalAuthMethod", kind: kindString},
+		7: {name: "advertisedListenerName", kind: kindString},
+	},
+	typeLookupResponse: {
+		1: {name: "brokerServiceUrl", kind: kindString},
+		2: {name: "brokerServiceUrlTls", kind: kindString},
+		3: {name: "response", kind: kindEnum, enum: map[uint64]string{0: "Redirect", 1: "Connect", 2: "Failed"}},
+		4: {name: "requestId", kind: kindUint},
+		5: {name: "authoritative", kind: kindBool},
+		6: serverError,
+		7: {name: "message", kind: kindString},
+		8: {name: "proxyThroughServiceUrl", kind: kindBool},
+	},
+	typeConsumerStats: {
+		1: {name: "requestId", kind: kindUint},
+		4: {name: "consumerId", kind: kindUint},
+	},
+	typeConsumerStatsResponse: {
+		1:  {name: "requestId", kind: kindUint},
+		2:  {name: "errorCode", kind: kindEnum, enum: serverErrors},
+		3:  {name: "errorMessage", kind: kindString},
+		4:  {name: "msgRateOut", kind: kindDouble},
+		5:  {name: "msgThroughputOut", kind: kindDouble},
+		6:  {name: "msgRateRedeliver", kind: kindDouble},
+		7:  {name: "consumerName", kind: kindString},
+		8:  {name: "availablePermits", kind: kindUint},
+		9:  {name: "unackedMessages", kind: kindUint},
+		10: {name: "blockedConsumerOnUnackedMsgs", kind: kindBool},
+		11: {name: "address", kind: kindString},
+		12: {name: "connectedSince", kind: kindString},
+		13: {name: "type", kind: kindString},
+		14: {name: "msgRateExpired", kind: kindDouble},
+		15: {name: "msgBacklog", kind: kindUint},
+		16: {name: "messageAckRate", kind: kindDouble},
+	},
+	typeReachedEndOfTopic: {
+		1: {name: "consumerId", kind: kindUint},
+	},
+	typeActiveConsumerChange: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "isActive", kind: kindBool},
+	},
+	typeSeek: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "messageId", kind: kindMessage, message: messageIDData},
+		4: {name: "messagePublishTime", kind: kindUint},
+	},
+	typeGetLastMessageID: {
+		1: {name: "consumerId", kind: kindUint},
+		2: {name: "requestId", kind: kindUint},
+	},
+	typeGetLastMessageIDResponse: {
+		1: {name: "lastMessageId", kind: kindMessage, message: messageIDData},
+		2: {name: "requestId", kind: kindUint},
+		3: {name: "consumerMarkDeletePosition", kind: kindMessage, message: messageIDData},
+	},
+	typeGetTopicsOfNamespace: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "namespace", kind: kindString},
+		3: {name: "mode", kind: kindEnum, enum: map[uint64]string{0: "PERSISTENT", 1: "NON_PERSISTENT", 2: "ALL"}},
+		4: {name: "topicsPattern", kind: kindString},
+		5: {name: "topicsHash", kind: kindString},
+	},
+	typeGetTopicsOfNamespaceResponse: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "topics", kind: kindString, repeated: true},
+		3: {name: "filtered", kind: kindBool},
+		4: {name: "topicsHash", kind: kindString},
+		5: {name: "changed", kind: kindBool},
+	},
+	typeGetSchema: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "topic", kind: kindString},
+		3: {name: "schemaVersion", kind: kindBytes},
+	},
+	typeGetSchemaResponse: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "errorCode", kind: kindEnum, enum: serverErrors},
+		3: {name: "errorMessage", kind: kindString},
+		4: {name: "schema", kind: kindMessage, message: schema},
+		5: {name: "schemaVersion", kind: kindBytes},
+	},
+	typeGetOrCreateSchema: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "topic", kind: kindString},
+		3: {name: "schema", kind: kindMessage, message: schema},
+	},
+	typeGetOrCreateSchemaResponse: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "errorCode", kind: kindEnum, enum: serverErrors},
+		3: {name: "errorMessage", kind: kindString},
+		4: {name: "schemaVersion", kind: kindBytes},
+	},
+	typeWatchTopicList: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "watcherId", kind: kindUint},
+		3: {name: "namespace", kind: kindString},
+		4: {name: "topicsPattern", kind: kindString},
+		5: {name: "topicsHash", kind: kindString},
+	},
+	typeWatchTopicListSuccess: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "watcherId", kind: kindUint},
+		3: {name: "topic", kind: kindString, repeated: true},
+		4: {name: "topicsHash", kind: kindString},
+	},
+	typeWatchTopicUpdate: {
+		1: {name: "watcherId", kind: kindUint},
+		2: {name: "newTopics", kind: kindString, repeated: true},
+		3: {name: "deletedTopics", kind: kindString, repeated: true},
+		4: {name: "topicsHash", kind: kindString},
+	},
+	typeWatchTopicListClose: {
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "watcherId", kind: kindUint},
+	},
+}
+
+// The requests and the responses of transactions share their ids
+func init() {
+	txnRequest := messageType{
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "txnidLeastBits", kind: kindUint},
+		3: {name: "txnidMostBits", kind: kindUint},
+	}
+	txnResponse := messageType{
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "txnidLeastBits", kind: kindUint},
+		3: {name: "txnidMostBits", kind: kindUint},
+		4: serverError,
+		5: {name: "message", kind: kindString},
+	}
+	for _, t := range []uint64{typeAddPartitionToTxn, typeAddSubscriptionToTxn, typeEndTxn, typeEndTxnOnPartition, typeEndTxnOnSubscription} {
+		commandMessages[t] = txnRequest
+		commandMessages[t+1] = txnResponse
+	}
+	commandMessages[typeNewTxn] = messageType{
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "txnTtlSeconds", kind: kindUint},
+		3: {name: "tcId", kind: kindUint},
+	}
+	commandMessages[typeNewTxnResponse] = txnResponse
+	commandMessages[typeTcClientConnectRequest] = messageType{
+		1: {name: "requestId", kind: kindUint},
+		2: {name: "tcId", kind: kindUint},
+	}
+	commandMessages[typeTcClientConnectResponse] = messageType{
+		1: {name: "requestId", kind: kindUint},
+		2: serverError,
+		3: {name: "message", kind: kindString},
+	}
+}
+
+var messageMetadata = messageType{
+	1:  {name: "producerName", kind: kindString},
+	2:  {name: "sequenceId", kind: kindUint},
+	3:  {name: "publishTime", kind: kindUint},
+	4:  {name: "properties", kind: kindKeyValues},
+	5:  {name: "replicatedFrom", kind: kindString},
+	6:  {name: "partitionKey", kind: kindString},
+	7:  {name: "replicateTo", kind: kindString, repeated: true},
+	8:  {name: "compression", kind: kindEnum, enum: compressionTypes},
+	9:  {name: "uncompressedSize", kind: kindUint},
+	11: {name: "numMessagesInBatch", kind: kindInt},
+	12: {name: "eventTime", kind: kindUint},
+	14: {name: "encryptionAlgo", kind: kindString},
+	16: {name: "schemaVersion", kind: kindBytes},
+	17: {name: "partitionKeyB64Encoded", kind: kindBool},
+	18: {name: "orderingKey", kind: kindBytes},
+	19: {name: "deliverAtTime", kind: kindInt},
+	20: {name: "markerType", kind: kindInt},
+	22: {name: "txnidLeastBits", kind: kindUint},
+	23: {name: "txnidMostBits", kind: kindUint},
+	24: {name: "highestSequenceId", kind: kindUint},
+	25: {name: "nullValue", kind: kindBool},
+	26: {name: "uuid", kind: kindString},
+	27: {name: "numChunksFromMsg", kind: kindInt},
+	28: {name: "totalChunkMsgSize", kind: kindInt},
+	29: {name: "chunkId", kind: kindInt},
+	30: {name: "nullPartitionKey", kind: kindBool},
+}
+
+var singleMessageMetadata = messageType{
+	1:  {name: "properties", kind: kindKeyValues},
+	2:  {name: "partitionKey", kind: kindString},
+	3:  {name: "payloadSize", kind: kindInt},
+	4:  {name: "compactedOut", kind: kindBool},
+	5:  {name: "eventTime", kind: kindUint},
+	6:  {name: "partitionKeyB64Encoded", kind: kindBool},
+	7:  {name: "orderingKey", kind: kindBytes},
+	8:  {name: "sequenceId", kind: kindUint},
+	9:  {name: "nullValue", kind: kindBool},
+	10: {name: "nullPartitionKey", kind: kindBool},
+}
+
+var brokerEntryMetadata = messageType{
+	1: {name: "brokerTimestamp", kind: kindUint},
+	2: {name: "index", kind: kindUint},
+}
+
+var keyValue = messageType{
+	1: {name: "key", kind: kindString},
+	2: {name: "value", kind: kindString},
+}
+
+var keyLongValue = messageType{
+	1: {name: "key", kind: kindString},
+	2: {name: "value", kind: kindUint},
+}
+
+// decodeMessage decodes a message to a map of its fields. Bytes are given in
+// base64, and the enums by their names.
+func decodeMessage(b []byte, t messageType, depth int) (map[string]interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("message is nested too deep")
+	}
+
+	fields := make(map[string]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f, ok := t[num]
+		if !ok {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+
+		var value interface{}
+		var err error
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			if v, n = protowire.ConsumeVarint(b); n >= 0 {
+				value, err = f.decodeVarint(v)
+			}
+		case protowire.Fixed64Type:
+			var v uint64
+			if v, n = protowire.ConsumeFixed64(b); n >= 0 {
+				if f.kind != kindDouble {
+					err = fmt.Errorf("unexpected wire type of %s: %d", f.name, typ)
+				}
+				value = math.Float64frombits(v)
+			}
+		case protowire.BytesType:
+			var v []byte
+			if v, n = protowire.ConsumeBytes(b); n >= 0 {
+				if f.kind == kindKeyValues {
+					err = decodeKeyValue(v, fields, f.name, depth)
+					b = b[n:]
+					if err != nil {
+						return nil, err
+					}
+					continue
+				}
+				value, err = f.decodeBytes(v, depth)
+			}
+		default:
+			err = fmt.Errorf("unexpected wire type of %s: %d", f.name, typ)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+
+		if f.repeated {
+			list, _ := fields[f.name].([]interface{})
+			fields[f.name] = append(list, value)
+		} else {
+			fields[f.name] = value
+		}
+	}
+	return fields, nil
+}
+
+func (f field) decodeVarint(v uint64) (interface{}, error) {
+	switch f.kind {
+	case kindUint:
+		return v, nil
+	case kindInt:
+		return int64(v), nil
+	case kindBool:
+		return v != 0, nil
+	case kindEnum:
+		if name, ok := f.enum[v]; ok {
+			return name, nil
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unexpected wire type of %s: varint", f.name)
+}
+
+func (f field) decodeBytes(v []byte, depth int) (interface{}, error) {
+	switch f.kind {
+	case kindString:
+		if !utf8.Valid(v) {
+			return nil, fmt.Errorf("invalid string in %s", f.name)
+		}
+		return string(v), nil
+	case kindBytes:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case kindMessage:
+		return decodeMessage(v, f.message, depth+1)
+	}
+	return nil, fmt.Errorf("unexpected wire type of %s: bytes", f.name)
+}
+
+// decodeKeyValue adds a KeyValue, or a KeyLongValue, to the map of a field.
+func decodeKeyValue(v []byte, fields map[string]interface{}, name string, depth int) error {
+	kv, err := decodeMessage(v, keyValue, depth+1)
+	if err != nil {
+		if kv, err = decodeMessage(v, keyLongValue, depth+1); err != nil {
+			return err
+		}
+	}
+	m, _ := fields[name].(map[string]interface{})
+	if m == nil {
+		m = make(map[string]interface{})
+		fields[name] = m
+	}
+	key, _ := kv["key"].(string)
+	m[key] = kv["value"]
+	return nil
+}
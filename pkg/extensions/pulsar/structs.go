@@ -0,0 +1,53 @@
+package pulsar
+
+import (
+	"encoding/json"
+)
+
+type PulsarPayload struct {
+	Data interface{}
+}
+
+type PulsarPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h PulsarPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type PulsarWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+// PulsarCommand is a BaseCommand with the fields of its command. The topic
+// is the one of the command, or of its producer or consumer. SEND and
+// MESSAGE also carry the metadata of a message, and either its payload or
+// the messages of a batch. A payload is base64 encoded and also given as
+// text when it's valid UTF-8.
+type PulsarCommand struct {
+	Type                string                 `json:"type"`
+	Command             map[string]interface{} `json:"command"`
+	Topic               string                 `json:"topic,omitempty"`
+	Error               string                 `json:"error,omitempty"`
+	BrokerEntryMetadata map[string]interface{} `json:"brokerEntryMetadata,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	Compression         string                 `json:"compression,omitempty"`
+	Payload             string                 `json:"payload,omitempty"`
+	PayloadText         string                 `json:"payloadText,omitempty"`
+	PayloadSize         int                    `json:"payloadSize,omitempty"`
+	Messages            []PulsarSingleMessage  `json:"messages,omitempty"`
+
+	code       uint64
+	fromClient bool
+}
+
+// PulsarSingleMessage is a message of a batch.
+type PulsarSingleMessage struct {
+	Metadata    map[string]interface{} `json:"metadata"`
+	Payload     string                 `json:"payload"`
+	PayloadText string                 `json:"payloadText,omitempty"`
+	PayloadSize int                    `json:"payloadSize"`
+}
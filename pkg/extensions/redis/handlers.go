@@ -2,12 +2,73 @@ package redis
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/kubeshark/worker/pkg/api"
 )
 
-func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, request *RedisPacket, reqResMatcher *requestResponseMatcher) error {
+// subscriptions are the channels that a client is subscribed to, by the
+// command that subscribed to them.
+type subscriptions map[RedisCommand]map[string]bool
+
+// split splits a command that is confirmed for each of its channels into a
+// request per channel. Unsubscribing from all the channels is confirmed for
+// each subscribed one, or once if there's none.
+func (s subscriptions) split(request *RedisPacket) []*RedisPacket {
+	if request.Command == "RESET" {
+		for kind := range s {
+			delete(s, kind)
+		}
+	}
+	if !isValidRedisCommand(subscriptionCommands, request.Command) {
+		return []*RedisPacket{request}
+	}
+
+	kind := RedisCommand(strings.Replace(string(request.Command), "UNSUBSCRIBE", "SUBSCRIBE", 1))
+	if s[kind] == nil {
+		s[kind] = make(map[string]bool)
+	}
+	channels := request.arguments
+	if kind == request.Command {
+		for _, channel := range channels {
+			s[kind][channel] = true
+		}
+	} else {
+		if len(channels) == 0 {
+			for channel := range s[kind] {
+				channels = append(channels, channel)
+			}
+			sort.Strings(channels)
+		}
+		for _, channel := range channels {
+			delete(s[kind], channel)
+		}
+	}
+
+	if len(channels) <= 1 && len(channels) == len(request.arguments) {
+		return []*RedisPacket{request}
+	}
+	requests := make([]*RedisPacket, 0, len(channels))
+	for _, channel := range channels {
+		requests = append(requests, &RedisPacket{
+			Type:    request.Type,
+			Command: request.Command,
+			Key:     channel,
+		})
+	}
+	return requests
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, request *RedisPacket, reqResMatcher *requestResponseMatcher, subscriptions subscriptions) error {
+	for _, request := range subscriptions.split(request) {
+		handleRequest(progress, tcpID, counterPair, captureTime, emitter, request, reqResMatcher)
+	}
+	return nil
+}
+
+func handleRequest(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, request *RedisPacket, reqResMatcher *requestResponseMatcher) {
 	counterPair.Lock()
 	counterPair.Request++
 	requestCounter := counterPair.Request
@@ -33,10 +94,23 @@ func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPai
 		}
 		emitter.Emit(item)
 	}
-	return nil
 }
 
 func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, counterPair *api.CounterPair, captureTime time.Time, emitter api.Emitter, response *RedisPacket, reqResMatcher *requestResponseMatcher) error {
+	if response.push {
+		// Not a response, so it doesn't take the place of one
+		item := reqResMatcher.preparePush(response, captureTime, progress.Current())
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+		return nil
+	}
+
 	counterPair.Lock()
 	counterPair.Response++
 	responseCounter := counterPair.Response
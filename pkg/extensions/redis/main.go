@@ -37,6 +37,7 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 		Buf:    make([]byte, 8192),
 	}
 	proto := NewProtocol(is)
+	subscriptions := make(subscriptions)
 	for {
 		redisPacket, err := proto.Read()
 		if err != nil {
@@ -44,7 +45,7 @@ func (d dissecting) Dissect(b *bufio.Reader, reader api.TcpReader) error {
 		}
 
 		if reader.GetIsClient() {
-			err = handleClientStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), redisPacket, reqResMatcher)
+			err = handleClientStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), redisPacket, reqResMatcher, subscriptions)
 		} else {
 			err = handleServerStream(reader.GetReadProgress(), reader.GetTcpID(), reader.GetCounterPair(), reader.GetCaptureTime(), reader.GetEmitter(), redisPacket, reqResMatcher)
 		}
@@ -76,6 +76,35 @@ func (matcher *requestResponseMatcher) registerResponse(ident string, response *
 	return nil
 }
 
+// preparePush pairs a message that the server sends on its own, like a
+// pub/sub message, with an empty response.
+func (matcher *requestResponseMatcher) preparePush(push *RedisPacket, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestRedisMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: RedisPayload{
+			Data: &RedisWrapper{
+				Method:  string(push.Command),
+				Url:     "",
+				Details: push,
+			},
+		},
+	}
+	responseRedisMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: RedisPayload{
+			Data: &RedisWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &RedisPacket{Type: types[notApplicableByte]},
+			},
+		},
+	}
+	return matcher.preparePair(&requestRedisMessage, &responseRedisMessage)
+}
+
 func (matcher *requestResponseMatcher) preparePair(requestRedisMessage *api.GenericMessage, responseRedisMessage *api.GenericMessage) *api.OutputChannelItem {
 	return &api.OutputChannelItem{
 		Protocol:       protocol,
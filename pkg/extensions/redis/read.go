@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
@@ -22,6 +23,23 @@ const (
 	minusByte         = '-'
 	colonByte         = ':'
 	notApplicableByte = '0'
+
+	// RESP3
+	percentByte     = '%'
+	tildeByte       = '~'
+	commaByte       = ','
+	hashByte        = '#'
+	leftParenByte   = '('
+	equalsByte      = '='
+	greaterThanByte = '>'
+	underscoreByte  = '_'
+	bangByte        = '!'
+	pipeByte        = '|'
+	dotByte         = '.'
+	semicolonByte   = ';'
+	questionByte    = '?'
+
+	maxBulkLength = 512 * 1024 * 1024
 )
 
 // receive message from redis
@@ -115,11 +133,7 @@ func (r *RedisInputStream) readLineBytes() ([]byte, error) {
 	}
 	N := pos - r.count - 2
 	line := make([]byte, N)
-	j := 0
-	for i := r.count; i <= N; i++ {
-		line[j] = buf[i]
-		j++
-	}
+	copy(line, buf[r.count:r.count+N])
 	r.count = pos
 	return line, nil
 }
@@ -133,7 +147,7 @@ func (r *RedisInputStream) readLineBytesSlowly() ([]byte, error) {
 		}
 		b := r.Buf[r.count]
 		r.count++
-		if b == 'r' {
+		if b == '\r' {
 			err := r.ensureFill()
 			if err != nil {
 				return nil, err
@@ -194,6 +208,49 @@ func (r *RedisInputStream) readIntCrLf() (int64, error) {
 	return value, nil
 }
 
+// readBulk reads the given number of bytes, which are followed by CRLF.
+func (r *RedisInputStream) readBulk(l int64) ([]byte, error) {
+	if l < 0 || l > maxBulkLength {
+		return nil, newConnectError(fmt.Sprintf("Invalid length: %d", l))
+	}
+	line := make([]byte, 0)
+	for int64(len(line)) < l {
+		err := r.ensureFill()
+		if err != nil {
+			return nil, err
+		}
+		n := r.limit - r.count
+		if int64(n) > l-int64(len(line)) {
+			n = int(l - int64(len(line)))
+		}
+		line = append(line, r.Buf[r.count:r.count+n]...)
+		r.count += n
+	}
+	return line, r.readCrLf()
+}
+
+func (r *RedisInputStream) readCrLf() error {
+	for _, expected := range []byte{'\r', '\n'} {
+		b, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if b != expected {
+			return newConnectError("Unexpected character!")
+		}
+	}
+	return nil
+}
+
+// peek returns the next byte without reading it.
+func (r *RedisInputStream) peek() (byte, error) {
+	err := r.ensureFill()
+	if err != nil {
+		return 0, err
+	}
+	return r.Buf[r.count], nil
+}
+
 type RedisProtocol struct {
 	is *RedisInputStream
 }
@@ -215,10 +272,18 @@ func (p *RedisProtocol) Read() (packet *RedisPacket, err error) {
 	switch v := x.(type) {
 	case []interface{}:
 		array := v
+		if r != types[asteriskByte] && r != types[greaterThanByte] {
+			// Sets are only sent by the server
+			packet.Value = formatValue(array)
+			break
+		}
 		if len(array) > 0 {
 			switch array[0].(type) {
 			case []uint8:
 				packet.Command = RedisCommand(strings.ToUpper(string(array[0].([]uint8))))
+				for _, item := range array[1:] {
+					packet.arguments = append(packet.arguments, formatValue(item))
+				}
 				if len(array) > 1 {
 					switch array[1].(type) {
 					case []uint8:
@@ -233,6 +298,8 @@ func (p *RedisProtocol) Read() (packet *RedisPacket, err error) {
 						packet.Value = string(array[2].([]uint8))
 					case int64:
 						packet.Value = fmt.Sprintf("%d", array[2].(int64))
+					default:
+						packet.Value = formatValue(array[2])
 					}
 				}
 				if len(array) > 3 {
@@ -243,15 +310,16 @@ func (p *RedisProtocol) Read() (packet *RedisPacket, err error) {
 							packet.Value = fmt.Sprintf("%s, %s", packet.Value, j)
 						case int64:
 							packet.Value = fmt.Sprintf("%s, %d", packet.Value, j)
+						default:
+							packet.Value = fmt.Sprintf("%s, %s", packet.Value, formatValue(j))
 						}
 					}
 					packet.Value = strings.TrimSuffix(packet.Value, ", ")
 					packet.Value = fmt.Sprintf("%s]", packet.Value)
 				}
 			default:
-				msg := fmt.Sprintf("Unrecognized element in Redis array: %v", reflect.TypeOf(array[0]))
-				err = errors.New(msg)
-				return
+				// A reply of nested aggregates, like ZRANGE WITHSCORES in RESP3
+				packet.Value = formatValue(array)
 			}
 		}
 	case []uint8:
@@ -269,6 +337,8 @@ func (p *RedisProtocol) Read() (packet *RedisPacket, err error) {
 		packet.Value = v
 	case int64:
 		packet.Value = fmt.Sprintf("%d", v)
+	case float64, bool, *big.Int, []redisPair:
+		packet.Value = formatValue(v)
 	default:
 		msg := fmt.Sprintf("Unrecognized Redis data type: %v", reflect.TypeOf(x))
 		err = errors.New(msg)
@@ -276,7 +346,12 @@ func (p *RedisProtocol) Read() (packet *RedisPacket, err error) {
 	}
 
 	if packet.Command != "" {
-		if !isValidRedisCommand(commands, packet.Command) {
+		switch {
+		case packet.Type == types[greaterThanByte] || isValidRedisCommand(pubSubMessages, packet.Command):
+			// Pushes confirm subscriptions, or are sent by the server on
+			// its own, like pub/sub messages and invalidations
+			packet.push = !isValidRedisCommand(subscriptionCommands, packet.Command)
+		case !isValidRedisCommand(commands, packet.Command):
 			err = fmt.Errorf("Unrecognized command: %s", string(packet.Command))
 			return
 		}
@@ -311,6 +386,48 @@ func (p *RedisProtocol) process() (v interface{}, r RedisType, err error) {
 		v, err = p.processError()
 		r = types[minusByte]
 		return
+	case percentByte:
+		v, err = p.processMap()
+		r = types[percentByte]
+		return
+	case tildeByte:
+		v, err = p.processArray()
+		r = types[tildeByte]
+		return
+	case greaterThanByte:
+		v, err = p.processArray()
+		r = types[greaterThanByte]
+		return
+	case commaByte:
+		v, err = p.processDouble()
+		r = types[commaByte]
+		return
+	case hashByte:
+		v, err = p.processBoolean()
+		r = types[hashByte]
+		return
+	case leftParenByte:
+		v, err = p.processBigNumber()
+		r = types[leftParenByte]
+		return
+	case equalsByte:
+		v, err = p.processVerbatimString()
+		r = types[equalsByte]
+		return
+	case underscoreByte:
+		v, err = p.processNull()
+		r = types[underscoreByte]
+		return
+	case bangByte:
+		v, err = p.processBulkError()
+		r = types[bangByte]
+		return
+	case pipeByte:
+		// Attributes only describe the reply that follows them
+		if _, err = p.processMap(); err != nil {
+			return nil, types[notApplicableByte], err
+		}
+		return p.process()
 	default:
 		return nil, types[notApplicableByte], newConnectError(fmt.Sprintf("Unknown reply: %b", b))
 	}
@@ -321,6 +438,13 @@ func (p *RedisProtocol) processSimpleString() ([]byte, error) {
 }
 
 func (p *RedisProtocol) processBulkString() ([]byte, error) {
+	streamed, err := p.isStreamed()
+	if err != nil {
+		return nil, err
+	}
+	if streamed {
+		return p.processStreamedString()
+	}
 	l, err := p.is.readIntCrLf()
 	if err != nil {
 		return nil, newConnectError(err.Error())
@@ -355,16 +479,35 @@ func (p *RedisProtocol) processBulkString() ([]byte, error) {
 }
 
 func (p *RedisProtocol) processArray() ([]interface{}, error) {
-	l, err := p.is.readIntCrLf()
+	streamed, err := p.isStreamed()
 	if err != nil {
-		return nil, newConnectError(err.Error())
+		return nil, err
 	}
-	if l == -1 {
-		return nil, nil
+	var l int64
+	if !streamed {
+		l, err = p.is.readIntCrLf()
+		if err != nil {
+			return nil, newConnectError(err.Error())
+		}
+		if l == -1 {
+			return nil, nil
+		}
 	}
 	ret := make([]interface{}, 0)
-	for i := 0; i < int(l); i++ {
+	for i := 0; streamed || i < int(l); i++ {
+		if streamed {
+			end, err := p.isEnd()
+			if err != nil {
+				return nil, err
+			}
+			if end {
+				break
+			}
+		}
 		if obj, _, err := p.process(); err != nil {
+			if streamed {
+				return nil, err
+			}
 			ret = append(ret, err)
 		} else {
 			ret = append(ret, obj)
@@ -373,6 +516,172 @@ func (p *RedisProtocol) processArray() ([]interface{}, error) {
 	return ret, nil
 }
 
+// redisPair is an entry of a map, which keeps the order of the entries.
+type redisPair struct {
+	key   interface{}
+	value interface{}
+}
+
+func (p *RedisProtocol) processMap() ([]redisPair, error) {
+	streamed, err := p.isStreamed()
+	if err != nil {
+		return nil, err
+	}
+	var l int64
+	if !streamed {
+		l, err = p.is.readIntCrLf()
+		if err != nil {
+			return nil, newConnectError(err.Error())
+		}
+		if l == -1 {
+			return nil, nil
+		}
+	}
+	ret := make([]redisPair, 0)
+	for i := 0; streamed || i < int(l); i++ {
+		if streamed {
+			end, err := p.isEnd()
+			if err != nil {
+				return nil, err
+			}
+			if end {
+				break
+			}
+		}
+		key, _, err := p.process()
+		if err != nil {
+			return nil, err
+		}
+		value, _, err := p.process()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, redisPair{key: key, value: value})
+	}
+	return ret, nil
+}
+
+// isStreamed reads the "?" length of a streamed string or aggregate.
+func (p *RedisProtocol) isStreamed() (bool, error) {
+	b, err := p.is.peek()
+	if err != nil || b != questionByte {
+		return false, err
+	}
+	p.is.count++
+	return true, p.is.readCrLf()
+}
+
+// isEnd reads the terminator of a streamed aggregate.
+func (p *RedisProtocol) isEnd() (bool, error) {
+	b, err := p.is.peek()
+	if err != nil || b != dotByte {
+		return false, err
+	}
+	p.is.count++
+	return true, p.is.readCrLf()
+}
+
+// processStreamedString reads the chunks of a streamed string, until the
+// empty one.
+func (p *RedisProtocol) processStreamedString() ([]byte, error) {
+	line := make([]byte, 0)
+	for {
+		b, err := p.is.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != semicolonByte {
+			return nil, newConnectError("Unexpected character!")
+		}
+		l, err := p.is.readIntCrLf()
+		if err != nil {
+			return nil, err
+		}
+		if l == 0 {
+			return line, nil
+		}
+		if int64(len(line))+l > maxBulkLength {
+			return nil, newConnectError(fmt.Sprintf("Invalid length: %d", int64(len(line))+l))
+		}
+		chunk, err := p.is.readBulk(l)
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+	}
+}
+
+func (p *RedisProtocol) processDouble() (float64, error) {
+	line, err := p.is.readLine()
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(line, 64)
+	if err != nil {
+		return 0, newConnectError(fmt.Sprintf("Invalid double: %s", line))
+	}
+	return v, nil
+}
+
+func (p *RedisProtocol) processBoolean() (bool, error) {
+	line, err := p.is.readLine()
+	if err != nil {
+		return false, err
+	}
+	switch line {
+	case "t":
+		return true, nil
+	case "f":
+		return false, nil
+	}
+	return false, newConnectError(fmt.Sprintf("Invalid boolean: %s", line))
+}
+
+func (p *RedisProtocol) processBigNumber() (*big.Int, error) {
+	line, err := p.is.readLine()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := new(big.Int).SetString(line, 10)
+	if !ok {
+		return nil, newConnectError(fmt.Sprintf("Invalid big number: %s", line))
+	}
+	return v, nil
+}
+
+// processVerbatimString returns the text of a verbatim string, without its
+// format, like "txt:".
+func (p *RedisProtocol) processVerbatimString() ([]byte, error) {
+	l, err := p.is.readIntCrLf()
+	if err != nil {
+		return nil, err
+	}
+	line, err := p.is.readBulk(l)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 4 || line[3] != ':' {
+		return nil, newConnectError("Verbatim string without format")
+	}
+	return line[4:], nil
+}
+
+func (p *RedisProtocol) processNull() ([]byte, error) {
+	return nil, p.is.readCrLf()
+}
+
+func (p *RedisProtocol) processBulkError() (interface{}, error) {
+	l, err := p.is.readIntCrLf()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := p.is.readBulk(l)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseError(string(msg))
+}
+
 func (p *RedisProtocol) processInteger() (int64, error) {
 	return p.is.readIntCrLf()
 }
@@ -382,6 +691,10 @@ func (p *RedisProtocol) processError() (interface{}, error) {
 	if err != nil {
 		return nil, newConnectError(err.Error())
 	}
+	return p.parseError(msg)
+}
+
+func (p *RedisProtocol) parseError(msg string) (interface{}, error) {
 	if strings.HasPrefix(msg, movedPrefix) {
 		host, port, slot, err := p.parseTargetHostAndSlot(msg)
 		if err != nil {
@@ -424,3 +737,37 @@ func (p *RedisProtocol) extractParts(from string) (string, string) {
 	}
 	return host, port
 }
+
+// formatValue formats a value of any type, like an argument of a command or
+// a reply.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case []uint8:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case *big.Int:
+		return v.String()
+	case error:
+		return v.Error()
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, formatValue(item))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+	case []redisPair:
+		items := make([]string, 0, len(v))
+		for _, pair := range v {
+			items = append(items, fmt.Sprintf("%s: %s", formatValue(pair.key), formatValue(pair.value)))
+		}
+		return fmt.Sprintf("{%s}", strings.Join(items, ", "))
+	}
+	return ""
+}
@@ -0,0 +1,260 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/misc"
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func read(data string) (*RedisPacket, error) {
+	is := &RedisInputStream{
+		Reader: bufio.NewReader(strings.NewReader(data)),
+		Buf:    make([]byte, 8192),
+	}
+	return NewProtocol(is).Read()
+}
+
+func TestReadRESP3(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		typ       RedisType
+		value     string
+		keyword   RedisKeyword
+		push      bool
+		errPrefix string
+	}{
+		{
+			name:  "map",
+			data:  "%3\r\n$6\r\nserver\r\n$5\r\nredis\r\n$5\r\nproto\r\n:3\r\n$7\r\nmodules\r\n*0\r\n",
+			typ:   "Map",
+			value: "{server: redis, proto: 3, modules: []}",
+		},
+		{
+			name:  "set",
+			data:  "~2\r\n+a\r\n+b\r\n",
+			typ:   "Set",
+			value: "[a, b]",
+		},
+		{
+			name:  "double",
+			data:  ",3.14\r\n",
+			typ:   "Double",
+			value: "3.14",
+		},
+		{
+			name:  "infinite double",
+			data:  ",-inf\r\n",
+			typ:   "Double",
+			value: "-Inf",
+		},
+		{
+			name:  "boolean",
+			data:  "#t\r\n",
+			typ:   "Boolean",
+			value: "true",
+		},
+		{
+			name:  "big number",
+			data:  "(3492890328409238509324850943850943825024385\r\n",
+			typ:   "Big Number",
+			value: "3492890328409238509324850943850943825024385",
+		},
+		{
+			name:  "verbatim string",
+			data:  "=16\r\ntxt:Some\r\nstring\r\n",
+			typ:   "Verbatim String",
+			value: "Some\r\nstring",
+		},
+		{
+			name:  "null",
+			data:  "_\r\n",
+			typ:   "Null",
+			value: "",
+		},
+		{
+			name:  "bulk error",
+			data:  "!21\r\nSYNTAX invalid syntax\r\n",
+			typ:   "Bulk Error",
+			value: "DataError: SYNTAX invalid syntax",
+		},
+		{
+			name:  "nested aggregates",
+			data:  "*2\r\n*2\r\n$1\r\na\r\n,1.5\r\n*2\r\n$1\r\nb\r\n,2\r\n",
+			typ:   "Array",
+			value: "[[a, 1.5], [b, 2]]",
+		},
+		{
+			name:  "attribute",
+			data:  "|1\r\n+ttl\r\n:3600\r\n:42\r\n",
+			typ:   "Integer",
+			value: "42",
+		},
+		{
+			name:  "streamed string",
+			data:  "$?\r\n;4\r\nHell\r\n;1\r\no\r\n;0\r\n",
+			typ:   "Bulk String",
+			value: "Hello",
+		},
+		{
+			name:  "streamed map",
+			data:  "%?\r\n+a\r\n:1\r\n+b\r\n#f\r\n.\r\n",
+			typ:   "Map",
+			value: "{a: 1, b: false}",
+		},
+		{
+			name:    "simple string",
+			data:    "+OK\r\n",
+			typ:     "Simple String",
+			keyword: "OK",
+		},
+		{
+			name:  "push message",
+			data:  ">3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n",
+			typ:   "Push",
+			value: "hello",
+			push:  true,
+		},
+		{
+			name:  "push subscription",
+			data:  ">3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n",
+			typ:   "Push",
+			value: "1",
+		},
+		{
+			name:  "RESP2 message",
+			data:  "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n",
+			typ:   "Array",
+			value: "hello",
+			push:  true,
+		},
+		{
+			name:      "invalid boolean",
+			data:      "#x\r\n",
+			errPrefix: "Invalid boolean",
+		},
+		{
+			name:      "verbatim string without format",
+			data:      "=2\r\nab\r\n",
+			errPrefix: "Verbatim string without format",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			packet, err := read(test.data)
+			if test.errPrefix != "" {
+				assert.NotNil(t, err)
+				assert.True(t, strings.HasPrefix(err.Error(), test.errPrefix), err.Error())
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, test.typ, packet.Type)
+			assert.Equal(t, test.value, packet.Value)
+			assert.Equal(t, test.keyword, packet.Keyword)
+			assert.Equal(t, test.push, packet.push)
+		})
+	}
+}
+
+func TestReadSimpleStringsInARow(t *testing.T) {
+	is := &RedisInputStream{
+		Reader: bufio.NewReader(strings.NewReader("+OK\r\n+QUEUED\r\n")),
+		Buf:    make([]byte, 8192),
+	}
+	proto := NewProtocol(is)
+	for _, keyword := range []RedisKeyword{"OK", "QUEUED"} {
+		packet, err := proto.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, keyword, packet.Keyword)
+	}
+}
+
+func command(args ...string) string {
+	var b strings.Builder
+	b.WriteString("*")
+	b.WriteString(strconv.Itoa(len(args)))
+	b.WriteString("\r\n")
+	for _, arg := range args {
+		b.WriteString("$")
+		b.WriteString(strconv.Itoa(len(arg)))
+		b.WriteString("\r\n")
+		b.WriteString(arg)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+func TestDissectPubSub(t *testing.T) {
+	client := command("HELLO", "3") +
+		command("SUBSCRIBE", "news", "sports") +
+		command("PSUBSCRIBE", "news.*") +
+		command("UNSUBSCRIBE") +
+		command("PING")
+	server := "%1\r\n$5\r\nproto\r\n:3\r\n" +
+		">3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n" +
+		">3\r\n$9\r\nsubscribe\r\n$6\r\nsports\r\n:2\r\n" +
+		">3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n" +
+		">3\r\n$10\r\npsubscribe\r\n$6\r\nnews.*\r\n:3\r\n" +
+		">4\r\n$8\r\npmessage\r\n$6\r\nnews.*\r\n$8\r\nnews.eu\r\n$3\r\nhey\r\n" +
+		">3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:2\r\n" +
+		">3\r\n$11\r\nunsubscribe\r\n$6\r\nsports\r\n:1\r\n" +
+		"+PONG\r\n"
+
+	dissector := NewDissector()
+	itemChannel := make(chan *api.OutputChannelItem, misc.ItemChannelBufferSize)
+	stream := NewTcpStream()
+	emitter := &api.Emitting{
+		AppStats:      &api.AppStats{},
+		Stream:        stream,
+		OutputChannel: itemChannel,
+	}
+	counterPair := &api.CounterPair{}
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	tcpIDClient := &api.TcpID{SrcIP: "1", DstIP: "2", SrcPort: "1", DstPort: "2"}
+	reader := NewTcpReader(&api.ReadProgress{}, "", tcpIDClient, time.Time{}, stream, true, false, nil, emitter, counterPair, reqResMatcher)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte(client))), reader))
+
+	tcpIDServer := &api.TcpID{SrcIP: "2", DstIP: "1", SrcPort: "2", DstPort: "1"}
+	reader = NewTcpReader(&api.ReadProgress{}, "", tcpIDServer, time.Time{}, stream, false, false, nil, emitter, counterPair, reqResMatcher)
+	assert.NotNil(t, dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte(server))), reader))
+
+	close(itemChannel)
+	var summaries []string
+	for item := range itemChannel {
+		data, err := json.Marshal(item)
+		assert.Nil(t, err)
+		var finalItem *api.OutputChannelItem
+		assert.Nil(t, json.Unmarshal(data, &finalItem))
+
+		entry := dissector.Analyze(finalItem, &api.Resolution{}, &api.Resolution{})
+		_, err = dissector.Represent(entry.Request, entry.Response)
+		assert.Nil(t, err)
+
+		summaries = append(summaries, fmt.Sprintf("%s %s: %s %s", entry.Request["command"], entry.Request["key"], entry.Response["type"], entry.Response["value"]))
+	}
+
+	assert.ElementsMatch(t, []string{
+		"HELLO 3: Map {proto: 3}",
+		"SUBSCRIBE news: Push 1",
+		"SUBSCRIBE sports: Push 2",
+		"MESSAGE news: N/A ",
+		"PSUBSCRIBE news.*: Push 3",
+		"PMESSAGE news.*: N/A ",
+		"UNSUBSCRIBE news: Push 2",
+		"UNSUBSCRIBE sports: Push 1",
+		"PING : Simple String ",
+	}, summaries)
+}
@@ -11,6 +11,15 @@ var types = map[rune]RedisType{
 	colonByte:         "Integer",
 	minusByte:         "Error",
 	notApplicableByte: "N/A",
+	percentByte:       "Map",
+	tildeByte:         "Set",
+	commaByte:         "Double",
+	hashByte:          "Boolean",
+	leftParenByte:     "Big Number",
+	equalsByte:        "Verbatim String",
+	greaterThanByte:   "Push",
+	underscoreByte:    "Null",
+	bangByte:          "Bulk Error",
 }
 
 var commands = []RedisCommand{
@@ -198,6 +207,29 @@ var commands = []RedisCommand{
 	"XREADGROUP",
 	"XPENDING",
 	"XCLAIM",
+	"HELLO",
+	"RESET",
+	"SSUBSCRIBE",
+	"SUNSUBSCRIBE",
+	"SPUBLISH",
+}
+
+// The commands that are confirmed by a push, or an array in RESP2, for each
+// of their channels
+var subscriptionCommands = []RedisCommand{
+	"SUBSCRIBE",
+	"PSUBSCRIBE",
+	"SSUBSCRIBE",
+	"UNSUBSCRIBE",
+	"PUNSUBSCRIBE",
+	"SUNSUBSCRIBE",
+}
+
+// The pub/sub messages that are sent by the server on its own
+var pubSubMessages = []RedisCommand{
+	"MESSAGE",
+	"PMESSAGE",
+	"SMESSAGE",
 }
 
 var keywords = []RedisKeyword{
@@ -269,6 +301,10 @@ type RedisPacket struct {
 	Key     string       `json:"key"`
 	Value   string       `json:"value"`
 	Keyword RedisKeyword `json:"keyword"`
+
+	// Server-initiated, like a pub/sub message or an invalidation
+	push      bool
+	arguments []string
 }
 
 func isValidRedisCommand(s []RedisCommand, c RedisCommand) bool {
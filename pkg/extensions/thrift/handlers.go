@@ -0,0 +1,208 @@
+package thrift
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+const (
+	statusSuccess              = 0
+	statusDeclaredException    = 1
+	statusApplicationException = 2
+)
+
+var applicationExceptionTypes = map[int32]string{
+	0:  "UNKNOWN",
+	1:  "UNKNOWN_METHOD",
+	2:  "INVALID_MESSAGE_TYPE",
+	3:  "WRONG_METHOD_NAME",
+	4:  "BAD_SEQUENCE_ID",
+	5:  "MISSING_RESULT",
+	6:  "INTERNAL_ERROR",
+	7:  "PROTOCOL_ERROR",
+	8:  "INVALID_TRANSFORM",
+	9:  "INVALID_PROTOCOL",
+	10: "UNSUPPORTED_CLIENT_TYPE",
+}
+
+// connectionState keeps track of one side of a connection, whose transport
+// and protocol are detected by its first message.
+type connectionState struct {
+	transport string
+	protocol  string
+
+	// A reply echoes the sequence id of its call. The id is counted, since
+	// some clients send every call with the same one.
+	occurrences map[int32]int
+}
+
+func newConnectionState(b *bufio.Reader) (*connectionState, error) {
+	transport, protocol, err := detectEncoding(b)
+	if err != nil {
+		return nil, err
+	}
+	return &connectionState{
+		transport:   transport,
+		protocol:    protocol,
+		occurrences: make(map[int32]int),
+	}, nil
+}
+
+// readRequest reads a call and returns it with the key of its reply, which
+// is empty for a oneway call.
+func (s *connectionState) readRequest(b *bufio.Reader) (*ThriftRequest, string, error) {
+	m, err := readMessage(b, s.transport, s.protocol)
+	if err != nil {
+		return nil, "", err
+	}
+	if m.Type != messageCall && m.Type != messageOneway {
+		return nil, "", fmt.Errorf("unexpected %s from the client", messageTypes[m.Type])
+	}
+
+	service, method := splitMethod(m.Name)
+	thriftIDL.nameMessage(m.Type, service, method, m.Fields)
+	request := &ThriftRequest{
+		Protocol:  s.protocol,
+		Transport: s.transport,
+		Type:      messageTypes[m.Type],
+		Service:   service,
+		Method:    method,
+		SeqID:     m.SeqID,
+		Fields:    m.Fields,
+	}
+	if m.Type == messageOneway {
+		return request, "", nil
+	}
+	return request, s.seqIDKey(m.SeqID), nil
+}
+
+// readResponse reads a reply or an exception and returns it with the key of
+// its call.
+func (s *connectionState) readResponse(b *bufio.Reader) (*ThriftResponse, string, error) {
+	m, err := readMessage(b, s.transport, s.protocol)
+	if err != nil {
+		return nil, "", err
+	}
+	if m.Type != messageReply && m.Type != messageException {
+		return nil, "", fmt.Errorf("unexpected %s from the server", messageTypes[m.Type])
+	}
+
+	service, method := splitMethod(m.Name)
+	thriftIDL.nameMessage(m.Type, service, method, m.Fields)
+	response := &ThriftResponse{
+		Protocol:  s.protocol,
+		Transport: s.transport,
+		Type:      messageTypes[m.Type],
+		Service:   service,
+		Method:    method,
+		SeqID:     m.SeqID,
+		Fields:    m.Fields,
+	}
+	if response.Exception, err = readException(m); err != nil {
+		return nil, "", err
+	}
+	if response.Exception != nil {
+		if m.Type == messageException {
+			response.StatusCode = statusApplicationException
+		} else {
+			response.StatusCode = statusDeclaredException
+		}
+	}
+	return response, s.seqIDKey(m.SeqID), nil
+}
+
+// readException returns the exception of a response. A reply holds either
+// the success, whose id is 0, or one of the exceptions the method declares.
+func readException(m *message) (*ThriftException, error) {
+	if m.Type == messageException {
+		exception := &ThriftException{Kind: "application"}
+		for _, field := range m.Fields {
+			switch {
+			case field.ID == 1 && field.Type == "string":
+				exception.Message = field.Value.(string)
+			case field.ID == 2 && field.Type == "i32":
+				exception.Type = field.Value.(int32)
+			}
+		}
+		exception.TypeName = applicationExceptionTypes[exception.Type]
+		return exception, nil
+	}
+
+	if len(m.Fields) > 1 {
+		return nil, errors.New("reply has more than one field")
+	}
+	if len(m.Fields) == 0 || m.Fields[0].ID == 0 {
+		return nil, nil
+	}
+	field := m.Fields[0]
+	fields, ok := field.Value.([]ThriftField)
+	if !ok {
+		return nil, fmt.Errorf("exception of type %s", field.Type)
+	}
+	return &ThriftException{
+		Kind:    "declared",
+		FieldID: field.ID,
+		Name:    field.Name,
+		Fields:  fields,
+	}, nil
+}
+
+// splitMethod splits the name of a service off the method, which is how
+// the multiplexed protocol tells the services apart.
+func splitMethod(name string) (service string, method string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+func (s *connectionState) seqIDKey(seqID int32) string {
+	occurrence := s.occurrences[seqID]
+	s.occurrences[seqID]++
+	return fmt.Sprintf("%d_%d", seqID, occurrence)
+}
+
+func identity(tcpID *api.TcpID, isClient bool, key string) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, key)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, key)
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, request *ThriftRequest, key string, reqResMatcher *requestResponseMatcher) {
+	var item *api.OutputChannelItem
+	if key == "" {
+		item = reqResMatcher.prepareOneway(request, captureTime, progress.Current())
+	} else {
+		item = reqResMatcher.registerRequest(identity(tcpID, true, key), request, captureTime, progress.Current())
+	}
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, response *ThriftResponse, key string, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerResponse(identity(tcpID, false, key), response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
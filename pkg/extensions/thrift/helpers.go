@@ -0,0 +1,175 @@
+package thrift
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Protocol",
+			Value:    request["protocol"].(string),
+			Selector: `request.protocol`,
+		},
+		{
+			Name:     "Transport",
+			Value:    request["transport"].(string),
+			Selector: `request.transport`,
+		},
+		{
+			Name:     "Type",
+			Value:    request["type"].(string),
+			Selector: `request.type`,
+		},
+		{
+			Name:     "Service",
+			Value:    request["service"],
+			Selector: `request.service`,
+		},
+		{
+			Name:     "Method",
+			Value:    request["method"].(string),
+			Selector: `request.method`,
+		},
+		{
+			Name:     "Sequence ID",
+			Value:    request["seqId"].(float64),
+			Selector: `request.seqId`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if fields, ok := request["fields"].([]interface{}); ok && len(fields) > 0 {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Arguments",
+			Data:  representFields(fields, `request.fields`),
+		})
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	if response["type"].(string) == "" {
+		// A oneway call isn't replied to
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Type",
+			Value:    response["type"].(string),
+			Selector: `response.type`,
+		},
+		{
+			Name:     "Sequence ID",
+			Value:    response["seqId"].(float64),
+			Selector: `response.seqId`,
+		},
+		{
+			Name:     "Status Code",
+			Value:    response["statusCode"].(float64),
+			Selector: `response.statusCode`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if exception, ok := response["exception"].(map[string]interface{}); ok {
+		details, _ := json.Marshal([]api.TableData{
+			{
+				Name:     "Kind",
+				Value:    exception["kind"].(string),
+				Selector: `response.exception.kind`,
+			},
+			{
+				Name:     "Type",
+				Value:    exception["typeName"],
+				Selector: `response.exception.typeName`,
+			},
+			{
+				Name:     "Message",
+				Value:    exception["message"],
+				Selector: `response.exception.message`,
+			},
+			{
+				Name:     "Field ID",
+				Value:    exception["fieldId"],
+				Selector: `response.exception.fieldId`,
+			},
+			{
+				Name:     "Name",
+				Value:    exception["name"],
+				Selector: `response.exception.name`,
+			},
+		})
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Exception",
+			Data:  string(details),
+		})
+
+		if fields, ok := exception["fields"].([]interface{}); ok && len(fields) > 0 {
+			repResponse = append(repResponse, api.SectionData{
+				Type:  api.TABLE,
+				Title: "Exception Fields",
+				Data:  representFields(fields, `response.exception.fields`),
+			})
+		}
+		return
+	}
+
+	if fields, ok := response["fields"].([]interface{}); ok && len(fields) > 0 {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Result",
+			Data:  representFields(fields, `response.fields`),
+		})
+	}
+
+	return
+}
+
+// representFields lists the fields by their names, or their ids when the
+// IDL isn't known. The values of structs and collections are given as JSON.
+func representFields(fields []interface{}, selectorPrefix string) string {
+	var table []api.TableData
+	for i, field := range fields {
+		f := field.(map[string]interface{})
+		name, _ := f["name"].(string)
+		if name == "" {
+			name = fmt.Sprintf("%v", f["id"])
+		}
+
+		var value interface{}
+		switch v := f["value"].(type) {
+		case []interface{}, map[string]interface{}:
+			data, _ := json.Marshal(v)
+			value = string(data)
+		default:
+			value = v
+		}
+
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("%s (%s)", name, f["type"]),
+			Value:    value,
+			Selector: fmt.Sprintf("%s[%d].value", selectorPrefix, i),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
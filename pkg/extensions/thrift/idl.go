@@ -0,0 +1,565 @@
+package thrift
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// idlType is a type that a field is declared with. The element of a list
+// or a set is its value.
+type idlType struct {
+	name  string
+	key   *idlType
+	value *idlType
+}
+
+type idlField struct {
+	id   int16
+	name string
+	typ  *idlType
+}
+
+type idlStruct map[int16]*idlField
+
+type idlFunction struct {
+	args   idlStruct
+	result idlStruct
+}
+
+type idlService struct {
+	extends   string
+	functions map[string]*idlFunction
+}
+
+// idlRegistry holds the definitions of the uploaded IDL files, which name
+// the fields of the messages. The definitions are known by their names
+// without the include prefix.
+type idlRegistry struct {
+	sync.RWMutex
+	structs  map[string]idlStruct
+	typedefs map[string]*idlType
+	services map[string]*idlService
+}
+
+var thriftIDL = &idlRegistry{
+	structs:  make(map[string]idlStruct),
+	typedefs: make(map[string]*idlType),
+	services: make(map[string]*idlService),
+}
+
+// applicationException is the struct of a TApplicationException.
+var applicationException = idlStruct{
+	1: {id: 1, name: "message", typ: &idlType{name: "string"}},
+	2: {id: 2, name: "type", typ: &idlType{name: "i32"}},
+}
+
+// LoadThriftIDL parses a Thrift IDL file and names the fields of the
+// messages of its services. The included files have to be loaded too. It
+// returns the services that are known.
+func LoadThriftIDL(data []byte) ([]string, error) {
+	p := &idlParser{
+		tokens:   tokenizeIDL(string(data)),
+		structs:  make(map[string]idlStruct),
+		typedefs: make(map[string]*idlType),
+		services: make(map[string]*idlService),
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	if len(p.structs) == 0 && len(p.services) == 0 {
+		return nil, errors.New("IDL has no structs or services")
+	}
+
+	thriftIDL.add(p)
+	return ThriftServices(), nil
+}
+
+// ThriftServices returns the services of the uploaded IDL files.
+func ThriftServices() []string {
+	return thriftIDL.serviceNames()
+}
+
+func (r *idlRegistry) add(p *idlParser) {
+	r.Lock()
+	defer r.Unlock()
+
+	for name, s := range p.structs {
+		r.structs[name] = s
+	}
+	for name, t := range p.typedefs {
+		r.typedefs[name] = t
+	}
+	for name, s := range p.services {
+		r.services[name] = s
+	}
+}
+
+func (r *idlRegistry) serviceNames() []string {
+	r.RLock()
+	defer r.RUnlock()
+
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findFunction looks a method up in the service, or in all of the services
+// when the name isn't multiplexed with the one of the service.
+func (r *idlRegistry) findFunction(service string, method string) *idlFunction {
+	if service != "" {
+		// The functions of a service include the ones it extends
+		for depth := 0; depth < maxDepth; depth++ {
+			s, ok := r.services[service]
+			if !ok {
+				return nil
+			}
+			if function, ok := s.functions[method]; ok {
+				return function
+			}
+			service = s.extends
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if function, ok := r.services[name].functions[method]; ok {
+			return function
+		}
+	}
+	return nil
+}
+
+// nameMessage names the fields of a message after the declaration of the
+// method.
+func (r *idlRegistry) nameMessage(messageType byte, service string, method string, fields []ThriftField) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if messageType == messageException {
+		r.nameFields(fields, applicationException, 0)
+		return
+	}
+
+	function := r.findFunction(service, method)
+	if function == nil {
+		return
+	}
+	if messageType == messageReply {
+		r.nameFields(fields, function.result, 0)
+	} else {
+		r.nameFields(fields, function.args, 0)
+	}
+}
+
+func (r *idlRegistry) nameFields(fields []ThriftField, s idlStruct, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	for i := range fields {
+		field, ok := s[fields[i].ID]
+		if !ok {
+			continue
+		}
+		fields[i].Name = field.name
+		r.nameValue(fields[i].Value, field.typ, depth+1)
+	}
+}
+
+func (r *idlRegistry) nameValue(value interface{}, t *idlType, depth int) {
+	t = r.resolve(t)
+	if t == nil {
+		return
+	}
+
+	switch value := value.(type) {
+	case []ThriftField:
+		if s, ok := r.structs[t.name]; ok {
+			r.nameFields(value, s, depth)
+		}
+	case []interface{}:
+		for _, element := range value {
+			r.nameValue(element, t.value, depth+1)
+		}
+	case []ThriftMapEntry:
+		for _, entry := range value {
+			r.nameValue(entry.Key, t.key, depth+1)
+			r.nameValue(entry.Value, t.value, depth+1)
+		}
+	}
+}
+
+func (r *idlRegistry) resolve(t *idlType) *idlType {
+	for depth := 0; t != nil && depth < maxDepth; depth++ {
+		alias, ok := r.typedefs[t.name]
+		if !ok {
+			return t
+		}
+		t = alias
+	}
+	return t
+}
+
+type idlToken struct {
+	text string
+	line int
+}
+
+// tokenizeIDL splits an IDL file into words, literals and punctuation,
+// leaving out the comments.
+func tokenizeIDL(source string) (tokens []idlToken) {
+	line := 1
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				end = len(source) - i - 4
+			}
+			line += strings.Count(source[i:i+end+4], "\n")
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(source) && source[j] != c {
+				j++
+			}
+			tokens = append(tokens, idlToken{text: source[i:minInt(j+1, len(source))], line: line})
+			line += strings.Count(source[i:minInt(j+1, len(source))], "\n")
+			i = j + 1
+		case isWordByte(c):
+			j := i
+			for j < len(source) && isWordByte(source[j]) {
+				j++
+			}
+			tokens = append(tokens, idlToken{text: source[i:j], line: line})
+			i = j
+		default:
+			tokens = append(tokens, idlToken{text: string(c), line: line})
+			i++
+		}
+	}
+	return
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' || c == '+'
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// idlParser reads the definitions that name fields: the structs, unions
+// and exceptions, the typedefs and the services. The rest is skipped.
+type idlParser struct {
+	tokens []idlToken
+	pos    int
+
+	structs  map[string]idlStruct
+	typedefs map[string]*idlType
+	services map[string]*idlService
+}
+
+func (p *idlParser) parse() error {
+	for p.pos < len(p.tokens) {
+		token := p.next()
+		switch token.text {
+		case "namespace":
+			p.next()
+			p.next()
+		case "include", "cpp_include":
+			p.next()
+		case "typedef":
+			t, err := p.parseType()
+			if err != nil {
+				return err
+			}
+			p.typedefs[p.next().text] = t
+			p.skipAnnotations()
+			p.skipSeparator()
+		case "const":
+			if _, err := p.parseType(); err != nil {
+				return err
+			}
+			p.next()
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			p.skipValue()
+			p.skipSeparator()
+		case "enum", "senum":
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.skipBlock("{", "}"); err != nil {
+				return err
+			}
+			p.skipAnnotations()
+		case "struct", "union", "exception":
+			name := p.next().text
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			fields, err := p.parseFields("}")
+			if err != nil {
+				return err
+			}
+			p.structs[name] = fields
+			p.skipAnnotations()
+		case "service":
+			if err := p.parseService(); err != nil {
+				return err
+			}
+		case ";", ",":
+		default:
+			return fmt.Errorf("line %d: unexpected %q", token.line, token.text)
+		}
+	}
+	return nil
+}
+
+func (p *idlParser) parseService() error {
+	name := p.next().text
+	service := &idlService{functions: make(map[string]*idlFunction)}
+	if p.peek() == "extends" {
+		p.next()
+		service.extends = unqualified(p.next().text)
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for p.peek() != "}" {
+		if p.pos >= len(p.tokens) {
+			return errors.New("unexpected end of the IDL")
+		}
+		if p.peek() == "oneway" || p.peek() == "async" {
+			p.next()
+		}
+		returnType, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		function := &idlFunction{result: make(idlStruct)}
+		method := p.next().text
+		if err = p.expect("("); err != nil {
+			return err
+		}
+		if function.args, err = p.parseFields(")"); err != nil {
+			return err
+		}
+		if returnType.name != "void" {
+			function.result[0] = &idlField{id: 0, name: "success", typ: returnType}
+		}
+		if p.peek() == "throws" {
+			p.next()
+			if err = p.expect("("); err != nil {
+				return err
+			}
+			throws, err := p.parseFields(")")
+			if err != nil {
+				return err
+			}
+			for id, field := range throws {
+				function.result[id] = field
+			}
+		}
+		p.skipAnnotations()
+		p.skipSeparator()
+		service.functions[method] = function
+	}
+	p.next()
+	p.skipAnnotations()
+
+	p.services[name] = service
+	return nil
+}
+
+// parseFields reads the fields up to the end token. A field without an id
+// is given a negative one, as the Thrift compiler does.
+func (p *idlParser) parseFields(end string) (idlStruct, error) {
+	fields := make(idlStruct)
+	implicitID := int16(-1)
+	for p.peek() != end {
+		if p.pos >= len(p.tokens) {
+			return nil, errors.New("unexpected end of the IDL")
+		}
+		field := &idlField{}
+		if p.peekAt(1) == ":" {
+			token := p.next()
+			id, err := strconv.ParseInt(token.text, 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid field id %q", token.line, token.text)
+			}
+			field.id = int16(id)
+			p.next()
+		} else {
+			field.id = implicitID
+			implicitID--
+		}
+		if p.peek() == "required" || p.peek() == "optional" {
+			p.next()
+		}
+
+		var err error
+		if field.typ, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		field.name = p.next().text
+		if p.peek() == "=" {
+			p.next()
+			p.skipValue()
+		}
+		p.skipAnnotations()
+		p.skipSeparator()
+		fields[field.id] = field
+	}
+	p.next()
+	return fields, nil
+}
+
+func (p *idlParser) parseType() (*idlType, error) {
+	token := p.next()
+	t := &idlType{name: unqualified(token.text)}
+	switch token.text {
+	case "":
+		return nil, errors.New("unexpected end of the IDL")
+	case "list", "set":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.value = value
+		if err = p.expect(">"); err != nil {
+			return nil, err
+		}
+	case "map":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.key, t.value = key, value
+		if err = p.expect(">"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek() == "cpp_type" {
+		p.next()
+		p.next()
+	}
+	return t, nil
+}
+
+func (p *idlParser) next() idlToken {
+	if p.pos >= len(p.tokens) {
+		return idlToken{}
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *idlParser) peek() string {
+	return p.peekAt(0)
+}
+
+func (p *idlParser) peekAt(offset int) string {
+	if p.pos+offset >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos+offset].text
+}
+
+func (p *idlParser) expect(text string) error {
+	token := p.next()
+	if token.text != text {
+		return fmt.Errorf("line %d: expected %q instead of %q", token.line, text, token.text)
+	}
+	return nil
+}
+
+func (p *idlParser) skipSeparator() {
+	if p.peek() == "," || p.peek() == ";" {
+		p.next()
+	}
+}
+
+func (p *idlParser) skipAnnotations() {
+	if p.peek() == "(" {
+		p.next()
+		_ = p.skipBlock("(", ")")
+	}
+}
+
+// skipValue skips a constant, which may be a list or a map.
+func (p *idlParser) skipValue() {
+	switch p.next().text {
+	case "[":
+		_ = p.skipBlock("[", "]")
+	case "{":
+		_ = p.skipBlock("{", "}")
+	}
+}
+
+// skipBlock skips up to the token that closes a block, whose opening token
+// was already read.
+func (p *idlParser) skipBlock(open string, close string) error {
+	for depth := 1; depth > 0; {
+		if p.pos >= len(p.tokens) {
+			return errors.New("unexpected end of the IDL")
+		}
+		switch p.next().text {
+		case open:
+			depth++
+		case close:
+			depth--
+		}
+	}
+	return nil
+}
+
+// unqualified removes the include prefix of a name.
+func unqualified(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
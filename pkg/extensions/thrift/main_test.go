@@ -0,0 +1,332 @@
+package thrift
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "thrift", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"thrift": `protocol.name == "thrift"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+// binaryMessage encodes a message of the binary protocol, whose body is a
+// struct of fields that ends with a stop.
+func binaryMessage(messageType byte, name string, seqID int32, fields ...[]byte) []byte {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.BigEndian, uint32(binaryVersion1)|uint32(messageType))
+	_ = binary.Write(&b, binary.BigEndian, int32(len(name)))
+	b.WriteString(name)
+	_ = binary.Write(&b, binary.BigEndian, seqID)
+	b.Write(binaryStruct(fields...))
+	return b.Bytes()
+}
+
+func binaryStruct(fields ...[]byte) []byte {
+	return append(bytes.Join(fields, nil), typeStop)
+}
+
+func binaryField(fieldType byte, id int16, value []byte) []byte {
+	field := []byte{fieldType, byte(id >> 8), byte(id)}
+	return append(field, value...)
+}
+
+func binaryI32(v int32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func binaryI64(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+func binaryString(s string) []byte {
+	return append(binaryI32(int32(len(s))), s...)
+}
+
+func framed(message []byte) []byte {
+	return append(binaryI32(int32(len(message))), message...)
+}
+
+func compactMessage(messageType byte, name string, seqID int32, body []byte) []byte {
+	b := []byte{compactProtocolID, messageType<<5 | compactVersion}
+	b = append(b, varint(uint64(uint32(seqID)))...)
+	b = append(b, varint(uint64(len(name)))...)
+	b = append(b, name...)
+	return append(b, body...)
+}
+
+func varint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func zigzag(v int64) []byte {
+	return varint(uint64(v<<1 ^ v>>63))
+}
+
+func dissect(t *testing.T, client []byte, server []byte) map[string]*api.Entry {
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, client, server) {
+		key := entry.Request["method"].(string)
+		if _, ok := entries[key]; ok {
+			key += " again"
+		}
+		entries[key] = entry
+	}
+	return entries
+}
+
+func field(fields interface{}, i int) map[string]interface{} {
+	return fields.([]interface{})[i].(map[string]interface{})
+}
+
+func userServiceTraffic() (client []byte, server []byte) {
+	user := binaryStruct(
+		binaryField(typeString, 1, binaryString("alice")),
+		binaryField(typeList, 2, append([]byte{typeI32}, append(binaryI32(2), append(binaryI32(7), binaryI32(9)...)...)...)),
+	)
+	notFound := binaryStruct(binaryField(typeString, 1, binaryString("no such user")))
+
+	client = bytes.Join([][]byte{
+		framed(binaryMessage(messageCall, "getUser", 1, binaryField(typeI64, 1, binaryI64(42)))),
+		framed(binaryMessage(messageCall, "deleteUser", 2, binaryField(typeI64, 1, binaryI64(7)))),
+		framed(binaryMessage(messageOneway, "log", 3, binaryField(typeString, 1, binaryString("bye")))),
+		framed(binaryMessage(messageCall, "missing", 4)),
+	}, nil)
+	server = bytes.Join([][]byte{
+		framed(binaryMessage(messageReply, "getUser", 1, binaryField(typeStruct, 0, user))),
+		framed(binaryMessage(messageReply, "deleteUser", 2, binaryField(typeStruct, 1, notFound))),
+		framed(binaryMessage(messageException, "missing", 4,
+			binaryField(typeString, 1, binaryString("Invalid method name: 'missing'")),
+			binaryField(typeI32, 2, binaryI32(1)),
+		)),
+	}, nil)
+	return
+}
+
+func TestDissectBinaryFramed(t *testing.T) {
+	client, server := userServiceTraffic()
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 4)
+	dissector := NewDissector()
+
+	getUser := entries["getUser"]
+	assert.Equal(t, "binary", getUser.Request["protocol"])
+	assert.Equal(t, "framed", getUser.Request["transport"])
+	assert.Equal(t, "CALL", getUser.Request["type"])
+	assert.Equal(t, float64(1), getUser.Request["seqId"])
+	assert.Equal(t, float64(1), field(getUser.Request["fields"], 0)["id"])
+	assert.Equal(t, "i64", field(getUser.Request["fields"], 0)["type"])
+	assert.Equal(t, float64(42), field(getUser.Request["fields"], 0)["value"])
+	assert.Equal(t, "REPLY", getUser.Response["type"])
+	assert.Equal(t, float64(0), getUser.Response["statusCode"])
+	assert.Nil(t, getUser.Response["exception"])
+	success := field(getUser.Response["fields"], 0)
+	assert.Equal(t, "struct", success["type"])
+	assert.Equal(t, "alice", field(success["value"], 0)["value"])
+	assert.Equal(t, []interface{}{float64(7), float64(9)}, field(success["value"], 1)["value"])
+
+	summary := dissector.Summarize(getUser)
+	assert.Equal(t, "getUser", summary.Summary)
+	assert.Equal(t, `request.method == "getUser"`, summary.SummaryQuery)
+	assert.Equal(t, "CALL", summary.Method)
+	assert.Equal(t, 0, summary.Status)
+
+	deleteUser := entries["deleteUser"]
+	assert.Equal(t, float64(statusDeclaredException), deleteUser.Response["statusCode"])
+	exception := deleteUser.Response["exception"].(map[string]interface{})
+	assert.Equal(t, "declared", exception["kind"])
+	assert.Equal(t, float64(1), exception["fieldId"])
+	assert.Equal(t, "no such user", field(exception["fields"], 0)["value"])
+	summary = dissector.Summarize(deleteUser)
+	assert.Equal(t, statusDeclaredException, summary.Status)
+	assert.Equal(t, `response.statusCode == 1`, summary.StatusQuery)
+
+	log := entries["log"]
+	assert.Equal(t, "ONEWAY", log.Request["type"])
+	assert.Equal(t, "", log.Response["type"])
+
+	missing := entries["missing"]
+	assert.Equal(t, "EXCEPTION", missing.Response["type"])
+	assert.Equal(t, float64(statusApplicationException), missing.Response["statusCode"])
+	exception = missing.Response["exception"].(map[string]interface{})
+	assert.Equal(t, "application", exception["kind"])
+	assert.Equal(t, "UNKNOWN_METHOD", exception["typeName"])
+	assert.Equal(t, "Invalid method name: 'missing'", exception["message"])
+	assert.Equal(t, "message", field(missing.Response["fields"], 0)["name"])
+}
+
+func TestDissectCompactBuffered(t *testing.T) {
+	// Both calls have the same sequence id and are paired in order
+	double := make([]byte, 8)
+	binary.LittleEndian.PutUint64(double, math.Float64bits(1.5))
+	args := bytes.Join([][]byte{
+		// i64 field 1
+		{0x16}, zigzag(42),
+		// bool field 2, whose value is in the type
+		{0x11},
+		// double field 3
+		{0x17}, double,
+		// binary field 4 that isn't UTF-8
+		{0x18, 0x02, 0xff, 0xfe},
+		// map<string, i32> field 5
+		{0x1b, 0x01, 0x85, 0x01}, []byte("a"), zigzag(-3),
+		// struct field 100, whose id doesn't fit the delta, with an i32 field
+		{0x0c}, zigzag(100), {0x15}, zigzag(1), {0x00},
+		{0x00},
+	}, nil)
+
+	client := append(
+		compactMessage(messageCall, "UserService:getUser", 0, args),
+		compactMessage(messageCall, "UserService:getUser", 0, []byte{0x00})...,
+	)
+	server := append(
+		compactMessage(messageReply, "UserService:getUser", 0, []byte{0x08, 0x00, 0x01, 'x', 0x00}),
+		compactMessage(messageReply, "UserService:getUser", 0, []byte{0x08, 0x00, 0x01, 'y', 0x00})...,
+	)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 2)
+
+	first := entries["getUser"]
+	assert.Equal(t, "compact", first.Request["protocol"])
+	assert.Equal(t, "buffered", first.Request["transport"])
+	assert.Equal(t, "UserService", first.Request["service"])
+	fields := first.Request["fields"]
+	assert.Equal(t, float64(42), field(fields, 0)["value"])
+	assert.Equal(t, true, field(fields, 1)["value"])
+	assert.Equal(t, 1.5, field(fields, 2)["value"])
+	assert.Equal(t, "binary", field(fields, 3)["type"])
+	assert.Equal(t, "//4=", field(fields, 3)["value"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "a", "value": float64(-3)}}, field(fields, 4)["value"])
+	assert.Equal(t, float64(100), field(fields, 5)["id"])
+	assert.Equal(t, float64(1), field(field(fields, 5)["value"], 0)["value"])
+	assert.Equal(t, "x", field(first.Response["fields"], 0)["value"])
+
+	second := entries["getUser again"]
+	assert.Equal(t, "y", field(second.Response["fields"], 0)["value"])
+	assert.Equal(t, "UserService:getUser", NewDissector().Summarize(second).Summary)
+}
+
+func TestDissectWithIDL(t *testing.T) {
+	idl := `
+namespace go users
+
+/* A user of the service */
+struct User {
+  1: required string name,
+  2: list<i32> groups
+}
+
+typedef User Account
+
+exception NotFound {
+  1: string reason
+}
+
+service UserService {
+  Account getUser(1: i64 id),
+  void deleteUser(1: i64 id) throws (1: NotFound notFound);
+  oneway void log(1: string message)
+}
+`
+	services, err := LoadThriftIDL([]byte(idl))
+	assert.Nil(t, err)
+	assert.Contains(t, services, "UserService")
+	assert.Contains(t, ThriftServices(), "UserService")
+
+	client, server := userServiceTraffic()
+	entries := dissect(t, client, server)
+
+	getUser := entries["getUser"]
+	assert.Equal(t, "id", field(getUser.Request["fields"], 0)["name"])
+	success := field(getUser.Response["fields"], 0)
+	assert.Equal(t, "success", success["name"])
+	assert.Equal(t, "name", field(success["value"], 0)["name"])
+	assert.Equal(t, "groups", field(success["value"], 1)["name"])
+
+	exception := entries["deleteUser"].Response["exception"].(map[string]interface{})
+	assert.Equal(t, "notFound", exception["name"])
+	assert.Equal(t, "reason", field(exception["fields"], 0)["name"])
+
+	assert.Equal(t, "message", field(entries["log"].Request["fields"], 0)["name"])
+}
+
+func TestLoadThriftIDLErrors(t *testing.T) {
+	_, err := LoadThriftIDL([]byte("struct User {\n  1: string name\n"))
+	assert.NotNil(t, err)
+
+	_, err = LoadThriftIDL([]byte("struct User {\n  x: string name\n}"))
+	assert.EqualError(t, err, `line 2: invalid field id "x"`)
+
+	_, err = LoadThriftIDL([]byte("namespace go users\n"))
+	assert.NotNil(t, err)
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestReadMessageNestedTooDeep(t *testing.T) {
+	nested := func(depth int, container func(inner []byte, innerType byte) []byte, containerType byte) []byte {
+		value := binaryI32(0)
+		valueType := byte(typeI32)
+		for i := 0; i < depth; i++ {
+			value, valueType = container(value, valueType), containerType
+		}
+		return binaryMessage(messageCall, "deep", 1, binaryField(valueType, 1, value))
+	}
+	list := func(inner []byte, innerType byte) []byte {
+		return append(append([]byte{innerType}, binaryI32(1)...), inner...)
+	}
+	mapOf := func(inner []byte, innerType byte) []byte {
+		return append(append([]byte{typeI32, innerType}, binaryI32(1)...), append(binaryI32(7), inner...)...)
+	}
+
+	// The struct of the message is the first level
+	for _, c := range []struct {
+		container     func(inner []byte, innerType byte) []byte
+		containerType byte
+		err           string
+	}{
+		{list, typeList, "list is nested too deep"},
+		{mapOf, typeMap, "map is nested too deep"},
+	} {
+		_, err := readMessage(bufio.NewReader(bytes.NewReader(nested(maxDepth-1, c.container, c.containerType))), transportBuffered, protocolBinary)
+		assert.Nil(t, err)
+
+		_, err = readMessage(bufio.NewReader(bytes.NewReader(nested(maxDepth, c.container, c.containerType))), transportBuffered, protocolBinary)
+		assert.EqualError(t, err, c.err)
+	}
+}
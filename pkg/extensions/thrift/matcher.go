@@ -0,0 +1,126 @@
+package thrift
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{seqid}_{occurrence}`
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *ThriftRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestThriftMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: ThriftPayload{
+			Data: &ThriftWrapper{
+				Method:  request.Method,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseThriftMessage := response.(*api.GenericMessage)
+		if responseThriftMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestThriftMessage, responseThriftMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestThriftMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *ThriftResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseThriftMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: ThriftPayload{
+			Data: &ThriftWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestThriftMessage := request.(*api.GenericMessage)
+		if !requestThriftMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestThriftMessage, &responseThriftMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseThriftMessage)
+	return nil
+}
+
+// prepareOneway pairs a oneway call, which is never replied to, with an
+// empty response.
+func (matcher *requestResponseMatcher) prepareOneway(request *ThriftRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestThriftMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: ThriftPayload{
+			Data: &ThriftWrapper{
+				Method:  request.Method,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseThriftMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: ThriftPayload{
+			Data: &ThriftWrapper{
+				Method: "",
+				Url:    "",
+				Details: &ThriftResponse{
+					Protocol:  request.Protocol,
+					Transport: request.Transport,
+					Service:   request.Service,
+					Method:    request.Method,
+					SeqID:     request.SeqID,
+					Fields:    make([]ThriftField, 0),
+				},
+			},
+		},
+	}
+	return matcher.preparePair(&requestThriftMessage, &responseThriftMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestThriftMessage *api.GenericMessage, responseThriftMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestThriftMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestThriftMessage,
+			Response: *responseThriftMessage,
+		},
+	}
+}
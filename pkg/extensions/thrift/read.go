@@ -0,0 +1,568 @@
+package thrift
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+const (
+	protocolBinary  = "binary"
+	protocolCompact = "compact"
+
+	transportFramed   = "framed"
+	transportBuffered = "buffered"
+)
+
+const (
+	messageCall      = 1
+	messageReply     = 2
+	messageException = 3
+	messageOneway    = 4
+)
+
+var messageTypes = map[byte]string{
+	messageCall:      "CALL",
+	messageReply:     "REPLY",
+	messageException: "EXCEPTION",
+	messageOneway:    "ONEWAY",
+}
+
+const (
+	binaryVersionMask = 0xffff0000
+	binaryVersion1    = 0x80010000
+	compactProtocolID = 0x82
+	compactVersion    = 1
+)
+
+const (
+	maxMessageSize  = 16 * 1024 * 1024
+	maxMethodLength = 1024
+	maxDepth        = 64
+)
+
+// The types of the binary protocol, which are also used to name the types
+// of both protocols.
+const (
+	typeStop   = 0
+	typeBool   = 2
+	typeByte   = 3
+	typeDouble = 4
+	typeI16    = 6
+	typeI32    = 8
+	typeI64    = 10
+	typeString = 11
+	typeStruct = 12
+	typeMap    = 13
+	typeSet    = 14
+	typeList   = 15
+	typeUUID   = 16
+)
+
+var typeNames = map[byte]string{
+	typeBool:   "bool",
+	typeByte:   "byte",
+	typeDouble: "double",
+	typeI16:    "i16",
+	typeI32:    "i32",
+	typeI64:    "i64",
+	typeString: "string",
+	typeStruct: "struct",
+	typeMap:    "map",
+	typeSet:    "set",
+	typeList:   "list",
+	typeUUID:   "uuid",
+}
+
+// The types of the compact protocol, mapped to those of the binary protocol.
+// A boolean field carries its value in the type.
+const (
+	compactBooleanTrue  = 1
+	compactBooleanFalse = 2
+)
+
+var compactTypes = map[byte]byte{
+	compactBooleanTrue:  typeBool,
+	compactBooleanFalse: typeBool,
+	3:                   typeByte,
+	4:                   typeI16,
+	5:                   typeI32,
+	6:                   typeI64,
+	7:                   typeDouble,
+	8:                   typeString,
+	9:                   typeList,
+	10:                  typeSet,
+	11:                  typeMap,
+	12:                  typeStruct,
+	13:                  typeUUID,
+}
+
+// detectEncoding tells the transport and the protocol apart by the first
+// bytes of a side. Both protocols start a message with a version, which
+// follows the size of the frame on the framed transport. The old binary
+// protocol that has no version isn't supported.
+func detectEncoding(b *bufio.Reader) (transport string, protocol string, err error) {
+	head, err := b.Peek(2)
+	if err != nil {
+		return
+	}
+	if protocol = detectProtocol(head); protocol != "" {
+		transport = transportBuffered
+		return
+	}
+
+	head, err = b.Peek(6)
+	if err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(head)
+	if size == 0 || size > maxMessageSize {
+		err = fmt.Errorf("invalid frame size: %d", size)
+		return
+	}
+	if protocol = detectProtocol(head[4:]); protocol == "" {
+		err = errors.New("not a Thrift message")
+		return
+	}
+	transport = transportFramed
+	return
+}
+
+func detectProtocol(head []byte) string {
+	switch {
+	case head[0] == 0x80 && head[1] == 0x01:
+		return protocolBinary
+	case head[0] == compactProtocolID && head[1]&0x1f == compactVersion:
+		return protocolCompact
+	}
+	return ""
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+type message struct {
+	Type   byte
+	Name   string
+	SeqID  int32
+	Fields []ThriftField
+}
+
+// readMessage reads a message of the given transport and protocol. The
+// frame of a framed message has to hold exactly the message.
+func readMessage(b *bufio.Reader, transport string, protocol string) (*message, error) {
+	r := byteReader(b)
+	var frame *bytes.Reader
+	if transport == transportFramed {
+		var size uint32
+		if err := binary.Read(b, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		if size == 0 || size > maxMessageSize {
+			return nil, fmt.Errorf("invalid frame size: %d", size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(b, data); err != nil {
+			return nil, err
+		}
+		frame = bytes.NewReader(data)
+		r = frame
+	}
+
+	d := &decoder{r: r, compact: protocol == protocolCompact}
+	m, err := d.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	if frame != nil && frame.Len() != 0 {
+		return nil, fmt.Errorf("%d bytes left in the frame", frame.Len())
+	}
+	return m, nil
+}
+
+// decoder reads the values of either protocol without a schema, so the
+// fields are known only by their ids and wire types.
+type decoder struct {
+	r       byteReader
+	compact bool
+	depth   int
+}
+
+func (d *decoder) readMessage() (*message, error) {
+	m := &message{}
+	if d.compact {
+		id, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		versionAndType, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if id != compactProtocolID || versionAndType&0x1f != compactVersion {
+			return nil, errors.New("not a compact protocol message")
+		}
+		m.Type = versionAndType >> 5
+		seqID, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		m.SeqID = int32(seqID)
+	} else {
+		version, err := d.readI32()
+		if err != nil {
+			return nil, err
+		}
+		if uint32(version)&binaryVersionMask != binaryVersion1 {
+			return nil, errors.New("not a binary protocol message")
+		}
+		m.Type = byte(version & 0xff)
+	}
+	if _, ok := messageTypes[m.Type]; !ok {
+		return nil, fmt.Errorf("unknown message type: %d", m.Type)
+	}
+
+	name, err := d.readBinary(maxMethodLength)
+	if err != nil {
+		return nil, err
+	}
+	if len(name) == 0 || !utf8.Valid(name) {
+		return nil, errors.New("invalid method name")
+	}
+	m.Name = string(name)
+
+	if !d.compact {
+		if m.SeqID, err = d.readI32(); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.Fields, err = d.readStruct(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (d *decoder) readStruct() ([]ThriftField, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, errors.New("struct is nested too deep")
+	}
+
+	fields := make([]ThriftField, 0)
+	var lastID int16
+	for {
+		var fieldType byte
+		var id int16
+		var value interface{}
+		if d.compact {
+			header, err := d.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if header == typeStop {
+				return fields, nil
+			}
+			compactType := header & 0x0f
+			var ok bool
+			if fieldType, ok = compactTypes[compactType]; !ok {
+				return nil, fmt.Errorf("unknown compact type: %d", compactType)
+			}
+			if delta := header >> 4; delta != 0 {
+				id = lastID + int16(delta)
+			} else {
+				v, err := d.readZigZag()
+				if err != nil {
+					return nil, err
+				}
+				id = int16(v)
+			}
+			lastID = id
+			if fieldType == typeBool {
+				value = compactType == compactBooleanTrue
+			}
+		} else {
+			var err error
+			if fieldType, err = d.r.ReadByte(); err != nil {
+				return nil, err
+			}
+			if fieldType == typeStop {
+				return fields, nil
+			}
+			if id, err = d.readI16(); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, ok := typeNames[fieldType]; !ok {
+			return nil, fmt.Errorf("unknown type: %d", fieldType)
+		}
+		if value == nil {
+			var err error
+			if value, err = d.readValue(fieldType); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, ThriftField{
+			ID:    id,
+			Type:  typeName(fieldType, value),
+			Value: value,
+		})
+	}
+}
+
+// typeName names the type of a value, telling the strings apart from the
+// binaries that share the wire type.
+func typeName(fieldType byte, value interface{}) string {
+	if fieldType == typeString {
+		if _, ok := value.(thriftBinary); ok {
+			return "binary"
+		}
+	}
+	return typeNames[fieldType]
+}
+
+// thriftBinary is a binary value that isn't valid UTF-8, given in base64.
+type thriftBinary string
+
+func (d *decoder) readValue(valueType byte) (interface{}, error) {
+	switch valueType {
+	case typeBool:
+		v, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if d.compact {
+			return v == compactBooleanTrue, nil
+		}
+		return v != 0, nil
+	case typeByte:
+		v, err := d.r.ReadByte()
+		return int8(v), err
+	case typeI16:
+		return d.readI16()
+	case typeI32:
+		return d.readI32()
+	case typeI64:
+		return d.readI64()
+	case typeDouble:
+		var bits uint64
+		var err error
+		if d.compact {
+			err = binary.Read(d.r, binary.LittleEndian, &bits)
+		} else {
+			err = binary.Read(d.r, binary.BigEndian, &bits)
+		}
+		return math.Float64frombits(bits), err
+	case typeString:
+		v, err := d.readBinary(maxMessageSize)
+		if err != nil {
+			return nil, err
+		}
+		if utf8.Valid(v) {
+			return string(v), nil
+		}
+		return thriftBinary(base64.StdEncoding.EncodeToString(v)), nil
+	case typeUUID:
+		v := make([]byte, 16)
+		if _, err := io.ReadFull(d.r, v); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:]), nil
+	case typeStruct:
+		return d.readStruct()
+	case typeList, typeSet:
+		return d.readList()
+	case typeMap:
+		return d.readMap()
+	}
+	return nil, fmt.Errorf("unknown type: %d", valueType)
+}
+
+func (d *decoder) readList() ([]interface{}, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, errors.New("list is nested too deep")
+	}
+
+	var elementType byte
+	var size int
+	if d.compact {
+		header, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if elementType, ok = compactTypes[header&0x0f]; !ok {
+			return nil, fmt.Errorf("unknown compact type: %d", header&0x0f)
+		}
+		size = int(header >> 4)
+		if size == 15 {
+			v, err := d.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			size = int(v)
+		}
+	} else {
+		var err error
+		if elementType, err = d.r.ReadByte(); err != nil {
+			return nil, err
+		}
+		v, err := d.readI32()
+		if err != nil {
+			return nil, err
+		}
+		size = int(v)
+	}
+	if size < 0 || size > maxMessageSize {
+		return nil, fmt.Errorf("invalid collection size: %d", size)
+	}
+
+	list := make([]interface{}, 0)
+	for i := 0; i < size; i++ {
+		value, err := d.readValue(elementType)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+func (d *decoder) readMap() ([]ThriftMapEntry, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, errors.New("map is nested too deep")
+	}
+
+	var keyType, valueType byte
+	var size int
+	if d.compact {
+		v, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		size = int(v)
+		if size > 0 {
+			types, err := d.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			var keyOk, valueOk bool
+			keyType, keyOk = compactTypes[types>>4]
+			valueType, valueOk = compactTypes[types&0x0f]
+			if !keyOk || !valueOk {
+				return nil, fmt.Errorf("unknown compact map types: %#x", types)
+			}
+		}
+	} else {
+		var err error
+		if keyType, err = d.r.ReadByte(); err != nil {
+			return nil, err
+		}
+		if valueType, err = d.r.ReadByte(); err != nil {
+			return nil, err
+		}
+		v, err := d.readI32()
+		if err != nil {
+			return nil, err
+		}
+		size = int(v)
+	}
+	if size < 0 || size > maxMessageSize {
+		return nil, fmt.Errorf("invalid map size: %d", size)
+	}
+
+	entries := make([]ThriftMapEntry, 0)
+	for i := 0; i < size; i++ {
+		key, err := d.readValue(keyType)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.readValue(valueType)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ThriftMapEntry{Key: key, Value: value})
+	}
+	return entries, nil
+}
+
+func (d *decoder) readBinary(limit int) ([]byte, error) {
+	var size int
+	if d.compact {
+		v, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		size = int(v)
+	} else {
+		v, err := d.readI32()
+		if err != nil {
+			return nil, err
+		}
+		size = int(v)
+	}
+	if size < 0 || size > limit {
+		return nil, fmt.Errorf("invalid string size: %d", size)
+	}
+
+	v := make([]byte, size)
+	if _, err := io.ReadFull(d.r, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (d *decoder) readI16() (int16, error) {
+	if d.compact {
+		v, err := d.readZigZag()
+		return int16(v), err
+	}
+	var v int16
+	err := binary.Read(d.r, binary.BigEndian, &v)
+	return v, err
+}
+
+func (d *decoder) readI32() (int32, error) {
+	if d.compact {
+		v, err := d.readZigZag()
+		return int32(v), err
+	}
+	var v int32
+	err := binary.Read(d.r, binary.BigEndian, &v)
+	return v, err
+}
+
+func (d *decoder) readI64() (int64, error) {
+	if d.compact {
+		return d.readZigZag()
+	}
+	var v int64
+	err := binary.Read(d.r, binary.BigEndian, &v)
+	return v, err
+}
+
+func (d *decoder) readVarint() (uint64, error) {
+	return binary.ReadUvarint(d.r)
+}
+
+func (d *decoder) readZigZag() (int64, error) {
+	v, err := d.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
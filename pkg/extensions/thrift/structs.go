@@ -0,0 +1,73 @@
+package thrift
+
+import (
+	"encoding/json"
+)
+
+type ThriftPayload struct {
+	Data interface{}
+}
+
+type ThriftPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h ThriftPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type ThriftWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+// ThriftField is a field of a struct, known by its id unless an uploaded IDL
+// names it. The value of a struct is a list of fields, of a list or a set a
+// list of values and of a map a list of entries.
+type ThriftField struct {
+	ID    int16       `json:"id"`
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type ThriftMapEntry struct {
+	Key   interface{} `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type ThriftRequest struct {
+	Protocol  string        `json:"protocol"`
+	Transport string        `json:"transport"`
+	Type      string        `json:"type"`
+	Service   string        `json:"service,omitempty"`
+	Method    string        `json:"method"`
+	SeqID     int32         `json:"seqId"`
+	Fields    []ThriftField `json:"fields"`
+}
+
+// ThriftException is either a TApplicationException that the server returns
+// instead of a reply, or an exception declared by the method, which is a
+// field of the reply other than the success.
+type ThriftException struct {
+	Kind     string        `json:"kind"`
+	Type     int32         `json:"type,omitempty"`
+	TypeName string        `json:"typeName,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	FieldID  int16         `json:"fieldId,omitempty"`
+	Name     string        `json:"name,omitempty"`
+	Fields   []ThriftField `json:"fields,omitempty"`
+}
+
+type ThriftResponse struct {
+	Protocol   string           `json:"protocol"`
+	Transport  string           `json:"transport"`
+	Type       string           `json:"type"`
+	Service    string           `json:"service,omitempty"`
+	Method     string           `json:"method"`
+	SeqID      int32            `json:"seqId"`
+	Fields     []ThriftField    `json:"fields"`
+	StatusCode int              `json:"statusCode"`
+	Exception  *ThriftException `json:"exception,omitempty"`
+}
@@ -0,0 +1,43 @@
+package tls
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func identity(tcpID *api.TcpID, isClient bool) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_hello", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_hello", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort)
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, request *TlsRequest, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerRequest(identity(tcpID, true), request, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, response *TlsResponse, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerResponse(identity(tcpID, false), response, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
@@ -0,0 +1,206 @@
+package tls
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// joinStrings joins the strings of a list that was round-tripped through
+// JSON.
+func joinStrings(list interface{}, separator string) string {
+	var values []string
+	if items, ok := list.([]interface{}); ok {
+		for _, item := range items {
+			values = append(values, item.(string))
+		}
+	}
+	return strings.Join(values, separator)
+}
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Server Name",
+			Value:    request["serverName"].(string),
+			Selector: `request.serverName`,
+		},
+		{
+			Name:     "Version",
+			Value:    request["version"].(string),
+			Selector: `request.version`,
+		},
+		{
+			Name:     "Record Version",
+			Value:    request["recordVersion"].(string),
+			Selector: `request.recordVersion`,
+		},
+		{
+			Name:     "ALPN",
+			Value:    joinStrings(request["alpn"], ", "),
+			Selector: `request.alpn`,
+		},
+		{
+			Name:     "Session ID",
+			Value:    request["sessionId"],
+			Selector: `request.sessionId`,
+		},
+		{
+			Name:     "JA3",
+			Value:    request["ja3"].(string),
+			Selector: `request.ja3`,
+		},
+		{
+			Name:     "JA3 Hash",
+			Value:    request["ja3Hash"].(string),
+			Selector: `request.ja3Hash`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Client Hello",
+		Data:  string(details),
+	})
+
+	repRequest = representList(repRequest, request["cipherSuites"], "Cipher Suites", "request.cipherSuites")
+	repRequest = representList(repRequest, request["extensions"], "Extensions", "request.extensions")
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	if response["version"].(string) != "" {
+		details, _ := json.Marshal([]api.TableData{
+			{
+				Name:     "Version",
+				Value:    response["version"].(string),
+				Selector: `response.version`,
+			},
+			{
+				Name:     "Cipher Suite",
+				Value:    response["cipherSuite"].(string),
+				Selector: `response.cipherSuite`,
+			},
+			{
+				Name:     "ALPN",
+				Value:    response["alpn"].(string),
+				Selector: `response.alpn`,
+			},
+			{
+				Name:     "Session ID",
+				Value:    response["sessionId"],
+				Selector: `response.sessionId`,
+			},
+			{
+				Name:     "JA3S",
+				Value:    response["ja3s"].(string),
+				Selector: `response.ja3s`,
+			},
+			{
+				Name:     "JA3S Hash",
+				Value:    response["ja3sHash"].(string),
+				Selector: `response.ja3sHash`,
+			},
+		})
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Server Hello",
+			Data:  string(details),
+		})
+	}
+
+	if alert, ok := response["alert"].(string); ok && alert != "" {
+		// The code of close_notify is 0, which is omitted from the JSON
+		alertCode, _ := response["alertCode"].(float64)
+		details, _ := json.Marshal([]api.TableData{
+			{
+				Name:     "Alert",
+				Value:    alert,
+				Selector: `response.alert`,
+			},
+			{
+				Name:     "Alert Code",
+				Value:    alertCode,
+				Selector: `response.alertCode`,
+			},
+		})
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Alert",
+			Data:  string(details),
+		})
+	}
+
+	repResponse = representList(repResponse, response["extensions"], "Extensions", "response.extensions")
+
+	if certificates, ok := response["certificates"].([]interface{}); ok {
+		for i, certificate := range certificates {
+			c := certificate.(map[string]interface{})
+			details, _ := json.Marshal([]api.TableData{
+				{
+					Name:     "Subject",
+					Value:    c["subject"].(string),
+					Selector: fmt.Sprintf("response.certificates[%d].subject", i),
+				},
+				{
+					Name:     "Issuer",
+					Value:    c["issuer"].(string),
+					Selector: fmt.Sprintf("response.certificates[%d].issuer", i),
+				},
+				{
+					Name:     "Serial Number",
+					Value:    c["serialNumber"].(string),
+					Selector: fmt.Sprintf("response.certificates[%d].serialNumber", i),
+				},
+				{
+					Name:     "Not Before",
+					Value:    c["notBefore"].(string),
+					Selector: fmt.Sprintf("response.certificates[%d].notBefore", i),
+				},
+				{
+					Name:     "Not After",
+					Value:    c["notAfter"].(string),
+					Selector: fmt.Sprintf("response.certificates[%d].notAfter", i),
+				},
+				{
+					Name:     "DNS Names",
+					Value:    joinStrings(c["dnsNames"], ", "),
+					Selector: fmt.Sprintf("response.certificates[%d].dnsNames", i),
+				},
+			})
+			repResponse = append(repResponse, api.SectionData{
+				Type:  api.TABLE,
+				Title: fmt.Sprintf("Certificate [%d]", i),
+				Data:  string(details),
+			})
+		}
+	}
+
+	return
+}
+
+func representList(rep []interface{}, list interface{}, title string, selector string) []interface{} {
+	items, ok := list.([]interface{})
+	if !ok || len(items) == 0 {
+		return rep
+	}
+
+	var table []api.TableData
+	for i, item := range items {
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("[%d]", i),
+			Value:    item.(string),
+			Selector: fmt.Sprintf("%s[%d]", selector, i),
+		})
+	}
+	obj, _ := json.Marshal(table)
+	return append(rep, api.SectionData{
+		Type:  api.TABLE,
+		Title: title,
+		Data:  string(obj),
+	})
+}
@@ -0,0 +1,62 @@
+package tls
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/dreadl0ck/tlsx"
+)
+
+// joinValues joins the values of a fingerprint field with dashes, leaving
+// out the GREASE values.
+func joinValues(values []uint16) string {
+	var fields []string
+	for _, value := range values {
+		if isGrease(value) {
+			continue
+		}
+		fields = append(fields, fmt.Sprint(value))
+	}
+	return strings.Join(fields, "-")
+}
+
+func fingerprintHash(fingerprint string) string {
+	sum := md5.Sum([]byte(fingerprint))
+	return hex.EncodeToString(sum[:])
+}
+
+// ja3 returns the JA3 fingerprint of a ClientHello, which is
+// `SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats`.
+func ja3(hello *tlsx.ClientHello) string {
+	cipherSuites := make([]uint16, len(hello.CipherSuites))
+	for i, cipherSuite := range hello.CipherSuites {
+		cipherSuites[i] = uint16(cipherSuite)
+	}
+
+	points := make([]uint16, len(hello.SupportedPoints))
+	for i, point := range hello.SupportedPoints {
+		points[i] = uint16(point)
+	}
+
+	return fmt.Sprintf(
+		"%d,%s,%s,%s,%s",
+		hello.HandshakeVersion,
+		joinValues(cipherSuites),
+		joinValues(hello.AllExtensions),
+		joinValues(hello.SupportedGroups),
+		joinValues(points),
+	)
+}
+
+// ja3s returns the JA3S fingerprint of a ServerHello, which is
+// `SSLVersion,Cipher,SSLExtension`.
+func ja3s(hello *tlsx.ServerHello) string {
+	return fmt.Sprintf(
+		"%d,%d,%s",
+		hello.Vers,
+		hello.CipherSuite,
+		joinValues(hello.Extensions),
+	)
+}
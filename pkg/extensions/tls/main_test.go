@@ -0,0 +1,263 @@
+package tls
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "tls", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"tls": `protocol.name == "tls"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+func u16(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func u24(v int) []byte {
+	return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// vec prefixes the joined parts with their length, of 1, 2 or 3 bytes.
+func vec(size int, parts ...[]byte) []byte {
+	data := bytes.Join(parts, nil)
+	switch size {
+	case 1:
+		return append([]byte{byte(len(data))}, data...)
+	case 2:
+		return append(u16(uint16(len(data))), data...)
+	default:
+		return append(u24(len(data)), data...)
+	}
+}
+
+func extension(extensionType uint16, parts ...[]byte) []byte {
+	return append(u16(extensionType), vec(2, parts...)...)
+}
+
+func handshake(handshakeType byte, parts ...[]byte) []byte {
+	return append([]byte{handshakeType}, vec(3, parts...)...)
+}
+
+func tlsRecord(contentType byte, fragment []byte) []byte {
+	return append([]byte{contentType, 0x03, 0x03}, vec(2, fragment)...)
+}
+
+func clientHello(serverName string) []byte {
+	return handshake(handshakeClientHello,
+		u16(0x0303),
+		make([]byte, 32),
+		vec(1),
+		vec(2, u16(0x0a0a), u16(0x1301), u16(0xc02f)),
+		vec(1, []byte{0}),
+		vec(2,
+			extension(0x1a1a),
+			extension(0, vec(2, []byte{0}, vec(2, []byte(serverName)))),
+			extension(10, vec(2, u16(0x001d), u16(0x0017))),
+			extension(11, vec(1, []byte{0})),
+			extension(16, vec(2, vec(1, []byte("h2")), vec(1, []byte("http/1.1")))),
+			extension(43, vec(1, u16(0x0304), u16(0x0303))),
+		),
+	)
+}
+
+func serverHello(cipherSuite uint16, extensions ...[]byte) []byte {
+	return handshake(handshakeServerHello,
+		u16(0x0303),
+		make([]byte, 32),
+		vec(1, []byte{1, 2, 3, 4}),
+		u16(cipherSuite),
+		[]byte{0},
+		vec(2, extensions...),
+	)
+}
+
+func certificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		DNSNames:     []string{"example.com", "www.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	return der
+}
+
+func dissect(t *testing.T, client []byte, server []byte) []*api.Entry {
+	return dissecttest.Entries(t, NewDissector(), dissecttest.ClientFirst, client, server)
+}
+
+func TestDissectTLS12(t *testing.T) {
+	hello := clientHello("example.com")
+	// The ClientHello is fragmented across two records
+	client := bytes.Join([][]byte{
+		tlsRecord(recordHandshake, hello[:40]),
+		tlsRecord(recordHandshake, hello[40:]),
+		tlsRecord(recordChangeCipherSpec, []byte{1}),
+		tlsRecord(recordApplicationData, []byte("encrypted")),
+	}, nil)
+
+	// The handshake messages of the server are coalesced in one record
+	server := bytes.Join([][]byte{
+		tlsRecord(recordHandshake, bytes.Join([][]byte{
+			serverHello(0xc02f,
+				extension(65281, vec(1)),
+				extension(16, vec(2, vec(1, []byte("h2")))),
+			),
+			handshake(handshakeCertificate, vec(3, vec(3, certificate(t)))),
+			handshake(handshakeServerHelloDone),
+		}, nil)),
+		tlsRecord(recordChangeCipherSpec, []byte{1}),
+		tlsRecord(recordHandshake, []byte("encrypted finished")),
+		tlsRecord(recordApplicationData, []byte("encrypted")),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+
+	assert.Equal(t, "example.com", entry.Request["serverName"])
+	assert.Equal(t, "TLS 1.2", entry.Request["version"])
+	assert.Equal(t, []interface{}{"h2", "http/1.1"}, entry.Request["alpn"])
+	assert.Equal(t, []interface{}{"GREASE", "TLS_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, entry.Request["cipherSuites"])
+	assert.Equal(t, "GREASE", entry.Request["extensions"].([]interface{})[0])
+	assert.Equal(t, "supported_versions", entry.Request["extensions"].([]interface{})[5])
+	assert.Equal(t, "771,4865-49199,0-10-11-16-43,29-23,0", entry.Request["ja3"])
+	assert.Equal(t, fingerprintHash("771,4865-49199,0-10-11-16-43,29-23,0"), entry.Request["ja3Hash"])
+
+	assert.Equal(t, "TLS 1.2", entry.Response["version"])
+	assert.Equal(t, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", entry.Response["cipherSuite"])
+	assert.Equal(t, "h2", entry.Response["alpn"])
+	assert.Equal(t, "01020304", entry.Response["sessionId"])
+	assert.Equal(t, "771,49199,65281-16", entry.Response["ja3s"])
+	assert.Equal(t, []interface{}{"renegotiation_info", "application_layer_protocol_negotiation"}, entry.Response["extensions"])
+
+	certificates := entry.Response["certificates"].([]interface{})
+	assert.Len(t, certificates, 1)
+	cert := certificates[0].(map[string]interface{})
+	assert.Equal(t, "CN=example.com", cert["subject"])
+	assert.Equal(t, "42", cert["serialNumber"])
+	assert.Equal(t, "2025-01-01T00:00:00Z", cert["notAfter"])
+	assert.Equal(t, []interface{}{"example.com", "www.example.com"}, cert["dnsNames"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, "example.com", summary.Summary)
+	assert.Equal(t, `request.serverName == "example.com"`, summary.SummaryQuery)
+	assert.Equal(t, "TLS 1.2", summary.Method)
+	assert.Equal(t, 0, summary.Status)
+}
+
+func TestDissectTLS13(t *testing.T) {
+	client := bytes.Join([][]byte{
+		tlsRecord(recordHandshake, clientHello("api.example.com")),
+		tlsRecord(recordChangeCipherSpec, []byte{1}),
+		tlsRecord(recordApplicationData, []byte("encrypted")),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		tlsRecord(recordHandshake, serverHello(0x1301,
+			extension(43, u16(0x0304)),
+			extension(51, u16(0x001d), vec(2, make([]byte, 32))),
+		)),
+		tlsRecord(recordChangeCipherSpec, []byte{1}),
+		tlsRecord(recordApplicationData, []byte("encrypted extensions and certificates")),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+
+	assert.Equal(t, "TLS 1.3", entry.Response["version"])
+	assert.Equal(t, "TLS_AES_128_GCM_SHA256", entry.Response["cipherSuite"])
+	assert.Equal(t, []interface{}{"supported_versions", "key_share"}, entry.Response["extensions"])
+	assert.Nil(t, entry.Response["certificates"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, "TLS 1.3", summary.Method)
+	assert.Equal(t, `response.version == "TLS 1.3"`, summary.MethodQuery)
+}
+
+func TestDissectAlert(t *testing.T) {
+	client := tlsRecord(recordHandshake, clientHello("unknown.example.com"))
+	server := tlsRecord(recordAlert, []byte{2, 112})
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+
+	assert.Equal(t, "", entry.Response["version"])
+	assert.Equal(t, "unrecognized_name", entry.Response["alert"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, 112, summary.Status)
+	assert.Equal(t, `response.alertCode == 112`, summary.StatusQuery)
+	assert.Equal(t, "TLS 1.2", summary.Method)
+}
+
+func TestDissectCloseNotify(t *testing.T) {
+	client := tlsRecord(recordHandshake, clientHello("example.com"))
+	server := []byte("\x15\x03\x03\x00\x02\x02\x00")
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, "close_notify", entry.Response["alert"])
+
+	summary := NewDissector().Summarize(entry)
+	assert.Equal(t, 0, summary.Status)
+	assert.Equal(t, "", summary.StatusQuery)
+}
+
+func TestDissectNotTLS(t *testing.T) {
+	entries := dissect(t, []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), []byte("HTTP/1.1 200 OK\r\n\r\n"))
+	assert.Len(t, entries, 0)
+
+	// An application data record doesn't start a connection
+	entries = dissect(t, tlsRecord(recordApplicationData, []byte("data")), tlsRecord(recordApplicationData, []byte("data")))
+	assert.Len(t, entries, 0)
+}
+
+func TestReadClientHelloBadLengths(t *testing.T) {
+	withExtension := func(ext []byte) []byte {
+		return handshake(handshakeClientHello, u16(0x0303), make([]byte, 32), vec(1), vec(2, u16(0x1301)), vec(1, []byte{0}), vec(2, ext))
+	}
+	for _, message := range [][]byte{
+		// A protocol that's longer than the ALPN extension
+		withExtension(extension(16, vec(2, []byte{8}, []byte("h2")))),
+		// A name that's longer than the server_name extension
+		withExtension(extension(0, vec(2, []byte{0}, u16(100), []byte("example.com")))),
+		// Cipher suites that are longer than the message
+		handshake(handshakeClientHello, u16(0x0303), make([]byte, 32), vec(1), u16(4), u16(0x1301)),
+	} {
+		s := newConnectionState()
+		_, err := s.readClientHello(bufio.NewReader(bytes.NewReader(tlsRecord(recordHandshake, message))))
+		assert.Equal(t, errNotClientHello, err)
+	}
+}
@@ -0,0 +1,90 @@
+package tls
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_hello`
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *TlsRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestTlsMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: TlsPayload{
+			Data: &TlsWrapper{
+				Method:  request.Version,
+				Url:     request.ServerName,
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseTlsMessage := response.(*api.GenericMessage)
+		if responseTlsMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestTlsMessage, responseTlsMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestTlsMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *TlsResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseTlsMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: TlsPayload{
+			Data: &TlsWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestTlsMessage := request.(*api.GenericMessage)
+		if !requestTlsMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestTlsMessage, &responseTlsMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseTlsMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestTlsMessage *api.GenericMessage, responseTlsMessage *api.GenericMessage) *api.OutputChannelItem {
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestTlsMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestTlsMessage,
+			Response: *responseTlsMessage,
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package tls
+
+import (
+	"fmt"
+
+	"github.com/dreadl0ck/tlsx"
+)
+
+// The names that tlsx doesn't know, mostly from TLS 1.3.
+var cipherSuiteNames = map[uint16]string{
+	0x1301: "TLS_AES_128_GCM_SHA256",
+	0x1302: "TLS_AES_256_GCM_SHA384",
+	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
+	0x1304: "TLS_AES_128_CCM_SHA256",
+	0x1305: "TLS_AES_128_CCM_8_SHA256",
+}
+
+var extensionNames = map[uint16]string{
+	27:    "compress_certificate",
+	28:    "record_size_limit",
+	41:    "pre_shared_key",
+	42:    "early_data",
+	43:    "supported_versions",
+	44:    "cookie",
+	45:    "psk_key_exchange_modes",
+	47:    "certificate_authorities",
+	48:    "oid_filters",
+	49:    "post_handshake_auth",
+	50:    "signature_algorithms_cert",
+	51:    "key_share",
+	57:    "quic_transport_parameters",
+	17513: "application_settings",
+	65037: "encrypted_client_hello",
+}
+
+var alertNames = map[uint8]string{
+	0:   "close_notify",
+	10:  "unexpected_message",
+	20:  "bad_record_mac",
+	22:  "record_overflow",
+	40:  "handshake_failure",
+	42:  "bad_certificate",
+	43:  "unsupported_certificate",
+	44:  "certificate_revoked",
+	45:  "certificate_expired",
+	46:  "certificate_unknown",
+	47:  "illegal_parameter",
+	48:  "unknown_ca",
+	49:  "access_denied",
+	50:  "decode_error",
+	51:  "decrypt_error",
+	70:  "protocol_version",
+	71:  "insufficient_security",
+	80:  "internal_error",
+	86:  "inappropriate_fallback",
+	90:  "user_canceled",
+	109: "missing_extension",
+	110: "unsupported_extension",
+	112: "unrecognized_name",
+	113: "bad_certificate_status_response",
+	115: "unknown_psk_identity",
+	116: "certificate_required",
+	120: "no_application_protocol",
+}
+
+// isGrease tells whether a value is one of the GREASE values of RFC 8701,
+// which clients send at random and fingerprints leave out.
+func isGrease(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func versionName(version uint16) string {
+	if name, ok := tlsx.VersionReg[tlsx.Version(version)]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+func cipherSuiteName(cipherSuite uint16) string {
+	if isGrease(cipherSuite) {
+		return "GREASE"
+	}
+	if name, ok := cipherSuiteNames[cipherSuite]; ok {
+		return name
+	}
+	if name, ok := tlsx.CipherSuiteReg[tlsx.CipherSuite(cipherSuite)]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", cipherSuite)
+}
+
+func extensionName(extension uint16) string {
+	if isGrease(extension) {
+		return "GREASE"
+	}
+	if name, ok := extensionNames[extension]; ok {
+		return name
+	}
+	if name, ok := tlsx.ExtensionReg[tlsx.Extension(extension)]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", extension)
+}
+
+func alertName(description uint8) string {
+	if name, ok := alertNames[description]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", description)
+}
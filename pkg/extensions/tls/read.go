@@ -0,0 +1,343 @@
+package tls
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dreadl0ck/tlsx"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	recordChangeCipherSpec = 20
+	recordAlert            = 21
+	recordHandshake        = 22
+	recordApplicationData  = 23
+)
+
+const (
+	handshakeClientHello     = 1
+	handshakeServerHello     = 2
+	handshakeCertificate     = 11
+	handshakeServerHelloDone = 14
+)
+
+const (
+	recordHeaderLength    = 5
+	handshakeHeaderLength = 4
+	maxRecordLength       = 16384 + 2048
+	maxHandshakeLength    = 1 << 18
+	versionTLS13          = 0x0304
+)
+
+var errNotClientHello = errors.New("first handshake message is not a ClientHello")
+var errNotServerHello = errors.New("first handshake message is not a ServerHello")
+
+type record struct {
+	contentType uint8
+	version     uint16
+	fragment    []byte
+}
+
+func readRecord(b *bufio.Reader) (*record, error) {
+	header := make([]byte, recordHeaderLength)
+	if _, err := io.ReadFull(b, header); err != nil {
+		return nil, err
+	}
+
+	r := &record{
+		contentType: header[0],
+		version:     binary.BigEndian.Uint16(header[1:3]),
+	}
+	if r.contentType < recordChangeCipherSpec || r.contentType > recordApplicationData {
+		return nil, fmt.Errorf("invalid record type: %d", r.contentType)
+	}
+	if header[1] != 3 || header[2] > 4 {
+		return nil, fmt.Errorf("invalid record version: 0x%04x", r.version)
+	}
+
+	length := binary.BigEndian.Uint16(header[3:5])
+	if length == 0 || length > maxRecordLength {
+		return nil, fmt.Errorf("invalid record length: %d", length)
+	}
+
+	r.fragment = make([]byte, length)
+	if _, err := io.ReadFull(b, r.fragment); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// discardRecords reads the rest of a connection, which is encrypted.
+func discardRecords(b *bufio.Reader) error {
+	for {
+		if _, err := readRecord(b); err != nil {
+			return err
+		}
+	}
+}
+
+// connectionState keeps track of one side of a connection, whose handshake
+// messages can be fragmented across records or coalesced in one.
+type connectionState struct {
+	handshake     []byte
+	recordVersion uint16
+
+	// The handshake records that follow a ChangeCipherSpec are encrypted.
+	encrypted bool
+}
+
+func newConnectionState() *connectionState {
+	return &connectionState{}
+}
+
+// next returns the next handshake message that's in the clear, header
+// included, or else the next record.
+func (s *connectionState) next(b *bufio.Reader) ([]byte, *record, error) {
+	for {
+		if len(s.handshake) >= handshakeHeaderLength {
+			length := int(s.handshake[1])<<16 | int(s.handshake[2])<<8 | int(s.handshake[3])
+			if length > maxHandshakeLength {
+				return nil, nil, fmt.Errorf("handshake message is too long: %d", length)
+			}
+			if len(s.handshake) >= handshakeHeaderLength+length {
+				message := s.handshake[:handshakeHeaderLength+length]
+				s.handshake = s.handshake[handshakeHeaderLength+length:]
+				return message, nil, nil
+			}
+		}
+
+		r, err := readRecord(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if r.contentType != recordHandshake || s.encrypted {
+			if r.contentType == recordChangeCipherSpec {
+				s.encrypted = true
+			}
+			return nil, r, nil
+		}
+		s.recordVersion = r.version
+		s.handshake = append(s.handshake, r.fragment...)
+	}
+}
+
+// asRecord puts a handshake message back in a record of its own, which is
+// what tlsx parses.
+func (s *connectionState) asRecord(message []byte) []byte {
+	data := make([]byte, recordHeaderLength, recordHeaderLength+len(message))
+	data[0] = recordHandshake
+	binary.BigEndian.PutUint16(data[1:3], s.recordVersion)
+	binary.BigEndian.PutUint16(data[3:5], uint16(len(message)))
+	return append(data, message...)
+}
+
+// checkClientHello checks the lengths in a ClientHello message, which tlsx
+// doesn't do for every field and extension that it parses.
+func checkClientHello(message []byte) bool {
+	s := cryptobyte.String(message)
+	var body, sessionID, cipherSuites, compressionMethods cryptobyte.String
+	if !s.Skip(1) || !s.ReadUint24LengthPrefixed(&body) || !s.Empty() ||
+		!body.Skip(2+32) ||
+		!body.ReadUint8LengthPrefixed(&sessionID) ||
+		!body.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!body.ReadUint8LengthPrefixed(&compressionMethods) {
+		return false
+	}
+	if body.Empty() {
+		return true
+	}
+
+	var extensions cryptobyte.String
+	if !body.ReadUint16LengthPrefixed(&extensions) || !body.Empty() {
+		return false
+	}
+	for !extensions.Empty() {
+		var extension uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&extension) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return false
+		}
+
+		switch tlsx.Extension(extension) {
+		case tlsx.ExtServerName:
+			var names cryptobyte.String
+			if !data.ReadUint16LengthPrefixed(&names) || !data.Empty() {
+				return false
+			}
+			for !names.Empty() {
+				var name cryptobyte.String
+				if !names.Skip(1) || !names.ReadUint16LengthPrefixed(&name) {
+					return false
+				}
+			}
+		case tlsx.ExtALPN:
+			var protocols cryptobyte.String
+			if !data.ReadUint16LengthPrefixed(&protocols) || !data.Empty() {
+				return false
+			}
+			for !protocols.Empty() {
+				var protocol cryptobyte.String
+				if !protocols.ReadUint8LengthPrefixed(&protocol) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (s *connectionState) readClientHello(b *bufio.Reader) (*TlsRequest, error) {
+	message, _, err := s.next(b)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message[0] != handshakeClientHello || len(message) > 0xffff || !checkClientHello(message) {
+		return nil, errNotClientHello
+	}
+
+	hello := &tlsx.ClientHello{}
+	if err := hello.Unmarshal(s.asRecord(message)); err != nil {
+		return nil, err
+	}
+
+	request := &TlsRequest{
+		RecordVersion:   versionName(s.recordVersion),
+		Version:         versionName(uint16(hello.HandshakeVersion)),
+		ServerName:      hello.SNI,
+		Alpn:            hello.ALPNs,
+		SessionId:       hex.EncodeToString(hello.SessionID),
+		SupportedGroups: hello.SupportedGroups,
+		Ja3:             ja3(hello),
+	}
+	request.Ja3Hash = fingerprintHash(request.Ja3)
+	for _, cipherSuite := range hello.CipherSuites {
+		request.CipherSuites = append(request.CipherSuites, cipherSuiteName(uint16(cipherSuite)))
+	}
+	for _, extension := range hello.AllExtensions {
+		request.Extensions = append(request.Extensions, extensionName(extension))
+	}
+
+	return request, nil
+}
+
+// readServerHello reads the flight of a server up to the end of the part of
+// the handshake that's in the clear.
+func (s *connectionState) readServerHello(b *bufio.Reader) (*TlsResponse, error) {
+	message, r, err := s.next(b)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		if r.contentType == recordAlert && len(r.fragment) == 2 {
+			response := &TlsResponse{}
+			setAlert(response, r)
+			return response, nil
+		}
+		return nil, errNotServerHello
+	}
+	if message[0] != handshakeServerHello || len(message) > 0xffff {
+		return nil, errNotServerHello
+	}
+
+	hello := &tlsx.ServerHello{}
+	if err := hello.Unmarshal(s.asRecord(message)); err != nil {
+		return nil, err
+	}
+
+	version := hello.Vers
+	if hello.SupportedVersion != 0 {
+		version = hello.SupportedVersion
+	}
+
+	response := &TlsResponse{
+		Version:     versionName(version),
+		CipherSuite: cipherSuiteName(hello.CipherSuite),
+		Alpn:        hello.AlpnProtocol,
+		SessionId:   hex.EncodeToString(hello.SessionID),
+		Ja3s:        ja3s(hello),
+	}
+	response.Ja3sHash = fingerprintHash(response.Ja3s)
+	for _, extension := range hello.Extensions {
+		response.Extensions = append(response.Extensions, extensionName(extension))
+	}
+
+	// The rest of the handshake is encrypted since TLS 1.3
+	if version == versionTLS13 {
+		return response, nil
+	}
+
+	for {
+		message, r, err = s.next(b)
+		if err != nil {
+			// The flight is cut short, but the hello is known
+			return response, nil
+		}
+		if r != nil {
+			if r.contentType == recordAlert && len(r.fragment) == 2 && !s.encrypted {
+				setAlert(response, r)
+			}
+			return response, nil
+		}
+
+		switch message[0] {
+		case handshakeCertificate:
+			response.Certificates = parseCertificates(message[handshakeHeaderLength:])
+		case handshakeServerHelloDone:
+			return response, nil
+		}
+	}
+}
+
+func setAlert(response *TlsResponse, r *record) {
+	response.Alert = alertName(r.fragment[1])
+	response.AlertCode = int(r.fragment[1])
+}
+
+func readUint24(data []byte) int {
+	return int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+}
+
+// parseCertificates parses the chain of a Certificate message, leaving out
+// the certificates that can't be parsed.
+func parseCertificates(body []byte) (certificates []TlsCertificate) {
+	if len(body) < 3 {
+		return
+	}
+	length := readUint24(body)
+	body = body[3:]
+	if length < len(body) {
+		body = body[:length]
+	}
+
+	for len(body) >= 3 {
+		length = readUint24(body)
+		body = body[3:]
+		if length > len(body) {
+			return
+		}
+
+		cert, err := x509.ParseCertificate(body[:length])
+		body = body[length:]
+		if err != nil {
+			continue
+		}
+
+		certificates = append(certificates, TlsCertificate{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.String(),
+			NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+			DnsNames:     cert.DNSNames,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,62 @@
+package tls
+
+import (
+	"encoding/json"
+)
+
+type TlsPayload struct {
+	Data interface{}
+}
+
+type TlsPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h TlsPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type TlsWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+// TlsRequest is the ClientHello of a connection.
+type TlsRequest struct {
+	RecordVersion   string   `json:"recordVersion"`
+	Version         string   `json:"version"`
+	ServerName      string   `json:"serverName"`
+	Alpn            []string `json:"alpn"`
+	SessionId       string   `json:"sessionId,omitempty"`
+	CipherSuites    []string `json:"cipherSuites"`
+	Extensions      []string `json:"extensions"`
+	SupportedGroups []uint16 `json:"supportedGroups"`
+	Ja3             string   `json:"ja3"`
+	Ja3Hash         string   `json:"ja3Hash"`
+}
+
+// TlsResponse is the ServerHello of a connection, along with the certificate
+// chain when it's sent in the clear, which is before TLS 1.3. A server that
+// refuses the handshake only answers with an alert.
+type TlsResponse struct {
+	Version      string           `json:"version"`
+	CipherSuite  string           `json:"cipherSuite"`
+	Alpn         string           `json:"alpn"`
+	SessionId    string           `json:"sessionId,omitempty"`
+	Extensions   []string         `json:"extensions"`
+	Ja3s         string           `json:"ja3s"`
+	Ja3sHash     string           `json:"ja3sHash"`
+	Certificates []TlsCertificate `json:"certificates"`
+	Alert        string           `json:"alert,omitempty"`
+	AlertCode    int              `json:"alertCode,omitempty"`
+}
+
+type TlsCertificate struct {
+	Subject      string   `json:"subject"`
+	Issuer       string   `json:"issuer"`
+	SerialNumber string   `json:"serialNumber"`
+	NotBefore    string   `json:"notBefore"`
+	NotAfter     string   `json:"notAfter"`
+	DnsNames     []string `json:"dnsNames"`
+}
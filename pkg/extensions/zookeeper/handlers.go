@@ -0,0 +1,129 @@
+package zookeeper
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// connectionState keeps track of one side of a connection, which starts
+// with a ConnectRequest and its response unless it was captured midway.
+type connectionState struct {
+	first bool
+
+	// Only the xids of the operations of a client are unique, so they're
+	// counted in case one is reused, like the xid of auth.
+	occurrences map[int32]int
+}
+
+func newConnectionState() *connectionState {
+	return &connectionState{
+		first:       true,
+		occurrences: make(map[int32]int),
+	}
+}
+
+// readRequest reads a request and returns it with the key of its response.
+// The request is nil for a ping.
+func (s *connectionState) readRequest(b *bufio.Reader) (*ZookeeperRequest, string, error) {
+	data, err := readPacket(b)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if s.first {
+		s.first = false
+		if isConnectRequest(data) {
+			request, err := readConnectRequest(data)
+			return request, operationConnect, err
+		}
+	}
+
+	request, err := readRequest(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if request.OpCode == opPing {
+		return nil, "", nil
+	}
+	return request, s.xidKey(request.Xid), nil
+}
+
+// readResponse reads a response and returns it with the key of its
+// request, or a watch notification that has no request. Both are nil for a
+// ping.
+func (s *connectionState) readResponse(b *bufio.Reader) (*ZookeeperResponse, *ZookeeperRequest, string, error) {
+	data, err := readPacket(b)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if s.first {
+		s.first = false
+		if isConnectResponse(data) {
+			response, err := readConnectResponse(data)
+			return response, nil, operationConnect, err
+		}
+	}
+
+	response, err := readReply(data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	switch response.Xid {
+	case xidPing:
+		return nil, nil, "", nil
+	case xidNotification:
+		notification, err := readNotification(response)
+		return nil, notification, "", err
+	}
+	return response, nil, s.xidKey(response.Xid), nil
+}
+
+func (s *connectionState) xidKey(xid int32) string {
+	occurrence := s.occurrences[xid]
+	s.occurrences[xid]++
+	return fmt.Sprintf("%d_%d", xid, occurrence)
+}
+
+func identity(tcpID *api.TcpID, isClient bool, key string) string {
+	if isClient {
+		return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.SrcIP, tcpID.DstIP, tcpID.SrcPort, tcpID.DstPort, key)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", tcpID.DstIP, tcpID.SrcIP, tcpID.DstPort, tcpID.SrcPort, key)
+}
+
+func handleClientStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, request *ZookeeperRequest, key string, reqResMatcher *requestResponseMatcher) {
+	item := reqResMatcher.registerRequest(identity(tcpID, true, key), request, captureTime, progress.Current())
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.SrcIP,
+			ClientPort: tcpID.SrcPort,
+			ServerIP:   tcpID.DstIP,
+			ServerPort: tcpID.DstPort,
+			IsOutgoing: true,
+		}
+		emitter.Emit(item)
+	}
+}
+
+func handleServerStream(progress *api.ReadProgress, tcpID *api.TcpID, captureTime time.Time, emitter api.Emitter, response *ZookeeperResponse, notification *ZookeeperRequest, key string, reqResMatcher *requestResponseMatcher) {
+	var item *api.OutputChannelItem
+	if notification != nil {
+		item = reqResMatcher.prepareNotification(notification, captureTime, progress.Current())
+	} else {
+		item = reqResMatcher.registerResponse(identity(tcpID, false, key), response, captureTime, progress.Current())
+	}
+	if item != nil {
+		item.ConnectionInfo = &api.ConnectionInfo{
+			ClientIP:   tcpID.DstIP,
+			ClientPort: tcpID.DstPort,
+			ServerIP:   tcpID.SrcIP,
+			ServerPort: tcpID.SrcPort,
+			IsOutgoing: false,
+		}
+		emitter.Emit(item)
+	}
+}
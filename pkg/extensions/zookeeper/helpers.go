@@ -0,0 +1,305 @@
+package zookeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+func representRequest(request map[string]interface{}) (repRequest []interface{}) {
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "Operation",
+			Value:    request["operation"].(string),
+			Selector: `request.operation`,
+		},
+		{
+			Name:     "XID",
+			Value:    request["xid"].(float64),
+			Selector: `request.xid`,
+		},
+		{
+			Name:     "Path",
+			Value:    request["path"],
+			Selector: `request.path`,
+		},
+		{
+			Name:     "Version",
+			Value:    request["version"],
+			Selector: `request.version`,
+		},
+		{
+			Name:     "Watch",
+			Value:    request["watch"],
+			Selector: `request.watch`,
+		},
+		{
+			Name:     "Mode",
+			Value:    request["mode"],
+			Selector: `request.mode`,
+		},
+		{
+			Name:     "TTL",
+			Value:    request["ttl"],
+			Selector: `request.ttl`,
+		},
+		{
+			Name:     "Data Size",
+			Value:    request["dataSize"],
+			Selector: `request.dataSize`,
+		},
+		{
+			Name:     "Scheme",
+			Value:    request["scheme"],
+			Selector: `request.scheme`,
+		},
+		{
+			Name:     "Session ID",
+			Value:    request["sessionId"],
+			Selector: `request.sessionId`,
+		},
+		{
+			Name:     "Timeout",
+			Value:    request["timeout"],
+			Selector: `request.timeout`,
+		},
+		{
+			Name:     "Last Zxid Seen",
+			Value:    request["lastZxidSeen"],
+			Selector: `request.lastZxidSeen`,
+		},
+		{
+			Name:     "Read Only",
+			Value:    request["readOnly"],
+			Selector: `request.readOnly`,
+		},
+		{
+			Name:     "Event Type",
+			Value:    request["eventType"],
+			Selector: `request.eventType`,
+		},
+		{
+			Name:     "State",
+			Value:    request["state"],
+			Selector: `request.state`,
+		},
+		{
+			Name:     "Zxid",
+			Value:    request["zxid"],
+			Selector: `request.zxid`,
+		},
+	})
+	repRequest = append(repRequest, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if data, ok := request["data"].(string); ok && data != "" {
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Data",
+			Encoding: "base64",
+			Data:     data,
+			Selector: `request.data`,
+		})
+	}
+
+	if acl, ok := request["acl"].([]interface{}); ok && len(acl) > 0 {
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "ACL",
+			Data:  representACL(acl, `request.acl`),
+		})
+	}
+
+	if watches, ok := request["watches"].(map[string]interface{}); ok && len(watches) > 0 {
+		table := make(map[string]interface{})
+		for kind, paths := range watches {
+			var names []string
+			for _, path := range paths.([]interface{}) {
+				names = append(names, path.(string))
+			}
+			table[kind] = strings.Join(names, " ")
+		}
+		repRequest = append(repRequest, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Watches",
+			Data:  representMapAsTable(table, `request.watches`),
+		})
+	}
+
+	if ops, ok := request["ops"].([]interface{}); ok {
+		data, _ := json.MarshalIndent(ops, "", "  ")
+		repRequest = append(repRequest, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Operations",
+			MimeType: "application/json",
+			Data:     string(data),
+			Selector: `request.ops`,
+		})
+	}
+
+	return
+}
+
+func representResponse(response map[string]interface{}) (repResponse []interface{}) {
+	repResponse = make([]interface{}, 0)
+
+	if response["error"].(string) == "" {
+		// A notification isn't answered
+		return
+	}
+
+	details, _ := json.Marshal([]api.TableData{
+		{
+			Name:     "XID",
+			Value:    response["xid"].(float64),
+			Selector: `response.xid`,
+		},
+		{
+			Name:     "Zxid",
+			Value:    response["zxid"].(float64),
+			Selector: `response.zxid`,
+		},
+		{
+			Name:     "Error Code",
+			Value:    response["err"].(float64),
+			Selector: `response.err`,
+		},
+		{
+			Name:     "Error",
+			Value:    response["error"].(string),
+			Selector: `response.error`,
+		},
+		{
+			Name:     "Path",
+			Value:    response["path"],
+			Selector: `response.path`,
+		},
+		{
+			Name:     "Data Size",
+			Value:    response["dataSize"],
+			Selector: `response.dataSize`,
+		},
+		{
+			Name:     "Count",
+			Value:    response["count"],
+			Selector: `response.count`,
+		},
+		{
+			Name:     "Session ID",
+			Value:    response["sessionId"],
+			Selector: `response.sessionId`,
+		},
+		{
+			Name:     "Timeout",
+			Value:    response["timeout"],
+			Selector: `response.timeout`,
+		},
+		{
+			Name:     "Read Only",
+			Value:    response["readOnly"],
+			Selector: `response.readOnly`,
+		},
+	})
+	repResponse = append(repResponse, api.SectionData{
+		Type:  api.TABLE,
+		Title: "Details",
+		Data:  string(details),
+	})
+
+	if stat, ok := response["stat"].(map[string]interface{}); ok {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Stat",
+			Data:  representMapAsTable(stat, `response.stat`),
+		})
+	}
+
+	if data, ok := response["data"].(string); ok && data != "" {
+		repResponse = append(repResponse, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Data",
+			Encoding: "base64",
+			Data:     data,
+			Selector: `response.data`,
+		})
+	}
+
+	if children, ok := response["children"].([]interface{}); ok && len(children) > 0 {
+		var table []api.TableData
+		for i, child := range children {
+			table = append(table, api.TableData{
+				Name:     fmt.Sprint(i),
+				Value:    child,
+				Selector: fmt.Sprintf("response.children[%d]", i),
+			})
+		}
+		obj, _ := json.Marshal(table)
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "Children",
+			Data:  string(obj),
+		})
+	}
+
+	if acl, ok := response["acl"].([]interface{}); ok && len(acl) > 0 {
+		repResponse = append(repResponse, api.SectionData{
+			Type:  api.TABLE,
+			Title: "ACL",
+			Data:  representACL(acl, `response.acl`),
+		})
+	}
+
+	if results, ok := response["results"].([]interface{}); ok {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		repResponse = append(repResponse, api.SectionData{
+			Type:     api.BODY,
+			Title:    "Results",
+			MimeType: "application/json",
+			Data:     string(data),
+			Selector: `response.results`,
+		})
+	}
+
+	return
+}
+
+func representACL(acl []interface{}, selectorPrefix string) string {
+	var table []api.TableData
+	for i, entry := range acl {
+		e := entry.(map[string]interface{})
+		table = append(table, api.TableData{
+			Name:     fmt.Sprintf("%s:%s", e["scheme"], e["id"]),
+			Value:    e["perms"],
+			Selector: fmt.Sprintf("%s[%d].perms", selectorPrefix, i),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
+
+func representMapAsTable(mapToTable map[string]interface{}, selectorPrefix string) string {
+	keys := make([]string, 0, len(mapToTable))
+	for k := range mapToTable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table []api.TableData
+	for _, key := range keys {
+		table = append(table, api.TableData{
+			Name:     key,
+			Value:    mapToTable[key],
+			Selector: fmt.Sprintf("%s.%s", selectorPrefix, key),
+		})
+	}
+
+	obj, _ := json.Marshal(table)
+	return string(obj)
+}
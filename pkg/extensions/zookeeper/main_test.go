@@ -0,0 +1,201 @@
+package zookeeper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+	"github.com/kubeshark/worker/pkg/extensions/internal/dissecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	dissector := NewDissector()
+	extension := &api.Extension{}
+	dissector.Register(extension)
+	assert.Equal(t, "zookeeper", extension.Protocol.Name)
+}
+
+func TestMacros(t *testing.T) {
+	expectedMacros := map[string]string{
+		"zookeeper": `protocol.name == "zookeeper"`,
+	}
+	dissector := NewDissector()
+	macros := dissector.Macros()
+	assert.Equal(t, expectedMacros, macros)
+}
+
+// raw is a part of a record that's already encoded, like a nested record.
+type raw []byte
+
+// record encodes the jute fields of a record, which are int32, int64,
+// bool, string, []byte or raw.
+func record(fields ...interface{}) raw {
+	var b bytes.Buffer
+	for _, field := range fields {
+		switch v := field.(type) {
+		case raw:
+			b.Write(v)
+		case string:
+			_ = binary.Write(&b, binary.BigEndian, int32(len(v)))
+			b.WriteString(v)
+		case []byte:
+			_ = binary.Write(&b, binary.BigEndian, int32(len(v)))
+			b.Write(v)
+		default:
+			_ = binary.Write(&b, binary.BigEndian, v)
+		}
+	}
+	return b.Bytes()
+}
+
+func packet(fields ...interface{}) []byte {
+	data := record(fields...)
+	return append(record(int32(len(data))), data...)
+}
+
+func stat(version int32, dataLength int32) raw {
+	return record(int64(10), int64(11), int64(1000), int64(2000), version, int32(0), int32(0), int64(0), dataLength, int32(0), int64(10))
+}
+
+func dissect(t *testing.T, client []byte, server []byte) map[string]*api.Entry {
+	entries := make(map[string]*api.Entry)
+	for _, entry := range dissecttest.Entries(t, NewDissector(), dissecttest.ServerFirst, client, server) {
+		entries[entry.Request["operation"].(string)] = entry
+	}
+	return entries
+}
+
+func TestDissect(t *testing.T) {
+	password := make([]byte, 16)
+	acl := record(int32(1), int32(31), "world", "anyone")
+
+	client := bytes.Join([][]byte{
+		packet(int32(0), int64(0), int32(30000), int64(0), password, false),
+		packet(int32(1), int32(opCreate), "/app/lock-", "me", acl, int32(3)),
+		packet(int32(2), int32(opGetData), "/app/config", true),
+		packet(int32(xidPing), int32(opPing)),
+		packet(int32(3), int32(opExists), "/missing", false),
+		packet(int32(4), int32(opMulti),
+			int32(opCreate), false, int32(-1), "/app/a", []byte{}, record(int32(0)), int32(0),
+			int32(opDelete), false, int32(-1), "/app/b", int32(2),
+			int32(-1), true, int32(-1),
+		),
+		packet(int32(5), int32(opSetData), "/app/config", "v2", int32(1)),
+		packet(int32(6), int32(opCloseSession)),
+	}, nil)
+
+	server := bytes.Join([][]byte{
+		packet(int32(0), int32(30000), int64(0x1000a2b3c4d0000), password, false),
+		packet(int32(1), int64(100), int32(0), "/app/lock-0000000001"),
+		packet(int32(2), int64(100), int32(0), "v1", stat(1, 2)),
+		packet(int32(xidPing), int64(100), int32(0)),
+		packet(int32(xidNotification), int64(-1), int32(0), int32(3), int32(3), "/app/config"),
+		packet(int32(3), int64(101), int32(-101)),
+		packet(int32(4), int64(102), int32(0),
+			int32(opError), false, int32(0), int32(0),
+			int32(opError), false, int32(-103), int32(-103),
+			int32(-1), true, int32(-1),
+		),
+		packet(int32(5), int64(103), int32(0), stat(2, 2)),
+		packet(int32(6), int64(104), int32(0)),
+	}, nil)
+
+	entries := dissect(t, client, server)
+	assert.Len(t, entries, 8)
+	dissector := NewDissector()
+
+	connect := entries["connect"]
+	assert.Equal(t, float64(30000), connect.Request["timeout"])
+	assert.Equal(t, "0x0", connect.Request["sessionId"])
+	assert.Equal(t, "0x1000a2b3c4d0000", connect.Response["sessionId"])
+	assert.Equal(t, float64(0), connect.Response["err"])
+	summary := dissector.Summarize(connect)
+	assert.Equal(t, "0x1000a2b3c4d0000", summary.Summary)
+	assert.Equal(t, "connect", summary.Method)
+
+	create := entries["create"]
+	assert.Equal(t, "/app/lock-", create.Request["path"])
+	assert.Equal(t, "EPHEMERAL_SEQUENTIAL", create.Request["mode"])
+	assert.Equal(t, "me", create.Request["dataText"])
+	assert.Equal(t, "world", create.Request["acl"].([]interface{})[0].(map[string]interface{})["scheme"])
+	assert.Equal(t, "/app/lock-0000000001", create.Response["path"])
+	assert.Equal(t, float64(100), create.Response["zxid"])
+
+	getData := entries["getData"]
+	assert.Equal(t, true, getData.Request["watch"])
+	assert.Equal(t, "v1", getData.Response["dataText"])
+	assert.Equal(t, float64(1), getData.Response["stat"].(map[string]interface{})["version"])
+
+	notification := entries["notification"]
+	assert.Equal(t, float64(-1), notification.Request["xid"])
+	assert.Equal(t, "/app/config", notification.Request["path"])
+	assert.Equal(t, "NodeDataChanged", notification.Request["eventType"])
+	assert.Equal(t, "SyncConnected", notification.Request["state"])
+	assert.Equal(t, "", notification.Response["error"])
+
+	exists := entries["exists"]
+	assert.Equal(t, float64(-101), exists.Response["err"])
+	assert.Equal(t, "NONODE", exists.Response["error"])
+	assert.Nil(t, exists.Response["stat"])
+	summary = dissector.Summarize(exists)
+	assert.Equal(t, "/missing", summary.Summary)
+	assert.Equal(t, `request.path == "/missing"`, summary.SummaryQuery)
+	assert.Equal(t, -101, summary.Status)
+	assert.Equal(t, `response.err == -101`, summary.StatusQuery)
+
+	multi := entries["multi"]
+	ops := multi.Request["ops"].([]interface{})
+	assert.Len(t, ops, 2)
+	assert.Equal(t, "create", ops[0].(map[string]interface{})["operation"])
+	assert.Equal(t, "/app/b", ops[1].(map[string]interface{})["path"])
+	results := multi.Response["results"].([]interface{})
+	assert.Len(t, results, 2)
+	assert.Equal(t, "BADVERSION", results[1].(map[string]interface{})["error"])
+
+	setData := entries["setData"]
+	assert.Equal(t, float64(1), setData.Request["version"])
+	assert.Equal(t, float64(2), setData.Response["stat"].(map[string]interface{})["version"])
+
+	assert.Contains(t, entries, "closeSession")
+	assert.NotContains(t, entries, "ping")
+}
+
+func TestDissectSessionExpired(t *testing.T) {
+	password := make([]byte, 16)
+	client := packet(int32(0), int64(200), int32(30000), int64(0x1000a2b3c4d0000), password)
+	server := packet(int32(0), int32(0), int64(0), password)
+
+	entries := dissect(t, client, server)
+	connect := entries["connect"]
+	assert.Equal(t, "0x1000a2b3c4d0000", connect.Request["sessionId"])
+	assert.Equal(t, float64(200), connect.Request["lastZxidSeen"])
+	assert.Equal(t, float64(errSessionExpired), connect.Response["err"])
+	assert.Equal(t, "SESSIONEXPIRED", connect.Response["error"])
+	assert.Equal(t, `response.err == -112`, NewDissector().Summarize(connect).StatusQuery)
+}
+
+func TestDissectMidway(t *testing.T) {
+	client := packet(int32(42), int32(opGetChildren2), "/brokers/ids", false)
+	server := packet(int32(42), int64(7), int32(0), record(int32(2), "1", "2"), stat(0, 0))
+
+	entries := dissect(t, client, server)
+	getChildren := entries["getChildren2"]
+	assert.Equal(t, []interface{}{"1", "2"}, getChildren.Response["children"])
+	assert.NotNil(t, getChildren.Response["stat"])
+}
+
+func TestDissectRejectsOtherProtocols(t *testing.T) {
+	dissector := NewDissector()
+	stream := dissecttest.NewTcpStream()
+	reqResMatcher := dissector.NewResponseRequestMatcher()
+
+	for _, isClient := range []bool{true, false} {
+		reader := dissecttest.NewTcpReader(&api.ReadProgress{}, "", &api.TcpID{}, time.Time{}, stream, isClient, false, nil, nil, &api.CounterPair{}, reqResMatcher)
+		err := dissector.Dissect(bufio.NewReader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))), reader)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,125 @@
+package zookeeper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubeshark/worker/pkg/api"
+)
+
+// Key is `{client_ip}_{server_ip}_{client_port}_{server_port}_{xid}_{occurrence}`
+// and `{client_ip}_{server_ip}_{client_port}_{server_port}_connect` for the ConnectRequest.
+type requestResponseMatcher struct {
+	openMessagesMap *sync.Map
+}
+
+func createResponseRequestMatcher() api.RequestResponseMatcher {
+	return &requestResponseMatcher{openMessagesMap: &sync.Map{}}
+}
+
+func (matcher *requestResponseMatcher) GetMap() *sync.Map {
+	return matcher.openMessagesMap
+}
+
+func (matcher *requestResponseMatcher) SetMaxTry(value int) {
+}
+
+func (matcher *requestResponseMatcher) registerRequest(ident string, request *ZookeeperRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestZookeeperMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: ZookeeperPayload{
+			Data: &ZookeeperWrapper{
+				Method:  request.Operation,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+
+	if response, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		responseZookeeperMessage := response.(*api.GenericMessage)
+		if responseZookeeperMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(&requestZookeeperMessage, responseZookeeperMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &requestZookeeperMessage)
+	return nil
+}
+
+func (matcher *requestResponseMatcher) registerResponse(ident string, response *ZookeeperResponse, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	responseZookeeperMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: ZookeeperPayload{
+			Data: &ZookeeperWrapper{
+				Method:  "",
+				Url:     "",
+				Details: response,
+			},
+		},
+	}
+
+	if request, found := matcher.openMessagesMap.LoadAndDelete(ident); found {
+		// Type assertion always succeeds because all of the map's values are of api.GenericMessage type
+		requestZookeeperMessage := request.(*api.GenericMessage)
+		if !requestZookeeperMessage.IsRequest {
+			return nil
+		}
+		return matcher.preparePair(requestZookeeperMessage, &responseZookeeperMessage)
+	}
+
+	matcher.openMessagesMap.Store(ident, &responseZookeeperMessage)
+	return nil
+}
+
+// prepareNotification pairs a watch notification, which the server sends on
+// its own, with an empty response.
+func (matcher *requestResponseMatcher) prepareNotification(request *ZookeeperRequest, captureTime time.Time, captureSize int) *api.OutputChannelItem {
+	requestZookeeperMessage := api.GenericMessage{
+		IsRequest:   true,
+		CaptureTime: captureTime,
+		CaptureSize: captureSize,
+		Payload: ZookeeperPayload{
+			Data: &ZookeeperWrapper{
+				Method:  request.Operation,
+				Url:     "",
+				Details: request,
+			},
+		},
+	}
+	responseZookeeperMessage := api.GenericMessage{
+		IsRequest:   false,
+		CaptureTime: captureTime,
+		Payload: ZookeeperPayload{
+			Data: &ZookeeperWrapper{
+				Method:  "",
+				Url:     "",
+				Details: &ZookeeperResponse{Xid: request.Xid, Zxid: request.Zxid},
+			},
+		},
+	}
+	return matcher.preparePair(&requestZookeeperMessage, &responseZookeeperMessage)
+}
+
+func (matcher *requestResponseMatcher) preparePair(requestZookeeperMessage *api.GenericMessage, responseZookeeperMessage *api.GenericMessage) *api.OutputChannelItem {
+	request := requestZookeeperMessage.Payload.(ZookeeperPayload).Data.(*ZookeeperWrapper).Details.(*ZookeeperRequest)
+	response := responseZookeeperMessage.Payload.(ZookeeperPayload).Data.(*ZookeeperWrapper).Details.(*ZookeeperResponse)
+	// A body that doesn't match the operation is left out
+	_ = decodeReply(request, response)
+
+	return &api.OutputChannelItem{
+		Protocol:       protocol,
+		Timestamp:      requestZookeeperMessage.CaptureTime.UnixNano() / int64(time.Millisecond),
+		ConnectionInfo: nil,
+		Pair: &api.RequestResponsePair{
+			Request:  *requestZookeeperMessage,
+			Response: *responseZookeeperMessage,
+		},
+	}
+}
@@ -0,0 +1,786 @@
+package zookeeper
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const maxPacketSize = 16 * 1024 * 1024
+
+// The xids that aren't given to the operations of a client
+const (
+	xidNotification = -1
+	xidPing         = -2
+	xidAuth         = -4
+	xidSetWatches   = -8
+)
+
+const (
+	opNotification         = 0
+	opCreate               = 1
+	opDelete               = 2
+	opExists               = 3
+	opGetData              = 4
+	opSetData              = 5
+	opGetACL               = 6
+	opSetACL               = 7
+	opGetChildren          = 8
+	opSync                 = 9
+	opPing                 = 11
+	opGetChildren2         = 12
+	opCheck                = 13
+	opMulti                = 14
+	opCreate2              = 15
+	opReconfig             = 16
+	opCheckWatches         = 17
+	opRemoveWatches        = 18
+	opCreateContainer      = 19
+	opDeleteContainer      = 20
+	opCreateTTL            = 21
+	opMultiRead            = 22
+	opAuth                 = 100
+	opSetWatches           = 101
+	opSasl                 = 102
+	opGetEphemerals        = 103
+	opGetAllChildrenNumber = 104
+	opSetWatches2          = 105
+	opAddWatch             = 106
+	opWhoAmI               = 107
+	opCreateSession        = -10
+	opCloseSession         = -11
+	opError                = -1
+)
+
+// A ConnectRequest has no header, and a notification isn't requested, so
+// they're given operations of their own.
+const (
+	opConnect             = -100
+	operationConnect      = "connect"
+	operationNotification = "notification"
+)
+
+const connectRequestMinLength = 4 + 8 + 4 + 8 + 4
+
+var operations = map[int32]string{
+	opCreate:               "create",
+	opDelete:               "delete",
+	opExists:               "exists",
+	opGetData:              "getData",
+	opSetData:              "setData",
+	opGetACL:               "getACL",
+	opSetACL:               "setACL",
+	opGetChildren:          "getChildren",
+	opSync:                 "sync",
+	opPing:                 "ping",
+	opGetChildren2:         "getChildren2",
+	opCheck:                "check",
+	opMulti:                "multi",
+	opCreate2:              "create2",
+	opReconfig:             "reconfig",
+	opCheckWatches:         "checkWatches",
+	opRemoveWatches:        "removeWatches",
+	opCreateContainer:      "createContainer",
+	opDeleteContainer:      "deleteContainer",
+	opCreateTTL:            "createTTL",
+	opMultiRead:            "multiRead",
+	opAuth:                 "auth",
+	opSetWatches:           "setWatches",
+	opSasl:                 "sasl",
+	opGetEphemerals:        "getEphemerals",
+	opGetAllChildrenNumber: "getAllChildrenNumber",
+	opSetWatches2:          "setWatches2",
+	opAddWatch:             "addWatch",
+	opWhoAmI:               "whoAmI",
+	opCreateSession:        "createSession",
+	opCloseSession:         "closeSession",
+	opError:                "error",
+}
+
+const errSessionExpired = -112
+
+var errorCodes = map[int32]string{
+	0:    "OK",
+	-1:   "SYSTEMERROR",
+	-2:   "RUNTIMEINCONSISTENCY",
+	-3:   "DATAINCONSISTENCY",
+	-4:   "CONNECTIONLOSS",
+	-5:   "MARSHALLINGERROR",
+	-6:   "UNIMPLEMENTED",
+	-7:   "OPERATIONTIMEOUT",
+	-8:   "BADARGUMENTS",
+	-13:  "NEWCONFIGNOQUORUM",
+	-14:  "RECONFIGINPROGRESS",
+	-15:  "UNKNOWNSESSION",
+	-100: "APIERROR",
+	-101: "NONODE",
+	-102: "NOAUTH",
+	-103: "BADVERSION",
+	-108: "NOCHILDRENFOREPHEMERALS",
+	-110: "NODEEXISTS",
+	-111: "NOTEMPTY",
+	-112: "SESSIONEXPIRED",
+	-113: "INVALIDCALLBACK",
+	-114: "INVALIDACL",
+	-115: "AUTHFAILED",
+	-118: "SESSIONMOVED",
+	-119: "NOTREADONLY",
+	-120: "EPHEMERALONLOCALSESSION",
+	-121: "NOWATCHER",
+	-122: "RECONFIGDISABLED",
+	-123: "SESSIONCLOSEDREQUIRESASLAUTH",
+	-124: "QUOTAEXCEEDED",
+	-125: "THROTTLEDOP",
+}
+
+var createModes = map[int32]string{
+	0: "PERSISTENT",
+	1: "EPHEMERAL",
+	2: "PERSISTENT_SEQUENTIAL",
+	3: "EPHEMERAL_SEQUENTIAL",
+	4: "CONTAINER",
+	5: "PERSISTENT_WITH_TTL",
+	6: "PERSISTENT_SEQUENTIAL_WITH_TTL",
+}
+
+var eventTypes = map[int32]string{
+	-1: "None",
+	1:  "NodeCreated",
+	2:  "NodeDeleted",
+	3:  "NodeDataChanged",
+	4:  "NodeChildrenChanged",
+	5:  "DataWatchRemoved",
+	6:  "ChildWatchRemoved",
+	7:  "PersistentWatchRemoved",
+}
+
+var keeperStates = map[int32]string{
+	-1:   "Unknown",
+	0:    "Disconnected",
+	1:    "NoSyncConnected",
+	3:    "SyncConnected",
+	4:    "AuthFailed",
+	5:    "ConnectedReadOnly",
+	6:    "SaslAuthenticated",
+	7:    "Closed",
+	-112: "Expired",
+}
+
+var addWatchModes = map[int32]string{
+	0: "PERSISTENT",
+	1: "PERSISTENT_RECURSIVE",
+}
+
+// readPacket reads a packet, which is prefixed by its length.
+func readPacket(b *bufio.Reader) ([]byte, error) {
+	var length int32
+	if err := binary.Read(b, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length <= 0 || length > maxPacketSize {
+		return nil, fmt.Errorf("invalid packet length: %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(b, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// juteReader decodes the records of the jute serialization, which is
+// big-endian and prefixes the buffers, strings and vectors by their length.
+type juteReader struct {
+	data []byte
+	pos  int
+}
+
+var errShortRecord = errors.New("record is too short")
+
+func (r *juteReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *juteReader) readBool() (bool, error) {
+	if r.remaining() < 1 {
+		return false, errShortRecord
+	}
+	v := r.data[r.pos]
+	r.pos++
+	if v > 1 {
+		return false, fmt.Errorf("invalid boolean: %d", v)
+	}
+	return v == 1, nil
+}
+
+func (r *juteReader) readInt() (int32, error) {
+	if r.remaining() < 4 {
+		return 0, errShortRecord
+	}
+	v := int32(binary.BigEndian.Uint32(r.data[r.pos:]))
+	r.pos += 4
+	return v, nil
+}
+
+func (r *juteReader) readLong() (int64, error) {
+	if r.remaining() < 8 {
+		return 0, errShortRecord
+	}
+	v := int64(binary.BigEndian.Uint64(r.data[r.pos:]))
+	r.pos += 8
+	return v, nil
+}
+
+// readBuffer reads a buffer, which is nil if its length is -1.
+func (r *juteReader) readBuffer() ([]byte, error) {
+	length, err := r.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if length == -1 {
+		return nil, nil
+	}
+	if length < 0 || int(length) > r.remaining() {
+		return nil, fmt.Errorf("invalid length: %d", length)
+	}
+	v := r.data[r.pos : r.pos+int(length)]
+	r.pos += int(length)
+	return v, nil
+}
+
+func (r *juteReader) readString() (string, error) {
+	v, err := r.readBuffer()
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(v) {
+		return "", errors.New("invalid string")
+	}
+	return string(v), nil
+}
+
+func (r *juteReader) readVectorLength() (int, error) {
+	length, err := r.readInt()
+	if err != nil {
+		return 0, err
+	}
+	if length == -1 {
+		return 0, nil
+	}
+	if length < 0 || int(length) > r.remaining() {
+		return 0, fmt.Errorf("invalid vector length: %d", length)
+	}
+	return int(length), nil
+}
+
+func (r *juteReader) readStrings() ([]string, error) {
+	length, err := r.readVectorLength()
+	if err != nil {
+		return nil, err
+	}
+	strings := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		s, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		strings = append(strings, s)
+	}
+	return strings, nil
+}
+
+func (r *juteReader) readACL() ([]ZookeeperACL, error) {
+	length, err := r.readVectorLength()
+	if err != nil {
+		return nil, err
+	}
+	acl := make([]ZookeeperACL, 0, length)
+	for i := 0; i < length; i++ {
+		var entry ZookeeperACL
+		if entry.Perms, err = r.readInt(); err != nil {
+			return nil, err
+		}
+		if entry.Scheme, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if entry.ID, err = r.readString(); err != nil {
+			return nil, err
+		}
+		acl = append(acl, entry)
+	}
+	return acl, nil
+}
+
+func (r *juteReader) readStat() (*ZookeeperStat, error) {
+	stat := &ZookeeperStat{}
+	var err error
+	for _, v := range []*int64{&stat.Czxid, &stat.Mzxid, &stat.Ctime, &stat.Mtime} {
+		if *v, err = r.readLong(); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range []*int32{&stat.Version, &stat.Cversion, &stat.Aversion} {
+		if *v, err = r.readInt(); err != nil {
+			return nil, err
+		}
+	}
+	if stat.EphemeralOwner, err = r.readLong(); err != nil {
+		return nil, err
+	}
+	for _, v := range []*int32{&stat.DataLength, &stat.NumChildren} {
+		if *v, err = r.readInt(); err != nil {
+			return nil, err
+		}
+	}
+	if stat.Pzxid, err = r.readLong(); err != nil {
+		return nil, err
+	}
+	return stat, nil
+}
+
+// isConnectRequest tells a ConnectRequest, which starts a connection without
+// a header, by its protocol version and its length.
+func isConnectRequest(data []byte) bool {
+	if len(data) < connectRequestMinLength || binary.BigEndian.Uint32(data) != 0 {
+		return false
+	}
+	passwordLength := int(int32(binary.BigEndian.Uint32(data[connectRequestMinLength-4:])))
+	rest := len(data) - connectRequestMinLength - passwordLength
+	return passwordLength >= 0 && (rest == 0 || rest == 1)
+}
+
+func readConnectRequest(data []byte) (*ZookeeperRequest, error) {
+	r := &juteReader{data: data}
+	request := &ZookeeperRequest{Operation: operationConnect, OpCode: opConnect}
+	var err error
+	if request.ProtocolVersion, err = r.readInt(); err != nil {
+		return nil, err
+	}
+	if request.LastZxidSeen, err = r.readLong(); err != nil {
+		return nil, err
+	}
+	if request.Timeout, err = r.readInt(); err != nil {
+		return nil, err
+	}
+	sessionID, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	request.SessionID = formatSessionID(sessionID)
+	if _, err = r.readBuffer(); err != nil {
+		return nil, err
+	}
+	if r.remaining() > 0 {
+		if request.ReadOnly, err = r.readBool(); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// isConnectResponse tells a ConnectResponse the same way as a
+// ConnectRequest.
+func isConnectResponse(data []byte) bool {
+	const minLength = 4 + 4 + 8 + 4
+	if len(data) < minLength || binary.BigEndian.Uint32(data) != 0 {
+		return false
+	}
+	passwordLength := int(int32(binary.BigEndian.Uint32(data[minLength-4:])))
+	rest := len(data) - minLength - passwordLength
+	return passwordLength >= 0 && (rest == 0 || rest == 1)
+}
+
+// readConnectResponse reads the response of a ConnectRequest. A session
+// that expired is answered with a timeout of 0, which is given the error
+// of an expired session.
+func readConnectResponse(data []byte) (*ZookeeperResponse, error) {
+	r := &juteReader{data: data}
+	response := &ZookeeperResponse{}
+	var err error
+	if response.ProtocolVersion, err = r.readInt(); err != nil {
+		return nil, err
+	}
+	if response.Timeout, err = r.readInt(); err != nil {
+		return nil, err
+	}
+	sessionID, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	response.SessionID = formatSessionID(sessionID)
+	if _, err = r.readBuffer(); err != nil {
+		return nil, err
+	}
+	if r.remaining() > 0 {
+		if response.ReadOnly, err = r.readBool(); err != nil {
+			return nil, err
+		}
+	}
+	if response.Timeout <= 0 {
+		response.Err = errSessionExpired
+	}
+	response.Error = errorCodes[response.Err]
+	return response, nil
+}
+
+// readRequest reads a request, which starts with its xid and its operation.
+func readRequest(data []byte) (*ZookeeperRequest, error) {
+	r := &juteReader{data: data}
+	xid, err := r.readInt()
+	if err != nil {
+		return nil, err
+	}
+	opCode, err := r.readInt()
+	if err != nil {
+		return nil, err
+	}
+	request, err := readRequestBody(r, opCode)
+	if err != nil {
+		return nil, err
+	}
+	if r.remaining() > 0 {
+		return nil, fmt.Errorf("%d bytes left in the %s request", r.remaining(), request.Operation)
+	}
+	request.Xid = xid
+	return request, nil
+}
+
+func readRequestBody(r *juteReader, opCode int32) (request *ZookeeperRequest, err error) {
+	operation, ok := operations[opCode]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation: %d", opCode)
+	}
+	request = &ZookeeperRequest{Operation: operation, OpCode: opCode}
+
+	switch opCode {
+	case opCreate, opCreate2, opCreateContainer, opCreateTTL:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		if err = request.readData(r); err != nil {
+			return
+		}
+		if request.ACL, err = r.readACL(); err != nil {
+			return
+		}
+		if request.Flags, err = r.readInt(); err != nil {
+			return
+		}
+		request.Mode = createModes[request.Flags]
+		if opCode == opCreateTTL {
+			request.TTL, err = r.readLong()
+		}
+	case opDelete, opCheck:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		err = request.readVersion(r)
+	case opExists, opGetData, opGetChildren, opGetChildren2:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		request.Watch, err = r.readBool()
+	case opSetData:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		if err = request.readData(r); err != nil {
+			return
+		}
+		err = request.readVersion(r)
+	case opSetACL:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		if request.ACL, err = r.readACL(); err != nil {
+			return
+		}
+		err = request.readVersion(r)
+	case opGetACL, opSync, opDeleteContainer, opGetEphemerals, opGetAllChildrenNumber:
+		request.Path, err = r.readString()
+	case opCheckWatches, opRemoveWatches:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		var watcherType int32
+		watcherType, err = r.readInt()
+		request.Flags = watcherType
+	case opAddWatch:
+		if request.Path, err = r.readString(); err != nil {
+			return
+		}
+		if request.Flags, err = r.readInt(); err != nil {
+			return
+		}
+		request.Mode = addWatchModes[request.Flags]
+	case opMulti, opMultiRead:
+		request.Ops, err = readMultiRequest(r)
+	case opAuth:
+		if _, err = r.readInt(); err != nil {
+			return
+		}
+		if request.Scheme, err = r.readString(); err != nil {
+			return
+		}
+		// The credentials aren't kept
+		_, err = r.readBuffer()
+	case opSetWatches, opSetWatches2:
+		err = request.readWatches(r, opCode == opSetWatches2)
+	case opReconfig:
+		var servers [3]string
+		for i := range servers {
+			if servers[i], err = r.readString(); err != nil {
+				return
+			}
+		}
+		request.Data = base64.StdEncoding.EncodeToString([]byte(servers[2]))
+		request.DataText = servers[2]
+		_, err = r.readLong()
+	case opSasl:
+		_, err = r.readBuffer()
+	case opPing, opCloseSession, opWhoAmI, opCreateSession, opError:
+	}
+	return
+}
+
+func (request *ZookeeperRequest) readData(r *juteReader) error {
+	data, err := r.readBuffer()
+	if err != nil {
+		return err
+	}
+	request.Data, request.DataText, request.DataSize = encodeData(data)
+	return nil
+}
+
+func (request *ZookeeperRequest) readVersion(r *juteReader) error {
+	version, err := r.readInt()
+	if err != nil {
+		return err
+	}
+	request.Version = &version
+	return nil
+}
+
+func (request *ZookeeperRequest) readWatches(r *juteReader, persistent bool) (err error) {
+	if request.RelativeZxid, err = r.readLong(); err != nil {
+		return
+	}
+	kinds := []string{"data", "exist", "child"}
+	if persistent {
+		kinds = append(kinds, "persistent", "persistentRecursive")
+	}
+	request.Watches = make(map[string][]string)
+	for _, kind := range kinds {
+		var paths []string
+		if paths, err = r.readStrings(); err != nil {
+			return
+		}
+		if len(paths) > 0 {
+			request.Watches[kind] = paths
+		}
+	}
+	return
+}
+
+// readMultiRequest reads the operations of a transaction, each after a
+// header, up to a header that's done.
+func readMultiRequest(r *juteReader) ([]ZookeeperRequest, error) {
+	ops := make([]ZookeeperRequest, 0)
+	for {
+		opCode, done, _, err := readMultiHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return ops, nil
+		}
+		op, err := readRequestBody(r, opCode)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, *op)
+	}
+}
+
+func readMultiHeader(r *juteReader) (opCode int32, done bool, err int32, readErr error) {
+	if opCode, readErr = r.readInt(); readErr != nil {
+		return
+	}
+	if done, readErr = r.readBool(); readErr != nil {
+		return
+	}
+	err, readErr = r.readInt()
+	return
+}
+
+// readReply reads the header of a reply. The body can only be read knowing
+// the operation of the request, so it's kept until the reply is paired.
+func readReply(data []byte) (*ZookeeperResponse, error) {
+	r := &juteReader{data: data}
+	response := &ZookeeperResponse{}
+	var err error
+	if response.Xid, err = r.readInt(); err != nil {
+		return nil, err
+	}
+	if response.Zxid, err = r.readLong(); err != nil {
+		return nil, err
+	}
+	if response.Err, err = r.readInt(); err != nil {
+		return nil, err
+	}
+	var ok bool
+	if response.Error, ok = errorCodes[response.Err]; !ok {
+		return nil, fmt.Errorf("unknown error code: %d", response.Err)
+	}
+	response.body = data[r.pos:]
+	return response, nil
+}
+
+// readNotification reads a watch event, which the server sends with an xid
+// of -1.
+func readNotification(response *ZookeeperResponse) (*ZookeeperRequest, error) {
+	r := &juteReader{data: response.body}
+	request := &ZookeeperRequest{
+		Xid:       xidNotification,
+		Operation: operationNotification,
+		OpCode:    opNotification,
+	}
+	eventType, err := r.readInt()
+	if err != nil {
+		return nil, err
+	}
+	state, err := r.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if request.Path, err = r.readString(); err != nil {
+		return nil, err
+	}
+	if r.remaining() > 0 {
+		return nil, fmt.Errorf("%d bytes left in the notification", r.remaining())
+	}
+	request.EventType = eventTypes[eventType]
+	request.State = keeperStates[state]
+	request.Zxid = response.Zxid
+	return request, nil
+}
+
+// decodeReply reads the body of a reply to the request, which isn't there
+// unless the operation succeeded.
+func decodeReply(request *ZookeeperRequest, response *ZookeeperResponse) error {
+	if len(response.body) == 0 {
+		return nil
+	}
+	r := &juteReader{data: response.body}
+	if err := readReplyBody(r, request.OpCode, response); err != nil {
+		return err
+	}
+	if r.remaining() > 0 {
+		return fmt.Errorf("%d bytes left in the %s reply", r.remaining(), request.Operation)
+	}
+	return nil
+}
+
+func readReplyBody(r *juteReader, opCode int32, response *ZookeeperResponse) (err error) {
+	switch opCode {
+	case opCreate, opSync:
+		response.Path, err = r.readString()
+	case opCreate2, opCreateContainer, opCreateTTL:
+		if response.Path, err = r.readString(); err != nil {
+			return
+		}
+		response.Stat, err = r.readStat()
+	case opExists, opSetData, opSetACL:
+		response.Stat, err = r.readStat()
+	case opGetData, opReconfig:
+		var data []byte
+		if data, err = r.readBuffer(); err != nil {
+			return
+		}
+		response.Data, response.DataText, response.DataSize = encodeData(data)
+		response.Stat, err = r.readStat()
+	case opGetACL:
+		if response.ACL, err = r.readACL(); err != nil {
+			return
+		}
+		response.Stat, err = r.readStat()
+	case opGetChildren, opGetEphemerals:
+		response.Children, err = r.readStrings()
+	case opGetChildren2:
+		if response.Children, err = r.readStrings(); err != nil {
+			return
+		}
+		response.Stat, err = r.readStat()
+	case opGetAllChildrenNumber:
+		var count int32
+		count, err = r.readInt()
+		response.Count = &count
+	case opWhoAmI:
+		var length int
+		if length, err = r.readVectorLength(); err != nil {
+			return
+		}
+		for i := 0; i < length; i++ {
+			var info ZookeeperClientInfo
+			if info.AuthScheme, err = r.readString(); err != nil {
+				return
+			}
+			if info.User, err = r.readString(); err != nil {
+				return
+			}
+			response.ClientInfo = append(response.ClientInfo, info)
+		}
+	case opMulti, opMultiRead:
+		response.Results, err = readMultiReply(r)
+	case opSasl:
+		_, err = r.readBuffer()
+	}
+	return
+}
+
+// readMultiReply reads the results of a transaction. A failed operation
+// only has its error.
+func readMultiReply(r *juteReader) ([]ZookeeperResponse, error) {
+	results := make([]ZookeeperResponse, 0)
+	for {
+		opCode, done, errCode, err := readMultiHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return results, nil
+		}
+
+		result := ZookeeperResponse{Operation: operations[opCode]}
+		if opCode == opError {
+			if result.Err, err = r.readInt(); err != nil {
+				return nil, err
+			}
+		} else {
+			result.Err = errCode
+			if err = readReplyBody(r, opCode, &result); err != nil {
+				return nil, err
+			}
+		}
+		result.Error = errorCodes[result.Err]
+		results = append(results, result)
+	}
+}
+
+// encodeData returns the data in base64, and as text if it's valid UTF-8.
+func encodeData(data []byte) (encoded string, text string, size int) {
+	encoded = base64.StdEncoding.EncodeToString(data)
+	if utf8.Valid(data) {
+		text = string(data)
+	}
+	return encoded, text, len(data)
+}
+
+// formatSessionID formats a session id the way ZooKeeper logs it.
+func formatSessionID(sessionID int64) string {
+	return fmt.Sprintf("0x%x", uint64(sessionID))
+}
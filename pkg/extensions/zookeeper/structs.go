@@ -0,0 +1,104 @@
+package zookeeper
+
+import (
+	"encoding/json"
+)
+
+type ZookeeperPayload struct {
+	Data interface{}
+}
+
+type ZookeeperPayloader interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (h ZookeeperPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Data)
+}
+
+type ZookeeperWrapper struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Details interface{} `json:"details"`
+}
+
+type ZookeeperACL struct {
+	Perms  int32  `json:"perms"`
+	Scheme string `json:"scheme"`
+	ID     string `json:"id"`
+}
+
+type ZookeeperStat struct {
+	Czxid          int64 `json:"czxid"`
+	Mzxid          int64 `json:"mzxid"`
+	Ctime          int64 `json:"ctime"`
+	Mtime          int64 `json:"mtime"`
+	Version        int32 `json:"version"`
+	Cversion       int32 `json:"cversion"`
+	Aversion       int32 `json:"aversion"`
+	EphemeralOwner int64 `json:"ephemeralOwner"`
+	DataLength     int32 `json:"dataLength"`
+	NumChildren    int32 `json:"numChildren"`
+	Pzxid          int64 `json:"pzxid"`
+}
+
+type ZookeeperClientInfo struct {
+	AuthScheme string `json:"authScheme"`
+	User       string `json:"user"`
+}
+
+// ZookeeperRequest is an operation of a client, a ConnectRequest or a watch
+// notification of the server. The data is base64 encoded and also given as
+// text when it's valid UTF-8.
+type ZookeeperRequest struct {
+	Xid             int32               `json:"xid"`
+	Operation       string              `json:"operation"`
+	OpCode          int32               `json:"opCode"`
+	Path            string              `json:"path,omitempty"`
+	Data            string              `json:"data,omitempty"`
+	DataText        string              `json:"dataText,omitempty"`
+	DataSize        int                 `json:"dataSize,omitempty"`
+	Version         *int32              `json:"version,omitempty"`
+	Watch           bool                `json:"watch,omitempty"`
+	Flags           int32               `json:"flags,omitempty"`
+	Mode            string              `json:"mode,omitempty"`
+	TTL             int64               `json:"ttl,omitempty"`
+	ACL             []ZookeeperACL      `json:"acl,omitempty"`
+	Ops             []ZookeeperRequest  `json:"ops,omitempty"`
+	Scheme          string              `json:"scheme,omitempty"`
+	RelativeZxid    int64               `json:"relativeZxid,omitempty"`
+	Watches         map[string][]string `json:"watches,omitempty"`
+	ProtocolVersion int32               `json:"protocolVersion,omitempty"`
+	LastZxidSeen    int64               `json:"lastZxidSeen,omitempty"`
+	Timeout         int32               `json:"timeout,omitempty"`
+	SessionID       string              `json:"sessionId,omitempty"`
+	ReadOnly        bool                `json:"readOnly,omitempty"`
+	EventType       string              `json:"eventType,omitempty"`
+	State           string              `json:"state,omitempty"`
+	Zxid            int64               `json:"zxid,omitempty"`
+}
+
+type ZookeeperResponse struct {
+	Xid             int32                 `json:"xid"`
+	Zxid            int64                 `json:"zxid"`
+	Err             int32                 `json:"err"`
+	Error           string                `json:"error"`
+	Operation       string                `json:"operation,omitempty"`
+	Path            string                `json:"path,omitempty"`
+	Data            string                `json:"data,omitempty"`
+	DataText        string                `json:"dataText,omitempty"`
+	DataSize        int                   `json:"dataSize,omitempty"`
+	Stat            *ZookeeperStat        `json:"stat,omitempty"`
+	ACL             []ZookeeperACL        `json:"acl,omitempty"`
+	Children        []string              `json:"children,omitempty"`
+	Count           *int32                `json:"count,omitempty"`
+	ClientInfo      []ZookeeperClientInfo `json:"clientInfo,omitempty"`
+	Results         []ZookeeperResponse   `json:"results,omitempty"`
+	ProtocolVersion int32                 `json:"protocolVersion,omitempty"`
+	Timeout         int32                 `json:"timeout,omitempty"`
+	SessionID       string                `json:"sessionId,omitempty"`
+	ReadOnly        bool                  `json:"readOnly,omitempty"`
+
+	// The body of a reply is read once it's paired with its request
+	body []byte
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	httpExt "github.com/kubeshark/worker/pkg/extensions/http"
+)
+
+type grpcServicesResponse struct {
+	Services []string `json:"services"`
+}
+
+func GetGrpcServices(c *gin.Context) {
+	c.JSON(http.StatusOK, grpcServicesResponse{Services: httpExt.GrpcServices()})
+}
+
+// PostGrpcDescriptors loads a serialized FileDescriptorSet, e.g. the output
+// of `protoc --include_imports --descriptor_set_out`, that the gRPC messages
+// are decoded with.
+func PostGrpcDescriptors(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	services, err := httpExt.LoadGrpcDescriptorSet(data)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, grpcServicesResponse{Services: services})
+}
@@ -62,6 +62,7 @@ func GetItem(c *gin.Context, opts *misc.Opts) {
 			}
 			assembler.ProcessPacket(packetInfo, false)
 		}
+		assembler.FlushDnsQueries()
 	}()
 
 	defer s.Close()
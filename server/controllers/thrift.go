@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	thriftExt "github.com/kubeshark/worker/pkg/extensions/thrift"
+)
+
+type thriftServicesResponse struct {
+	Services []string `json:"services"`
+}
+
+func GetThriftServices(c *gin.Context) {
+	c.JSON(http.StatusOK, thriftServicesResponse{Services: thriftExt.ThriftServices()})
+}
+
+// PostThriftIDL loads a Thrift IDL file that names the fields of the Thrift
+// messages. The files it includes are to be posted separately.
+func PostThriftIDL(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	services, err := thriftExt.LoadThriftIDL(data)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, thriftServicesResponse{Services: services})
+}
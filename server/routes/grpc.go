@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/worker/server/controllers"
+)
+
+func GrpcRoutes(ginApp *gin.Engine) {
+	routeGroup := ginApp.Group("/grpc")
+
+	routeGroup.GET("/services", controllers.GetGrpcServices)
+	routeGroup.POST("/descriptors", controllers.PostGrpcDescriptors)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/worker/server/controllers"
+)
+
+func ThriftRoutes(ginApp *gin.Engine) {
+	routeGroup := ginApp.Group("/thrift")
+
+	routeGroup.GET("/services", controllers.GetThriftServices)
+	routeGroup.POST("/idl", controllers.PostThriftIDL)
+}
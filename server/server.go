@@ -39,6 +39,8 @@ func Build(opts *misc.Opts, procfs string, updateTargetsQueue *queue.Queue) *gin
 	routes.ScriptsRoutes(ginApp)
 	routes.JobsRoutes(ginApp)
 	routes.SelfRoutes(ginApp)
+	routes.GrpcRoutes(ginApp)
+	routes.ThriftRoutes(ginApp)
 
 	return ginApp
 }
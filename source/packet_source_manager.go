@@ -2,6 +2,7 @@ package source
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/kubeshark/worker/misc"
@@ -17,6 +18,7 @@ type PacketSourceManagerConfig struct {
 	procfs        string
 	interfaceName string
 	packetCapture string
+	excludedPorts []string
 }
 
 type PacketSourceManager struct {
@@ -30,6 +32,7 @@ func NewPacketSourceManager(
 	mtls bool,
 	pods []v1.Pod,
 	packetCapture string,
+	excludedPorts string,
 	packets chan<- TcpPacketInfo,
 ) (*PacketSourceManager, error) {
 	hostSource, err := NewHostPacketSource("", interfaceName, packetCapture)
@@ -48,6 +51,7 @@ func NewPacketSourceManager(
 		procfs:        procfs,
 		interfaceName: interfaceName,
 		packetCapture: packetCapture,
+		excludedPorts: parseExcludedPorts(excludedPorts),
 	}
 
 	go hostSource.ReadPackets(packets, true, true)
@@ -121,14 +125,47 @@ func (m *PacketSourceManager) getRelevantPids(procfs string, pods []v1.Pod) []st
 	return relevantPids
 }
 
-func buildBPFExpr(pods []v1.Pod) string {
+// parseExcludedPorts parses a comma separated list of the ports that are
+// left out of the capture, like the encrypted traffic on 443.
+func parseExcludedPorts(ports string) []string {
+	excludedPorts := make([]string, 0)
+	for _, port := range strings.Split(ports, ",") {
+		port = strings.TrimSpace(port)
+		if port == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			log.Warn().Str("port", port).Msg("Ignoring an invalid excluded port:")
+			continue
+		}
+		excludedPorts = append(excludedPorts, port)
+	}
+	return excludedPorts
+}
+
+func buildExcludedPortsExpr(excludedPorts []string) string {
+	portsFilter := make([]string, 0)
+
+	for _, port := range excludedPorts {
+		portsFilter = append(portsFilter, fmt.Sprintf("port not %s", port))
+	}
+
+	return strings.Join(portsFilter, " and ")
+}
+
+func buildBPFExpr(pods []v1.Pod, excludedPorts []string) string {
 	hostsFilter := make([]string, 0)
 
 	for _, pod := range pods {
 		hostsFilter = append(hostsFilter, fmt.Sprintf("host %s", pod.Status.PodIP))
 	}
 
-	return fmt.Sprintf("%s and port not 443", strings.Join(hostsFilter, " or "))
+	expr := strings.Join(hostsFilter, " or ")
+	if portsExpr := buildExcludedPortsExpr(excludedPorts); portsExpr != "" {
+		expr = fmt.Sprintf("%s and %s", expr, portsExpr)
+	}
+
+	return expr
 }
 
 func (m *PacketSourceManager) setBPFFilter(pods []v1.Pod) {
@@ -140,10 +177,10 @@ func (m *PacketSourceManager) setBPFFilter(pods []v1.Pod) {
 	var expr string
 
 	if len(pods) > bpfFilterMaxPods {
-		log.Info().Msg(fmt.Sprintf("Too many pods for setting ebpf filter %d, setting just not 443", len(pods)))
-		expr = "port not 443"
+		expr = buildExcludedPortsExpr(m.config.excludedPorts)
+		log.Info().Msg(fmt.Sprintf("Too many pods for setting ebpf filter %d, setting just %q", len(pods), expr))
 	} else {
-		expr = buildBPFExpr(pods)
+		expr = buildBPFExpr(pods, m.config.excludedPorts)
 	}
 
 	log.Info().Msg(fmt.Sprintf("Setting pcap bpf filter %s", expr))
@@ -229,7 +229,7 @@ func initializePacketSources() error {
 	}
 
 	var err error
-	target.PacketSourceManager, err = source.NewPacketSourceManager(*procfs, *iface, *servicemesh, misc.TargetedPods, *packetCapture, target.MainPacketInputChan)
+	target.PacketSourceManager, err = source.NewPacketSourceManager(*procfs, *iface, *servicemesh, misc.TargetedPods, *packetCapture, *bpfExcludedPorts, target.MainPacketInputChan)
 	return err
 }
 